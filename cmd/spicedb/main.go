@@ -16,11 +16,13 @@ import (
 	"github.com/authzed/spicedb/pkg/cmd"
 	cmdutil "github.com/authzed/spicedb/pkg/cmd/server"
 	"github.com/authzed/spicedb/pkg/cmd/testserver"
+	"github.com/authzed/spicedb/pkg/discovery"
 )
 
 const (
-	hashringReplicationFactor = 20
-	backendsPerKey            = 1
+	hashringReplicationFactor   = 20
+	backendsPerKey              = 1
+	additionalBackendsPerHotKey = 2
 )
 
 var errParsing = errors.New("parsing error")
@@ -32,11 +34,15 @@ func main() {
 	// Enable Kubernetes gRPC resolver
 	kuberesolver.RegisterInCluster()
 
+	// Enable peer discovery gRPC resolvers
+	discovery.RegisterResolvers()
+
 	// Enable consistent hashring gRPC load balancer
 	balancer.Register(consistentbalancer.NewConsistentHashringBuilder(
 		xxhash.Sum64,
 		hashringReplicationFactor,
 		backendsPerKey,
+		consistentbalancer.HotKeySpread(additionalBackendsPerHotKey),
 	))
 
 	// Create a root command
@@ -62,6 +68,13 @@ func main() {
 	cmd.RegisterHeadFlags(headCmd)
 	rootCmd.AddCommand(headCmd)
 
+	// Add datastore commands
+	datastoreCmd := cmd.NewDatastoreCommand(rootCmd.Use)
+	datastoreCopyCmd := cmd.NewDatastoreCopyCommand(rootCmd.Use)
+	cmd.RegisterDatastoreCopyFlags(datastoreCopyCmd)
+	datastoreCmd.AddCommand(datastoreCopyCmd)
+	rootCmd.AddCommand(datastoreCmd)
+
 	// Add server commands
 	var serverConfig cmdutil.Config
 	serveCmd := cmd.NewServeCommand(rootCmd.Use, &serverConfig)
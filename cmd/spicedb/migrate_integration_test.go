@@ -20,7 +20,7 @@ import (
 	"github.com/authzed/spicedb/pkg/datastore"
 )
 
-var toSkip = []string{"memory"}
+var toSkip = []string{"memory", "sqlite"}
 
 func TestMigrate(t *testing.T) {
 	bridgeNetworkName := fmt.Sprintf("bridge-%s", uuid.New().String())
@@ -118,3 +118,18 @@ func (ch Changes) AsRevisionChanges() (changes []*datastore.RevisionChanges) {
 
 	return
 }
+
+// SendCheckpoint sends a checkpoint at the revision to a watcher which requested checkpoints and
+// has received all of the changes sent to it. Checkpoints are dropped for a watcher which is
+// behind, so that they do not take up space in its buffer, as it will learn of later revisions
+// from later changes.
+func SendCheckpoint(ctx context.Context, updates chan<- *datastore.RevisionChanges, revision datastore.Revision) {
+	if !datastore.WatchCheckpointsRequested(ctx) || len(updates) > 0 {
+		return
+	}
+
+	select {
+	case updates <- &datastore.RevisionChanges{Revision: revision, IsCheckpoint: true}:
+	default:
+	}
+}
@@ -0,0 +1,48 @@
+package common
+
+import (
+	"sort"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+)
+
+// RelationshipCountChanges holds the change in the number of relationships of each object
+// type made by a read-write transaction, for updating the counters maintained by the
+// datastore.
+type RelationshipCountChanges map[string]int64
+
+// Add records a change in the number of relationships of the object type.
+func (rcc RelationshipCountChanges) Add(objectType string, change int64) {
+	rcc[objectType] += change
+}
+
+// Total returns the change in the number of relationships of all object types.
+func (rcc RelationshipCountChanges) Total() int64 {
+	var total int64
+	for _, change := range rcc {
+		total += change
+	}
+	return total
+}
+
+// SortedObjectTypes returns the object types whose number of relationships has changed,
+// in sorted order, so that concurrent transactions update their counters in the same order.
+func (rcc RelationshipCountChanges) SortedObjectTypes() []string {
+	objectTypes := make([]string, 0, len(rcc))
+	for objectType, change := range rcc {
+		if change != 0 {
+			objectTypes = append(objectTypes, objectType)
+		}
+	}
+	sort.Strings(objectTypes)
+	return objectTypes
+}
+
+// CountableFromCounters returns whether the relationships matching the filter are all of
+// those of its resource type, such that they can be counted from the counters of each object
+// type rather than by counting the relationships themselves.
+func CountableFromCounters(filter *v1.RelationshipFilter) bool {
+	return filter.OptionalResourceId == "" &&
+		filter.OptionalRelation == "" &&
+		filter.OptionalSubjectFilter == nil
+}
@@ -0,0 +1,154 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	replicaTarget = "replica"
+	primaryTarget = "primary"
+)
+
+var (
+	// ReplicaRoutedReadsCounter counts the snapshot reads routed to a read replica or to the
+	// primary, from which the replica hit ratio can be derived.
+	ReplicaRoutedReadsCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "spicedb",
+		Subsystem: "datastore",
+		Name:      "read_replica_routed_reads_total",
+		Help:      "number of snapshot reads routed to a read replica or, when no replica has replayed the revision, to the primary.",
+	}, []string{"engine", "target"})
+
+	// ReplicaLagGauge is the age of the oldest transaction committed on the primary which
+	// has not yet been replayed by a read replica.
+	ReplicaLagGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "spicedb",
+		Subsystem: "datastore",
+		Name:      "read_replica_lag_seconds",
+		Help:      "age of the oldest transaction committed on the primary which has not yet been replayed by the read replica.",
+	}, []string{"engine", "replica"})
+)
+
+// ReplicaStatusFunc returns the highest transaction ID replayed by the replica at the given
+// index, and the age of the oldest transaction on the primary that the replica has yet
+// to replay.
+type ReplicaStatusFunc func(ctx context.Context, replica int) (replayedTxnID uint64, lag time.Duration, err error)
+
+// ReplicaRouter routes reads at a transaction to a read replica which has replayed that
+// transaction, as last observed by periodically polling the status of the replicas.
+// Replicas which have not been observed to have replayed a transaction are never chosen,
+// so reads which no replica can serve are left to the primary.
+type ReplicaRouter struct {
+	engine   string
+	names    []string
+	replayed []uint64
+	next     uint32
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewReplicaRouter creates a router for the replicas with the given names, which are used
+// to label metrics. No reads are routed to the replicas until the router is started.
+func NewReplicaRouter(engine string, names []string) *ReplicaRouter {
+	return &ReplicaRouter{
+		engine:   engine,
+		names:    names,
+		replayed: make([]uint64, len(names)),
+	}
+}
+
+// Start polls the status of the replicas at the given interval, until the router is stopped.
+func (rr *ReplicaRouter) Start(interval time.Duration, status ReplicaStatusFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	rr.cancel = cancel
+	rr.done = make(chan struct{})
+
+	go func() {
+		defer close(rr.done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			rr.poll(ctx, status)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop stops polling the status of the replicas, waiting for any poll in progress.
+func (rr *ReplicaRouter) Stop() {
+	if rr.cancel == nil {
+		return
+	}
+
+	rr.cancel()
+	<-rr.done
+}
+
+func (rr *ReplicaRouter) poll(ctx context.Context, status ReplicaStatusFunc) {
+	for i, name := range rr.names {
+		replayed, lag, err := status(ctx, i)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Warn().Err(err).Str("replica", name).Msg("unable to load read replica status; routing reads to the primary")
+			}
+
+			// Route no further reads to a replica whose status is unknown.
+			atomic.StoreUint64(&rr.replayed[i], 0)
+			continue
+		}
+
+		atomic.StoreUint64(&rr.replayed[i], replayed)
+		ReplicaLagGauge.WithLabelValues(rr.engine, name).Set(lag.Seconds())
+	}
+}
+
+// ReplicaFor returns the index of a replica which has replayed the given transaction, if
+// any. Reads are spread across such replicas in turn.
+func (rr *ReplicaRouter) ReplicaFor(txnID uint64) (int, bool) {
+	count := uint32(len(rr.replayed))
+	if count == 0 {
+		// Without replicas every read is served by the primary, so none are counted.
+		return 0, false
+	}
+
+	start := atomic.AddUint32(&rr.next, 1)
+	for i := uint32(0); i < count; i++ {
+		replica := int((start + i) % count)
+		replayed := atomic.LoadUint64(&rr.replayed[replica])
+		if replayed > 0 && replayed >= txnID {
+			ReplicaRoutedReadsCounter.WithLabelValues(rr.engine, replicaTarget).Inc()
+			return replica, true
+		}
+	}
+
+	ReplicaRoutedReadsCounter.WithLabelValues(rr.engine, primaryTarget).Inc()
+	return 0, false
+}
+
+// RegisterReplicaMetrics registers the read replica metrics with Prometheus. The metrics are
+// shared by every datastore with read replicas, so registering them again is not an error.
+func RegisterReplicaMetrics() error {
+	for _, collector := range []prometheus.Collector{ReplicaRoutedReadsCounter, ReplicaLagGauge} {
+		if err := prometheus.Register(collector); err != nil {
+			var alreadyRegistered prometheus.AlreadyRegisteredError
+			if !errors.As(err, &alreadyRegistered) || alreadyRegistered.ExistingCollector != collector {
+				return err
+			}
+		}
+	}
+	return nil
+}
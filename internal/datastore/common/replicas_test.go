@@ -0,0 +1,106 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeReplicas struct {
+	sync.Mutex
+	replayed []uint64
+	failing  []bool
+}
+
+func (fr *fakeReplicas) status(ctx context.Context, replica int) (uint64, time.Duration, error) {
+	fr.Lock()
+	defer fr.Unlock()
+
+	if fr.failing[replica] {
+		return 0, 0, errors.New("replica unavailable")
+	}
+	return fr.replayed[replica], 0, nil
+}
+
+func TestReplicaRouter(t *testing.T) {
+	require := require.New(t)
+
+	replicas := &fakeReplicas{
+		replayed: []uint64{5, 10},
+		failing:  []bool{false, false},
+	}
+
+	router := NewReplicaRouter("test", []string{"first", "second"})
+
+	// Nothing is routed to replicas before their status is known.
+	_, ok := router.ReplicaFor(1)
+	require.False(ok)
+
+	router.poll(context.Background(), replicas.status)
+
+	// Reads which both replicas can serve are spread across them.
+	seen := map[int]bool{}
+	for i := 0; i < 4; i++ {
+		replica, ok := router.ReplicaFor(5)
+		require.True(ok)
+		seen[replica] = true
+	}
+	require.Equal(map[int]bool{0: true, 1: true}, seen)
+
+	// Reads which only one replica can serve are routed to it.
+	for i := 0; i < 4; i++ {
+		replica, ok := router.ReplicaFor(7)
+		require.True(ok)
+		require.Equal(1, replica)
+	}
+
+	// Reads which no replica can serve are left to the primary.
+	_, ok = router.ReplicaFor(11)
+	require.False(ok)
+
+	// A replica whose status cannot be loaded is no longer routed to.
+	replicas.failing[1] = true
+	router.poll(context.Background(), replicas.status)
+	_, ok = router.ReplicaFor(7)
+	require.False(ok)
+}
+
+func TestReplicaRouterPolling(t *testing.T) {
+	replicas := &fakeReplicas{
+		replayed: []uint64{0},
+		failing:  []bool{false},
+	}
+
+	router := NewReplicaRouter("test", []string{"replica"})
+	router.Start(1*time.Millisecond, replicas.status)
+	defer router.Stop()
+
+	replicas.Lock()
+	replicas.replayed[0] = 3
+	replicas.Unlock()
+
+	require.Eventually(t, func() bool {
+		_, ok := router.ReplicaFor(3)
+		return ok
+	}, 1*time.Second, 1*time.Millisecond)
+}
+
+func TestReplicaRouterWithoutReplicas(t *testing.T) {
+	router := NewReplicaRouter("noreplicas", nil)
+
+	_, ok := router.ReplicaFor(1)
+	require.False(t, ok)
+
+	// Reads are only counted as routed to the primary when there are replicas to route them to.
+	require.Equal(t, float64(0), testutil.ToFloat64(ReplicaRoutedReadsCounter.WithLabelValues("noreplicas", primaryTarget)))
+}
+
+func TestRegisterReplicaMetricsTwice(t *testing.T) {
+	require.NoError(t, RegisterReplicaMetrics())
+	require.NoError(t, RegisterReplicaMetrics())
+}
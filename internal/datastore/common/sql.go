@@ -125,6 +125,36 @@ func (sqf SchemaQueryFilterer) FilterToSubjectFilter(filter *v1.SubjectFilter) S
 	return sqf
 }
 
+// FilterToRelationshipFilter returns a new SchemaQueryFilterer that is limited to relationships
+// that match the specified filter.
+func (sqf SchemaQueryFilterer) FilterToRelationshipFilter(filter *v1.RelationshipFilter) SchemaQueryFilterer {
+	sqf = sqf.FilterToResourceType(filter.ResourceType)
+
+	if filter.OptionalResourceId != "" {
+		sqf = sqf.FilterToResourceID(filter.OptionalResourceId)
+	}
+
+	if filter.OptionalRelation != "" {
+		sqf = sqf.FilterToRelation(filter.OptionalRelation)
+	}
+
+	if filter.OptionalSubjectFilter != nil {
+		sqf = sqf.FilterToSubjectFilter(filter.OptionalSubjectFilter)
+	}
+
+	return sqf
+}
+
+// ToSql generates the SQL and arguments for the filtered query.
+func (sqf SchemaQueryFilterer) ToSql() (string, []interface{}, error) {
+	return sqf.queryBuilder.ToSql()
+}
+
+// TracerAttributes returns the tracing attributes describing the filters applied.
+func (sqf SchemaQueryFilterer) TracerAttributes() []attribute.KeyValue {
+	return sqf.tracerAttributes
+}
+
 // FilterToUsersets returns a new SchemaQueryFilterer that is limited to resources with subjects
 // in the specified list of usersets. Nil or empty usersets parameter does not affect the underlying
 // query.
@@ -0,0 +1,87 @@
+package copier
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/authzed/spicedb/pkg/datastore"
+)
+
+// checkpoint records the progress of a copy.
+type checkpoint struct {
+	// Revision is the revision of the source datastore which is being copied.
+	Revision datastore.Revision `json:"revision"`
+
+	// SchemaCopied is whether the namespaces have been written to the target.
+	SchemaCopied bool `json:"schemaCopied"`
+
+	// CopiedRelations are the relations, as `resource_type#relation`, whose
+	// relationships have all been written to the target.
+	CopiedRelations []string `json:"copiedRelations"`
+
+	// CopyingRelation is the relation, as `resource_type#relation`, whose relationships
+	// were being written to the target, if any.
+	CopyingRelation string `json:"copyingRelation"`
+
+	// CopyingWritten is the number of relationships of CopyingRelation which have been
+	// written to the target.
+	CopyingWritten uint64 `json:"copyingWritten"`
+
+	// TailedRevision is the latest revision of the source datastore whose changes have
+	// been applied to the target, if the source has been tailed.
+	TailedRevision datastore.Revision `json:"tailedRevision"`
+}
+
+func (cp *checkpoint) isRelationCopied(key string) bool {
+	for _, copied := range cp.CopiedRelations {
+		if copied == key {
+			return true
+		}
+	}
+	return false
+}
+
+// loadCheckpoint loads the checkpoint at the path, returning nil if there is none.
+func loadCheckpoint(path string) (*checkpoint, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read checkpoint: %w", err)
+	}
+
+	var loaded checkpoint
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		return nil, fmt.Errorf("unable to parse checkpoint: %w", err)
+	}
+	return &loaded, nil
+}
+
+// save replaces the checkpoint at the path, if any. The checkpoint is written to a
+// temporary file first, so that an interrupted save does not lose the previous one.
+func (cp *checkpoint) save(path string) error {
+	if path == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return fmt.Errorf("unable to serialize checkpoint: %w", err)
+	}
+
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o600); err != nil {
+		return fmt.Errorf("unable to write checkpoint: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("unable to write checkpoint: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,199 @@
+// Package copier copies the schema and relationships of one datastore into another,
+// for moving between datastore engines.
+package copier
+
+import (
+	"context"
+	"fmt"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/rs/zerolog/log"
+
+	"github.com/authzed/spicedb/pkg/datastore"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+// Copier copies the schema and relationships of a source datastore, as of a single
+// revision, into a target datastore. Once copied, changes made to the source can be
+// replicated to the target until the source is no longer written to.
+type Copier struct {
+	source datastore.Datastore
+	target datastore.Datastore
+	config copierOptions
+
+	checkpoint *checkpoint
+}
+
+// NewCopier creates a Copier from the source datastore to the target datastore.
+func NewCopier(source, target datastore.Datastore, options ...Option) *Copier {
+	return &Copier{
+		source: source,
+		target: target,
+		config: generateConfig(options),
+	}
+}
+
+// Copy writes the namespaces and relationships of the source datastore, as of its
+// head revision, into the target datastore, returning the revision which was copied.
+// Relationships are written in batches, and a copy which is interrupted is resumed
+// from its checkpoint, if configured, at the same revision. Progress is checkpointed after
+// each batch, so that a resumed copy only checks for, rather than rewrites, the
+// relationships of a partially copied relation which were already written.
+//
+// Relationships are touched in the target, so relationships which already exist there
+// do not fail the copy.
+func (c *Copier) Copy(ctx context.Context) (datastore.Revision, error) {
+	cp, err := loadCheckpoint(c.config.checkpointPath)
+	if err != nil {
+		return datastore.NoRevision, err
+	}
+
+	if cp == nil {
+		revision, err := c.source.HeadRevision(ctx)
+		if err != nil {
+			return datastore.NoRevision, fmt.Errorf("unable to load source revision: %w", err)
+		}
+		cp = &checkpoint{Revision: revision}
+	} else {
+		log.Info().Stringer("revision", cp.Revision).Msg("resuming copy from checkpoint")
+
+		if err := c.source.CheckRevision(ctx, cp.Revision); err != nil {
+			return datastore.NoRevision, fmt.Errorf("unable to resume copy at revision %s: %w", cp.Revision, err)
+		}
+	}
+	c.checkpoint = cp
+
+	if err := cp.save(c.config.checkpointPath); err != nil {
+		return datastore.NoRevision, err
+	}
+
+	reader := c.source.SnapshotReader(cp.Revision)
+	namespaces, err := reader.ListNamespaces(ctx)
+	if err != nil {
+		return datastore.NoRevision, fmt.Errorf("unable to load source namespaces: %w", err)
+	}
+
+	if !cp.SchemaCopied {
+		if _, err := c.target.ReadWriteTx(ctx, func(ctx context.Context, rwt datastore.ReadWriteTransaction) error {
+			return rwt.WriteNamespaces(namespaces...)
+		}); err != nil {
+			return datastore.NoRevision, fmt.Errorf("unable to write namespaces: %w", err)
+		}
+		log.Info().Int("namespaces", len(namespaces)).Msg("copied schema")
+
+		cp.SchemaCopied = true
+		if err := cp.save(c.config.checkpointPath); err != nil {
+			return datastore.NoRevision, err
+		}
+	}
+
+	for _, nsDef := range namespaces {
+		for _, relation := range nsDef.Relation {
+			key := relationKey(nsDef.Name, relation.Name)
+			if cp.isRelationCopied(key) {
+				continue
+			}
+
+			var written uint64
+			if cp.CopyingRelation == key {
+				written = cp.CopyingWritten
+				log.Info().Str("relation", key).Uint64("relationships", written).Msg("resuming copy of relationships")
+			}
+
+			copied, err := c.copyRelation(ctx, reader, nsDef.Name, relation.Name, written)
+			if err != nil {
+				return datastore.NoRevision, fmt.Errorf("unable to copy relationships for %s: %w", key, err)
+			}
+			log.Info().Str("relation", key).Uint64("relationships", copied).Msg("copied relationships")
+
+			cp.CopiedRelations = append(cp.CopiedRelations, key)
+			cp.CopyingRelation = ""
+			cp.CopyingWritten = 0
+			if err := cp.save(c.config.checkpointPath); err != nil {
+				return datastore.NoRevision, err
+			}
+		}
+	}
+
+	return cp.Revision, nil
+}
+
+// copyRelation writes the relationships of the relation to the target in batches, recording
+// the number written in the checkpoint after each batch. The given number of relationships
+// were already written by an interrupted copy; as the relationships are not read in any
+// particular order, each is checked for in the target, and only written if missing, until
+// that many have been found.
+func (c *Copier) copyRelation(ctx context.Context, reader datastore.Reader, resourceType, relation string, written uint64) (uint64, error) {
+	iter, err := reader.QueryRelationships(ctx, &v1.RelationshipFilter{
+		ResourceType:     resourceType,
+		OptionalRelation: relation,
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer iter.Close()
+
+	var targetReader datastore.Reader
+	if written > 0 {
+		targetRevision, err := c.target.HeadRevision(ctx)
+		if err != nil {
+			return 0, fmt.Errorf("unable to load target revision: %w", err)
+		}
+		targetReader = c.target.SnapshotReader(targetRevision)
+	}
+
+	copied := written
+	unfound := written
+	batch := make([]*v1.RelationshipUpdate, 0, c.config.batchSize)
+	writeBatch := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+
+		if _, err := c.target.ReadWriteTx(ctx, func(ctx context.Context, rwt datastore.ReadWriteTransaction) error {
+			return rwt.WriteRelationships(batch)
+		}); err != nil {
+			return err
+		}
+
+		copied += uint64(len(batch))
+		batch = batch[:0]
+
+		c.checkpoint.CopyingRelation = relationKey(resourceType, relation)
+		c.checkpoint.CopyingWritten = copied
+		return c.checkpoint.save(c.config.checkpointPath)
+	}
+
+	for tpl := iter.Next(); tpl != nil; tpl = iter.Next() {
+		if unfound > 0 {
+			count, err := countRelationships(ctx, targetReader, tuple.ToFilter(tpl), nil)
+			if err != nil {
+				return copied, fmt.Errorf("unable to check for relationship in target: %w", err)
+			}
+			if count > 0 {
+				unfound--
+				continue
+			}
+		}
+
+		batch = append(batch, &v1.RelationshipUpdate{
+			Operation:    v1.RelationshipUpdate_OPERATION_TOUCH,
+			Relationship: tuple.ToRelationship(tpl),
+		})
+
+		if len(batch) == cap(batch) {
+			if err := writeBatch(); err != nil {
+				return copied, err
+			}
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return copied, err
+	}
+
+	return copied, writeBatch()
+}
+
+func relationKey(resourceType, relation string) string {
+	return resourceType + "#" + relation
+}
@@ -0,0 +1,281 @@
+package copier
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/spicedb/internal/datastore/memdb"
+	"github.com/authzed/spicedb/pkg/datastore"
+	"github.com/authzed/spicedb/pkg/namespace"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+const relationshipCount = 25
+
+func newDatastore(t *testing.T) datastore.Datastore {
+	ds, err := memdb.NewMemdbDatastore(16, 0, memdb.DisableGC)
+	require.NoError(t, err)
+	t.Cleanup(func() { ds.Close() })
+	return ds
+}
+
+// newSourceDatastore creates a datastore with a schema and relationships across
+// several relations.
+func newSourceDatastore(ctx context.Context, t *testing.T) (datastore.Datastore, datastore.Revision) {
+	ds := newDatastore(t)
+
+	revision, err := ds.ReadWriteTx(ctx, func(ctx context.Context, rwt datastore.ReadWriteTransaction) error {
+		if err := rwt.WriteNamespaces(
+			namespace.Namespace("user"),
+			namespace.Namespace("document",
+				namespace.Relation("viewer", nil),
+				namespace.Relation("editor", nil),
+			),
+		); err != nil {
+			return err
+		}
+
+		var updates []*v1.RelationshipUpdate
+		for i := 0; i < relationshipCount; i++ {
+			for _, relation := range []string{"viewer", "editor"} {
+				updates = append(updates, &v1.RelationshipUpdate{
+					Operation:    v1.RelationshipUpdate_OPERATION_CREATE,
+					Relationship: tuple.ParseRel(fmt.Sprintf("document:doc%d#%s@user:user%d", i, relation, i)),
+				})
+			}
+		}
+		return rwt.WriteRelationships(updates)
+	})
+	require.NoError(t, err)
+
+	return ds, revision
+}
+
+func writeRelationship(ctx context.Context, t *testing.T, ds datastore.Datastore, op v1.RelationshipUpdate_Operation, rel string) datastore.Revision {
+	revision, err := ds.ReadWriteTx(ctx, func(ctx context.Context, rwt datastore.ReadWriteTransaction) error {
+		return rwt.WriteRelationships([]*v1.RelationshipUpdate{{
+			Operation:    op,
+			Relationship: tuple.ParseRel(rel),
+		}})
+	})
+	require.NoError(t, err)
+	return revision
+}
+
+func readRelationships(ctx context.Context, t *testing.T, ds datastore.Datastore) []string {
+	revision, err := ds.HeadRevision(ctx)
+	require.NoError(t, err)
+
+	iter, err := ds.SnapshotReader(revision).QueryRelationships(ctx, &v1.RelationshipFilter{ResourceType: "document"})
+	require.NoError(t, err)
+	defer iter.Close()
+
+	var found []string
+	for tpl := iter.Next(); tpl != nil; tpl = iter.Next() {
+		found = append(found, tuple.String(tpl))
+	}
+	require.NoError(t, iter.Err())
+	return found
+}
+
+func TestCopy(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	source, written := newSourceDatastore(ctx, t)
+	target := newDatastore(t)
+
+	copier := NewCopier(source, target, BatchSize(7))
+	revision, err := copier.Copy(ctx)
+	require.NoError(err)
+	require.True(revision.GreaterThanOrEqual(written))
+
+	require.NoError(copier.Verify(ctx))
+	require.ElementsMatch(readRelationships(ctx, t, source), readRelationships(ctx, t, target))
+
+	targetHead, err := target.HeadRevision(ctx)
+	require.NoError(err)
+	namespaces, err := target.SnapshotReader(targetHead).ListNamespaces(ctx)
+	require.NoError(err)
+	require.Len(namespaces, 2)
+}
+
+func TestCopyResumesFromCheckpoint(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	checkpointPath := filepath.Join(t.TempDir(), "checkpoint.json")
+
+	source, written := newSourceDatastore(ctx, t)
+
+	// Record a copy which was interrupted after the viewers were copied.
+	interrupted := &checkpoint{
+		Revision:        written,
+		SchemaCopied:    false,
+		CopiedRelations: []string{"document#viewer"},
+	}
+	require.NoError(interrupted.save(checkpointPath))
+
+	// Changes made after the copy started are not copied.
+	writeRelationship(ctx, t, source, v1.RelationshipUpdate_OPERATION_CREATE, "document:later#editor@user:later")
+
+	target := newDatastore(t)
+	copier := NewCopier(source, target, CheckpointFile(checkpointPath))
+	revision, err := copier.Copy(ctx)
+	require.NoError(err)
+	require.True(written.Equal(revision))
+
+	copied := readRelationships(ctx, t, target)
+	require.Len(copied, relationshipCount)
+	for _, rel := range copied {
+		require.Contains(rel, "#editor@")
+		require.NotContains(rel, "later")
+	}
+
+	// As the viewers were never copied, verification fails.
+	require.ErrorContains(copier.Verify(ctx), "document#viewer")
+
+	resumed, err := loadCheckpoint(checkpointPath)
+	require.NoError(err)
+	require.True(resumed.SchemaCopied)
+	require.ElementsMatch([]string{"document#viewer", "document#editor"}, resumed.CopiedRelations)
+}
+
+func TestCopyResumesWithinRelation(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	checkpointPath := filepath.Join(t.TempDir(), "checkpoint.json")
+
+	source, written := newSourceDatastore(ctx, t)
+	target := &updateCountingDatastore{Datastore: newDatastore(t)}
+
+	// Record a copy which was interrupted after some of the viewers were written.
+	const alreadyWritten = 10
+	sourceNamespaces, err := source.SnapshotReader(written).ListNamespaces(ctx)
+	require.NoError(err)
+	_, err = target.Datastore.ReadWriteTx(ctx, func(ctx context.Context, rwt datastore.ReadWriteTransaction) error {
+		if err := rwt.WriteNamespaces(sourceNamespaces...); err != nil {
+			return err
+		}
+
+		var updates []*v1.RelationshipUpdate
+		for i := 0; i < alreadyWritten; i++ {
+			updates = append(updates, &v1.RelationshipUpdate{
+				Operation:    v1.RelationshipUpdate_OPERATION_CREATE,
+				Relationship: tuple.ParseRel(fmt.Sprintf("document:doc%d#viewer@user:user%d", i, i)),
+			})
+		}
+		return rwt.WriteRelationships(updates)
+	})
+	require.NoError(err)
+
+	interrupted := &checkpoint{
+		Revision:        written,
+		SchemaCopied:    true,
+		CopyingRelation: "document#viewer",
+		CopyingWritten:  alreadyWritten,
+	}
+	require.NoError(interrupted.save(checkpointPath))
+
+	copier := NewCopier(source, target, BatchSize(4), CheckpointFile(checkpointPath))
+	_, err = copier.Copy(ctx)
+	require.NoError(err)
+	require.NoError(copier.Verify(ctx))
+
+	// Only the relationships which were not already written are written.
+	require.Equal(2*relationshipCount-alreadyWritten, target.updates)
+	require.ElementsMatch(readRelationships(ctx, t, source), readRelationships(ctx, t, target))
+
+	resumed, err := loadCheckpoint(checkpointPath)
+	require.NoError(err)
+	require.ElementsMatch([]string{"document#viewer", "document#editor"}, resumed.CopiedRelations)
+	require.Empty(resumed.CopyingRelation)
+}
+
+func TestVerifyDetectsMissingRelationship(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	source, _ := newSourceDatastore(ctx, t)
+	target := newDatastore(t)
+
+	copier := NewCopier(source, target, SampleSize(2*relationshipCount))
+	_, err := copier.Copy(ctx)
+	require.NoError(err)
+
+	// Replace a relationship, so the counts match but the contents do not.
+	writeRelationship(ctx, t, target, v1.RelationshipUpdate_OPERATION_DELETE, "document:doc3#viewer@user:user3")
+	writeRelationship(ctx, t, target, v1.RelationshipUpdate_OPERATION_CREATE, "document:doc3#viewer@user:other")
+
+	require.ErrorContains(copier.Verify(ctx), "document:doc3#viewer@user:user3")
+}
+
+func TestTail(t *testing.T) {
+	require := require.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	checkpointPath := filepath.Join(t.TempDir(), "checkpoint.json")
+
+	source, _ := newSourceDatastore(ctx, t)
+	target := newDatastore(t)
+
+	copier := NewCopier(source, target, CheckpointFile(checkpointPath))
+	_, err := copier.Copy(ctx)
+	require.NoError(err)
+
+	tailed := make(chan error, 1)
+	go func() {
+		tailed <- copier.Tail(ctx)
+	}()
+
+	writeRelationship(ctx, t, source, v1.RelationshipUpdate_OPERATION_CREATE, "document:new#viewer@user:new")
+	lastWritten := writeRelationship(ctx, t, source, v1.RelationshipUpdate_OPERATION_DELETE, "document:doc0#viewer@user:user0")
+
+	require.Eventually(func() bool {
+		expected, found := readRelationships(ctx, t, source), readRelationships(ctx, t, target)
+		sort.Strings(expected)
+		sort.Strings(found)
+		return reflect.DeepEqual(expected, found)
+	}, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	require.NoError(<-tailed)
+
+	// The last change replicated is checkpointed.
+	saved, err := loadCheckpoint(checkpointPath)
+	require.NoError(err)
+	require.True(lastWritten.Equal(saved.TailedRevision))
+
+	// Once changes have been replicated, the copy can no longer be verified.
+	require.True(copier.Tailed())
+	require.ErrorContains(copier.Verify(context.Background()), "replicated")
+}
+
+// updateCountingDatastore counts the relationship updates written to it.
+type updateCountingDatastore struct {
+	datastore.Datastore
+	updates int
+}
+
+func (ucd *updateCountingDatastore) ReadWriteTx(ctx context.Context, fn datastore.TxUserFunc) (datastore.Revision, error) {
+	return ucd.Datastore.ReadWriteTx(ctx, func(ctx context.Context, rwt datastore.ReadWriteTransaction) error {
+		return fn(ctx, updateCountingTransaction{rwt, ucd})
+	})
+}
+
+type updateCountingTransaction struct {
+	datastore.ReadWriteTransaction
+	ucd *updateCountingDatastore
+}
+
+func (uct updateCountingTransaction) WriteRelationships(updates []*v1.RelationshipUpdate) error {
+	uct.ucd.updates += len(updates)
+	return uct.ReadWriteTransaction.WriteRelationships(updates)
+}
@@ -0,0 +1,63 @@
+package copier
+
+const (
+	defaultBatchSize  = 1000
+	defaultSampleSize = 100
+)
+
+type copierOptions struct {
+	batchSize      uint16
+	sampleSize     uint32
+	checkpointPath string
+}
+
+// Option provides the facility to configure how relationships are copied between
+// datastores.
+type Option func(*copierOptions)
+
+func generateConfig(options []Option) copierOptions {
+	computed := copierOptions{
+		batchSize:  defaultBatchSize,
+		sampleSize: defaultSampleSize,
+	}
+
+	for _, option := range options {
+		option(&computed)
+	}
+
+	if computed.batchSize == 0 {
+		computed.batchSize = 1
+	}
+
+	return computed
+}
+
+// BatchSize is the maximum number of relationships written to the target datastore
+// in a single transaction.
+//
+// This value defaults to 1000.
+func BatchSize(batchSize uint16) Option {
+	return func(co *copierOptions) {
+		co.batchSize = batchSize
+	}
+}
+
+// SampleSize is the number of relationships, chosen at random from the source
+// datastore, which are checked to exist in the target datastore when verifying a copy.
+//
+// This value defaults to 100.
+func SampleSize(sampleSize uint32) Option {
+	return func(co *copierOptions) {
+		co.sampleSize = sampleSize
+	}
+}
+
+// CheckpointFile is the path of a file to which progress is recorded, so that an
+// interrupted copy can be resumed from where it left off.
+//
+// By default, progress is not recorded.
+func CheckpointFile(path string) Option {
+	return func(co *copierOptions) {
+		co.checkpointPath = path
+	}
+}
@@ -0,0 +1,97 @@
+package copier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/rs/zerolog/log"
+
+	"github.com/authzed/spicedb/pkg/datastore"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+// Tail watches the source datastore for changes made after the copied revision, and
+// applies each to the target datastore in its own transaction, until the context is
+// canceled. Tailing is resumed from the last change applied, if checkpointed.
+//
+// Only relationship changes are replicated; changes to the schema are not.
+func (c *Copier) Tail(ctx context.Context) error {
+	if c.checkpoint == nil {
+		return fmt.Errorf("cannot tail a copy which has not been made")
+	}
+
+	afterRevision := c.checkpoint.Revision
+	if c.checkpoint.TailedRevision.GreaterThan(afterRevision) {
+		afterRevision = c.checkpoint.TailedRevision
+	}
+
+	log.Info().Stringer("revision", afterRevision).Msg("replicating changes from source")
+
+	for {
+		var err error
+		afterRevision, err = c.tailFrom(ctx, afterRevision)
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		if !errors.As(err, &datastore.ErrWatchDisconnected{}) {
+			return err
+		}
+
+		log.Warn().Err(err).Stringer("revision", afterRevision).Msg("watch disconnected; resuming")
+	}
+}
+
+// Tailed returns whether changes made to the source datastore after the copied revision
+// have been replicated to the target.
+func (c *Copier) Tailed() bool {
+	return c.checkpoint != nil && !c.checkpoint.TailedRevision.Equal(datastore.NoRevision)
+}
+
+// tailFrom applies changes after the revision until the watch fails, returning the
+// revision of the last change applied.
+func (c *Copier) tailFrom(ctx context.Context, afterRevision datastore.Revision) (datastore.Revision, error) {
+	watchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	changes, errs := c.source.Watch(watchCtx, afterRevision)
+	for {
+		select {
+		case change, ok := <-changes:
+			if !ok {
+				return afterRevision, <-errs
+			}
+
+			if _, err := c.target.ReadWriteTx(ctx, func(ctx context.Context, rwt datastore.ReadWriteTransaction) error {
+				return rwt.WriteRelationships(touchesAndDeletes(change))
+			}); err != nil {
+				return afterRevision, fmt.Errorf("unable to apply changes at revision %s: %w", change.Revision, err)
+			}
+
+			afterRevision = change.Revision
+			c.checkpoint.TailedRevision = afterRevision
+			if err := c.checkpoint.save(c.config.checkpointPath); err != nil {
+				return afterRevision, err
+			}
+
+			log.Debug().Stringer("revision", afterRevision).Int("changes", len(change.Changes)).Msg("replicated changes")
+
+		case err := <-errs:
+			return afterRevision, err
+		}
+	}
+}
+
+// touchesAndDeletes converts the changes to updates which can be applied regardless of
+// whether the change has already been applied to the target.
+func touchesAndDeletes(change *datastore.RevisionChanges) []*v1.RelationshipUpdate {
+	updates := tuple.UpdatesToRelationshipUpdates(change.Changes)
+	for _, update := range updates {
+		if update.Operation == v1.RelationshipUpdate_OPERATION_CREATE {
+			update.Operation = v1.RelationshipUpdate_OPERATION_TOUCH
+		}
+	}
+	return updates
+}
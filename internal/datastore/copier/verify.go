@@ -0,0 +1,193 @@
+package copier
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/rs/zerolog/log"
+
+	"github.com/authzed/spicedb/internal/dispatch"
+	"github.com/authzed/spicedb/internal/dispatch/graph"
+	datastoremw "github.com/authzed/spicedb/internal/middleware/datastore"
+	"github.com/authzed/spicedb/pkg/datastore"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	dispatchv1 "github.com/authzed/spicedb/pkg/proto/dispatch/v1"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+// verifyCheckDepth is the maximum depth of the checks made to verify a copy.
+const verifyCheckDepth = 50
+
+// Verify checks that the target datastore holds the same number of relationships for
+// each relation as the source did at the copied revision, and that for a random sample of
+// the source relationships, the subject of each has the same relations and permissions on
+// its resource in both datastores. Verify must be called after Copy and before any changes
+// are replicated to the target.
+func (c *Copier) Verify(ctx context.Context) error {
+	if c.checkpoint == nil {
+		return fmt.Errorf("cannot verify a copy which has not been made")
+	}
+	if c.Tailed() {
+		return fmt.Errorf("cannot verify a copy to which changes have been replicated")
+	}
+
+	targetRevision, err := c.target.HeadRevision(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to load target revision: %w", err)
+	}
+
+	sourceReader := c.source.SnapshotReader(c.checkpoint.Revision)
+	targetReader := c.target.SnapshotReader(targetRevision)
+
+	namespaces, err := sourceReader.ListNamespaces(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to load source namespaces: %w", err)
+	}
+
+	var seen uint64
+	samples := make([]*core.RelationTuple, 0, c.config.sampleSize)
+	sample := func(tpl *core.RelationTuple) {
+		// Reservoir sample the relationships, so each is equally likely to be checked.
+		seen++
+		if len(samples) < cap(samples) {
+			samples = append(samples, tpl)
+		} else if index := rand.Int63n(int64(seen)); index < int64(len(samples)) {
+			samples[index] = tpl
+		}
+	}
+
+	for _, nsDef := range namespaces {
+		if _, _, err := targetReader.ReadNamespace(ctx, nsDef.Name); err != nil {
+			return fmt.Errorf("unable to read namespace %s from target: %w", nsDef.Name, err)
+		}
+
+		for _, relation := range nsDef.Relation {
+			filter := &v1.RelationshipFilter{
+				ResourceType:     nsDef.Name,
+				OptionalRelation: relation.Name,
+			}
+
+			sourceCount, err := countRelationships(ctx, sourceReader, filter, sample)
+			if err != nil {
+				return fmt.Errorf("unable to count source relationships: %w", err)
+			}
+
+			targetCount, err := countRelationships(ctx, targetReader, filter, nil)
+			if err != nil {
+				return fmt.Errorf("unable to count target relationships: %w", err)
+			}
+
+			if sourceCount != targetCount {
+				return fmt.Errorf(
+					"relationship count mismatch for %s: %d in source, %d in target",
+					relationKey(nsDef.Name, relation.Name),
+					sourceCount,
+					targetCount,
+				)
+			}
+		}
+	}
+
+	nsDefs := make(map[string]*core.NamespaceDefinition, len(namespaces))
+	for _, nsDef := range namespaces {
+		nsDefs[nsDef.Name] = nsDef
+	}
+
+	dispatcher := graph.NewLocalOnlyDispatcher()
+	defer func() {
+		_ = dispatcher.Close()
+	}()
+
+	sourceChecker := newRevisionChecker(ctx, dispatcher, c.source, c.checkpoint.Revision)
+	targetChecker := newRevisionChecker(ctx, dispatcher, c.target, targetRevision)
+	for _, tpl := range samples {
+		for _, relation := range nsDefs[tpl.ObjectAndRelation.Namespace].Relation {
+			resource := &core.ObjectAndRelation{
+				Namespace: tpl.ObjectAndRelation.Namespace,
+				ObjectId:  tpl.ObjectAndRelation.ObjectId,
+				Relation:  relation.Name,
+			}
+			checked := tuple.String(&core.RelationTuple{ObjectAndRelation: resource, User: tpl.User})
+
+			sourceMembership, err := sourceChecker.check(resource, tpl.User.GetUserset())
+			if err != nil {
+				return fmt.Errorf("unable to check %s in source: %w", checked, err)
+			}
+
+			targetMembership, err := targetChecker.check(resource, tpl.User.GetUserset())
+			if err != nil {
+				return fmt.Errorf("unable to check %s in target: %w", checked, err)
+			}
+
+			if sourceMembership != targetMembership {
+				return fmt.Errorf(
+					"check of %s differs: %s in source, %s in target",
+					checked,
+					sourceMembership,
+					targetMembership,
+				)
+			}
+		}
+	}
+
+	log.Info().
+		Uint64("relationships", seen).
+		Int("sampled", len(samples)).
+		Msg("verified copy")
+
+	return nil
+}
+
+// revisionChecker checks permissions in a datastore at a revision.
+type revisionChecker struct {
+	ctx        context.Context
+	dispatcher dispatch.Dispatcher
+	revision   datastore.Revision
+}
+
+func newRevisionChecker(ctx context.Context, dispatcher dispatch.Dispatcher, ds datastore.Datastore, revision datastore.Revision) revisionChecker {
+	return revisionChecker{
+		ctx:        datastoremw.ContextWithDatastore(ctx, ds),
+		dispatcher: dispatcher,
+		revision:   revision,
+	}
+}
+
+func (rc revisionChecker) check(resource, subject *core.ObjectAndRelation) (dispatchv1.DispatchCheckResponse_Membership, error) {
+	resp, err := rc.dispatcher.DispatchCheck(rc.ctx, &dispatchv1.DispatchCheckRequest{
+		Metadata: &dispatchv1.ResolverMeta{
+			AtRevision:     rc.revision.String(),
+			DepthRemaining: verifyCheckDepth,
+		},
+		ObjectAndRelation: resource,
+		Subject:           subject,
+	})
+	if err != nil {
+		return dispatchv1.DispatchCheckResponse_UNKNOWN, err
+	}
+	return resp.Membership, nil
+}
+
+func countRelationships(
+	ctx context.Context,
+	reader datastore.Reader,
+	filter *v1.RelationshipFilter,
+	visit func(*core.RelationTuple),
+) (uint64, error) {
+	iter, err := reader.QueryRelationships(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	defer iter.Close()
+
+	var count uint64
+	for tpl := iter.Next(); tpl != nil; tpl = iter.Next() {
+		count++
+		if visit != nil {
+			visit(tpl)
+		}
+	}
+	return count, iter.Err()
+}
@@ -223,7 +223,7 @@ func (cds *crdbDatastore) ReadWriteTx(
 				},
 				ctx,
 				tx,
-				0,
+				make(common.RelationshipCountChanges),
 			}
 
 			if err := f(ctx, rwt); err != nil {
@@ -238,8 +238,12 @@ func (cds *crdbDatastore) ReadWriteTx(
 				}
 			}
 
+			if err := updateNamespaceCounters(ctx, tx, rwt.relCountChanges); err != nil {
+				return fmt.Errorf("error updating namespace relationship counters: %w", err)
+			}
+
 			var err error
-			commitTimestamp, err = updateCounter(ctx, tx, rwt.relCountChange)
+			commitTimestamp, err = updateCounter(ctx, tx, rwt.relCountChanges.Total())
 			if err != nil {
 				return fmt.Errorf("error updating relationship counter: %w", err)
 			}
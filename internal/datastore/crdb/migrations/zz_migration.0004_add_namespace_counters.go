@@ -0,0 +1,38 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v4"
+)
+
+const (
+	createNamespaceCounters = `CREATE TABLE namespace_relationship_counters (
+	namespace STRING NOT NULL,
+	id BYTES NOT NULL,
+	count INT NOT NULL,
+	PRIMARY KEY (namespace, id)
+);`
+
+	backfillNamespaceCounters = `INSERT INTO namespace_relationship_counters (namespace, id, count)
+	SELECT namespace, b'\x00\x00', count(*) FROM relation_tuple GROUP BY namespace;`
+)
+
+func init() {
+	if err := CRDBMigrations.Register("add-namespace-counters", "add-metadata-and-counters", func(apd *CRDBDriver) error {
+		ctx := context.Background()
+
+		return apd.db.BeginFunc(ctx, func(tx pgx.Tx) error {
+			if _, err := tx.Exec(ctx, createNamespaceCounters); err != nil {
+				return err
+			}
+			if _, err := tx.Exec(ctx, backfillNamespaceCounters); err != nil {
+				return err
+			}
+
+			return nil
+		})
+	}); err != nil {
+		panic("failed to register migration: " + err.Error())
+	}
+}
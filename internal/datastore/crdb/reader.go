@@ -20,6 +20,7 @@ import (
 const (
 	errUnableToReadConfig     = "unable to read namespace config: %w"
 	errUnableToListNamespaces = "unable to list namespaces: %w"
+	errUnableToCountTuples    = "unable to count tuples: %w"
 )
 
 var (
@@ -34,6 +35,8 @@ var (
 		colUsersetRelation,
 	).From(tableTuple)
 
+	countTuples = psql.Select("COUNT(*)").From(tableTuple)
+
 	schema = common.SchemaInformation{
 		ColNamespace:        colNamespace,
 		ColObjectID:         colObjectID,
@@ -170,6 +173,35 @@ func (cr *crdbReader) ReverseQueryRelationships(
 	return
 }
 
+func (cr *crdbReader) CountRelationships(ctx context.Context, filter *v1.RelationshipFilter) (uint64, error) {
+	ctx, span := tracer.Start(datastore.SeparateContextWithTracing(ctx), "CountRelationships")
+	defer span.End()
+
+	qBuilder := common.NewSchemaQueryFilterer(schema, countTuples).
+		FilterToRelationshipFilter(filter)
+	span.SetAttributes(qBuilder.TracerAttributes()...)
+
+	sql, args, err := qBuilder.ToSql()
+	if err != nil {
+		return 0, fmt.Errorf(errUnableToCountTuples, err)
+	}
+
+	var count uint64
+	if err := cr.execute(ctx, func(ctx context.Context) error {
+		tx, txCleanup, err := cr.txSource(ctx)
+		if err != nil {
+			return err
+		}
+		defer txCleanup(ctx)
+
+		return tx.QueryRow(ctx, sql, args...).Scan(&count)
+	}); err != nil {
+		return 0, fmt.Errorf(errUnableToCountTuples, err)
+	}
+
+	return count, nil
+}
+
 func loadNamespace(ctx context.Context, tx pgx.Tx, nsName string) (*core.NamespaceDefinition, time.Time, error) {
 	query := queryReadNamespace.Where(sq.Eq{colNamespace: nsName})
 
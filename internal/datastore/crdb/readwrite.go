@@ -40,11 +40,14 @@ var (
 	queryDeleteNamespace = psql.Delete(tableNamespace)
 )
 
+// bulkLoadBatchSize is the number of relationships created by each INSERT made by BulkLoad.
+const bulkLoadBatchSize = 1000
+
 type crdbReadWriteTXN struct {
 	*crdbReader
-	ctx            context.Context
-	tx             pgx.Tx
-	relCountChange int64
+	ctx             context.Context
+	tx              pgx.Tx
+	relCountChanges common.RelationshipCountChanges
 }
 
 var (
@@ -99,7 +102,7 @@ func (rwt *crdbReadWriteTXN) WriteRelationships(mutations []*v1.RelationshipUpda
 
 		switch mutation.Operation {
 		case v1.RelationshipUpdate_OPERATION_TOUCH:
-			rwt.relCountChange++
+			rwt.relCountChanges.Add(rel.Resource.ObjectType, 1)
 			bulkTouch = bulkTouch.Values(
 				rel.Resource.ObjectType,
 				rel.Resource.ObjectId,
@@ -110,7 +113,7 @@ func (rwt *crdbReadWriteTXN) WriteRelationships(mutations []*v1.RelationshipUpda
 			)
 			bulkTouchCount++
 		case v1.RelationshipUpdate_OPERATION_CREATE:
-			rwt.relCountChange++
+			rwt.relCountChanges.Add(rel.Resource.ObjectType, 1)
 			bulkWrite = bulkWrite.Values(
 				rel.Resource.ObjectType,
 				rel.Resource.ObjectId,
@@ -121,7 +124,7 @@ func (rwt *crdbReadWriteTXN) WriteRelationships(mutations []*v1.RelationshipUpda
 			)
 			bulkWriteCount++
 		case v1.RelationshipUpdate_OPERATION_DELETE:
-			rwt.relCountChange--
+			rwt.relCountChanges.Add(rel.Resource.ObjectType, -1)
 			sql, args, err := queryDeleteTuples.Where(exactRelationshipClause(rel)).ToSql()
 			if err != nil {
 				return fmt.Errorf(errUnableToWriteRelationships, err)
@@ -158,6 +161,44 @@ func (rwt *crdbReadWriteTXN) WriteRelationships(mutations []*v1.RelationshipUpda
 	return nil
 }
 
+// BulkLoad creates the relationships with multi-row INSERTs, which unlike IMPORT can be made
+// within the transaction, and so at its revision.
+func (rwt *crdbReadWriteTXN) BulkLoad(ctx context.Context, source datastore.BulkWriteRelationshipSource) (uint64, error) {
+	ctx, span := tracer.Start(datastore.SeparateContextWithTracing(ctx), "BulkLoad")
+	defer span.End()
+
+	loaded, err := datastore.BulkLoadInBatches(ctx, source, bulkLoadBatchSize, func(batch []*v1.Relationship) error {
+		bulkWrite := queryWriteTuple
+		for _, rel := range batch {
+			rwt.addOverlapKey(rel.Resource.ObjectType)
+			rwt.addOverlapKey(rel.Subject.Object.ObjectType)
+			rwt.relCountChanges.Add(rel.Resource.ObjectType, 1)
+
+			bulkWrite = bulkWrite.Values(
+				rel.Resource.ObjectType,
+				rel.Resource.ObjectId,
+				rel.Relation,
+				rel.Subject.Object.ObjectType,
+				rel.Subject.Object.ObjectId,
+				stringz.DefaultEmpty(rel.Subject.OptionalRelation, datastore.Ellipsis),
+			)
+		}
+
+		sql, args, err := bulkWrite.ToSql()
+		if err != nil {
+			return err
+		}
+
+		_, err = rwt.tx.Exec(ctx, sql, args...)
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf(errUnableToWriteRelationships, err)
+	}
+
+	return loaded, nil
+}
+
 func exactRelationshipClause(r *v1.Relationship) sq.Eq {
 	return sq.Eq{
 		colNamespace:        r.Resource.ObjectType,
@@ -211,7 +252,7 @@ func (rwt *crdbReadWriteTXN) DeleteRelationships(filter *v1.RelationshipFilter)
 		return fmt.Errorf(errUnableToDeleteRelationships, err)
 	}
 
-	rwt.relCountChange -= modified.RowsAffected()
+	rwt.relCountChanges.Add(filter.ResourceType, -modified.RowsAffected())
 
 	return nil
 }
@@ -278,9 +319,12 @@ func (rwt *crdbReadWriteTXN) DeleteNamespace(nsName string) error {
 	}
 
 	numRowsDeleted := modified.RowsAffected()
-	rwt.relCountChange -= numRowsDeleted
+	rwt.relCountChanges.Add(nsName, -numRowsDeleted)
 
 	return nil
 }
 
-var _ datastore.ReadWriteTransaction = &crdbReadWriteTXN{}
+var (
+	_ datastore.ReadWriteTransaction = &crdbReadWriteTXN{}
+	_ datastore.BulkLoader           = &crdbReadWriteTXN{}
+)
@@ -8,6 +8,7 @@ import (
 	"github.com/jackc/pgx/v4"
 	"github.com/shopspring/decimal"
 
+	"github.com/authzed/spicedb/internal/datastore/common"
 	"github.com/authzed/spicedb/pkg/datastore"
 	corev1 "github.com/authzed/spicedb/pkg/proto/core/v1"
 )
@@ -19,6 +20,8 @@ const (
 	tableCounters = "relationship_estimate_counters"
 	colID         = "id"
 	colCount      = "count"
+
+	tableNamespaceCounters = "namespace_relationship_counters"
 )
 
 var (
@@ -29,6 +32,17 @@ var (
 		colID,
 		colCount,
 	).Suffix(fmt.Sprintf("ON CONFLICT (%[1]s) DO UPDATE SET %[2]s = %[3]s.%[2]s + EXCLUDED.%[2]s RETURNING cluster_logical_timestamp()", colID, colCount, tableCounters))
+
+	queryNamespaceEstimates = psql.
+				Select(colNamespace, fmt.Sprintf("SUM(%s)", colCount)).
+				From(tableNamespaceCounters).
+				GroupBy(colNamespace)
+
+	upsertNamespaceCounterQuery = psql.Insert(tableNamespaceCounters).Columns(
+		colNamespace,
+		colID,
+		colCount,
+	).Suffix(fmt.Sprintf("ON CONFLICT (%[1]s, %[2]s) DO UPDATE SET %[3]s = %[4]s.%[3]s + EXCLUDED.%[3]s", colNamespace, colID, colCount, tableNamespaceCounters))
 )
 
 func (cds *crdbDatastore) Statistics(ctx context.Context) (datastore.Stats, error) {
@@ -42,6 +56,7 @@ func (cds *crdbDatastore) Statistics(ctx context.Context) (datastore.Stats, erro
 	var uniqueID string
 	var nsDefs []*corev1.NamespaceDefinition
 	var relCount uint64
+	var countsByType map[string]uint64
 	if err := cds.pool.BeginTxFunc(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly}, func(tx pgx.Tx) error {
 		if err := tx.QueryRow(ctx, sql, args...).Scan(&uniqueID); err != nil {
 			return fmt.Errorf("unable to query unique ID: %w", err)
@@ -56,6 +71,11 @@ func (cds *crdbDatastore) Statistics(ctx context.Context) (datastore.Stats, erro
 			return fmt.Errorf("unable to read namespaces: %w", err)
 		}
 
+		countsByType, err = readNamespaceEstimates(ctx, tx)
+		if err != nil {
+			return fmt.Errorf("unable to read namespace relationship counts: %w", err)
+		}
+
 		return nil
 	}); err != nil {
 		return datastore.Stats{}, err
@@ -64,7 +84,7 @@ func (cds *crdbDatastore) Statistics(ctx context.Context) (datastore.Stats, erro
 	return datastore.Stats{
 		UniqueID:                   uniqueID,
 		EstimatedRelationshipCount: relCount,
-		ObjectTypeStatistics:       datastore.ComputeObjectTypeStats(nsDefs),
+		ObjectTypeStatistics:       datastore.ComputeObjectTypeStats(nsDefs, countsByType),
 	}, nil
 }
 
@@ -87,3 +107,60 @@ func updateCounter(ctx context.Context, tx pgx.Tx, change int64) (datastore.Revi
 
 	return timestamp, nil
 }
+
+func readNamespaceEstimates(ctx context.Context, tx pgx.Tx) (map[string]uint64, error) {
+	sql, args, err := queryNamespaceEstimates.ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	countsByType := make(map[string]uint64)
+	for rows.Next() {
+		var objectType string
+		var count int64
+		if err := rows.Scan(&objectType, &count); err != nil {
+			return nil, err
+		}
+
+		if count > 0 {
+			countsByType[objectType] = uint64(count)
+		}
+	}
+
+	return countsByType, rows.Err()
+}
+
+func updateNamespaceCounters(ctx context.Context, tx pgx.Tx, changes common.RelationshipCountChanges) error {
+	objectTypes := changes.SortedObjectTypes()
+	if len(objectTypes) == 0 {
+		return nil
+	}
+
+	counterID := make([]byte, 2)
+	_, err := rand.Read(counterID)
+	if err != nil {
+		return fmt.Errorf("unable to select random counter: %w", err)
+	}
+
+	query := upsertNamespaceCounterQuery
+	for _, objectType := range objectTypes {
+		query = query.Values(objectType, counterID, changes[objectType])
+	}
+
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("unable to prepare upsert namespace counter sql: %w", err)
+	}
+
+	if _, err := tx.Exec(ctx, sql, args...); err != nil {
+		return fmt.Errorf("unable to execute upsert namespace counter query: %w", err)
+	}
+
+	return nil
+}
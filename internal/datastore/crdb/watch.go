@@ -11,6 +11,7 @@ import (
 
 	core "github.com/authzed/spicedb/pkg/proto/core/v1"
 
+	"github.com/authzed/spicedb/internal/datastore/common"
 	"github.com/authzed/spicedb/pkg/datastore"
 )
 
@@ -96,6 +97,9 @@ func (cds *crdbDatastore) Watch(ctx context.Context, afterRevision datastore.Rev
 					}
 				}
 
+				// All changes at or before the resolved timestamp have now been emitted.
+				common.SendCheckpoint(ctx, updates, resolved)
+
 				continue
 			}
 
@@ -0,0 +1,102 @@
+package memdb
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// defaultGCInterval is the maximum amount of time between passes of garbage collection.
+const defaultGCInterval = 3 * time.Minute
+
+// runGarbageCollector periodically collects garbage until the context is canceled.
+func (mdb *memdbDatastore) runGarbageCollector(ctx context.Context, interval time.Duration) {
+	defer close(mdb.gcDone)
+
+	log.Debug().Dur("interval", interval).Msg("memdb garbage collection worker started")
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Debug().Msg("shutting down memdb garbage collection worker")
+			return
+
+		case <-ticker.C:
+			snapshotsDeleted, changesDeleted, err := mdb.collectGarbage(time.Now().UTC())
+			if err != nil {
+				log.Warn().Err(err).Msg("error when attempting to perform garbage collection")
+				continue
+			}
+
+			log.Debug().
+				Int("snapshotsDeleted", snapshotsDeleted).
+				Int("changesDeleted", changesDeleted).
+				Msg("garbage collection completed for memdb")
+		}
+	}
+}
+
+// collectGarbage drops the snapshots and changelog entries which are no longer needed to
+// serve reads and watches at revisions within the GC window ending at the given time. As deleted relationships are
+// removed from the live database, they are referenced only by the snapshots taken before
+// their deletion, and are reclaimed once those snapshots have been dropped.
+func (mdb *memdbDatastore) collectGarbage(now time.Time) (int, int, error) {
+	mdb.Lock()
+	defer mdb.Unlock()
+
+	if mdb.db == nil {
+		return 0, 0, fmt.Errorf("datastore is closed")
+	}
+
+	oldest := revisionFromTimestamp(now).Add(mdb.negativeGCWindow)
+
+	// Keep the newest snapshot at or before the start of the window, as it holds the state
+	// visible at the start of the window, along with every snapshot after it.
+	firstAfter := sort.Search(len(mdb.revisions), func(i int) bool {
+		return mdb.revisions[i].revision.GreaterThan(oldest)
+	})
+	snapshotsDeleted := 0
+	if firstAfter > 1 {
+		snapshotsDeleted = firstAfter - 1
+		mdb.revisions = append([]snapshot(nil), mdb.revisions[snapshotsDeleted:]...)
+	}
+
+	// The changelog can only be modified by a write transaction; if one is already active,
+	// collect the changelog during the next pass.
+	if mdb.activeWriteTxn != nil {
+		return snapshotsDeleted, 0, nil
+	}
+
+	tx := mdb.db.Txn(true)
+	defer tx.Abort()
+
+	it, err := tx.Get(tableChangelog, indexRevision)
+	if err != nil {
+		return snapshotsDeleted, 0, fmt.Errorf("unable to load changelog: %w", err)
+	}
+
+	var expired []*changelog
+	for changeRaw := it.Next(); changeRaw != nil; changeRaw = it.Next() {
+		change := changeRaw.(*changelog)
+		if change.revisionNanos >= oldest.IntPart() {
+			break
+		}
+		expired = append(expired, change)
+	}
+
+	for _, change := range expired {
+		if err := tx.Delete(tableChangelog, change); err != nil {
+			return snapshotsDeleted, 0, fmt.Errorf("unable to delete changelog entry: %w", err)
+		}
+	}
+
+	tx.Commit()
+
+	return snapshotsDeleted, len(expired), nil
+}
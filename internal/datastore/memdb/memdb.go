@@ -25,17 +25,26 @@ const (
 var errSerialization = errors.New("serialization error")
 
 // DisableGC is a convenient constant for setting the garbage collection
-// interval high enough that it will never run.
+// window high enough that garbage collection will never run.
 const DisableGC = time.Duration(math.MaxInt64)
 
 // NewMemdbDatastore creates a new Datastore compliant datastore backed by memdb.
 //
 // If the watchBufferLength value of 0 is set then a default value of 128 will be used.
+//
+// Unless the gcWindow is DisableGC, a worker periodically garbage collects the revisions
+// which have fallen out of the gcWindow. The worker is stopped by Close.
+//
+// The datastore can be persisted to a local directory with the PersistenceDir option, in
+// which case any state previously persisted to the directory is restored.
 func NewMemdbDatastore(
 	watchBufferLength uint16,
 	revisionQuantization,
 	gcWindow time.Duration,
+	options ...Option,
 ) (datastore.Datastore, error) {
+	config := generateConfig(options)
+
 	if revisionQuantization > gcWindow {
 		return nil, errors.New("gc window must be larger than quantization interval")
 	}
@@ -57,7 +66,9 @@ func NewMemdbDatastore(
 
 	negativeGCWindow := decimal.NewFromInt(gcWindow.Nanoseconds()).Mul(decimal.NewFromInt(-1))
 
-	return &memdbDatastore{
+	gcCtx, cancelGc := context.WithCancel(context.Background())
+
+	mdb := &memdbDatastore{
 		db: db,
 		revisions: []snapshot{
 			{
@@ -66,11 +77,38 @@ func NewMemdbDatastore(
 			},
 		},
 
+		pendingRevisions: map[int64]struct{}{},
+
 		negativeGCWindow:   negativeGCWindow,
 		quantizationPeriod: decimal.NewFromInt(revisionQuantization.Nanoseconds()),
 		watchBufferLength:  watchBufferLength,
 		uniqueID:           uniqueID,
-	}, nil
+		cancelGc:           cancelGc,
+	}
+
+	if config.persistenceDir != "" {
+		if err := mdb.restore(config.persistenceDir); err != nil {
+			cancelGc()
+			return nil, fmt.Errorf("unable to restore persisted datastore: %w", err)
+		}
+
+		snapshotCtx, cancelSnapshots := context.WithCancel(context.Background())
+		mdb.persistence.cancelSnapshots = cancelSnapshots
+		mdb.persistence.snapshotsDone = make(chan struct{})
+		go mdb.runSnapshotter(snapshotCtx, config.snapshotInterval)
+	}
+
+	if gcWindow > 0 && gcWindow != DisableGC {
+		gcInterval := gcWindow
+		if gcInterval > defaultGCInterval {
+			gcInterval = defaultGCInterval
+		}
+
+		mdb.gcDone = make(chan struct{})
+		go mdb.runGarbageCollector(gcCtx, gcInterval)
+	}
+
+	return mdb, nil
 }
 
 type memdbDatastore struct {
@@ -80,10 +118,19 @@ type memdbDatastore struct {
 	revisions      []snapshot
 	activeWriteTxn *memdb.Txn
 
+	// pendingRevisions are the revisions of the read/write transactions which have begun but
+	// not yet committed, which watchers may not checkpoint beyond.
+	pendingRevisions map[int64]struct{}
+
 	negativeGCWindow   datastore.Revision
 	quantizationPeriod datastore.Revision
 	watchBufferLength  uint16
 	uniqueID           string
+
+	cancelGc context.CancelFunc
+	gcDone   chan struct{}
+
+	persistence *persistence
 }
 
 type snapshot struct {
@@ -144,11 +191,12 @@ func (mdb *memdbDatastore) ReadWriteTx(
 			return tx, err
 		}
 
-		newRevision := revisionFromTimestamp(time.Now().UTC())
+		newRevision := mdb.beginRevision()
 
 		rwt := &memdbReadWriteTx{memdbReader{&sync.Mutex{}, txSrc, datastore.NoRevision, nil}, newRevision}
 		if err := f(ctx, rwt); err != nil {
 			mdb.Lock()
+			delete(mdb.pendingRevisions, newRevision.IntPart())
 			if tx != nil {
 				tx.Abort()
 				mdb.activeWriteTxn = nil
@@ -172,6 +220,8 @@ func (mdb *memdbDatastore) ReadWriteTx(
 		mdb.Lock()
 		defer mdb.Unlock()
 
+		delete(mdb.pendingRevisions, newRevision.IntPart())
+
 		// Record the changes that were made
 		newChanges := datastore.RevisionChanges{
 			Revision: newRevision,
@@ -203,6 +253,16 @@ func (mdb *memdbDatastore) ReadWriteTx(
 				return datastore.NoRevision, fmt.Errorf("error writing changelog: %w", err)
 			}
 
+			// Log the transaction before committing it, so that a committed transaction is
+			// never lost.
+			if mdb.persistence != nil {
+				if err := mdb.persistTxn(newRevision, tx, newChanges.Changes); err != nil {
+					tx.Abort()
+					mdb.activeWriteTxn = nil
+					return datastore.NoRevision, fmt.Errorf("error writing to the write-ahead log: %w", err)
+				}
+			}
+
 			tx.Commit()
 		}
 		mdb.activeWriteTxn = nil
@@ -225,9 +285,23 @@ func (mdb *memdbDatastore) IsReady(ctx context.Context) (bool, error) {
 }
 
 func (mdb *memdbDatastore) Close() error {
+	mdb.cancelGc()
+	if mdb.gcDone != nil {
+		<-mdb.gcDone
+	}
+	if mdb.persistence != nil {
+		mdb.persistence.cancelSnapshots()
+		<-mdb.persistence.snapshotsDone
+	}
+
 	mdb.Lock()
 	defer mdb.Unlock()
 
+	var err error
+	if mdb.persistence != nil && mdb.db != nil {
+		err = mdb.closePersistence()
+	}
+
 	// TODO Make this nil once we have removed all access to closed datastores
 	mdb.revisions = []snapshot{
 		{
@@ -237,7 +311,7 @@ func (mdb *memdbDatastore) Close() error {
 	}
 	mdb.db = nil
 
-	return nil
+	return err
 }
 
 var _ datastore.Datastore = &memdbDatastore{}
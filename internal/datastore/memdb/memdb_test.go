@@ -8,6 +8,8 @@ import (
 	"testing"
 	"time"
 
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/hashicorp/go-memdb"
 	"github.com/stretchr/testify/require"
 	"golang.org/x/sync/errgroup"
 
@@ -15,6 +17,7 @@ import (
 	test "github.com/authzed/spicedb/pkg/datastore/test"
 	ns "github.com/authzed/spicedb/pkg/namespace"
 	corev1 "github.com/authzed/spicedb/pkg/proto/core/v1"
+	"github.com/authzed/spicedb/pkg/tuple"
 )
 
 type memDBTest struct{}
@@ -77,3 +80,150 @@ func TestConcurrentWritePanic(t *testing.T) {
 	}, 1*time.Second, 10*time.Millisecond)
 	require.ErrorIs(err, recoverErr)
 }
+
+func TestGarbageCollection(t *testing.T) {
+	require := require.New(t)
+
+	gcWindow := time.Hour
+	ds, err := NewMemdbDatastore(0, 0, gcWindow)
+	require.NoError(err)
+	defer ds.Close()
+
+	// Stop the worker, so that garbage is collected only when requested.
+	mdb := ds.(*memdbDatastore)
+	mdb.cancelGc()
+	<-mdb.gcDone
+
+	ctx := context.Background()
+	_, err = ds.ReadWriteTx(ctx, func(ctx context.Context, rwt datastore.ReadWriteTransaction) error {
+		return rwt.WriteNamespaces(
+			ns.Namespace("resource", ns.Relation("reader", nil)),
+			ns.Namespace("user"),
+		)
+	})
+	require.NoError(err)
+
+	deleted := tuple.MustParse("resource:foo#reader@user:tom")
+	kept := tuple.MustParse("resource:foo#reader@user:sarah")
+
+	writeRelationship(ctx, require, ds, v1.RelationshipUpdate_OPERATION_CREATE, deleted)
+	writeRelationship(ctx, require, ds, v1.RelationshipUpdate_OPERATION_DELETE, deleted)
+	keptWrite := writeRelationship(ctx, require, ds, v1.RelationshipUpdate_OPERATION_CREATE, kept)
+
+	// Nothing has fallen out of the window yet.
+	snapshotsDeleted, changesDeleted, err := mdb.collectGarbage(time.Now().UTC())
+	require.NoError(err)
+	require.Zero(snapshotsDeleted)
+	require.Zero(changesDeleted)
+	require.Len(mdb.revisions, 5)
+	require.Equal(1, countRelationships(require, mdb.revisions[2].db))
+
+	lastWrite := writeRelationship(ctx, require, ds, v1.RelationshipUpdate_OPERATION_TOUCH, kept)
+	require.True(lastWrite.GreaterThan(keptWrite))
+
+	// Collect as of when the window starts just before the last write.
+	collectAt := time.Unix(0, lastWrite.IntPart()-1).UTC().Add(gcWindow)
+
+	// Everything but the state at the start of the window and the last write is collected.
+	snapshotsDeleted, changesDeleted, err = mdb.collectGarbage(collectAt)
+	require.NoError(err)
+	require.Equal(4, snapshotsDeleted)
+	require.Equal(4, changesDeleted)
+	require.Len(mdb.revisions, 2)
+	require.Equal(1, countChanges(require, mdb.db))
+
+	// The deleted relationship is no longer referenced by any snapshot.
+	for _, snap := range mdb.revisions {
+		require.Equal(1, countRelationships(require, snap.db))
+	}
+
+	// Running again collects nothing.
+	snapshotsDeleted, changesDeleted, err = mdb.collectGarbage(collectAt)
+	require.NoError(err)
+	require.Zero(snapshotsDeleted)
+	require.Zero(changesDeleted)
+
+	// Only the snapshots at the start of the window and after it remain.
+	require.True(keptWrite.Equal(mdb.revisions[0].revision))
+	require.True(lastWrite.Equal(mdb.revisions[1].revision))
+
+	require.NoError(ds.CheckRevision(ctx, lastWrite))
+	iter, err := ds.SnapshotReader(lastWrite).QueryRelationships(ctx, &v1.RelationshipFilter{ResourceType: "resource"})
+	require.NoError(err)
+	defer iter.Close()
+	require.Equal(kept, iter.Next())
+	require.Nil(iter.Next())
+}
+
+func TestGarbageCollectionWorker(t *testing.T) {
+	require := require.New(t)
+
+	ds, err := NewMemdbDatastore(0, 0, 10*time.Millisecond)
+	require.NoError(err)
+
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		_, err = ds.ReadWriteTx(ctx, func(ctx context.Context, rwt datastore.ReadWriteTransaction) error {
+			return rwt.WriteNamespaces(ns.Namespace("user"))
+		})
+		require.NoError(err)
+	}
+
+	mdb := ds.(*memdbDatastore)
+	require.Eventually(func() bool {
+		mdb.RLock()
+		defer mdb.RUnlock()
+		return len(mdb.revisions) == 1 && countChanges(require, mdb.db) == 0
+	}, 1*time.Second, 10*time.Millisecond)
+
+	require.NoError(ds.Close())
+	_, open := <-mdb.gcDone
+	require.False(open)
+}
+
+func TestGarbageCollectionDisabled(t *testing.T) {
+	ds, err := NewMemdbDatastore(0, 0, DisableGC)
+	require.NoError(t, err)
+	defer ds.Close()
+
+	require.Nil(t, ds.(*memdbDatastore).gcDone)
+}
+
+func writeRelationship(
+	ctx context.Context,
+	require *require.Assertions,
+	ds datastore.Datastore,
+	op v1.RelationshipUpdate_Operation,
+	tpl *corev1.RelationTuple,
+) datastore.Revision {
+	revision, err := ds.ReadWriteTx(ctx, func(ctx context.Context, rwt datastore.ReadWriteTransaction) error {
+		return rwt.WriteRelationships([]*v1.RelationshipUpdate{{
+			Operation:    op,
+			Relationship: tuple.MustToRelationship(tpl),
+		}})
+	})
+	require.NoError(err)
+	return revision
+}
+
+func countRelationships(require *require.Assertions, db *memdb.MemDB) int {
+	return countRows(require, db, tableRelationship, indexID)
+}
+
+func countChanges(require *require.Assertions, db *memdb.MemDB) int {
+	return countRows(require, db, tableChangelog, indexRevision)
+}
+
+func countRows(require *require.Assertions, db *memdb.MemDB, table, index string) int {
+	txn := db.Txn(false)
+	defer txn.Abort()
+
+	it, err := txn.Get(table, index)
+	require.NoError(err)
+
+	count := 0
+	for row := it.Next(); row != nil; row = it.Next() {
+		count++
+	}
+	return count
+}
@@ -0,0 +1,47 @@
+package memdb
+
+import "time"
+
+const defaultSnapshotInterval = 1 * time.Minute
+
+type memdbOptions struct {
+	persistenceDir   string
+	snapshotInterval time.Duration
+}
+
+// Option configures optional behavior of the memdb datastore.
+type Option func(*memdbOptions)
+
+func generateConfig(options []Option) memdbOptions {
+	computed := memdbOptions{
+		snapshotInterval: defaultSnapshotInterval,
+	}
+
+	for _, option := range options {
+		option(&computed)
+	}
+
+	return computed
+}
+
+// PersistenceDir is the local directory to which the datastore is persisted, so that
+// its relationships, namespaces and revisions survive a restart. The directory holds a
+// periodic snapshot of the datastore and a write-ahead log of the transactions committed
+// since, which are replayed when the datastore is next created with the same directory.
+//
+// The datastore is not persisted by default.
+func PersistenceDir(dir string) Option {
+	return func(mo *memdbOptions) {
+		mo.persistenceDir = dir
+	}
+}
+
+// SnapshotInterval is the interval at which a persisted datastore writes a snapshot
+// and truncates its write-ahead log.
+//
+// This value defaults to 1 minute.
+func SnapshotInterval(interval time.Duration) Option {
+	return func(mo *memdbOptions) {
+		mo.snapshotInterval = interval
+	}
+}
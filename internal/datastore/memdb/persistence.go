@@ -0,0 +1,421 @@
+package memdb
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/hashicorp/go-memdb"
+	"github.com/rs/zerolog/log"
+	"github.com/shopspring/decimal"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/authzed/spicedb/pkg/datastore"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	implv1 "github.com/authzed/spicedb/pkg/proto/impl/v1"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+const (
+	snapshotFileName = "snapshot"
+	logFileName      = "wal"
+
+	// Each log entry is preceded by its length and its CRC-32C checksum.
+	logEntryHeaderSize = 8
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// persistence is the on-disk state of a persisted datastore: the snapshot of the datastore
+// as of a revision, and the log of the transactions committed after it.
+type persistence struct {
+	dir string
+	log *os.File
+
+	// pendingEntries is the number of entries appended to the log since the last snapshot.
+	pendingEntries int
+
+	cancelSnapshots context.CancelFunc
+	snapshotsDone   chan struct{}
+}
+
+// restore loads the snapshot and replays the log found in the persistence directory,
+// and opens the log for appending further transactions. A log which ends with a
+// partially written entry, as left by a crash, is truncated after its last whole entry.
+func (mdb *memdbDatastore) restore(dir string) error {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("unable to create persistence directory: %w", err)
+	}
+
+	snapshotRevisionNanos, err := mdb.loadSnapshot(filepath.Join(dir, snapshotFileName))
+	if err != nil {
+		return err
+	}
+
+	logFile, err := os.OpenFile(filepath.Join(dir, logFileName), os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("unable to open write-ahead log: %w", err)
+	}
+
+	entries, validLength, err := readLog(logFile)
+	if err != nil {
+		logFile.Close()
+		return err
+	}
+
+	if err := logFile.Truncate(validLength); err != nil {
+		logFile.Close()
+		return fmt.Errorf("unable to truncate write-ahead log: %w", err)
+	}
+
+	pendingEntries := 0
+	for _, entry := range entries {
+		// Entries at or before the snapshot remain if the datastore stopped after writing
+		// the snapshot but before truncating the log.
+		if entry.RevisionNanos <= snapshotRevisionNanos {
+			continue
+		}
+
+		if err := mdb.replay(entry); err != nil {
+			logFile.Close()
+			return err
+		}
+		pendingEntries++
+	}
+
+	log.Info().
+		Str("dir", dir).
+		Int("replayedTransactions", pendingEntries).
+		Msg("restored memdb datastore from disk")
+
+	mdb.persistence = &persistence{
+		dir:            dir,
+		log:            logFile,
+		pendingEntries: pendingEntries,
+	}
+
+	return nil
+}
+
+// loadSnapshot loads the snapshot at the given path, if any, into the datastore and
+// returns its revision.
+func (mdb *memdbDatastore) loadSnapshot(path string) (int64, error) {
+	serialized, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		mdb.revisions = []snapshot{{decimal.Zero, mdb.db.Snapshot()}}
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("unable to read snapshot: %w", err)
+	}
+
+	snap := &implv1.MemdbSnapshot{}
+	if err := proto.Unmarshal(serialized, snap); err != nil {
+		return 0, fmt.Errorf("unable to decode snapshot: %w", err)
+	}
+
+	tx := mdb.db.Txn(true)
+	defer tx.Abort()
+
+	for _, ns := range snap.Namespaces {
+		serializedConfig, err := proto.Marshal(ns.Definition)
+		if err != nil {
+			return 0, fmt.Errorf("unable to restore namespace: %w", err)
+		}
+
+		updated := decimal.NewFromInt(ns.UpdatedRevisionNanos)
+		if err := tx.Insert(tableNamespace, &namespace{ns.Definition.Name, serializedConfig, updated}); err != nil {
+			return 0, fmt.Errorf("unable to restore namespace: %w", err)
+		}
+	}
+
+	for _, tpl := range snap.Relationships {
+		if err := tx.Insert(tableRelationship, relationshipFromTuple(tpl)); err != nil {
+			return 0, fmt.Errorf("unable to restore relationship: %w", err)
+		}
+	}
+
+	tx.Commit()
+
+	mdb.revisions = []snapshot{{decimal.NewFromInt(snap.RevisionNanos), mdb.db.Snapshot()}}
+	return snap.RevisionNanos, nil
+}
+
+// replay applies a transaction read from the log, recording it as a new revision.
+func (mdb *memdbDatastore) replay(entry *implv1.MemdbLogEntry) error {
+	revision := decimal.NewFromInt(entry.RevisionNanos)
+
+	tx := mdb.db.Txn(true)
+	defer tx.Abort()
+
+	for _, nsName := range entry.DeletedNamespaces {
+		if _, err := tx.DeleteAll(tableNamespace, indexName, nsName); err != nil {
+			return fmt.Errorf("unable to replay namespace deletion: %w", err)
+		}
+	}
+
+	for _, def := range entry.WrittenNamespaces {
+		serializedConfig, err := proto.Marshal(def)
+		if err != nil {
+			return fmt.Errorf("unable to replay namespace write: %w", err)
+		}
+
+		if err := tx.Insert(tableNamespace, &namespace{def.Name, serializedConfig, revision}); err != nil {
+			return fmt.Errorf("unable to replay namespace write: %w", err)
+		}
+	}
+
+	if err := writeRelationships(tx, tuple.UpdatesToRelationshipUpdates(entry.RelationshipUpdates)); err != nil {
+		return fmt.Errorf("unable to replay relationship updates: %w", err)
+	}
+
+	change := &changelog{
+		revisionNanos: entry.RevisionNanos,
+		changes: datastore.RevisionChanges{
+			Revision: revision,
+			Changes:  entry.RelationshipUpdates,
+		},
+	}
+	if err := tx.Insert(tableChangelog, change); err != nil {
+		return fmt.Errorf("error writing changelog: %w", err)
+	}
+
+	tx.Commit()
+
+	mdb.revisions = append(mdb.revisions, snapshot{revision, mdb.db.Snapshot()})
+	return nil
+}
+
+// readLog reads the entries of the log, returning them along with the length of the log
+// up to the end of the last whole entry.
+func readLog(logFile *os.File) ([]*implv1.MemdbLogEntry, int64, error) {
+	reader := bufio.NewReader(logFile)
+
+	var entries []*implv1.MemdbLogEntry
+	var validLength int64
+	header := make([]byte, logEntryHeaderSize)
+	for {
+		if _, err := io.ReadFull(reader, header); err != nil {
+			if errors.Is(err, io.ErrUnexpectedEOF) {
+				log.Warn().Int64("offset", validLength).Msg("discarding truncated entry at the end of the memdb write-ahead log")
+			} else if !errors.Is(err, io.EOF) {
+				return nil, 0, fmt.Errorf("unable to read write-ahead log: %w", err)
+			}
+			return entries, validLength, nil
+		}
+
+		length := binary.BigEndian.Uint32(header[0:4])
+		checksum := binary.BigEndian.Uint32(header[4:8])
+
+		serialized := make([]byte, length)
+		if _, err := io.ReadFull(reader, serialized); err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+				return nil, 0, fmt.Errorf("unable to read write-ahead log: %w", err)
+			}
+			log.Warn().Int64("offset", validLength).Msg("discarding truncated entry at the end of the memdb write-ahead log")
+			return entries, validLength, nil
+		}
+
+		entry := &implv1.MemdbLogEntry{}
+		if crc32.Checksum(serialized, crcTable) != checksum || proto.Unmarshal(serialized, entry) != nil {
+			log.Warn().Int64("offset", validLength).Msg("discarding corrupt entry at the end of the memdb write-ahead log")
+			return entries, validLength, nil
+		}
+
+		entries = append(entries, entry)
+		validLength += int64(logEntryHeaderSize) + int64(length)
+	}
+}
+
+// persistTxn appends the net effect of the given transaction to the log.
+func (mdb *memdbDatastore) persistTxn(revision datastore.Revision, tx *memdb.Txn, updates []*core.RelationTupleUpdate) error {
+	entry, err := logEntryForTxn(revision, tx, updates)
+	if err != nil {
+		return err
+	}
+	return mdb.persistence.append(entry)
+}
+
+// logEntryForTxn builds the log entry for the net effect of the given transaction.
+func logEntryForTxn(revision datastore.Revision, tx *memdb.Txn, updates []*core.RelationTupleUpdate) (*implv1.MemdbLogEntry, error) {
+	entry := &implv1.MemdbLogEntry{
+		RevisionNanos:       revision.IntPart(),
+		RelationshipUpdates: updates,
+	}
+
+	for _, change := range tx.Changes() {
+		if change.Table != tableNamespace {
+			continue
+		}
+
+		if change.After == nil {
+			entry.DeletedNamespaces = append(entry.DeletedNamespaces, change.Before.(*namespace).name)
+			continue
+		}
+
+		def := &core.NamespaceDefinition{}
+		if err := proto.Unmarshal(change.After.(*namespace).configBytes, def); err != nil {
+			return nil, err
+		}
+		entry.WrittenNamespaces = append(entry.WrittenNamespaces, def)
+	}
+
+	return entry, nil
+}
+
+// append durably appends an entry to the log.
+func (p *persistence) append(entry *implv1.MemdbLogEntry) error {
+	serialized, err := proto.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	record := make([]byte, logEntryHeaderSize, logEntryHeaderSize+len(serialized))
+	binary.BigEndian.PutUint32(record[0:4], uint32(len(serialized)))
+	binary.BigEndian.PutUint32(record[4:8], crc32.Checksum(serialized, crcTable))
+	record = append(record, serialized...)
+
+	if _, err := p.log.Write(record); err != nil {
+		return err
+	}
+	if err := p.log.Sync(); err != nil {
+		return err
+	}
+
+	p.pendingEntries++
+	return nil
+}
+
+// runSnapshotter periodically writes a snapshot until the context is canceled.
+func (mdb *memdbDatastore) runSnapshotter(ctx context.Context, interval time.Duration) {
+	defer close(mdb.persistence.snapshotsDone)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-ticker.C:
+			mdb.Lock()
+			err := mdb.writeSnapshot()
+			mdb.Unlock()
+
+			if err != nil {
+				log.Warn().Err(err).Msg("error when attempting to snapshot memdb datastore")
+			}
+		}
+	}
+}
+
+// writeSnapshot replaces the snapshot with the current state of the datastore, after which
+// the log is truncated. The caller must hold the datastore's lock.
+func (mdb *memdbDatastore) writeSnapshot() error {
+	p := mdb.persistence
+	if p.pendingEntries == 0 || mdb.db == nil {
+		return nil
+	}
+
+	snap := &implv1.MemdbSnapshot{
+		RevisionNanos: mdb.revisions[len(mdb.revisions)-1].revision.IntPart(),
+	}
+
+	txn := mdb.db.Txn(false)
+	defer txn.Abort()
+
+	nsIt, err := txn.Get(tableNamespace, indexName)
+	if err != nil {
+		return fmt.Errorf("unable to load namespaces: %w", err)
+	}
+	for row := nsIt.Next(); row != nil; row = nsIt.Next() {
+		ns := row.(*namespace)
+
+		def := &core.NamespaceDefinition{}
+		if err := proto.Unmarshal(ns.configBytes, def); err != nil {
+			return fmt.Errorf("unable to load namespaces: %w", err)
+		}
+
+		snap.Namespaces = append(snap.Namespaces, &implv1.MemdbSnapshot_Namespace{
+			Definition:           def,
+			UpdatedRevisionNanos: ns.updated.IntPart(),
+		})
+	}
+
+	relIt, err := txn.Get(tableRelationship, indexID)
+	if err != nil {
+		return fmt.Errorf("unable to load relationships: %w", err)
+	}
+	for row := relIt.Next(); row != nil; row = relIt.Next() {
+		snap.Relationships = append(snap.Relationships, row.(*relationship).RelationTuple())
+	}
+
+	serialized, err := proto.Marshal(snap)
+	if err != nil {
+		return err
+	}
+
+	// Write the snapshot to a temporary file and rename it into place, so that a crash never
+	// leaves a partially written snapshot.
+	snapshotPath := filepath.Join(p.dir, snapshotFileName)
+	tmpFile, err := os.CreateTemp(p.dir, snapshotFileName+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("unable to create snapshot: %w", err)
+	}
+	defer os.Remove(tmpFile.Name())
+
+	if _, err := tmpFile.Write(serialized); err != nil {
+		tmpFile.Close()
+		return fmt.Errorf("unable to write snapshot: %w", err)
+	}
+	if err := tmpFile.Sync(); err != nil {
+		tmpFile.Close()
+		return fmt.Errorf("unable to write snapshot: %w", err)
+	}
+	if err := tmpFile.Close(); err != nil {
+		return fmt.Errorf("unable to write snapshot: %w", err)
+	}
+	if err := os.Rename(tmpFile.Name(), snapshotPath); err != nil {
+		return fmt.Errorf("unable to write snapshot: %w", err)
+	}
+
+	if err := p.log.Truncate(0); err != nil {
+		return fmt.Errorf("unable to truncate write-ahead log: %w", err)
+	}
+	if err := p.log.Sync(); err != nil {
+		return fmt.Errorf("unable to truncate write-ahead log: %w", err)
+	}
+
+	p.pendingEntries = 0
+	return nil
+}
+
+// closePersistence writes a final snapshot and closes the log. The caller must hold the
+// datastore's lock, and must have stopped the snapshotter.
+func (mdb *memdbDatastore) closePersistence() error {
+	snapshotErr := mdb.writeSnapshot()
+	if err := mdb.persistence.log.Close(); err != nil {
+		return err
+	}
+	return snapshotErr
+}
+
+func relationshipFromTuple(tpl *core.RelationTuple) *relationship {
+	userset := tpl.User.GetUserset()
+	return &relationship{
+		tpl.ObjectAndRelation.Namespace,
+		tpl.ObjectAndRelation.ObjectId,
+		tpl.ObjectAndRelation.Relation,
+		userset.Namespace,
+		userset.ObjectId,
+		userset.Relation,
+	}
+}
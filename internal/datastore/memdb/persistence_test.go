@@ -0,0 +1,168 @@
+package memdb
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/spicedb/pkg/datastore"
+	ns "github.com/authzed/spicedb/pkg/namespace"
+	corev1 "github.com/authzed/spicedb/pkg/proto/core/v1"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+var (
+	persistedTom   = tuple.MustParse("resource:foo#reader@user:tom")
+	persistedSarah = tuple.MustParse("resource:foo#reader@user:sarah")
+	persistedFred  = tuple.MustParse("resource:bar#reader@user:fred")
+)
+
+func newPersistedDatastore(t *testing.T, dir string) *memdbDatastore {
+	ds, err := NewMemdbDatastore(0, 0, DisableGC, PersistenceDir(dir), SnapshotInterval(1*time.Hour))
+	require.NoError(t, err)
+	t.Cleanup(func() { ds.Close() })
+	return ds.(*memdbDatastore)
+}
+
+// writePersistenceFixture writes a namespace and relationships in separate transactions,
+// returning the revision of each transaction.
+func writePersistenceFixture(ctx context.Context, require *require.Assertions, ds datastore.Datastore) []datastore.Revision {
+	nsWritten, err := ds.ReadWriteTx(ctx, func(ctx context.Context, rwt datastore.ReadWriteTransaction) error {
+		return rwt.WriteNamespaces(
+			ns.Namespace("resource", ns.Relation("reader", nil)),
+			ns.Namespace("user"),
+		)
+	})
+	require.NoError(err)
+
+	return []datastore.Revision{
+		nsWritten,
+		writeRelationship(ctx, require, ds, v1.RelationshipUpdate_OPERATION_CREATE, persistedTom),
+		writeRelationship(ctx, require, ds, v1.RelationshipUpdate_OPERATION_CREATE, persistedSarah),
+		writeRelationship(ctx, require, ds, v1.RelationshipUpdate_OPERATION_DELETE, persistedTom),
+	}
+}
+
+func requireRelationships(ctx context.Context, require *require.Assertions, ds datastore.Datastore, revision datastore.Revision, expected ...*corev1.RelationTuple) {
+	iter, err := ds.SnapshotReader(revision).QueryRelationships(ctx, &v1.RelationshipFilter{ResourceType: "resource"})
+	require.NoError(err)
+	defer iter.Close()
+
+	var found []*corev1.RelationTuple
+	for tpl := iter.Next(); tpl != nil; tpl = iter.Next() {
+		found = append(found, tpl)
+	}
+	require.NoError(iter.Err())
+	require.ElementsMatch(expected, found)
+}
+
+func TestPersistenceRestoresFromSnapshot(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	ds := newPersistedDatastore(t, dir)
+	revisions := writePersistenceFixture(ctx, require, ds)
+	require.NoError(ds.Close())
+
+	// Closing writes a snapshot and empties the log.
+	logInfo, err := os.Stat(filepath.Join(dir, logFileName))
+	require.NoError(err)
+	require.Zero(logInfo.Size())
+
+	restored := newPersistedDatastore(t, dir)
+	head := revisions[len(revisions)-1]
+	require.NoError(restored.CheckRevision(ctx, head))
+	requireRelationships(ctx, require, restored, head, persistedSarah)
+
+	def, lastWritten, err := restored.SnapshotReader(head).ReadNamespace(ctx, "resource")
+	require.NoError(err)
+	require.Equal("resource", def.Name)
+	require.True(revisions[0].Equal(lastWritten))
+
+	// Writes continue from the restored state.
+	written := writeRelationship(ctx, require, restored, v1.RelationshipUpdate_OPERATION_CREATE, persistedFred)
+	requireRelationships(ctx, require, restored, written, persistedSarah, persistedFred)
+}
+
+func TestPersistenceReplaysLog(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	// The first datastore is never closed, as if the process had crashed.
+	ds := newPersistedDatastore(t, dir)
+	revisions := writePersistenceFixture(ctx, require, ds)
+
+	restored := newPersistedDatastore(t, dir)
+
+	// Each transaction is restored as its own revision.
+	requireRelationships(ctx, require, restored, revisions[0])
+	requireRelationships(ctx, require, restored, revisions[1], persistedTom)
+	requireRelationships(ctx, require, restored, revisions[2], persistedTom, persistedSarah)
+	requireRelationships(ctx, require, restored, revisions[3], persistedSarah)
+
+	// Watching from a restored revision returns the restored changes.
+	changes, errs := restored.Watch(ctx, revisions[1])
+	select {
+	case change := <-changes:
+		require.True(revisions[2].Equal(change.Revision))
+		require.Equal([]*corev1.RelationTupleUpdate{tuple.Touch(persistedSarah)}, change.Changes)
+	case err := <-errs:
+		require.FailNow("unexpected watch error", err)
+	case <-time.After(1 * time.Second):
+		require.FailNow("timed out waiting for restored changes")
+	}
+}
+
+func TestPersistenceReplaysLogAfterSnapshot(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	ds := newPersistedDatastore(t, dir)
+	revisions := writePersistenceFixture(ctx, require, ds)
+
+	ds.Lock()
+	require.NoError(ds.writeSnapshot())
+	ds.Unlock()
+
+	written := writeRelationship(ctx, require, ds, v1.RelationshipUpdate_OPERATION_CREATE, persistedFred)
+
+	restored := newPersistedDatastore(t, dir)
+	requireRelationships(ctx, require, restored, revisions[len(revisions)-1], persistedSarah)
+	requireRelationships(ctx, require, restored, written, persistedSarah, persistedFred)
+}
+
+func TestPersistenceToleratesTruncatedLog(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	ds := newPersistedDatastore(t, dir)
+	revisions := writePersistenceFixture(ctx, require, ds)
+
+	// Cut the last entry short, as if the process had crashed while appending it.
+	logPath := filepath.Join(dir, logFileName)
+	logInfo, err := os.Stat(logPath)
+	require.NoError(err)
+	require.NoError(os.Truncate(logPath, logInfo.Size()-3))
+
+	restored := newPersistedDatastore(t, dir)
+	requireRelationships(ctx, require, restored, revisions[2], persistedTom, persistedSarah)
+
+	head, err := restored.HeadRevision(ctx)
+	require.NoError(err)
+	requireRelationships(ctx, require, restored, head, persistedTom, persistedSarah)
+
+	// The partial entry was discarded, so entries appended after it can be restored.
+	written := writeRelationship(ctx, require, restored, v1.RelationshipUpdate_OPERATION_CREATE, persistedFred)
+
+	restoredAgain := newPersistedDatastore(t, dir)
+	requireRelationships(ctx, require, restoredAgain, written, persistedTom, persistedSarah, persistedFred)
+}
@@ -218,6 +218,42 @@ func (r *memdbReader) ListNamespaces(ctx context.Context) ([]*core.NamespaceDefi
 	return nsDefs, nil
 }
 
+// CountRelationships counts the relationships matching the filter.
+func (r *memdbReader) CountRelationships(ctx context.Context, filter *v1.RelationshipFilter) (uint64, error) {
+	if r.initErr != nil {
+		return 0, r.initErr
+	}
+
+	r.lockOrPanic()
+	defer r.Unlock()
+
+	tx, err := r.txSource()
+	if err != nil {
+		return 0, err
+	}
+
+	bestIterator, err := iteratorForFilter(tx, filter)
+	if err != nil {
+		return 0, err
+	}
+
+	matchingRelationshipsFilterFunc := filterFuncForFilters(
+		filter.ResourceType,
+		filter.OptionalResourceId,
+		filter.OptionalRelation,
+		filter.OptionalSubjectFilter,
+		nil,
+	)
+	filteredIterator := memdb.NewFilterIterator(bestIterator, matchingRelationshipsFilterFunc)
+
+	var count uint64
+	for row := filteredIterator.Next(); row != nil; row = filteredIterator.Next() {
+		count++
+	}
+
+	return count, nil
+}
+
 func (r *memdbReader) lockOrPanic() {
 	if !r.TryLock() {
 		panic("detected concurrent use of ReadWriteTransaction")
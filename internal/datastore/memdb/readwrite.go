@@ -26,11 +26,11 @@ func (rwt *memdbReadWriteTx) WriteRelationships(mutations []*v1.RelationshipUpda
 		return err
 	}
 
-	return rwt.write(tx, mutations)
+	return writeRelationships(tx, mutations)
 }
 
 // Caller must already hold the concurrent access lock!
-func (rwt *memdbReadWriteTx) write(tx *memdb.Txn, mutations []*v1.RelationshipUpdate) error {
+func writeRelationships(tx *memdb.Txn, mutations []*v1.RelationshipUpdate) error {
 	// Apply the mutations
 	for _, mutation := range mutations {
 		rel := &relationship{
@@ -115,7 +115,7 @@ func (rwt *memdbReadWriteTx) deleteWithLock(tx *memdb.Txn, filter *v1.Relationsh
 		})
 	}
 
-	return rwt.write(tx, mutations)
+	return writeRelationships(tx, mutations)
 }
 
 func (rwt *memdbReadWriteTx) WriteNamespaces(newConfigs ...*core.NamespaceDefinition) error {
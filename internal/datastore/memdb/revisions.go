@@ -44,3 +44,27 @@ func (mdb *memdbDatastore) checkRevisionLocal(revision datastore.Revision) error
 
 	return nil
 }
+
+// beginRevision returns the revision for a new read/write transaction, which remains pending
+// until the transaction either commits or aborts.
+func (mdb *memdbDatastore) beginRevision() datastore.Revision {
+	mdb.Lock()
+	defer mdb.Unlock()
+
+	revision := revisionFromTimestamp(time.Now().UTC())
+	mdb.pendingRevisions[revision.IntPart()] = struct{}{}
+	return revision
+}
+
+// checkpointRevision returns the latest revision at which no read/write transaction may yet
+// commit. The caller must hold the read lock.
+func (mdb *memdbDatastore) checkpointRevision() int64 {
+	// Any transaction beginning after this point receives a later revision.
+	checkpoint := time.Now().UTC().UnixNano() - 1
+	for pending := range mdb.pendingRevisions {
+		if pending <= checkpoint {
+			checkpoint = pending - 1
+		}
+	}
+	return checkpoint
+}
@@ -13,7 +13,7 @@ func (mdb *memdbDatastore) Statistics(ctx context.Context) (datastore.Stats, err
 		return datastore.Stats{}, fmt.Errorf("unable to compute head revision: %w", err)
 	}
 
-	count, err := mdb.countRelationships(ctx)
+	count, countsByType, err := mdb.countRelationships(ctx)
 	if err != nil {
 		return datastore.Stats{}, fmt.Errorf("unable to count relationships: %w", err)
 	}
@@ -26,11 +26,13 @@ func (mdb *memdbDatastore) Statistics(ctx context.Context) (datastore.Stats, err
 	return datastore.Stats{
 		UniqueID:                   mdb.uniqueID,
 		EstimatedRelationshipCount: count,
-		ObjectTypeStatistics:       datastore.ComputeObjectTypeStats(objTypes),
+		ObjectTypeStatistics:       datastore.ComputeObjectTypeStats(objTypes, countsByType),
 	}, nil
 }
 
-func (mdb *memdbDatastore) countRelationships(ctx context.Context) (uint64, error) {
+// countRelationships counts all of the relationships, along with the relationships for
+// each object type.
+func (mdb *memdbDatastore) countRelationships(ctx context.Context) (uint64, map[string]uint64, error) {
 	mdb.RLock()
 	defer mdb.RUnlock()
 
@@ -39,13 +41,15 @@ func (mdb *memdbDatastore) countRelationships(ctx context.Context) (uint64, erro
 
 	it, err := txn.LowerBound(tableRelationship, indexID)
 	if err != nil {
-		return 0, err
+		return 0, nil, err
 	}
 
 	var count uint64
+	countsByType := make(map[string]uint64)
 	for row := it.Next(); row != nil; row = it.Next() {
 		count++
+		countsByType[row.(*relationship).namespace]++
 	}
 
-	return count, nil
+	return count, countsByType, nil
 }
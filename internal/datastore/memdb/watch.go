@@ -4,13 +4,22 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/hashicorp/go-memdb"
+	"github.com/shopspring/decimal"
 
+	"github.com/authzed/spicedb/internal/datastore/common"
 	"github.com/authzed/spicedb/pkg/datastore"
 )
 
-const errWatchError = "watch error: %w"
+const (
+	errWatchError = "watch error: %w"
+
+	// watchCheckpointInterval is the longest time for which a watcher waits for changes before
+	// sending a checkpoint.
+	watchCheckpointInterval = 100 * time.Millisecond
+)
 
 func (mdb *memdbDatastore) Watch(ctx context.Context, afterRevision datastore.Revision) (<-chan *datastore.RevisionChanges, <-chan error) {
 	updates := make(chan *datastore.RevisionChanges, mdb.watchBufferLength)
@@ -21,12 +30,14 @@ func (mdb *memdbDatastore) Watch(ctx context.Context, afterRevision datastore.Re
 		defer close(errs)
 
 		currentTxn := afterRevision.IntPart()
+		checkpoints := datastore.WatchCheckpointsRequested(ctx)
 
 		for {
 			var stagedUpdates []*datastore.RevisionChanges
+			var checkpoint int64
 			var watchChan <-chan struct{}
 			var err error
-			stagedUpdates, currentTxn, watchChan, err = mdb.loadChanges(ctx, currentTxn)
+			stagedUpdates, currentTxn, checkpoint, watchChan, err = mdb.loadChanges(ctx, currentTxn)
 			if err != nil {
 				errs <- err
 				return
@@ -42,18 +53,29 @@ func (mdb *memdbDatastore) Watch(ctx context.Context, afterRevision datastore.Re
 				}
 			}
 
-			// Wait for new changes
+			if checkpoints && checkpoint > currentTxn {
+				currentTxn = checkpoint
+				common.SendCheckpoint(ctx, updates, decimal.NewFromInt(checkpoint))
+			}
+
+			// Wait for new changes, or until the next checkpoint is due
 			ws := memdb.NewWatchSet()
 			ws.Add(watchChan)
 
-			err = ws.WatchCtx(ctx)
-			if err != nil {
-				switch {
-				case errors.Is(err, context.Canceled):
-					errs <- datastore.NewWatchCanceledErr()
-				default:
-					errs <- fmt.Errorf(errWatchError, err)
-				}
+			waitCtx, cancel := ctx, func() {}
+			if checkpoints {
+				waitCtx, cancel = context.WithTimeout(ctx, watchCheckpointInterval)
+			}
+			err = ws.WatchCtx(waitCtx)
+			cancel()
+			switch {
+			case err == nil:
+			case ctx.Err() == nil && errors.Is(err, context.DeadlineExceeded):
+			case errors.Is(err, context.Canceled):
+				errs <- datastore.NewWatchCanceledErr()
+				return
+			default:
+				errs <- fmt.Errorf(errWatchError, err)
 				return
 			}
 		}
@@ -62,7 +84,9 @@ func (mdb *memdbDatastore) Watch(ctx context.Context, afterRevision datastore.Re
 	return updates, errs
 }
 
-func (mdb *memdbDatastore) loadChanges(ctx context.Context, currentTxn int64) ([]*datastore.RevisionChanges, int64, <-chan struct{}, error) {
+// loadChanges loads the changes after the current revision, along with the revision up to which
+// all changes are known to have been loaded.
+func (mdb *memdbDatastore) loadChanges(ctx context.Context, currentTxn int64) ([]*datastore.RevisionChanges, int64, int64, <-chan struct{}, error) {
 	mdb.RLock()
 	defer mdb.RUnlock()
 
@@ -71,7 +95,7 @@ func (mdb *memdbDatastore) loadChanges(ctx context.Context, currentTxn int64) ([
 
 	it, err := loadNewTxn.LowerBound(tableChangelog, indexRevision, currentTxn+1)
 	if err != nil {
-		return nil, 0, nil, fmt.Errorf(errWatchError, err)
+		return nil, 0, 0, nil, fmt.Errorf(errWatchError, err)
 	}
 
 	var changes []*datastore.RevisionChanges
@@ -84,8 +108,8 @@ func (mdb *memdbDatastore) loadChanges(ctx context.Context, currentTxn int64) ([
 
 	watchChan, _, err := loadNewTxn.LastWatch(tableChangelog, indexRevision)
 	if err != nil {
-		return nil, 0, nil, fmt.Errorf(errWatchError, err)
+		return nil, 0, 0, nil, fmt.Errorf(errWatchError, err)
 	}
 
-	return changes, lastRevision, watchChan, nil
+	return changes, lastRevision, mdb.checkpointRevision(), watchChan, nil
 }
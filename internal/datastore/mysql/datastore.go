@@ -40,6 +40,8 @@ const (
 	colUsersetNamespace = "userset_namespace"
 	colUsersetObjectID  = "userset_object_id"
 	colUsersetRelation  = "userset_relation"
+	colShard            = "shard"
+	colCount            = "count"
 
 	errUnableToInstantiate = "unable to instantiate datastore: %w"
 	liveDeletedTxnID       = uint64(math.MaxInt64)
@@ -88,19 +90,9 @@ func NewMySQLDatastore(uri string, options ...Option) (*Datastore, error) {
 	if err != nil {
 		return nil, fmt.Errorf(errUnableToInstantiate, err)
 	}
-	connector, err := mysql.MySQLDriver{}.OpenConnector(uri)
+	connector, err := newConnector(uri, config)
 	if err != nil {
-		return nil, fmt.Errorf("NewMySQLDatastore: failed to create connector: %w", err)
-	}
-
-	if config.lockWaitTimeoutSeconds != nil {
-		log.Info().Uint8("timeout", *config.lockWaitTimeoutSeconds).Msg("overriding innodb_lock_wait_timeout")
-		connector, err = addSessionVariables(connector, map[string]string{
-			"innodb_lock_wait_timeout": fmt.Sprintf("%d", *config.lockWaitTimeoutSeconds),
-		})
-		if err != nil {
-			return nil, fmt.Errorf("NewMySQLDatastore: failed to add session variables to connector: %w", err)
-		}
+		return nil, err
 	}
 
 	var db *sql.DB
@@ -116,14 +108,23 @@ func NewMySQLDatastore(uri string, options ...Option) (*Datastore, error) {
 		if err != nil {
 			return nil, fmt.Errorf(errUnableToInstantiate, err)
 		}
+
+		if len(config.readReplicaURIs) > 0 {
+			err = common.RegisterReplicaMetrics()
+			if err != nil {
+				return nil, fmt.Errorf(errUnableToInstantiate, err)
+			}
+		}
 	} else {
 		db = sql.OpenDB(connector)
 	}
 
-	db.SetConnMaxLifetime(config.connMaxLifetime)
-	db.SetConnMaxIdleTime(config.connMaxIdleTime)
-	db.SetMaxOpenConns(config.maxOpenConns)
-	db.SetMaxIdleConns(config.maxOpenConns)
+	configurePool(db, config)
+
+	replicaDBs, replicaRouter, err := openReplicas(config)
+	if err != nil {
+		return nil, err
+	}
 
 	driver := migrations.NewMySQLDriverFromDB(db, config.tablePrefix)
 	queryBuilder := NewQueryBuilder(driver)
@@ -173,6 +174,7 @@ func NewMySQLDatastore(uri string, options ...Option) (*Datastore, error) {
 		gcWindowInverted:       gcWindowInverted,
 		gcInterval:             config.gcInterval,
 		gcMaxOperationTime:     config.gcMaxOperationTime,
+		gcArchiveHistory:       config.gcArchiveHistory,
 		gcCtx:                  gcCtx,
 		cancelGc:               cancelGc,
 		watchBufferLength:      config.watchBufferLength,
@@ -185,6 +187,9 @@ func NewMySQLDatastore(uri string, options ...Option) (*Datastore, error) {
 		readTxOptions:          &sql.TxOptions{Isolation: sql.LevelSerializable, ReadOnly: true},
 		maxRetries:             config.maxRetries,
 		analyzeBeforeStats:     config.analyzeBeforeStats,
+		replicaDBs:             replicaDBs,
+		replicaRouter:          replicaRouter,
+		replicaLagQuery:        fmt.Sprintf(queryReplicaLag, colTimestamp, driver.RelationTupleTransaction(), colID),
 		CachedOptimizedRevisions: revisions.NewCachedOptimizedRevisions(
 			maxRevisionStaleness,
 		),
@@ -199,6 +204,10 @@ func NewMySQLDatastore(uri string, options ...Option) (*Datastore, error) {
 		return nil, err
 	}
 
+	if len(replicaDBs) > 0 {
+		replicaRouter.Start(config.readReplicaPollInterval, store.replicaStatus)
+	}
+
 	// Start a goroutine for garbage collection.
 	if store.gcInterval > 0*time.Minute {
 		store.gcGroup, store.gcCtx = errgroup.WithContext(store.gcCtx)
@@ -212,8 +221,13 @@ func NewMySQLDatastore(uri string, options ...Option) (*Datastore, error) {
 
 // TODO (@vroldanbet) dupe from postgres datastore - need to refactor
 func (mds *Datastore) SnapshotReader(rev datastore.Revision) datastore.Reader {
+	db := mds.db
+	if replica, ok := mds.replicaRouter.ReplicaFor(transactionFromRevision(rev)); ok {
+		db = mds.replicaDBs[replica]
+	}
+
 	createTxFunc := func(ctx context.Context) (*sql.Tx, txCleanupFunc, error) {
-		tx, err := mds.db.BeginTx(ctx, mds.readTxOptions)
+		tx, err := db.BeginTx(ctx, mds.readTxOptions)
 		if err != nil {
 			return nil, nil, err
 		}
@@ -222,7 +236,7 @@ func (mds *Datastore) SnapshotReader(rev datastore.Revision) datastore.Reader {
 	}
 
 	querySplitter := common.TupleQuerySplitter{
-		Executor:         newMySQLExecutor(mds.db),
+		Executor:         newMySQLExecutor(db),
 		UsersetBatchSize: mds.usersetBatchSize,
 	}
 
@@ -231,6 +245,7 @@ func (mds *Datastore) SnapshotReader(rev datastore.Revision) datastore.Reader {
 		createTxFunc,
 		querySplitter,
 		buildLivingObjectFilterForRevision(rev),
+		transactionFromRevision(rev),
 	}
 }
 
@@ -267,16 +282,22 @@ func (mds *Datastore) ReadWriteTx(
 					longLivedTx,
 					querySplitter,
 					currentlyLivingObjects,
+					0,
 				},
 				ctx,
 				tx,
 				newTxnID,
+				make(common.RelationshipCountChanges),
 			}
 
 			if err := fn(ctx, rwt); err != nil {
 				return err
 			}
 
+			if err := rwt.updateCounters(ctx); err != nil {
+				return fmt.Errorf("error updating relationship counters: %w", err)
+			}
+
 			return nil
 		}); err != nil {
 			if isErrorRetryable(err) {
@@ -379,10 +400,15 @@ type Datastore struct {
 	gcWindowInverted     time.Duration
 	gcInterval           time.Duration
 	gcMaxOperationTime   time.Duration
+	gcArchiveHistory     bool
 	watchBufferLength    uint16
 	usersetBatchSize     uint16
 	maxRetries           uint8
 
+	replicaDBs      []*sql.DB
+	replicaRouter   *common.ReplicaRouter
+	replicaLagQuery string
+
 	optimizedRevisionQuery string
 	validTransactionQuery  string
 
@@ -406,6 +432,14 @@ func (mds *Datastore) Close() error {
 			log.Error().Err(err).Msg("error waiting for garbage collector to shutdown")
 		}
 	}
+
+	mds.replicaRouter.Stop()
+	for _, db := range mds.replicaDBs {
+		if err := db.Close(); err != nil {
+			log.Error().Err(err).Msg("error closing read replica connection pool")
+		}
+	}
+
 	return mds.db.Close()
 }
 
@@ -511,7 +545,13 @@ func (mds *Datastore) collectGarbageBefore(ctx context.Context, before time.Time
 // - implementation misses metrics
 func (mds *Datastore) collectGarbageForTransaction(ctx context.Context, highest uint64) (int64, int64, error) {
 	// Delete any relationship rows with deleted_transaction <= the transaction ID.
-	relCount, err := mds.batchDelete(ctx, mds.driver.RelationTuple(), sq.LtOrEq{colDeletedTxn: highest})
+	relCount, err := mds.batchRemove(
+		ctx,
+		mds.driver.RelationTuple(),
+		mds.driver.RelationTupleHistory(),
+		tupleHistoryColumns,
+		sq.LtOrEq{colDeletedTxn: highest},
+	)
 	if err != nil {
 		return 0, 0, err
 	}
@@ -520,7 +560,13 @@ func (mds *Datastore) collectGarbageForTransaction(ctx context.Context, highest
 
 	// Delete all transaction rows with ID < the transaction ID. We don't delete the transaction
 	// itself to ensure there is always at least one transaction present.
-	transactionCount, err := mds.batchDelete(ctx, mds.driver.RelationTupleTransaction(), sq.Lt{colID: highest})
+	transactionCount, err := mds.batchRemove(
+		ctx,
+		mds.driver.RelationTupleTransaction(),
+		mds.driver.RelationTupleTransactionHistory(),
+		transactionHistoryColumns,
+		sq.Lt{colID: highest},
+	)
 	if err != nil {
 		return relCount, 0, err
 	}
@@ -31,21 +31,29 @@ const (
 )
 
 type datastoreTester struct {
-	b      testdatastore.RunningEngineForTest
-	t      *testing.T
-	prefix string
+	b           testdatastore.RunningEngineForTest
+	t           *testing.T
+	prefix      string
+	readReplica bool
 }
 
 func (dst *datastoreTester) createDatastore(revisionQuantization, gcWindow time.Duration, _ uint16) (datastore.Datastore, error) {
 	ds := dst.b.NewDatastore(dst.t, func(engine, uri string) datastore.Datastore {
-		ds, err := NewMySQLDatastore(uri,
+		options := []Option{
 			RevisionQuantization(revisionQuantization),
 			GCWindow(gcWindow),
-			GCInterval(0*time.Second),
+			GCInterval(0 * time.Second),
 			TablePrefix(dst.prefix),
 			DebugAnalyzeBeforeStatistics(),
 			OverrideLockWaitTimeout(1),
-		)
+		}
+		if dst.readReplica {
+			// Use the primary as its own replica, to ensure snapshot reads routed to a
+			// replica observe the same data as those served by the primary.
+			options = append(options, ReadReplicaURIs([]string{uri}), ReadReplicaPollInterval(1*time.Millisecond))
+		}
+
+		ds, err := NewMySQLDatastore(uri, options...)
 		require.NoError(dst.t, err)
 		return ds
 	})
@@ -95,6 +103,11 @@ func TestMySQLDatastore(t *testing.T) {
 	t.Run("GarbageCollection", createDatastoreTest(b, GarbageCollectionTest, defaultOptions...))
 	t.Run("GarbageCollectionByTime", createDatastoreTest(b, GarbageCollectionByTimeTest, defaultOptions...))
 	t.Run("ChunkedGarbageCollection", createDatastoreTest(b, ChunkedGarbageCollectionTest, defaultOptions...))
+	t.Run("GarbageCollectionArchive", createDatastoreTest(
+		b,
+		GarbageCollectionArchiveTest,
+		append(defaultOptions, GCArchiveHistory(true))...,
+	))
 	t.Run("TransactionTimestamps", createDatastoreTest(b, TransactionTimestampsTest, defaultOptions...))
 	t.Run("QuantizedRevisions", func(t *testing.T) {
 		QuantizedRevisionTest(t, b)
@@ -107,6 +120,12 @@ func TestMySQLDatastoreWithTablePrefix(t *testing.T) {
 	test.All(t, test.DatastoreTesterFunc(dst.createDatastore))
 }
 
+func TestMySQLDatastoreWithReadReplica(t *testing.T) {
+	b := testdatastore.RunMySQLForTesting(t, "")
+	dst := datastoreTester{b: b, t: t, readReplica: true}
+	test.All(t, test.DatastoreTesterFunc(dst.createDatastore))
+}
+
 func DatabaseSeedingTest(t *testing.T, ds datastore.Datastore) {
 	req := require.New(t)
 
@@ -380,6 +399,82 @@ func GarbageCollectionByTimeTest(t *testing.T, ds datastore.Datastore) {
 	tRequire.NoTupleExists(ctx, tpl, relDeletedAt)
 }
 
+func GarbageCollectionArchiveTest(t *testing.T, ds datastore.Datastore) {
+	req := require.New(t)
+
+	ctx := context.Background()
+	ok, err := ds.IsReady(ctx)
+	req.NoError(err)
+	req.True(ok)
+
+	mds := ds.(*Datastore)
+
+	// Write basic namespaces.
+	_, err = ds.ReadWriteTx(ctx, func(ctx context.Context, rwt datastore.ReadWriteTransaction) error {
+		return rwt.WriteNamespaces(
+			namespace.Namespace(
+				"resource",
+				namespace.Relation("reader", nil),
+			),
+			namespace.Namespace("user"),
+		)
+	})
+	req.NoError(err)
+
+	beforeWrite, err := mds.getNow(ctx)
+	req.NoError(err)
+
+	// Write a relationship, and then delete it.
+	const rel = "resource:someresource#reader@user:someuser"
+	relationship := tuple.ParseRel(rel)
+	for _, op := range []v1.RelationshipUpdate_Operation{
+		v1.RelationshipUpdate_OPERATION_CREATE,
+		v1.RelationshipUpdate_OPERATION_DELETE,
+	} {
+		_, err = ds.ReadWriteTx(ctx, func(ctx context.Context, rwt datastore.ReadWriteTransaction) error {
+			return rwt.WriteRelationships([]*v1.RelationshipUpdate{{
+				Operation:    op,
+				Relationship: relationship,
+			}})
+		})
+		req.NoError(err)
+	}
+
+	afterDelete, err := mds.getNow(ctx)
+	req.NoError(err)
+
+	// Run GC and ensure the relationship is archived.
+	relsDeleted, transactionsDeleted, err := mds.collectGarbageBefore(ctx, afterDelete)
+	req.NoError(err)
+	req.Equal(int64(1), relsDeleted)
+	req.True(transactionsDeleted > 0)
+
+	readAsOf := func(asOf time.Time) []string {
+		iter, err := mds.QueryRelationshipsAsOf(ctx, asOf, &v1.RelationshipFilter{ResourceType: "resource"})
+		req.NoError(err)
+		defer iter.Close()
+
+		var found []string
+		for tpl := iter.Next(); tpl != nil; tpl = iter.Next() {
+			found = append(found, tuple.String(tpl))
+		}
+		req.NoError(iter.Err())
+		return found
+	}
+
+	req.Empty(readAsOf(beforeWrite))
+	req.Empty(readAsOf(afterDelete))
+
+	// Find the time at which the relationship existed from its archived transactions.
+	var createdAt time.Time
+	req.NoError(mds.db.QueryRowContext(ctx, fmt.Sprintf(
+		"SELECT timestamp FROM %s WHERE id = (SELECT created_transaction FROM %s)",
+		mds.driver.RelationTupleTransactionHistory(),
+		mds.driver.RelationTupleHistory(),
+	)).Scan(&createdAt))
+	req.Equal([]string{rel}, readAsOf(createdAt))
+}
+
 func ChunkedGarbageCollectionTest(t *testing.T, ds datastore.Datastore) {
 	req := require.New(t)
 
@@ -0,0 +1,170 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+
+	"github.com/authzed/spicedb/internal/datastore/common"
+	"github.com/authzed/spicedb/internal/datastore/mysql/migrations"
+	"github.com/authzed/spicedb/pkg/datastore"
+)
+
+const errUnableToQueryHistory = "unable to query relationship history: %w"
+
+var (
+	tupleHistoryColumns = []string{
+		colID,
+		colNamespace,
+		colObjectID,
+		colRelation,
+		colUsersetNamespace,
+		colUsersetObjectID,
+		colUsersetRelation,
+		colCreatedTxn,
+		colDeletedTxn,
+	}
+
+	transactionHistoryColumns = []string{colID, colTimestamp}
+)
+
+// unionWithHistory returns a subquery of the rows of both the table and its history table.
+func unionWithHistory(tableName, historyTableName string, columns []string) string {
+	cols := strings.Join(columns, ", ")
+	return fmt.Sprintf("(SELECT %s FROM %s UNION ALL SELECT %s FROM %s) AS %s",
+		cols, tableName, cols, historyTableName, tableName)
+}
+
+// QueryRelationshipsAsOf reads the relationships matching the filter which existed at the
+// given time, from both the relation tuple table and the rows archived by garbage
+// collection. The history is complete from the oldest transaction which was archived, or
+// has not yet been garbage collected.
+func (mds *Datastore) QueryRelationshipsAsOf(
+	ctx context.Context,
+	asOf time.Time,
+	filter *v1.RelationshipFilter,
+) (datastore.RelationshipIterator, error) {
+	if !mds.gcArchiveHistory {
+		return nil, datastore.NewHistoryNotArchivedErr()
+	}
+
+	ctx, span := tracer.Start(ctx, "QueryRelationshipsAsOf")
+	defer span.End()
+
+	query, args, err := mds.GetHistoricalRevision.Where(sq.LtOrEq{colTimestamp: asOf.UTC()}).ToSql()
+	if err != nil {
+		return nil, fmt.Errorf(errUnableToQueryHistory, err)
+	}
+
+	var txnID sql.NullInt64
+	err = mds.db.QueryRowContext(datastore.SeparateContextWithTracing(ctx), query, args...).Scan(&txnID)
+	if err != nil {
+		return nil, fmt.Errorf(errUnableToQueryHistory, err)
+	}
+
+	// The datastore is seeded with a transaction when it is created, so there is only no
+	// transaction if the earliest were garbage collected before archiving was enabled.
+	if !txnID.Valid {
+		return nil, datastore.NewHistoryNotRecordedErr(asOf)
+	}
+
+	qBuilder := common.NewSchemaQueryFilterer(
+		schema,
+		buildLivingObjectFilterForRevision(revisionFromTransaction(uint64(txnID.Int64)))(mds.QueryHistoricalTuplesQuery),
+	).FilterToRelationshipFilter(filter)
+
+	querySplitter := common.TupleQuerySplitter{
+		Executor:         newMySQLExecutor(mds.db),
+		UsersetBatchSize: mds.usersetBatchSize,
+	}
+	return querySplitter.SplitAndExecuteQuery(ctx, qBuilder)
+}
+
+// batchRemove removes the rows matching the filter from the table, moving them into its
+// history table if history is archived, and deleting them otherwise.
+func (mds *Datastore) batchRemove(ctx context.Context, tableName, historyTableName string, columns []string, filter sqlFilter) (int64, error) {
+	if !mds.gcArchiveHistory {
+		return mds.batchDelete(ctx, tableName, filter)
+	}
+	return mds.batchArchive(ctx, tableName, historyTableName, columns, filter)
+}
+
+// batchArchive moves the rows matching the filter into the history table in batches. Each
+// batch is locked, copied and deleted in a single transaction. The IDs of each batch are
+// read before it is moved, as neither MySQL nor Vitess support LIMIT within the subquery
+// of an IN clause.
+func (mds *Datastore) batchArchive(ctx context.Context, tableName, historyTableName string, columns []string, filter sqlFilter) (int64, error) {
+	selectQuery, selectArgs, err := sb.Select(colID).From(tableName).Where(filter).
+		OrderBy(colID).Limit(batchDeleteSize).Suffix("FOR UPDATE").ToSql()
+	if err != nil {
+		return -1, err
+	}
+
+	cols := strings.Join(columns, ", ")
+
+	var archivedCount int64
+	for {
+		var rowsArchived int64
+		if err := BeginTxFunc(ctx, mds.db, nil, func(tx *sql.Tx) error {
+			ids, err := selectIDs(ctx, tx, selectQuery, selectArgs)
+			if err != nil || len(ids) == 0 {
+				return err
+			}
+
+			copyQuery, copyArgs, err := sb.Insert(historyTableName).Columns(columns...).
+				Select(sb.Select(cols).From(tableName).Where(sq.Eq{colID: ids})).ToSql()
+			if err != nil {
+				return err
+			}
+			if _, err := tx.ExecContext(ctx, copyQuery, copyArgs...); err != nil {
+				return err
+			}
+
+			deleteQuery, deleteArgs, err := sb.Delete(tableName).Where(sq.Eq{colID: ids}).ToSql()
+			if err != nil {
+				return err
+			}
+			cr, err := tx.ExecContext(ctx, deleteQuery, deleteArgs...)
+			if err != nil {
+				return err
+			}
+
+			rowsArchived, err = cr.RowsAffected()
+			return err
+		}); err != nil {
+			return archivedCount, err
+		}
+
+		archivedCount += rowsArchived
+		if rowsArchived < batchDeleteSize {
+			break
+		}
+	}
+
+	return archivedCount, nil
+}
+
+func selectIDs(ctx context.Context, tx *sql.Tx, query string, args []interface{}) ([]uint64, error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer migrations.LogOnError(ctx, rows.Close)
+
+	var ids []uint64
+	for rows.Next() {
+		var id uint64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+var _ datastore.HistoryReader = &Datastore{}
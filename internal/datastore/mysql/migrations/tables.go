@@ -3,11 +3,14 @@ package migrations
 import "fmt"
 
 const (
-	tableNamespaceDefault   = "namespace_config"
-	tableTransactionDefault = "relation_tuple_transaction"
-	tableTupleDefault       = "relation_tuple"
-	tableMigrationVersion   = "mysql_migration_version"
-	tableMetadataDefault    = "mysql_metadata"
+	tableNamespaceDefault    = "namespace_config"
+	tableTransactionDefault  = "relation_tuple_transaction"
+	tableTupleDefault        = "relation_tuple"
+	tableMigrationVersion    = "mysql_migration_version"
+	tableMetadataDefault     = "mysql_metadata"
+	tableCounterDefault      = "relationship_counter"
+	tableTupleHistoryDefault = "relation_tuple_history"
+	tableTxnHistoryDefault   = "relation_tuple_transaction_history"
 )
 
 type tables struct {
@@ -16,6 +19,9 @@ type tables struct {
 	tableTuple            string
 	tableNamespace        string
 	tableMetadata         string
+	tableCounter          string
+	tableTupleHistory     string
+	tableTxnHistory       string
 }
 
 func newTables(prefix string) *tables {
@@ -25,6 +31,9 @@ func newTables(prefix string) *tables {
 		tableTuple:            fmt.Sprintf("%s%s", prefix, tableTupleDefault),
 		tableNamespace:        fmt.Sprintf("%s%s", prefix, tableNamespaceDefault),
 		tableMetadata:         fmt.Sprintf("%s%s", prefix, tableMetadataDefault),
+		tableCounter:          fmt.Sprintf("%s%s", prefix, tableCounterDefault),
+		tableTupleHistory:     fmt.Sprintf("%s%s", prefix, tableTupleHistoryDefault),
+		tableTxnHistory:       fmt.Sprintf("%s%s", prefix, tableTxnHistoryDefault),
 	}
 }
 
@@ -50,3 +59,18 @@ func (tn *tables) Namespace() string {
 func (tn *tables) Metadata() string {
 	return tn.tableMetadata
 }
+
+// RelationshipCounter returns the prefixed relationship counter table name.
+func (tn *tables) RelationshipCounter() string {
+	return tn.tableCounter
+}
+
+// RelationTupleHistory returns the prefixed relationship tuple history table name.
+func (tn *tables) RelationTupleHistory() string {
+	return tn.tableTupleHistory
+}
+
+// RelationTupleTransactionHistory returns the prefixed transaction history table name.
+func (tn *tables) RelationTupleTransactionHistory() string {
+	return tn.tableTxnHistory
+}
@@ -0,0 +1,34 @@
+package migrations
+
+import "fmt"
+
+// The counter for each namespace is spread over several rows, so that concurrent
+// transactions which write relationships of the same namespace rarely update the same row.
+func createRelationshipCounter(driver *MySQLDriver) string {
+	return fmt.Sprintf(`CREATE TABLE %s (
+		namespace VARCHAR(128) NOT NULL,
+		shard SMALLINT NOT NULL,
+		count BIGINT NOT NULL,
+		PRIMARY KEY (namespace, shard)) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`,
+		driver.RelationshipCounter(),
+	)
+}
+
+func backfillRelationshipCounter(driver *MySQLDriver) string {
+	return fmt.Sprintf(`INSERT INTO %s (namespace, shard, count)
+		SELECT namespace, 0, COUNT(*) FROM %s
+		WHERE deleted_transaction = '9223372036854775807'
+		GROUP BY namespace;`,
+		driver.RelationshipCounter(),
+		driver.RelationTuple(),
+	)
+}
+
+func init() {
+	mustRegisterMigration("add_relationship_counters", "add_unique_datastore_id",
+		newExecutor(
+			createRelationshipCounter,
+			backfillRelationshipCounter,
+		).migrate,
+	)
+}
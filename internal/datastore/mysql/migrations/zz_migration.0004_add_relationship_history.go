@@ -0,0 +1,42 @@
+package migrations
+
+import "fmt"
+
+// The history tables hold the rows moved out of the relation tuple and transaction tables
+// by garbage collection when history is archived. Rows are only ever appended.
+func createRelationTupleHistory(driver *MySQLDriver) string {
+	return fmt.Sprintf(`CREATE TABLE %s (
+		id BIGINT UNSIGNED NOT NULL,
+		namespace VARCHAR(128) NOT NULL,
+		object_id VARCHAR(128) NOT NULL,
+		relation VARCHAR(64) NOT NULL,
+		userset_namespace VARCHAR(128) NOT NULL,
+		userset_object_id VARCHAR(128) NOT NULL,
+		userset_relation VARCHAR(64) NOT NULL,
+		created_transaction BIGINT NOT NULL,
+		deleted_transaction BIGINT NOT NULL,
+		PRIMARY KEY (id),
+		INDEX ix_relation_tuple_history_by_resource (namespace, object_id, relation),
+		INDEX ix_relation_tuple_history_by_subject (userset_object_id, userset_namespace, userset_relation)) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`,
+		driver.RelationTupleHistory(),
+	)
+}
+
+func createRelationTupleTransactionHistory(driver *MySQLDriver) string {
+	return fmt.Sprintf(`CREATE TABLE %s (
+		id BIGINT UNSIGNED NOT NULL,
+		timestamp DATETIME(6) NOT NULL,
+		PRIMARY KEY (id),
+		INDEX ix_relation_tuple_transaction_history_by_timestamp (timestamp)) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`,
+		driver.RelationTupleTransactionHistory(),
+	)
+}
+
+func init() {
+	mustRegisterMigration("add_relationship_history", "add_relationship_counters",
+		newExecutor(
+			createRelationTupleHistory,
+			createRelationTupleTransactionHistory,
+		).migrate,
+	)
+}
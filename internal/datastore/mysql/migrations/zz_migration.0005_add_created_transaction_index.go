@@ -0,0 +1,19 @@
+package migrations
+
+import "fmt"
+
+// The index is used to find the relationships created since a revision, when counting
+// relationships from the relationship counters.
+func createCreatedTransactionIndex(driver *MySQLDriver) string {
+	return fmt.Sprintf(`CREATE INDEX ix_relation_tuple_by_created_transaction ON %s (created_transaction);`,
+		driver.RelationTuple(),
+	)
+}
+
+func init() {
+	mustRegisterMigration("add_created_transaction_index", "add_relationship_history",
+		newExecutor(
+			createCreatedTransactionIndex,
+		).migrate,
+	)
+}
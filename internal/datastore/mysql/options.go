@@ -20,6 +20,7 @@ const (
 	defaultMaxRevisionStalenessPercent       = 0.1
 	defaultEnablePrometheusStats             = false
 	defaultMaxRetries                        = 8
+	defaultReadReplicaPollInterval           = time.Second
 )
 
 type mysqlOptions struct {
@@ -27,6 +28,7 @@ type mysqlOptions struct {
 	gcWindow                    time.Duration
 	gcInterval                  time.Duration
 	gcMaxOperationTime          time.Duration
+	gcArchiveHistory            bool
 	maxRevisionStalenessPercent float64
 	watchBufferLength           uint16
 	tablePrefix                 string
@@ -38,6 +40,8 @@ type mysqlOptions struct {
 	analyzeBeforeStats          bool
 	maxRetries                  uint8
 	lockWaitTimeoutSeconds      *uint8
+	readReplicaURIs             []string
+	readReplicaPollInterval     time.Duration
 }
 
 // Option provides the facility to configure how clients within the
@@ -58,6 +62,7 @@ func generateConfig(options []Option) (mysqlOptions, error) {
 		maxRevisionStalenessPercent: defaultMaxRevisionStalenessPercent,
 		enablePrometheusStats:       defaultEnablePrometheusStats,
 		maxRetries:                  defaultMaxRetries,
+		readReplicaPollInterval:     defaultReadReplicaPollInterval,
 	}
 
 	for _, option := range options {
@@ -126,6 +131,18 @@ func GCInterval(interval time.Duration) Option {
 	}
 }
 
+// GCArchiveHistory marks whether garbage collection moves the relationships and
+// transactions it removes into history tables, rather than deleting them, so that
+// the relationships of any time since archiving began can be read with
+// QueryRelationshipsAsOf. The history tables are never garbage collected.
+//
+// Archiving is disabled by default.
+func GCArchiveHistory(archive bool) Option {
+	return func(mo *mysqlOptions) {
+		mo.gcArchiveHistory = archive
+	}
+}
+
 // MaxRetries is the maximum number of times a retriable transaction will be
 // client-side retried.
 // Default: 10
@@ -207,3 +224,25 @@ func OverrideLockWaitTimeout(seconds uint8) Option {
 		po.lockWaitTimeoutSeconds = &seconds
 	}
 }
+
+// ReadReplicaURIs are the connection URIs of read replicas of the database.
+// Snapshot reads are routed to a replica which has replayed the revision being
+// read, falling back to the primary when no replica has; all other operations
+// always use the primary.
+//
+// This value defaults to having no read replicas.
+func ReadReplicaURIs(uris []string) Option {
+	return func(po *mysqlOptions) {
+		po.readReplicaURIs = uris
+	}
+}
+
+// ReadReplicaPollInterval is the interval at which the read replicas are polled
+// for the revision they have replayed and their replication lag.
+//
+// This value defaults to 1 second.
+func ReadReplicaPollInterval(interval time.Duration) Option {
+	return func(po *mysqlOptions) {
+		po.readReplicaPollInterval = interval
+	}
+}
@@ -1,6 +1,8 @@
 package mysql
 
 import (
+	"fmt"
+
 	"github.com/authzed/spicedb/internal/datastore/mysql/migrations"
 
 	sq "github.com/Masterminds/squirrel"
@@ -23,6 +25,14 @@ type QueryBuilder struct {
 	QueryTupleExistsQuery sq.SelectBuilder
 	WriteTupleQuery       sq.InsertBuilder
 	QueryChangedQuery     sq.SelectBuilder
+	CountTuplesQuery      sq.SelectBuilder
+
+	QueryRelationshipCountersQuery sq.SelectBuilder
+	QueryCountersTotalQuery        sq.SelectBuilder
+	UpsertRelationshipCounterQuery sq.InsertBuilder
+
+	GetHistoricalRevision      sq.SelectBuilder
+	QueryHistoricalTuplesQuery sq.SelectBuilder
 }
 
 // NewQueryBuilder returns a new QueryBuilder instance. The migration
@@ -47,6 +57,22 @@ func NewQueryBuilder(driver *migrations.MySQLDriver) *QueryBuilder {
 	builder.QueryTupleExistsQuery = queryTupleExists(driver.RelationTuple())
 	builder.WriteTupleQuery = writeTuple(driver.RelationTuple())
 	builder.QueryChangedQuery = queryChanged(driver.RelationTuple())
+	builder.CountTuplesQuery = countTuples(driver.RelationTuple())
+
+	// counter builders
+	builder.QueryRelationshipCountersQuery = queryRelationshipCounters(driver.RelationshipCounter())
+	builder.QueryCountersTotalQuery = queryCountersTotal(driver.RelationshipCounter())
+	builder.UpsertRelationshipCounterQuery = upsertRelationshipCounter(driver.RelationshipCounter())
+
+	// history builders
+	builder.GetHistoricalRevision = getHistoricalRevision(
+		driver.RelationTupleTransaction(),
+		driver.RelationTupleTransactionHistory(),
+	)
+	builder.QueryHistoricalTuplesQuery = queryHistoricalTuples(
+		driver.RelationTuple(),
+		driver.RelationTupleHistory(),
+	)
 
 	return &builder
 }
@@ -82,6 +108,7 @@ func deleteNamespaceTuples(tableTuple string) sq.UpdateBuilder {
 func queryTupleIds(tableTuple string) sq.SelectBuilder {
 	return sb.Select(
 		colID,
+		colNamespace,
 	).From(tableTuple)
 }
 
@@ -128,3 +155,38 @@ func queryChanged(tableTuple string) sq.SelectBuilder {
 		colDeletedTxn,
 	).From(tableTuple)
 }
+
+func countTuples(tableTuple string) sq.SelectBuilder {
+	return sb.Select("COUNT(*)").From(tableTuple)
+}
+
+func queryRelationshipCounters(tableCounter string) sq.SelectBuilder {
+	return sb.Select(colNamespace, fmt.Sprintf("SUM(%s)", colCount)).From(tableCounter).GroupBy(colNamespace)
+}
+
+func queryCountersTotal(tableCounter string) sq.SelectBuilder {
+	return sb.Select(fmt.Sprintf("COALESCE(SUM(%s), 0)", colCount)).From(tableCounter)
+}
+
+func upsertRelationshipCounter(tableCounter string) sq.InsertBuilder {
+	return sb.Insert(tableCounter).Columns(
+		colNamespace,
+		colShard,
+		colCount,
+	).Suffix(fmt.Sprintf("ON DUPLICATE KEY UPDATE %[1]s = %[1]s + VALUES(%[1]s)", colCount))
+}
+
+func getHistoricalRevision(tableTransaction, tableTransactionHistory string) sq.SelectBuilder {
+	return sb.Select("MAX(id)").From(unionWithHistory(tableTransaction, tableTransactionHistory, transactionHistoryColumns))
+}
+
+func queryHistoricalTuples(tableTuple, tableTupleHistory string) sq.SelectBuilder {
+	return sb.Select(
+		colNamespace,
+		colObjectID,
+		colRelation,
+		colUsersetNamespace,
+		colUsersetObjectID,
+		colUsersetRelation,
+	).From(unionWithHistory(tableTuple, tableTupleHistory, tupleHistoryColumns))
+}
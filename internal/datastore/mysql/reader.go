@@ -29,6 +29,11 @@ type mysqlReader struct {
 	txSource      txFactory
 	querySplitter common.TupleQuerySplitter
 	filterer      queryFilterer
+
+	// snapshotTxn is the transaction at which the reader reads, or zero for the reader of a
+	// read-write transaction, whose changes are not reflected in the relationship counters
+	// until it commits.
+	snapshotTxn uint64
 }
 
 type queryFilterer func(original sq.SelectBuilder) sq.SelectBuilder
@@ -37,6 +42,7 @@ const (
 	errUnableToReadConfig     = "unable to read namespace config: %w"
 	errUnableToListNamespaces = "unable to list namespaces: %w"
 	errUnableToQueryTuples    = "unable to query tuples: %w"
+	errUnableToCountTuples    = "unable to count tuples: %w"
 )
 
 // TODO (@vroldanbet) dupe from postgres datastore - need to refactor
@@ -208,4 +214,87 @@ func loadAllNamespaces(ctx context.Context, tx *sql.Tx, queryBuilder sq.SelectBu
 	return nsDefs, nil
 }
 
+// CountRelationships counts the relationships matching the filter. Those of a resource type
+// are counted from the relationship counters, and only narrower filters count the matching
+// relationships themselves.
+func (mr *mysqlReader) CountRelationships(ctx context.Context, filter *v1.RelationshipFilter) (uint64, error) {
+	ctx, span := tracer.Start(ctx, "CountRelationships")
+	defer span.End()
+
+	if mr.snapshotTxn != 0 && common.CountableFromCounters(filter) {
+		span.SetAttributes(attribute.String("resourceType", filter.ResourceType))
+		return mr.countFromCounters(ctx, filter.ResourceType)
+	}
+
+	qBuilder := common.NewSchemaQueryFilterer(schema, mr.filterer(mr.CountTuplesQuery)).
+		FilterToRelationshipFilter(filter)
+	span.SetAttributes(qBuilder.TracerAttributes()...)
+
+	query, args, err := qBuilder.ToSql()
+	if err != nil {
+		return 0, fmt.Errorf(errUnableToCountTuples, err)
+	}
+
+	tx, txCleanup, err := mr.txSource(ctx)
+	if err != nil {
+		return 0, fmt.Errorf(errUnableToCountTuples, err)
+	}
+	defer migrations.LogOnError(ctx, txCleanup)
+
+	var count uint64
+	if err := tx.QueryRowContext(ctx, query, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf(errUnableToCountTuples, err)
+	}
+
+	return count, nil
+}
+
+// countFromCounters counts the relationships of the resource type at the reader's revision.
+// The counters reflect the relationships currently living, so the relationships created and
+// deleted since the revision are counted, within the same transaction, to undo their changes.
+func (mr *mysqlReader) countFromCounters(ctx context.Context, resourceType string) (uint64, error) {
+	counters := mr.QueryCountersTotalQuery.Where(sq.Eq{colNamespace: resourceType})
+	createdSince := mr.CountTuplesQuery.
+		Where(sq.Eq{colNamespace: resourceType}).
+		Where(sq.Gt{colCreatedTxn: mr.snapshotTxn}).
+		Where(sq.Eq{colDeletedTxn: liveDeletedTxnID})
+	deletedSince := mr.CountTuplesQuery.
+		Where(sq.Eq{colNamespace: resourceType}).
+		Where(sq.LtOrEq{colCreatedTxn: mr.snapshotTxn}).
+		Where(sq.Gt{colDeletedTxn: mr.snapshotTxn}).
+		Where(sq.NotEq{colDeletedTxn: liveDeletedTxnID})
+
+	tx, txCleanup, err := mr.txSource(ctx)
+	if err != nil {
+		return 0, fmt.Errorf(errUnableToCountTuples, err)
+	}
+	defer migrations.LogOnError(ctx, txCleanup)
+
+	var total, created, deleted int64
+	for _, counted := range []struct {
+		query sq.SelectBuilder
+		count *int64
+	}{
+		{counters, &total},
+		{createdSince, &created},
+		{deletedSince, &deleted},
+	} {
+		query, args, err := counted.query.ToSql()
+		if err != nil {
+			return 0, fmt.Errorf(errUnableToCountTuples, err)
+		}
+
+		if err := tx.QueryRowContext(ctx, query, args...).Scan(counted.count); err != nil {
+			return 0, fmt.Errorf(errUnableToCountTuples, err)
+		}
+	}
+
+	// Writes made by versions which did not maintain the counters can leave them negative.
+	count := total - created + deleted
+	if count < 0 {
+		return 0, nil
+	}
+	return uint64(count), nil
+}
+
 var _ datastore.Reader = &mysqlReader{}
@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"math/rand"
 
 	sq "github.com/Masterminds/squirrel"
 	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
@@ -26,12 +27,17 @@ const (
 	errUnableToDeleteConfig        = "unable to delete namespace config: %w"
 )
 
+// bulkLoadBatchSize is the number of relationships created by each INSERT made by BulkLoad,
+// which keeps the number of placeholders in each statement under the limit of 65535.
+const bulkLoadBatchSize = 5000
+
 type mysqlReadWriteTXN struct {
 	*mysqlReader
 
-	ctx      context.Context
-	tx       *sql.Tx
-	newTxnID uint64
+	ctx             context.Context
+	tx              *sql.Tx
+	newTxnID        uint64
+	relCountChanges common.RelationshipCountChanges
 }
 
 // WriteRelationships takes a list of existing relationships that must exist, and a list of
@@ -59,6 +65,7 @@ func (rwt *mysqlReadWriteTXN) WriteRelationships(mutations []*v1.RelationshipUpd
 		}
 
 		if mut.Operation == v1.RelationshipUpdate_OPERATION_TOUCH || mut.Operation == v1.RelationshipUpdate_OPERATION_CREATE {
+			rwt.relCountChanges.Add(rel.Resource.ObjectType, 1)
 			bulkWrite = bulkWrite.Values(
 				rel.Resource.ObjectType,
 				rel.Resource.ObjectId,
@@ -85,13 +92,16 @@ func (rwt *mysqlReadWriteTXN) WriteRelationships(mutations []*v1.RelationshipUpd
 		defer migrations.LogOnError(ctx, rows.Close)
 
 		tupleIds := make([]int64, 0, len(clauses))
+		deletedCounts := make(common.RelationshipCountChanges)
 		for rows.Next() {
 			var tupleID int64
-			if err := rows.Scan(&tupleID); err != nil {
+			var objectType string
+			if err := rows.Scan(&tupleID, &objectType); err != nil {
 				return fmt.Errorf(errUnableToWriteRelationships, err)
 			}
 
 			tupleIds = append(tupleIds, tupleID)
+			deletedCounts.Add(objectType, 1)
 		}
 
 		if rows.Err() != nil {
@@ -110,6 +120,10 @@ func (rwt *mysqlReadWriteTXN) WriteRelationships(mutations []*v1.RelationshipUpd
 			if _, err := rwt.tx.ExecContext(ctx, query, args...); err != nil {
 				return fmt.Errorf(errUnableToWriteRelationships, err)
 			}
+
+			for objectType, deleted := range deletedCounts {
+				rwt.relCountChanges.Add(objectType, -deleted)
+			}
 		}
 	}
 
@@ -180,7 +194,12 @@ func (rwt *mysqlReadWriteTXN) DeleteRelationships(filter *v1.RelationshipFilter)
 		return fmt.Errorf(errUnableToDeleteRelationships, err)
 	}
 
-	if _, err := rwt.tx.ExecContext(ctx, querySQL, args...); err != nil {
+	result, err := rwt.tx.ExecContext(ctx, querySQL, args...)
+	if err != nil {
+		return fmt.Errorf(errUnableToDeleteRelationships, err)
+	}
+
+	if err := rwt.recordDeleted(filter.ResourceType, result); err != nil {
 		return fmt.Errorf(errUnableToDeleteRelationships, err)
 	}
 
@@ -279,12 +298,90 @@ func (rwt *mysqlReadWriteTXN) DeleteNamespace(nsName string) error {
 		return fmt.Errorf(errUnableToDeleteConfig, err)
 	}
 
-	_, err = rwt.tx.ExecContext(ctx, deleteTupleSQL, deleteTupleArgs...)
+	result, err := rwt.tx.ExecContext(ctx, deleteTupleSQL, deleteTupleArgs...)
 	if err != nil {
 		return fmt.Errorf(errUnableToDeleteConfig, err)
 	}
 
+	if err := rwt.recordDeleted(nsName, result); err != nil {
+		return fmt.Errorf(errUnableToDeleteConfig, err)
+	}
+
 	return nil
 }
 
-var _ datastore.ReadWriteTransaction = &mysqlReadWriteTXN{}
+// recordDeleted records the relationships of the object type deleted by the statement.
+func (rwt *mysqlReadWriteTXN) recordDeleted(objectType string, result sql.Result) error {
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	rwt.relCountChanges.Add(objectType, -deleted)
+	return nil
+}
+
+// updateCounters applies the changes in the number of relationships of each namespace to
+// a randomly chosen shard of its counter.
+func (rwt *mysqlReadWriteTXN) updateCounters(ctx context.Context) error {
+	objectTypes := rwt.relCountChanges.SortedObjectTypes()
+	if len(objectTypes) == 0 {
+		return nil
+	}
+
+	shard := rand.Intn(counterShards)
+
+	query := rwt.UpsertRelationshipCounterQuery
+	for _, objectType := range objectTypes {
+		query = query.Values(objectType, shard, rwt.relCountChanges[objectType])
+	}
+
+	upsertSQL, args, err := query.ToSql()
+	if err != nil {
+		return err
+	}
+
+	_, err = rwt.tx.ExecContext(ctx, upsertSQL, args...)
+	return err
+}
+
+// BulkLoad creates the relationships with multi-row INSERTs, without first checking for and
+// deleting existing relationships as WriteRelationships does.
+func (rwt *mysqlReadWriteTXN) BulkLoad(ctx context.Context, source datastore.BulkWriteRelationshipSource) (uint64, error) {
+	ctx, span := tracer.Start(datastore.SeparateContextWithTracing(ctx), "BulkLoad")
+	defer span.End()
+
+	loaded, err := datastore.BulkLoadInBatches(ctx, source, bulkLoadBatchSize, func(batch []*v1.Relationship) error {
+		bulkWrite := rwt.WriteTupleQuery
+		for _, rel := range batch {
+			rwt.relCountChanges.Add(rel.Resource.ObjectType, 1)
+			bulkWrite = bulkWrite.Values(
+				rel.Resource.ObjectType,
+				rel.Resource.ObjectId,
+				rel.Relation,
+				rel.Subject.Object.ObjectType,
+				rel.Subject.Object.ObjectId,
+				stringz.DefaultEmpty(rel.Subject.OptionalRelation, datastore.Ellipsis),
+				rwt.newTxnID,
+			)
+		}
+
+		query, args, err := bulkWrite.ToSql()
+		if err != nil {
+			return err
+		}
+
+		_, err = rwt.tx.ExecContext(ctx, query, args...)
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf(errUnableToWriteRelationships, err)
+	}
+
+	return loaded, nil
+}
+
+var (
+	_ datastore.ReadWriteTransaction = &mysqlReadWriteTXN{}
+	_ datastore.BulkLoader           = &mysqlReadWriteTXN{}
+)
@@ -0,0 +1,104 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/rs/zerolog/log"
+
+	"github.com/authzed/spicedb/internal/datastore/common"
+	"github.com/authzed/spicedb/pkg/datastore"
+)
+
+// queryReplicaLag returns the age, in microseconds, of the oldest transaction newer than
+// the one given, which is the amount of time a replica that has replayed the given
+// transaction is lagging behind the primary.
+//
+//   %[1] Name of timestamp column
+//   %[2] Relationship tuple transaction table
+//   %[3] Name of id column
+const queryReplicaLag = `SELECT COALESCE(TIMESTAMPDIFF(MICROSECOND, MIN(%[1]s), UTC_TIMESTAMP(6)), 0) FROM %[2]s WHERE %[3]s > ?;`
+
+// newConnector creates a connector to the database at the given URI, which sets the
+// session variables configured by the options of the datastore.
+func newConnector(uri string, config mysqlOptions) (driver.Connector, error) {
+	connector, err := mysql.MySQLDriver{}.OpenConnector(uri)
+	if err != nil {
+		return nil, fmt.Errorf("NewMySQLDatastore: failed to create connector: %w", err)
+	}
+
+	if config.lockWaitTimeoutSeconds != nil {
+		log.Info().Uint8("timeout", *config.lockWaitTimeoutSeconds).Msg("overriding innodb_lock_wait_timeout")
+		connector, err = addSessionVariables(connector, map[string]string{
+			"innodb_lock_wait_timeout": fmt.Sprintf("%d", *config.lockWaitTimeoutSeconds),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("NewMySQLDatastore: failed to add session variables to connector: %w", err)
+		}
+	}
+
+	return connector, nil
+}
+
+func configurePool(db *sql.DB, config mysqlOptions) {
+	db.SetConnMaxLifetime(config.connMaxLifetime)
+	db.SetConnMaxIdleTime(config.connMaxIdleTime)
+	db.SetMaxOpenConns(config.maxOpenConns)
+	db.SetMaxIdleConns(config.maxOpenConns)
+}
+
+// openReplicas opens a connection pool to each of the configured read replicas, along
+// with a router to choose between them. The router is not started.
+func openReplicas(config mysqlOptions) ([]*sql.DB, *common.ReplicaRouter, error) {
+	dbs := make([]*sql.DB, 0, len(config.readReplicaURIs))
+	names := make([]string, 0, len(config.readReplicaURIs))
+	for _, uri := range config.readReplicaURIs {
+		parsed, err := mysql.ParseDSN(uri)
+		if err != nil {
+			return nil, nil, fmt.Errorf("NewMySQLDatastore: invalid read replica URI: %w", err)
+		}
+
+		connector, err := newConnector(uri, config)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		// The connection metrics are registered along with the primary connector.
+		if config.enablePrometheusStats {
+			connector = &instrumentedConnector{
+				conn: connector,
+				drv:  connector.Driver(),
+			}
+		}
+
+		db := sql.OpenDB(connector)
+		configurePool(db, config)
+
+		dbs = append(dbs, db)
+		names = append(names, parsed.Addr)
+	}
+
+	return dbs, common.NewReplicaRouter(Engine, names), nil
+}
+
+// replicaStatus returns the latest transaction replayed by the replica, and how long ago
+// the first transaction it has yet to replay was committed on the primary.
+func (mds *Datastore) replicaStatus(ctx context.Context, replica int) (uint64, time.Duration, error) {
+	replayed, err := mds.loadRevisionFrom(ctx, mds.replicaDBs[replica])
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var lagMicros int64
+	if err := mds.db.QueryRowContext(
+		datastore.SeparateContextWithTracing(ctx), mds.replicaLagQuery, replayed,
+	).Scan(&lagMicros); err != nil {
+		return 0, 0, fmt.Errorf("unable to compute replica lag: %w", err)
+	}
+
+	return replayed, time.Duration(lagMicros) * time.Microsecond, nil
+}
@@ -108,6 +108,10 @@ func (mds *Datastore) CheckRevision(ctx context.Context, revision datastore.Revi
 }
 
 func (mds *Datastore) loadRevision(ctx context.Context) (uint64, error) {
+	return mds.loadRevisionFrom(ctx, mds.db)
+}
+
+func (mds *Datastore) loadRevisionFrom(ctx context.Context, db *sql.DB) (uint64, error) {
 	// TODO (@vroldanbet) dupe from postgres datastore - need to refactor
 	// slightly changed to support no revisions at all, needed for runtime seeding of first transaction
 	ctx, span := tracer.Start(ctx, "loadRevision")
@@ -119,7 +123,7 @@ func (mds *Datastore) loadRevision(ctx context.Context) (uint64, error) {
 	}
 
 	var revision *uint64
-	err = mds.db.QueryRowContext(datastore.SeparateContextWithTracing(ctx), query, args...).Scan(&revision)
+	err = db.QueryRowContext(datastore.SeparateContextWithTracing(ctx), query, args...).Scan(&revision)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return 0, nil
@@ -2,6 +2,7 @@ package mysql
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 
 	"github.com/authzed/spicedb/internal/datastore/mysql/migrations"
@@ -19,6 +20,10 @@ const (
 
 	metadataIDColumn       = "id"
 	metadataUniqueIDColumn = "unique_id"
+
+	// counterShards is the number of rows over which the counter for each namespace is
+	// spread.
+	counterShards = 16
 )
 
 func (mds *Datastore) Statistics(ctx context.Context) (datastore.Stats, error) {
@@ -61,9 +66,14 @@ func (mds *Datastore) Statistics(ctx context.Context) (datastore.Stats, error) {
 		return datastore.Stats{}, fmt.Errorf("unable to load namespaces: %w", err)
 	}
 
+	countsByType, err := mds.readRelationshipCounters(ctx, tx)
+	if err != nil {
+		return datastore.Stats{}, fmt.Errorf("unable to read relationship counters: %w", err)
+	}
+
 	return datastore.Stats{
 		UniqueID:                   uniqueID,
-		ObjectTypeStatistics:       datastore.ComputeObjectTypeStats(nsDefs),
+		ObjectTypeStatistics:       datastore.ComputeObjectTypeStats(nsDefs, countsByType),
 		EstimatedRelationshipCount: count,
 	}, nil
 }
@@ -81,3 +91,32 @@ func (mds *Datastore) getUniqueID(ctx context.Context) (string, error) {
 
 	return uniqueID, nil
 }
+
+func (mds *Datastore) readRelationshipCounters(ctx context.Context, tx *sql.Tx) (map[string]uint64, error) {
+	query, args, err := mds.QueryRelationshipCountersQuery.ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer migrations.LogOnError(ctx, rows.Close)
+
+	countsByType := make(map[string]uint64)
+	for rows.Next() {
+		var objectType string
+		var count int64
+		if err := rows.Scan(&objectType, &count); err != nil {
+			return nil, err
+		}
+
+		// Writes made by versions which did not maintain the counters can leave them negative.
+		if count > 0 {
+			countsByType[objectType] = uint64(count)
+		}
+	}
+
+	return countsByType, rows.Err()
+}
@@ -35,6 +35,7 @@ func (mds *Datastore) Watch(ctx context.Context, afterRevision datastore.Revisio
 		for {
 			var stagedUpdates []*datastore.RevisionChanges
 			var err error
+			previousTxn := currentTxn
 			stagedUpdates, currentTxn, err = mds.loadChanges(ctx, currentTxn)
 			if err != nil {
 				if errors.Is(ctx.Err(), context.Canceled) {
@@ -55,6 +56,10 @@ func (mds *Datastore) Watch(ctx context.Context, afterRevision datastore.Revisio
 				}
 			}
 
+			if currentTxn > previousTxn {
+				common.SendCheckpoint(ctx, updates, revisionFromTransaction(currentTxn))
+			}
+
 			// If there were no changes, sleep a bit
 			if len(stagedUpdates) == 0 {
 				sleep := time.NewTimer(watchSleep)
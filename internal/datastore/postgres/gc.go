@@ -96,7 +96,7 @@ func (pgd *pgDatastore) collectGarbageBefore(ctx context.Context, before time.Ti
 
 func (pgd *pgDatastore) collectGarbageForTransaction(ctx context.Context, highest uint64) (int64, int64, error) {
 	// Delete any relationship rows with deleted_transaction <= the transaction ID.
-	relCount, err := pgd.batchDelete(ctx, tableTuple, sq.LtOrEq{colDeletedTxn: highest})
+	relCount, err := pgd.batchRemove(ctx, tableTuple, tableTupleHistory, tupleHistoryColumns, sq.LtOrEq{colDeletedTxn: highest})
 	if err != nil {
 		return 0, 0, err
 	}
@@ -106,7 +106,7 @@ func (pgd *pgDatastore) collectGarbageForTransaction(ctx context.Context, highes
 
 	// Delete all transaction rows with ID < the transaction ID. We don't delete the transaction
 	// itself to ensure there is always at least one transaction present.
-	transactionCount, err := pgd.batchDelete(ctx, tableTransaction, sq.Lt{colID: highest})
+	transactionCount, err := pgd.batchRemove(ctx, tableTransaction, tableTransactionHistory, transactionHistoryColumns, sq.Lt{colID: highest})
 	if err != nil {
 		return relCount, 0, err
 	}
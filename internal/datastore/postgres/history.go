@@ -0,0 +1,167 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/jackc/pgtype"
+	"github.com/jackc/pgx/v4"
+	"github.com/rs/zerolog/log"
+
+	"github.com/authzed/spicedb/internal/datastore/common"
+	"github.com/authzed/spicedb/pkg/datastore"
+)
+
+const errUnableToQueryHistory = "unable to query relationship history: %w"
+
+var (
+	tupleHistoryColumns = []string{
+		colID,
+		colNamespace,
+		colObjectID,
+		colRelation,
+		colUsersetNamespace,
+		colUsersetObjectID,
+		colUsersetRelation,
+		colCreatedTxn,
+		colDeletedTxn,
+	}
+
+	transactionHistoryColumns = []string{colID, colTimestamp}
+
+	getHistoricalTransaction = psql.Select("MAX(id)").From(
+		unionWithHistory(tableTransaction, tableTransactionHistory, transactionHistoryColumns),
+	)
+
+	queryHistoricalTuples = psql.Select(
+		colNamespace,
+		colObjectID,
+		colRelation,
+		colUsersetNamespace,
+		colUsersetObjectID,
+		colUsersetRelation,
+	).From(unionWithHistory(tableTuple, tableTupleHistory, tupleHistoryColumns))
+)
+
+// unionWithHistory returns a subquery of the rows of both the table and its history table.
+func unionWithHistory(tableName, historyTableName string, columns []string) string {
+	cols := strings.Join(columns, ", ")
+	return fmt.Sprintf("(SELECT %s FROM %s UNION ALL SELECT %s FROM %s) AS %s",
+		cols, tableName, cols, historyTableName, tableName)
+}
+
+// QueryRelationshipsAsOf reads the relationships matching the filter which existed at the
+// given time, from both the relation tuple table and the rows archived by garbage
+// collection. The history is complete from the oldest transaction which was archived, or
+// has not yet been garbage collected.
+func (pgd *pgDatastore) QueryRelationshipsAsOf(
+	ctx context.Context,
+	asOf time.Time,
+	filter *v1.RelationshipFilter,
+) (datastore.RelationshipIterator, error) {
+	if !pgd.gcArchiveHistory {
+		return nil, datastore.NewHistoryNotArchivedErr()
+	}
+
+	ctx, span := tracer.Start(ctx, "QueryRelationshipsAsOf")
+	defer span.End()
+
+	// RelationTupleTransaction is not timezone aware, so the time is compared in UTC.
+	sql, args, err := getHistoricalTransaction.Where(sq.LtOrEq{colTimestamp: asOf.UTC()}).ToSql()
+	if err != nil {
+		return nil, fmt.Errorf(errUnableToQueryHistory, err)
+	}
+
+	value := pgtype.Int8{}
+	err = pgd.dbpool.QueryRow(datastore.SeparateContextWithTracing(ctx), sql, args...).Scan(&value)
+	if err != nil {
+		return nil, fmt.Errorf(errUnableToQueryHistory, err)
+	}
+
+	// The initial migration creates a transaction at the epoch, so there is only no
+	// transaction if the earliest were garbage collected before archiving was enabled.
+	if value.Status != pgtype.Present {
+		return nil, datastore.NewHistoryNotRecordedErr(asOf)
+	}
+
+	var txnID uint64
+	if err := value.AssignTo(&txnID); err != nil {
+		return nil, fmt.Errorf(errUnableToQueryHistory, err)
+	}
+
+	createTxFunc := func(ctx context.Context) (pgx.Tx, common.TxCleanupFunc, error) {
+		tx, err := pgd.dbpool.BeginTx(ctx, pgd.readTxOptions)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		cleanup := func(ctx context.Context) {
+			if err := tx.Rollback(ctx); err != nil {
+				log.Ctx(ctx).Err(err).Msg("error running transaction cleanup function")
+			}
+		}
+
+		return tx, cleanup, nil
+	}
+
+	querySplitter := common.TupleQuerySplitter{
+		Executor:         common.NewPGXExecutor(createTxFunc),
+		UsersetBatchSize: pgd.usersetBatchSize,
+	}
+
+	qBuilder := common.NewSchemaQueryFilterer(
+		schema,
+		buildLivingObjectFilterForRevision(revisionFromTransaction(txnID))(queryHistoricalTuples),
+	).FilterToRelationshipFilter(filter)
+
+	return querySplitter.SplitAndExecuteQuery(ctx, qBuilder)
+}
+
+// batchRemove removes the rows matching the filter from the table, moving them into its
+// history table if history is archived, and deleting them otherwise.
+func (pgd *pgDatastore) batchRemove(ctx context.Context, tableName, historyTableName string, columns []string, filter sqlFilter) (int64, error) {
+	if !pgd.gcArchiveHistory {
+		return pgd.batchDelete(ctx, tableName, filter)
+	}
+	return pgd.batchArchive(ctx, tableName, historyTableName, columns, filter)
+}
+
+// batchArchive moves the rows matching the filter into the history table in batches, each
+// deleting the rows and inserting them into the history table in a single statement.
+func (pgd *pgDatastore) batchArchive(ctx context.Context, tableName, historyTableName string, columns []string, filter sqlFilter) (int64, error) {
+	sql, args, err := psql.Select("id").From(tableName).Where(filter).Limit(batchDeleteSize).ToSql()
+	if err != nil {
+		return -1, err
+	}
+
+	cols := strings.Join(columns, ", ")
+	query := fmt.Sprintf(`WITH rows AS (
+		  DELETE FROM %s
+		  WHERE id IN (%s)
+		  RETURNING %s
+		)
+		INSERT INTO %s (%s) SELECT %s FROM rows;
+	`, tableName, sql, cols, historyTableName, cols, cols)
+
+	var archivedCount int64
+	for {
+		cr, err := pgd.dbpool.Exec(ctx, query, args...)
+		if err != nil {
+			return archivedCount, err
+		}
+
+		rowsArchived := cr.RowsAffected()
+		archivedCount += rowsArchived
+		if rowsArchived < batchDeleteSize {
+			break
+		}
+	}
+
+	return archivedCount, nil
+}
+
+var _ datastore.HistoryReader = &pgDatastore{}
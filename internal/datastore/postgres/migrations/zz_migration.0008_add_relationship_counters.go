@@ -0,0 +1,41 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// The counter for each namespace is spread over several rows, so that concurrent
+// transactions which write relationships of the same namespace rarely update the same row.
+const createRelationshipCounterTable = `CREATE TABLE relationship_counter (
+	namespace VARCHAR NOT NULL,
+	shard SMALLINT NOT NULL,
+	count BIGINT NOT NULL,
+	CONSTRAINT pk_relationship_counter PRIMARY KEY (namespace, shard)
+);`
+
+const backfillRelationshipCounters = `INSERT INTO relationship_counter (namespace, shard, count)
+	SELECT namespace, 0, COUNT(*) FROM relation_tuple
+	WHERE deleted_transaction = '9223372036854775807'
+	GROUP BY namespace;`
+
+func init() {
+	if err := DatabaseMigrations.Register("add-relationship-counters", "add-unique-datastore-id", func(apd *AlembicPostgresDriver) error {
+		ctx := context.Background()
+
+		return apd.db.BeginFunc(ctx, func(tx pgx.Tx) error {
+			if _, err := tx.Exec(ctx, createRelationshipCounterTable); err != nil {
+				return err
+			}
+
+			if _, err := tx.Exec(ctx, backfillRelationshipCounters); err != nil {
+				return err
+			}
+
+			return nil
+		})
+	}); err != nil {
+		panic("failed to register migration: " + err.Error())
+	}
+}
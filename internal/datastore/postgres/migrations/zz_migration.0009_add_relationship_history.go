@@ -0,0 +1,61 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// The history tables hold the rows moved out of relation_tuple and relation_tuple_transaction
+// by garbage collection when history is archived. Rows are only ever appended.
+const createRelationTupleHistoryTable = `CREATE TABLE relation_tuple_history (
+	id BIGINT NOT NULL,
+	namespace VARCHAR NOT NULL,
+	object_id VARCHAR NOT NULL,
+	relation VARCHAR NOT NULL,
+	userset_namespace VARCHAR NOT NULL,
+	userset_object_id VARCHAR NOT NULL,
+	userset_relation VARCHAR NOT NULL,
+	created_transaction BIGINT NOT NULL,
+	deleted_transaction BIGINT NOT NULL,
+	CONSTRAINT pk_relation_tuple_history PRIMARY KEY (id)
+);`
+
+const createTupleHistoryResourceIndex = `CREATE INDEX ix_relation_tuple_history_by_resource
+	ON relation_tuple_history (namespace, object_id, relation);`
+
+const createTupleHistorySubjectIndex = `CREATE INDEX ix_relation_tuple_history_by_subject
+	ON relation_tuple_history (userset_object_id, userset_namespace, userset_relation);`
+
+const createTransactionHistoryTable = `CREATE TABLE relation_tuple_transaction_history (
+	id BIGINT NOT NULL,
+	timestamp TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+	CONSTRAINT pk_rttx_history PRIMARY KEY (id)
+);`
+
+const createTransactionHistoryTimestampIndex = `CREATE INDEX ix_relation_tuple_transaction_history_by_timestamp
+	ON relation_tuple_transaction_history (timestamp);`
+
+func init() {
+	if err := DatabaseMigrations.Register("add-relationship-history", "add-relationship-counters", func(apd *AlembicPostgresDriver) error {
+		ctx := context.Background()
+
+		statements := []string{
+			createRelationTupleHistoryTable,
+			createTupleHistoryResourceIndex,
+			createTupleHistorySubjectIndex,
+			createTransactionHistoryTable,
+			createTransactionHistoryTimestampIndex,
+		}
+		return apd.db.BeginFunc(ctx, func(tx pgx.Tx) error {
+			for _, stmt := range statements {
+				if _, err := tx.Exec(ctx, stmt); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}); err != nil {
+		panic("failed to register migration: " + err.Error())
+	}
+}
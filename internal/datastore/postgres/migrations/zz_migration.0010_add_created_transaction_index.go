@@ -0,0 +1,16 @@
+package migrations
+
+import "context"
+
+const (
+	createCreatedTransactionIndex = `CREATE INDEX CONCURRENTLY ix_relation_tuple_by_created_transaction ON relation_tuple (created_transaction)`
+)
+
+func init() {
+	if err := DatabaseMigrations.Register("add-created-transaction-index", "add-relationship-history", func(apd *AlembicPostgresDriver) error {
+		_, err := apd.db.Exec(context.Background(), createCreatedTransactionIndex)
+		return err
+	}); err != nil {
+		panic("failed to register migration: " + err.Error())
+	}
+}
@@ -0,0 +1,150 @@
+package postgres
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// watchChannel is the channel on which the ID of each committed read/write
+	// transaction is sent.
+	watchChannel = "spicedb_watch"
+
+	notifyCommitted = "SELECT pg_notify($1, $2)"
+	listenCommitted = "LISTEN " + watchChannel
+
+	listenRetryDelay = 1 * time.Second
+)
+
+// notifyCommit sends a notification for the transaction, which is delivered to listeners
+// only once the transaction has committed.
+func notifyCommit(ctx context.Context, tx pgx.Tx, txnID uint64) error {
+	_, err := tx.Exec(ctx, notifyCommitted, watchChannel, strconv.FormatUint(txnID, 10))
+	return err
+}
+
+// changeNotifier listens for committed transactions on a single connection, on behalf of
+// all of the watchers of the datastore. The connection is established when the first
+// watcher subscribes, and is re-established if it is lost.
+type changeNotifier struct {
+	sync.Mutex
+
+	connConfig  *pgx.ConnConfig
+	subscribers map[chan struct{}]struct{}
+	listening   uint32
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func newChangeNotifier(connConfig *pgx.ConnConfig) *changeNotifier {
+	return &changeNotifier{
+		connConfig:  connConfig,
+		subscribers: make(map[chan struct{}]struct{}),
+	}
+}
+
+// subscribe returns a channel which receives a value whenever a transaction may have
+// been committed, along with a function to unsubscribe. Notifications are coalesced, so
+// a subscriber which is busy receives only a single notification once it is done.
+func (cn *changeNotifier) subscribe() (<-chan struct{}, func()) {
+	cn.Lock()
+	defer cn.Unlock()
+
+	if cn.done == nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		cn.cancel = cancel
+		cn.done = make(chan struct{})
+		go cn.run(ctx)
+	}
+
+	notifications := make(chan struct{}, 1)
+	cn.subscribers[notifications] = struct{}{}
+
+	return notifications, func() {
+		cn.Lock()
+		defer cn.Unlock()
+		delete(cn.subscribers, notifications)
+	}
+}
+
+// isListening returns whether notifications are currently being received.
+func (cn *changeNotifier) isListening() bool {
+	return atomic.LoadUint32(&cn.listening) == 1
+}
+
+// close stops listening for notifications, waiting for the connection to be closed.
+func (cn *changeNotifier) close() {
+	cn.Lock()
+	cancel, done := cn.cancel, cn.done
+	cn.Unlock()
+
+	if cancel == nil {
+		return
+	}
+
+	cancel()
+	<-done
+}
+
+func (cn *changeNotifier) run(ctx context.Context) {
+	defer close(cn.done)
+
+	for {
+		err := cn.listen(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+
+		log.Warn().Err(err).Msg("lost connection listening for postgres watch notifications; falling back to polling")
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(listenRetryDelay):
+		}
+	}
+}
+
+func (cn *changeNotifier) listen(ctx context.Context) error {
+	conn, err := pgx.ConnectConfig(ctx, cn.connConfig.Copy())
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, listenCommitted); err != nil {
+		return err
+	}
+
+	atomic.StoreUint32(&cn.listening, 1)
+	defer atomic.StoreUint32(&cn.listening, 0)
+
+	// Transactions may have been committed while no connection was listening.
+	cn.broadcast()
+
+	for {
+		if _, err := conn.WaitForNotification(ctx); err != nil {
+			return err
+		}
+		cn.broadcast()
+	}
+}
+
+func (cn *changeNotifier) broadcast() {
+	cn.Lock()
+	defer cn.Unlock()
+
+	for notifications := range cn.subscribers {
+		select {
+		case notifications <- struct{}{}:
+		default:
+		}
+	}
+}
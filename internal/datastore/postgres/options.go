@@ -18,9 +18,13 @@ type postgresOptions struct {
 	gcWindow             time.Duration
 	gcInterval           time.Duration
 	gcMaxOperationTime   time.Duration
+	gcArchiveHistory     bool
 	splitAtUsersetCount  uint16
 	maxRetries           uint8
 
+	readReplicaURIs         []string
+	readReplicaPollInterval time.Duration
+
 	enablePrometheusStats   bool
 	analyzeBeforeStatistics bool
 
@@ -39,6 +43,7 @@ const (
 	defaultMaxRevisionStalenessPercent       = 0.1
 	defaultEnablePrometheusStats             = false
 	defaultMaxRetries                        = 10
+	defaultReadReplicaPollInterval           = time.Second
 )
 
 // Option provides the facility to configure how clients within the
@@ -56,6 +61,7 @@ func generateConfig(options []Option) (postgresOptions, error) {
 		maxRevisionStalenessPercent: defaultMaxRevisionStalenessPercent,
 		enablePrometheusStats:       defaultEnablePrometheusStats,
 		maxRetries:                  defaultMaxRetries,
+		readReplicaPollInterval:     defaultReadReplicaPollInterval,
 	}
 
 	for _, option := range options {
@@ -192,6 +198,18 @@ func GCMaxOperationTime(time time.Duration) Option {
 	}
 }
 
+// GCArchiveHistory marks whether garbage collection moves the relationships and
+// transactions it removes into history tables, rather than deleting them, so that
+// the relationships of any time since archiving began can be read with
+// QueryRelationshipsAsOf. The history tables are never garbage collected.
+//
+// Archiving is disabled by default.
+func GCArchiveHistory(archive bool) Option {
+	return func(po *postgresOptions) {
+		po.gcArchiveHistory = archive
+	}
+}
+
 // MaxRetries is the maximum number of times a retriable transaction will be
 // client-side retried.
 // Default: 10
@@ -201,6 +219,28 @@ func MaxRetries(maxRetries uint8) Option {
 	}
 }
 
+// ReadReplicaURIs are the connection URIs of read replicas of the database.
+// Snapshot reads are routed to a replica which has replayed the revision being
+// read, falling back to the primary when no replica has; all other operations
+// always use the primary.
+//
+// This value defaults to having no read replicas.
+func ReadReplicaURIs(uris []string) Option {
+	return func(po *postgresOptions) {
+		po.readReplicaURIs = uris
+	}
+}
+
+// ReadReplicaPollInterval is the interval at which the read replicas are polled
+// for the revision they have replayed and their replication lag.
+//
+// This value defaults to 1 second.
+func ReadReplicaPollInterval(interval time.Duration) Option {
+	return func(po *postgresOptions) {
+		po.readReplicaPollInterval = interval
+	}
+}
+
 // WithEnablePrometheusStats marks whether Prometheus metrics provided by the Postgres
 // clients being used by the datastore are enabled.
 //
@@ -12,7 +12,6 @@ import (
 	sq "github.com/Masterminds/squirrel"
 	"github.com/jackc/pgconn"
 	"github.com/jackc/pgx/v4"
-	"github.com/jackc/pgx/v4/log/zerologadapter"
 	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/jackc/pgx/v4/stdlib"
 	"github.com/ngrok/sqlmw"
@@ -36,6 +35,9 @@ const (
 	tableTransaction = "relation_tuple_transaction"
 	tableTuple       = "relation_tuple"
 
+	tableTupleHistory       = "relation_tuple_history"
+	tableTransactionHistory = "relation_tuple_transaction_history"
+
 	colID               = "id"
 	colTimestamp        = "timestamp"
 	colNamespace        = "namespace"
@@ -118,31 +120,7 @@ func NewPostgresDatastore(
 		return nil, fmt.Errorf(errUnableToInstantiate, err)
 	}
 
-	// config must be initialized by ParseConfig
-	pgxConfig, err := pgxpool.ParseConfig(url)
-	if err != nil {
-		return nil, fmt.Errorf(errUnableToInstantiate, err)
-	}
-
-	if config.maxOpenConns != nil {
-		pgxConfig.MaxConns = int32(*config.maxOpenConns)
-	}
-	if config.minOpenConns != nil {
-		pgxConfig.MinConns = int32(*config.minOpenConns)
-	}
-	if config.connMaxIdleTime != nil {
-		pgxConfig.MaxConnIdleTime = *config.connMaxIdleTime
-	}
-	if config.connMaxLifetime != nil {
-		pgxConfig.MaxConnLifetime = *config.connMaxLifetime
-	}
-	if config.healthCheckPeriod != nil {
-		pgxConfig.HealthCheckPeriod = *config.healthCheckPeriod
-	}
-
-	pgxConfig.ConnConfig.Logger = zerologadapter.NewLogger(log.Logger)
-
-	dbpool, err := pgxpool.ConnectConfig(context.Background(), pgxConfig)
+	dbpool, err := connectPool(url, config)
 	if err != nil {
 		return nil, fmt.Errorf(errUnableToInstantiate, err)
 	}
@@ -165,6 +143,18 @@ func NewPostgresDatastore(
 		if err != nil {
 			return nil, fmt.Errorf(errUnableToInstantiate, err)
 		}
+		if len(config.readReplicaURIs) > 0 {
+			err = common.RegisterReplicaMetrics()
+			if err != nil {
+				return nil, fmt.Errorf(errUnableToInstantiate, err)
+			}
+		}
+	}
+
+	replicaPools, replicaRouter, err := connectReplicas(config)
+	if err != nil {
+		dbpool.Close()
+		return nil, fmt.Errorf(errUnableToInstantiate, err)
 	}
 
 	gcCtx, cancelGc := context.WithCancel(context.Background())
@@ -204,16 +194,24 @@ func NewPostgresDatastore(
 		gcWindowInverted:        -1 * config.gcWindow,
 		gcInterval:              config.gcInterval,
 		gcMaxOperationTime:      config.gcMaxOperationTime,
+		gcArchiveHistory:        config.gcArchiveHistory,
 		analyzeBeforeStatistics: config.analyzeBeforeStatistics,
 		usersetBatchSize:        config.splitAtUsersetCount,
 		gcCtx:                   gcCtx,
 		cancelGc:                cancelGc,
 		readTxOptions:           pgx.TxOptions{IsoLevel: pgx.Serializable, AccessMode: pgx.ReadOnly},
 		maxRetries:              config.maxRetries,
+		replicaPools:            replicaPools,
+		replicaRouter:           replicaRouter,
+		changeNotifier:          newChangeNotifier(dbpool.Config().ConnConfig),
 	}
 
 	datastore.SetOptimizedRevisionFunc(datastore.optimizedRevisionFunc)
 
+	if len(replicaPools) > 0 {
+		replicaRouter.Start(config.readReplicaPollInterval, datastore.replicaStatus)
+	}
+
 	// Start a goroutine for garbage collection.
 	if datastore.gcInterval > 0*time.Minute {
 		datastore.gcGroup, datastore.gcCtx = errgroup.WithContext(datastore.gcCtx)
@@ -236,10 +234,14 @@ type pgDatastore struct {
 	gcWindowInverted        time.Duration
 	gcInterval              time.Duration
 	gcMaxOperationTime      time.Duration
+	gcArchiveHistory        bool
 	usersetBatchSize        uint16
 	analyzeBeforeStatistics bool
 	readTxOptions           pgx.TxOptions
 	maxRetries              uint8
+	replicaPools            []*pgxpool.Pool
+	replicaRouter           *common.ReplicaRouter
+	changeNotifier          *changeNotifier
 
 	gcGroup  *errgroup.Group
 	gcCtx    context.Context
@@ -247,8 +249,13 @@ type pgDatastore struct {
 }
 
 func (pgd *pgDatastore) SnapshotReader(rev datastore.Revision) datastore.Reader {
+	pool, txOptions := pgd.dbpool, pgd.readTxOptions
+	if replica, ok := pgd.replicaRouter.ReplicaFor(transactionFromRevision(rev)); ok {
+		pool, txOptions = pgd.replicaPools[replica], replicaReadTxOptions
+	}
+
 	createTxFunc := func(ctx context.Context) (pgx.Tx, common.TxCleanupFunc, error) {
-		tx, err := pgd.dbpool.BeginTx(ctx, pgd.readTxOptions)
+		tx, err := pool.BeginTx(ctx, txOptions)
 		if err != nil {
 			return nil, nil, err
 		}
@@ -271,6 +278,7 @@ func (pgd *pgDatastore) SnapshotReader(rev datastore.Revision) datastore.Reader
 		createTxFunc,
 		querySplitter,
 		buildLivingObjectFilterForRevision(rev),
+		transactionFromRevision(rev),
 	}
 }
 
@@ -306,13 +314,23 @@ func (pgd *pgDatastore) ReadWriteTx(
 					longLivedTx,
 					querySplitter,
 					currentlyLivingObjects,
+					0,
 				},
 				ctx,
 				tx,
 				newTxnID,
+				make(common.RelationshipCountChanges),
 			}
 
-			return fn(ctx, rwt)
+			if err := fn(ctx, rwt); err != nil {
+				return err
+			}
+
+			if err := updateCounters(ctx, tx, rwt.relCountChanges); err != nil {
+				return fmt.Errorf("error updating relationship counters: %w", err)
+			}
+
+			return notifyCommit(ctx, tx, newTxnID)
 		})
 		if err != nil {
 			if errorRetryable(err) {
@@ -333,6 +351,12 @@ func (pgd *pgDatastore) Close() error {
 		log.Warn().Err(err).Msg("completed shutdown of postgres datastore")
 	}
 
+	pgd.changeNotifier.close()
+	pgd.replicaRouter.Stop()
+	for _, pool := range pgd.replicaPools {
+		pool.Close()
+	}
+
 	pgd.dbpool.Close()
 	return nil
 }
@@ -61,6 +61,27 @@ func TestPostgresDatastore(t *testing.T) {
 		}))
 	})
 
+	t.Run("WithReadReplica", func(t *testing.T) {
+		// Use the primary as its own replica, to ensure snapshot reads routed to a
+		// replica observe the same data as those served by the primary.
+		test.All(t, test.DatastoreTesterFunc(func(revisionQuantization, gcWindow time.Duration, watchBufferLength uint16) (datastore.Datastore, error) {
+			ds := b.NewDatastore(t, func(engine, uri string) datastore.Datastore {
+				ds, err := NewPostgresDatastore(uri,
+					RevisionQuantization(revisionQuantization),
+					GCWindow(gcWindow),
+					WatchBufferLength(watchBufferLength),
+					DebugAnalyzeBeforeStatistics(),
+					ReadReplicaURIs([]string{uri}),
+					ReadReplicaPollInterval(1*time.Millisecond),
+				)
+				require.NoError(t, err)
+				return ds
+			})
+
+			return ds, nil
+		}))
+	})
+
 	t.Run("GarbageCollection", createDatastoreTest(
 		b,
 		GarbageCollectionTest,
@@ -93,6 +114,23 @@ func TestPostgresDatastore(t *testing.T) {
 		WatchBufferLength(1),
 	))
 
+	t.Run("GarbageCollectionArchive", createDatastoreTest(
+		b,
+		GarbageCollectionArchiveTest,
+		RevisionQuantization(0),
+		GCWindow(1*time.Millisecond),
+		WatchBufferLength(1),
+		GCArchiveHistory(true),
+	))
+
+	t.Run("WatchNotifications", createDatastoreTest(
+		b,
+		WatchNotificationsTest,
+		RevisionQuantization(0),
+		GCWindow(1*time.Millisecond),
+		WatchBufferLength(1),
+	))
+
 	t.Run("QuantizedRevisions", func(t *testing.T) {
 		QuantizedRevisionTest(t, b)
 	})
@@ -248,6 +286,51 @@ func GarbageCollectionTest(t *testing.T, ds datastore.Datastore) {
 	tRequire.TupleExists(ctx, tpl, relLastWriteAt)
 }
 
+func WatchNotificationsTest(t *testing.T, ds datastore.Datastore) {
+	require := require.New(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ok, err := ds.IsReady(ctx)
+	require.NoError(err)
+	require.True(ok)
+
+	startRevision, err := ds.ReadWriteTx(ctx, func(ctx context.Context, rwt datastore.ReadWriteTransaction) error {
+		return rwt.WriteNamespaces(namespace.Namespace(
+			"resource",
+			namespace.Relation("reader", nil),
+		), namespace.Namespace("user"))
+	})
+	require.NoError(err)
+
+	// Start two watchers, which share a single listener.
+	firstChanges, _ := ds.Watch(ctx, startRevision)
+	secondChanges, _ := ds.Watch(ctx, startRevision)
+
+	pgd := ds.(*pgDatastore)
+	require.Eventually(pgd.changeNotifier.isListening, 5*time.Second, 10*time.Millisecond)
+
+	tpl := tuple.MustParse("resource:foo#reader@user:tom")
+	writtenAt, err := ds.ReadWriteTx(ctx, func(ctx context.Context, rwt datastore.ReadWriteTransaction) error {
+		return rwt.WriteRelationships([]*v1.RelationshipUpdate{{
+			Operation:    v1.RelationshipUpdate_OPERATION_CREATE,
+			Relationship: tuple.MustToRelationship(tpl),
+		}})
+	})
+	require.NoError(err)
+
+	// Both watchers must be woken by the notification, well before they would next poll.
+	for _, changes := range []<-chan *datastore.RevisionChanges{firstChanges, secondChanges} {
+		select {
+		case change := <-changes:
+			require.True(writtenAt.Equal(change.Revision))
+		case <-time.After(watchFallbackSleep / 2):
+			require.FailNow("timed out waiting for notified change")
+		}
+	}
+}
+
 func TransactionTimestampsTest(t *testing.T, ds datastore.Datastore) {
 	require := require.New(t)
 
@@ -371,6 +454,76 @@ func GarbageCollectionByTimeTest(t *testing.T, ds datastore.Datastore) {
 	tRequire.NoTupleExists(ctx, tpl, relDeletedAt)
 }
 
+func GarbageCollectionArchiveTest(t *testing.T, ds datastore.Datastore) {
+	require := require.New(t)
+
+	ctx := context.Background()
+	ok, err := ds.IsReady(ctx)
+	require.NoError(err)
+	require.True(ok)
+
+	pds := ds.(*pgDatastore)
+
+	// Write basic namespaces.
+	_, err = ds.ReadWriteTx(ctx, func(ctx context.Context, rwt datastore.ReadWriteTransaction) error {
+		return rwt.WriteNamespaces(namespace.Namespace(
+			"resource",
+			namespace.Relation("reader", nil),
+		), namespace.Namespace("user"))
+	})
+	require.NoError(err)
+
+	beforeWrite, err := pds.getNow(ctx)
+	require.NoError(err)
+
+	// Write a relationship, and then delete it.
+	const rel = "resource:someresource#reader@user:someuser"
+	relationship := tuple.ParseRel(rel)
+	for _, op := range []v1.RelationshipUpdate_Operation{
+		v1.RelationshipUpdate_OPERATION_CREATE,
+		v1.RelationshipUpdate_OPERATION_DELETE,
+	} {
+		_, err = ds.ReadWriteTx(ctx, func(ctx context.Context, rwt datastore.ReadWriteTransaction) error {
+			return rwt.WriteRelationships([]*v1.RelationshipUpdate{{
+				Operation:    op,
+				Relationship: relationship,
+			}})
+		})
+		require.NoError(err)
+	}
+
+	afterDelete, err := pds.getNow(ctx)
+	require.NoError(err)
+
+	// Run GC and ensure the relationship is archived.
+	relsDeleted, transactionsDeleted, err := pds.collectGarbageBefore(ctx, afterDelete)
+	require.NoError(err)
+	require.Equal(int64(1), relsDeleted)
+	require.True(transactionsDeleted > 0)
+
+	readAsOf := func(asOf time.Time) []string {
+		iter, err := pds.QueryRelationshipsAsOf(ctx, asOf, &v1.RelationshipFilter{ResourceType: "resource"})
+		require.NoError(err)
+		defer iter.Close()
+
+		var found []string
+		for tpl := iter.Next(); tpl != nil; tpl = iter.Next() {
+			found = append(found, tuple.String(tpl))
+		}
+		require.NoError(iter.Err())
+		return found
+	}
+
+	require.Empty(readAsOf(beforeWrite))
+	require.Empty(readAsOf(afterDelete))
+
+	// Find the time at which the relationship existed from its archived transactions.
+	var createdAt time.Time
+	require.NoError(pds.dbpool.QueryRow(ctx, `SELECT timestamp FROM relation_tuple_transaction_history
+		WHERE id = (SELECT created_transaction FROM relation_tuple_history)`).Scan(&createdAt))
+	require.Equal([]string{rel}, readAsOf(createdAt))
+}
+
 const chunkRelationshipCount = 2000
 
 func ChunkedGarbageCollectionTest(t *testing.T, ds datastore.Datastore) {
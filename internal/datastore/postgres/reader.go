@@ -22,6 +22,11 @@ type pgReader struct {
 	txSource      common.TxFactory
 	querySplitter common.TupleQuerySplitter
 	filterer      queryFilterer
+
+	// snapshotTxn is the transaction at which the reader reads, or zero for the reader of a
+	// read-write transaction, whose changes are not reflected in the relationship counters
+	// until it commits.
+	snapshotTxn uint64
 }
 
 type queryFilterer func(original sq.SelectBuilder) sq.SelectBuilder
@@ -36,6 +41,10 @@ var (
 		colUsersetRelation,
 	).From(tableTuple)
 
+	countTuples = psql.Select("COUNT(*)").From(tableTuple)
+
+	queryCountersTotal = psql.Select(fmt.Sprintf("COALESCE(SUM(%s), 0)", colCount)).From(tableCounter)
+
 	schema = common.SchemaInformation{
 		ColNamespace:        colNamespace,
 		ColObjectID:         colObjectID,
@@ -51,6 +60,7 @@ var (
 const (
 	errUnableToReadConfig     = "unable to read namespace config: %w"
 	errUnableToListNamespaces = "unable to list namespaces: %w"
+	errUnableToCountTuples    = "unable to count tuples: %w"
 )
 
 func (r *pgReader) QueryRelationships(
@@ -200,4 +210,87 @@ func loadAllNamespaces(ctx context.Context, tx pgx.Tx, query sq.SelectBuilder) (
 	return nsDefs, nil
 }
 
+// CountRelationships counts the relationships matching the filter. Those of a resource type
+// are counted from the relationship counters, and only narrower filters count the matching
+// relationships themselves.
+func (r *pgReader) CountRelationships(ctx context.Context, filter *v1.RelationshipFilter) (uint64, error) {
+	ctx, span := tracer.Start(ctx, "CountRelationships")
+	defer span.End()
+
+	if r.snapshotTxn != 0 && common.CountableFromCounters(filter) {
+		span.SetAttributes(attribute.String("resourceType", filter.ResourceType))
+		return r.countFromCounters(ctx, filter.ResourceType)
+	}
+
+	qBuilder := common.NewSchemaQueryFilterer(schema, r.filterer(countTuples)).
+		FilterToRelationshipFilter(filter)
+	span.SetAttributes(qBuilder.TracerAttributes()...)
+
+	sql, args, err := qBuilder.ToSql()
+	if err != nil {
+		return 0, fmt.Errorf(errUnableToCountTuples, err)
+	}
+
+	tx, txCleanup, err := r.txSource(ctx)
+	if err != nil {
+		return 0, fmt.Errorf(errUnableToCountTuples, err)
+	}
+	defer txCleanup(ctx)
+
+	var count uint64
+	if err := tx.QueryRow(ctx, sql, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf(errUnableToCountTuples, err)
+	}
+
+	return count, nil
+}
+
+// countFromCounters counts the relationships of the resource type at the reader's revision.
+// The counters reflect the relationships currently living, so the relationships created and
+// deleted since the revision are counted, within the same transaction, to undo their changes.
+func (r *pgReader) countFromCounters(ctx context.Context, resourceType string) (uint64, error) {
+	counters := queryCountersTotal.Where(sq.Eq{colNamespace: resourceType})
+	createdSince := countTuples.
+		Where(sq.Eq{colNamespace: resourceType}).
+		Where(sq.Gt{colCreatedTxn: r.snapshotTxn}).
+		Where(sq.Eq{colDeletedTxn: liveDeletedTxnID})
+	deletedSince := countTuples.
+		Where(sq.Eq{colNamespace: resourceType}).
+		Where(sq.LtOrEq{colCreatedTxn: r.snapshotTxn}).
+		Where(sq.Gt{colDeletedTxn: r.snapshotTxn}).
+		Where(sq.NotEq{colDeletedTxn: liveDeletedTxnID})
+
+	tx, txCleanup, err := r.txSource(ctx)
+	if err != nil {
+		return 0, fmt.Errorf(errUnableToCountTuples, err)
+	}
+	defer txCleanup(ctx)
+
+	var total, created, deleted int64
+	for _, counted := range []struct {
+		query sq.SelectBuilder
+		count *int64
+	}{
+		{counters, &total},
+		{createdSince, &created},
+		{deletedSince, &deleted},
+	} {
+		sql, args, err := counted.query.ToSql()
+		if err != nil {
+			return 0, fmt.Errorf(errUnableToCountTuples, err)
+		}
+
+		if err := tx.QueryRow(ctx, sql, args...).Scan(counted.count); err != nil {
+			return 0, fmt.Errorf(errUnableToCountTuples, err)
+		}
+	}
+
+	// Writes made by versions which did not maintain the counters can leave them negative.
+	count := total - created + deleted
+	if count < 0 {
+		return 0, nil
+	}
+	return uint64(count), nil
+}
+
 var _ datastore.Reader = &pgReader{}
@@ -50,9 +50,10 @@ var (
 
 type pgReadWriteTXN struct {
 	*pgReader
-	ctx      context.Context
-	tx       pgx.Tx
-	newTxnID uint64
+	ctx             context.Context
+	tx              pgx.Tx
+	newTxnID        uint64
+	relCountChanges common.RelationshipCountChanges
 }
 
 func (rwt *pgReadWriteTXN) WriteRelationships(mutations []*v1.RelationshipUpdate) error {
@@ -62,17 +63,21 @@ func (rwt *pgReadWriteTXN) WriteRelationships(mutations []*v1.RelationshipUpdate
 	bulkWrite := writeTuple
 	bulkWriteHasValues := false
 
-	deleteClauses := sq.Or{}
+	// Deletes are made separately for each object type, so that the number of relationships
+	// deleted of each type is known.
+	deleteClauses := make(map[string]sq.Or)
 
 	// Process the actual updates
 	for _, mut := range mutations {
 		rel := mut.Relationship
 
 		if mut.Operation == v1.RelationshipUpdate_OPERATION_TOUCH || mut.Operation == v1.RelationshipUpdate_OPERATION_DELETE {
-			deleteClauses = append(deleteClauses, exactRelationshipClause(rel))
+			objectType := rel.Resource.ObjectType
+			deleteClauses[objectType] = append(deleteClauses[objectType], exactRelationshipClause(rel))
 		}
 
 		if mut.Operation == v1.RelationshipUpdate_OPERATION_TOUCH || mut.Operation == v1.RelationshipUpdate_OPERATION_CREATE {
+			rwt.relCountChanges.Add(rel.Resource.ObjectType, 1)
 			bulkWrite = bulkWrite.Values(
 				rel.Resource.ObjectType,
 				rel.Resource.ObjectId,
@@ -86,15 +91,18 @@ func (rwt *pgReadWriteTXN) WriteRelationships(mutations []*v1.RelationshipUpdate
 		}
 	}
 
-	if len(deleteClauses) > 0 {
-		sql, args, err := deleteTuple.Where(deleteClauses).Set(colDeletedTxn, rwt.newTxnID).ToSql()
+	for objectType, clauses := range deleteClauses {
+		sql, args, err := deleteTuple.Where(clauses).Set(colDeletedTxn, rwt.newTxnID).ToSql()
 		if err != nil {
 			return fmt.Errorf(errUnableToWriteRelationships, err)
 		}
 
-		if _, err := rwt.tx.Exec(ctx, sql, args...); err != nil {
+		deleted, err := rwt.tx.Exec(ctx, sql, args...)
+		if err != nil {
 			return fmt.Errorf(errUnableToWriteRelationships, err)
 		}
+
+		rwt.relCountChanges.Add(objectType, -deleted.RowsAffected())
 	}
 
 	if bulkWriteHasValues {
@@ -111,6 +119,65 @@ func (rwt *pgReadWriteTXN) WriteRelationships(mutations []*v1.RelationshipUpdate
 	return nil
 }
 
+// BulkLoad creates the relationships with a single COPY into the tuple table.
+func (rwt *pgReadWriteTXN) BulkLoad(ctx context.Context, source datastore.BulkWriteRelationshipSource) (uint64, error) {
+	ctx, span := tracer.Start(datastore.SeparateContextWithTracing(ctx), "BulkLoad")
+	defer span.End()
+
+	copySource := &copyFromSource{ctx: ctx, source: source, rwt: rwt}
+	copied, err := rwt.tx.CopyFrom(ctx, pgx.Identifier{tableTuple}, copyTupleCols, copySource)
+	if err != nil {
+		return 0, fmt.Errorf(errUnableToWriteRelationships, err)
+	}
+
+	return uint64(copied), nil
+}
+
+var copyTupleCols = []string{
+	colNamespace,
+	colObjectID,
+	colRelation,
+	colUsersetNamespace,
+	colUsersetObjectID,
+	colUsersetRelation,
+	colCreatedTxn,
+}
+
+// copyFromSource adapts a bulk load source to the rows of a COPY.
+type copyFromSource struct {
+	ctx    context.Context
+	source datastore.BulkWriteRelationshipSource
+	rwt    *pgReadWriteTXN
+
+	current *v1.Relationship
+	err     error
+}
+
+func (cfs *copyFromSource) Next() bool {
+	cfs.current, cfs.err = cfs.source.Next(cfs.ctx)
+	if cfs.current != nil {
+		cfs.rwt.relCountChanges.Add(cfs.current.Resource.ObjectType, 1)
+	}
+	return cfs.current != nil
+}
+
+func (cfs *copyFromSource) Values() ([]interface{}, error) {
+	rel := cfs.current
+	return []interface{}{
+		rel.Resource.ObjectType,
+		rel.Resource.ObjectId,
+		rel.Relation,
+		rel.Subject.Object.ObjectType,
+		rel.Subject.Object.ObjectId,
+		stringz.DefaultEmpty(rel.Subject.OptionalRelation, datastore.Ellipsis),
+		cfs.rwt.newTxnID,
+	}, nil
+}
+
+func (cfs *copyFromSource) Err() error {
+	return cfs.err
+}
+
 func (rwt *pgReadWriteTXN) DeleteRelationships(filter *v1.RelationshipFilter) error {
 	ctx, span := tracer.Start(datastore.SeparateContextWithTracing(rwt.ctx), "DeleteRelationships")
 	defer span.End()
@@ -150,10 +217,13 @@ func (rwt *pgReadWriteTXN) DeleteRelationships(filter *v1.RelationshipFilter) er
 		return fmt.Errorf(errUnableToDeleteRelationships, err)
 	}
 
-	if _, err := rwt.tx.Exec(ctx, sql, args...); err != nil {
+	deleted, err := rwt.tx.Exec(ctx, sql, args...)
+	if err != nil {
 		return fmt.Errorf(errUnableToDeleteRelationships, err)
 	}
 
+	rwt.relCountChanges.Add(filter.ResourceType, -deleted.RowsAffected())
+
 	return nil
 }
 
@@ -242,11 +312,13 @@ func (rwt *pgReadWriteTXN) DeleteNamespace(nsName string) error {
 		return fmt.Errorf(errUnableToDeleteConfig, err)
 	}
 
-	_, err = rwt.tx.Exec(ctx, deleteTupleSQL, deleteTupleArgs...)
+	deleted, err := rwt.tx.Exec(ctx, deleteTupleSQL, deleteTupleArgs...)
 	if err != nil {
 		return fmt.Errorf(errUnableToDeleteConfig, err)
 	}
 
+	rwt.relCountChanges.Add(nsName, -deleted.RowsAffected())
+
 	return nil
 }
 
@@ -261,4 +333,7 @@ func exactRelationshipClause(r *v1.Relationship) sq.Eq {
 	}
 }
 
-var _ datastore.ReadWriteTransaction = &pgReadWriteTXN{}
+var (
+	_ datastore.ReadWriteTransaction = &pgReadWriteTXN{}
+	_ datastore.BulkLoader           = &pgReadWriteTXN{}
+)
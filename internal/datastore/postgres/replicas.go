@@ -0,0 +1,102 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/log/zerologadapter"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/rs/zerolog/log"
+
+	"github.com/authzed/spicedb/internal/datastore/common"
+	"github.com/authzed/spicedb/pkg/datastore"
+)
+
+// queryReplicaLag returns the age, in seconds, of the oldest transaction newer than
+// the one given, which is the amount of time a replica that has replayed the given
+// transaction is lagging behind the primary.
+var queryReplicaLag = fmt.Sprintf(
+	`SELECT COALESCE(EXTRACT(EPOCH FROM (NOW() AT TIME ZONE 'utc') - MIN(%s)), 0) FROM %s WHERE %s > $1;`,
+	colTimestamp,
+	tableTransaction,
+	colID,
+)
+
+// replicaReadTxOptions are used for snapshot reads on replicas, as hot standbys do not
+// support serializable transactions. As snapshot reads never observe transactions past
+// their revision, repeatable read provides the same results.
+var replicaReadTxOptions = pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly}
+
+// connectPool connects a pool to the database at the given URL, configured by the
+// options of the datastore.
+func connectPool(url string, config postgresOptions) (*pgxpool.Pool, error) {
+	// config must be initialized by ParseConfig
+	pgxConfig, err := pgxpool.ParseConfig(url)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.maxOpenConns != nil {
+		pgxConfig.MaxConns = int32(*config.maxOpenConns)
+	}
+	if config.minOpenConns != nil {
+		pgxConfig.MinConns = int32(*config.minOpenConns)
+	}
+	if config.connMaxIdleTime != nil {
+		pgxConfig.MaxConnIdleTime = *config.connMaxIdleTime
+	}
+	if config.connMaxLifetime != nil {
+		pgxConfig.MaxConnLifetime = *config.connMaxLifetime
+	}
+	if config.healthCheckPeriod != nil {
+		pgxConfig.HealthCheckPeriod = *config.healthCheckPeriod
+	}
+
+	pgxConfig.ConnConfig.Logger = zerologadapter.NewLogger(log.Logger)
+
+	return pgxpool.ConnectConfig(context.Background(), pgxConfig)
+}
+
+// connectReplicas connects a pool to each of the configured read replicas, along with a
+// router to choose between them. The router is not started.
+func connectReplicas(config postgresOptions) ([]*pgxpool.Pool, *common.ReplicaRouter, error) {
+	pools := make([]*pgxpool.Pool, 0, len(config.readReplicaURIs))
+	names := make([]string, 0, len(config.readReplicaURIs))
+	for _, url := range config.readReplicaURIs {
+		pool, err := connectPool(url, config)
+		if err != nil {
+			for _, connected := range pools {
+				connected.Close()
+			}
+			return nil, nil, fmt.Errorf("unable to connect to read replica: %w", err)
+		}
+
+		connConfig := pool.Config().ConnConfig
+		pools = append(pools, pool)
+		names = append(names, net.JoinHostPort(connConfig.Host, strconv.Itoa(int(connConfig.Port))))
+	}
+
+	return pools, common.NewReplicaRouter(Engine, names), nil
+}
+
+// replicaStatus returns the latest transaction replayed by the replica, and how long ago
+// the first transaction it has yet to replay was committed on the primary.
+func (pgd *pgDatastore) replicaStatus(ctx context.Context, replica int) (uint64, time.Duration, error) {
+	replayed, err := loadRevisionFrom(ctx, pgd.replicaPools[replica])
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var lagSeconds float64
+	if err := pgd.dbpool.QueryRow(
+		datastore.SeparateContextWithTracing(ctx), queryReplicaLag, replayed,
+	).Scan(&lagSeconds); err != nil {
+		return 0, 0, fmt.Errorf("unable to compute replica lag: %w", err)
+	}
+
+	return replayed, time.Duration(lagSeconds * float64(time.Second)), nil
+}
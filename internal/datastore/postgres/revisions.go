@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/shopspring/decimal"
 
 	"github.com/authzed/spicedb/pkg/datastore"
@@ -98,6 +99,10 @@ func (pgd *pgDatastore) CheckRevision(ctx context.Context, revision datastore.Re
 }
 
 func (pgd *pgDatastore) loadRevision(ctx context.Context) (uint64, error) {
+	return loadRevisionFrom(ctx, pgd.dbpool)
+}
+
+func loadRevisionFrom(ctx context.Context, pool *pgxpool.Pool) (uint64, error) {
 	ctx, span := tracer.Start(ctx, "loadRevision")
 	defer span.End()
 
@@ -107,7 +112,7 @@ func (pgd *pgDatastore) loadRevision(ctx context.Context) (uint64, error) {
 	}
 
 	var revision uint64
-	err = pgd.dbpool.QueryRow(datastore.SeparateContextWithTracing(ctx), sql, args...).Scan(&revision)
+	err = pool.QueryRow(datastore.SeparateContextWithTracing(ctx), sql, args...).Scan(&revision)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return 0, nil
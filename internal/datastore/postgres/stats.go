@@ -3,10 +3,12 @@ package postgres
 import (
 	"context"
 	"fmt"
+	"math/rand"
 
 	sq "github.com/Masterminds/squirrel"
 	"github.com/jackc/pgx/v4"
 
+	"github.com/authzed/spicedb/internal/datastore/common"
 	"github.com/authzed/spicedb/pkg/datastore"
 	corev1 "github.com/authzed/spicedb/pkg/proto/core/v1"
 )
@@ -15,6 +17,14 @@ const (
 	tableMetadata = "metadata"
 	colUniqueID   = "unique_id"
 
+	tableCounter = "relationship_counter"
+	colShard     = "shard"
+	colCount     = "count"
+
+	// counterShards is the number of rows over which the counter for each namespace is
+	// spread.
+	counterShards = 16
+
 	tablePGClass = "pg_class"
 	colReltuples = "reltuples"
 	colRelname   = "relname"
@@ -26,6 +36,17 @@ var (
 				Select(colReltuples).
 				From(tablePGClass).
 				Where(sq.Eq{colRelname: tableTuple})
+
+	queryRelationshipCounters = psql.
+					Select(colNamespace, fmt.Sprintf("SUM(%s)", colCount)).
+					From(tableCounter).
+					GroupBy(colNamespace)
+
+	upsertCounterQuery = psql.Insert(tableCounter).Columns(
+		colNamespace,
+		colShard,
+		colCount,
+	).Suffix(fmt.Sprintf("ON CONFLICT (%[1]s, %[2]s) DO UPDATE SET %[3]s = %[4]s.%[3]s + EXCLUDED.%[3]s", colNamespace, colShard, colCount, tableCounter))
 )
 
 func (pgd *pgDatastore) Statistics(ctx context.Context) (datastore.Stats, error) {
@@ -44,6 +65,7 @@ func (pgd *pgDatastore) Statistics(ctx context.Context) (datastore.Stats, error)
 	var uniqueID string
 	var nsDefs []*corev1.NamespaceDefinition
 	var relCount int64
+	var countsByType map[string]uint64
 	if err := pgd.dbpool.BeginTxFunc(ctx, pgd.readTxOptions, func(tx pgx.Tx) error {
 		if pgd.analyzeBeforeStatistics {
 			if _, err := tx.Exec(ctx, fmt.Sprintf("ANALYZE %s", tableTuple)); err != nil {
@@ -64,6 +86,11 @@ func (pgd *pgDatastore) Statistics(ctx context.Context) (datastore.Stats, error)
 			return fmt.Errorf("unable to read relationship count: %w", err)
 		}
 
+		countsByType, err = readRelationshipCounters(ctx, tx)
+		if err != nil {
+			return fmt.Errorf("unable to read relationship counters: %w", err)
+		}
+
 		return nil
 	}); err != nil {
 		return datastore.Stats{}, err
@@ -77,7 +104,63 @@ func (pgd *pgDatastore) Statistics(ctx context.Context) (datastore.Stats, error)
 
 	return datastore.Stats{
 		UniqueID:                   uniqueID,
-		ObjectTypeStatistics:       datastore.ComputeObjectTypeStats(nsDefs),
+		ObjectTypeStatistics:       datastore.ComputeObjectTypeStats(nsDefs, countsByType),
 		EstimatedRelationshipCount: relCountUint,
 	}, nil
 }
+
+func readRelationshipCounters(ctx context.Context, tx pgx.Tx) (map[string]uint64, error) {
+	sql, args, err := queryRelationshipCounters.ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	countsByType := make(map[string]uint64)
+	for rows.Next() {
+		var objectType string
+		var count int64
+		if err := rows.Scan(&objectType, &count); err != nil {
+			return nil, err
+		}
+
+		// Writes made by versions which did not maintain the counters can leave them negative.
+		if count > 0 {
+			countsByType[objectType] = uint64(count)
+		}
+	}
+
+	return countsByType, rows.Err()
+}
+
+// updateCounters applies the changes in the number of relationships of each namespace to
+// a randomly chosen shard of its counter.
+func updateCounters(ctx context.Context, tx pgx.Tx, changes common.RelationshipCountChanges) error {
+	objectTypes := changes.SortedObjectTypes()
+	if len(objectTypes) == 0 {
+		return nil
+	}
+
+	shard := rand.Intn(counterShards)
+
+	query := upsertCounterQuery
+	for _, objectType := range objectTypes {
+		query = query.Values(objectType, shard, changes[objectType])
+	}
+
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("unable to prepare upsert counter sql: %w", err)
+	}
+
+	if _, err := tx.Exec(ctx, sql, args...); err != nil {
+		return fmt.Errorf("unable to execute upsert counter query: %w", err)
+	}
+
+	return nil
+}
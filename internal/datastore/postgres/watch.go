@@ -14,7 +14,14 @@ import (
 )
 
 const (
+	// watchSleep is the interval at which watchers poll for changes while change
+	// notifications are not being received.
 	watchSleep = 100 * time.Millisecond
+
+	// watchFallbackSleep is the interval at which watchers poll for changes while change
+	// notifications are being received, in case any notification is missed. It bounds the delay
+	// in reporting changes, and the checkpoints which follow them, when a notification is lost.
+	watchFallbackSleep = 1 * time.Second
 )
 
 var queryChanged = psql.Select(
@@ -36,11 +43,15 @@ func (pgd *pgDatastore) Watch(ctx context.Context, afterRevision datastore.Revis
 		defer close(updates)
 		defer close(errs)
 
+		notifications, unsubscribe := pgd.changeNotifier.subscribe()
+		defer unsubscribe()
+
 		currentTxn := transactionFromRevision(afterRevision)
 
 		for {
 			var stagedUpdates []*datastore.RevisionChanges
 			var err error
+			previousTxn := currentTxn
 			stagedUpdates, currentTxn, err = pgd.loadChanges(ctx, currentTxn)
 			if err != nil {
 				if errors.Is(ctx.Err(), context.Canceled) {
@@ -61,11 +72,21 @@ func (pgd *pgDatastore) Watch(ctx context.Context, afterRevision datastore.Revis
 				}
 			}
 
-			// If there were no changes, sleep a bit
+			if currentTxn > previousTxn {
+				common.SendCheckpoint(ctx, updates, revisionFromTransaction(currentTxn))
+			}
+
+			// If there were no changes, wait for a transaction to be committed
 			if len(stagedUpdates) == 0 {
-				sleep := time.NewTimer(watchSleep)
+				sleepDuration := watchSleep
+				if pgd.changeNotifier.isListening() {
+					sleepDuration = watchFallbackSleep
+				}
+				sleep := time.NewTimer(sleepDuration)
 
 				select {
+				case <-notifications:
+					sleep.Stop()
 				case <-sleep.C:
 					break
 				case <-ctx.Done():
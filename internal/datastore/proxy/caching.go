@@ -5,6 +5,9 @@ import (
 	"errors"
 	"fmt"
 	"sync"
+	"time"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
 
 	"github.com/dgraph-io/ristretto"
 	"github.com/dustin/go-humanize"
@@ -65,6 +68,16 @@ func (p *nsCachingProxy) ReadWriteTx(
 	})
 }
 
+// QueryRelationshipsAsOf forwards to the delegate datastore, which would otherwise be hidden
+// from datastore.QueryRelationshipsAsOf by the proxy.
+func (p *nsCachingProxy) QueryRelationshipsAsOf(
+	ctx context.Context,
+	asOf time.Time,
+	filter *v1.RelationshipFilter,
+) (datastore.RelationshipIterator, error) {
+	return datastore.QueryRelationshipsAsOf(ctx, p.Datastore, asOf, filter)
+}
+
 type nsCachingReader struct {
 	datastore.Reader
 	sync.Mutex
@@ -139,6 +152,12 @@ func (rwt *nsCachingRWT) ReadNamespace(
 	return entry.def, entry.updated, entry.notFound
 }
 
+// BulkLoad forwards to the delegate transaction, which would otherwise be hidden from
+// datastore.BulkLoad by the proxy.
+func (rwt *nsCachingRWT) BulkLoad(ctx context.Context, source datastore.BulkWriteRelationshipSource) (uint64, error) {
+	return datastore.BulkLoad(ctx, rwt.ReadWriteTransaction, source)
+}
+
 type cacheEntry struct {
 	def      *core.NamespaceDefinition
 	updated  datastore.Revision
@@ -205,6 +205,16 @@ func (hp hedgingProxy) HeadRevision(ctx context.Context) (rev datastore.Revision
 	return
 }
 
+// QueryRelationshipsAsOf forwards to the delegate datastore without hedging, as reads of the
+// archived history are rare and may be expensive.
+func (hp hedgingProxy) QueryRelationshipsAsOf(
+	ctx context.Context,
+	asOf time.Time,
+	filter *v1.RelationshipFilter,
+) (datastore.RelationshipIterator, error) {
+	return datastore.QueryRelationshipsAsOf(ctx, hp.Datastore, asOf, filter)
+}
+
 func (hp hedgingProxy) SnapshotReader(rev datastore.Revision) datastore.Reader {
 	delegate := hp.Datastore.SnapshotReader(rev)
 	return &hedgingReader{delegate, hp}
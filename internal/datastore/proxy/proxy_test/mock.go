@@ -127,6 +127,11 @@ func (dm *MockReader) ReverseQueryRelationships(
 	return results, args.Error(1)
 }
 
+func (dm *MockReader) CountRelationships(ctx context.Context, filter *v1.RelationshipFilter) (uint64, error) {
+	args := dm.Called(filter)
+	return args.Get(0).(uint64), args.Error(1)
+}
+
 func (dm *MockReader) ListNamespaces(ctx context.Context) ([]*core.NamespaceDefinition, error) {
 	args := dm.Called()
 	return args.Get(0).([]*core.NamespaceDefinition), args.Error(1)
@@ -190,6 +195,11 @@ func (dm *MockReadWriteTransaction) ReverseQueryRelationships(
 	return results, args.Error(1)
 }
 
+func (dm *MockReadWriteTransaction) CountRelationships(ctx context.Context, filter *v1.RelationshipFilter) (uint64, error) {
+	args := dm.Called(filter)
+	return args.Get(0).(uint64), args.Error(1)
+}
+
 func (dm *MockReadWriteTransaction) ListNamespaces(ctx context.Context) ([]*core.NamespaceDefinition, error) {
 	args := dm.Called()
 	return args.Get(0).([]*core.NamespaceDefinition), args.Error(1)
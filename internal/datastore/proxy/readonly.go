@@ -2,6 +2,9 @@ package proxy
 
 import (
 	"context"
+	"time"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
 
 	"github.com/authzed/spicedb/pkg/datastore"
 )
@@ -53,3 +56,7 @@ func (rd roDatastore) Watch(ctx context.Context, afterRevision datastore.Revisio
 func (rd roDatastore) Statistics(ctx context.Context) (datastore.Stats, error) {
 	return rd.delegate.Statistics(ctx)
 }
+
+func (rd roDatastore) QueryRelationshipsAsOf(ctx context.Context, asOf time.Time, filter *v1.RelationshipFilter) (datastore.RelationshipIterator, error) {
+	return datastore.QueryRelationshipsAsOf(ctx, rd.delegate, asOf, filter)
+}
@@ -0,0 +1,296 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/dgraph-io/ristretto"
+	"github.com/dustin/go-humanize"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/sync/singleflight"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/authzed/spicedb/internal/datastore/options"
+	"github.com/authzed/spicedb/pkg/datastore"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+var relationshipCacheHits = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "spicedb",
+	Subsystem: "datastore",
+	Name:      "relationship_cache_hits_total",
+	Help:      "total number of relationship queries served from the relationship cache",
+}, []string{"query"})
+
+var relationshipCacheMisses = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "spicedb",
+	Subsystem: "datastore",
+	Name:      "relationship_cache_misses_total",
+	Help:      "total number of relationship queries which could not be served from the relationship cache",
+}, []string{"query"})
+
+const (
+	forwardQueryLabel = "forward"
+	reverseQueryLabel = "reverse"
+
+	// defaultMaxResultCost is the largest result, in bytes, which will be cached when no limit
+	// is specified.
+	defaultMaxResultCost = 1 << 16
+)
+
+// NewRelationshipCachingDatastoreProxy creates a new datastore proxy which caches the results of
+// relationship queries performed at specific datastore revisions. Results whose total size
+// exceeds maxResultCost bytes are not cached and are always read from the delegate.
+//
+// The relationships returned by the proxy's readers may be shared with other callers of the
+// proxy, and must not be modified.
+func NewRelationshipCachingDatastoreProxy(
+	delegate datastore.Datastore,
+	cacheConfig *ristretto.Config,
+	maxResultCost int64,
+) (datastore.Datastore, error) {
+	if cacheConfig == nil {
+		cacheConfig = &ristretto.Config{
+			NumCounters: 1e4,     // number of keys to track frequency of (10k).
+			MaxCost:     1 << 24, // maximum cost of cache (16MB).
+			BufferItems: 64,      // number of keys per Get buffer.
+		}
+	} else {
+		log.Info().Int64("numCounters", cacheConfig.NumCounters).Str("maxCost", humanize.Bytes(uint64(cacheConfig.MaxCost))).Msg("configured caching relationship reader")
+	}
+
+	if maxResultCost <= 0 {
+		maxResultCost = defaultMaxResultCost
+	}
+
+	cache, err := ristretto.NewCache(cacheConfig)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create cache: %w", err)
+	}
+
+	return &relCachingProxy{
+		Datastore:     delegate,
+		c:             cache,
+		maxResultCost: maxResultCost,
+	}, nil
+}
+
+type relCachingProxy struct {
+	datastore.Datastore
+	c             *ristretto.Cache
+	maxResultCost int64
+	queryGroup    singleflight.Group
+}
+
+func (p *relCachingProxy) SnapshotReader(rev datastore.Revision) datastore.Reader {
+	delegateReader := p.Datastore.SnapshotReader(rev)
+	return &relCachingReader{delegateReader, rev, p}
+}
+
+// QueryRelationshipsAsOf forwards to the delegate datastore, which would otherwise be hidden
+// from datastore.QueryRelationshipsAsOf by the proxy.
+func (p *relCachingProxy) QueryRelationshipsAsOf(
+	ctx context.Context,
+	asOf time.Time,
+	filter *v1.RelationshipFilter,
+) (datastore.RelationshipIterator, error) {
+	return datastore.QueryRelationshipsAsOf(ctx, p.Datastore, asOf, filter)
+}
+
+type relCachingReader struct {
+	datastore.Reader
+	rev datastore.Revision
+	p   *relCachingProxy
+}
+
+func (r *relCachingReader) QueryRelationships(
+	ctx context.Context,
+	filter *v1.RelationshipFilter,
+	opts ...options.QueryOptionsOption,
+) (datastore.RelationshipIterator, error) {
+	queryOpts := options.NewQueryOptionsWithOptions(opts...)
+
+	var sb strings.Builder
+	sb.WriteString("q:")
+	if err := writeProtoKey(&sb, filter); err != nil {
+		return nil, err
+	}
+	writeLimitKey(&sb, queryOpts.Limit)
+
+	// The order of the usersets does not affect the results of the query.
+	usersets := make([]string, 0, len(queryOpts.Usersets))
+	for _, userset := range queryOpts.Usersets {
+		usersets = append(usersets, tuple.StringONR(userset))
+	}
+	sort.Strings(usersets)
+	for _, userset := range usersets {
+		sb.WriteString(userset)
+		sb.WriteByte(',')
+	}
+
+	return r.cachedQuery(ctx, sb.String(), forwardQueryLabel, func() (datastore.RelationshipIterator, error) {
+		return r.Reader.QueryRelationships(ctx, filter, opts...)
+	})
+}
+
+func (r *relCachingReader) ReverseQueryRelationships(
+	ctx context.Context,
+	subjectFilter *v1.SubjectFilter,
+	opts ...options.ReverseQueryOptionsOption,
+) (datastore.RelationshipIterator, error) {
+	queryOpts := options.NewReverseQueryOptionsWithOptions(opts...)
+
+	var sb strings.Builder
+	sb.WriteString("r:")
+	if err := writeProtoKey(&sb, subjectFilter); err != nil {
+		return nil, err
+	}
+	writeLimitKey(&sb, queryOpts.ReverseLimit)
+	if queryOpts.ResRelation != nil {
+		sb.WriteString(queryOpts.ResRelation.Namespace)
+		sb.WriteByte('#')
+		sb.WriteString(queryOpts.ResRelation.Relation)
+	}
+
+	return r.cachedQuery(ctx, sb.String(), reverseQueryLabel, func() (datastore.RelationshipIterator, error) {
+		return r.Reader.ReverseQueryRelationships(ctx, subjectFilter, opts...)
+	})
+}
+
+// cachedQuery returns an iterator over the cached results for the query key at the reader's
+// revision, loading them with exec if they are not yet cached. The tuples in a cached result are
+// shared between all callers and must not be modified.
+func (r *relCachingReader) cachedQuery(
+	ctx context.Context,
+	queryKey string,
+	queryLabel string,
+	exec func() (datastore.RelationshipIterator, error),
+) (datastore.RelationshipIterator, error) {
+	cacheKey := fmt.Sprintf("%s@%s", queryKey, r.rev)
+
+	loadedRaw, found := r.p.c.Get(cacheKey)
+	if found {
+		if tuples, ok := loadedRaw.([]*core.RelationTuple); ok {
+			relationshipCacheHits.WithLabelValues(queryLabel).Inc()
+			return datastore.NewSliceRelationshipIterator(tuples), nil
+		}
+
+		// The result is known to be too large to cache.
+		relationshipCacheMisses.WithLabelValues(queryLabel).Inc()
+		return exec()
+	}
+
+	relationshipCacheMisses.WithLabelValues(queryLabel).Inc()
+
+	loadedRaw, err := r.loadQuery(ctx, cacheKey, exec)
+	if err != nil {
+		return nil, err
+	}
+
+	tuples, ok := loadedRaw.([]*core.RelationTuple)
+	if !ok {
+		return exec()
+	}
+
+	return datastore.NewSliceRelationshipIterator(tuples), nil
+}
+
+// loadQuery loads the results for the cache key with exec, sharing the load with any concurrent
+// callers for the same key. As the shared load is performed with the context of whichever
+// caller started it, a load which fails because that caller was canceled is retried for each
+// caller which has not been canceled itself.
+func (r *relCachingReader) loadQuery(
+	ctx context.Context,
+	cacheKey string,
+	exec func() (datastore.RelationshipIterator, error),
+) (interface{}, error) {
+	for {
+		executed := false
+		loadedRaw, err := r.doLoadQuery(cacheKey, func() (datastore.RelationshipIterator, error) {
+			executed = true
+			return exec()
+		})
+		if err != nil && !executed && isContextError(err) && ctx.Err() == nil {
+			continue
+		}
+		return loadedRaw, err
+	}
+}
+
+func (r *relCachingReader) doLoadQuery(
+	cacheKey string,
+	exec func() (datastore.RelationshipIterator, error),
+) (interface{}, error) {
+	loadedRaw, err, _ := r.p.queryGroup.Do(cacheKey, func() (interface{}, error) {
+		iter, err := exec()
+		if err != nil {
+			return nil, err
+		}
+		defer iter.Close()
+
+		var tuples []*core.RelationTuple
+		var cost int64
+		for tpl := iter.Next(); tpl != nil; tpl = iter.Next() {
+			cost += int64(proto.Size(tpl))
+			if cost > r.p.maxResultCost {
+				// Remember that this result is too large, so that subsequent callers go straight
+				// to the delegate rather than buffering it again.
+				r.p.c.Set(cacheKey, resultTooLarge{}, 1)
+				r.p.c.Wait()
+				return resultTooLarge{}, nil
+			}
+			tuples = append(tuples, tpl)
+		}
+		if iter.Err() != nil {
+			return nil, iter.Err()
+		}
+
+		// Save it to the cache
+		r.p.c.Set(cacheKey, tuples, cost)
+
+		// We have to call wait here or else Ristretto may not have the key available to a
+		// subsequent caller.
+		r.p.c.Wait()
+
+		return tuples, nil
+	})
+	return loadedRaw, err
+}
+
+func isContextError(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// resultTooLarge is cached in place of results which exceed the maximum result cost.
+type resultTooLarge struct{}
+
+func writeProtoKey(sb *strings.Builder, msg proto.Message) error {
+	marshalled, err := proto.MarshalOptions{Deterministic: true}.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("unable to compute relationship cache key: %w", err)
+	}
+
+	sb.Write(marshalled)
+	sb.WriteByte(':')
+	return nil
+}
+
+func writeLimitKey(sb *strings.Builder, limit *uint64) {
+	if limit != nil {
+		fmt.Fprintf(sb, "%d", *limit)
+	}
+	sb.WriteByte(':')
+}
+
+var (
+	_ datastore.Datastore = &relCachingProxy{}
+	_ datastore.Reader    = &relCachingReader{}
+)
@@ -0,0 +1,243 @@
+package proxy
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/authzed/spicedb/internal/datastore/options"
+	"github.com/authzed/spicedb/internal/datastore/proxy/proxy_test"
+	"github.com/authzed/spicedb/pkg/datastore"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+var (
+	folderFilter   = &v1.RelationshipFilter{ResourceType: "folder", OptionalResourceId: "shared", OptionalRelation: "parent"}
+	documentFilter = &v1.RelationshipFilter{ResourceType: "document", OptionalResourceId: "readme", OptionalRelation: "parent"}
+	userFilter     = &v1.SubjectFilter{SubjectType: "user", OptionalSubjectId: "tom"}
+
+	folderParent = tuple.MustParse("folder:shared#parent@folder:root")
+	docParent    = tuple.MustParse("document:readme#parent@folder:shared")
+)
+
+func iteratorOf(tuples ...*core.RelationTuple) datastore.RelationshipIterator {
+	return datastore.NewSliceRelationshipIterator(tuples)
+}
+
+func collectTuples(t *testing.T, iter datastore.RelationshipIterator, err error) []*core.RelationTuple {
+	require.NoError(t, err)
+	defer iter.Close()
+
+	var tuples []*core.RelationTuple
+	for tpl := iter.Next(); tpl != nil; tpl = iter.Next() {
+		tuples = append(tuples, tpl)
+	}
+	require.NoError(t, iter.Err())
+	return tuples
+}
+
+func TestSnapshotRelationshipCaching(t *testing.T) {
+	dsMock := &proxy_test.MockDatastore{}
+
+	oneReader := &proxy_test.MockReader{}
+	dsMock.On("SnapshotReader", one).Return(oneReader)
+	oneReader.On("QueryRelationships", folderFilter).Return(iteratorOf(folderParent), nil).Once()
+	oneReader.On("QueryRelationships", documentFilter).Return(iteratorOf(docParent), nil).Once()
+
+	twoReader := &proxy_test.MockReader{}
+	dsMock.On("SnapshotReader", two).Return(twoReader)
+	twoReader.On("QueryRelationships", folderFilter).Return(iteratorOf(), nil).Once()
+
+	require := require.New(t)
+	ctx := context.Background()
+
+	ds, err := NewRelationshipCachingDatastoreProxy(dsMock, nil, 0)
+	require.NoError(err)
+
+	for i := 0; i < 3; i++ {
+		iter, err := ds.SnapshotReader(one).QueryRelationships(ctx, folderFilter)
+		require.Equal([]*core.RelationTuple{folderParent}, collectTuples(t, iter, err))
+
+		iter, err = ds.SnapshotReader(one).QueryRelationships(ctx, documentFilter)
+		require.Equal([]*core.RelationTuple{docParent}, collectTuples(t, iter, err))
+
+		iter, err = ds.SnapshotReader(two).QueryRelationships(ctx, folderFilter)
+		require.Empty(collectTuples(t, iter, err))
+	}
+
+	dsMock.AssertExpectations(t)
+	oneReader.AssertExpectations(t)
+	twoReader.AssertExpectations(t)
+}
+
+func TestReverseRelationshipCachingOptions(t *testing.T) {
+	dsMock := &proxy_test.MockDatastore{}
+
+	oneReader := &proxy_test.MockReader{}
+	dsMock.On("SnapshotReader", one).Return(oneReader)
+	oneReader.On("ReverseQueryRelationships", userFilter, mock.Anything).Return(iteratorOf(docParent), nil).Once()
+	oneReader.On("ReverseQueryRelationships", userFilter, mock.Anything).Return(iteratorOf(folderParent), nil).Once()
+
+	require := require.New(t)
+	ctx := context.Background()
+
+	ds, err := NewRelationshipCachingDatastoreProxy(dsMock, nil, 0)
+	require.NoError(err)
+
+	for i := 0; i < 2; i++ {
+		iter, err := ds.SnapshotReader(one).ReverseQueryRelationships(ctx, userFilter, options.WithResRelation(&options.ResourceRelation{
+			Namespace: "document",
+			Relation:  "parent",
+		}))
+		require.Equal([]*core.RelationTuple{docParent}, collectTuples(t, iter, err))
+
+		iter, err = ds.SnapshotReader(one).ReverseQueryRelationships(ctx, userFilter, options.WithResRelation(&options.ResourceRelation{
+			Namespace: "folder",
+			Relation:  "parent",
+		}))
+		require.Equal([]*core.RelationTuple{folderParent}, collectTuples(t, iter, err))
+	}
+
+	dsMock.AssertExpectations(t)
+	oneReader.AssertExpectations(t)
+}
+
+func TestRelationshipCachingResultTooLarge(t *testing.T) {
+	dsMock := &proxy_test.MockDatastore{}
+
+	oneReader := &proxy_test.MockReader{}
+	dsMock.On("SnapshotReader", one).Return(oneReader)
+
+	// The first query is read once to measure it and once more to return it, and every
+	// subsequent query goes straight to the delegate.
+	oneReader.On("QueryRelationships", folderFilter).Return(iteratorOf(folderParent, docParent), nil).Once()
+	oneReader.On("QueryRelationships", folderFilter).Return(iteratorOf(folderParent, docParent), nil).Once()
+	oneReader.On("QueryRelationships", folderFilter).Return(iteratorOf(folderParent, docParent), nil).Once()
+
+	require := require.New(t)
+	ctx := context.Background()
+
+	ds, err := NewRelationshipCachingDatastoreProxy(dsMock, nil, int64(proto.Size(folderParent)))
+	require.NoError(err)
+
+	for i := 0; i < 2; i++ {
+		iter, err := ds.SnapshotReader(one).QueryRelationships(ctx, folderFilter)
+		require.Equal([]*core.RelationTuple{folderParent, docParent}, collectTuples(t, iter, err))
+	}
+
+	dsMock.AssertExpectations(t)
+	oneReader.AssertExpectations(t)
+}
+
+func TestRelationshipCachingSingleFlight(t *testing.T) {
+	dsMock := &proxy_test.MockDatastore{}
+
+	oneReader := &proxy_test.MockReader{}
+	dsMock.On("SnapshotReader", one).Return(oneReader)
+	oneReader.
+		On("QueryRelationships", folderFilter).
+		WaitUntil(time.After(10*time.Millisecond)).
+		Return(iteratorOf(folderParent), nil).
+		Once()
+
+	require := require.New(t)
+	ctx := context.Background()
+
+	ds, err := NewRelationshipCachingDatastoreProxy(dsMock, nil, 0)
+	require.NoError(err)
+
+	queryRelationships := func() error {
+		iter, err := ds.SnapshotReader(one).QueryRelationships(ctx, folderFilter)
+		require.Equal([]*core.RelationTuple{folderParent}, collectTuples(t, iter, err))
+		return nil
+	}
+
+	g := errgroup.Group{}
+	g.Go(queryRelationships)
+	g.Go(queryRelationships)
+
+	require.NoError(g.Wait())
+
+	dsMock.AssertExpectations(t)
+	oneReader.AssertExpectations(t)
+}
+
+func TestRelationshipCachingSingleFlightCanceled(t *testing.T) {
+	dsMock := &proxy_test.MockDatastore{}
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	oneReader := &proxy_test.MockReader{}
+	dsMock.On("SnapshotReader", one).Return(oneReader)
+	oneReader.
+		On("QueryRelationships", folderFilter).
+		Run(func(mock.Arguments) {
+			close(started)
+			<-release
+		}).
+		Return(nil, context.Canceled).
+		Once()
+	oneReader.On("QueryRelationships", folderFilter).Return(iteratorOf(folderParent), nil).Once()
+
+	require := require.New(t)
+
+	ds, err := NewRelationshipCachingDatastoreProxy(dsMock, nil, 0)
+	require.NoError(err)
+
+	canceledErr := make(chan error, 1)
+	go func() {
+		_, err := ds.SnapshotReader(one).QueryRelationships(context.Background(), folderFilter)
+		canceledErr <- err
+	}()
+
+	// The second caller waits on the query started by the first, which is then canceled. As
+	// the second caller has not been canceled, it queries again itself.
+	<-started
+	queried := make(chan []*core.RelationTuple, 1)
+	go func() {
+		iter, err := ds.SnapshotReader(one).QueryRelationships(context.Background(), folderFilter)
+		queried <- collectTuples(t, iter, err)
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+
+	require.ErrorIs(<-canceledErr, context.Canceled)
+	require.Equal([]*core.RelationTuple{folderParent}, <-queried)
+
+	dsMock.AssertExpectations(t)
+	oneReader.AssertExpectations(t)
+}
+
+func TestRelationshipCachingUsersetOrder(t *testing.T) {
+	first := tuple.ParseONR("group:first#member")
+	second := tuple.ParseONR("group:second#member")
+
+	dsMock := &proxy_test.MockDatastore{}
+
+	oneReader := &proxy_test.MockReader{}
+	dsMock.On("SnapshotReader", one).Return(oneReader)
+	oneReader.On("QueryRelationships", folderFilter, mock.Anything).Return(iteratorOf(folderParent), nil).Once()
+
+	require := require.New(t)
+	ctx := context.Background()
+
+	ds, err := NewRelationshipCachingDatastoreProxy(dsMock, nil, 0)
+	require.NoError(err)
+
+	for _, usersets := range [][]*core.ObjectAndRelation{{first, second}, {second, first}} {
+		iter, err := ds.SnapshotReader(one).QueryRelationships(ctx, folderFilter, options.SetUsersets(usersets))
+		require.Equal([]*core.RelationTuple{folderParent}, collectTuples(t, iter, err))
+	}
+
+	dsMock.AssertExpectations(t)
+	oneReader.AssertExpectations(t)
+}
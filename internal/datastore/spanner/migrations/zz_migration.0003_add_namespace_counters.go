@@ -0,0 +1,50 @@
+package migrations
+
+import (
+	"context"
+
+	"cloud.google.com/go/spanner"
+	"google.golang.org/genproto/googleapis/spanner/admin/database/v1"
+)
+
+const (
+	createNamespaceCounters = `CREATE TABLE namespace_relationship_counters (
+		namespace STRING(MAX) NOT NULL,
+		id BYTES(2) NOT NULL,
+		count INT64 NOT NULL
+	) PRIMARY KEY (namespace, id)`
+
+	backfillNamespaceCounters = `INSERT INTO namespace_relationship_counters (namespace, id, count)
+		SELECT namespace, b'\x00\x00', COUNT(*) FROM relation_tuple GROUP BY namespace`
+)
+
+func init() {
+	if err := SpannerMigrations.Register("add-namespace-counters", "add-metadata-and-counters", func(smd SpannerMigrationDriver) error {
+		ctx := context.Background()
+
+		updateOp, err := smd.adminClient.UpdateDatabaseDdl(ctx, &database.UpdateDatabaseDdlRequest{
+			Database: smd.client.DatabaseName(),
+			Statements: []string{
+				createNamespaceCounters,
+			},
+		})
+		if err != nil {
+			return err
+		}
+
+		if err := updateOp.Wait(ctx); err != nil {
+			return err
+		}
+
+		if _, err := smd.client.ReadWriteTransaction(ctx, func(ctx context.Context, rwt *spanner.ReadWriteTransaction) error {
+			_, err := rwt.Update(ctx, spanner.Statement{SQL: backfillNamespaceCounters})
+			return err
+		}); err != nil {
+			return err
+		}
+
+		return nil
+	}); err != nil {
+		panic("failed to register migration: " + err.Error())
+	}
+}
@@ -79,6 +79,32 @@ func (sr spannerReader) ReverseQueryRelationships(
 	)
 }
 
+func (sr spannerReader) CountRelationships(ctx context.Context, filter *v1.RelationshipFilter) (uint64, error) {
+	ctx, span := tracer.Start(ctx, "CountRelationships")
+	defer span.End()
+
+	qBuilder := common.NewSchemaQueryFilterer(schema, countTuples).
+		FilterToRelationshipFilter(filter)
+	span.SetAttributes(qBuilder.TracerAttributes()...)
+
+	sql, args, err := qBuilder.ToSql()
+	if err != nil {
+		return 0, fmt.Errorf(errUnableToCountTuples, err)
+	}
+
+	row, err := sr.txSource().Query(ctx, statementFromSQL(sql, args)).Next()
+	if err != nil {
+		return 0, fmt.Errorf(errUnableToCountTuples, err)
+	}
+
+	var count int64
+	if err := row.Columns(&count); err != nil {
+		return 0, fmt.Errorf(errUnableToCountTuples, err)
+	}
+
+	return uint64(count), nil
+}
+
 func queryExecutor(txSource txFactory) common.ExecuteQueryFunc {
 	return func(
 		ctx context.Context,
@@ -208,6 +234,8 @@ var queryTuples = sql.Select(
 	colUsersetRelation,
 ).From(tableRelationship)
 
+var countTuples = sql.Select("COUNT(*)").From(tableRelationship)
+
 var schema = common.SchemaInformation{
 	ColNamespace:        colNamespace,
 	ColObjectID:         colObjectID,
@@ -12,14 +12,19 @@ import (
 	"github.com/rs/zerolog/log"
 	"google.golang.org/protobuf/proto"
 
+	"github.com/authzed/spicedb/internal/datastore/common"
 	"github.com/authzed/spicedb/pkg/datastore"
 	core "github.com/authzed/spicedb/pkg/proto/core/v1"
 )
 
+// bulkLoadBatchSize is the number of relationships buffered by each write made by BulkLoad.
+const bulkLoadBatchSize = 1000
+
 type spannerReadWriteTXN struct {
 	spannerReader
-	ctx        context.Context
-	spannerRWT *spanner.ReadWriteTransaction
+	ctx             context.Context
+	spannerRWT      *spanner.ReadWriteTransaction
+	relCountChanges common.RelationshipCountChanges
 }
 
 func (rwt spannerReadWriteTXN) WriteRelationships(mutations []*v1.RelationshipUpdate) error {
@@ -28,22 +33,20 @@ func (rwt spannerReadWriteTXN) WriteRelationships(mutations []*v1.RelationshipUp
 
 	changeUUID := uuid.New().String()
 
-	var rowCountChange int64
-
 	for _, mutation := range mutations {
 		var txnMut *spanner.Mutation
 		var op int
 		switch mutation.Operation {
 		case v1.RelationshipUpdate_OPERATION_TOUCH:
-			rowCountChange++
+			rwt.relCountChanges.Add(mutation.Relationship.Resource.ObjectType, 1)
 			txnMut = spanner.InsertOrUpdate(tableRelationship, allRelationshipCols, upsertVals(mutation.Relationship))
 			op = colChangeOpTouch
 		case v1.RelationshipUpdate_OPERATION_CREATE:
-			rowCountChange++
+			rwt.relCountChanges.Add(mutation.Relationship.Resource.ObjectType, 1)
 			txnMut = spanner.Insert(tableRelationship, allRelationshipCols, upsertVals(mutation.Relationship))
 			op = colChangeOpCreate
 		case v1.RelationshipUpdate_OPERATION_DELETE:
-			rowCountChange--
+			rwt.relCountChanges.Add(mutation.Relationship.Resource.ObjectType, -1)
 			txnMut = spanner.Delete(tableRelationship, keyFromRelationship(mutation.Relationship))
 			op = colChangeOpDelete
 		default:
@@ -60,18 +63,43 @@ func (rwt spannerReadWriteTXN) WriteRelationships(mutations []*v1.RelationshipUp
 		}
 	}
 
-	if err := updateCounter(ctx, rwt.spannerRWT, rowCountChange); err != nil {
-		return fmt.Errorf(errUnableToWriteRelationships, err)
+	return nil
+}
+
+// BulkLoad creates the relationships with insert mutations, which are buffered until the
+// transaction commits and so are not checked for existing relationships until then. Spanner
+// limits the number of mutations in each commit, which bounds the number of relationships
+// that can be loaded by a single transaction.
+func (rwt spannerReadWriteTXN) BulkLoad(ctx context.Context, source datastore.BulkWriteRelationshipSource) (uint64, error) {
+	ctx, span := tracer.Start(ctx, "BulkLoad")
+	defer span.End()
+
+	changeUUID := uuid.NewString()
+
+	loaded, err := datastore.BulkLoadInBatches(ctx, source, bulkLoadBatchSize, func(batch []*v1.Relationship) error {
+		mutations := make([]*spanner.Mutation, 0, 2*len(batch))
+		for _, rel := range batch {
+			rwt.relCountChanges.Add(rel.Resource.ObjectType, 1)
+			mutations = append(mutations,
+				spanner.Insert(tableRelationship, allRelationshipCols, upsertVals(rel)),
+				spanner.Insert(tableChangelog, allChangelogCols, changeVals(changeUUID, colChangeOpCreate, rel)),
+			)
+		}
+
+		return rwt.spannerRWT.BufferWrite(mutations)
+	})
+	if err != nil {
+		return 0, fmt.Errorf(errUnableToWriteRelationships, err)
 	}
 
-	return nil
+	return loaded, nil
 }
 
 func (rwt spannerReadWriteTXN) DeleteRelationships(filter *v1.RelationshipFilter) error {
 	ctx, span := tracer.Start(rwt.ctx, "DeleteRelationships")
 	defer span.End()
 
-	err := deleteWithFilter(ctx, rwt.spannerRWT, filter)
+	err := deleteWithFilter(ctx, rwt.spannerRWT, filter, rwt.relCountChanges)
 	if err != nil {
 		return fmt.Errorf(errUnableToDeleteRelationships, err)
 	}
@@ -89,7 +117,7 @@ func (snd selectAndDelete) Where(pred interface{}, args ...interface{}) selectAn
 	return snd
 }
 
-func deleteWithFilter(ctx context.Context, rwt *spanner.ReadWriteTransaction, filter *v1.RelationshipFilter) error {
+func deleteWithFilter(ctx context.Context, rwt *spanner.ReadWriteTransaction, filter *v1.RelationshipFilter, relCountChanges common.RelationshipCountChanges) error {
 	queries := selectAndDelete{queryTuples, sql.Delete(tableRelationship)}
 
 	// Add clauses for the ResourceFilter
@@ -167,9 +195,7 @@ func deleteWithFilter(ctx context.Context, rwt *spanner.ReadWriteTransaction, fi
 		return err
 	}
 
-	if err := updateCounter(ctx, rwt, -1*numDeleted); err != nil {
-		return err
-	}
+	relCountChanges.Add(filter.ResourceType, -numDeleted)
 
 	return nil
 }
@@ -231,7 +257,7 @@ func (rwt spannerReadWriteTXN) DeleteNamespace(nsName string) error {
 
 	if err := deleteWithFilter(ctx, rwt.spannerRWT, &v1.RelationshipFilter{
 		ResourceType: nsName,
-	}); err != nil {
+	}, rwt.relCountChanges); err != nil {
 		return fmt.Errorf(errUnableToDeleteConfig, err)
 	}
 
@@ -245,4 +271,7 @@ func (rwt spannerReadWriteTXN) DeleteNamespace(nsName string) error {
 	return err
 }
 
-var _ datastore.ReadWriteTransaction = spannerReadWriteTXN{}
+var (
+	_ datastore.ReadWriteTransaction = spannerReadWriteTXN{}
+	_ datastore.BulkLoader           = spannerReadWriteTXN{}
+)
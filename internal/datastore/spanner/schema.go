@@ -37,6 +37,8 @@ const (
 	colID         = "id"
 	colCount      = "count"
 
+	tableNamespaceCounters = "namespace_relationship_counters"
+
 	colChangeOpCreate = 1
 	colChangeOpTouch  = 2
 	colChangeOpDelete = 3
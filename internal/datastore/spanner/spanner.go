@@ -37,6 +37,8 @@ const (
 	errUnableToDeleteConfig   = "unable to delete namespace config: %w"
 	errUnableToListNamespaces = "unable to list namespaces: %w"
 
+	errUnableToCountTuples = "unable to count tuples: %w"
+
 	// Spanner requires a much smaller userset batch size than other datastores because of the
 	// limitation on the maximum number of function calls.
 	// https://cloud.google.com/spanner/quotas
@@ -126,8 +128,27 @@ func (sd spannerDatastore) ReadWriteTx(
 			Executor:         queryExecutor(txSource),
 			UsersetBatchSize: usersetBatchsize,
 		}
-		rwt := spannerReadWriteTXN{spannerReader{querySplitter, txSource}, ctx, spannerRWT}
-		return fn(ctx, rwt)
+		rwt := spannerReadWriteTXN{
+			spannerReader{querySplitter, txSource},
+			ctx,
+			spannerRWT,
+			make(common.RelationshipCountChanges),
+		}
+		if err := fn(ctx, rwt); err != nil {
+			return err
+		}
+
+		if change := rwt.relCountChanges.Total(); change != 0 {
+			if err := updateCounter(ctx, spannerRWT, change); err != nil {
+				return fmt.Errorf("error updating relationship counter: %w", err)
+			}
+		}
+
+		if err := updateNamespaceCounters(ctx, spannerRWT, rwt.relCountChanges); err != nil {
+			return fmt.Errorf("error updating namespace relationship counters: %w", err)
+		}
+
+		return nil
 	})
 	if err != nil {
 		return datastore.NoRevision, err
@@ -9,10 +9,15 @@ import (
 	"github.com/rs/zerolog/log"
 	"google.golang.org/grpc/codes"
 
+	"github.com/authzed/spicedb/internal/datastore/common"
 	"github.com/authzed/spicedb/pkg/datastore"
 )
 
-var queryRelationshipEstimate = fmt.Sprintf("SELECT SUM(%s) FROM %s", colCount, tableCounters)
+var (
+	queryRelationshipEstimate = fmt.Sprintf("SELECT SUM(%s) FROM %s", colCount, tableCounters)
+
+	queryNamespaceEstimates = fmt.Sprintf("SELECT %[1]s, SUM(%[2]s) FROM %[3]s GROUP BY %[1]s", colNamespace, colCount, tableNamespaceCounters)
+)
 
 func (sd spannerDatastore) Statistics(ctx context.Context) (datastore.Stats, error) {
 	ctx, span := tracer.Start(ctx, "Statistics")
@@ -58,9 +63,25 @@ func (sd spannerDatastore) Statistics(ctx context.Context) (datastore.Stats, err
 		}
 	}
 
+	countsByType := make(map[string]uint64)
+	if err := sd.client.Single().Query(ctx, spanner.Statement{SQL: queryNamespaceEstimates}).Do(func(row *spanner.Row) error {
+		var objectType string
+		var count int64
+		if err := row.Columns(&objectType, &count); err != nil {
+			return err
+		}
+
+		if count > 0 {
+			countsByType[objectType] = uint64(count)
+		}
+		return nil
+	}); err != nil {
+		return datastore.Stats{}, fmt.Errorf("unable to read namespace relationship counts: %w", err)
+	}
+
 	return datastore.Stats{
 		UniqueID:                   uniqueID,
-		ObjectTypeStatistics:       datastore.ComputeObjectTypeStats(allNamespaces),
+		ObjectTypeStatistics:       datastore.ComputeObjectTypeStats(allNamespaces, countsByType),
 		EstimatedRelationshipCount: uint64(estimate),
 	}, nil
 }
@@ -102,3 +123,59 @@ func updateCounter(ctx context.Context, rwt *spanner.ReadWriteTransaction, chang
 
 	return nil
 }
+
+func updateNamespaceCounters(ctx context.Context, rwt *spanner.ReadWriteTransaction, changes common.RelationshipCountChanges) error {
+	objectTypes := changes.SortedObjectTypes()
+	if len(objectTypes) == 0 {
+		return nil
+	}
+
+	counterID := make([]byte, 2)
+	_, err := rand.Read(counterID)
+	if err != nil {
+		return fmt.Errorf("unable to select random counter: %w", err)
+	}
+
+	keys := make([]spanner.Key, 0, len(objectTypes))
+	for _, objectType := range objectTypes {
+		keys = append(keys, spanner.Key{objectType, counterID})
+	}
+
+	newValues := make(map[string]int64, len(objectTypes))
+	for _, objectType := range objectTypes {
+		newValues[objectType] = changes[objectType]
+	}
+
+	if err := rwt.Read(
+		ctx,
+		tableNamespaceCounters,
+		spanner.KeySetFromKeys(keys...),
+		[]string{colNamespace, colCount},
+	).Do(func(row *spanner.Row) error {
+		var objectType string
+		var currentValue int64
+		if err := row.Columns(&objectType, &currentValue); err != nil {
+			return err
+		}
+
+		newValues[objectType] += currentValue
+		return nil
+	}); err != nil {
+		return fmt.Errorf("unable to read namespace counter values: %w", err)
+	}
+
+	mutations := make([]*spanner.Mutation, 0, len(objectTypes))
+	for _, objectType := range objectTypes {
+		mutations = append(mutations, spanner.InsertOrUpdate(
+			tableNamespaceCounters,
+			[]string{colNamespace, colID, colCount},
+			[]interface{}{objectType, counterID, newValues[objectType]},
+		))
+	}
+
+	if err := rwt.BufferWrite(mutations); err != nil {
+		return fmt.Errorf("unable to buffer update to namespace counters: %w", err)
+	}
+
+	return nil
+}
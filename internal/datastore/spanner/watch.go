@@ -31,8 +31,9 @@ func (sd spannerDatastore) Watch(ctx context.Context, afterRevision datastore.Re
 
 		for {
 			var stagedUpdates []*datastore.RevisionChanges
+			var readTimestamp time.Time
 			var err error
-			stagedUpdates, currentTxn, err = sd.loadChanges(ctx, currentTxn)
+			stagedUpdates, currentTxn, readTimestamp, err = sd.loadChanges(ctx, currentTxn)
 			if err != nil {
 				if errors.Is(ctx.Err(), context.Canceled) {
 					errs <- datastore.NewWatchCanceledErr()
@@ -52,6 +53,12 @@ func (sd spannerDatastore) Watch(ctx context.Context, afterRevision datastore.Re
 				}
 			}
 
+			// Every change committed before the changes were read has now been sent.
+			if readTimestamp.After(currentTxn) {
+				currentTxn = readTimestamp
+				common.SendCheckpoint(ctx, updates, revisionFromTimestamp(readTimestamp))
+			}
+
 			// If there were no changes, sleep a bit
 			if len(stagedUpdates) == 0 {
 				sleep := time.NewTimer(watchSleep)
@@ -73,13 +80,14 @@ func (sd spannerDatastore) Watch(ctx context.Context, afterRevision datastore.Re
 func (sd spannerDatastore) loadChanges(
 	ctx context.Context,
 	afterTimestamp time.Time,
-) ([]*datastore.RevisionChanges, time.Time, error) {
+) ([]*datastore.RevisionChanges, time.Time, time.Time, error) {
 	sql, args, err := queryChanged.Where(sq.Gt{colChangeTS: afterTimestamp}).ToSql()
 	if err != nil {
-		return nil, afterTimestamp, err
+		return nil, afterTimestamp, time.Time{}, err
 	}
 
-	rows := sd.client.Single().Query(ctx, statementFromSQL(sql, args))
+	txn := sd.client.Single()
+	rows := txn.Query(ctx, statementFromSQL(sql, args))
 	stagedChanges := common.NewChanges()
 
 	newTimestamp := afterTimestamp
@@ -119,12 +127,21 @@ func (sd spannerDatastore) loadChanges(
 		return nil
 	})
 	if err != nil {
-		return nil, afterTimestamp, err
+		return nil, afterTimestamp, time.Time{}, err
+	}
+
+	// The read timestamp is only needed to checkpoint the watcher.
+	var readTimestamp time.Time
+	if datastore.WatchCheckpointsRequested(ctx) {
+		readTimestamp, err = txn.Timestamp()
+		if err != nil {
+			return nil, afterTimestamp, time.Time{}, err
+		}
 	}
 
 	changes := stagedChanges.AsRevisionChanges()
 
-	return changes, newTimestamp, nil
+	return changes, newTimestamp, readTimestamp, nil
 }
 
 func maxTime(t1 time.Time, t2 time.Time) time.Time {
@@ -0,0 +1,130 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/rs/zerolog/log"
+
+	"github.com/authzed/spicedb/pkg/datastore"
+)
+
+func (sd *Datastore) runGarbageCollector() error {
+	log.Info().Dur("interval", sd.gcInterval).Msg("garbage collection worker started for sqlite driver")
+
+	for {
+		select {
+		case <-sd.gcCtx.Done():
+			log.Info().Msg("shutting down garbage collection worker for sqlite driver")
+			return sd.gcCtx.Err()
+
+		case <-time.After(sd.gcInterval):
+			err := sd.collectGarbage()
+			if err != nil {
+				log.Warn().Err(err).Msg("error when attempting to perform garbage collection")
+			}
+		}
+	}
+}
+
+func (sd *Datastore) collectGarbage() error {
+	ctx, cancel := context.WithTimeout(context.Background(), sd.gcMaxOperationTime)
+	defer cancel()
+
+	// Ensure the database is ready.
+	ready, err := sd.IsReady(ctx)
+	if err != nil {
+		return err
+	}
+
+	if !ready {
+		log.Warn().Msg("cannot perform sqlite garbage collection: sqlite driver is not yet ready")
+		return nil
+	}
+
+	before := time.Now().Add(-1 * sd.gcWindow)
+	log.Debug().Time("before", before).Msg("running sqlite garbage collection")
+	relCount, transCount, err := sd.collectGarbageBefore(ctx, before)
+	log.Debug().Int64("relationshipsDeleted", relCount).Int64("transactionsDeleted", transCount).Msg("garbage collection completed for sqlite")
+	return err
+}
+
+func (sd *Datastore) collectGarbageBefore(ctx context.Context, before time.Time) (int64, int64, error) {
+	// Find the highest transaction ID before the GC window.
+	query, args, err := getRevision.Where(sq.Lt{colTimestamp: before.UTC().UnixNano()}).ToSql()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var value sql.NullInt64
+	err = sd.db.QueryRowContext(
+		datastore.SeparateContextWithTracing(ctx), query, args...,
+	).Scan(&value)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if !value.Valid {
+		log.Debug().Time("before", before).Msg("no stale transactions found in the datastore")
+		return 0, 0, nil
+	}
+	highest := uint64(value.Int64)
+
+	log.Trace().Uint64("highestTransactionId", highest).Msg("retrieved transaction ID for GC")
+
+	return sd.collectGarbageForTransaction(ctx, highest)
+}
+
+func (sd *Datastore) collectGarbageForTransaction(ctx context.Context, highest uint64) (int64, int64, error) {
+	// Delete any relationship rows with deleted_transaction <= the transaction ID.
+	relCount, err := sd.batchRemove(ctx, tableTuple, tableTupleHistory, tupleHistoryColumns, sq.LtOrEq{colDeletedTxn: highest})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	log.Trace().Uint64("highestTransactionId", highest).Int64("relationshipsDeleted", relCount).Msg("deleted stale relationships")
+
+	// Delete all transaction rows with ID < the transaction ID. We don't delete the transaction
+	// itself to ensure there is always at least one transaction present.
+	transactionCount, err := sd.batchRemove(ctx, tableTransaction, tableTransactionHistory, transactionHistoryColumns, sq.Lt{colID: highest})
+	if err != nil {
+		return relCount, 0, err
+	}
+
+	log.Trace().Uint64("highestTransactionId", highest).Int64("transactionsDeleted", transactionCount).Msg("deleted stale transactions")
+	return relCount, transactionCount, nil
+}
+
+// batchDelete deletes the rows matching the filter in batches, so that each batch holds
+// the database's write lock only briefly. SQLite does not support DELETE with a LIMIT
+// clause by default, so each batch is selected by a subquery.
+func (sd *Datastore) batchDelete(ctx context.Context, tableName string, filter sqlFilter) (int64, error) {
+	selectSQL, args, err := sb.Select(colID).From(tableName).Where(filter).Limit(batchDeleteSize).ToSql()
+	if err != nil {
+		return -1, err
+	}
+
+	query := fmt.Sprintf("DELETE FROM %s WHERE %s IN (%s)", tableName, colID, selectSQL)
+
+	var deletedCount int64
+	for {
+		cr, err := sd.db.ExecContext(ctx, query, args...)
+		if err != nil {
+			return deletedCount, err
+		}
+
+		rowsDeleted, err := cr.RowsAffected()
+		if err != nil {
+			return deletedCount, err
+		}
+		deletedCount += rowsDeleted
+		if rowsDeleted < batchDeleteSize {
+			break
+		}
+	}
+
+	return deletedCount, nil
+}
@@ -0,0 +1,151 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+
+	"github.com/authzed/spicedb/internal/datastore/common"
+	"github.com/authzed/spicedb/pkg/datastore"
+)
+
+const errUnableToQueryHistory = "unable to query relationship history: %w"
+
+var (
+	tupleHistoryColumns = []string{
+		colID,
+		colNamespace,
+		colObjectID,
+		colRelation,
+		colUsersetNamespace,
+		colUsersetObjectID,
+		colUsersetRelation,
+		colCreatedTxn,
+		colDeletedTxn,
+	}
+
+	transactionHistoryColumns = []string{colID, colTimestamp}
+
+	getHistoricalTransaction = sb.Select("MAX(id)").From(
+		unionWithHistory(tableTransaction, tableTransactionHistory, transactionHistoryColumns),
+	)
+
+	queryHistoricalTuples = sb.Select(
+		colNamespace,
+		colObjectID,
+		colRelation,
+		colUsersetNamespace,
+		colUsersetObjectID,
+		colUsersetRelation,
+	).From(unionWithHistory(tableTuple, tableTupleHistory, tupleHistoryColumns))
+)
+
+// unionWithHistory returns a subquery of the rows of both the table and its history table.
+func unionWithHistory(tableName, historyTableName string, columns []string) string {
+	cols := strings.Join(columns, ", ")
+	return fmt.Sprintf("(SELECT %s FROM %s UNION ALL SELECT %s FROM %s) AS %s",
+		cols, tableName, cols, historyTableName, tableName)
+}
+
+// QueryRelationshipsAsOf reads the relationships matching the filter which existed at the
+// given time, from both the relation tuple table and the rows archived by garbage
+// collection. The history is complete from the oldest transaction which was archived, or
+// has not yet been garbage collected.
+func (sd *Datastore) QueryRelationshipsAsOf(
+	ctx context.Context,
+	asOf time.Time,
+	filter *v1.RelationshipFilter,
+) (datastore.RelationshipIterator, error) {
+	if !sd.gcArchiveHistory {
+		return nil, datastore.NewHistoryNotArchivedErr()
+	}
+
+	ctx, span := tracer.Start(ctx, "QueryRelationshipsAsOf")
+	defer span.End()
+
+	query, args, err := getHistoricalTransaction.Where(sq.LtOrEq{colTimestamp: asOf.UTC().UnixNano()}).ToSql()
+	if err != nil {
+		return nil, fmt.Errorf(errUnableToQueryHistory, err)
+	}
+
+	var txnID sql.NullInt64
+	err = sd.db.QueryRowContext(datastore.SeparateContextWithTracing(ctx), query, args...).Scan(&txnID)
+	if err != nil {
+		return nil, fmt.Errorf(errUnableToQueryHistory, err)
+	}
+
+	// The initial migration creates a transaction with a timestamp of zero, so there is only
+	// no transaction if the earliest were garbage collected before archiving was enabled.
+	if !txnID.Valid {
+		return nil, datastore.NewHistoryNotRecordedErr(asOf)
+	}
+
+	qBuilder := common.NewSchemaQueryFilterer(
+		schema,
+		buildLivingObjectFilterForRevision(revisionFromTransaction(uint64(txnID.Int64)))(queryHistoricalTuples),
+	).FilterToRelationshipFilter(filter)
+
+	querySplitter := common.TupleQuerySplitter{
+		Executor:         newSQLiteExecutor(sd.db),
+		UsersetBatchSize: sd.usersetBatchSize,
+	}
+	return querySplitter.SplitAndExecuteQuery(ctx, qBuilder)
+}
+
+// batchRemove removes the rows matching the filter from the table, moving them into its
+// history table if history is archived, and deleting them otherwise.
+func (sd *Datastore) batchRemove(ctx context.Context, tableName, historyTableName string, columns []string, filter sqlFilter) (int64, error) {
+	if !sd.gcArchiveHistory {
+		return sd.batchDelete(ctx, tableName, filter)
+	}
+	return sd.batchArchive(ctx, tableName, historyTableName, columns, filter)
+}
+
+// batchArchive moves the rows matching the filter into the history table in batches. Each
+// batch is copied and deleted in a single transaction, which selects the same rows for both
+// as the batch is ordered by ID.
+func (sd *Datastore) batchArchive(ctx context.Context, tableName, historyTableName string, columns []string, filter sqlFilter) (int64, error) {
+	selectSQL, args, err := sb.Select(colID).From(tableName).Where(filter).OrderBy(colID).Limit(batchDeleteSize).ToSql()
+	if err != nil {
+		return -1, err
+	}
+
+	cols := strings.Join(columns, ", ")
+	copyQuery := fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s WHERE %s IN (%s)",
+		historyTableName, cols, cols, tableName, colID, selectSQL)
+	deleteQuery := fmt.Sprintf("DELETE FROM %s WHERE %s IN (%s)", tableName, colID, selectSQL)
+
+	var archivedCount int64
+	for {
+		var rowsArchived int64
+		if err := BeginTxFunc(ctx, sd.db, nil, func(tx *sql.Tx) error {
+			if _, err := tx.ExecContext(ctx, copyQuery, args...); err != nil {
+				return err
+			}
+
+			cr, err := tx.ExecContext(ctx, deleteQuery, args...)
+			if err != nil {
+				return err
+			}
+
+			rowsArchived, err = cr.RowsAffected()
+			return err
+		}); err != nil {
+			return archivedCount, err
+		}
+
+		archivedCount += rowsArchived
+		if rowsArchived < batchDeleteSize {
+			break
+		}
+	}
+
+	return archivedCount, nil
+}
+
+var _ datastore.HistoryReader = &Datastore{}
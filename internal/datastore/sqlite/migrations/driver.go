@@ -0,0 +1,121 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	// Registers the pure-Go "sqlite" database/sql driver.
+	_ "modernc.org/sqlite"
+)
+
+const (
+	errUnableToInstantiate = "unable to instantiate SQLiteDriver: %w"
+
+	// defaultMigrationBusyTimeout is how long migrations wait for a lock held by a running
+	// datastore before failing.
+	defaultMigrationBusyTimeout = 5 * time.Second
+
+	queryTableExists = "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?"
+)
+
+// SQLiteDriver is an implementation of migrate.Driver for SQLite
+type SQLiteDriver struct {
+	db *sql.DB
+}
+
+// OpenDB opens the SQLite database file at the given path, configuring each connection to
+// use write-ahead logging and to wait up to busyTimeout for locks held by other connections.
+//
+// The path may be a plain file path or a "file:" URI, optionally with query parameters
+// supported by the modernc.org/sqlite driver. In-memory databases are not supported, as
+// each connection in the pool would see a different database.
+func OpenDB(path string, busyTimeout time.Duration) (*sql.DB, error) {
+	base, query, _ := strings.Cut(path, "?")
+	if base == "" || base == ":memory:" || strings.Contains(query, "mode=memory") {
+		return nil, errors.New("a path to an SQLite database file is required")
+	}
+
+	params, err := url.ParseQuery(query)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SQLite database URI: %w", err)
+	}
+	params.Add("_pragma", fmt.Sprintf("busy_timeout(%d)", busyTimeout.Milliseconds()))
+	params.Add("_pragma", "journal_mode(WAL)")
+
+	return sql.Open("sqlite", base+"?"+params.Encode())
+}
+
+// NewSQLiteDriver creates a new migration driver for the SQLite database file at the given path.
+func NewSQLiteDriver(path string) (*SQLiteDriver, error) {
+	db, err := OpenDB(path, defaultMigrationBusyTimeout)
+	if err != nil {
+		return nil, fmt.Errorf(errUnableToInstantiate, err)
+	}
+
+	return NewSQLiteDriverFromDB(db), nil
+}
+
+// NewSQLiteDriverFromDB creates a new migration driver with a connection pool specified upfront.
+func NewSQLiteDriverFromDB(db *sql.DB) *SQLiteDriver {
+	return &SQLiteDriver{db}
+}
+
+// Version returns the version of the schema to which the connected database
+// has been migrated.
+func (driver *SQLiteDriver) Version() (string, error) {
+	var tableCount int
+	if err := driver.db.QueryRow(queryTableExists, tableMigrationVersion).Scan(&tableCount); err != nil {
+		return "", fmt.Errorf("unable to load driver migration revision: %w", err)
+	}
+	if tableCount == 0 {
+		return "", nil
+	}
+
+	var loaded string
+	query := fmt.Sprintf("SELECT version_num FROM %s", tableMigrationVersion)
+	if err := driver.db.QueryRow(query).Scan(&loaded); err != nil {
+		return "", fmt.Errorf("unable to load driver migration revision: %w", err)
+	}
+
+	return loaded, nil
+}
+
+// WriteVersion overwrites the value stored to track the version of the
+// database schema.
+func (driver *SQLiteDriver) WriteVersion(version, replaced string) error {
+	stmt := fmt.Sprintf("UPDATE %s SET version_num = ? WHERE version_num = ?", tableMigrationVersion)
+	result, err := driver.db.Exec(stmt, version, replaced)
+	if err != nil {
+		return fmt.Errorf("unable to update version row: %w", err)
+	}
+
+	updatedCount, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("unable to update version row: %w", err)
+	}
+	if updatedCount != 1 {
+		return fmt.Errorf("writing version update affected %d rows, should be 1", updatedCount)
+	}
+
+	return nil
+}
+
+func (driver *SQLiteDriver) Close() error {
+	return driver.db.Close()
+}
+
+// LogOnError executes the function and logs the error.
+// Useful to avoid silently ignoring errors in defer statements. Rolling back
+// a transaction which has already been committed is not considered an error.
+func LogOnError(ctx context.Context, f func() error) {
+	if err := f(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+		log.Ctx(ctx).Error().Err(err).Msg("error in deferred call")
+	}
+}
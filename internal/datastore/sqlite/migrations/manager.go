@@ -0,0 +1,12 @@
+package migrations
+
+import "github.com/authzed/spicedb/pkg/migrate"
+
+// Manager is the singleton migration manager instance for SQLite
+var Manager = migrate.NewManager()
+
+func mustRegisterMigration(version, replaces string, up interface{}) {
+	if err := Manager.Register(version, replaces, up); err != nil {
+		panic("failed to register migration: " + err.Error())
+	}
+}
@@ -0,0 +1,12 @@
+package migrations
+
+const (
+	tableMigrationVersion = "migration_version"
+	tableNamespace        = "namespace_config"
+	tableTransaction      = "relation_tuple_transaction"
+	tableTuple            = "relation_tuple"
+	tableMetadata         = "metadata"
+	tableCounter          = "relationship_counter"
+	tableTupleHistory     = "relation_tuple_history"
+	tableTxnHistory       = "relation_tuple_transaction_history"
+)
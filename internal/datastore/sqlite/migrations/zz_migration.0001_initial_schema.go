@@ -0,0 +1,101 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+var createMigrationVersion = fmt.Sprintf(`CREATE TABLE %s (
+	version_num TEXT NOT NULL
+);`, tableMigrationVersion)
+
+var insertEmptyVersion = fmt.Sprintf(`INSERT INTO %s (version_num) VALUES ('');`, tableMigrationVersion)
+
+// Transaction IDs are never reused, even once garbage collected, so that revisions only
+// ever increase. Timestamps are stored as nanoseconds since the unix epoch, in UTC.
+var createRelationTupleTransaction = fmt.Sprintf(`CREATE TABLE %s (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	timestamp INTEGER NOT NULL
+);`, tableTransaction)
+
+var createTransactionTimestampIndex = fmt.Sprintf(`CREATE INDEX ix_relation_tuple_transaction_by_timestamp
+	ON %s (timestamp);`, tableTransaction)
+
+var createNamespaceConfig = fmt.Sprintf(`CREATE TABLE %s (
+	namespace TEXT NOT NULL,
+	serialized_config BLOB NOT NULL,
+	created_transaction INTEGER NOT NULL,
+	deleted_transaction INTEGER NOT NULL DEFAULT 9223372036854775807,
+	CONSTRAINT pk_namespace_config PRIMARY KEY (namespace, created_transaction),
+	CONSTRAINT uq_namespace_living UNIQUE (namespace, deleted_transaction)
+);`, tableNamespace)
+
+var createRelationTuple = fmt.Sprintf(`CREATE TABLE %s (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	namespace TEXT NOT NULL,
+	object_id TEXT NOT NULL,
+	relation TEXT NOT NULL,
+	userset_namespace TEXT NOT NULL,
+	userset_object_id TEXT NOT NULL,
+	userset_relation TEXT NOT NULL,
+	created_transaction INTEGER NOT NULL,
+	deleted_transaction INTEGER NOT NULL DEFAULT 9223372036854775807,
+	CONSTRAINT uq_relation_tuple_namespace UNIQUE (namespace, object_id, relation, userset_namespace, userset_object_id, userset_relation, created_transaction, deleted_transaction),
+	CONSTRAINT uq_relation_tuple_living UNIQUE (namespace, object_id, relation, userset_namespace, userset_object_id, userset_relation, deleted_transaction)
+);`, tableTuple)
+
+var createReverseQueryIndex = fmt.Sprintf(`CREATE INDEX ix_relation_tuple_by_subject
+	ON %s (userset_object_id, userset_namespace, userset_relation, namespace, relation);`, tableTuple)
+
+var createReverseCheckIndex = fmt.Sprintf(`CREATE INDEX ix_relation_tuple_by_subject_relation
+	ON %s (userset_namespace, userset_relation, namespace, relation);`, tableTuple)
+
+var createGCIndex = fmt.Sprintf(`CREATE INDEX ix_relation_tuple_by_deleted_transaction
+	ON %s (deleted_transaction);`, tableTuple)
+
+var insertFirstTransaction = fmt.Sprintf(`INSERT INTO %s (timestamp) VALUES (0);`, tableTransaction)
+
+var createMetadata = fmt.Sprintf(`CREATE TABLE %s (
+	unique_id TEXT PRIMARY KEY
+);`, tableMetadata)
+
+var insertUniqueID = fmt.Sprintf(`INSERT INTO %s (unique_id) VALUES (?);`, tableMetadata)
+
+func init() {
+	mustRegisterMigration("initial", "", func(driver *SQLiteDriver) error {
+		ctx := context.Background()
+
+		tx, err := driver.db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer LogOnError(ctx, tx.Rollback)
+
+		statements := []string{
+			createMigrationVersion,
+			insertEmptyVersion,
+			createRelationTupleTransaction,
+			createTransactionTimestampIndex,
+			createNamespaceConfig,
+			createRelationTuple,
+			createReverseQueryIndex,
+			createReverseCheckIndex,
+			createGCIndex,
+			insertFirstTransaction,
+			createMetadata,
+		}
+		for _, stmt := range statements {
+			if _, err := tx.ExecContext(ctx, stmt); err != nil {
+				return fmt.Errorf("unable to run statement: %w", err)
+			}
+		}
+
+		if _, err := tx.ExecContext(ctx, insertUniqueID, uuid.NewString()); err != nil {
+			return fmt.Errorf("unable to insert unique ID: %w", err)
+		}
+
+		return tx.Commit()
+	})
+}
@@ -0,0 +1,36 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+)
+
+// SQLite serializes write transactions, so a single counter row is kept for each object type.
+var createRelationshipCounter = fmt.Sprintf(`CREATE TABLE %s (
+	namespace TEXT PRIMARY KEY,
+	count INTEGER NOT NULL
+);`, tableCounter)
+
+var backfillRelationshipCounter = fmt.Sprintf(`INSERT INTO %s (namespace, count)
+	SELECT namespace, COUNT(*) FROM %s WHERE deleted_transaction = 9223372036854775807 GROUP BY namespace;`,
+	tableCounter, tableTuple)
+
+func init() {
+	mustRegisterMigration("add-relationship-counters", "initial", func(driver *SQLiteDriver) error {
+		ctx := context.Background()
+
+		tx, err := driver.db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer LogOnError(ctx, tx.Rollback)
+
+		for _, stmt := range []string{createRelationshipCounter, backfillRelationshipCounter} {
+			if _, err := tx.ExecContext(ctx, stmt); err != nil {
+				return fmt.Errorf("unable to run statement: %w", err)
+			}
+		}
+
+		return tx.Commit()
+	})
+}
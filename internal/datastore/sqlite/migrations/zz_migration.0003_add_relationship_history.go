@@ -0,0 +1,61 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+)
+
+// The history tables hold the rows moved out of the relation tuple and transaction tables
+// by garbage collection when history is archived. Rows are only ever appended.
+var createRelationTupleHistory = fmt.Sprintf(`CREATE TABLE %s (
+	id INTEGER PRIMARY KEY,
+	namespace TEXT NOT NULL,
+	object_id TEXT NOT NULL,
+	relation TEXT NOT NULL,
+	userset_namespace TEXT NOT NULL,
+	userset_object_id TEXT NOT NULL,
+	userset_relation TEXT NOT NULL,
+	created_transaction INTEGER NOT NULL,
+	deleted_transaction INTEGER NOT NULL
+);`, tableTupleHistory)
+
+var createTupleHistoryResourceIndex = fmt.Sprintf(`CREATE INDEX ix_relation_tuple_history_by_resource
+	ON %s (namespace, object_id, relation);`, tableTupleHistory)
+
+var createTupleHistorySubjectIndex = fmt.Sprintf(`CREATE INDEX ix_relation_tuple_history_by_subject
+	ON %s (userset_object_id, userset_namespace, userset_relation);`, tableTupleHistory)
+
+var createTransactionHistory = fmt.Sprintf(`CREATE TABLE %s (
+	id INTEGER PRIMARY KEY,
+	timestamp INTEGER NOT NULL
+);`, tableTxnHistory)
+
+var createTransactionHistoryTimestampIndex = fmt.Sprintf(`CREATE INDEX ix_relation_tuple_transaction_history_by_timestamp
+	ON %s (timestamp);`, tableTxnHistory)
+
+func init() {
+	mustRegisterMigration("add-relationship-history", "add-relationship-counters", func(driver *SQLiteDriver) error {
+		ctx := context.Background()
+
+		tx, err := driver.db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer LogOnError(ctx, tx.Rollback)
+
+		statements := []string{
+			createRelationTupleHistory,
+			createTupleHistoryResourceIndex,
+			createTupleHistorySubjectIndex,
+			createTransactionHistory,
+			createTransactionHistoryTimestampIndex,
+		}
+		for _, stmt := range statements {
+			if _, err := tx.ExecContext(ctx, stmt); err != nil {
+				return fmt.Errorf("unable to run statement: %w", err)
+			}
+		}
+
+		return tx.Commit()
+	})
+}
@@ -0,0 +1,205 @@
+package sqlite
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	errQuantizationTooLarge = "revision quantization interval (%s) must be less than GC window (%s)"
+
+	defaultGarbageCollectionWindow           = 24 * time.Hour
+	defaultGarbageCollectionInterval         = time.Minute * 3
+	defaultGarbageCollectionMaxOperationTime = time.Minute
+	defaultMaxOpenConns                      = 20
+	defaultBusyTimeout                       = time.Second
+	defaultWatchBufferLength                 = 128
+	defaultUsersetBatchSize                  = 256
+	defaultQuantization                      = 5 * time.Second
+	defaultMaxRevisionStalenessPercent       = 0.1
+	defaultEnablePrometheusStats             = false
+	defaultMaxRetries                        = 8
+
+	// maxUsersetBatchSize bounds the number of usersets in a single query, as each
+	// userset adds to the depth of the query's expression tree, which SQLite limits
+	// to 1000 by default.
+	maxUsersetBatchSize = 256
+)
+
+type sqliteOptions struct {
+	revisionQuantization        time.Duration
+	gcWindow                    time.Duration
+	gcInterval                  time.Duration
+	gcMaxOperationTime          time.Duration
+	gcArchiveHistory            bool
+	maxRevisionStalenessPercent float64
+	watchBufferLength           uint16
+	enablePrometheusStats       bool
+	maxOpenConns                int
+	busyTimeout                 time.Duration
+	splitAtUsersetCount         uint16
+	maxRetries                  uint8
+}
+
+// Option provides the facility to configure how clients within the
+// SQLite datastore interact with the SQLite database file.
+type Option func(*sqliteOptions)
+
+func generateConfig(options []Option) (sqliteOptions, error) {
+	computed := sqliteOptions{
+		gcWindow:                    defaultGarbageCollectionWindow,
+		gcInterval:                  defaultGarbageCollectionInterval,
+		gcMaxOperationTime:          defaultGarbageCollectionMaxOperationTime,
+		watchBufferLength:           defaultWatchBufferLength,
+		maxOpenConns:                defaultMaxOpenConns,
+		busyTimeout:                 defaultBusyTimeout,
+		splitAtUsersetCount:         defaultUsersetBatchSize,
+		revisionQuantization:        defaultQuantization,
+		maxRevisionStalenessPercent: defaultMaxRevisionStalenessPercent,
+		enablePrometheusStats:       defaultEnablePrometheusStats,
+		maxRetries:                  defaultMaxRetries,
+	}
+
+	for _, option := range options {
+		option(&computed)
+	}
+
+	if computed.splitAtUsersetCount > maxUsersetBatchSize {
+		computed.splitAtUsersetCount = maxUsersetBatchSize
+	}
+
+	// Run any checks on the config that need to be done
+	if computed.revisionQuantization >= computed.gcWindow {
+		return computed, fmt.Errorf(
+			errQuantizationTooLarge,
+			computed.revisionQuantization,
+			computed.gcWindow,
+		)
+	}
+
+	return computed, nil
+}
+
+// WatchBufferLength is the number of entries that can be stored in the watch
+// buffer while awaiting read by the client.
+//
+// This value defaults to 128.
+func WatchBufferLength(watchBufferLength uint16) Option {
+	return func(so *sqliteOptions) {
+		so.watchBufferLength = watchBufferLength
+	}
+}
+
+// RevisionQuantization is the time bucket size to which advertised
+// revisions will be rounded.
+//
+// This value defaults to 5 seconds.
+func RevisionQuantization(quantization time.Duration) Option {
+	return func(so *sqliteOptions) {
+		so.revisionQuantization = quantization
+	}
+}
+
+// MaxRevisionStalenessPercent is the amount of time, expressed as a percentage of
+// the revision quantization window, that a previously computed rounded revision
+// can still be advertised after the next rounded revision would otherwise be ready.
+//
+// This value defaults to 0.1 (10%).
+func MaxRevisionStalenessPercent(stalenessPercent float64) Option {
+	return func(so *sqliteOptions) {
+		so.maxRevisionStalenessPercent = stalenessPercent
+	}
+}
+
+// GCWindow is the maximum age of a passed revision that will be considered
+// valid.
+//
+// This value defaults to 24 hours.
+func GCWindow(window time.Duration) Option {
+	return func(so *sqliteOptions) {
+		so.gcWindow = window
+	}
+}
+
+// GCInterval is the interval at which garbage collection will occur.
+//
+// This value defaults to 3 minutes.
+func GCInterval(interval time.Duration) Option {
+	return func(so *sqliteOptions) {
+		so.gcInterval = interval
+	}
+}
+
+// GCMaxOperationTime is the maximum operation time of a garbage collection
+// pass before it times out.
+//
+// This value defaults to 1 minute.
+func GCMaxOperationTime(time time.Duration) Option {
+	return func(so *sqliteOptions) {
+		so.gcMaxOperationTime = time
+	}
+}
+
+// GCArchiveHistory marks whether garbage collection moves the relationships and
+// transactions it removes into history tables, rather than deleting them, so that
+// the relationships of any time since archiving began can be read with
+// QueryRelationshipsAsOf. The history tables are never garbage collected.
+//
+// Archiving is disabled by default.
+func GCArchiveHistory(archive bool) Option {
+	return func(so *sqliteOptions) {
+		so.gcArchiveHistory = archive
+	}
+}
+
+// SplitAtUsersetCount is the batch size for which userset queries will be
+// split into smaller queries. Values above 256 are lowered to 256.
+//
+// This defaults to 256.
+func SplitAtUsersetCount(splitAtUsersetCount uint16) Option {
+	return func(so *sqliteOptions) {
+		so.splitAtUsersetCount = splitAtUsersetCount
+	}
+}
+
+// MaxRetries is the maximum number of times a retriable transaction will be
+// client-side retried.
+//
+// This value defaults to 8.
+func MaxRetries(maxRetries uint8) Option {
+	return func(so *sqliteOptions) {
+		so.maxRetries = maxRetries
+	}
+}
+
+// WithEnablePrometheusStats marks whether Prometheus metrics provided by Go's database/sql package
+// are enabled.
+//
+// Prometheus metrics are disabled by default.
+func WithEnablePrometheusStats(enablePrometheusStats bool) Option {
+	return func(so *sqliteOptions) {
+		so.enablePrometheusStats = enablePrometheusStats
+	}
+}
+
+// MaxOpenConns is the maximum size of the connection pool.
+// See https://pkg.go.dev/database/sql#DB.SetMaxOpenConns
+//
+// This value defaults to 20.
+func MaxOpenConns(conns int) Option {
+	return func(so *sqliteOptions) {
+		so.maxOpenConns = conns
+	}
+}
+
+// BusyTimeout is the maximum amount of time a write waits for another
+// connection's write transaction to complete before it fails and the
+// transaction is retried.
+// See https://www.sqlite.org/pragma.html#pragma_busy_timeout
+//
+// This value defaults to 1 second.
+func BusyTimeout(timeout time.Duration) Option {
+	return func(so *sqliteOptions) {
+		so.busyTimeout = timeout
+	}
+}
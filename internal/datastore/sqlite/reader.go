@@ -0,0 +1,245 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	sq "github.com/Masterminds/squirrel"
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/authzed/spicedb/internal/datastore/common"
+	"github.com/authzed/spicedb/internal/datastore/options"
+	"github.com/authzed/spicedb/internal/datastore/sqlite/migrations"
+	"github.com/authzed/spicedb/pkg/datastore"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+)
+
+type txCleanupFunc func() error
+
+type txFactory func(context.Context) (*sql.Tx, txCleanupFunc, error)
+
+type sqliteReader struct {
+	txSource      txFactory
+	querySplitter common.TupleQuerySplitter
+	filterer      queryFilterer
+}
+
+type queryFilterer func(original sq.SelectBuilder) sq.SelectBuilder
+
+const (
+	errUnableToReadConfig     = "unable to read namespace config: %w"
+	errUnableToListNamespaces = "unable to list namespaces: %w"
+	errUnableToQueryTuples    = "unable to query tuples: %w"
+	errUnableToCountTuples    = "unable to count tuples: %w"
+)
+
+var (
+	queryTuples = sb.Select(
+		colNamespace,
+		colObjectID,
+		colRelation,
+		colUsersetNamespace,
+		colUsersetObjectID,
+		colUsersetRelation,
+	).From(tableTuple)
+
+	countTuples = sb.Select("COUNT(*)").From(tableTuple)
+
+	readNamespace = sb.Select(colConfig, colCreatedTxn).From(tableNamespace)
+)
+
+var schema = common.SchemaInformation{
+	ColNamespace:        colNamespace,
+	ColObjectID:         colObjectID,
+	ColRelation:         colRelation,
+	ColUsersetNamespace: colUsersetNamespace,
+	ColUsersetObjectID:  colUsersetObjectID,
+	ColUsersetRelation:  colUsersetRelation,
+}
+
+func (sr *sqliteReader) QueryRelationships(
+	ctx context.Context,
+	filter *v1.RelationshipFilter,
+	opts ...options.QueryOptionsOption,
+) (iter datastore.RelationshipIterator, err error) {
+	qBuilder := common.NewSchemaQueryFilterer(schema, sr.filterer(queryTuples)).
+		FilterToResourceType(filter.ResourceType)
+
+	if filter.OptionalResourceId != "" {
+		qBuilder = qBuilder.FilterToResourceID(filter.OptionalResourceId)
+	}
+
+	if filter.OptionalRelation != "" {
+		qBuilder = qBuilder.FilterToRelation(filter.OptionalRelation)
+	}
+
+	if filter.OptionalSubjectFilter != nil {
+		qBuilder = qBuilder.FilterToSubjectFilter(filter.OptionalSubjectFilter)
+	}
+
+	return sr.querySplitter.SplitAndExecuteQuery(ctx, qBuilder, opts...)
+}
+
+func (sr *sqliteReader) ReverseQueryRelationships(
+	ctx context.Context,
+	subjectFilter *v1.SubjectFilter,
+	opts ...options.ReverseQueryOptionsOption,
+) (iter datastore.RelationshipIterator, err error) {
+	qBuilder := common.NewSchemaQueryFilterer(schema, sr.filterer(queryTuples)).
+		FilterToSubjectFilter(subjectFilter)
+
+	queryOpts := options.NewReverseQueryOptionsWithOptions(opts...)
+
+	if queryOpts.ResRelation != nil {
+		qBuilder = qBuilder.
+			FilterToResourceType(queryOpts.ResRelation.Namespace).
+			FilterToRelation(queryOpts.ResRelation.Relation)
+	}
+
+	return sr.querySplitter.SplitAndExecuteQuery(
+		ctx,
+		qBuilder,
+		options.WithLimit(queryOpts.ReverseLimit),
+	)
+}
+
+func (sr *sqliteReader) ReadNamespace(ctx context.Context, nsName string) (*core.NamespaceDefinition, datastore.Revision, error) {
+	ctx, span := tracer.Start(ctx, "ReadNamespace", trace.WithAttributes(
+		attribute.String("name", nsName),
+	))
+	defer span.End()
+
+	tx, txCleanup, err := sr.txSource(ctx)
+	if err != nil {
+		return nil, datastore.NoRevision, fmt.Errorf(errUnableToReadConfig, err)
+	}
+	defer migrations.LogOnError(ctx, txCleanup)
+
+	loaded, version, err := loadNamespace(ctx, nsName, tx, sr.filterer(readNamespace))
+	switch {
+	case errors.As(err, &datastore.ErrNamespaceNotFound{}):
+		return nil, datastore.NoRevision, err
+	case err == nil:
+		return loaded, version, nil
+	default:
+		return nil, datastore.NoRevision, fmt.Errorf(errUnableToReadConfig, err)
+	}
+}
+
+func loadNamespace(ctx context.Context, namespace string, tx *sql.Tx, baseQuery sq.SelectBuilder) (*core.NamespaceDefinition, datastore.Revision, error) {
+	ctx = datastore.SeparateContextWithTracing(ctx)
+
+	ctx, span := tracer.Start(ctx, "loadNamespace")
+	defer span.End()
+
+	query, args, err := baseQuery.Where(sq.Eq{colNamespace: namespace}).ToSql()
+	if err != nil {
+		return nil, datastore.NoRevision, err
+	}
+
+	var config []byte
+	var version datastore.Revision
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&config, &version)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = datastore.NewNamespaceNotFoundErr(namespace)
+		}
+		return nil, datastore.NoRevision, err
+	}
+
+	loaded := &core.NamespaceDefinition{}
+	err = proto.Unmarshal(config, loaded)
+	if err != nil {
+		return nil, datastore.NoRevision, err
+	}
+
+	return loaded, version, nil
+}
+
+func (sr *sqliteReader) ListNamespaces(ctx context.Context) ([]*core.NamespaceDefinition, error) {
+	ctx = datastore.SeparateContextWithTracing(ctx)
+
+	tx, txCleanup, err := sr.txSource(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer migrations.LogOnError(ctx, txCleanup)
+
+	query := sr.filterer(readNamespace)
+
+	nsDefs, err := loadAllNamespaces(ctx, tx, query)
+	if err != nil {
+		return nil, fmt.Errorf(errUnableToListNamespaces, err)
+	}
+
+	return nsDefs, err
+}
+
+func loadAllNamespaces(ctx context.Context, tx *sql.Tx, queryBuilder sq.SelectBuilder) ([]*core.NamespaceDefinition, error) {
+	query, args, err := queryBuilder.ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	var nsDefs []*core.NamespaceDefinition
+
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer migrations.LogOnError(ctx, rows.Close)
+
+	for rows.Next() {
+		var config []byte
+		var version datastore.Revision
+		if err := rows.Scan(&config, &version); err != nil {
+			return nil, err
+		}
+
+		var loaded core.NamespaceDefinition
+		if err := proto.Unmarshal(config, &loaded); err != nil {
+			return nil, fmt.Errorf(errUnableToReadConfig, err)
+		}
+
+		nsDefs = append(nsDefs, &loaded)
+	}
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+
+	return nsDefs, nil
+}
+
+func (sr *sqliteReader) CountRelationships(ctx context.Context, filter *v1.RelationshipFilter) (uint64, error) {
+	ctx, span := tracer.Start(ctx, "CountRelationships")
+	defer span.End()
+
+	qBuilder := common.NewSchemaQueryFilterer(schema, sr.filterer(countTuples)).
+		FilterToRelationshipFilter(filter)
+	span.SetAttributes(qBuilder.TracerAttributes()...)
+
+	query, args, err := qBuilder.ToSql()
+	if err != nil {
+		return 0, fmt.Errorf(errUnableToCountTuples, err)
+	}
+
+	tx, txCleanup, err := sr.txSource(ctx)
+	if err != nil {
+		return 0, fmt.Errorf(errUnableToCountTuples, err)
+	}
+	defer migrations.LogOnError(ctx, txCleanup)
+
+	var count uint64
+	if err := tx.QueryRowContext(ctx, query, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf(errUnableToCountTuples, err)
+	}
+
+	return count, nil
+}
+
+var _ datastore.Reader = &sqliteReader{}
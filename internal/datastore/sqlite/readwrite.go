@@ -0,0 +1,369 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	sq "github.com/Masterminds/squirrel"
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/jzelinskie/stringz"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/authzed/spicedb/internal/datastore/common"
+	"github.com/authzed/spicedb/pkg/datastore"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+)
+
+const (
+	errUnableToWriteRelationships  = "unable to write relationships: %w"
+	errUnableToDeleteRelationships = "unable to delete relationships: %w"
+	errUnableToWriteConfig         = "unable to write namespace config: %w"
+	errUnableToDeleteConfig        = "unable to delete namespace config: %w"
+
+	// writeBatchSize is the maximum number of relationships written or deleted by a single
+	// statement, which keeps statements within SQLite's limits on the number of bound
+	// parameters and the depth of expressions.
+	writeBatchSize = 100
+)
+
+var (
+	writeNamespace = sb.Insert(tableNamespace).Columns(
+		colNamespace,
+		colConfig,
+		colCreatedTxn,
+	)
+
+	deleteNamespace = sb.Update(tableNamespace).Where(sq.Eq{colDeletedTxn: liveDeletedTxnID})
+
+	deleteNamespaceTuples = sb.Update(tableTuple).Where(sq.Eq{colDeletedTxn: liveDeletedTxnID})
+
+	writeTuple = sb.Insert(tableTuple).Columns(
+		colNamespace,
+		colObjectID,
+		colRelation,
+		colUsersetNamespace,
+		colUsersetObjectID,
+		colUsersetRelation,
+		colCreatedTxn,
+	)
+
+	deleteTuple = sb.Update(tableTuple).Where(sq.Eq{colDeletedTxn: liveDeletedTxnID})
+
+	upsertCounter = sb.Insert(tableCounter).Columns(
+		colNamespace,
+		colCount,
+	).Suffix(fmt.Sprintf("ON CONFLICT (%[1]s) DO UPDATE SET %[2]s = %[2]s + excluded.%[2]s", colNamespace, colCount))
+)
+
+type sqliteReadWriteTXN struct {
+	*sqliteReader
+
+	ctx             context.Context
+	tx              *sql.Tx
+	newTxnID        uint64
+	relCountChanges common.RelationshipCountChanges
+}
+
+// transactionID returns the ID of the transaction in which changes are written, creating
+// the transaction on first use. Creating the transaction is the first write, and so waits
+// for any other write transaction to complete.
+func (rwt *sqliteReadWriteTXN) transactionID() (uint64, error) {
+	if rwt.newTxnID == 0 {
+		newTxnID, err := createNewTransaction(rwt.ctx, rwt.tx)
+		if err != nil {
+			return 0, fmt.Errorf("unable to create new txn ID: %w", err)
+		}
+		rwt.newTxnID = newTxnID
+	}
+	return rwt.newTxnID, nil
+}
+
+// WriteRelationships takes a list of existing relationships that must exist, and a list of
+// tuple mutations and applies it to the datastore for the specified namespace.
+func (rwt *sqliteReadWriteTXN) WriteRelationships(mutations []*v1.RelationshipUpdate) error {
+	ctx, span := tracer.Start(datastore.SeparateContextWithTracing(rwt.ctx), "WriteTuples")
+	defer span.End()
+
+	newTxnID, err := rwt.transactionID()
+	if err != nil {
+		return fmt.Errorf(errUnableToWriteRelationships, err)
+	}
+
+	for start := 0; start < len(mutations); start += writeBatchSize {
+		end := start + writeBatchSize
+		if end > len(mutations) {
+			end = len(mutations)
+		}
+
+		bulkWrite := writeTuple
+		bulkWriteHasValues := false
+
+		// Deletes are made separately for each object type, so that the number of
+		// relationships deleted of each type is known.
+		deleteClauses := make(map[string]sq.Or)
+
+		// Process the actual updates
+		for _, mut := range mutations[start:end] {
+			rel := mut.Relationship
+
+			if mut.Operation == v1.RelationshipUpdate_OPERATION_TOUCH || mut.Operation == v1.RelationshipUpdate_OPERATION_DELETE {
+				objectType := rel.Resource.ObjectType
+				deleteClauses[objectType] = append(deleteClauses[objectType], exactRelationshipClause(rel))
+			}
+
+			if mut.Operation == v1.RelationshipUpdate_OPERATION_TOUCH || mut.Operation == v1.RelationshipUpdate_OPERATION_CREATE {
+				rwt.relCountChanges.Add(rel.Resource.ObjectType, 1)
+				bulkWrite = bulkWrite.Values(
+					rel.Resource.ObjectType,
+					rel.Resource.ObjectId,
+					rel.Relation,
+					rel.Subject.Object.ObjectType,
+					rel.Subject.Object.ObjectId,
+					stringz.DefaultEmpty(rel.Subject.OptionalRelation, datastore.Ellipsis),
+					newTxnID,
+				)
+				bulkWriteHasValues = true
+			}
+		}
+
+		for objectType, clauses := range deleteClauses {
+			query, args, err := deleteTuple.Where(clauses).Set(colDeletedTxn, newTxnID).ToSql()
+			if err != nil {
+				return fmt.Errorf(errUnableToWriteRelationships, err)
+			}
+
+			result, err := rwt.tx.ExecContext(ctx, query, args...)
+			if err != nil {
+				return fmt.Errorf(errUnableToWriteRelationships, err)
+			}
+
+			if err := rwt.recordDeleted(objectType, result); err != nil {
+				return fmt.Errorf(errUnableToWriteRelationships, err)
+			}
+		}
+
+		if bulkWriteHasValues {
+			query, args, err := bulkWrite.ToSql()
+			if err != nil {
+				return fmt.Errorf(errUnableToWriteRelationships, err)
+			}
+
+			if _, err := rwt.tx.ExecContext(ctx, query, args...); err != nil {
+				return fmt.Errorf(errUnableToWriteRelationships, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func exactRelationshipClause(r *v1.Relationship) sq.Eq {
+	return sq.Eq{
+		colNamespace:        r.Resource.ObjectType,
+		colObjectID:         r.Resource.ObjectId,
+		colRelation:         r.Relation,
+		colUsersetNamespace: r.Subject.Object.ObjectType,
+		colUsersetObjectID:  r.Subject.Object.ObjectId,
+		colUsersetRelation:  stringz.DefaultEmpty(r.Subject.OptionalRelation, datastore.Ellipsis),
+	}
+}
+
+func (rwt *sqliteReadWriteTXN) DeleteRelationships(filter *v1.RelationshipFilter) error {
+	ctx, span := tracer.Start(datastore.SeparateContextWithTracing(rwt.ctx), "DeleteRelationships")
+	defer span.End()
+
+	// Add clauses for the ResourceFilter
+	query := deleteTuple.Where(sq.Eq{colNamespace: filter.ResourceType})
+	tracerAttributes := []attribute.KeyValue{common.ObjNamespaceNameKey.String(filter.ResourceType)}
+	if filter.OptionalResourceId != "" {
+		query = query.Where(sq.Eq{colObjectID: filter.OptionalResourceId})
+		tracerAttributes = append(tracerAttributes, common.ObjIDKey.String(filter.OptionalResourceId))
+	}
+	if filter.OptionalRelation != "" {
+		query = query.Where(sq.Eq{colRelation: filter.OptionalRelation})
+		tracerAttributes = append(tracerAttributes, common.ObjRelationNameKey.String(filter.OptionalRelation))
+	}
+
+	// Add clauses for the SubjectFilter
+	if subjectFilter := filter.OptionalSubjectFilter; subjectFilter != nil {
+		query = query.Where(sq.Eq{colUsersetNamespace: subjectFilter.SubjectType})
+		tracerAttributes = append(tracerAttributes, common.SubNamespaceNameKey.String(subjectFilter.SubjectType))
+		if subjectFilter.OptionalSubjectId != "" {
+			query = query.Where(sq.Eq{colUsersetObjectID: subjectFilter.OptionalSubjectId})
+			tracerAttributes = append(tracerAttributes, common.SubObjectIDKey.String(subjectFilter.OptionalSubjectId))
+		}
+		if relationFilter := subjectFilter.OptionalRelation; relationFilter != nil {
+			query = query.Where(sq.Eq{colUsersetRelation: stringz.DefaultEmpty(relationFilter.Relation, datastore.Ellipsis)})
+			tracerAttributes = append(tracerAttributes, common.SubRelationNameKey.String(relationFilter.Relation))
+		}
+	}
+
+	span.SetAttributes(tracerAttributes...)
+
+	newTxnID, err := rwt.transactionID()
+	if err != nil {
+		return fmt.Errorf(errUnableToDeleteRelationships, err)
+	}
+
+	querySQL, args, err := query.Set(colDeletedTxn, newTxnID).ToSql()
+	if err != nil {
+		return fmt.Errorf(errUnableToDeleteRelationships, err)
+	}
+
+	result, err := rwt.tx.ExecContext(ctx, querySQL, args...)
+	if err != nil {
+		return fmt.Errorf(errUnableToDeleteRelationships, err)
+	}
+
+	if err := rwt.recordDeleted(filter.ResourceType, result); err != nil {
+		return fmt.Errorf(errUnableToDeleteRelationships, err)
+	}
+
+	return nil
+}
+
+func (rwt *sqliteReadWriteTXN) WriteNamespaces(newNamespaces ...*core.NamespaceDefinition) error {
+	ctx, span := tracer.Start(datastore.SeparateContextWithTracing(rwt.ctx), "WriteNamespaces")
+	defer span.End()
+
+	newTxnID, err := rwt.transactionID()
+	if err != nil {
+		return fmt.Errorf(errUnableToWriteConfig, err)
+	}
+
+	deletedNamespaceClause := sq.Or{}
+	writeQuery := writeNamespace
+
+	writtenNamespaceNames := make([]string, 0, len(newNamespaces))
+	for _, newNamespace := range newNamespaces {
+		serialized, err := proto.Marshal(newNamespace)
+		if err != nil {
+			return fmt.Errorf(errUnableToWriteConfig, err)
+		}
+		span.AddEvent("Serialized namespace config")
+
+		deletedNamespaceClause = append(deletedNamespaceClause, sq.Eq{colNamespace: newNamespace.Name})
+		writeQuery = writeQuery.Values(newNamespace.Name, serialized, newTxnID)
+		writtenNamespaceNames = append(writtenNamespaceNames, newNamespace.Name)
+	}
+
+	span.SetAttributes(common.ObjNamespaceNameKey.StringSlice(writtenNamespaceNames))
+
+	delSQL, delArgs, err := deleteNamespace.
+		Set(colDeletedTxn, newTxnID).
+		Where(sq.And{sq.Eq{colDeletedTxn: liveDeletedTxnID}, deletedNamespaceClause}).
+		ToSql()
+	if err != nil {
+		return fmt.Errorf(errUnableToWriteConfig, err)
+	}
+
+	if _, err := rwt.tx.ExecContext(ctx, delSQL, delArgs...); err != nil {
+		return fmt.Errorf(errUnableToWriteConfig, err)
+	}
+
+	query, args, err := writeQuery.ToSql()
+	if err != nil {
+		return fmt.Errorf(errUnableToWriteConfig, err)
+	}
+
+	if _, err := rwt.tx.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf(errUnableToWriteConfig, err)
+	}
+	span.AddEvent("Namespace config written")
+
+	return nil
+}
+
+func (rwt *sqliteReadWriteTXN) DeleteNamespace(nsName string) error {
+	ctx, span := tracer.Start(rwt.ctx, "DeleteNamespace", trace.WithAttributes(
+		attribute.String("name", nsName),
+	))
+	defer span.End()
+	ctx = datastore.SeparateContextWithTracing(ctx)
+
+	baseQuery := readNamespace.Where(sq.Eq{colDeletedTxn: liveDeletedTxnID})
+	_, createdAt, err := loadNamespace(ctx, nsName, rwt.tx, baseQuery)
+	switch {
+	case errors.As(err, &datastore.ErrNamespaceNotFound{}):
+		return err
+	case err == nil:
+		break
+	default:
+		return fmt.Errorf(errUnableToDeleteConfig, err)
+	}
+
+	newTxnID, err := rwt.transactionID()
+	if err != nil {
+		return fmt.Errorf(errUnableToDeleteConfig, err)
+	}
+
+	delSQL, delArgs, err := deleteNamespace.
+		Set(colDeletedTxn, newTxnID).
+		Where(sq.Eq{colNamespace: nsName, colCreatedTxn: transactionFromRevision(createdAt)}).
+		ToSql()
+	if err != nil {
+		return fmt.Errorf(errUnableToDeleteConfig, err)
+	}
+
+	if _, err := rwt.tx.ExecContext(ctx, delSQL, delArgs...); err != nil {
+		return fmt.Errorf(errUnableToDeleteConfig, err)
+	}
+
+	deleteTupleSQL, deleteTupleArgs, err := deleteNamespaceTuples.
+		Set(colDeletedTxn, newTxnID).
+		Where(sq.Eq{colNamespace: nsName}).
+		ToSql()
+	if err != nil {
+		return fmt.Errorf(errUnableToDeleteConfig, err)
+	}
+
+	result, err := rwt.tx.ExecContext(ctx, deleteTupleSQL, deleteTupleArgs...)
+	if err != nil {
+		return fmt.Errorf(errUnableToDeleteConfig, err)
+	}
+
+	if err := rwt.recordDeleted(nsName, result); err != nil {
+		return fmt.Errorf(errUnableToDeleteConfig, err)
+	}
+
+	return nil
+}
+
+// recordDeleted records the relationships of the object type deleted by the statement.
+func (rwt *sqliteReadWriteTXN) recordDeleted(objectType string, result sql.Result) error {
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	rwt.relCountChanges.Add(objectType, -deleted)
+	return nil
+}
+
+// updateCounters applies the changes in the number of relationships of each object type
+// to the relationship counters.
+func (rwt *sqliteReadWriteTXN) updateCounters(ctx context.Context) error {
+	objectTypes := rwt.relCountChanges.SortedObjectTypes()
+	if len(objectTypes) == 0 {
+		return nil
+	}
+
+	query := upsertCounter
+	for _, objectType := range objectTypes {
+		query = query.Values(objectType, rwt.relCountChanges[objectType])
+	}
+
+	upsertSQL, args, err := query.ToSql()
+	if err != nil {
+		return err
+	}
+
+	_, err = rwt.tx.ExecContext(ctx, upsertSQL, args...)
+	return err
+}
+
+var _ datastore.ReadWriteTransaction = &sqliteReadWriteTXN{}
@@ -0,0 +1,144 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/authzed/spicedb/pkg/datastore"
+)
+
+const (
+	errRevision      = "unable to find revision: %w"
+	errCheckRevision = "unable to check revision: %w"
+)
+
+var (
+	// querySelectRevision finds the first transaction at or after the given timestamp,
+	// which is the current time rounded down to the nearest quantization period. If there
+	// are no transactions newer than the quantization period, it just picks the latest
+	// transaction.
+	querySelectRevision = fmt.Sprintf(`
+	SELECT COALESCE(
+		(SELECT MIN(%[1]s) FROM %[2]s WHERE %[3]s >= ?),
+		(SELECT MAX(%[1]s) FROM %[2]s)
+	);`, colID, tableTransaction, colTimestamp)
+
+	// queryValidTransaction will return a single row with two values, one boolean
+	// for whether the specified transaction ID is newer than the given start of the
+	// garbage collection window, and one boolean for whether the transaction ID
+	// represents a transaction that will occur in the future.
+	queryValidTransaction = fmt.Sprintf(`
+	SELECT ? >= (
+		SELECT MIN(%[1]s) FROM %[2]s WHERE %[3]s >= ?
+	) as fresh, ? > (
+		SELECT MAX(%[1]s) FROM %[2]s
+	) as future;`, colID, tableTransaction, colTimestamp)
+)
+
+// now returns the current time as stored in transaction timestamps. As SQLite runs
+// in-process, the local clock serves as the database's clock.
+func now() int64 {
+	return time.Now().UTC().UnixNano()
+}
+
+func (sd *Datastore) optimizedRevisionFunc(ctx context.Context) (datastore.Revision, time.Duration, error) {
+	nowNanos := now()
+	sinceQuantized := nowNanos % sd.quantizationPeriodNanos
+	validForNanos := time.Duration(sd.quantizationPeriodNanos - sinceQuantized)
+
+	var revision uint64
+	if err := sd.db.QueryRowContext(
+		datastore.SeparateContextWithTracing(ctx), querySelectRevision, nowNanos-sinceQuantized,
+	).Scan(&revision); err != nil {
+		return datastore.NoRevision, 0, fmt.Errorf(errRevision, err)
+	}
+	return revisionFromTransaction(revision), validForNanos, nil
+}
+
+func (sd *Datastore) HeadRevision(ctx context.Context) (datastore.Revision, error) {
+	ctx, span := tracer.Start(ctx, "HeadRevision")
+	defer span.End()
+
+	revision, err := sd.loadRevision(ctx)
+	if err != nil {
+		return datastore.NoRevision, err
+	}
+
+	return revisionFromTransaction(revision), nil
+}
+
+func (sd *Datastore) CheckRevision(ctx context.Context, revision datastore.Revision) error {
+	ctx, span := tracer.Start(ctx, "CheckRevision")
+	defer span.End()
+
+	revisionTx := transactionFromRevision(revision)
+	gcWindowStart := now() - sd.gcWindow.Nanoseconds()
+
+	var freshEnough, future sql.NullBool
+	if err := sd.db.QueryRowContext(
+		datastore.SeparateContextWithTracing(ctx), queryValidTransaction, revisionTx, gcWindowStart, revisionTx,
+	).Scan(&freshEnough, &future); err != nil {
+		return fmt.Errorf(errCheckRevision, err)
+	}
+
+	if !freshEnough.Bool {
+		return datastore.NewInvalidRevisionErr(revision, datastore.RevisionStale)
+	}
+	if future.Bool {
+		return datastore.NewInvalidRevisionErr(revision, datastore.RevisionInFuture)
+	}
+
+	return nil
+}
+
+func (sd *Datastore) loadRevision(ctx context.Context) (uint64, error) {
+	ctx, span := tracer.Start(ctx, "loadRevision")
+	defer span.End()
+
+	query, args, err := getRevision.ToSql()
+	if err != nil {
+		return 0, fmt.Errorf(errRevision, err)
+	}
+
+	var revision sql.NullInt64
+	err = sd.db.QueryRowContext(datastore.SeparateContextWithTracing(ctx), query, args...).Scan(&revision)
+	if err != nil {
+		return 0, fmt.Errorf(errRevision, err)
+	}
+
+	return uint64(revision.Int64), nil
+}
+
+func createNewTransaction(ctx context.Context, tx *sql.Tx) (uint64, error) {
+	ctx, span := tracer.Start(ctx, "createNewTransaction")
+	defer span.End()
+
+	query, args, err := createTxn.Values(now()).ToSql()
+	if err != nil {
+		return 0, fmt.Errorf("createNewTransaction: %w", err)
+	}
+
+	result, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("createNewTransaction: %w", err)
+	}
+
+	lastInsertID, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("createNewTransaction: failed to get last inserted id: %w", err)
+	}
+
+	return uint64(lastInsertID), nil
+}
+
+func revisionFromTransaction(txID uint64) datastore.Revision {
+	return decimal.NewFromInt(int64(txID))
+}
+
+func transactionFromRevision(revision datastore.Revision) uint64 {
+	return uint64(revision.IntPart())
+}
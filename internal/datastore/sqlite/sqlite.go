@@ -0,0 +1,365 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/dlmiddlecote/sqlstats"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/sync/errgroup"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+
+	"github.com/authzed/spicedb/internal/datastore/common"
+	"github.com/authzed/spicedb/internal/datastore/common/revisions"
+	"github.com/authzed/spicedb/internal/datastore/sqlite/migrations"
+	"github.com/authzed/spicedb/pkg/datastore"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+)
+
+func init() {
+	datastore.Engines = append(datastore.Engines, Engine)
+}
+
+const (
+	Engine           = "sqlite"
+	tableNamespace   = "namespace_config"
+	tableTransaction = "relation_tuple_transaction"
+	tableTuple       = "relation_tuple"
+	tableMetadata    = "metadata"
+	tableCounter     = "relationship_counter"
+
+	tableTupleHistory       = "relation_tuple_history"
+	tableTransactionHistory = "relation_tuple_transaction_history"
+
+	colID               = "id"
+	colTimestamp        = "timestamp"
+	colNamespace        = "namespace"
+	colConfig           = "serialized_config"
+	colCreatedTxn       = "created_transaction"
+	colDeletedTxn       = "deleted_transaction"
+	colObjectID         = "object_id"
+	colRelation         = "relation"
+	colUsersetNamespace = "userset_namespace"
+	colUsersetObjectID  = "userset_object_id"
+	colUsersetRelation  = "userset_relation"
+	colUniqueID         = "unique_id"
+	colCount            = "count"
+
+	errUnableToInstantiate = "unable to instantiate datastore: %w"
+
+	// This is the largest positive integer possible in sqlite
+	liveDeletedTxnID = uint64(math.MaxInt64)
+
+	batchDeleteSize = 1000
+)
+
+var (
+	sb = sq.StatementBuilder.PlaceholderFormat(sq.Question)
+
+	getRevision = sb.Select("MAX(id)").From(tableTransaction)
+
+	createTxn = sb.Insert(tableTransaction).Columns(colTimestamp)
+
+	tracer = otel.Tracer("spicedb/internal/datastore/sqlite")
+)
+
+type sqlFilter interface {
+	ToSql() (string, []interface{}, error)
+}
+
+// NewSQLiteDatastore creates a new sqlite.Datastore value, storing its data in the SQLite
+// database file at the given path. The database file must have been migrated with the
+// migrations in the migrations package. Supports customization via the various options
+// available in this package.
+//
+// The path may be a plain file path or a "file:" URI, e.g. "file:/var/lib/spicedb/spicedb.db".
+// See https://www.sqlite.org/uri.html
+func NewSQLiteDatastore(path string, options ...Option) (*Datastore, error) {
+	config, err := generateConfig(options)
+	if err != nil {
+		return nil, fmt.Errorf(errUnableToInstantiate, err)
+	}
+
+	db, err := migrations.OpenDB(path, config.busyTimeout)
+	if err != nil {
+		return nil, fmt.Errorf(errUnableToInstantiate, err)
+	}
+
+	if config.enablePrometheusStats {
+		collector := sqlstats.NewStatsCollector("spicedb", db)
+		if err := prometheus.Register(collector); err != nil {
+			return nil, fmt.Errorf(errUnableToInstantiate, err)
+		}
+	}
+
+	db.SetMaxOpenConns(config.maxOpenConns)
+	db.SetMaxIdleConns(config.maxOpenConns)
+
+	gcCtx, cancelGc := context.WithCancel(context.Background())
+
+	maxRevisionStaleness := time.Duration(float64(config.revisionQuantization.Nanoseconds())*
+		config.maxRevisionStalenessPercent) * time.Nanosecond
+
+	quantizationPeriodNanos := config.revisionQuantization.Nanoseconds()
+	if quantizationPeriodNanos < 1 {
+		quantizationPeriodNanos = 1
+	}
+
+	store := &Datastore{
+		db:                      db,
+		driver:                  migrations.NewSQLiteDriverFromDB(db),
+		quantizationPeriodNanos: quantizationPeriodNanos,
+		gcWindow:                config.gcWindow,
+		gcInterval:              config.gcInterval,
+		gcMaxOperationTime:      config.gcMaxOperationTime,
+		gcArchiveHistory:        config.gcArchiveHistory,
+		gcCtx:                   gcCtx,
+		cancelGc:                cancelGc,
+		watchBufferLength:       config.watchBufferLength,
+		usersetBatchSize:        config.splitAtUsersetCount,
+		readTxOptions:           &sql.TxOptions{ReadOnly: true},
+		maxRetries:              config.maxRetries,
+		CachedOptimizedRevisions: revisions.NewCachedOptimizedRevisions(
+			maxRevisionStaleness,
+		),
+	}
+
+	store.SetOptimizedRevisionFunc(store.optimizedRevisionFunc)
+
+	// Start a goroutine for garbage collection.
+	if store.gcInterval > 0*time.Minute {
+		store.gcGroup, store.gcCtx = errgroup.WithContext(store.gcCtx)
+		store.gcGroup.Go(store.runGarbageCollector)
+	} else {
+		log.Warn().Msg("garbage collection disabled in sqlite driver")
+	}
+
+	return store, nil
+}
+
+// Datastore is a SQLite-based implementation of the datastore.Datastore interface
+type Datastore struct {
+	db            *sql.DB
+	driver        *migrations.SQLiteDriver
+	readTxOptions *sql.TxOptions
+
+	quantizationPeriodNanos int64
+	gcWindow                time.Duration
+	gcInterval              time.Duration
+	gcMaxOperationTime      time.Duration
+	gcArchiveHistory        bool
+	watchBufferLength       uint16
+	usersetBatchSize        uint16
+	maxRetries              uint8
+
+	gcGroup  *errgroup.Group
+	gcCtx    context.Context
+	cancelGc context.CancelFunc
+
+	*revisions.CachedOptimizedRevisions
+}
+
+func (sd *Datastore) SnapshotReader(rev datastore.Revision) datastore.Reader {
+	createTxFunc := func(ctx context.Context) (*sql.Tx, txCleanupFunc, error) {
+		tx, err := sd.db.BeginTx(ctx, sd.readTxOptions)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return tx, tx.Rollback, nil
+	}
+
+	querySplitter := common.TupleQuerySplitter{
+		Executor:         newSQLiteExecutor(sd.db),
+		UsersetBatchSize: sd.usersetBatchSize,
+	}
+
+	return &sqliteReader{
+		createTxFunc,
+		querySplitter,
+		buildLivingObjectFilterForRevision(rev),
+	}
+}
+
+func noCleanup() error { return nil }
+
+// ReadWriteTx starts a read/write transaction, which will be committed if no error is
+// returned and rolled back if an error is returned.
+//
+// SQLite allows only a single write transaction at a time. The transaction is begun as a
+// read transaction, and only becomes a write transaction when it first writes, at which
+// point it waits for any other write transaction to complete. If it cannot become a write
+// transaction, because another transaction has since written or the wait times out, the
+// write fails with a serialization error and the transaction is retried.
+func (sd *Datastore) ReadWriteTx(
+	ctx context.Context,
+	fn datastore.TxUserFunc,
+) (datastore.Revision, error) {
+	var err error
+	for i := uint8(0); i <= sd.maxRetries; i++ {
+		var newTxnID uint64
+		if err = BeginTxFunc(ctx, sd.db, nil, func(tx *sql.Tx) error {
+			longLivedTx := func(context.Context) (*sql.Tx, txCleanupFunc, error) {
+				return tx, noCleanup, nil
+			}
+
+			querySplitter := common.TupleQuerySplitter{
+				Executor:         newSQLiteExecutor(tx),
+				UsersetBatchSize: sd.usersetBatchSize,
+			}
+
+			rwt := &sqliteReadWriteTXN{
+				&sqliteReader{
+					longLivedTx,
+					querySplitter,
+					currentlyLivingObjects,
+				},
+				ctx,
+				tx,
+				0,
+				make(common.RelationshipCountChanges),
+			}
+
+			if err := fn(ctx, rwt); err != nil {
+				return err
+			}
+
+			if err := rwt.updateCounters(ctx); err != nil {
+				return fmt.Errorf("error updating relationship counters: %w", err)
+			}
+
+			// A transaction which made no changes still produces a new revision.
+			newTxnID, err = rwt.transactionID()
+			return err
+		}); err != nil {
+			if isErrorRetryable(err) {
+				continue
+			}
+
+			return datastore.NoRevision, err
+		}
+
+		return revisionFromTransaction(newTxnID), nil
+	}
+	return datastore.NoRevision, fmt.Errorf("max retries exceeded: %w", err)
+}
+
+func isErrorRetryable(err error) bool {
+	var sqliteErr *sqlite.Error
+	if !errors.As(err, &sqliteErr) {
+		log.Debug().Err(err).Msg("couldn't determine a sqlite error code")
+		return false
+	}
+
+	// Extended result codes, such as SQLITE_BUSY_SNAPSHOT, hold the primary result code in
+	// their least significant byte.
+	return sqliteErr.Code()&0xff == sqlite3.SQLITE_BUSY
+}
+
+type querier interface {
+	QueryContext(context.Context, string, ...interface{}) (*sql.Rows, error)
+}
+
+func newSQLiteExecutor(tx querier) common.ExecuteQueryFunc {
+	return func(ctx context.Context, sqlQuery string, args []interface{}) ([]*core.RelationTuple, error) {
+		ctx = datastore.SeparateContextWithTracing(ctx)
+
+		span := trace.SpanFromContext(ctx)
+
+		rows, err := tx.QueryContext(ctx, sqlQuery, args...)
+		if err != nil {
+			return nil, fmt.Errorf(errUnableToQueryTuples, err)
+		}
+		defer migrations.LogOnError(ctx, rows.Close)
+
+		span.AddEvent("Query issued to database")
+
+		var tuples []*core.RelationTuple
+		for rows.Next() {
+			nextTuple := &core.RelationTuple{
+				ObjectAndRelation: &core.ObjectAndRelation{},
+				User: &core.User{
+					UserOneof: &core.User_Userset{
+						Userset: &core.ObjectAndRelation{},
+					},
+				},
+			}
+			userset := nextTuple.User.GetUserset()
+			err := rows.Scan(
+				&nextTuple.ObjectAndRelation.Namespace,
+				&nextTuple.ObjectAndRelation.ObjectId,
+				&nextTuple.ObjectAndRelation.Relation,
+				&userset.Namespace,
+				&userset.ObjectId,
+				&userset.Relation,
+			)
+			if err != nil {
+				return nil, fmt.Errorf(errUnableToQueryTuples, err)
+			}
+
+			tuples = append(tuples, nextTuple)
+		}
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf(errUnableToQueryTuples, err)
+		}
+		span.AddEvent("Tuples loaded", trace.WithAttributes(attribute.Int("tupleCount", len(tuples))))
+		return tuples, nil
+	}
+}
+
+// IsReady returns whether the datastore is ready to accept data. The datastore is not
+// ready until its database file has been migrated to the head migration.
+func (sd *Datastore) IsReady(ctx context.Context) (bool, error) {
+	if err := sd.db.PingContext(ctx); err != nil {
+		return false, err
+	}
+
+	headMigration, err := migrations.Manager.HeadRevision()
+	if err != nil {
+		return false, fmt.Errorf("invalid head migration found for sqlite: %w", err)
+	}
+
+	version, err := sd.driver.Version()
+	if err != nil {
+		return false, err
+	}
+
+	return version == headMigration, nil
+}
+
+// Close closes the data store.
+func (sd *Datastore) Close() error {
+	sd.cancelGc()
+	if sd.gcGroup != nil {
+		if err := sd.gcGroup.Wait(); err != nil && !errors.Is(err, context.Canceled) {
+			log.Error().Err(err).Msg("error waiting for garbage collector to shutdown")
+		}
+	}
+	return sd.db.Close()
+}
+
+func buildLivingObjectFilterForRevision(revision datastore.Revision) queryFilterer {
+	return func(original sq.SelectBuilder) sq.SelectBuilder {
+		return original.Where(sq.LtOrEq{colCreatedTxn: transactionFromRevision(revision)}).
+			Where(sq.Or{
+				sq.Eq{colDeletedTxn: liveDeletedTxnID},
+				sq.Gt{colDeletedTxn: transactionFromRevision(revision)},
+			})
+	}
+}
+
+func currentlyLivingObjects(original sq.SelectBuilder) sq.SelectBuilder {
+	return original.Where(sq.Eq{colDeletedTxn: liveDeletedTxnID})
+}
+
+var _ datastore.Datastore = &Datastore{}
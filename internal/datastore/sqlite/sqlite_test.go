@@ -0,0 +1,255 @@
+package sqlite
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/spicedb/internal/datastore/sqlite/migrations"
+	"github.com/authzed/spicedb/internal/testfixtures"
+	"github.com/authzed/spicedb/pkg/datastore"
+	"github.com/authzed/spicedb/pkg/datastore/test"
+	"github.com/authzed/spicedb/pkg/migrate"
+	"github.com/authzed/spicedb/pkg/namespace"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+func newMigratedDatastore(t *testing.T, options ...Option) *Datastore {
+	path := filepath.Join(t.TempDir(), "spicedb.db")
+
+	driver, err := migrations.NewSQLiteDriver(path)
+	require.NoError(t, err)
+	require.NoError(t, migrations.Manager.Run(driver, migrate.Head, migrate.LiveRun))
+	require.NoError(t, driver.Close())
+
+	ds, err := NewSQLiteDatastore(path, options...)
+	require.NoError(t, err)
+	t.Cleanup(func() { ds.Close() })
+
+	return ds
+}
+
+func TestSQLiteDatastore(t *testing.T) {
+	test.All(t, test.DatastoreTesterFunc(func(revisionQuantization, gcWindow time.Duration, watchBufferLength uint16) (datastore.Datastore, error) {
+		return newMigratedDatastore(t,
+			RevisionQuantization(revisionQuantization),
+			GCWindow(gcWindow),
+			GCInterval(0),
+			WatchBufferLength(watchBufferLength),
+		), nil
+	}))
+
+	t.Run("WithSplit", func(t *testing.T) {
+		// Set the split at a VERY small size, to ensure any WithUsersets queries are split.
+		test.All(t, test.DatastoreTesterFunc(func(revisionQuantization, gcWindow time.Duration, watchBufferLength uint16) (datastore.Datastore, error) {
+			return newMigratedDatastore(t,
+				RevisionQuantization(revisionQuantization),
+				GCWindow(gcWindow),
+				GCInterval(0),
+				WatchBufferLength(watchBufferLength),
+				SplitAtUsersetCount(1), // 1 userset
+			), nil
+		}))
+	})
+}
+
+func TestSQLiteInMemoryRejected(t *testing.T) {
+	for _, path := range []string{"", ":memory:", "file:spicedb?mode=memory"} {
+		path := path
+		t.Run(path, func(t *testing.T) {
+			_, err := NewSQLiteDatastore(path)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestSQLiteNotReadyBeforeMigration(t *testing.T) {
+	ds, err := NewSQLiteDatastore(filepath.Join(t.TempDir(), "spicedb.db"), GCInterval(0))
+	require.NoError(t, err)
+	defer ds.Close()
+
+	ready, err := ds.IsReady(context.Background())
+	require.NoError(t, err)
+	require.False(t, ready)
+}
+
+func TestSQLiteGarbageCollection(t *testing.T) {
+	require := require.New(t)
+
+	ds := newMigratedDatastore(t,
+		RevisionQuantization(0),
+		GCWindow(1*time.Millisecond),
+		GCInterval(0),
+		WatchBufferLength(1),
+	)
+
+	ctx := context.Background()
+	ok, err := ds.IsReady(ctx)
+	require.NoError(err)
+	require.True(ok)
+
+	writtenAt, err := ds.ReadWriteTx(ctx, func(ctx context.Context, rwt datastore.ReadWriteTransaction) error {
+		// Write basic namespaces.
+		return rwt.WriteNamespaces(namespace.Namespace(
+			"resource",
+			namespace.Relation("reader", nil),
+		), namespace.Namespace("user"))
+	})
+	require.NoError(err)
+
+	// Run GC at the transaction and ensure no relationships are removed.
+	relsDeleted, _, err := ds.collectGarbageForTransaction(ctx, transactionFromRevision(writtenAt))
+	require.Equal(int64(0), relsDeleted)
+	require.NoError(err)
+
+	// Write a relationship.
+	tpl := &core.RelationTuple{
+		ObjectAndRelation: &core.ObjectAndRelation{
+			Namespace: "resource",
+			ObjectId:  "someresource",
+			Relation:  "reader",
+		},
+		User: &core.User{UserOneof: &core.User_Userset{Userset: &core.ObjectAndRelation{
+			Namespace: "user",
+			ObjectId:  "someuser",
+			Relation:  "...",
+		}}},
+	}
+	relationship := tuple.ToRelationship(tpl)
+
+	relWrittenAt, err := ds.ReadWriteTx(ctx, func(ctx context.Context, rwt datastore.ReadWriteTransaction) error {
+		return rwt.WriteRelationships([]*v1.RelationshipUpdate{{
+			Operation:    v1.RelationshipUpdate_OPERATION_CREATE,
+			Relationship: relationship,
+		}})
+	})
+	require.NoError(err)
+
+	// Run GC at the transaction and ensure no relationships are removed, but 1 transaction (the previous write namespace) is.
+	relsDeleted, transactionsDeleted, err := ds.collectGarbageForTransaction(ctx, transactionFromRevision(relWrittenAt))
+	require.Equal(int64(0), relsDeleted)
+	require.Equal(int64(1), transactionsDeleted)
+	require.NoError(err)
+
+	// Ensure the relationship is still present.
+	tRequire := testfixtures.TupleChecker{Require: require, DS: ds}
+	tRequire.TupleExists(ctx, tpl, relWrittenAt)
+
+	// Overwrite the relationship.
+	relOverwrittenAt, err := ds.ReadWriteTx(ctx, func(ctx context.Context, rwt datastore.ReadWriteTransaction) error {
+		return rwt.WriteRelationships([]*v1.RelationshipUpdate{{
+			Operation:    v1.RelationshipUpdate_OPERATION_TOUCH,
+			Relationship: relationship,
+		}})
+	})
+	require.NoError(err)
+
+	// Run GC by time and ensure the (older copy of the) relationship is removed, as well as 1 transaction (the write).
+	time.Sleep(1 * time.Millisecond)
+	relsDeleted, transactionsDeleted, err = ds.collectGarbageBefore(ctx, time.Now())
+	require.Equal(int64(1), relsDeleted)
+	require.Equal(int64(1), transactionsDeleted)
+	require.NoError(err)
+
+	// Run GC again and ensure there are no changes.
+	relsDeleted, transactionsDeleted, err = ds.collectGarbageBefore(ctx, time.Now())
+	require.Equal(int64(0), relsDeleted)
+	require.Equal(int64(0), transactionsDeleted)
+	require.NoError(err)
+
+	// Ensure the relationship is still present.
+	tRequire.TupleExists(ctx, tpl, relOverwrittenAt)
+
+	// Ensure the older revisions are no longer valid.
+	require.Error(ds.CheckRevision(ctx, relWrittenAt))
+}
+
+func TestSQLiteArchivedHistory(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	ds := newMigratedDatastore(t,
+		RevisionQuantization(0),
+		GCWindow(1*time.Millisecond),
+		GCInterval(0),
+		GCArchiveHistory(true),
+	)
+
+	write := func(op v1.RelationshipUpdate_Operation, rels ...string) time.Time {
+		_, err := ds.ReadWriteTx(ctx, func(ctx context.Context, rwt datastore.ReadWriteTransaction) error {
+			updates := make([]*v1.RelationshipUpdate, 0, len(rels))
+			for _, rel := range rels {
+				updates = append(updates, &v1.RelationshipUpdate{Operation: op, Relationship: tuple.ParseRel(rel)})
+			}
+			return rwt.WriteRelationships(updates)
+		})
+		require.NoError(err)
+
+		time.Sleep(1 * time.Millisecond)
+		return time.Now()
+	}
+
+	readAsOf := func(asOf time.Time) []string {
+		iter, err := ds.QueryRelationshipsAsOf(ctx, asOf, &v1.RelationshipFilter{ResourceType: "resource"})
+		require.NoError(err)
+		defer iter.Close()
+
+		var found []string
+		for tpl := iter.Next(); tpl != nil; tpl = iter.Next() {
+			found = append(found, tuple.String(tpl))
+		}
+		require.NoError(iter.Err())
+		return found
+	}
+
+	_, err := ds.ReadWriteTx(ctx, func(ctx context.Context, rwt datastore.ReadWriteTransaction) error {
+		return rwt.WriteNamespaces(namespace.Namespace(
+			"resource",
+			namespace.Relation("reader", nil),
+		), namespace.Namespace("user"))
+	})
+	require.NoError(err)
+	time.Sleep(1 * time.Millisecond)
+	beforeWrites := time.Now()
+
+	const alice = "resource:someresource#reader@user:alice"
+	const bob = "resource:someresource#reader@user:bob"
+	bothWritten := write(v1.RelationshipUpdate_OPERATION_CREATE, alice, bob)
+	aliceDeleted := write(v1.RelationshipUpdate_OPERATION_DELETE, alice)
+	bobTouched := write(v1.RelationshipUpdate_OPERATION_TOUCH, bob)
+
+	// Collect the deleted relationship and the replaced copy of the touched relationship,
+	// along with all but the most recent transaction.
+	relsDeleted, transactionsDeleted, err := ds.collectGarbageBefore(ctx, time.Now())
+	require.NoError(err)
+	require.Equal(int64(2), relsDeleted)
+	require.Equal(int64(4), transactionsDeleted)
+
+	require.Empty(readAsOf(beforeWrites))
+	require.ElementsMatch([]string{alice, bob}, readAsOf(bothWritten))
+	require.ElementsMatch([]string{bob}, readAsOf(aliceDeleted))
+	require.ElementsMatch([]string{bob}, readAsOf(bobTouched))
+
+	// Filters apply to the archived relationships.
+	iter, err := ds.QueryRelationshipsAsOf(ctx, bothWritten, &v1.RelationshipFilter{
+		ResourceType:          "resource",
+		OptionalSubjectFilter: &v1.SubjectFilter{SubjectType: "user", OptionalSubjectId: "alice"},
+	})
+	require.NoError(err)
+	defer iter.Close()
+	require.Equal(alice, tuple.String(iter.Next()))
+	require.Nil(iter.Next())
+	require.NoError(iter.Err())
+}
+
+func TestSQLiteHistoryNotArchived(t *testing.T) {
+	ds := newMigratedDatastore(t)
+
+	_, err := datastore.QueryRelationshipsAsOf(context.Background(), ds, time.Now(), &v1.RelationshipFilter{ResourceType: "resource"})
+	require.ErrorAs(t, err, &datastore.ErrHistoryUnavailable{})
+}
@@ -0,0 +1,96 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	sq "github.com/Masterminds/squirrel"
+
+	"github.com/authzed/spicedb/internal/datastore/sqlite/migrations"
+	"github.com/authzed/spicedb/pkg/datastore"
+)
+
+var (
+	queryUniqueID = sb.Select(colUniqueID).From(tableMetadata)
+
+	// SQLite keeps no estimate of the number of rows in a table, so the living
+	// relationships are counted instead.
+	queryRelationshipCount = sb.
+				Select("COUNT(*)").
+				From(tableTuple).
+				Where(sq.Eq{colDeletedTxn: liveDeletedTxnID})
+
+	queryRelationshipCounters = sb.Select(colNamespace, colCount).From(tableCounter)
+)
+
+func (sd *Datastore) Statistics(ctx context.Context) (datastore.Stats, error) {
+	idSQL, idArgs, err := queryUniqueID.ToSql()
+	if err != nil {
+		return datastore.Stats{}, fmt.Errorf("unable to generate query sql: %w", err)
+	}
+
+	rowCountSQL, rowCountArgs, err := queryRelationshipCount.ToSql()
+	if err != nil {
+		return datastore.Stats{}, fmt.Errorf("unable to prepare row count sql: %w", err)
+	}
+
+	tx, err := sd.db.BeginTx(ctx, sd.readTxOptions)
+	if err != nil {
+		return datastore.Stats{}, err
+	}
+	defer migrations.LogOnError(ctx, tx.Rollback)
+
+	var uniqueID string
+	if err := tx.QueryRowContext(ctx, idSQL, idArgs...).Scan(&uniqueID); err != nil {
+		return datastore.Stats{}, fmt.Errorf("unable to query unique ID: %w", err)
+	}
+
+	nsDefs, err := loadAllNamespaces(ctx, tx, readNamespace.Where(sq.Eq{colDeletedTxn: liveDeletedTxnID}))
+	if err != nil {
+		return datastore.Stats{}, fmt.Errorf("unable to load namespaces: %w", err)
+	}
+
+	var relCount uint64
+	if err := tx.QueryRowContext(ctx, rowCountSQL, rowCountArgs...).Scan(&relCount); err != nil {
+		return datastore.Stats{}, fmt.Errorf("unable to read relationship count: %w", err)
+	}
+
+	countsByType, err := readRelationshipCounters(ctx, tx)
+	if err != nil {
+		return datastore.Stats{}, fmt.Errorf("unable to read relationship counters: %w", err)
+	}
+
+	return datastore.Stats{
+		UniqueID:                   uniqueID,
+		ObjectTypeStatistics:       datastore.ComputeObjectTypeStats(nsDefs, countsByType),
+		EstimatedRelationshipCount: relCount,
+	}, nil
+}
+
+func readRelationshipCounters(ctx context.Context, tx *sql.Tx) (map[string]uint64, error) {
+	query, args, err := queryRelationshipCounters.ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer migrations.LogOnError(ctx, rows.Close)
+
+	countsByType := make(map[string]uint64)
+	for rows.Next() {
+		var objectType string
+		var count int64
+		if err := rows.Scan(&objectType, &count); err != nil {
+			return nil, err
+		}
+		if count > 0 {
+			countsByType[objectType] = uint64(count)
+		}
+	}
+
+	return countsByType, rows.Err()
+}
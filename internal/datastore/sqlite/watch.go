@@ -0,0 +1,170 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+
+	"github.com/authzed/spicedb/internal/datastore/common"
+	"github.com/authzed/spicedb/internal/datastore/sqlite/migrations"
+	"github.com/authzed/spicedb/pkg/datastore"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+)
+
+const (
+	watchSleep = 100 * time.Millisecond
+)
+
+var queryChanged = sb.Select(
+	colNamespace,
+	colObjectID,
+	colRelation,
+	colUsersetNamespace,
+	colUsersetObjectID,
+	colUsersetRelation,
+	colCreatedTxn,
+	colDeletedTxn,
+).From(tableTuple)
+
+// Watch notifies the caller about all changes to tuples.
+//
+// All events following afterRevision will be sent to the caller.
+func (sd *Datastore) Watch(ctx context.Context, afterRevision datastore.Revision) (<-chan *datastore.RevisionChanges, <-chan error) {
+	updates := make(chan *datastore.RevisionChanges, sd.watchBufferLength)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(updates)
+		defer close(errs)
+
+		currentTxn := transactionFromRevision(afterRevision)
+
+		for {
+			var stagedUpdates []*datastore.RevisionChanges
+			var err error
+			previousTxn := currentTxn
+			stagedUpdates, currentTxn, err = sd.loadChanges(ctx, currentTxn)
+			if err != nil {
+				if errors.Is(ctx.Err(), context.Canceled) {
+					errs <- datastore.NewWatchCanceledErr()
+				} else {
+					errs <- err
+				}
+				return
+			}
+
+			// Write the staged updates to the channel
+			for _, changeToWrite := range stagedUpdates {
+				select {
+				case updates <- changeToWrite:
+				default:
+					errs <- datastore.NewWatchDisconnectedErr()
+					return
+				}
+			}
+
+			if currentTxn > previousTxn {
+				common.SendCheckpoint(ctx, updates, revisionFromTransaction(currentTxn))
+			}
+
+			// If there were no changes, sleep a bit
+			if len(stagedUpdates) == 0 {
+				sleep := time.NewTimer(watchSleep)
+
+				select {
+				case <-sleep.C:
+					break
+				case <-ctx.Done():
+					errs <- datastore.NewWatchCanceledErr()
+					return
+				}
+			}
+		}
+	}()
+
+	return updates, errs
+}
+
+func (sd *Datastore) loadChanges(
+	ctx context.Context,
+	afterRevision uint64,
+) (changes []*datastore.RevisionChanges, newRevision uint64, err error) {
+	newRevision, err = sd.loadRevision(ctx)
+	if err != nil {
+		return
+	}
+
+	if newRevision == afterRevision {
+		return
+	}
+
+	sql, args, err := queryChanged.Where(sq.Or{
+		sq.And{
+			sq.Gt{colCreatedTxn: afterRevision},
+			sq.LtOrEq{colCreatedTxn: newRevision},
+		},
+		sq.And{
+			sq.Gt{colDeletedTxn: afterRevision},
+			sq.LtOrEq{colDeletedTxn: newRevision},
+		},
+	}).ToSql()
+	if err != nil {
+		return
+	}
+
+	rows, err := sd.db.QueryContext(ctx, sql, args...)
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			err = datastore.NewWatchCanceledErr()
+		}
+		return
+	}
+	defer migrations.LogOnError(ctx, rows.Close)
+
+	stagedChanges := common.NewChanges()
+
+	for rows.Next() {
+		userset := &core.ObjectAndRelation{}
+		tpl := &core.RelationTuple{
+			ObjectAndRelation: &core.ObjectAndRelation{},
+			User: &core.User{
+				UserOneof: &core.User_Userset{
+					Userset: userset,
+				},
+			},
+		}
+
+		var createdTxn uint64
+		var deletedTxn uint64
+		err = rows.Scan(
+			&tpl.ObjectAndRelation.Namespace,
+			&tpl.ObjectAndRelation.ObjectId,
+			&tpl.ObjectAndRelation.Relation,
+			&userset.Namespace,
+			&userset.ObjectId,
+			&userset.Relation,
+			&createdTxn,
+			&deletedTxn,
+		)
+		if err != nil {
+			return
+		}
+
+		if createdTxn > afterRevision && createdTxn <= newRevision {
+			stagedChanges.AddChange(ctx, revisionFromTransaction(createdTxn), tpl, core.RelationTupleUpdate_TOUCH)
+		}
+
+		if deletedTxn > afterRevision && deletedTxn <= newRevision {
+			stagedChanges.AddChange(ctx, revisionFromTransaction(deletedTxn), tpl, core.RelationTupleUpdate_DELETE)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return
+	}
+
+	changes = stagedChanges.AsRevisionChanges()
+
+	return
+}
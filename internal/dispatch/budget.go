@@ -0,0 +1,333 @@
+package dispatch
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	v1 "github.com/authzed/spicedb/pkg/proto/dispatch/v1"
+)
+
+// ErrBudgetExhausted is returned when a request has performed more dispatches or datastore
+// queries than allowed by its Budget.
+type ErrBudgetExhausted struct {
+	error
+	resource string
+	limit    uint32
+}
+
+// Resource returns the name of the resource whose budget was exhausted.
+func (err ErrBudgetExhausted) Resource() string {
+	return err.resource
+}
+
+// Limit returns the configured limit for the exhausted resource.
+func (err ErrBudgetExhausted) Limit() uint32 {
+	return err.limit
+}
+
+// NewBudgetExhaustedErr constructs a new budget exhausted error.
+func NewBudgetExhaustedErr(resource string, limit uint32) error {
+	return ErrBudgetExhausted{
+		error:    fmt.Errorf("request exceeded its budget of %d %s: this usually indicates a very broad query or a very large data set", limit, resource),
+		resource: resource,
+		limit:    limit,
+	}
+}
+
+const (
+	budgetResourceDispatches       = "dispatches"
+	budgetResourceDatastoreQueries = "datastore queries"
+
+	// ReasonBudgetExhausted is the error reason that will show up in ErrorInfo when a dispatched
+	// request has exhausted its budget.
+	ReasonBudgetExhausted = "DISPATCH_BUDGET_EXHAUSTED"
+
+	budgetErrorDomain = "authzed.com"
+)
+
+// BudgetExhaustedStatus converts a budget exhausted error into a gRPC status error, carrying
+// an ErrorInfo detail so that the sending node can distinguish it from other resource
+// exhaustion, such as message size limits.
+func BudgetExhaustedStatus(err ErrBudgetExhausted) error {
+	s, serr := status.New(codes.ResourceExhausted, err.Error()).WithDetails(&errdetails.ErrorInfo{
+		Reason: ReasonBudgetExhausted,
+		Domain: budgetErrorDomain,
+		Metadata: map[string]string{
+			"resource": err.resource,
+			"limit":    strconv.FormatUint(uint64(err.limit), 10),
+		},
+	})
+	if serr != nil {
+		return status.Errorf(codes.ResourceExhausted, "%s", err)
+	}
+	return s.Err()
+}
+
+// BudgetExhaustedFromStatus converts a gRPC status error created by BudgetExhaustedStatus on a
+// peer node back into a budget exhausted error. Any other error is returned as nil.
+func BudgetExhaustedFromStatus(err error) error {
+	s, ok := status.FromError(err)
+	if !ok || s.Code() != codes.ResourceExhausted {
+		return nil
+	}
+
+	for _, detail := range s.Details() {
+		info, ok := detail.(*errdetails.ErrorInfo)
+		if !ok || info.Reason != ReasonBudgetExhausted || info.Domain != budgetErrorDomain {
+			continue
+		}
+
+		limit, _ := strconv.ParseUint(info.Metadata["limit"], 10, 32)
+		return ErrBudgetExhausted{
+			error:    err,
+			resource: info.Metadata["resource"],
+			limit:    uint32(limit),
+		}
+	}
+	return nil
+}
+
+// Budget tracks the number of dispatches and datastore queries performed on behalf of a single
+// top-level request on this node. A limit of zero indicates that the resource is unlimited, but
+// its usage is still tracked.
+//
+// Budgets are carried between nodes in the ResolverMeta of dispatched requests: before a request
+// is sent to another node, a share of the remaining allowance is reserved for it, and the
+// receiving node creates its own Budget from that share. The amount the receiving node reports
+// as consumed in its responses is charged against the sender's Budget, and the reservation is
+// released once the request completes. As reservations count against the allowance, the
+// requests concurrently in flight on other nodes can never together exceed the budget.
+type Budget struct {
+	maxDispatches       uint32
+	maxDatastoreQueries uint32
+
+	mu                       sync.Mutex
+	dispatches               uint32
+	datastoreQueries         uint32
+	reservedDispatches       uint32
+	reservedDatastoreQueries uint32
+	reportedDispatches       uint32
+	reportedDatastoreQueries uint32
+}
+
+// NewBudget creates a new Budget with the given limits.
+func NewBudget(maxDispatches uint32, maxDatastoreQueries uint32) *Budget {
+	return &Budget{
+		maxDispatches:       maxDispatches,
+		maxDatastoreQueries: maxDatastoreQueries,
+	}
+}
+
+// NewBudgetFromMetadata creates a new Budget from the remaining allowance found in the
+// resolver metadata.
+func NewBudgetFromMetadata(metadata *v1.ResolverMeta) *Budget {
+	return NewBudget(metadata.GetDispatchBudgetRemaining(), metadata.GetDatastoreQueryBudgetRemaining())
+}
+
+// ConsumeDispatch records a single dispatch, returning ErrBudgetExhausted if the budget for
+// dispatches has been exceeded.
+func (b *Budget) ConsumeDispatch() error {
+	return b.Charge(1, 0)
+}
+
+// ConsumeDatastoreQuery records a single datastore query, returning ErrBudgetExhausted if the
+// budget for datastore queries has been exceeded.
+func (b *Budget) ConsumeDatastoreQuery() error {
+	return b.Charge(0, 1)
+}
+
+// Charge records the given number of dispatches and datastore queries, such as those performed
+// by a peer node, returning ErrBudgetExhausted if either budget has been exceeded.
+func (b *Budget) Charge(dispatches uint32, datastoreQueries uint32) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.dispatches += dispatches
+	b.datastoreQueries += datastoreQueries
+	return b.exceededLocked()
+}
+
+// ChargeRemote charges the work reported as consumed in the response metadata of a peer node.
+func (b *Budget) ChargeRemote(metadata *v1.ResponseMeta) error {
+	return b.Charge(metadata.GetBudgetDispatchesConsumed(), metadata.GetBudgetDatastoreQueriesConsumed())
+}
+
+func (b *Budget) exceededLocked() error {
+	if b.maxDispatches > 0 && b.dispatches+b.reservedDispatches > b.maxDispatches {
+		return NewBudgetExhaustedErr(budgetResourceDispatches, b.maxDispatches)
+	}
+	if b.maxDatastoreQueries > 0 && b.datastoreQueries+b.reservedDatastoreQueries > b.maxDatastoreQueries {
+		return NewBudgetExhaustedErr(budgetResourceDatastoreQueries, b.maxDatastoreQueries)
+	}
+	return nil
+}
+
+// CheckRemaining returns ErrBudgetExhausted if either of the budgets has no allowance left.
+func (b *Budget) CheckRemaining() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.checkRemainingLocked()
+}
+
+func (b *Budget) checkRemainingLocked() error {
+	if b.maxDispatches > 0 && b.dispatches+b.reservedDispatches >= b.maxDispatches {
+		return NewBudgetExhaustedErr(budgetResourceDispatches, b.maxDispatches)
+	}
+	if b.maxDatastoreQueries > 0 && b.datastoreQueries+b.reservedDatastoreQueries >= b.maxDatastoreQueries {
+		return NewBudgetExhaustedErr(budgetResourceDatastoreQueries, b.maxDatastoreQueries)
+	}
+	return nil
+}
+
+// DispatchesUsed returns the number of dispatches recorded against the budget.
+func (b *Budget) DispatchesUsed() uint32 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.dispatches
+}
+
+// DatastoreQueriesUsed returns the number of datastore queries recorded against the budget.
+func (b *Budget) DatastoreQueriesUsed() uint32 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.datastoreQueries
+}
+
+// Allowance is a share of a Budget reserved for a request sent to another node.
+type Allowance struct {
+	dispatches       uint32
+	datastoreQueries uint32
+}
+
+// Reserve reserves a share of the remaining allowance for a request sent to another node, which
+// is one of fanOut requests being dispatched concurrently. A fanOut of zero indicates that the
+// number of concurrent requests is not known, in which case half of the remaining allowance is
+// reserved, so that later requests are not starved. The reservation must be released with
+// Release once the request has completed.
+func (b *Budget) Reserve(fanOut uint32) (Allowance, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := b.checkRemainingLocked(); err != nil {
+		return Allowance{}, err
+	}
+
+	allowance := Allowance{
+		dispatches:       share(b.maxDispatches, b.dispatches+b.reservedDispatches, fanOut),
+		datastoreQueries: share(b.maxDatastoreQueries, b.datastoreQueries+b.reservedDatastoreQueries, fanOut),
+	}
+	b.reservedDispatches += allowance.dispatches
+	b.reservedDatastoreQueries += allowance.datastoreQueries
+	return allowance, nil
+}
+
+// Release releases an allowance reserved with Reserve.
+func (b *Budget) Release(allowance Allowance) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.reservedDispatches -= allowance.dispatches
+	b.reservedDatastoreQueries -= allowance.datastoreQueries
+}
+
+// ResolverMeta returns a copy of the resolver metadata with the allowance filled in as the
+// remaining budget, for sending to another node.
+func (a Allowance) ResolverMeta(metadata *v1.ResolverMeta) *v1.ResolverMeta {
+	return &v1.ResolverMeta{
+		AtRevision:                    metadata.AtRevision,
+		DepthRemaining:                metadata.DepthRemaining,
+		DispatchBudgetRemaining:       a.dispatches,
+		DatastoreQueryBudgetRemaining: a.datastoreQueries,
+	}
+}
+
+// share returns the share of the remaining allowance of a limited resource to reserve for one
+// of fanOut concurrent requests. A limited resource is never given a share of zero, as that
+// would indicate no limit on the receiving node; callers are expected to have checked that some
+// allowance remains.
+func share(limit, used, fanOut uint32) uint32 {
+	if limit == 0 {
+		return 0
+	}
+
+	if fanOut == 0 {
+		fanOut = 2
+	}
+
+	available := limit - used
+	if available/fanOut == 0 {
+		return 1
+	}
+	return available / fanOut
+}
+
+// Consumed returns a copy of the response metadata reporting the work charged against the
+// budget since the previous call, for sending back to the node which dispatched the request.
+func (b *Budget) Consumed(metadata *v1.ResponseMeta) *v1.ResponseMeta {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	reported := proto.Clone(metadata).(*v1.ResponseMeta)
+	reported.BudgetDispatchesConsumed = b.dispatches - b.reportedDispatches
+	reported.BudgetDatastoreQueriesConsumed = b.datastoreQueries - b.reportedDatastoreQueries
+	b.reportedDispatches = b.dispatches
+	b.reportedDatastoreQueries = b.datastoreQueries
+	return reported
+}
+
+type fanOutKey struct{}
+
+// ContextWithFanOut returns a new context recording that requests dispatched with it are one of
+// fanOut requests dispatched concurrently, amongst which the remaining budget is to be split. A
+// fanOut of zero indicates that the number of concurrent requests is not known.
+func ContextWithFanOut(ctx context.Context, fanOut uint32) context.Context {
+	return context.WithValue(ctx, fanOutKey{}, fanOut)
+}
+
+// FanOutFromContext returns the number of concurrent requests recorded in the context, or one
+// if none was recorded.
+func FanOutFromContext(ctx context.Context) uint32 {
+	if fanOut, ok := ctx.Value(fanOutKey{}).(uint32); ok {
+		return fanOut
+	}
+	return 1
+}
+
+type budgetKey struct{}
+
+// ContextWithBudget returns a new context with the given budget attached.
+func ContextWithBudget(ctx context.Context, budget *Budget) context.Context {
+	return context.WithValue(ctx, budgetKey{}, budget)
+}
+
+// BudgetFromContext returns the budget attached to the context, if any.
+func BudgetFromContext(ctx context.Context) *Budget {
+	if budget, ok := ctx.Value(budgetKey{}).(*Budget); ok {
+		return budget
+	}
+	return nil
+}
+
+// ConsumeDispatch records a single dispatch against the budget found in the context, if any.
+func ConsumeDispatch(ctx context.Context) error {
+	if budget := BudgetFromContext(ctx); budget != nil {
+		return budget.ConsumeDispatch()
+	}
+	return nil
+}
+
+// ConsumeDatastoreQuery records a single datastore query against the budget found in the
+// context, if any.
+func ConsumeDatastoreQuery(ctx context.Context) error {
+	if budget := BudgetFromContext(ctx); budget != nil {
+		return budget.ConsumeDatastoreQuery()
+	}
+	return nil
+}
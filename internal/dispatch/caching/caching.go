@@ -3,6 +3,7 @@ package caching
 import (
 	"context"
 	"fmt"
+	"strings"
 	"sync"
 	"unsafe"
 
@@ -10,10 +11,14 @@ import (
 	"github.com/dustin/go-humanize"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/rs/zerolog/log"
+	"github.com/shopspring/decimal"
 	"google.golang.org/protobuf/proto"
 
 	"github.com/authzed/spicedb/internal/dispatch"
 	"github.com/authzed/spicedb/internal/dispatch/keys"
+	"github.com/authzed/spicedb/internal/graph"
+	datastoremw "github.com/authzed/spicedb/internal/middleware/datastore"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
 	v1 "github.com/authzed/spicedb/pkg/proto/dispatch/v1"
 )
 
@@ -25,16 +30,21 @@ const (
 
 // Dispatcher is a dispatcher with built-in caching.
 type Dispatcher struct {
-	d          dispatch.Dispatcher
-	c          *ristretto.Cache
-	keyHandler keys.Handler
+	d            dispatch.Dispatcher
+	c            *ristretto.Cache
+	keyHandler   keys.Handler
+	writeTracker *WriteTracker
+	snapshots    *snapshotIndex
 
 	checkTotalCounter                  prometheus.Counter
 	checkFromCacheCounter              prometheus.Counter
+	checkFromPriorRevisionCounter      prometheus.Counter
 	lookupTotalCounter                 prometheus.Counter
 	lookupFromCacheCounter             prometheus.Counter
+	lookupFromPriorRevisionCounter     prometheus.Counter
 	reachableResourcesTotalCounter     prometheus.Counter
 	reachableResourcesFromCacheCounter prometheus.Counter
+	reachableFromPriorRevisionCounter  prometheus.Counter
 
 	cacheHits        prometheus.CounterFunc
 	cacheMisses      prometheus.CounterFunc
@@ -44,14 +54,18 @@ type Dispatcher struct {
 
 type checkResultEntry struct {
 	response *v1.DispatchCheckResponse
+	revision decimal.Decimal
 }
 
 type lookupResultEntry struct {
 	response *v1.DispatchLookupResponse
+	revision decimal.Decimal
 }
 
 type reachableResourcesResultEntry struct {
-	responses []*v1.DispatchReachableResourcesResponse
+	responses     []*v1.DispatchReachableResourcesResponse
+	revision      decimal.Decimal
+	readRelations []*core.RelationReference
 }
 
 var (
@@ -78,6 +92,10 @@ func NewCachingDispatcher(
 		log.Info().Int64("numCounters", cacheConfig.NumCounters).Str("maxCost", humanize.Bytes(uint64(cacheConfig.MaxCost))).Msg("configured caching dispatcher")
 	}
 
+	// Entries are removed from the snapshot index, if enabled, as the cache drops them.
+	snapshots := newSnapshotIndex()
+	cacheConfig = snapshots.wrapConfig(cacheConfig)
+
 	cache, err := ristretto.NewCache(cacheConfig)
 	if err != nil {
 		return nil, fmt.Errorf(errCachingInitialization, err)
@@ -94,6 +112,12 @@ func NewCachingDispatcher(
 		Name:      "check_from_cache_total",
 	})
 
+	checkFromPriorRevisionCounter := prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: prometheusNamespace,
+		Subsystem: prometheusSubsystem,
+		Name:      "check_from_prior_revision_total",
+	})
+
 	lookupTotalCounter := prometheus.NewCounter(prometheus.CounterOpts{
 		Namespace: prometheusNamespace,
 		Subsystem: prometheusSubsystem,
@@ -104,6 +128,11 @@ func NewCachingDispatcher(
 		Subsystem: prometheusSubsystem,
 		Name:      "lookup_from_cache_total",
 	})
+	lookupFromPriorRevisionCounter := prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: prometheusNamespace,
+		Subsystem: prometheusSubsystem,
+		Name:      "lookup_from_prior_revision_total",
+	})
 
 	reachableResourcesTotalCounter := prometheus.NewCounter(prometheus.CounterOpts{
 		Namespace: prometheusNamespace,
@@ -115,6 +144,11 @@ func NewCachingDispatcher(
 		Subsystem: prometheusSubsystem,
 		Name:      "reachable_resources_from_cache_total",
 	})
+	reachableFromPriorRevisionCounter := prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: prometheusNamespace,
+		Subsystem: prometheusSubsystem,
+		Name:      "reachable_resources_from_prior_revision_total",
+	})
 
 	cacheHitsTotal := prometheus.NewCounterFunc(prometheus.CounterOpts{
 		Namespace: prometheusNamespace,
@@ -156,6 +190,10 @@ func NewCachingDispatcher(
 		if err != nil {
 			return nil, fmt.Errorf(errCachingInitialization, err)
 		}
+		err = prometheus.Register(checkFromPriorRevisionCounter)
+		if err != nil {
+			return nil, fmt.Errorf(errCachingInitialization, err)
+		}
 		err = prometheus.Register(lookupTotalCounter)
 		if err != nil {
 			return nil, fmt.Errorf(errCachingInitialization, err)
@@ -164,6 +202,10 @@ func NewCachingDispatcher(
 		if err != nil {
 			return nil, fmt.Errorf(errCachingInitialization, err)
 		}
+		err = prometheus.Register(lookupFromPriorRevisionCounter)
+		if err != nil {
+			return nil, fmt.Errorf(errCachingInitialization, err)
+		}
 		err = prometheus.Register(reachableResourcesTotalCounter)
 		if err != nil {
 			return nil, fmt.Errorf(errCachingInitialization, err)
@@ -172,6 +214,10 @@ func NewCachingDispatcher(
 		if err != nil {
 			return nil, fmt.Errorf(errCachingInitialization, err)
 		}
+		err = prometheus.Register(reachableFromPriorRevisionCounter)
+		if err != nil {
+			return nil, fmt.Errorf(errCachingInitialization, err)
+		}
 
 		// Export some ristretto metrics
 		err = prometheus.Register(cacheHitsTotal)
@@ -200,12 +246,16 @@ func NewCachingDispatcher(
 		d:                                  fakeDelegate{},
 		c:                                  cache,
 		keyHandler:                         keyHandler,
+		snapshots:                          snapshots,
 		checkTotalCounter:                  checkTotalCounter,
 		checkFromCacheCounter:              checkFromCacheCounter,
+		checkFromPriorRevisionCounter:      checkFromPriorRevisionCounter,
 		lookupTotalCounter:                 lookupTotalCounter,
 		lookupFromCacheCounter:             lookupFromCacheCounter,
+		lookupFromPriorRevisionCounter:     lookupFromPriorRevisionCounter,
 		reachableResourcesTotalCounter:     reachableResourcesTotalCounter,
 		reachableResourcesFromCacheCounter: reachableResourcesFromCacheCounter,
+		reachableFromPriorRevisionCounter:  reachableFromPriorRevisionCounter,
 		cacheHits:                          cacheHitsTotal,
 		cacheMisses:                        cacheMissesTotal,
 		costAddedBytes:                     costAddedBytes,
@@ -218,6 +268,14 @@ func (cd *Dispatcher) SetDelegate(delegate dispatch.Dispatcher) {
 	cd.d = delegate
 }
 
+// SetWriteTracker enables write-aware caching of check, lookup and reachable resources results:
+// results are cached without regard to the revision at which they were computed, and are reused
+// at later revisions so long as the tracker reports that none of the relations read to compute
+// them have since changed.
+func (cd *Dispatcher) SetWriteTracker(tracker *WriteTracker) {
+	cd.writeTracker = tracker
+}
+
 // DispatchCheck implements dispatch.Check interface
 func (cd *Dispatcher) DispatchCheck(ctx context.Context, req *v1.DispatchCheckRequest) (*v1.DispatchCheckResponse, error) {
 	cd.checkTotalCounter.Inc()
@@ -227,11 +285,27 @@ func (cd *Dispatcher) DispatchCheck(ctx context.Context, req *v1.DispatchCheckRe
 		return &v1.DispatchCheckResponse{Metadata: &v1.ResponseMeta{}}, err
 	}
 
+	revision, err := decimal.NewFromString(req.Metadata.AtRevision)
+	if err != nil {
+		return &v1.DispatchCheckResponse{Metadata: &v1.ResponseMeta{}}, err
+	}
+
+	if cd.writeTracker != nil {
+		requestKey = revisionlessKey(requestKey, req.Metadata.AtRevision)
+	}
+
 	if cachedResultRaw, found := cd.c.Get(requestKey); found {
 		cachedResult := cachedResultRaw.(checkResultEntry)
 		if req.Metadata.DepthRemaining >= cachedResult.response.Metadata.DepthRequired {
-			cd.checkFromCacheCounter.Inc()
-			return cachedResult.response, nil
+			valid, err := cd.isValidAt(ctx, cachedResult.response.Metadata.ReadRelations, cachedResult.revision, revision, cd.checkFromPriorRevisionCounter)
+			if err != nil {
+				return &v1.DispatchCheckResponse{Metadata: &v1.ResponseMeta{}}, err
+			}
+
+			if valid {
+				cd.checkFromCacheCounter.Inc()
+				return cachedResult.response, nil
+			}
 		}
 	}
 
@@ -243,8 +317,8 @@ func (cd *Dispatcher) DispatchCheck(ctx context.Context, req *v1.DispatchCheckRe
 		adjustedComputed.Metadata.CachedDispatchCount = adjustedComputed.Metadata.DispatchCount
 		adjustedComputed.Metadata.DispatchCount = 0
 
-		toCache := checkResultEntry{adjustedComputed}
-		cd.c.Set(requestKey, toCache, checkResultEntryCost)
+		toCache := checkResultEntry{adjustedComputed, revision}
+		cd.set(requestKey, toCache, checkEntryCost(toCache))
 	}
 
 	// Return both the computed and err in ALL cases: computed contains resolved metadata even
@@ -258,17 +332,37 @@ func (cd *Dispatcher) DispatchExpand(ctx context.Context, req *v1.DispatchExpand
 	return resp, err
 }
 
-// DispatchLookup implements dispatch.Lookup interface and does not do any caching yet.
+// DispatchLookup implements dispatch.Lookup interface.
 func (cd *Dispatcher) DispatchLookup(ctx context.Context, req *v1.DispatchLookupRequest) (*v1.DispatchLookupResponse, error) {
 	cd.lookupTotalCounter.Inc()
 
-	requestKey := dispatch.LookupRequestToKey(req)
+	requestKey, err := cd.keyHandler.ComputeLookupKey(ctx, req)
+	if err != nil {
+		return &v1.DispatchLookupResponse{Metadata: &v1.ResponseMeta{}}, err
+	}
+
+	revision, err := decimal.NewFromString(req.Metadata.AtRevision)
+	if err != nil {
+		return &v1.DispatchLookupResponse{Metadata: &v1.ResponseMeta{}}, err
+	}
+
+	if cd.writeTracker != nil {
+		requestKey = revisionlessKey(requestKey, req.Metadata.AtRevision)
+	}
+
 	if cachedResultRaw, found := cd.c.Get(requestKey); found {
 		cachedResult := cachedResultRaw.(lookupResultEntry)
 		if req.Metadata.DepthRemaining >= cachedResult.response.Metadata.DepthRequired {
-			log.Trace().Object("cachedLookup", req).Int("resultCount", len(cachedResult.response.ResolvedOnrs)).Send()
-			cd.lookupFromCacheCounter.Inc()
-			return cachedResult.response, nil
+			valid, err := cd.isValidAt(ctx, cachedResult.response.Metadata.ReadRelations, cachedResult.revision, revision, cd.lookupFromPriorRevisionCounter)
+			if err != nil {
+				return &v1.DispatchLookupResponse{Metadata: &v1.ResponseMeta{}}, err
+			}
+
+			if valid {
+				log.Trace().Object("cachedLookup", req).Int("resultCount", len(cachedResult.response.ResolvedOnrs)).Send()
+				cd.lookupFromCacheCounter.Inc()
+				return relabelLookupResponse(cachedResult.response, req.ObjectRelation.Relation), nil
+			}
 		}
 	}
 
@@ -284,15 +378,8 @@ func (cd *Dispatcher) DispatchLookup(ctx context.Context, req *v1.DispatchLookup
 		adjustedComputed.Metadata.LookupExcludedDirect = nil
 		adjustedComputed.Metadata.LookupExcludedTtu = nil
 
-		requestKey := dispatch.LookupRequestToKey(req)
-		toCache := lookupResultEntry{adjustedComputed}
-
-		estimatedSize := lookupResultEntryEmptyCost
-		for _, onr := range toCache.response.ResolvedOnrs {
-			estimatedSize += int64(len(onr.Namespace) + len(onr.ObjectId) + len(onr.Relation))
-		}
-
-		cd.c.Set(requestKey, toCache, estimatedSize)
+		toCache := lookupResultEntry{adjustedComputed, revision}
+		cd.set(requestKey, toCache, lookupEntryCost(toCache))
 	}
 
 	// Return both the computed and err in ALL cases: computed contains resolved metadata even
@@ -300,26 +387,46 @@ func (cd *Dispatcher) DispatchLookup(ctx context.Context, req *v1.DispatchLookup
 	return computed, err
 }
 
-// DispatchReachableResources implements dispatch.ReachableResources interface and does not do any caching yet.
+// DispatchReachableResources implements dispatch.ReachableResources interface.
 func (cd *Dispatcher) DispatchReachableResources(req *v1.DispatchReachableResourcesRequest, stream dispatch.ReachableResourcesStream) error {
 	cd.reachableResourcesTotalCounter.Inc()
 
-	requestKey := dispatch.ReachableResourcesRequestToKey(req)
+	ctx := stream.Context()
+	requestKey, err := cd.keyHandler.ComputeReachableResourcesKey(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	revision, err := decimal.NewFromString(req.Metadata.AtRevision)
+	if err != nil {
+		return err
+	}
+
+	if cd.writeTracker != nil {
+		requestKey = revisionlessKey(requestKey, req.Metadata.AtRevision)
+	}
+
 	if cachedResultRaw, found := cd.c.Get(requestKey); found {
 		cachedResult := cachedResultRaw.(reachableResourcesResultEntry)
-		cd.reachableResourcesFromCacheCounter.Inc()
-		for _, result := range cachedResult.responses {
-			err := stream.Publish(result)
-			if err != nil {
-				return err
-			}
+		valid, err := cd.isValidAt(ctx, cachedResult.readRelations, cachedResult.revision, revision, cd.reachableFromPriorRevisionCounter)
+		if err != nil {
+			return err
 		}
 
-		return nil
+		if valid {
+			cd.reachableResourcesFromCacheCounter.Inc()
+			for _, result := range cachedResult.responses {
+				err := stream.Publish(relabelReachableResourcesResponse(result, req.ObjectRelation.Relation))
+				if err != nil {
+					return err
+				}
+			}
+
+			return nil
+		}
 	}
 
 	var mu sync.Mutex
-	estimatedSize := reachbleResourcesEntryEmptyCost
 	toCacheResults := []*v1.DispatchReachableResourcesResponse{}
 	wrapped := &dispatch.WrappedDispatchStream[*v1.DispatchReachableResourcesResponse]{
 		Stream: stream,
@@ -333,27 +440,81 @@ func (cd *Dispatcher) DispatchReachableResources(req *v1.DispatchReachableResour
 			adjustedResult.Metadata.DispatchCount = 0
 
 			toCacheResults = append(toCacheResults, adjustedResult)
-			estimatedSize += int64(len(result.Resource.Resource.Namespace) + len(result.Resource.Resource.ObjectId) + len(result.Resource.Resource.Relation))
 			return result, nil
 		},
 	}
 
-	err := cd.d.DispatchReachableResources(req, wrapped)
+	err = cd.d.DispatchReachableResources(req, wrapped)
 
 	// We only want to cache the result if there was no error
 	if err == nil {
-		toCache := reachableResourcesResultEntry{toCacheResults}
-		cd.c.Set(requestKey, toCache, estimatedSize)
+		toCache := reachableResourcesResultEntry{toCacheResults, revision, nil}
+
+		// As the stream does not report every relation read by the subproblems which found no
+		// resources, the relations which may have been read are determined from the schema.
+		if cd.writeTracker != nil {
+			toCache.readRelations, err = graph.ReachableResourcesReadRelations(
+				ctx,
+				datastoremw.MustFromContext(ctx).SnapshotReader(revision),
+				req.ObjectRelation,
+				&core.RelationReference{Namespace: req.Subject.Namespace, Relation: req.Subject.Relation},
+			)
+			if err != nil {
+				return err
+			}
+		}
+
+		cd.set(requestKey, toCache, reachableResourcesEntryCost(toCache))
 	}
 
 	return err
 }
 
+// isValidAt returns whether a result computed at one revision, which read the given relations,
+// is valid at the requested revision. The counter is incremented for results which are reused
+// from a prior revision.
+func (cd *Dispatcher) isValidAt(
+	ctx context.Context,
+	readRelations []*core.RelationReference,
+	computedAt decimal.Decimal,
+	requestedAt decimal.Decimal,
+	priorRevisionCounter prometheus.Counter,
+) (bool, error) {
+	if computedAt.Equal(requestedAt) {
+		return true, nil
+	}
+
+	// NOTE: results without any read relations are never reused at other revisions, as they
+	// may have been computed by a node which does not report them.
+	if cd.writeTracker == nil || len(readRelations) == 0 {
+		return false, nil
+	}
+
+	unchanged, err := cd.writeTracker.IsUnchanged(ctx, readRelations, computedAt, requestedAt)
+	if err != nil || !unchanged {
+		return false, err
+	}
+
+	priorRevisionCounter.Inc()
+	return true, nil
+}
+
 func (cd *Dispatcher) Close() error {
+	if cd.snapshots.path != "" {
+		if err := cd.saveSnapshot(); err != nil {
+			log.Warn().Err(err).Str("path", cd.snapshots.path).Msg("unable to save dispatch cache snapshot")
+		}
+	}
+
 	prometheus.Unregister(cd.checkTotalCounter)
 	prometheus.Unregister(cd.lookupTotalCounter)
 	prometheus.Unregister(cd.lookupFromCacheCounter)
+	prometheus.Unregister(cd.lookupFromPriorRevisionCounter)
 	prometheus.Unregister(cd.checkFromCacheCounter)
+	prometheus.Unregister(cd.checkFromPriorRevisionCounter)
+	prometheus.Unregister(cd.reachableResourcesTotalCounter)
+	prometheus.Unregister(cd.reachableResourcesFromCacheCounter)
+	prometheus.Unregister(cd.reachableFromPriorRevisionCounter)
 	prometheus.Unregister(cd.cacheHits)
 	prometheus.Unregister(cd.cacheMisses)
 	prometheus.Unregister(cd.costAddedBytes)
@@ -365,5 +526,91 @@ func (cd *Dispatcher) Close() error {
 	return nil
 }
 
+// set adds the entry to the cache, and to the snapshot index if enabled.
+//
+// The entry is indexed before it is set, as the cache applies sets asynchronously and may
+// evict or reject the entry before Set returns. Entries dropped without being applied are
+// removed from the index here, while those evicted or rejected later are removed by the
+// cache's callbacks.
+func (cd *Dispatcher) set(key string, entry interface{}, cost int64) {
+	cd.snapshots.add(key, entry)
+	if !cd.c.Set(key, entry, cost) {
+		cd.snapshots.removeKey(key)
+	}
+}
+
+func checkEntryCost(entry checkResultEntry) int64 {
+	estimatedSize := checkResultEntryCost
+	for _, relation := range entry.response.Metadata.ReadRelations {
+		estimatedSize += int64(len(relation.Namespace) + len(relation.Relation))
+	}
+	return estimatedSize
+}
+
+func lookupEntryCost(entry lookupResultEntry) int64 {
+	estimatedSize := lookupResultEntryEmptyCost
+	for _, relation := range entry.response.Metadata.ReadRelations {
+		estimatedSize += int64(len(relation.Namespace) + len(relation.Relation))
+	}
+	for _, onr := range entry.response.ResolvedOnrs {
+		estimatedSize += int64(len(onr.Namespace) + len(onr.ObjectId) + len(onr.Relation))
+	}
+	return estimatedSize
+}
+
+func reachableResourcesEntryCost(entry reachableResourcesResultEntry) int64 {
+	estimatedSize := reachbleResourcesEntryEmptyCost
+	for _, relation := range entry.readRelations {
+		estimatedSize += int64(len(relation.Namespace) + len(relation.Relation))
+	}
+	for _, result := range entry.responses {
+		resource := result.Resource.Resource
+		estimatedSize += int64(len(resource.Namespace) + len(resource.ObjectId) + len(resource.Relation))
+	}
+	return estimatedSize
+}
+
+// relabelLookupResponse returns the lookup response with its resolved objects relabeled with the
+// given relation, as a response cached under a canonical key may have been computed for another
+// relation with an equivalent rewrite.
+func relabelLookupResponse(resp *v1.DispatchLookupResponse, relation string) *v1.DispatchLookupResponse {
+	needsRelabel := false
+	for _, onr := range resp.ResolvedOnrs {
+		if onr.Relation != relation {
+			needsRelabel = true
+			break
+		}
+	}
+
+	if !needsRelabel {
+		return resp
+	}
+
+	relabeled := proto.Clone(resp).(*v1.DispatchLookupResponse)
+	for _, onr := range relabeled.ResolvedOnrs {
+		onr.Relation = relation
+	}
+	return relabeled
+}
+
+// relabelReachableResourcesResponse returns the reachable resources response with its resource
+// relabeled with the given relation, as a response cached under a canonical key may have been
+// computed for another relation with an equivalent rewrite.
+func relabelReachableResourcesResponse(resp *v1.DispatchReachableResourcesResponse, relation string) *v1.DispatchReachableResourcesResponse {
+	if resp.Resource.Resource.Relation == relation {
+		return resp
+	}
+
+	relabeled := proto.Clone(resp).(*v1.DispatchReachableResourcesResponse)
+	relabeled.Resource.Resource.Relation = relation
+	return relabeled
+}
+
+// revisionlessKey strips the revision from a dispatch cache key, all of which end with the
+// revision at which the request is resolved.
+func revisionlessKey(key string, revision string) string {
+	return strings.TrimSuffix(key, "@"+revision)
+}
+
 // Always verify that we implement the interfaces
 var _ dispatch.Dispatcher = &Dispatcher{}
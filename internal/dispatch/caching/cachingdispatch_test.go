@@ -2,14 +2,26 @@ package caching
 
 import (
 	"context"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
+	v1_api "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/dgraph-io/ristretto"
 	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 
+	"github.com/authzed/spicedb/internal/datastore/memdb"
 	"github.com/authzed/spicedb/internal/dispatch"
+	"github.com/authzed/spicedb/internal/dispatch/keys"
+	datastoremw "github.com/authzed/spicedb/internal/middleware/datastore"
+	"github.com/authzed/spicedb/internal/namespace"
+	"github.com/authzed/spicedb/internal/testfixtures"
+	"github.com/authzed/spicedb/pkg/datastore"
+	ns "github.com/authzed/spicedb/pkg/namespace"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
 	v1 "github.com/authzed/spicedb/pkg/proto/dispatch/v1"
 	"github.com/authzed/spicedb/pkg/tuple"
 )
@@ -129,6 +141,635 @@ func TestMaxDepthCaching(t *testing.T) {
 	}
 }
 
+func TestWriteAwareCaching(t *testing.T) {
+	readRelations := []*core.RelationReference{{Namespace: "document", Relation: "editor"}}
+	metadata := func(revision decimal.Decimal) *v1.ResolverMeta {
+		return &v1.ResolverMeta{AtRevision: revision.String(), DepthRemaining: 50}
+	}
+
+	testCases := []struct {
+		name     string
+		expect   func(delegate delegateDispatchMock, revision decimal.Decimal)
+		dispatch func(ctx context.Context, dispatcher *Dispatcher, revision decimal.Decimal) error
+	}{
+		{
+			"check",
+			func(delegate delegateDispatchMock, revision decimal.Decimal) {
+				delegate.On("DispatchCheck", &v1.DispatchCheckRequest{
+					ObjectAndRelation: tuple.ParseONR("document:masterplan#editor"),
+					Subject:           tuple.ParseSubjectONR("user:product_manager#..."),
+					Metadata:          metadata(revision),
+				}).Return(&v1.DispatchCheckResponse{
+					Membership: v1.DispatchCheckResponse_MEMBER,
+					Metadata: &v1.ResponseMeta{
+						DispatchCount: 1,
+						DepthRequired: 1,
+						ReadRelations: readRelations,
+					},
+				}, nil).Times(1)
+			},
+			func(ctx context.Context, dispatcher *Dispatcher, revision decimal.Decimal) error {
+				_, err := dispatcher.DispatchCheck(ctx, &v1.DispatchCheckRequest{
+					ObjectAndRelation: tuple.ParseONR("document:masterplan#editor"),
+					Subject:           tuple.ParseSubjectONR("user:product_manager#..."),
+					Metadata:          metadata(revision),
+				})
+				return err
+			},
+		},
+		{
+			"lookup",
+			func(delegate delegateDispatchMock, revision decimal.Decimal) {
+				delegate.On("DispatchLookup", &v1.DispatchLookupRequest{
+					ObjectRelation: &core.RelationReference{Namespace: "document", Relation: "editor"},
+					Subject:        tuple.ParseSubjectONR("user:product_manager#..."),
+					Metadata:       metadata(revision),
+					Limit:          10,
+				}).Return(&v1.DispatchLookupResponse{
+					Metadata: &v1.ResponseMeta{
+						DispatchCount: 1,
+						DepthRequired: 1,
+						ReadRelations: readRelations,
+					},
+					ResolvedOnrs: []*core.ObjectAndRelation{tuple.ParseONR("document:masterplan#editor")},
+				}, nil).Times(1)
+			},
+			func(ctx context.Context, dispatcher *Dispatcher, revision decimal.Decimal) error {
+				_, err := dispatcher.DispatchLookup(ctx, &v1.DispatchLookupRequest{
+					ObjectRelation: &core.RelationReference{Namespace: "document", Relation: "editor"},
+					Subject:        tuple.ParseSubjectONR("user:product_manager#..."),
+					Metadata:       metadata(revision),
+					Limit:          10,
+				})
+				return err
+			},
+		},
+		{
+			"reachable resources",
+			func(delegate delegateDispatchMock, revision decimal.Decimal) {
+				delegate.On("DispatchReachableResources", &v1.DispatchReachableResourcesRequest{
+					ObjectRelation: &core.RelationReference{Namespace: "document", Relation: "editor"},
+					Subject:        tuple.ParseSubjectONR("user:product_manager#..."),
+					Metadata:       metadata(revision),
+				}).Return([]*v1.DispatchReachableResourcesResponse{{
+					Resource: &v1.ReachableResource{
+						Resource:     tuple.ParseONR("document:masterplan#editor"),
+						ResultStatus: v1.ReachableResource_HAS_PERMISSION,
+					},
+					Metadata: &v1.ResponseMeta{DispatchCount: 1},
+				}}, nil).Times(1)
+			},
+			func(ctx context.Context, dispatcher *Dispatcher, revision decimal.Decimal) error {
+				stream := dispatch.NewCollectingDispatchStream[*v1.DispatchReachableResourcesResponse](ctx)
+				return dispatcher.DispatchReachableResources(&v1.DispatchReachableResourcesRequest{
+					ObjectRelation: &core.RelationReference{Namespace: "document", Relation: "editor"},
+					Subject:        tuple.ParseSubjectONR("user:product_manager#..."),
+					Metadata:       metadata(revision),
+				}, stream)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			require := require.New(t)
+
+			rawDS, err := memdb.NewMemdbDatastore(0, 0, memdb.DisableGC)
+			require.NoError(err)
+
+			ds, _ := testfixtures.StandardDatastoreWithSchema(rawDS, require)
+
+			ctx := datastoremw.ContextWithHandle(context.Background())
+			require.NoError(datastoremw.SetInContext(ctx, ds))
+
+			tracker, err := NewWriteTracker(ds)
+			require.NoError(err)
+			defer tracker.Close()
+
+			delegate := delegateDispatchMock{&mock.Mock{}}
+			dispatcher, err := NewCachingDispatcher(nil, "", nil)
+			require.NoError(err)
+			defer dispatcher.Close()
+
+			dispatcher.SetDelegate(delegate)
+			dispatcher.SetWriteTracker(tracker)
+
+			// Dispatches at a revision wait for the tracker to have observed all changes up to it,
+			// and then for the cache to have stored the result.
+			dispatchAt := func(revision decimal.Decimal) {
+				waitForWatermark(tracker, revision)
+				require.NoError(tc.dispatch(ctx, dispatcher, revision))
+				dispatcher.c.Wait()
+			}
+
+			write := func(rel string) decimal.Decimal {
+				updated, err := ds.ReadWriteTx(ctx, func(ctx context.Context, rwt datastore.ReadWriteTransaction) error {
+					return rwt.WriteRelationships([]*v1_api.RelationshipUpdate{
+						tuple.UpdateToRelationshipUpdate(tuple.Touch(tuple.MustParse(rel))),
+					})
+				})
+				require.NoError(err)
+				return updated
+			}
+
+			// The first result is computed.
+			revision, err := ds.HeadRevision(ctx)
+			require.NoError(err)
+			tc.expect(delegate, revision)
+			dispatchAt(revision)
+
+			// A write to an unrelated relation does not invalidate the cached result.
+			unrelatedRevision := write("folder:company#viewer@user:someone#...")
+			dispatchAt(unrelatedRevision)
+
+			// A write to a relation that was read does.
+			relatedRevision := write("document:masterplan#editor@user:someone#...")
+			tc.expect(delegate, relatedRevision)
+			dispatchAt(relatedRevision)
+
+			delegate.AssertExpectations(t)
+		})
+	}
+}
+
+func TestWriteTrackerWatermark(t *testing.T) {
+	require := require.New(t)
+
+	rawDS, err := memdb.NewMemdbDatastore(0, 0, memdb.DisableGC)
+	require.NoError(err)
+
+	ds, _ := testfixtures.StandardDatastoreWithSchema(rawDS, require)
+	watched := controlledWatchDatastore{ds, make(chan *datastore.RevisionChanges)}
+
+	ctx := datastoremw.ContextWithHandle(context.Background())
+	require.NoError(datastoremw.SetInContext(ctx, ds))
+
+	tracker, err := NewWriteTracker(watched)
+	require.NoError(err)
+	defer tracker.Close()
+
+	start, err := ds.HeadRevision(ctx)
+	require.NoError(err)
+
+	write := func(rel string) (decimal.Decimal, *core.RelationTupleUpdate) {
+		update := tuple.Touch(tuple.MustParse(rel))
+		updated, err := ds.ReadWriteTx(ctx, func(ctx context.Context, rwt datastore.ReadWriteTransaction) error {
+			return rwt.WriteRelationships([]*v1_api.RelationshipUpdate{tuple.UpdateToRelationshipUpdate(update)})
+		})
+		require.NoError(err)
+		return updated, update
+	}
+
+	deliver := func(changes *datastore.RevisionChanges) {
+		watched.changes <- changes
+		waitForWatermark(tracker, changes.Revision)
+	}
+
+	isUnchanged := func(computedAt, requestedAt decimal.Decimal) bool {
+		unchanged, err := tracker.IsUnchanged(ctx, []*core.RelationReference{{Namespace: "document", Relation: "editor"}}, computedAt, requestedAt)
+		require.NoError(err)
+		return unchanged
+	}
+
+	unrelatedRevision, unrelated := write("folder:company#viewer@user:someone#...")
+	relatedRevision, related := write("document:masterplan#editor@user:someone#...")
+
+	// Revisions which the datastore's Watch has not yet delivered are never reported as
+	// unchanged, however long ago they were written.
+	require.False(isUnchanged(start, unrelatedRevision))
+
+	deliver(&datastore.RevisionChanges{Revision: unrelatedRevision, Changes: []*core.RelationTupleUpdate{unrelated}})
+	require.True(isUnchanged(start, unrelatedRevision))
+	require.False(isUnchanged(start, relatedRevision))
+
+	// Revisions after a change to a relation that was read are not unchanged.
+	deliver(&datastore.RevisionChanges{Revision: relatedRevision, Changes: []*core.RelationTupleUpdate{related}})
+	require.False(isUnchanged(start, relatedRevision))
+
+	// Checkpoints advance the watermark past revisions at which nothing changed.
+	checkpoint, err := ds.HeadRevision(ctx)
+	require.NoError(err)
+	require.False(isUnchanged(relatedRevision, checkpoint))
+
+	deliver(&datastore.RevisionChanges{Revision: checkpoint, IsCheckpoint: true})
+	require.True(isUnchanged(relatedRevision, checkpoint))
+}
+
+// controlledWatchDatastore is a datastore whose Watch delivers only the changes sent by the test.
+type controlledWatchDatastore struct {
+	datastore.Datastore
+	changes chan *datastore.RevisionChanges
+}
+
+func (cwd controlledWatchDatastore) Watch(ctx context.Context, afterRevision datastore.Revision) (<-chan *datastore.RevisionChanges, <-chan error) {
+	return cwd.changes, make(chan error)
+}
+
+// waitForWatermark blocks until the tracker has observed all changes up to the revision.
+func waitForWatermark(wt *WriteTracker, revision decimal.Decimal) {
+	wt.RLock()
+	defer wt.RUnlock()
+
+	for wt.watermark.LessThan(revision) {
+		wt.advanced.Wait()
+	}
+}
+
+func TestCanonicalLookupRelabeling(t *testing.T) {
+	require := require.New(t)
+
+	delegate := delegateDispatchMock{&mock.Mock{}}
+	delegate.On("DispatchLookup", mock.Anything).Return(&v1.DispatchLookupResponse{
+		Metadata: &v1.ResponseMeta{
+			DispatchCount: 1,
+			DepthRequired: 1,
+		},
+		ResolvedOnrs: []*core.ObjectAndRelation{
+			tuple.ParseONR("document:doc1#view"),
+		},
+	}, nil).Times(1)
+
+	dispatcher, err := NewCachingDispatcher(nil, "", &sharedLookupKeyHandler{})
+	require.NoError(err)
+	defer dispatcher.Close()
+	dispatcher.SetDelegate(delegate)
+
+	lookup := func(relation string) *v1.DispatchLookupResponse {
+		resp, err := dispatcher.DispatchLookup(context.Background(), &v1.DispatchLookupRequest{
+			ObjectRelation: &core.RelationReference{Namespace: "document", Relation: relation},
+			Subject:        tuple.ParseSubjectONR("user:user1#..."),
+			Metadata: &v1.ResolverMeta{
+				AtRevision:     "1",
+				DepthRemaining: 50,
+			},
+		})
+		require.NoError(err)
+
+		// We have to sleep a while to let the cache converge:
+		// https://github.com/dgraph-io/ristretto/blob/01b9f37dd0fd453225e042d6f3a27cd14f252cd0/cache_test.go#L17
+		time.Sleep(10 * time.Millisecond)
+		return resp
+	}
+
+	require.Equal("view", lookup("view").ResolvedOnrs[0].Relation)
+
+	// The equivalent relation is served from the cache, relabeled with the requested relation.
+	require.Equal("read", lookup("read").ResolvedOnrs[0].Relation)
+	require.Equal("view", lookup("view").ResolvedOnrs[0].Relation)
+
+	delegate.AssertExpectations(t)
+}
+
+func TestCanonicalKeyRelabeling(t *testing.T) {
+	require := require.New(t)
+
+	rawDS, err := memdb.NewMemdbDatastore(0, 0, memdb.DisableGC)
+	require.NoError(err)
+
+	// view and read are equivalent rewrites, and so share a canonical key.
+	documentNS := ns.Namespace("document",
+		ns.Relation("owner", nil, ns.AllowedRelation("user", "...")),
+		ns.Relation("editor", nil, ns.AllowedRelation("user", "...")),
+		ns.Relation("view", ns.Union(
+			ns.ComputedUserset("owner"),
+			ns.ComputedUserset("editor"),
+		)),
+		ns.Relation("read", ns.Union(
+			ns.ComputedUserset("editor"),
+			ns.ComputedUserset("owner"),
+		)),
+	)
+	allDefs := []*core.NamespaceDefinition{testfixtures.UserNS, documentNS}
+
+	revision, err := rawDS.ReadWriteTx(context.Background(), func(ctx context.Context, rwt datastore.ReadWriteTransaction) error {
+		for _, nsDef := range allDefs {
+			ts, err := namespace.BuildNamespaceTypeSystemWithFallback(nsDef, rwt, allDefs)
+			require.NoError(err)
+
+			vts, err := ts.Validate(ctx)
+			require.NoError(err)
+			require.NoError(namespace.AnnotateNamespace(vts))
+			require.NoError(rwt.WriteNamespaces(nsDef))
+		}
+		return nil
+	})
+	require.NoError(err)
+
+	ctx := datastoremw.ContextWithHandle(context.Background())
+	require.NoError(datastoremw.SetInContext(ctx, rawDS))
+
+	metadata := &v1.ResolverMeta{
+		AtRevision:     revision.String(),
+		DepthRemaining: 50,
+	}
+	subject := tuple.ParseSubjectONR("user:user1#...")
+
+	keyHandler := &keys.CanonicalKeyHandler{}
+	viewKey, err := keyHandler.ComputeLookupKey(ctx, &v1.DispatchLookupRequest{
+		ObjectRelation: &core.RelationReference{Namespace: "document", Relation: "view"},
+		Subject:        subject,
+		Metadata:       metadata,
+	})
+	require.NoError(err)
+	readKey, err := keyHandler.ComputeLookupKey(ctx, &v1.DispatchLookupRequest{
+		ObjectRelation: &core.RelationReference{Namespace: "document", Relation: "read"},
+		Subject:        subject,
+		Metadata:       metadata,
+	})
+	require.NoError(err)
+	require.Equal(viewKey, readKey)
+
+	delegate := delegateDispatchMock{&mock.Mock{}}
+	delegate.On("DispatchLookup", mock.Anything).Return(&v1.DispatchLookupResponse{
+		Metadata: &v1.ResponseMeta{
+			DispatchCount: 1,
+			DepthRequired: 1,
+		},
+		ResolvedOnrs: []*core.ObjectAndRelation{
+			tuple.ParseONR("document:doc1#view"),
+		},
+	}, nil).Times(1)
+	delegate.On("DispatchReachableResources", mock.Anything).Return([]*v1.DispatchReachableResourcesResponse{{
+		Resource: &v1.ReachableResource{
+			Resource:     tuple.ParseONR("document:doc1#view"),
+			ResultStatus: v1.ReachableResource_HAS_PERMISSION,
+		},
+		Metadata: &v1.ResponseMeta{DispatchCount: 1},
+	}}, nil).Times(1)
+
+	dispatcher, err := NewCachingDispatcher(nil, "", keyHandler)
+	require.NoError(err)
+	defer dispatcher.Close()
+	dispatcher.SetDelegate(delegate)
+
+	lookup := func(relation string) string {
+		resp, err := dispatcher.DispatchLookup(ctx, &v1.DispatchLookupRequest{
+			ObjectRelation: &core.RelationReference{Namespace: "document", Relation: relation},
+			Subject:        subject,
+			Metadata:       metadata,
+		})
+		require.NoError(err)
+		require.Len(resp.ResolvedOnrs, 1)
+
+		// We have to sleep a while to let the cache converge:
+		// https://github.com/dgraph-io/ristretto/blob/01b9f37dd0fd453225e042d6f3a27cd14f252cd0/cache_test.go#L17
+		time.Sleep(10 * time.Millisecond)
+		return resp.ResolvedOnrs[0].Relation
+	}
+
+	reachable := func(relation string) string {
+		stream := dispatch.NewCollectingDispatchStream[*v1.DispatchReachableResourcesResponse](ctx)
+		err := dispatcher.DispatchReachableResources(&v1.DispatchReachableResourcesRequest{
+			ObjectRelation: &core.RelationReference{Namespace: "document", Relation: relation},
+			Subject:        subject,
+			Metadata:       metadata,
+		}, stream)
+		require.NoError(err)
+		require.Len(stream.Results(), 1)
+
+		time.Sleep(10 * time.Millisecond)
+		return stream.Results()[0].Resource.Resource.Relation
+	}
+
+	require.Equal("view", lookup("view"))
+	require.Equal("view", reachable("view"))
+
+	// The equivalent relation is served from the cache, relabeled with the requested relation.
+	require.Equal("read", lookup("read"))
+	require.Equal("read", reachable("read"))
+	require.Equal("view", lookup("view"))
+	require.Equal("view", reachable("view"))
+
+	delegate.AssertExpectations(t)
+}
+
+func TestCacheSnapshot(t *testing.T) {
+	require := require.New(t)
+
+	rawDS, err := memdb.NewMemdbDatastore(0, 0, memdb.DisableGC)
+	require.NoError(err)
+
+	ds, revision := testfixtures.StandardDatastoreWithSchema(rawDS, require)
+	path := filepath.Join(t.TempDir(), "dispatch-cache.snapshot")
+
+	req := &v1.DispatchCheckRequest{
+		ObjectAndRelation: tuple.ParseONR("document:masterplan#viewer"),
+		Subject:           tuple.ParseSubjectONR("user:eng_lead#..."),
+		Metadata: &v1.ResolverMeta{
+			AtRevision:     revision.String(),
+			DepthRemaining: 50,
+		},
+	}
+
+	// Loading a snapshot which does not yet exist loads nothing.
+	delegate := delegateDispatchMock{&mock.Mock{}}
+	delegate.On("DispatchCheck", req).Return(&v1.DispatchCheckResponse{
+		Membership: v1.DispatchCheckResponse_MEMBER,
+		Metadata: &v1.ResponseMeta{
+			DispatchCount: 1,
+			DepthRequired: 1,
+		},
+	}, nil).Times(1)
+
+	dispatcher, err := NewCachingDispatcher(nil, "", nil)
+	require.NoError(err)
+	dispatcher.SetDelegate(delegate)
+	dispatcher.EnableSnapshots(path)
+
+	loaded, err := dispatcher.LoadSnapshot(context.Background(), ds)
+	require.NoError(err)
+	require.Equal(0, loaded)
+
+	_, err = dispatcher.DispatchCheck(context.Background(), req)
+	require.NoError(err)
+
+	// We have to sleep a while to let the cache converge:
+	// https://github.com/dgraph-io/ristretto/blob/01b9f37dd0fd453225e042d6f3a27cd14f252cd0/cache_test.go#L17
+	time.Sleep(10 * time.Millisecond)
+
+	require.NoError(dispatcher.Close())
+	delegate.AssertExpectations(t)
+
+	// A new dispatcher loading the snapshot serves the check from its cache.
+	restarted, err := NewCachingDispatcher(nil, "", nil)
+	require.NoError(err)
+	defer restarted.Close()
+
+	restartedDelegate := delegateDispatchMock{&mock.Mock{}}
+	restarted.SetDelegate(restartedDelegate)
+	restarted.EnableSnapshots(path)
+
+	loaded, err = restarted.LoadSnapshot(context.Background(), ds)
+	require.NoError(err)
+	require.Equal(1, loaded)
+
+	resp, err := restarted.DispatchCheck(context.Background(), req)
+	require.NoError(err)
+	require.Equal(v1.DispatchCheckResponse_MEMBER, resp.Membership)
+	restartedDelegate.AssertExpectations(t)
+}
+
+func TestCacheSnapshotDiscardsStaleRevisions(t *testing.T) {
+	require := require.New(t)
+
+	rawDS, err := memdb.NewMemdbDatastore(0, 0, memdb.DisableGC)
+	require.NoError(err)
+
+	ds, revision := testfixtures.StandardDatastoreWithSchema(rawDS, require)
+	path := filepath.Join(t.TempDir(), "dispatch-cache.snapshot")
+
+	req := &v1.DispatchCheckRequest{
+		ObjectAndRelation: tuple.ParseONR("document:masterplan#viewer"),
+		Subject:           tuple.ParseSubjectONR("user:eng_lead#..."),
+		Metadata: &v1.ResolverMeta{
+			AtRevision:     revision.String(),
+			DepthRemaining: 50,
+		},
+	}
+
+	delegate := delegateDispatchMock{&mock.Mock{}}
+	delegate.On("DispatchCheck", req).Return(&v1.DispatchCheckResponse{
+		Membership: v1.DispatchCheckResponse_MEMBER,
+		Metadata: &v1.ResponseMeta{
+			DispatchCount: 1,
+			DepthRequired: 1,
+		},
+	}, nil).Times(1)
+
+	dispatcher, err := NewCachingDispatcher(nil, "", nil)
+	require.NoError(err)
+	dispatcher.SetDelegate(delegate)
+	dispatcher.EnableSnapshots(path)
+
+	_, err = dispatcher.DispatchCheck(context.Background(), req)
+	require.NoError(err)
+	dispatcher.c.Wait()
+	require.NoError(dispatcher.Close())
+
+	// Entries computed at revisions which have since been garbage collected are not loaded.
+	restarted, err := NewCachingDispatcher(nil, "", nil)
+	require.NoError(err)
+	defer restarted.Close()
+	restarted.EnableSnapshots(path)
+
+	loaded, err := restarted.LoadSnapshot(context.Background(), staleRevisionsDatastore{ds})
+	require.NoError(err)
+	require.Equal(0, loaded)
+	require.Empty(restarted.snapshots.snapshot())
+}
+
+func TestCacheSnapshotIndexRemovesRejectedEntries(t *testing.T) {
+	require := require.New(t)
+
+	// Every entry costs more than the cache can hold, and so is rejected.
+	dispatcher, err := NewCachingDispatcher(&ristretto.Config{
+		NumCounters: 100,
+		MaxCost:     1,
+		BufferItems: 64,
+	}, "", nil)
+	require.NoError(err)
+	defer dispatcher.Close()
+	dispatcher.EnableSnapshots(filepath.Join(t.TempDir(), "dispatch-cache.snapshot"))
+
+	delegate := delegateDispatchMock{&mock.Mock{}}
+	delegate.On("DispatchCheck", mock.Anything).Return(&v1.DispatchCheckResponse{
+		Membership: v1.DispatchCheckResponse_MEMBER,
+		Metadata: &v1.ResponseMeta{
+			DispatchCount: 1,
+			DepthRequired: 1,
+		},
+	}, nil)
+	dispatcher.SetDelegate(delegate)
+
+	for _, user := range []string{"user:tom#...", "user:fred#...", "user:sarah#..."} {
+		_, err := dispatcher.DispatchCheck(context.Background(), &v1.DispatchCheckRequest{
+			ObjectAndRelation: tuple.ParseONR("document:masterplan#viewer"),
+			Subject:           tuple.ParseSubjectONR(user),
+			Metadata: &v1.ResolverMeta{
+				AtRevision:     decimal.NewFromInt(1).String(),
+				DepthRemaining: 50,
+			},
+		})
+		require.NoError(err)
+	}
+
+	dispatcher.c.Wait()
+	require.Empty(dispatcher.snapshots.snapshot())
+}
+
+func TestWarm(t *testing.T) {
+	require := require.New(t)
+
+	rawDS, err := memdb.NewMemdbDatastore(0, 0, memdb.DisableGC)
+	require.NoError(err)
+
+	ds, _ := testfixtures.StandardDatastoreWithSchema(rawDS, require)
+
+	path := filepath.Join(t.TempDir(), "warmup-checks")
+	require.NoError(os.WriteFile(path, []byte(`# Hot checks
+document:masterplan#view@user:eng_lead
+
+document:specialplan#view@user:multiroleguy
+`), 0o600))
+
+	delegate := delegateDispatchMock{&mock.Mock{}}
+	delegate.On("DispatchCheck", mock.Anything).Return(&v1.DispatchCheckResponse{
+		Membership: v1.DispatchCheckResponse_MEMBER,
+		Metadata: &v1.ResponseMeta{
+			DispatchCount: 1,
+			DepthRequired: 1,
+		},
+	}, nil).Times(2)
+
+	dispatcher, err := NewCachingDispatcher(nil, "", nil)
+	require.NoError(err)
+	defer dispatcher.Close()
+	dispatcher.SetDelegate(delegate)
+
+	warmed, err := Warm(context.Background(), dispatcher, ds, path, 50)
+	require.NoError(err)
+	require.Equal(2, warmed)
+	dispatcher.c.Wait()
+
+	// The warmed checks are served from the cache.
+	for _, call := range delegate.Calls {
+		req := call.Arguments.Get(0).(*v1.DispatchCheckRequest)
+		resp, err := dispatcher.DispatchCheck(datastoremw.ContextWithDatastore(context.Background(), ds), req)
+		require.NoError(err)
+		require.Equal(v1.DispatchCheckResponse_MEMBER, resp.Membership)
+	}
+	delegate.AssertExpectations(t)
+
+	// Warming stops once its context is done.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	warmed, err = Warm(ctx, dispatcher, ds, path, 50)
+	require.ErrorIs(err, context.Canceled)
+	require.Equal(0, warmed)
+	delegate.AssertNumberOfCalls(t, "DispatchCheck", 2)
+}
+
+// staleRevisionsDatastore is a datastore for which every revision has been garbage collected.
+type staleRevisionsDatastore struct {
+	datastore.Datastore
+}
+
+func (staleRevisionsDatastore) CheckRevision(ctx context.Context, revision datastore.Revision) error {
+	return datastore.NewInvalidRevisionErr(revision, datastore.RevisionStale)
+}
+
+// sharedLookupKeyHandler is a key handler which computes the same key for all lookups, as
+// would occur for relations sharing a canonical key.
+type sharedLookupKeyHandler struct {
+	keys.DirectKeyHandler
+}
+
+func (*sharedLookupKeyHandler) ComputeLookupKey(ctx context.Context, req *v1.DispatchLookupRequest) (string, error) {
+	return "lookup//shared", nil
+}
+
 type delegateDispatchMock struct {
 	*mock.Mock
 }
@@ -143,11 +784,18 @@ func (ddm delegateDispatchMock) DispatchExpand(ctx context.Context, req *v1.Disp
 }
 
 func (ddm delegateDispatchMock) DispatchLookup(ctx context.Context, req *v1.DispatchLookupRequest) (*v1.DispatchLookupResponse, error) {
-	return &v1.DispatchLookupResponse{}, nil
+	args := ddm.Called(req)
+	return args.Get(0).(*v1.DispatchLookupResponse), args.Error(1)
 }
 
 func (ddm delegateDispatchMock) DispatchReachableResources(req *v1.DispatchReachableResourcesRequest, stream dispatch.ReachableResourcesStream) error {
-	return nil
+	args := ddm.Called(req)
+	for _, result := range args.Get(0).([]*v1.DispatchReachableResourcesResponse) {
+		if err := stream.Publish(result); err != nil {
+			return err
+		}
+	}
+	return args.Error(1)
 }
 
 func (ddm delegateDispatchMock) Close() error {
@@ -0,0 +1,344 @@
+package caching
+
+import (
+	"bufio"
+	"context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/dgraph-io/ristretto"
+	"github.com/dgraph-io/ristretto/z"
+	"github.com/rs/zerolog/log"
+	"github.com/shopspring/decimal"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/authzed/spicedb/pkg/datastore"
+	v1 "github.com/authzed/spicedb/pkg/proto/dispatch/v1"
+)
+
+// snapshotFormatVersion is the version of the snapshot file format. Snapshots of other
+// versions are ignored when loaded.
+const snapshotFormatVersion = 1
+
+type snapshotEntryKind int
+
+const (
+	checkSnapshotEntry snapshotEntryKind = iota
+	lookupSnapshotEntry
+	reachableResourcesSnapshotEntry
+)
+
+type snapshotHeader struct {
+	Version    int
+	EntryCount int
+}
+
+type snapshotEntry struct {
+	Key       string
+	Kind      snapshotEntryKind
+	Revision  string
+	Responses [][]byte
+}
+
+// snapshotIndex tracks the entries in a cache, as ristretto does not support iterating
+// over its entries.
+type snapshotIndex struct {
+	keyToHash func(key interface{}) (uint64, uint64)
+
+	sync.Mutex
+	path    string
+	entries map[uint64]indexedEntry
+}
+
+type indexedEntry struct {
+	key   string
+	entry interface{}
+}
+
+func newSnapshotIndex() *snapshotIndex {
+	return &snapshotIndex{
+		keyToHash: z.KeyToHash,
+		entries:   map[uint64]indexedEntry{},
+	}
+}
+
+// wrapConfig returns a copy of the cache config which removes entries from the index
+// when they are evicted or rejected by the cache.
+func (si *snapshotIndex) wrapConfig(config *ristretto.Config) *ristretto.Config {
+	wrapped := *config
+	if config.KeyToHash != nil {
+		si.keyToHash = config.KeyToHash
+	}
+
+	onEvict := config.OnEvict
+	wrapped.OnEvict = func(item *ristretto.Item) {
+		si.remove(item.Key)
+		if onEvict != nil {
+			onEvict(item)
+		}
+	}
+
+	onReject := config.OnReject
+	wrapped.OnReject = func(item *ristretto.Item) {
+		si.remove(item.Key)
+		if onReject != nil {
+			onReject(item)
+		}
+	}
+
+	return &wrapped
+}
+
+func (si *snapshotIndex) add(key string, entry interface{}) {
+	si.Lock()
+	defer si.Unlock()
+
+	if si.path == "" {
+		return
+	}
+
+	keyHash, _ := si.keyToHash(key)
+	si.entries[keyHash] = indexedEntry{key, entry}
+}
+
+func (si *snapshotIndex) removeKey(key string) {
+	keyHash, _ := si.keyToHash(key)
+	si.remove(keyHash)
+}
+
+func (si *snapshotIndex) remove(keyHash uint64) {
+	si.Lock()
+	defer si.Unlock()
+	delete(si.entries, keyHash)
+}
+
+func (si *snapshotIndex) snapshot() []indexedEntry {
+	si.Lock()
+	defer si.Unlock()
+
+	entries := make([]indexedEntry, 0, len(si.entries))
+	for _, entry := range si.entries {
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// EnableSnapshots enables saving the entries of the cache to the file at the given path
+// when the dispatcher is closed, so that they can be loaded with LoadSnapshot when the
+// process restarts. It must be called before the dispatcher is used.
+func (cd *Dispatcher) EnableSnapshots(path string) {
+	cd.snapshots.Lock()
+	defer cd.snapshots.Unlock()
+	cd.snapshots.path = path
+}
+
+// LoadSnapshot loads the entries saved in the dispatcher's snapshot file into the cache,
+// discarding those computed at revisions which are no longer valid in the datastore, and
+// returns the number of entries loaded. Loading a snapshot that does not exist is a no-op.
+func (cd *Dispatcher) LoadSnapshot(ctx context.Context, ds datastore.Datastore) (int, error) {
+	file, err := os.Open(cd.snapshots.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("unable to open dispatch cache snapshot: %w", err)
+	}
+	defer file.Close()
+
+	decoder := gob.NewDecoder(bufio.NewReader(file))
+
+	var header snapshotHeader
+	if err := decoder.Decode(&header); err != nil {
+		return 0, fmt.Errorf("unable to read dispatch cache snapshot: %w", err)
+	}
+
+	if header.Version != snapshotFormatVersion {
+		log.Ctx(ctx).Info().Int("version", header.Version).Msg("ignoring dispatch cache snapshot with unsupported version")
+		return 0, nil
+	}
+
+	validRevisions := map[string]bool{}
+	loaded := 0
+	for i := 0; i < header.EntryCount; i++ {
+		var se snapshotEntry
+		if err := decoder.Decode(&se); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return loaded, fmt.Errorf("unable to read dispatch cache snapshot: %w", err)
+		}
+
+		valid, ok := validRevisions[se.Revision]
+		if !ok {
+			revision, err := decimal.NewFromString(se.Revision)
+			if err != nil {
+				return loaded, fmt.Errorf("invalid revision in dispatch cache snapshot: %w", err)
+			}
+
+			// Entries computed at revisions which have since been garbage collected are
+			// discarded, as they would never be requested.
+			valid = ds.CheckRevision(ctx, revision) == nil
+			validRevisions[se.Revision] = valid
+		}
+
+		if !valid {
+			continue
+		}
+
+		entry, cost, err := decodeSnapshotEntry(se)
+		if err != nil {
+			return loaded, err
+		}
+
+		cd.set(se.Key, entry, cost)
+		loaded++
+	}
+
+	// Wait for the entries to be applied to the cache before it is used.
+	cd.c.Wait()
+
+	return loaded, nil
+}
+
+func (cd *Dispatcher) saveSnapshot() error {
+	entries := cd.snapshots.snapshot()
+
+	// The snapshot is written to a temporary file and then renamed over the existing
+	// snapshot, so that an interrupted save does not leave a partial snapshot.
+	tempPath := cd.snapshots.path + ".tmp"
+	file, err := os.Create(tempPath)
+	if err != nil {
+		return fmt.Errorf("unable to create dispatch cache snapshot: %w", err)
+	}
+	defer os.Remove(tempPath)
+
+	writer := bufio.NewWriter(file)
+	encoder := gob.NewEncoder(writer)
+
+	if err := encoder.Encode(snapshotHeader{snapshotFormatVersion, len(entries)}); err != nil {
+		file.Close()
+		return fmt.Errorf("unable to write dispatch cache snapshot: %w", err)
+	}
+
+	for _, indexed := range entries {
+		se, err := encodeSnapshotEntry(indexed)
+		if err != nil {
+			file.Close()
+			return err
+		}
+
+		if err := encoder.Encode(se); err != nil {
+			file.Close()
+			return fmt.Errorf("unable to write dispatch cache snapshot: %w", err)
+		}
+	}
+
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return fmt.Errorf("unable to write dispatch cache snapshot: %w", err)
+	}
+
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("unable to write dispatch cache snapshot: %w", err)
+	}
+
+	if err := os.Rename(tempPath, cd.snapshots.path); err != nil {
+		return fmt.Errorf("unable to write dispatch cache snapshot: %w", err)
+	}
+
+	log.Info().Int("entries", len(entries)).Str("path", cd.snapshots.path).Msg("saved dispatch cache snapshot")
+	return nil
+}
+
+func encodeSnapshotEntry(indexed indexedEntry) (snapshotEntry, error) {
+	se := snapshotEntry{Key: indexed.key}
+
+	var responses []proto.Message
+	switch entry := indexed.entry.(type) {
+	case checkResultEntry:
+		se.Kind = checkSnapshotEntry
+		se.Revision = entry.revision.String()
+		responses = []proto.Message{entry.response}
+
+	case lookupResultEntry:
+		se.Kind = lookupSnapshotEntry
+		se.Revision = entry.revision.String()
+		responses = []proto.Message{entry.response}
+
+	case reachableResourcesResultEntry:
+		se.Kind = reachableResourcesSnapshotEntry
+		se.Revision = entry.revision.String()
+		for _, response := range entry.responses {
+			responses = append(responses, response)
+		}
+
+	default:
+		return se, fmt.Errorf("unknown dispatch cache entry type: %T", indexed.entry)
+	}
+
+	for _, response := range responses {
+		marshaled, err := proto.Marshal(response)
+		if err != nil {
+			return se, fmt.Errorf("unable to encode dispatch cache entry: %w", err)
+		}
+		se.Responses = append(se.Responses, marshaled)
+	}
+
+	return se, nil
+}
+
+func decodeSnapshotEntry(se snapshotEntry) (interface{}, int64, error) {
+	revision, err := decimal.NewFromString(se.Revision)
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid revision in dispatch cache snapshot: %w", err)
+	}
+
+	switch se.Kind {
+	case checkSnapshotEntry:
+		if len(se.Responses) != 1 {
+			return nil, 0, errors.New("invalid check entry in dispatch cache snapshot")
+		}
+
+		response := &v1.DispatchCheckResponse{}
+		if err := proto.Unmarshal(se.Responses[0], response); err != nil {
+			return nil, 0, fmt.Errorf("unable to decode dispatch cache entry: %w", err)
+		}
+
+		entry := checkResultEntry{response, revision}
+		return entry, checkEntryCost(entry), nil
+
+	case lookupSnapshotEntry:
+		if len(se.Responses) != 1 {
+			return nil, 0, errors.New("invalid lookup entry in dispatch cache snapshot")
+		}
+
+		response := &v1.DispatchLookupResponse{}
+		if err := proto.Unmarshal(se.Responses[0], response); err != nil {
+			return nil, 0, fmt.Errorf("unable to decode dispatch cache entry: %w", err)
+		}
+
+		entry := lookupResultEntry{response, revision}
+		return entry, lookupEntryCost(entry), nil
+
+	case reachableResourcesSnapshotEntry:
+		responses := make([]*v1.DispatchReachableResourcesResponse, 0, len(se.Responses))
+		for _, marshaled := range se.Responses {
+			response := &v1.DispatchReachableResourcesResponse{}
+			if err := proto.Unmarshal(marshaled, response); err != nil {
+				return nil, 0, fmt.Errorf("unable to decode dispatch cache entry: %w", err)
+			}
+			responses = append(responses, response)
+		}
+
+		// The relations read are not saved, so the entry is only valid at its own revision.
+		entry := reachableResourcesResultEntry{responses, revision, nil}
+		return entry, reachableResourcesEntryCost(entry), nil
+
+	default:
+		return nil, 0, fmt.Errorf("unknown entry kind in dispatch cache snapshot: %d", se.Kind)
+	}
+}
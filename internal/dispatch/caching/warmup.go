@@ -0,0 +1,89 @@
+package caching
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/authzed/spicedb/internal/dispatch"
+	datastoremw "github.com/authzed/spicedb/internal/middleware/datastore"
+	"github.com/authzed/spicedb/pkg/datastore"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	v1 "github.com/authzed/spicedb/pkg/proto/dispatch/v1"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+// Warm replays the checks listed in the file at the given path through the dispatcher, at
+// the datastore's optimized revision, so that their results are cached before the dispatcher
+// serves requests. The file lists one check per line in relationship form, such as
+// `document:firstdoc#view@user:tom`, ignoring blank lines and those starting with `#`.
+//
+// Returns the number of checks performed. Checks which fail are logged and skipped.
+func Warm(ctx context.Context, d dispatch.Check, ds datastore.Datastore, path string, depth uint32) (int, error) {
+	checks, err := readWarmupChecks(path)
+	if err != nil {
+		return 0, err
+	}
+
+	revision, err := ds.OptimizedRevision(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("unable to warm dispatch cache: %w", err)
+	}
+
+	ctx = datastoremw.ContextWithDatastore(ctx, ds)
+
+	warmed := 0
+	for _, check := range checks {
+		if ctx.Err() != nil {
+			return warmed, ctx.Err()
+		}
+
+		_, err := d.DispatchCheck(ctx, &v1.DispatchCheckRequest{
+			Metadata: &v1.ResolverMeta{
+				AtRevision:     revision.String(),
+				DepthRemaining: depth,
+			},
+			ObjectAndRelation: check.ObjectAndRelation,
+			Subject:           check.User.GetUserset(),
+		})
+		if err != nil {
+			log.Ctx(ctx).Warn().Err(err).Str("check", tuple.String(check)).Msg("unable to warm dispatch cache")
+			continue
+		}
+		warmed++
+	}
+
+	return warmed, nil
+}
+
+func readWarmupChecks(path string) ([]*core.RelationTuple, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read dispatch cache warmup checks: %w", err)
+	}
+	defer file.Close()
+
+	var checks []*core.RelationTuple
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		check := tuple.Parse(line)
+		if check == nil {
+			return nil, fmt.Errorf("invalid dispatch cache warmup check: %q", line)
+		}
+		checks = append(checks, check)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read dispatch cache warmup checks: %w", err)
+	}
+	return checks, nil
+}
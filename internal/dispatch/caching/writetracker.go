@@ -0,0 +1,204 @@
+package caching
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/shopspring/decimal"
+
+	datastoremw "github.com/authzed/spicedb/internal/middleware/datastore"
+	"github.com/authzed/spicedb/pkg/datastore"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+)
+
+const watchRestartDelay = 1 * time.Second
+
+var errWatchClosed = errors.New("watch closed unexpectedly")
+
+// WriteTracker watches a datastore for relationship changes, recording the last revision at
+// which each relation was written. It is used by the caching dispatcher to determine whether
+// a result computed at one revision remains valid at a later revision.
+//
+// Changes are only known to have been observed up to the tracker's watermark, which advances
+// only to the revisions of the changes and checkpoints delivered by the datastore's Watch.
+type WriteTracker struct {
+	ds datastore.Datastore
+
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	sync.RWMutex
+	lastWritten map[string]decimal.Decimal
+	floor       decimal.Decimal
+	watermark   decimal.Decimal
+	advanced    *sync.Cond
+}
+
+// NewWriteTracker creates a new WriteTracker and begins watching the datastore for changes.
+func NewWriteTracker(ds datastore.Datastore) (*WriteTracker, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	head, err := ds.HeadRevision(ctx)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("unable to start write tracker: %w", err)
+	}
+
+	wt := &WriteTracker{
+		ds:          ds,
+		cancel:      cancel,
+		done:        make(chan struct{}),
+		lastWritten: map[string]decimal.Decimal{},
+		floor:       head,
+		watermark:   head,
+	}
+	wt.advanced = sync.NewCond(wt.RLocker())
+
+	go wt.run(ctx)
+	return wt, nil
+}
+
+// IsUnchanged returns true if none of the given relations, nor the definitions of their
+// namespaces, were written after computedAt and up to and including requestedAt.
+func (wt *WriteTracker) IsUnchanged(ctx context.Context, relations []*core.RelationReference, computedAt, requestedAt decimal.Decimal) (bool, error) {
+	if requestedAt.LessThan(computedAt) {
+		return false, nil
+	}
+
+	if !wt.relationsUnchanged(relations, computedAt, requestedAt) {
+		return false, nil
+	}
+
+	// Namespace definition changes are not reported by Watch, so they are checked against
+	// the revision at which each definition was last written.
+	ds := datastoremw.MustFromContext(ctx).SnapshotReader(requestedAt)
+	checked := make(map[string]struct{}, len(relations))
+	for _, relation := range relations {
+		if _, ok := checked[relation.Namespace]; ok {
+			continue
+		}
+		checked[relation.Namespace] = struct{}{}
+
+		_, lastWritten, err := ds.ReadNamespace(ctx, relation.Namespace)
+		if err != nil {
+			if errors.As(err, &datastore.ErrNamespaceNotFound{}) {
+				return false, nil
+			}
+			return false, err
+		}
+
+		if lastWritten.GreaterThan(computedAt) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+func (wt *WriteTracker) relationsUnchanged(relations []*core.RelationReference, computedAt, requestedAt decimal.Decimal) bool {
+	wt.RLock()
+	defer wt.RUnlock()
+
+	if computedAt.LessThan(wt.floor) || requestedAt.GreaterThan(wt.watermark) {
+		return false
+	}
+
+	for _, relation := range relations {
+		lastWritten, ok := wt.lastWritten[relationKey(relation.Namespace, relation.Relation)]
+		if ok && lastWritten.GreaterThan(computedAt) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Close stops watching the datastore.
+func (wt *WriteTracker) Close() error {
+	wt.cancel()
+	<-wt.done
+	return nil
+}
+
+func (wt *WriteTracker) run(ctx context.Context) {
+	defer close(wt.done)
+
+	for {
+		wt.RLock()
+		afterRevision := wt.watermark
+		wt.RUnlock()
+
+		changes, errs := wt.ds.Watch(datastore.WithWatchCheckpoints(ctx), afterRevision)
+		err := wt.consume(ctx, changes, errs)
+		if ctx.Err() != nil {
+			return
+		}
+
+		log.Warn().Err(err).Msg("write tracker watch failed, restarting")
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(watchRestartDelay):
+		}
+
+		// Changes may have been missed while the watch was down, so results computed before
+		// the restart can no longer be validated.
+		head, err := wt.ds.HeadRevision(ctx)
+		if err != nil {
+			log.Warn().Err(err).Msg("unable to load head revision for write tracker")
+			continue
+		}
+		wt.reset(head)
+	}
+}
+
+func (wt *WriteTracker) consume(ctx context.Context, changes <-chan *datastore.RevisionChanges, errs <-chan error) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+
+		case revChanges, ok := <-changes:
+			if !ok {
+				return errWatchClosed
+			}
+			wt.recordChanges(revChanges)
+
+		case err := <-errs:
+			return err
+		}
+	}
+}
+
+func (wt *WriteTracker) recordChanges(revChanges *datastore.RevisionChanges) {
+	wt.Lock()
+	defer wt.Unlock()
+
+	for _, change := range revChanges.Changes {
+		onr := change.Tuple.ObjectAndRelation
+		wt.lastWritten[relationKey(onr.Namespace, onr.Relation)] = revChanges.Revision
+	}
+
+	if revChanges.Revision.GreaterThan(wt.watermark) {
+		wt.watermark = revChanges.Revision
+		wt.advanced.Broadcast()
+	}
+}
+
+func (wt *WriteTracker) reset(head decimal.Decimal) {
+	wt.Lock()
+	defer wt.Unlock()
+
+	wt.lastWritten = map[string]decimal.Decimal{}
+	wt.floor = head
+	wt.watermark = head
+	wt.advanced.Broadcast()
+}
+
+func relationKey(namespace, relation string) string {
+	return namespace + "#" + relation
+}
@@ -1,12 +1,18 @@
 package cluster
 
 import (
+	"context"
+
 	"github.com/dgraph-io/ristretto"
+	"github.com/rs/zerolog/log"
 
 	"github.com/authzed/spicedb/internal/dispatch"
 	"github.com/authzed/spicedb/internal/dispatch/caching"
+	"github.com/authzed/spicedb/internal/dispatch/coalescing"
 	"github.com/authzed/spicedb/internal/dispatch/graph"
 	"github.com/authzed/spicedb/internal/dispatch/keys"
+	"github.com/authzed/spicedb/internal/dispatch/materialized"
+	"github.com/authzed/spicedb/pkg/datastore"
 )
 
 // Option is a function-style option for configuring a combined Dispatcher.
@@ -15,6 +21,10 @@ type Option func(*optionState)
 type optionState struct {
 	prometheusSubsystem string
 	cacheConfig         *ristretto.Config
+	writeTracker        *caching.WriteTracker
+	materializedIndex   *materialized.Index
+	snapshotPath        string
+	snapshotDatastore   datastore.Datastore
 }
 
 // PrometheusSubsystem sets the subsystem name for the prometheus metrics
@@ -31,6 +41,32 @@ func CacheConfig(config *ristretto.Config) Option {
 	}
 }
 
+// CacheSnapshot enables saving the local dispatcher's cache to the file at the given path
+// when the dispatcher is closed, and loads any entries previously saved there which remain
+// valid in the given datastore.
+func CacheSnapshot(path string, ds datastore.Datastore) Option {
+	return func(state *optionState) {
+		state.snapshotPath = path
+		state.snapshotDatastore = ds
+	}
+}
+
+// WriteTracker enables write-aware caching in the local dispatcher's cache, using the given
+// tracker to determine whether cached results remain valid at later revisions.
+func WriteTracker(tracker *caching.WriteTracker) Option {
+	return func(state *optionState) {
+		state.writeTracker = tracker
+	}
+}
+
+// MaterializedIndex enables answering requests for materialized relations from the given
+// index, rather than dispatching them.
+func MaterializedIndex(index *materialized.Index) Option {
+	return func(state *optionState) {
+		state.materializedIndex = index
+	}
+}
+
 // NewClusterDispatcher takes a dispatcher (such as one created by
 // combined.NewDispatcher) and returns a cluster dispatcher suitable for use as
 // the dispatcher for the dispatch grpc server.
@@ -41,6 +77,10 @@ func NewClusterDispatcher(dispatch dispatch.Dispatcher, options ...Option) (disp
 		fn(&opts)
 	}
 
+	if opts.materializedIndex != nil {
+		clusterDispatch = materialized.NewDispatcher(opts.materializedIndex, clusterDispatch)
+	}
+
 	if opts.prometheusSubsystem == "" {
 		opts.prometheusSubsystem = "dispatch"
 	}
@@ -49,6 +89,19 @@ func NewClusterDispatcher(dispatch dispatch.Dispatcher, options ...Option) (disp
 	if err != nil {
 		return nil, err
 	}
-	cachingClusterDispatch.SetDelegate(clusterDispatch)
+	if opts.writeTracker != nil {
+		cachingClusterDispatch.SetWriteTracker(opts.writeTracker)
+	}
+
+	if opts.snapshotPath != "" {
+		cachingClusterDispatch.EnableSnapshots(opts.snapshotPath)
+		loaded, err := cachingClusterDispatch.LoadSnapshot(context.Background(), opts.snapshotDatastore)
+		if err != nil {
+			log.Warn().Err(err).Str("path", opts.snapshotPath).Msg("unable to load dispatch cache snapshot")
+		} else {
+			log.Info().Int("entries", loaded).Str("path", opts.snapshotPath).Msg("loaded dispatch cache snapshot")
+		}
+	}
+	cachingClusterDispatch.SetDelegate(coalescing.NewDispatcher(clusterDispatch))
 	return cachingClusterDispatch, nil
 }
@@ -0,0 +1,190 @@
+// Package coalescing implements a dispatcher which coalesces identical dispatch requests
+// that are in flight at the same time, such that only one of them is evaluated.
+package coalescing
+
+import (
+	"context"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/authzed/spicedb/internal/dispatch"
+	v1 "github.com/authzed/spicedb/pkg/proto/dispatch/v1"
+)
+
+var coalescedCount = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "spicedb",
+	Subsystem: "dispatch",
+	Name:      "coalesced_requests_total",
+	Help:      "total number of dispatch requests answered with the result of an identical request already in flight",
+}, []string{"operation"})
+
+// Dispatcher is a dispatcher which coalesces identical check, lookup and reachable resources
+// requests which are in flight at the same time, whether within a single request or across
+// concurrent requests. The first request is evaluated by the delegate, and its result is
+// shared with the others, which report the dispatches it performed as cached dispatches.
+//
+// A request only waits on an identical request with at most as much depth remaining. As
+// every subproblem dispatched by a request has less depth remaining than the request itself,
+// a request can never wait on its own result, even when the relationships are cyclic.
+type Dispatcher struct {
+	d dispatch.Dispatcher
+
+	checks             group[*v1.DispatchCheckResponse]
+	lookups            group[*v1.DispatchLookupResponse]
+	reachableResources group[[]*v1.DispatchReachableResourcesResponse]
+}
+
+// NewDispatcher creates a new coalescing dispatcher which delegates to the given dispatcher.
+func NewDispatcher(delegate dispatch.Dispatcher) *Dispatcher {
+	return &Dispatcher{
+		d:                  delegate,
+		checks:             newGroup[*v1.DispatchCheckResponse](),
+		lookups:            newGroup[*v1.DispatchLookupResponse](),
+		reachableResources: newGroup[[]*v1.DispatchReachableResourcesResponse](),
+	}
+}
+
+// DispatchCheck implements dispatch.Check interface
+func (cd *Dispatcher) DispatchCheck(ctx context.Context, req *v1.DispatchCheckRequest) (*v1.DispatchCheckResponse, error) {
+	key, err := requestKey(&v1.DispatchCheckRequest{
+		Metadata:          revisionOnly(req.Metadata),
+		ObjectAndRelation: req.ObjectAndRelation,
+		Subject:           req.Subject,
+	})
+	if err != nil {
+		return &v1.DispatchCheckResponse{Metadata: &v1.ResponseMeta{}}, err
+	}
+
+	resp, shared, err := cd.checks.do(ctx, key, req.GetMetadata().GetDepthRemaining(), func() (*v1.DispatchCheckResponse, error) {
+		return cd.d.DispatchCheck(ctx, req)
+	})
+	if !shared {
+		if resp == nil {
+			resp = &v1.DispatchCheckResponse{Metadata: &v1.ResponseMeta{}}
+		}
+		return resp, err
+	}
+
+	coalescedCount.WithLabelValues("check").Inc()
+	adjusted := proto.Clone(resp).(*v1.DispatchCheckResponse)
+	adjusted.Metadata = asCached(adjusted.Metadata)
+	return adjusted, nil
+}
+
+// DispatchExpand implements dispatch.Expand interface and does not coalesce requests.
+func (cd *Dispatcher) DispatchExpand(ctx context.Context, req *v1.DispatchExpandRequest) (*v1.DispatchExpandResponse, error) {
+	return cd.d.DispatchExpand(ctx, req)
+}
+
+// DispatchLookup implements dispatch.Lookup interface
+func (cd *Dispatcher) DispatchLookup(ctx context.Context, req *v1.DispatchLookupRequest) (*v1.DispatchLookupResponse, error) {
+	key, err := requestKey(&v1.DispatchLookupRequest{
+		Metadata:       revisionOnly(req.Metadata),
+		ObjectRelation: req.ObjectRelation,
+		Subject:        req.Subject,
+		Limit:          req.Limit,
+		DirectStack:    req.DirectStack,
+		TtuStack:       req.TtuStack,
+	})
+	if err != nil {
+		return &v1.DispatchLookupResponse{Metadata: &v1.ResponseMeta{}}, err
+	}
+
+	resp, shared, err := cd.lookups.do(ctx, key, req.GetMetadata().GetDepthRemaining(), func() (*v1.DispatchLookupResponse, error) {
+		return cd.d.DispatchLookup(ctx, req)
+	})
+	if !shared {
+		if resp == nil {
+			resp = &v1.DispatchLookupResponse{Metadata: &v1.ResponseMeta{}}
+		}
+		return resp, err
+	}
+
+	coalescedCount.WithLabelValues("lookup").Inc()
+	adjusted := proto.Clone(resp).(*v1.DispatchLookupResponse)
+	adjusted.Metadata = asCached(adjusted.Metadata)
+	return adjusted, nil
+}
+
+// DispatchReachableResources implements dispatch.ReachableResources interface
+//
+// Results are published to the stream of the first request as they are received, and to the
+// streams of the others once the first has completed.
+func (cd *Dispatcher) DispatchReachableResources(req *v1.DispatchReachableResourcesRequest, stream dispatch.ReachableResourcesStream) error {
+	key, err := requestKey(&v1.DispatchReachableResourcesRequest{
+		Metadata:       revisionOnly(req.Metadata),
+		ObjectRelation: req.ObjectRelation,
+		Subject:        req.Subject,
+	})
+	if err != nil {
+		return err
+	}
+
+	ctx := stream.Context()
+	results, shared, err := cd.reachableResources.do(ctx, key, req.GetMetadata().GetDepthRemaining(), func() ([]*v1.DispatchReachableResourcesResponse, error) {
+		var mu sync.Mutex
+		var results []*v1.DispatchReachableResourcesResponse
+		err := cd.d.DispatchReachableResources(req, &dispatch.WrappedDispatchStream[*v1.DispatchReachableResourcesResponse]{
+			Stream: stream,
+			Ctx:    ctx,
+			Processor: func(result *v1.DispatchReachableResourcesResponse) (*v1.DispatchReachableResourcesResponse, error) {
+				mu.Lock()
+				defer mu.Unlock()
+				results = append(results, result)
+				return result, nil
+			},
+		})
+		return results, err
+	})
+	if !shared {
+		return err
+	}
+
+	coalescedCount.WithLabelValues("reachableresources").Inc()
+	for _, result := range results {
+		adjusted := proto.Clone(result).(*v1.DispatchReachableResourcesResponse)
+		adjusted.Metadata = asCached(adjusted.Metadata)
+		if err := stream.Publish(adjusted); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (cd *Dispatcher) Close() error {
+	return nil
+}
+
+// Always verify that we implement the interface
+var _ dispatch.Dispatcher = &Dispatcher{}
+
+// requestKey returns the key identifying the given request.
+func requestKey(req proto.Message) (string, error) {
+	marshaled, err := proto.MarshalOptions{Deterministic: true}.Marshal(req)
+	if err != nil {
+		return "", err
+	}
+	return string(marshaled), nil
+}
+
+// revisionOnly returns a copy of the resolver metadata with only its revision, so that
+// requests differing only in their depth remaining or budget share a key.
+func revisionOnly(metadata *v1.ResolverMeta) *v1.ResolverMeta {
+	return &v1.ResolverMeta{AtRevision: metadata.GetAtRevision()}
+}
+
+// asCached returns the metadata of a shared response, with the dispatches performed to compute
+// it reported as cached dispatches.
+func asCached(metadata *v1.ResponseMeta) *v1.ResponseMeta {
+	if metadata == nil {
+		return nil
+	}
+
+	metadata.CachedDispatchCount += metadata.DispatchCount
+	metadata.DispatchCount = 0
+	return metadata
+}
@@ -0,0 +1,216 @@
+package coalescing
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/goleak"
+
+	"github.com/authzed/spicedb/internal/dispatch"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	v1 "github.com/authzed/spicedb/pkg/proto/dispatch/v1"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+var (
+	errKnown = errors.New("known error")
+
+	waitFor = time.Second
+	tick    = time.Millisecond
+)
+
+func TestCoalescedCheck(t *testing.T) {
+	testCases := []struct {
+		name                   string
+		leaderDepth            uint32
+		followerDepth          uint32
+		leaderErr              error
+		expectedCalls          int32
+		expectedDispatchCount  uint32
+		expectedCachedDispatch uint32
+	}{
+		{"identical requests are coalesced", 50, 50, nil, 1, 0, 3},
+		{"requests with more depth are coalesced", 40, 50, nil, 1, 0, 3},
+		{"requests with less depth are not coalesced", 50, 40, nil, 2, 3, 0},
+		{"failed requests are not shared", 50, 50, errKnown, 2, 3, 0},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+			require := require.New(t)
+
+			delegate := &blockingDispatcher{
+				started:  make(chan struct{}, 2),
+				release:  make(chan struct{}),
+				firstErr: tc.leaderErr,
+			}
+			cd := NewDispatcher(delegate)
+
+			var wg sync.WaitGroup
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := cd.DispatchCheck(context.Background(), checkRequest(tc.leaderDepth))
+				require.ErrorIs(err, tc.leaderErr)
+			}()
+			<-delegate.started
+
+			resultCh := make(chan *v1.DispatchCheckResponse, 1)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				resp, err := cd.DispatchCheck(context.Background(), checkRequest(tc.followerDepth))
+				require.NoError(err)
+				resultCh <- resp
+			}()
+
+			// Wait for the follower to either start its own request or join the leader's.
+			if tc.expectedCalls > 1 && tc.leaderErr == nil {
+				<-delegate.started
+			} else {
+				require.Eventually(func() bool { return waitingCount(&cd.checks) == 1 }, waitFor, tick)
+			}
+
+			close(delegate.release)
+			wg.Wait()
+
+			resp := <-resultCh
+			require.Equal(v1.DispatchCheckResponse_MEMBER, resp.Membership)
+			require.Equal(tc.expectedDispatchCount, resp.Metadata.DispatchCount)
+			require.Equal(tc.expectedCachedDispatch, resp.Metadata.CachedDispatchCount)
+			require.Equal(tc.expectedCalls, atomic.LoadInt32(&delegate.calls))
+		})
+	}
+}
+
+func TestCoalescedReachableResources(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+	require := require.New(t)
+
+	delegate := &blockingDispatcher{
+		started: make(chan struct{}, 2),
+		release: make(chan struct{}),
+	}
+	cd := NewDispatcher(delegate)
+
+	req := &v1.DispatchReachableResourcesRequest{
+		ObjectRelation: &core.RelationReference{Namespace: "document", Relation: "view"},
+		Subject:        tuple.ParseSubjectONR("user:tom#..."),
+		Metadata:       &v1.ResolverMeta{AtRevision: "1", DepthRemaining: 50},
+	}
+
+	leaderStream := dispatch.NewCollectingDispatchStream[*v1.DispatchReachableResourcesResponse](context.Background())
+	followerStream := dispatch.NewCollectingDispatchStream[*v1.DispatchReachableResourcesResponse](context.Background())
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		require.NoError(cd.DispatchReachableResources(req, leaderStream))
+	}()
+	<-delegate.started
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		require.NoError(cd.DispatchReachableResources(req, followerStream))
+	}()
+	require.Eventually(func() bool { return waitingCount(&cd.reachableResources) == 1 }, waitFor, tick)
+
+	close(delegate.release)
+	wg.Wait()
+
+	require.Equal(int32(1), atomic.LoadInt32(&delegate.calls))
+	require.Len(leaderStream.Results(), 1)
+	require.Len(followerStream.Results(), 1)
+	require.Equal(uint32(1), leaderStream.Results()[0].Metadata.DispatchCount)
+	require.Equal(uint32(0), followerStream.Results()[0].Metadata.DispatchCount)
+	require.Equal(uint32(2), followerStream.Results()[0].Metadata.CachedDispatchCount)
+}
+
+func checkRequest(depthRemaining uint32) *v1.DispatchCheckRequest {
+	return &v1.DispatchCheckRequest{
+		ObjectAndRelation: tuple.ParseONR("document:masterplan#view"),
+		Subject:           tuple.ParseSubjectONR("user:tom#..."),
+		Metadata:          &v1.ResolverMeta{AtRevision: "1", DepthRemaining: depthRemaining},
+	}
+}
+
+// waitingCount returns the number of requests waiting on requests in flight in the group.
+func waitingCount[T any](g *group[T]) int {
+	g.Lock()
+	defer g.Unlock()
+
+	waiting := 0
+	for _, f := range g.flights {
+		waiting += f.waiting
+	}
+	return waiting
+}
+
+// blockingDispatcher is a dispatcher whose requests block until released, failing the first
+// request with firstErr, if set.
+type blockingDispatcher struct {
+	started  chan struct{}
+	release  chan struct{}
+	firstErr error
+	calls    int32
+}
+
+func (bd *blockingDispatcher) wait() error {
+	call := atomic.AddInt32(&bd.calls, 1)
+	bd.started <- struct{}{}
+	<-bd.release
+
+	if call == 1 {
+		return bd.firstErr
+	}
+	return nil
+}
+
+func (bd *blockingDispatcher) DispatchCheck(ctx context.Context, req *v1.DispatchCheckRequest) (*v1.DispatchCheckResponse, error) {
+	if err := bd.wait(); err != nil {
+		return &v1.DispatchCheckResponse{Metadata: &v1.ResponseMeta{DispatchCount: 1}}, err
+	}
+
+	return &v1.DispatchCheckResponse{
+		Membership: v1.DispatchCheckResponse_MEMBER,
+		Metadata:   &v1.ResponseMeta{DispatchCount: 3},
+	}, nil
+}
+
+func (bd *blockingDispatcher) DispatchExpand(ctx context.Context, req *v1.DispatchExpandRequest) (*v1.DispatchExpandResponse, error) {
+	return &v1.DispatchExpandResponse{}, nil
+}
+
+func (bd *blockingDispatcher) DispatchLookup(ctx context.Context, req *v1.DispatchLookupRequest) (*v1.DispatchLookupResponse, error) {
+	if err := bd.wait(); err != nil {
+		return &v1.DispatchLookupResponse{Metadata: &v1.ResponseMeta{DispatchCount: 1}}, err
+	}
+	return &v1.DispatchLookupResponse{Metadata: &v1.ResponseMeta{DispatchCount: 3}}, nil
+}
+
+func (bd *blockingDispatcher) DispatchReachableResources(req *v1.DispatchReachableResourcesRequest, stream dispatch.ReachableResourcesStream) error {
+	if err := bd.wait(); err != nil {
+		return err
+	}
+
+	return stream.Publish(&v1.DispatchReachableResourcesResponse{
+		Resource: &v1.ReachableResource{
+			Resource:     tuple.ParseONR("document:masterplan#view"),
+			ResultStatus: v1.ReachableResource_HAS_PERMISSION,
+		},
+		Metadata: &v1.ResponseMeta{DispatchCount: 1, CachedDispatchCount: 1},
+	})
+}
+
+func (bd *blockingDispatcher) Close() error {
+	return nil
+}
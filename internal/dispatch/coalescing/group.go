@@ -0,0 +1,79 @@
+package coalescing
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// errFlightAborted is the error of a request in flight which did not complete.
+var errFlightAborted = errors.New("coalesced dispatch request did not complete")
+
+// group tracks the requests in flight for a single kind of dispatch, by key.
+type group[T any] struct {
+	sync.Mutex
+	flights map[string]*flight[T]
+}
+
+// flight is a request in flight, whose result is shared with identical requests.
+type flight[T any] struct {
+	depthRemaining uint32
+	done           chan struct{}
+	waiting        int
+
+	result T
+	err    error
+}
+
+func newGroup[T any]() group[T] {
+	return group[T]{flights: map[string]*flight[T]{}}
+}
+
+// do runs the given function for the request with the given key, unless an identical request
+// with at most as much depth remaining is already in flight, in which case its result is
+// returned instead and shared is true.
+//
+// Results are only shared if they were computed successfully: if the request in flight fails,
+// the function is run for the waiting request itself.
+func (g *group[T]) do(ctx context.Context, key string, depthRemaining uint32, run func() (T, error)) (result T, shared bool, err error) {
+	g.Lock()
+	existing, ok := g.flights[key]
+	if ok && existing.depthRemaining <= depthRemaining {
+		existing.waiting++
+		g.Unlock()
+
+		select {
+		case <-existing.done:
+			if existing.err == nil {
+				return existing.result, true, nil
+			}
+			result, err := run()
+			return result, false, err
+
+		case <-ctx.Done():
+			return result, false, ctx.Err()
+		}
+	}
+
+	// Requests with less depth remaining than the request in flight may be one of its own
+	// subproblems, and so are evaluated independently.
+	if ok {
+		g.Unlock()
+		result, err := run()
+		return result, false, err
+	}
+
+	f := &flight[T]{depthRemaining: depthRemaining, done: make(chan struct{}), err: errFlightAborted}
+	g.flights[key] = f
+	g.Unlock()
+
+	defer func() {
+		g.Lock()
+		delete(g.flights, key)
+		g.Unlock()
+		close(f.done)
+	}()
+
+	f.result, f.err = run()
+	return f.result, false, f.err
+}
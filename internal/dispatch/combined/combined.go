@@ -3,7 +3,9 @@
 package combined
 
 import (
+	"context"
 	"os"
+	"time"
 
 	"github.com/authzed/grpcutil"
 	"github.com/dgraph-io/ristretto"
@@ -13,9 +15,13 @@ import (
 
 	"github.com/authzed/spicedb/internal/dispatch"
 	"github.com/authzed/spicedb/internal/dispatch/caching"
+	"github.com/authzed/spicedb/internal/dispatch/coalescing"
 	"github.com/authzed/spicedb/internal/dispatch/graph"
 	"github.com/authzed/spicedb/internal/dispatch/keys"
+	"github.com/authzed/spicedb/internal/dispatch/materialized"
 	"github.com/authzed/spicedb/internal/dispatch/remote"
+	"github.com/authzed/spicedb/pkg/balancer"
+	"github.com/authzed/spicedb/pkg/datastore"
 	v1 "github.com/authzed/spicedb/pkg/proto/dispatch/v1"
 )
 
@@ -29,6 +35,14 @@ type optionState struct {
 	grpcPresharedKey    string
 	grpcDialOpts        []grpc.DialOption
 	cacheConfig         *ristretto.Config
+	writeTracker        *caching.WriteTracker
+	materializedIndex   *materialized.Index
+	snapshotPath        string
+	snapshotDatastore   datastore.Datastore
+	remoteOptions       []remote.Option
+	hedgeLocally        bool
+	localityAware       bool
+	router              *balancer.Router
 }
 
 // PrometheusSubsystem sets the subsystem name for the prometheus metrics
@@ -76,6 +90,75 @@ func CacheConfig(config *ristretto.Config) Option {
 	}
 }
 
+// CacheSnapshot enables saving the local dispatcher's cache to the file at the given path
+// when the dispatcher is closed, and loads any entries previously saved there which remain
+// valid in the given datastore.
+func CacheSnapshot(path string, ds datastore.Datastore) Option {
+	return func(state *optionState) {
+		state.snapshotPath = path
+		state.snapshotDatastore = ds
+	}
+}
+
+// WriteTracker enables write-aware caching in the local dispatcher's cache, using the given
+// tracker to determine whether cached results remain valid at later revisions.
+func WriteTracker(tracker *caching.WriteTracker) Option {
+	return func(state *optionState) {
+		state.writeTracker = tracker
+	}
+}
+
+// MaterializedIndex enables answering requests for materialized relations from the given
+// index, rather than dispatching them.
+func MaterializedIndex(index *materialized.Index) Option {
+	return func(state *optionState) {
+		state.materializedIndex = index
+	}
+}
+
+// Hedging enables hedging of requests dispatched to the optional upstream.
+func Hedging(initialSlowRequestThreshold time.Duration, maxSampleCount uint64, quantile float64) Option {
+	return func(state *optionState) {
+		state.remoteOptions = append(state.remoteOptions, remote.Hedging(initialSlowRequestThreshold, maxSampleCount, quantile))
+	}
+}
+
+// Failover enables retrying requests to the optional upstream which fail because their node
+// in the cluster is unavailable.
+func Failover(enabled bool) Option {
+	return func(state *optionState) {
+		if enabled {
+			state.remoteOptions = append(state.remoteOptions, remote.Failover())
+		}
+	}
+}
+
+// HedgeLocally sets whether hedged and failover requests to the optional upstream are
+// evaluated locally, rather than being sent to another node in the cluster.
+func HedgeLocally(enabled bool) Option {
+	return func(state *optionState) {
+		state.hedgeLocally = enabled
+	}
+}
+
+// LocalityAware sets whether cheap subproblems, which can be resolved with a single query
+// for direct relationships, are evaluated locally rather than being sent to the optional
+// upstream.
+func LocalityAware(enabled bool) Option {
+	return func(state *optionState) {
+		state.localityAware = enabled
+	}
+}
+
+// Multiplexing enables multiplexing requests to each node in the cluster over a single stream.
+// The optional upstream must be dialed with the service config of the given router, as set
+// with GrpcDialOpts.
+func Multiplexing(router *balancer.Router) Option {
+	return func(state *optionState) {
+		state.router = router
+	}
+}
+
 // NewDispatcher initializes a Dispatcher that caches and redispatches
 // optionally to the provided upstream.
 func NewDispatcher(options ...Option) (dispatch.Dispatcher, error) {
@@ -94,6 +177,20 @@ func NewDispatcher(options ...Option) (dispatch.Dispatcher, error) {
 		return nil, err
 	}
 
+	if opts.writeTracker != nil {
+		cachingRedispatch.SetWriteTracker(opts.writeTracker)
+	}
+
+	if opts.snapshotPath != "" {
+		cachingRedispatch.EnableSnapshots(opts.snapshotPath)
+		loaded, err := cachingRedispatch.LoadSnapshot(context.Background(), opts.snapshotDatastore)
+		if err != nil {
+			log.Warn().Err(err).Str("path", opts.snapshotPath).Msg("unable to load dispatch cache snapshot")
+		} else {
+			log.Info().Int("entries", loaded).Str("path", opts.snapshotPath).Msg("loaded dispatch cache snapshot")
+		}
+	}
+
 	redispatch := graph.NewDispatcher(cachingRedispatch)
 
 	// If an upstream is specified, create a cluster dispatcher.
@@ -114,10 +211,26 @@ func NewDispatcher(options ...Option) (dispatch.Dispatcher, error) {
 		if err != nil {
 			return nil, err
 		}
-		redispatch = remote.NewClusterDispatcher(v1.NewDispatchServiceClient(conn), &keys.CanonicalKeyHandler{})
+
+		remoteOptions := opts.remoteOptions
+		if opts.hedgeLocally {
+			remoteOptions = append(remoteOptions, remote.LocalFallback(redispatch))
+		}
+		if opts.localityAware {
+			remoteOptions = append(remoteOptions, remote.LocalEvaluation(redispatch))
+		}
+		client := v1.NewDispatchServiceClient(conn)
+		if opts.router != nil {
+			remoteOptions = append(remoteOptions, remote.Multiplexing(client, opts.router))
+		}
+		redispatch = remote.NewClusterDispatcher(client, &keys.CanonicalKeyHandler{}, remoteOptions...)
+	}
+
+	if opts.materializedIndex != nil {
+		redispatch = materialized.NewDispatcher(opts.materializedIndex, redispatch)
 	}
 
-	cachingRedispatch.SetDelegate(redispatch)
+	cachingRedispatch.SetDelegate(coalescing.NewDispatcher(redispatch))
 
 	return cachingRedispatch, nil
 }
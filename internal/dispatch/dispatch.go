@@ -99,12 +99,45 @@ func LookupRequestToKey(req *v1.DispatchLookupRequest) string {
 	return fmt.Sprintf("lookup//%s#%s@%s@%s", req.ObjectRelation.Namespace, req.ObjectRelation.Relation, tuple.StringONR(req.Subject), req.Metadata.AtRevision)
 }
 
+// LookupRequestToKeyWithCanonical converts a lookup request into a cache key based
+// on the canonical key.
+func LookupRequestToKeyWithCanonical(req *v1.DispatchLookupRequest, canonicalKey string) string {
+	if canonicalKey == "" {
+		panic(fmt.Sprintf("given empty canonical key for request: %s#%s => %s", req.ObjectRelation.Namespace, req.ObjectRelation.Relation, tuple.StringONR(req.Subject)))
+	}
+
+	// NOTE: canonical cache keys are only unique *within* a version of a namespace.
+	return fmt.Sprintf("lookup//canonical/%s#%s@%s@%s", req.ObjectRelation.Namespace, canonicalKey, tuple.StringONR(req.Subject), req.Metadata.AtRevision)
+}
+
 // ExpandRequestToKey converts an expand request into a cache key
 func ExpandRequestToKey(req *v1.DispatchExpandRequest) string {
 	return fmt.Sprintf("expand//%s@%s", tuple.StringONR(req.ObjectAndRelation), req.Metadata.AtRevision)
 }
 
+// ExpandRequestToKeyWithCanonical converts an expand request into a cache key based
+// on the canonical key.
+func ExpandRequestToKeyWithCanonical(req *v1.DispatchExpandRequest, canonicalKey string) string {
+	if canonicalKey == "" {
+		panic(fmt.Sprintf("given empty canonical key for request: %s", tuple.StringONR(req.ObjectAndRelation)))
+	}
+
+	// NOTE: canonical cache keys are only unique *within* a version of a namespace.
+	return fmt.Sprintf("expand//canonical/%s:%s#%s@%s", req.ObjectAndRelation.Namespace, req.ObjectAndRelation.ObjectId, canonicalKey, req.Metadata.AtRevision)
+}
+
 // ReachableResourcesRequestToKey converts a reachable resources request into a cache key
 func ReachableResourcesRequestToKey(req *v1.DispatchReachableResourcesRequest) string {
 	return fmt.Sprintf("reachableresources//%s#%s@%s@%s", req.ObjectRelation.Namespace, req.ObjectRelation.Relation, tuple.StringONR(req.Subject), req.Metadata.AtRevision)
 }
+
+// ReachableResourcesRequestToKeyWithCanonical converts a reachable resources request into a
+// cache key based on the canonical key.
+func ReachableResourcesRequestToKeyWithCanonical(req *v1.DispatchReachableResourcesRequest, canonicalKey string) string {
+	if canonicalKey == "" {
+		panic(fmt.Sprintf("given empty canonical key for request: %s#%s => %s", req.ObjectRelation.Namespace, req.ObjectRelation.Relation, tuple.StringONR(req.Subject)))
+	}
+
+	// NOTE: canonical cache keys are only unique *within* a version of a namespace.
+	return fmt.Sprintf("reachableresources//canonical/%s#%s@%s@%s", req.ObjectRelation.Namespace, canonicalKey, tuple.StringONR(req.Subject), req.Metadata.AtRevision)
+}
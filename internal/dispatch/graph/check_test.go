@@ -137,6 +137,7 @@ func TestSimpleCheck(t *testing.T) {
 					require.NoError(err)
 					require.Equal(expected.isMember, checkResult.Membership == v1.DispatchCheckResponse_MEMBER)
 					require.GreaterOrEqual(checkResult.Metadata.DepthRequired, uint32(1))
+					require.NotEmpty(checkResult.Metadata.ReadRelations)
 				})
 			}
 		}
@@ -193,6 +194,51 @@ func TestMaxDepth(t *testing.T) {
 	require.Equal(v1.DispatchCheckResponse_UNKNOWN, checkResult.Membership)
 }
 
+func TestCheckBudget(t *testing.T) {
+	testCases := []struct {
+		name                string
+		maxDispatches       uint32
+		maxDatastoreQueries uint32
+		expectedResource    string
+	}{
+		{"unlimited", 0, 0, ""},
+		{"ample", 1000, 1000, ""},
+		{"too few dispatches", 1, 0, "dispatches"},
+		{"too few datastore queries", 0, 1, "datastore queries"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require := require.New(t)
+
+			ctx, localDispatch, revision := newLocalDispatcher(require)
+			budget := dispatch.NewBudget(tc.maxDispatches, tc.maxDatastoreQueries)
+			ctx = dispatch.ContextWithBudget(ctx, budget)
+
+			checkResult, err := localDispatch.DispatchCheck(ctx, &v1.DispatchCheckRequest{
+				ObjectAndRelation: ONR("document", "masterplan", "viewer"),
+				Subject:           ONR("user", "villain", graph.Ellipsis),
+				Metadata: &v1.ResolverMeta{
+					AtRevision:     revision.String(),
+					DepthRemaining: 50,
+				},
+			})
+
+			if tc.expectedResource == "" {
+				require.NoError(err)
+				require.Equal(v1.DispatchCheckResponse_NOT_MEMBER, checkResult.Membership)
+				require.Greater(budget.DispatchesUsed(), uint32(1))
+				require.Greater(budget.DatastoreQueriesUsed(), uint32(1))
+				return
+			}
+
+			var budgetErr dispatch.ErrBudgetExhausted
+			require.ErrorAs(err, &budgetErr)
+			require.Equal(tc.expectedResource, budgetErr.Resource())
+		})
+	}
+}
+
 func TestCheckMetadata(t *testing.T) {
 	type expected struct {
 		relation              string
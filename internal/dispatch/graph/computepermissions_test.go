@@ -0,0 +1,111 @@
+package graph
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/spicedb/internal/datastore/memdb"
+	"github.com/authzed/spicedb/internal/dispatch"
+	"github.com/authzed/spicedb/internal/graph"
+	datastoremw "github.com/authzed/spicedb/internal/middleware/datastore"
+	"github.com/authzed/spicedb/internal/testfixtures"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	v1 "github.com/authzed/spicedb/pkg/proto/dispatch/v1"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+func TestComputePermissions(t *testing.T) {
+	testCases := []struct {
+		subject  *core.ObjectAndRelation
+		expected map[string]bool
+	}{
+		{
+			ONR("user", "product_manager", graph.Ellipsis),
+			map[string]bool{"owner": true, "editor": true, "viewer": true, "viewer_and_editor": false},
+		},
+		{
+			ONR("user", "eng_lead", graph.Ellipsis),
+			map[string]bool{"owner": false, "editor": false, "viewer": true, "viewer_and_editor": false},
+		},
+		{
+			ONR("user", "legal", graph.Ellipsis),
+			map[string]bool{"owner": false, "editor": false, "viewer": true, "viewer_and_editor": false},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tuple.StringONR(tc.subject), func(t *testing.T) {
+			require := require.New(t)
+
+			rawDS, err := memdb.NewMemdbDatastore(0, 0, memdb.DisableGC)
+			require.NoError(err)
+
+			ds, revision := testfixtures.StandardDatastoreWithData(rawDS, require)
+
+			ctx := datastoremw.ContextWithHandle(context.Background())
+			require.NoError(datastoremw.SetInContext(ctx, ds))
+
+			nsDef, _, err := ds.SnapshotReader(revision).ReadNamespace(ctx, "document")
+			require.NoError(err)
+
+			var relations []*core.Relation
+			for _, relation := range nsDef.Relation {
+				if _, ok := tc.expected[relation.Name]; ok {
+					relations = append(relations, relation)
+				}
+			}
+
+			recording := &recordingCheckDispatcher{d: NewLocalOnlyDispatcher()}
+			responses, err := graph.ComputePermissions(ctx, recording, graph.ValidatedCheckRequest{
+				DispatchCheckRequest: &v1.DispatchCheckRequest{
+					ObjectAndRelation: &core.ObjectAndRelation{Namespace: "document", ObjectId: "masterplan"},
+					Subject:           tc.subject,
+					Metadata: &v1.ResolverMeta{
+						AtRevision:     revision.String(),
+						DepthRemaining: 50,
+					},
+				},
+				Revision: revision,
+			}, relations)
+			require.NoError(err)
+			require.Len(responses, len(relations))
+
+			for index, relation := range relations {
+				require.Equal(tc.expected[relation.Name], responses[index].Membership == v1.DispatchCheckResponse_MEMBER, relation.Name)
+			}
+
+			// Each subproblem is dispatched once, and the relations of the resource being
+			// computed are reused rather than dispatched.
+			recording.Lock()
+			defer recording.Unlock()
+
+			dispatched := map[string]int{}
+			for _, key := range recording.dispatched {
+				dispatched[key]++
+				require.Equal(1, dispatched[key], "%s dispatched more than once", key)
+			}
+			require.NotContains(dispatched, "document:masterplan#owner")
+			require.NotContains(dispatched, "document:masterplan#editor")
+		})
+	}
+}
+
+// recordingCheckDispatcher records the resources and relations of the checks dispatched.
+type recordingCheckDispatcher struct {
+	d dispatch.Check
+
+	sync.Mutex
+	dispatched []string
+}
+
+func (rcd *recordingCheckDispatcher) DispatchCheck(ctx context.Context, req *v1.DispatchCheckRequest) (*v1.DispatchCheckResponse, error) {
+	rcd.Lock()
+	rcd.dispatched = append(rcd.dispatched, tuple.StringONR(req.ObjectAndRelation))
+	rcd.Unlock()
+
+	return rcd.d.DispatchCheck(ctx, req)
+}
@@ -115,6 +115,11 @@ func (ld *localDispatcher) DispatchCheck(ctx context.Context, req *v1.DispatchCh
 		return &v1.DispatchCheckResponse{Metadata: emptyMetadata}, err
 	}
 
+	err = dispatch.ConsumeDispatch(ctx)
+	if err != nil {
+		return &v1.DispatchCheckResponse{Metadata: emptyMetadata}, err
+	}
+
 	revision, err := decimal.NewFromString(req.Metadata.AtRevision)
 	if err != nil {
 		return &v1.DispatchCheckResponse{Metadata: emptyMetadata}, err
@@ -177,6 +182,11 @@ func (ld *localDispatcher) DispatchExpand(ctx context.Context, req *v1.DispatchE
 		return &v1.DispatchExpandResponse{Metadata: emptyMetadata}, err
 	}
 
+	err = dispatch.ConsumeDispatch(ctx)
+	if err != nil {
+		return &v1.DispatchExpandResponse{Metadata: emptyMetadata}, err
+	}
+
 	revision, err := decimal.NewFromString(req.Metadata.AtRevision)
 	if err != nil {
 		return &v1.DispatchExpandResponse{Metadata: emptyMetadata}, err
@@ -214,6 +224,11 @@ func (ld *localDispatcher) DispatchLookup(ctx context.Context, req *v1.DispatchL
 		return &v1.DispatchLookupResponse{Metadata: emptyMetadata}, err
 	}
 
+	err = dispatch.ConsumeDispatch(ctx)
+	if err != nil {
+		return &v1.DispatchLookupResponse{Metadata: emptyMetadata}, err
+	}
+
 	revision, err := decimal.NewFromString(req.Metadata.AtRevision)
 	if err != nil {
 		return &v1.DispatchLookupResponse{Metadata: emptyMetadata}, err
@@ -247,6 +262,11 @@ func (ld *localDispatcher) DispatchReachableResources(
 		return err
 	}
 
+	err = dispatch.ConsumeDispatch(ctx)
+	if err != nil {
+		return err
+	}
+
 	revision, err := decimal.NewFromString(req.Metadata.AtRevision)
 	if err != nil {
 		return err
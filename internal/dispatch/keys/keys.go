@@ -15,6 +15,15 @@ import (
 type Handler interface {
 	// ComputeCheckKey computes the key for a Check operation.
 	ComputeCheckKey(ctx context.Context, req *v1.DispatchCheckRequest) (string, error)
+
+	// ComputeExpandKey computes the key for an Expand operation.
+	ComputeExpandKey(ctx context.Context, req *v1.DispatchExpandRequest) (string, error)
+
+	// ComputeLookupKey computes the key for a Lookup operation.
+	ComputeLookupKey(ctx context.Context, req *v1.DispatchLookupRequest) (string, error)
+
+	// ComputeReachableResourcesKey computes the key for a ReachableResources operation.
+	ComputeReachableResourcesKey(ctx context.Context, req *v1.DispatchReachableResourcesRequest) (string, error)
 }
 
 // DirectKeyHandler is a key handler that uses the relation name itself as the key.
@@ -24,8 +33,24 @@ func (d *DirectKeyHandler) ComputeCheckKey(ctx context.Context, req *v1.Dispatch
 	return dispatch.CheckRequestToKey(req), nil
 }
 
+func (d *DirectKeyHandler) ComputeExpandKey(ctx context.Context, req *v1.DispatchExpandRequest) (string, error) {
+	return dispatch.ExpandRequestToKey(req), nil
+}
+
+func (d *DirectKeyHandler) ComputeLookupKey(ctx context.Context, req *v1.DispatchLookupRequest) (string, error) {
+	return dispatch.LookupRequestToKey(req), nil
+}
+
+func (d *DirectKeyHandler) ComputeReachableResourcesKey(ctx context.Context, req *v1.DispatchReachableResourcesRequest) (string, error) {
+	return dispatch.ReachableResourcesRequestToKey(req), nil
+}
+
 // CanonicalKeyHandler is a key handler which makes use of the canonical key for relations for
 // dispatching.
+//
+// NOTE: As relations with equivalent rewrites share a canonical key, results computed for one
+// relation may be returned for another. Callers caching results which reference the requested
+// relation, such as lookup and reachable resources, must relabel them before returning them.
 type CanonicalKeyHandler struct{}
 
 func (c *CanonicalKeyHandler) ComputeCheckKey(ctx context.Context, req *v1.DispatchCheckRequest) (string, error) {
@@ -33,30 +58,87 @@ func (c *CanonicalKeyHandler) ComputeCheckKey(ctx context.Context, req *v1.Dispa
 	// we may get different results if the subject being checked matches the resource exactly, e.g.
 	// a check for `somenamespace:someobject#somerel@somenamespace:someobject#somerel`.
 	if req.ObjectAndRelation.Namespace != req.Subject.Namespace {
-		// Load the relation to get its computed cache key, if any.
-		revision, err := decimal.NewFromString(req.Metadata.AtRevision)
+		canonicalKey, err := canonicalKeyFor(ctx, req.Metadata, req.ObjectAndRelation.Namespace, req.ObjectAndRelation.Relation)
 		if err != nil {
 			return "", err
 		}
 
-		ds := datastoremw.MustFromContext(ctx).SnapshotReader(revision)
+		// TODO(jschorr): Remove this conditional once we have a verified migration ordering system that ensures a backfill migration has
+		// run after the namespace annotation code has been fully deployed by users.
+		if canonicalKey != "" {
+			return dispatch.CheckRequestToKeyWithCanonical(req, canonicalKey), nil
+		}
+	}
+
+	return dispatch.CheckRequestToKey(req), nil
+}
 
-		_, relation, err := namespace.ReadNamespaceAndRelation(
-			ctx,
-			req.ObjectAndRelation.Namespace,
-			req.ObjectAndRelation.Relation,
-			ds,
-		)
+func (c *CanonicalKeyHandler) ComputeExpandKey(ctx context.Context, req *v1.DispatchExpandRequest) (string, error) {
+	canonicalKey, err := canonicalKeyFor(ctx, req.Metadata, req.ObjectAndRelation.Namespace, req.ObjectAndRelation.Relation)
+	if err != nil {
+		return "", err
+	}
+
+	if canonicalKey != "" {
+		return dispatch.ExpandRequestToKeyWithCanonical(req, canonicalKey), nil
+	}
+
+	return dispatch.ExpandRequestToKey(req), nil
+}
+
+func (c *CanonicalKeyHandler) ComputeLookupKey(ctx context.Context, req *v1.DispatchLookupRequest) (string, error) {
+	// NOTE: As with checks, we do not use the canonicalized cache key when the subject is within
+	// the same namespace, as the subject itself may be found as a result.
+	if req.ObjectRelation.Namespace != req.Subject.Namespace {
+		canonicalKey, err := canonicalKeyFor(ctx, req.Metadata, req.ObjectRelation.Namespace, req.ObjectRelation.Relation)
 		if err != nil {
 			return "", err
 		}
 
-		// TODO(jschorr): Remove this conditional once we have a verified migration ordering system that ensures a backfill migration has
-		// run after the namespace annotation code has been fully deployed by users.
-		if relation.CanonicalCacheKey != "" {
-			return dispatch.CheckRequestToKeyWithCanonical(req, relation.CanonicalCacheKey), nil
+		if canonicalKey != "" {
+			return dispatch.LookupRequestToKeyWithCanonical(req, canonicalKey), nil
 		}
 	}
 
-	return dispatch.CheckRequestToKey(req), nil
+	return dispatch.LookupRequestToKey(req), nil
+}
+
+func (c *CanonicalKeyHandler) ComputeReachableResourcesKey(ctx context.Context, req *v1.DispatchReachableResourcesRequest) (string, error) {
+	// NOTE: As with checks, we do not use the canonicalized cache key when the subject is within
+	// the same namespace, as the subject itself may be found as a result.
+	if req.ObjectRelation.Namespace != req.Subject.Namespace {
+		canonicalKey, err := canonicalKeyFor(ctx, req.Metadata, req.ObjectRelation.Namespace, req.ObjectRelation.Relation)
+		if err != nil {
+			return "", err
+		}
+
+		if canonicalKey != "" {
+			return dispatch.ReachableResourcesRequestToKeyWithCanonical(req, canonicalKey), nil
+		}
+	}
+
+	return dispatch.ReachableResourcesRequestToKey(req), nil
+}
+
+// canonicalKeyFor loads the relation to get its computed cache key, if any. An empty key is
+// returned for relations which have not been annotated with one.
+func canonicalKeyFor(ctx context.Context, metadata *v1.ResolverMeta, namespaceName string, relationName string) (string, error) {
+	revision, err := decimal.NewFromString(metadata.AtRevision)
+	if err != nil {
+		return "", err
+	}
+
+	ds := datastoremw.MustFromContext(ctx).SnapshotReader(revision)
+
+	_, relation, err := namespace.ReadNamespaceAndRelation(
+		ctx,
+		namespaceName,
+		relationName,
+		ds,
+	)
+	if err != nil {
+		return "", err
+	}
+
+	return relation.CanonicalCacheKey, nil
 }
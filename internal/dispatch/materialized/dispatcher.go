@@ -0,0 +1,190 @@
+package materialized
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/shopspring/decimal"
+
+	"github.com/authzed/spicedb/internal/dispatch"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	v1 "github.com/authzed/spicedb/pkg/proto/dispatch/v1"
+)
+
+var indexRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "spicedb",
+	Subsystem: "dispatch",
+	Name:      "materialized_index_requests_total",
+	Help:      "total number of dispatch requests for materialized relations, by whether they were answered by the index",
+}, []string{"operation", "result"})
+
+// Dispatcher is a dispatcher which answers check, lookup and reachable resources requests for
+// materialized relations from an Index, and delegates all other requests, including those the
+// index is unable to answer at the requested revision.
+type Dispatcher struct {
+	index *Index
+	d     dispatch.Dispatcher
+}
+
+// NewDispatcher creates a new dispatcher which answers requests from the given index where
+// possible, and otherwise delegates to the given dispatcher.
+func NewDispatcher(index *Index, delegate dispatch.Dispatcher) *Dispatcher {
+	return &Dispatcher{index: index, d: delegate}
+}
+
+// DispatchCheck implements dispatch.Check interface
+func (md *Dispatcher) DispatchCheck(ctx context.Context, req *v1.DispatchCheckRequest) (*v1.DispatchCheckResponse, error) {
+	revision, err := decimal.NewFromString(req.GetMetadata().GetAtRevision())
+	if err != nil {
+		return md.d.DispatchCheck(ctx, req)
+	}
+
+	member, status, err := md.index.Check(ctx, revision, req.ObjectAndRelation, req.Subject)
+	if err != nil {
+		return &v1.DispatchCheckResponse{Metadata: &v1.ResponseMeta{}}, err
+	}
+	if !record("check", status) {
+		return md.d.DispatchCheck(ctx, req)
+	}
+
+	if err := answer(ctx, req); err != nil {
+		return &v1.DispatchCheckResponse{Metadata: &v1.ResponseMeta{}}, err
+	}
+
+	membership := v1.DispatchCheckResponse_NOT_MEMBER
+	if member {
+		membership = v1.DispatchCheckResponse_MEMBER
+	}
+	// The result depends only upon the relationships of the materialized relation, allowing
+	// it to be reused by a write-aware cache until the relation is written.
+	metadata := answeredMetadata()
+	metadata.ReadRelations = []*core.RelationReference{{
+		Namespace: req.ObjectAndRelation.Namespace,
+		Relation:  req.ObjectAndRelation.Relation,
+	}}
+	return &v1.DispatchCheckResponse{
+		Metadata:   metadata,
+		Membership: membership,
+	}, nil
+}
+
+// DispatchExpand implements dispatch.Expand interface and always delegates, as the tree of a
+// relation is not materialized.
+func (md *Dispatcher) DispatchExpand(ctx context.Context, req *v1.DispatchExpandRequest) (*v1.DispatchExpandResponse, error) {
+	return md.d.DispatchExpand(ctx, req)
+}
+
+// DispatchLookup implements dispatch.Lookup interface
+func (md *Dispatcher) DispatchLookup(ctx context.Context, req *v1.DispatchLookupRequest) (*v1.DispatchLookupResponse, error) {
+	revision, err := decimal.NewFromString(req.GetMetadata().GetAtRevision())
+	if err != nil {
+		return md.d.DispatchLookup(ctx, req)
+	}
+
+	objectIDs, status, err := md.index.LookupResources(ctx, revision, req.ObjectRelation, req.Subject)
+	if err != nil {
+		return &v1.DispatchLookupResponse{Metadata: &v1.ResponseMeta{}}, err
+	}
+	if !record("lookup", status) {
+		return md.d.DispatchLookup(ctx, req)
+	}
+
+	if err := answer(ctx, req); err != nil {
+		return &v1.DispatchLookupResponse{Metadata: &v1.ResponseMeta{}}, err
+	}
+
+	if len(objectIDs) > int(req.Limit) {
+		objectIDs = objectIDs[:req.Limit]
+	}
+
+	resolved := make([]*core.ObjectAndRelation, 0, len(objectIDs))
+	for _, objectID := range objectIDs {
+		resolved = append(resolved, &core.ObjectAndRelation{
+			Namespace: req.ObjectRelation.Namespace,
+			ObjectId:  objectID,
+			Relation:  req.ObjectRelation.Relation,
+		})
+	}
+	return &v1.DispatchLookupResponse{
+		Metadata:     answeredMetadata(),
+		ResolvedOnrs: resolved,
+	}, nil
+}
+
+// DispatchReachableResources implements dispatch.ReachableResources interface
+//
+// As materialized relations have no rewrites, every resource found by the index is published
+// as having permission.
+func (md *Dispatcher) DispatchReachableResources(req *v1.DispatchReachableResourcesRequest, stream dispatch.ReachableResourcesStream) error {
+	revision, err := decimal.NewFromString(req.GetMetadata().GetAtRevision())
+	if err != nil {
+		return md.d.DispatchReachableResources(req, stream)
+	}
+
+	ctx := stream.Context()
+	objectIDs, status, err := md.index.LookupResources(ctx, revision, req.ObjectRelation, req.Subject)
+	if err != nil {
+		return err
+	}
+	if !record("reachableresources", status) {
+		return md.d.DispatchReachableResources(req, stream)
+	}
+
+	if err := answer(ctx, req); err != nil {
+		return err
+	}
+
+	for _, objectID := range objectIDs {
+		err := stream.Publish(&v1.DispatchReachableResourcesResponse{
+			Resource: &v1.ReachableResource{
+				Resource: &core.ObjectAndRelation{
+					Namespace: req.ObjectRelation.Namespace,
+					ObjectId:  objectID,
+					Relation:  req.ObjectRelation.Relation,
+				},
+				ResultStatus: v1.ReachableResource_HAS_PERMISSION,
+			},
+			Metadata: answeredMetadata(),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close implements dispatch.Dispatcher interface. The index is owned, and closed, by the
+// caller.
+func (md *Dispatcher) Close() error {
+	return md.d.Close()
+}
+
+// record records the outcome of a request to the index and returns whether it was answered.
+func record(operation string, status Status) bool {
+	switch status {
+	case Answered:
+		indexRequests.WithLabelValues(operation, "hit").Inc()
+		return true
+	case Unavailable:
+		indexRequests.WithLabelValues(operation, "fallback").Inc()
+	}
+	return false
+}
+
+// answer accounts for a request answered by the index as a single dispatch.
+func answer(ctx context.Context, req dispatch.HasMetadata) error {
+	if err := dispatch.CheckDepth(ctx, req); err != nil {
+		return err
+	}
+	return dispatch.ConsumeDispatch(ctx)
+}
+
+func answeredMetadata() *v1.ResponseMeta {
+	return &v1.ResponseMeta{
+		DispatchCount: 1,
+		DepthRequired: 1,
+	}
+}
+
+var _ dispatch.Dispatcher = &Dispatcher{}
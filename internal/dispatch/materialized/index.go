@@ -0,0 +1,623 @@
+// Package materialized implements an index of the transitive closure of relations marked with
+// the materialize directive in the schema, and a dispatcher which answers requests for those
+// relations from the index rather than dispatching once per level of nesting.
+package materialized
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	v1_proto "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/rs/zerolog/log"
+	"github.com/shopspring/decimal"
+
+	datastoremw "github.com/authzed/spicedb/internal/middleware/datastore"
+	"github.com/authzed/spicedb/pkg/datastore"
+	nspkg "github.com/authzed/spicedb/pkg/namespace"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+const watchRestartDelay = 1 * time.Second
+
+var (
+	errWatchClosed      = errors.New("watch closed unexpectedly")
+	errRebuildRequested = errors.New("rebuild requested")
+)
+
+// Index maintains the transitive closure of each relation marked with the materialize
+// directive, such as a `group#member` relation which may contain `group#member` subjects,
+// incrementally from the datastore's Watch.
+//
+// Only relations without a userset rewrite can be materialized. Subjects which are usersets of
+// other relations are not expanded, so a subject not found in a closure containing them is
+// resolved by dispatch.
+//
+// The index answers a request at a revision, such as the revision of a ZedToken, only if its
+// contents are known to be exactly those of the datastore at that revision:
+//   - the revision is at or after the revision at which the index was built;
+//   - no change to the relation has been received after the revision;
+//   - the index's watermark has reached the revision. As with the dispatch cache's write
+//     tracker, the watermark advances only to the revisions of the changes and checkpoints
+//     delivered by the datastore's Watch; and
+//   - the namespace defining the relation has not been written since the index was built.
+//
+// Requests which do not meet these conditions, such as those at revisions newer than the
+// watermark or older than the relation's most recent change, are dispatched as normal. Results
+// from the index are therefore always those which would be computed by dispatch at the
+// requested revision.
+type Index struct {
+	ds datastore.Datastore
+
+	cancel  context.CancelFunc
+	done    chan struct{}
+	rebuild chan struct{}
+
+	sync.Mutex
+	relations map[string]*relationIndex
+	floor     decimal.Decimal
+	watermark decimal.Decimal
+
+	// definitionsVerified holds, for each namespace, the latest revision at which its
+	// definition was found not to have been written since the index was built.
+	definitionsVerified map[string]decimal.Decimal
+}
+
+// NewIndex creates a new Index, building it from the relationships in the datastore at its
+// head revision, and begins watching the datastore for changes.
+func NewIndex(ds datastore.Datastore) (*Index, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	idx := &Index{
+		ds:        ds,
+		cancel:    cancel,
+		done:      make(chan struct{}),
+		rebuild:   make(chan struct{}, 1),
+		relations: map[string]*relationIndex{},
+	}
+
+	if err := idx.build(ctx); err != nil {
+		cancel()
+		return nil, fmt.Errorf("unable to build materialized index: %w", err)
+	}
+
+	go idx.run(ctx)
+	return idx, nil
+}
+
+// Close stops watching the datastore.
+func (idx *Index) Close() error {
+	idx.cancel()
+	<-idx.done
+	return nil
+}
+
+// Check returns whether the subject is a member of the object and relation at the revision,
+// and whether the index was able to determine it.
+func (idx *Index) Check(ctx context.Context, revision decimal.Decimal, onr, subject *core.ObjectAndRelation) (member bool, status Status, err error) {
+	status, err = idx.withRelation(ctx, onr.Namespace, onr.Relation, revision, func(ri *relationIndex) bool {
+		if subject.ObjectId == tuple.PublicWildcard {
+			return false
+		}
+
+		if onr.Namespace == subject.Namespace && onr.ObjectId == subject.ObjectId && onr.Relation == subject.Relation {
+			member = true
+			return true
+		}
+
+		c := ri.closure(onr.ObjectId)
+		if c.contains(subject) {
+			member = true
+			return true
+		}
+
+		// The subject may be reachable through a userset of another relation.
+		return !c.foreign
+	})
+	return
+}
+
+// LookupResources returns the IDs of the objects of which the subject is a member of the
+// relation at the revision, sorted, and whether the index was able to determine them.
+func (idx *Index) LookupResources(ctx context.Context, revision decimal.Decimal, relation *core.RelationReference, subject *core.ObjectAndRelation) (objectIDs []string, status Status, err error) {
+	status, err = idx.withRelation(ctx, relation.Namespace, relation.Relation, revision, func(ri *relationIndex) bool {
+		// The subject may be reachable through a userset of another relation.
+		if subject.ObjectId == tuple.PublicWildcard || ri.foreignCount > 0 {
+			return false
+		}
+
+		found := ri.containing(subject)
+		if subject.Namespace == relation.Namespace && subject.Relation == relation.Relation {
+			found[subject.ObjectId] = struct{}{}
+		}
+
+		objectIDs = make([]string, 0, len(found))
+		for objectID := range found {
+			objectIDs = append(objectIDs, objectID)
+		}
+		sort.Strings(objectIDs)
+		return true
+	})
+	return
+}
+
+// Status is the outcome of a request to the index.
+type Status int
+
+const (
+	// NotIndexed indicates that the relation is not materialized.
+	NotIndexed Status = iota
+
+	// Unavailable indicates that the relation is materialized, but that the index was unable
+	// to answer the request at the requested revision.
+	Unavailable
+
+	// Answered indicates that the request was answered by the index.
+	Answered
+)
+
+// withRelation invokes the given function with the index of the given relation, if the index
+// can answer requests for it at the given revision. The function returns whether it was able
+// to answer the request.
+func (idx *Index) withRelation(ctx context.Context, namespace, relation string, revision decimal.Decimal, fn func(ri *relationIndex) bool) (Status, error) {
+	if status := idx.covers(namespace, relation, revision, nil); status != Answered {
+		return status, nil
+	}
+
+	unchanged, err := idx.definitionUnchanged(ctx, namespace, revision)
+	if err != nil || !unchanged {
+		return Unavailable, err
+	}
+
+	return idx.covers(namespace, relation, revision, fn), nil
+}
+
+func (idx *Index) covers(namespace, relation string, revision decimal.Decimal, fn func(ri *relationIndex) bool) Status {
+	idx.Lock()
+	defer idx.Unlock()
+
+	ri, ok := idx.relations[relationKey(namespace, relation)]
+	if !ok {
+		return NotIndexed
+	}
+
+	if revision.LessThan(idx.floor) || revision.GreaterThan(idx.watermark) || ri.lastWritten.GreaterThan(revision) {
+		return Unavailable
+	}
+
+	if fn != nil && !fn(ri) {
+		return Unavailable
+	}
+	return Answered
+}
+
+// definitionUnchanged returns whether the namespace defining the relation has not been written
+// since the index was built, and so the relation remains materialized, at the revision. If the
+// namespace has been written, a rebuild of the index is requested.
+//
+// As a definition unchanged at one revision is also unchanged at every earlier revision after
+// the index was built, the namespace is only read for revisions after the latest at which it was
+// found unchanged.
+func (idx *Index) definitionUnchanged(ctx context.Context, namespace string, revision decimal.Decimal) (bool, error) {
+	idx.Lock()
+	floor := idx.floor
+	verified, ok := idx.definitionsVerified[namespace]
+	idx.Unlock()
+
+	if ok && !revision.GreaterThan(verified) {
+		return true, nil
+	}
+
+	ds := datastoremw.MustFromContext(ctx).SnapshotReader(revision)
+	_, lastWritten, err := ds.ReadNamespace(ctx, namespace)
+	if err != nil {
+		if errors.As(err, &datastore.ErrNamespaceNotFound{}) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	if lastWritten.GreaterThan(floor) {
+		select {
+		case idx.rebuild <- struct{}{}:
+		default:
+		}
+		return false, nil
+	}
+
+	idx.Lock()
+	defer idx.Unlock()
+
+	// The index may have been rebuilt while the namespace was being read.
+	if idx.floor.Equal(floor) && revision.GreaterThan(idx.definitionsVerified[namespace]) {
+		idx.definitionsVerified[namespace] = revision
+	}
+	return true, nil
+}
+
+func (idx *Index) run(ctx context.Context) {
+	defer close(idx.done)
+
+	for {
+		idx.Lock()
+		afterRevision := idx.watermark
+		idx.Unlock()
+
+		changes, errs := idx.ds.Watch(datastore.WithWatchCheckpoints(ctx), afterRevision)
+		err := idx.consume(ctx, changes, errs)
+		if ctx.Err() != nil {
+			return
+		}
+
+		if !errors.Is(err, errRebuildRequested) {
+			log.Warn().Err(err).Msg("materialized index watch failed, rebuilding")
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(watchRestartDelay):
+			}
+		}
+
+		// Changes may have been missed while the watch was down and the schema may have
+		// changed, so the index is rebuilt from scratch.
+		for {
+			err := idx.build(ctx)
+			if err == nil {
+				break
+			}
+			if ctx.Err() != nil {
+				return
+			}
+			log.Warn().Err(err).Msg("unable to rebuild materialized index")
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(watchRestartDelay):
+			}
+		}
+	}
+}
+
+func (idx *Index) consume(ctx context.Context, changes <-chan *datastore.RevisionChanges, errs <-chan error) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+
+		case revChanges, ok := <-changes:
+			if !ok {
+				return errWatchClosed
+			}
+			idx.applyChanges(revChanges)
+
+		case err := <-errs:
+			return err
+
+		case <-idx.rebuild:
+			return errRebuildRequested
+		}
+	}
+}
+
+// build replaces the contents of the index with the relationships of each materialized
+// relation at the datastore's head revision.
+func (idx *Index) build(ctx context.Context) error {
+	head, err := idx.ds.HeadRevision(ctx)
+	if err != nil {
+		return err
+	}
+
+	reader := idx.ds.SnapshotReader(head)
+	nsDefs, err := reader.ListNamespaces(ctx)
+	if err != nil {
+		return err
+	}
+
+	relations := map[string]*relationIndex{}
+	for _, nsDef := range nsDefs {
+		for _, relation := range nsDef.Relation {
+			if !nspkg.IsMaterialized(relation) {
+				continue
+			}
+
+			if !materializable(relation) {
+				log.Warn().
+					Str("namespace", nsDef.Name).
+					Str("relation", relation.Name).
+					Msg("only relations without a rewrite can be materialized")
+				continue
+			}
+
+			ri, err := loadRelationIndex(ctx, reader, nsDef.Name, relation.Name)
+			if err != nil {
+				return err
+			}
+			relations[relationKey(nsDef.Name, relation.Name)] = ri
+		}
+	}
+
+	idx.Lock()
+	defer idx.Unlock()
+
+	idx.relations = relations
+	idx.floor = head
+	idx.watermark = head
+	idx.definitionsVerified = map[string]decimal.Decimal{}
+
+	log.Info().Int("relations", len(relations)).Stringer("revision", head).Msg("built materialized index")
+	return nil
+}
+
+func (idx *Index) applyChanges(revChanges *datastore.RevisionChanges) {
+	idx.Lock()
+	defer idx.Unlock()
+
+	for _, change := range revChanges.Changes {
+		onr := change.Tuple.ObjectAndRelation
+		ri, ok := idx.relations[relationKey(onr.Namespace, onr.Relation)]
+		if !ok {
+			continue
+		}
+
+		ri.apply(change)
+		ri.lastWritten = revChanges.Revision
+	}
+
+	if revChanges.Revision.GreaterThan(idx.watermark) {
+		idx.watermark = revChanges.Revision
+	}
+}
+
+func materializable(relation *core.Relation) bool {
+	return nspkg.IsMaterialized(relation) && relation.UsersetRewrite == nil
+}
+
+func relationKey(namespace, relation string) string {
+	return namespace + "#" + relation
+}
+
+// relationIndex holds the relationships of a single materialized relation, and the closures
+// computed from them.
+type relationIndex struct {
+	namespace   string
+	relation    string
+	lastWritten decimal.Decimal
+
+	// direct holds the subjects of each object's relationships, by subject key.
+	direct map[string]map[string]*core.ObjectAndRelation
+
+	// parents holds the IDs of the objects with a relationship to each subject, by subject key.
+	parents map[string]map[string]struct{}
+
+	// wildcardParents holds the IDs of the objects with a relationship to a wildcard of each
+	// namespace.
+	wildcardParents map[string]map[string]struct{}
+
+	// foreignCount is the number of relationships to usersets of other relations.
+	foreignCount int
+
+	// closures holds the closures computed for objects, which are invalidated when the
+	// relationships of the object, or of any object it transitively contains, change.
+	closures map[string]*closure
+}
+
+// closure is the set of subjects which are members of an object's relation, directly or
+// through nested usersets of the relation.
+type closure struct {
+	subjects  map[string]struct{}
+	wildcards map[string]struct{}
+
+	// foreign is set if the closure contains usersets of other relations, which are not
+	// expanded.
+	foreign bool
+}
+
+func (c *closure) contains(subject *core.ObjectAndRelation) bool {
+	if _, ok := c.subjects[tuple.StringONR(subject)]; ok {
+		return true
+	}
+	_, ok := c.wildcards[subject.Namespace]
+	return ok
+}
+
+func newRelationIndex(namespace, relation string) *relationIndex {
+	return &relationIndex{
+		namespace:       namespace,
+		relation:        relation,
+		direct:          map[string]map[string]*core.ObjectAndRelation{},
+		parents:         map[string]map[string]struct{}{},
+		wildcardParents: map[string]map[string]struct{}{},
+		closures:        map[string]*closure{},
+	}
+}
+
+func loadRelationIndex(ctx context.Context, reader datastore.Reader, namespace, relation string) (*relationIndex, error) {
+	it, err := reader.QueryRelationships(ctx, &v1_proto.RelationshipFilter{
+		ResourceType:     namespace,
+		OptionalRelation: relation,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+
+	ri := newRelationIndex(namespace, relation)
+	for tpl := it.Next(); tpl != nil; tpl = it.Next() {
+		ri.add(tpl.ObjectAndRelation.ObjectId, tpl.User.GetUserset())
+	}
+	if it.Err() != nil {
+		return nil, it.Err()
+	}
+	return ri, nil
+}
+
+func (ri *relationIndex) apply(update *core.RelationTupleUpdate) {
+	objectID := update.Tuple.ObjectAndRelation.ObjectId
+	subject := update.Tuple.User.GetUserset()
+
+	switch update.Operation {
+	case core.RelationTupleUpdate_CREATE, core.RelationTupleUpdate_TOUCH:
+		ri.add(objectID, subject)
+	case core.RelationTupleUpdate_DELETE:
+		ri.remove(objectID, subject)
+	}
+}
+
+func (ri *relationIndex) add(objectID string, subject *core.ObjectAndRelation) {
+	key := tuple.StringONR(subject)
+	subjects, ok := ri.direct[objectID]
+	if !ok {
+		subjects = map[string]*core.ObjectAndRelation{}
+		ri.direct[objectID] = subjects
+	}
+	if _, ok := subjects[key]; ok {
+		return
+	}
+	subjects[key] = subject
+
+	parents := ri.parents
+	parentKey := key
+	if subject.ObjectId == tuple.PublicWildcard {
+		parents = ri.wildcardParents
+		parentKey = subject.Namespace
+	}
+	if _, ok := parents[parentKey]; !ok {
+		parents[parentKey] = map[string]struct{}{}
+	}
+	parents[parentKey][objectID] = struct{}{}
+
+	if ri.isForeign(subject) {
+		ri.foreignCount++
+	}
+	ri.invalidate(objectID)
+}
+
+func (ri *relationIndex) remove(objectID string, subject *core.ObjectAndRelation) {
+	key := tuple.StringONR(subject)
+	subjects := ri.direct[objectID]
+	if _, ok := subjects[key]; !ok {
+		return
+	}
+	delete(subjects, key)
+	if len(subjects) == 0 {
+		delete(ri.direct, objectID)
+	}
+
+	parents := ri.parents
+	parentKey := key
+	if subject.ObjectId == tuple.PublicWildcard {
+		parents = ri.wildcardParents
+		parentKey = subject.Namespace
+	}
+	delete(parents[parentKey], objectID)
+	if len(parents[parentKey]) == 0 {
+		delete(parents, parentKey)
+	}
+
+	if ri.isForeign(subject) {
+		ri.foreignCount--
+	}
+	ri.invalidate(objectID)
+}
+
+// invalidate removes the closures of the object and of every object which transitively
+// contains it.
+func (ri *relationIndex) invalidate(objectID string) {
+	visited := map[string]struct{}{objectID: {}}
+	queue := []string{objectID}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		delete(ri.closures, current)
+
+		for parentID := range ri.parents[ri.userset(current)] {
+			if _, ok := visited[parentID]; !ok {
+				visited[parentID] = struct{}{}
+				queue = append(queue, parentID)
+			}
+		}
+	}
+}
+
+// closure returns the closure of the object, computing it if necessary.
+func (ri *relationIndex) closure(objectID string) *closure {
+	if c, ok := ri.closures[objectID]; ok {
+		return c
+	}
+
+	c := &closure{subjects: map[string]struct{}{}, wildcards: map[string]struct{}{}}
+	visited := map[string]struct{}{objectID: {}}
+	queue := []string{objectID}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		for key, subject := range ri.direct[current] {
+			if subject.ObjectId == tuple.PublicWildcard {
+				c.wildcards[subject.Namespace] = struct{}{}
+				continue
+			}
+			c.subjects[key] = struct{}{}
+
+			switch {
+			case ri.isNested(subject):
+				if _, ok := visited[subject.ObjectId]; !ok {
+					visited[subject.ObjectId] = struct{}{}
+					queue = append(queue, subject.ObjectId)
+				}
+			case ri.isForeign(subject):
+				c.foreign = true
+			}
+		}
+	}
+
+	ri.closures[objectID] = c
+	return c
+}
+
+// containing returns the IDs of the objects of which the subject is transitively a member.
+func (ri *relationIndex) containing(subject *core.ObjectAndRelation) map[string]struct{} {
+	found := map[string]struct{}{}
+	var queue []string
+	enqueue := func(objectIDs map[string]struct{}) {
+		for objectID := range objectIDs {
+			if _, ok := found[objectID]; !ok {
+				found[objectID] = struct{}{}
+				queue = append(queue, objectID)
+			}
+		}
+	}
+
+	enqueue(ri.parents[tuple.StringONR(subject)])
+	enqueue(ri.wildcardParents[subject.Namespace])
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		enqueue(ri.parents[ri.userset(current)])
+	}
+	return found
+}
+
+// userset returns the subject key of the relation's userset for the object.
+func (ri *relationIndex) userset(objectID string) string {
+	return tuple.StringONR(&core.ObjectAndRelation{
+		Namespace: ri.namespace,
+		ObjectId:  objectID,
+		Relation:  ri.relation,
+	})
+}
+
+// isNested returns whether the subject is a userset of the relation itself.
+func (ri *relationIndex) isNested(subject *core.ObjectAndRelation) bool {
+	return subject.Namespace == ri.namespace && subject.Relation == ri.relation
+}
+
+// isForeign returns whether the subject is a userset of another relation.
+func (ri *relationIndex) isForeign(subject *core.ObjectAndRelation) bool {
+	return subject.Relation != tuple.Ellipsis && !ri.isNested(subject)
+}
@@ -0,0 +1,412 @@
+package materialized
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	v1_api "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/spicedb/internal/datastore/memdb"
+	"github.com/authzed/spicedb/internal/dispatch"
+	datastoremw "github.com/authzed/spicedb/internal/middleware/datastore"
+	"github.com/authzed/spicedb/pkg/datastore"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	v1 "github.com/authzed/spicedb/pkg/proto/dispatch/v1"
+	"github.com/authzed/spicedb/pkg/schemadsl/compiler"
+	"github.com/authzed/spicedb/pkg/schemadsl/input"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+const testSchema = `definition user {}
+
+definition team {
+	relation member: user
+}
+
+definition group {
+	// spicedb:materialize
+	relation member: user | user:* | group#member | team#member
+
+	relation admin: user | group#member
+}`
+
+var testRelationships = []string{
+	"group:eng#member@user:alice",
+	"group:eng#member@group:backend#member",
+	"group:backend#member@user:bob",
+	"group:backend#member@group:infra#member",
+	"group:infra#member@user:carol",
+	"group:infra#member@group:eng#member",
+	"group:everyone#member@user:*",
+	"group:leads#admin@user:dan",
+}
+
+func TestIndexCheck(t *testing.T) {
+	ctx, ds := newTestDatastore(t)
+	idx := newTestIndex(t, ds)
+	revision := indexedRevision(ctx, t, ds, idx)
+
+	testCases := []struct {
+		resource       string
+		subject        string
+		expectedStatus Status
+		expectedMember bool
+	}{
+		{"group:eng#member", "user:alice#...", Answered, true},
+		{"group:eng#member", "user:bob#...", Answered, true},
+		{"group:eng#member", "user:carol#...", Answered, true},
+		{"group:infra#member", "user:alice#...", Answered, true},
+		{"group:backend#member", "user:dan#...", Answered, false},
+		{"group:eng#member", "group:infra#member", Answered, true},
+		{"group:eng#member", "group:eng#member", Answered, true},
+		{"group:unknown#member", "user:alice#...", Answered, false},
+		{"group:everyone#member", "user:anyone#...", Answered, true},
+		{"group:everyone#member", "user:*#...", Unavailable, false},
+		{"group:leads#admin", "user:dan#...", NotIndexed, false},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.resource+"@"+tc.subject, func(t *testing.T) {
+			require := require.New(t)
+			member, status, err := idx.Check(ctx, revision, tuple.ParseONR(tc.resource), tuple.ParseSubjectONR(tc.subject))
+			require.NoError(err)
+			require.Equal(tc.expectedStatus, status)
+			require.Equal(tc.expectedMember, member)
+		})
+	}
+}
+
+func TestIndexLookupResources(t *testing.T) {
+	ctx, ds := newTestDatastore(t)
+	idx := newTestIndex(t, ds)
+	revision := indexedRevision(ctx, t, ds, idx)
+
+	member := &core.RelationReference{Namespace: "group", Relation: "member"}
+
+	testCases := []struct {
+		subject        string
+		expectedStatus Status
+		expected       []string
+	}{
+		{"user:alice#...", Answered, []string{"eng", "everyone", "infra", "backend"}},
+		{"user:carol#...", Answered, []string{"eng", "everyone", "infra", "backend"}},
+		{"user:dan#...", Answered, []string{"everyone"}},
+		{"group:backend#member", Answered, []string{"eng", "infra", "backend"}},
+		{"user:*#...", Unavailable, nil},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.subject, func(t *testing.T) {
+			require := require.New(t)
+			objectIDs, status, err := idx.LookupResources(ctx, revision, member, tuple.ParseSubjectONR(tc.subject))
+			require.NoError(err)
+			require.Equal(tc.expectedStatus, status)
+			require.ElementsMatch(tc.expected, objectIDs)
+		})
+	}
+}
+
+func TestIndexUpdates(t *testing.T) {
+	require := require.New(t)
+	ctx, ds := newTestDatastore(t)
+	idx := newTestIndex(t, ds)
+	revision := indexedRevision(ctx, t, ds, idx)
+
+	eng := tuple.ParseONR("group:eng#member")
+	dan := tuple.ParseSubjectONR("user:dan#...")
+
+	check := func(revision decimal.Decimal) (bool, Status) {
+		member, status, err := idx.Check(ctx, revision, eng, dan)
+		require.NoError(err)
+		return member, status
+	}
+
+	member, status := check(revision)
+	require.Equal(Answered, status)
+	require.False(member)
+
+	// Revisions the index has not yet reached are not answered.
+	added := write(ctx, require, ds, tuple.Touch(tuple.MustParse("group:infra#member@user:dan")))
+	require.Eventually(func() bool {
+		_, status := check(added)
+		return status == Answered
+	}, 1*time.Second, 10*time.Millisecond)
+
+	member, _ = check(added)
+	require.True(member)
+
+	// Revisions before the most recent change to the relation are not answered.
+	_, status = check(revision)
+	require.Equal(Unavailable, status)
+
+	removed := write(ctx, require, ds, tuple.Delete(tuple.MustParse("group:backend#member@group:infra#member")))
+	require.Eventually(func() bool {
+		_, status := check(removed)
+		return status == Answered
+	}, 1*time.Second, 10*time.Millisecond)
+
+	member, _ = check(removed)
+	require.False(member)
+
+	// Writes to other relations advance the watermark without invalidating the relation.
+	unrelated := write(ctx, require, ds, tuple.Touch(tuple.MustParse("team:t#member@user:dan")))
+	require.Eventually(func() bool {
+		_, status := check(unrelated)
+		return status == Answered
+	}, 1*time.Second, 10*time.Millisecond)
+}
+
+func TestIndexDefinitionChanges(t *testing.T) {
+	require := require.New(t)
+	ctx, ds := newTestDatastore(t)
+	idx := newTestIndex(t, ds)
+	revision := indexedRevision(ctx, t, ds, idx)
+
+	eng := tuple.ParseONR("group:eng#member")
+	alice := tuple.ParseSubjectONR("user:alice#...")
+
+	// The namespace is only read to verify its definition once for each revision.
+	counting := &namespaceReadCountingDatastore{Datastore: ds}
+	countingCtx := datastoremw.ContextWithHandle(context.Background())
+	require.NoError(datastoremw.SetInContext(countingCtx, counting))
+
+	for i := 0; i < 3; i++ {
+		member, status, err := idx.Check(countingCtx, revision, eng, alice)
+		require.NoError(err)
+		require.Equal(Answered, status)
+		require.True(member)
+	}
+	require.Equal(int64(1), atomic.LoadInt64(&counting.reads))
+
+	// Once the namespace has been written, requests are not answered until the index has been
+	// rebuilt.
+	written := writeSchema(ctx, require, ds)
+	indexedRevision(ctx, t, ds, idx)
+
+	_, status, err := idx.Check(ctx, written, eng, alice)
+	require.NoError(err)
+	require.Equal(Unavailable, status)
+
+	require.Eventually(func() bool {
+		idx.Lock()
+		defer idx.Unlock()
+		return idx.floor.GreaterThanOrEqual(written)
+	}, 1*time.Second, 10*time.Millisecond)
+
+	member, status, err := idx.Check(ctx, indexedRevision(ctx, t, ds, idx), eng, alice)
+	require.NoError(err)
+	require.Equal(Answered, status)
+	require.True(member)
+}
+
+func TestIndexForeignUsersets(t *testing.T) {
+	require := require.New(t)
+	ctx, ds := newTestDatastore(t)
+
+	write(ctx, require, ds,
+		tuple.Touch(tuple.MustParse("group:backend#member@team:platform#member")),
+		tuple.Touch(tuple.MustParse("team:platform#member@user:erin")),
+	)
+
+	idx := newTestIndex(t, ds)
+	revision := indexedRevision(ctx, t, ds, idx)
+
+	// Members found in the index are answered, while others may be members of the team.
+	member, status, err := idx.Check(ctx, revision, tuple.ParseONR("group:eng#member"), tuple.ParseSubjectONR("user:alice#..."))
+	require.NoError(err)
+	require.Equal(Answered, status)
+	require.True(member)
+
+	_, status, err = idx.Check(ctx, revision, tuple.ParseONR("group:eng#member"), tuple.ParseSubjectONR("user:erin#..."))
+	require.NoError(err)
+	require.Equal(Unavailable, status)
+
+	// Groups which do not contain the team are answered.
+	member, status, err = idx.Check(ctx, revision, tuple.ParseONR("group:everyone#member"), tuple.ParseSubjectONR("user:erin#..."))
+	require.NoError(err)
+	require.Equal(Answered, status)
+	require.True(member)
+
+	_, status, err = idx.LookupResources(ctx, revision, &core.RelationReference{Namespace: "group", Relation: "member"}, tuple.ParseSubjectONR("user:alice#..."))
+	require.NoError(err)
+	require.Equal(Unavailable, status)
+}
+
+func TestDispatcher(t *testing.T) {
+	require := require.New(t)
+	ctx, ds := newTestDatastore(t)
+	idx := newTestIndex(t, ds)
+	revision := indexedRevision(ctx, t, ds, idx)
+
+	delegate := &countingDispatcher{}
+	dispatcher := NewDispatcher(idx, delegate)
+
+	metadata := &v1.ResolverMeta{AtRevision: revision.String(), DepthRemaining: 50}
+
+	resp, err := dispatcher.DispatchCheck(ctx, &v1.DispatchCheckRequest{
+		Metadata:          metadata,
+		ObjectAndRelation: tuple.ParseONR("group:eng#member"),
+		Subject:           tuple.ParseSubjectONR("user:carol#..."),
+	})
+	require.NoError(err)
+	require.Equal(v1.DispatchCheckResponse_MEMBER, resp.Membership)
+	require.Equal(uint32(1), resp.Metadata.DispatchCount)
+	require.Len(resp.Metadata.ReadRelations, 1)
+
+	lookupResp, err := dispatcher.DispatchLookup(ctx, &v1.DispatchLookupRequest{
+		Metadata:       metadata,
+		ObjectRelation: &core.RelationReference{Namespace: "group", Relation: "member"},
+		Subject:        tuple.ParseSubjectONR("user:bob#..."),
+		Limit:          2,
+	})
+	require.NoError(err)
+	require.Len(lookupResp.ResolvedOnrs, 2)
+
+	stream := dispatch.NewCollectingDispatchStream[*v1.DispatchReachableResourcesResponse](ctx)
+	err = dispatcher.DispatchReachableResources(&v1.DispatchReachableResourcesRequest{
+		Metadata:       metadata,
+		ObjectRelation: &core.RelationReference{Namespace: "group", Relation: "member"},
+		Subject:        tuple.ParseSubjectONR("user:bob#..."),
+	}, stream)
+	require.NoError(err)
+	require.Len(stream.Results(), 4)
+	for _, result := range stream.Results() {
+		require.Equal(v1.ReachableResource_HAS_PERMISSION, result.Resource.ResultStatus)
+	}
+	require.Equal(0, delegate.count)
+
+	// Relations which are not materialized, and requests without enough depth, are delegated.
+	_, err = dispatcher.DispatchCheck(ctx, &v1.DispatchCheckRequest{
+		Metadata:          metadata,
+		ObjectAndRelation: tuple.ParseONR("group:leads#admin"),
+		Subject:           tuple.ParseSubjectONR("user:dan#..."),
+	})
+	require.NoError(err)
+	require.Equal(1, delegate.count)
+
+	_, err = dispatcher.DispatchCheck(ctx, &v1.DispatchCheckRequest{
+		Metadata:          &v1.ResolverMeta{AtRevision: revision.String(), DepthRemaining: 0},
+		ObjectAndRelation: tuple.ParseONR("group:eng#member"),
+		Subject:           tuple.ParseSubjectONR("user:carol#..."),
+	})
+	require.ErrorIs(err, dispatch.ErrMaxDepth)
+}
+
+func newTestDatastore(t *testing.T) (context.Context, datastore.Datastore) {
+	require := require.New(t)
+	ds, err := memdb.NewMemdbDatastore(0, 0, memdb.DisableGC)
+	require.NoError(err)
+
+	ctx := datastoremw.ContextWithHandle(context.Background())
+	require.NoError(datastoremw.SetInContext(ctx, ds))
+
+	writeSchema(ctx, require, ds)
+
+	updates := make([]*core.RelationTupleUpdate, 0, len(testRelationships))
+	for _, rel := range testRelationships {
+		updates = append(updates, tuple.Create(tuple.MustParse(rel)))
+	}
+	write(ctx, require, ds, updates...)
+	return ctx, ds
+}
+
+func writeSchema(ctx context.Context, require *require.Assertions, ds datastore.Datastore) decimal.Decimal {
+	empty := ""
+	defs, err := compiler.Compile([]compiler.InputSchema{
+		{Source: input.Source("schema"), SchemaString: testSchema},
+	}, &empty)
+	require.NoError(err)
+
+	revision, err := ds.ReadWriteTx(ctx, func(ctx context.Context, rwt datastore.ReadWriteTransaction) error {
+		return rwt.WriteNamespaces(defs...)
+	})
+	require.NoError(err)
+	return revision
+}
+
+func newTestIndex(t *testing.T, ds datastore.Datastore) *Index {
+	idx, err := NewIndex(ds)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, idx.Close())
+	})
+	return idx
+}
+
+// indexedRevision returns the datastore's head revision, once the index has reached it.
+func indexedRevision(ctx context.Context, t *testing.T, ds datastore.Datastore, idx *Index) decimal.Decimal {
+	revision, err := ds.HeadRevision(ctx)
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		idx.Lock()
+		defer idx.Unlock()
+		return !idx.watermark.LessThan(revision)
+	}, 1*time.Second, 10*time.Millisecond)
+	return revision
+}
+
+func write(ctx context.Context, require *require.Assertions, ds datastore.Datastore, updates ...*core.RelationTupleUpdate) decimal.Decimal {
+	revision, err := ds.ReadWriteTx(ctx, func(ctx context.Context, rwt datastore.ReadWriteTransaction) error {
+		mutations := make([]*v1_api.RelationshipUpdate, 0, len(updates))
+		for _, update := range updates {
+			mutations = append(mutations, tuple.UpdateToRelationshipUpdate(update))
+		}
+		return rwt.WriteRelationships(mutations)
+	})
+	require.NoError(err)
+	return revision
+}
+
+// namespaceReadCountingDatastore counts the namespaces read from its snapshot readers.
+type namespaceReadCountingDatastore struct {
+	datastore.Datastore
+	reads int64
+}
+
+func (nrc *namespaceReadCountingDatastore) SnapshotReader(revision datastore.Revision) datastore.Reader {
+	return namespaceReadCountingReader{nrc.Datastore.SnapshotReader(revision), &nrc.reads}
+}
+
+type namespaceReadCountingReader struct {
+	datastore.Reader
+	reads *int64
+}
+
+func (nrc namespaceReadCountingReader) ReadNamespace(ctx context.Context, nsName string) (*core.NamespaceDefinition, datastore.Revision, error) {
+	atomic.AddInt64(nrc.reads, 1)
+	return nrc.Reader.ReadNamespace(ctx, nsName)
+}
+
+type countingDispatcher struct {
+	count int
+}
+
+func (cd *countingDispatcher) DispatchCheck(ctx context.Context, req *v1.DispatchCheckRequest) (*v1.DispatchCheckResponse, error) {
+	cd.count++
+	return &v1.DispatchCheckResponse{Metadata: &v1.ResponseMeta{}}, nil
+}
+
+func (cd *countingDispatcher) DispatchExpand(ctx context.Context, req *v1.DispatchExpandRequest) (*v1.DispatchExpandResponse, error) {
+	cd.count++
+	return &v1.DispatchExpandResponse{Metadata: &v1.ResponseMeta{}}, nil
+}
+
+func (cd *countingDispatcher) DispatchLookup(ctx context.Context, req *v1.DispatchLookupRequest) (*v1.DispatchLookupResponse, error) {
+	cd.count++
+	return &v1.DispatchLookupResponse{Metadata: &v1.ResponseMeta{}}, nil
+}
+
+func (cd *countingDispatcher) DispatchReachableResources(req *v1.DispatchReachableResourcesRequest, stream dispatch.ReachableResourcesStream) error {
+	cd.count++
+	return nil
+}
+
+func (cd *countingDispatcher) Close() error {
+	return nil
+}
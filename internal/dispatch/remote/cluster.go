@@ -4,12 +4,15 @@ import (
 	"context"
 	"errors"
 	"io"
+	"time"
 
+	"github.com/benbjohnson/clock"
 	"google.golang.org/grpc"
 
 	"github.com/authzed/spicedb/internal/dispatch"
 	"github.com/authzed/spicedb/internal/dispatch/keys"
 	"github.com/authzed/spicedb/pkg/balancer"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
 	v1 "github.com/authzed/spicedb/pkg/proto/dispatch/v1"
 )
 
@@ -20,19 +23,129 @@ type clusterClient interface {
 	DispatchReachableResources(ctx context.Context, in *v1.DispatchReachableResourcesRequest, opts ...grpc.CallOption) (v1.DispatchService_DispatchReachableResourcesClient, error)
 }
 
+// Option is a function-style option for configuring a cluster Dispatcher.
+type Option func(*optionState)
+
+type optionState struct {
+	hedgingEnabled                     bool
+	failoverEnabled                    bool
+	hedgingInitialSlowRequestThreshold time.Duration
+	hedgingMaxSampleCount              uint64
+	hedgingQuantile                    float64
+	localFallback                      dispatch.Dispatcher
+	localEvaluation                    dispatch.Dispatcher
+	streamingClient                    streamingClient
+	router                             *balancer.Router
+	timeSource                         clock.Clock
+}
+
+// Hedging enables hedging of dispatch requests: a request which has not completed within
+// the given quantile of historical request durations is sent a second time, and the first
+// answer received is used.
+func Hedging(initialSlowRequestThreshold time.Duration, maxSampleCount uint64, quantile float64) Option {
+	return func(state *optionState) {
+		state.hedgingEnabled = true
+		state.hedgingInitialSlowRequestThreshold = initialSlowRequestThreshold
+		state.hedgingMaxSampleCount = maxSampleCount
+		state.hedgingQuantile = quantile
+	}
+}
+
+// Failover enables retrying dispatch requests which fail because their peer node is
+// unavailable. Each request is retried once, either on the next peer node on the hashring or
+// locally, if a local fallback has been configured.
+func Failover() Option {
+	return func(state *optionState) {
+		state.failoverEnabled = true
+	}
+}
+
+// LocalFallback sets a dispatcher with which hedged and failover requests are evaluated
+// locally. If unset, these requests are sent to the next peer node on the hashring.
+func LocalFallback(fallback dispatch.Dispatcher) Option {
+	return func(state *optionState) {
+		state.localFallback = fallback
+	}
+}
+
+// LocalEvaluation enables evaluating cheap check, lookup and reachable resources requests
+// with the given local dispatcher, rather than sending them to peer nodes. A request is cheap
+// if it can be resolved with a single query for direct relationships, and the recent latency
+// of evaluating such requests locally is no worse than sending them to peer nodes.
+func LocalEvaluation(local dispatch.Dispatcher) Option {
+	return func(state *optionState) {
+		state.localEvaluation = local
+	}
+}
+
+// Multiplexing enables sending requests to each peer node over a single bidirectional
+// stream, with the given client, rather than as individual calls. The router must be bound to
+// the client's connection, so that requests are sent to the same peers as individual calls.
+// Requests to peers which do not support streams are sent as individual calls.
+func Multiplexing(client streamingClient, router *balancer.Router) Option {
+	return func(state *optionState) {
+		state.streamingClient = client
+		state.router = router
+	}
+}
+
 // NewClusterDispatcher creates a dispatcher implementation that uses the provided client
 // to dispatch requests to peer nodes in the cluster.
-func NewClusterDispatcher(client clusterClient, keyHandler keys.Handler) dispatch.Dispatcher {
+func NewClusterDispatcher(client clusterClient, keyHandler keys.Handler, options ...Option) dispatch.Dispatcher {
 	if keyHandler == nil {
 		keyHandler = &keys.DirectKeyHandler{}
 	}
 
-	return &clusterDispatcher{clusterClient: client, keyHandler: keyHandler}
+	opts := optionState{timeSource: clock.New()}
+	for _, fn := range options {
+		fn(&opts)
+	}
+
+	var h *hedger
+	if opts.hedgingEnabled {
+		h = newHedger(
+			opts.timeSource,
+			opts.hedgingInitialSlowRequestThreshold,
+			opts.hedgingMaxSampleCount,
+			opts.hedgingQuantile,
+		)
+	}
+
+	var l *locality
+	if opts.localEvaluation != nil {
+		l = newLocality(opts.localEvaluation, opts.timeSource)
+	}
+
+	var m *multiplexer
+	if opts.streamingClient != nil && opts.router != nil {
+		m = newMultiplexer(opts.streamingClient, opts.router, opts.timeSource)
+	}
+
+	return &clusterDispatcher{
+		clusterClient:   client,
+		keyHandler:      keyHandler,
+		hedger:          h,
+		failoverEnabled: opts.failoverEnabled,
+		localFallback:   opts.localFallback,
+		locality:        l,
+		multiplexer:     m,
+	}
 }
 
 type clusterDispatcher struct {
-	clusterClient clusterClient
-	keyHandler    keys.Handler
+	clusterClient   clusterClient
+	keyHandler      keys.Handler
+	hedger          *hedger
+	failoverEnabled bool
+	localFallback   dispatch.Dispatcher
+	locality        *locality
+	multiplexer     *multiplexer
+}
+
+// isLocalAttempt returns whether the given attempt of a request is evaluated by the local
+// fallback, rather than sent to a peer node.
+func (cr *clusterDispatcher) isLocalAttempt(attempt uint8) bool {
+	return attempt > 0 && cr.localFallback != nil
 }
 
 func (cr *clusterDispatcher) DispatchCheck(ctx context.Context, req *v1.DispatchCheckRequest) (*v1.DispatchCheckResponse, error) {
@@ -41,15 +154,54 @@ func (cr *clusterDispatcher) DispatchCheck(ctx context.Context, req *v1.Dispatch
 		return &v1.DispatchCheckResponse{Metadata: emptyMetadata}, err
 	}
 
+	evaluateLocally, record := cr.locality.place(ctx, "check", req.Metadata, &core.RelationReference{
+		Namespace: req.ObjectAndRelation.Namespace,
+		Relation:  req.ObjectAndRelation.Relation,
+	}, &core.RelationReference{
+		Namespace: req.Subject.Namespace,
+		Relation:  req.Subject.Relation,
+	})
+
+	if evaluateLocally {
+		resp, err := cr.locality.local.DispatchCheck(ctx, req)
+		record(err)
+		return resp, err
+	}
+
 	requestKey, err := cr.keyHandler.ComputeCheckKey(ctx, req)
 	if err != nil {
 		return &v1.DispatchCheckResponse{Metadata: emptyMetadata}, err
 	}
 
+	budget := dispatch.BudgetFromContext(ctx)
+	if err := checkBudget(budget); err != nil {
+		return &v1.DispatchCheckResponse{Metadata: emptyMetadata}, err
+	}
+	fanOut := cr.attemptFanOut(ctx)
+
 	ctx = context.WithValue(ctx, balancer.CtxKey, []byte(requestKey))
-	resp, err := cr.clusterClient.DispatchCheck(ctx, req)
+	resp, _, err := hedge(ctx, cr.hedger, cr.failoverEnabled, func(ctx context.Context, attempt uint8) (*v1.DispatchCheckResponse, error) {
+		if cr.isLocalAttempt(attempt) {
+			return cr.localFallback.DispatchCheck(ctx, req)
+		}
+
+		return budgetedAttempt(budget, fanOut, req.Metadata, func(metadata *v1.ResolverMeta) (*v1.DispatchCheckResponse, error) {
+			req := &v1.DispatchCheckRequest{
+				Metadata:          metadata,
+				ObjectAndRelation: req.ObjectAndRelation,
+				Subject:           req.Subject,
+			}
+
+			resp, err := cr.multiplexer.dispatchCheck(ctx, attempt, req)
+			if errors.Is(err, errStreamingUnsupported) {
+				resp, err = cr.clusterClient.DispatchCheck(balancer.WithAttempt(ctx, attempt), req)
+			}
+			return resp, err
+		})
+	})
+	record(err)
 	if err != nil {
-		return &v1.DispatchCheckResponse{Metadata: requestFailureMetadata}, err
+		return &v1.DispatchCheckResponse{Metadata: requestFailureMetadata}, rewriteError(err)
 	}
 
 	return resp, nil
@@ -60,10 +212,40 @@ func (cr *clusterDispatcher) DispatchExpand(ctx context.Context, req *v1.Dispatc
 	if err != nil {
 		return &v1.DispatchExpandResponse{Metadata: emptyMetadata}, err
 	}
-	ctx = context.WithValue(ctx, balancer.CtxKey, []byte(dispatch.ExpandRequestToKey(req)))
-	resp, err := cr.clusterClient.DispatchExpand(ctx, req)
+
+	budget := dispatch.BudgetFromContext(ctx)
+	if err := checkBudget(budget); err != nil {
+		return &v1.DispatchExpandResponse{Metadata: emptyMetadata}, err
+	}
+	fanOut := cr.attemptFanOut(ctx)
+
+	requestKey, err := cr.keyHandler.ComputeExpandKey(ctx, req)
+	if err != nil {
+		return &v1.DispatchExpandResponse{Metadata: emptyMetadata}, err
+	}
+
+	ctx = context.WithValue(ctx, balancer.CtxKey, []byte(requestKey))
+	resp, _, err := hedge(ctx, cr.hedger, cr.failoverEnabled, func(ctx context.Context, attempt uint8) (*v1.DispatchExpandResponse, error) {
+		if cr.isLocalAttempt(attempt) {
+			return cr.localFallback.DispatchExpand(ctx, req)
+		}
+
+		return budgetedAttempt(budget, fanOut, req.Metadata, func(metadata *v1.ResolverMeta) (*v1.DispatchExpandResponse, error) {
+			req := &v1.DispatchExpandRequest{
+				Metadata:          metadata,
+				ObjectAndRelation: req.ObjectAndRelation,
+				ExpansionMode:     req.ExpansionMode,
+			}
+
+			resp, err := cr.multiplexer.dispatchExpand(ctx, attempt, req)
+			if errors.Is(err, errStreamingUnsupported) {
+				resp, err = cr.clusterClient.DispatchExpand(balancer.WithAttempt(ctx, attempt), req)
+			}
+			return resp, err
+		})
+	})
 	if err != nil {
-		return &v1.DispatchExpandResponse{Metadata: requestFailureMetadata}, err
+		return &v1.DispatchExpandResponse{Metadata: requestFailureMetadata}, rewriteError(err)
 	}
 
 	return resp, nil
@@ -74,10 +256,55 @@ func (cr *clusterDispatcher) DispatchLookup(ctx context.Context, req *v1.Dispatc
 	if err != nil {
 		return &v1.DispatchLookupResponse{Metadata: emptyMetadata}, err
 	}
-	ctx = context.WithValue(ctx, balancer.CtxKey, []byte(dispatch.LookupRequestToKey(req)))
-	resp, err := cr.clusterClient.DispatchLookup(ctx, req)
+
+	evaluateLocally, record := cr.locality.place(ctx, "lookup", req.Metadata, req.ObjectRelation, &core.RelationReference{
+		Namespace: req.Subject.Namespace,
+		Relation:  req.Subject.Relation,
+	})
+
+	if evaluateLocally {
+		resp, err := cr.locality.local.DispatchLookup(ctx, req)
+		record(err)
+		return resp, err
+	}
+
+	budget := dispatch.BudgetFromContext(ctx)
+	if err := checkBudget(budget); err != nil {
+		return &v1.DispatchLookupResponse{Metadata: emptyMetadata}, err
+	}
+	fanOut := cr.attemptFanOut(ctx)
+
+	requestKey, err := cr.keyHandler.ComputeLookupKey(ctx, req)
+	if err != nil {
+		return &v1.DispatchLookupResponse{Metadata: emptyMetadata}, err
+	}
+
+	ctx = context.WithValue(ctx, balancer.CtxKey, []byte(requestKey))
+	resp, _, err := hedge(ctx, cr.hedger, cr.failoverEnabled, func(ctx context.Context, attempt uint8) (*v1.DispatchLookupResponse, error) {
+		if cr.isLocalAttempt(attempt) {
+			return cr.localFallback.DispatchLookup(ctx, req)
+		}
+
+		return budgetedAttempt(budget, fanOut, req.Metadata, func(metadata *v1.ResolverMeta) (*v1.DispatchLookupResponse, error) {
+			req := &v1.DispatchLookupRequest{
+				Metadata:       metadata,
+				ObjectRelation: req.ObjectRelation,
+				Subject:        req.Subject,
+				Limit:          req.Limit,
+				DirectStack:    req.DirectStack,
+				TtuStack:       req.TtuStack,
+			}
+
+			resp, err := cr.multiplexer.dispatchLookup(ctx, attempt, req)
+			if errors.Is(err, errStreamingUnsupported) {
+				resp, err = cr.clusterClient.DispatchLookup(balancer.WithAttempt(ctx, attempt), req)
+			}
+			return resp, err
+		})
+	})
+	record(err)
 	if err != nil {
-		return &v1.DispatchLookupResponse{Metadata: requestFailureMetadata}, err
+		return &v1.DispatchLookupResponse{Metadata: requestFailureMetadata}, rewriteError(err)
 	}
 
 	return resp, nil
@@ -87,37 +314,186 @@ func (cr *clusterDispatcher) DispatchReachableResources(
 	req *v1.DispatchReachableResourcesRequest,
 	stream dispatch.ReachableResourcesStream,
 ) error {
-	ctx := context.WithValue(stream.Context(), balancer.CtxKey, []byte(dispatch.ReachableResourcesRequestToKey(req)))
-	stream = dispatch.StreamWithContext(ctx, stream)
+	err := dispatch.CheckDepth(stream.Context(), req)
+	if err != nil {
+		return err
+	}
 
-	err := dispatch.CheckDepth(ctx, req)
+	evaluateLocally, record := cr.locality.place(stream.Context(), "reachableresources", req.Metadata, req.ObjectRelation, &core.RelationReference{
+		Namespace: req.Subject.Namespace,
+		Relation:  req.Subject.Relation,
+	})
+
+	if evaluateLocally {
+		err := cr.locality.local.DispatchReachableResources(req, stream)
+		record(err)
+		return err
+	}
+
+	requestKey, err := cr.keyHandler.ComputeReachableResourcesKey(stream.Context(), req)
 	if err != nil {
 		return err
 	}
 
-	client, err := cr.clusterClient.DispatchReachableResources(ctx, req)
+	ctx := context.WithValue(stream.Context(), balancer.CtxKey, []byte(requestKey))
+	stream = dispatch.StreamWithContext(ctx, stream)
+
+	budget := dispatch.BudgetFromContext(ctx)
+	metadata, release, err := reserveBudget(budget, dispatch.FanOutFromContext(ctx), req.Metadata)
 	if err != nil {
 		return err
 	}
+	defer release()
+
+	req = &v1.DispatchReachableResourcesRequest{
+		Metadata:       metadata,
+		ObjectRelation: req.ObjectRelation,
+		Subject:        req.Subject,
+	}
+
+	// As results are published as they are received, streamed requests are not hedged, and
+	// are only failed over if their peer was unavailable before any results were published.
+	published, err := cr.streamReachableResources(ctx, req, stream, budget, 0)
+	if err == nil || !cr.failoverEnabled || published || !isUnavailable(err) || ctx.Err() != nil {
+		record(err)
+		return rewriteError(err)
+	}
+
+	failoverCount.Inc()
+	if cr.isLocalAttempt(1) {
+		return cr.localFallback.DispatchReachableResources(req, stream)
+	}
+
+	_, err = cr.streamReachableResources(ctx, req, stream, budget, 1)
+	return rewriteError(err)
+}
+
+func (cr *clusterDispatcher) streamReachableResources(
+	ctx context.Context,
+	req *v1.DispatchReachableResourcesRequest,
+	stream dispatch.ReachableResourcesStream,
+	budget *dispatch.Budget,
+	attempt uint8,
+) (bool, error) {
+	published := false
+	err := cr.multiplexer.dispatchReachableResources(ctx, attempt, req, func(result *v1.DispatchReachableResourcesResponse) error {
+		chargeBudget(budget, result.Metadata)
+
+		published = true
+		return stream.Publish(result)
+	})
+	if !errors.Is(err, errStreamingUnsupported) {
+		return published, err
+	}
+
+	client, err := cr.clusterClient.DispatchReachableResources(balancer.WithAttempt(ctx, attempt), req)
+	if err != nil {
+		return false, err
+	}
 
 	for {
 		result, err := client.Recv()
 		if errors.Is(err, io.EOF) {
-			return nil
+			return published, nil
 		}
 
 		if err != nil {
-			return err
+			return published, err
 		}
 
+		chargeBudget(budget, result.Metadata)
+
+		published = true
 		serr := stream.Publish(result)
 		if serr != nil {
-			return serr
+			return published, serr
 		}
 	}
 }
 
+// attemptFanOut returns the number of concurrent requests amongst which the budget is split
+// for each attempt of a request. When hedging is enabled, two attempts of each request may be
+// in flight at once, and so each is reserved half of the request's share.
+func (cr *clusterDispatcher) attemptFanOut(ctx context.Context) uint32 {
+	fanOut := dispatch.FanOutFromContext(ctx)
+	if cr.hedger != nil {
+		fanOut *= 2
+	}
+	return fanOut
+}
+
+// responseWithMetadata is a response of a peer node reporting the work it has consumed.
+type responseWithMetadata interface {
+	GetMetadata() *v1.ResponseMeta
+}
+
+// budgetedAttempt performs a single attempt of a request sent to a peer node, reserving its
+// own share of the budget, if any, and charging the work reported by the peer whether or not
+// the response of the attempt is the one used. A hedged attempt canceled before its peer has
+// responded is not charged, but its reservation bounded the work it could perform.
+func budgetedAttempt[T responseWithMetadata](
+	budget *dispatch.Budget,
+	fanOut uint32,
+	metadata *v1.ResolverMeta,
+	run func(metadata *v1.ResolverMeta) (T, error),
+) (T, error) {
+	reserved, release, err := reserveBudget(budget, fanOut, metadata)
+	if err != nil {
+		var none T
+		return none, err
+	}
+	defer release()
+
+	resp, err := run(reserved)
+	chargeBudget(budget, resp.GetMetadata())
+	return resp, err
+}
+
+// checkBudget returns ErrBudgetExhausted if the budget, if any, has no allowance left.
+func checkBudget(budget *dispatch.Budget) error {
+	if budget == nil {
+		return nil
+	}
+	return budget.CheckRemaining()
+}
+
+// reserveBudget reserves a share of the budget, if any, for a request sent to a peer node,
+// which is one of fanOut concurrent requests, returning the resolver metadata to send with the
+// request and a function which releases the reservation once the request has completed.
+func reserveBudget(budget *dispatch.Budget, fanOut uint32, metadata *v1.ResolverMeta) (*v1.ResolverMeta, func(), error) {
+	if budget == nil {
+		return metadata, func() {}, nil
+	}
+
+	allowance, err := budget.Reserve(fanOut)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return allowance.ResolverMeta(metadata), func() { budget.Release(allowance) }, nil
+}
+
+// chargeBudget charges the work reported as consumed by a peer node against the local budget,
+// if any. The peer enforces its share of the budget itself, so exceeding the budget here only
+// affects subsequent dispatches.
+func chargeBudget(budget *dispatch.Budget, metadata *v1.ResponseMeta) {
+	if budget == nil || metadata == nil {
+		return
+	}
+
+	_ = budget.ChargeRemote(metadata)
+}
+
+// rewriteError converts errors returned by peer nodes back into their local equivalents.
+func rewriteError(err error) error {
+	if budgetErr := dispatch.BudgetExhaustedFromStatus(err); budgetErr != nil {
+		return budgetErr
+	}
+	return err
+}
+
 func (cr *clusterDispatcher) Close() error {
+	cr.multiplexer.close()
 	return nil
 }
 
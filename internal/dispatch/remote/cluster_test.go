@@ -0,0 +1,239 @@
+package remote
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/authzed/spicedb/internal/dispatch"
+	dispatchservice "github.com/authzed/spicedb/internal/services/dispatch/v1"
+	v1 "github.com/authzed/spicedb/pkg/proto/dispatch/v1"
+)
+
+// testServers are the kinds of dispatch server on a peer node, which are sent requests over a
+// dispatch stream or as individual calls respectively.
+var testServers = map[string]func(local dispatch.Dispatcher) v1.DispatchServiceServer{
+	"multiplexed": func(local dispatch.Dispatcher) v1.DispatchServiceServer {
+		return dispatchservice.NewDispatchServer(local)
+	},
+	"unary": func(local dispatch.Dispatcher) v1.DispatchServiceServer {
+		return unaryOnlyServer{dispatchservice.NewDispatchServer(local)}
+	},
+}
+
+func TestDispatchBudgetSplitAcrossFanOut(t *testing.T) {
+	for name, newServer := range testServers {
+		newServer := newServer
+		t.Run(name, func(t *testing.T) {
+			require := require.New(t)
+
+			var lock sync.Mutex
+			var allowances []uint32
+			started := make(chan struct{}, 3)
+			proceed := make(chan struct{})
+
+			local := &fakeDispatcher{
+				check: func(ctx context.Context, req *v1.DispatchCheckRequest) (*v1.DispatchCheckResponse, error) {
+					lock.Lock()
+					allowances = append(allowances, req.Metadata.DispatchBudgetRemaining)
+					lock.Unlock()
+
+					if err := dispatch.BudgetFromContext(ctx).Charge(3, 2); err != nil {
+						return nil, err
+					}
+
+					started <- struct{}{}
+					<-proceed
+					return &v1.DispatchCheckResponse{
+						Metadata:   &v1.ResponseMeta{DispatchCount: 1},
+						Membership: v1.DispatchCheckResponse_MEMBER,
+					}, nil
+				},
+			}
+			peer := newTestPeer(t, newServer(local))
+			dispatcher := peer.dispatcher(t)
+
+			budget := dispatch.NewBudget(100, 100)
+			ctx := dispatch.ContextWithBudget(context.Background(), budget)
+
+			var wg sync.WaitGroup
+			for _, objectID := range []string{"first", "second"} {
+				objectID := objectID
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					_, err := dispatcher.DispatchCheck(dispatch.ContextWithFanOut(ctx, 2), checkRequest(objectID))
+					require.NoError(err)
+				}()
+			}
+
+			// Both requests are in flight at once, so together they may not be allowed more
+			// than the whole budget.
+			<-started
+			<-started
+			close(proceed)
+			wg.Wait()
+
+			sort.Slice(allowances, func(i, j int) bool { return allowances[i] < allowances[j] })
+			require.Equal([]uint32{25, 50}, allowances)
+
+			// The work performed by the peer is charged to the caller, and the reservations
+			// are released once the requests complete.
+			require.Equal(uint32(6), budget.DispatchesUsed())
+			require.Equal(uint32(4), budget.DatastoreQueriesUsed())
+
+			_, err := dispatcher.DispatchCheck(ctx, checkRequest("third"))
+			require.NoError(err)
+			require.Equal(uint32(94), allowances[2])
+		})
+	}
+}
+
+func TestDispatchBudgetAcrossAttempts(t *testing.T) {
+	for name, newServer := range testServers {
+		newServer := newServer
+		t.Run(name, func(t *testing.T) {
+			t.Run("hedged", func(t *testing.T) {
+				require := require.New(t)
+
+				var lock sync.Mutex
+				var allowances []uint32
+				hedged := make(chan struct{})
+				local := &fakeDispatcher{
+					check: func(ctx context.Context, req *v1.DispatchCheckRequest) (*v1.DispatchCheckResponse, error) {
+						lock.Lock()
+						allowances = append(allowances, req.Metadata.DispatchBudgetRemaining)
+						attempt := len(allowances)
+						lock.Unlock()
+
+						// The hedged attempt loses, and is canceled once the first attempt
+						// has completed.
+						if attempt > 1 {
+							close(hedged)
+							<-ctx.Done()
+							return nil, ctx.Err()
+						}
+
+						if err := dispatch.BudgetFromContext(ctx).Charge(3, 2); err != nil {
+							return nil, err
+						}
+
+						<-hedged
+						return &v1.DispatchCheckResponse{
+							Metadata:   &v1.ResponseMeta{DispatchCount: 1},
+							Membership: v1.DispatchCheckResponse_MEMBER,
+						}, nil
+					},
+				}
+				peer := newTestPeer(t, newServer(local))
+				dispatcher := peer.dispatcher(t, Hedging(time.Millisecond, 1_000_000, 0.95))
+
+				budget := dispatch.NewBudget(100, 100)
+				ctx := dispatch.ContextWithBudget(context.Background(), budget)
+
+				resp, err := dispatcher.DispatchCheck(ctx, checkRequest("first"))
+				require.NoError(err)
+				require.Equal(v1.DispatchCheckResponse_MEMBER, resp.Membership)
+
+				// Both attempts are in flight at once, so each is reserved its own share and
+				// together they may not be allowed more than the whole budget.
+				lock.Lock()
+				require.Equal([]uint32{50, 25}, allowances)
+				lock.Unlock()
+
+				require.Equal(uint32(3), budget.DispatchesUsed())
+				require.Equal(uint32(2), budget.DatastoreQueriesUsed())
+			})
+
+			t.Run("failover", func(t *testing.T) {
+				require := require.New(t)
+
+				var allowances []uint32
+				local := &fakeDispatcher{
+					check: func(ctx context.Context, req *v1.DispatchCheckRequest) (*v1.DispatchCheckResponse, error) {
+						allowances = append(allowances, req.Metadata.DispatchBudgetRemaining)
+						if err := dispatch.BudgetFromContext(ctx).Charge(3, 2); err != nil {
+							return nil, err
+						}
+
+						if len(allowances) == 1 {
+							return nil, errUnavailable
+						}
+
+						return &v1.DispatchCheckResponse{
+							Metadata:   &v1.ResponseMeta{DispatchCount: 1},
+							Membership: v1.DispatchCheckResponse_MEMBER,
+						}, nil
+					},
+				}
+				peer := newTestPeer(t, newServer(local))
+				dispatcher := peer.dispatcher(t, Failover())
+
+				budget := dispatch.NewBudget(100, 100)
+				ctx := dispatch.ContextWithBudget(context.Background(), budget)
+
+				resp, err := dispatcher.DispatchCheck(ctx, checkRequest("first"))
+				require.NoError(err)
+				require.Equal(v1.DispatchCheckResponse_MEMBER, resp.Membership)
+
+				// The failed attempt's reservation is released before the failover attempt
+				// reserves its own, and the work of the failover attempt is charged.
+				require.Equal([]uint32{100, 100}, allowances)
+				require.Equal(uint32(3), budget.DispatchesUsed())
+				require.Equal(uint32(2), budget.DatastoreQueriesUsed())
+			})
+		})
+	}
+}
+
+func TestRemoteBudgetExhaustedError(t *testing.T) {
+	testCases := []struct {
+		name             string
+		peerErr          error
+		expectedResource string
+	}{
+		{"budget exhausted", dispatch.NewBudgetExhaustedErr("dispatches", 1), "dispatches"},
+		{"other resource exhaustion", status.Error(codes.ResourceExhausted, "grpc: received message larger than max"), ""},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			for name, newServer := range testServers {
+				newServer := newServer
+				t.Run(name, func(t *testing.T) {
+					require := require.New(t)
+
+					local := &fakeDispatcher{
+						check: func(ctx context.Context, req *v1.DispatchCheckRequest) (*v1.DispatchCheckResponse, error) {
+							return &v1.DispatchCheckResponse{Metadata: &v1.ResponseMeta{}}, tc.peerErr
+						},
+					}
+					peer := newTestPeer(t, newServer(local))
+					dispatcher := peer.dispatcher(t)
+
+					_, err := dispatcher.DispatchCheck(context.Background(), checkRequest("doc"))
+					require.Error(err)
+
+					var budgetErr dispatch.ErrBudgetExhausted
+					if tc.expectedResource == "" {
+						require.False(errors.As(err, &budgetErr))
+						require.Equal(codes.ResourceExhausted, status.Code(err))
+						return
+					}
+
+					require.ErrorAs(err, &budgetErr)
+					require.Equal(tc.expectedResource, budgetErr.Resource())
+					require.Equal(uint32(1), budgetErr.Limit())
+				})
+			}
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package remote
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/influxdata/tdigest"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var hedgeableCount = promauto.NewCounter(prometheus.CounterOpts{
+	Namespace: "spicedb",
+	Subsystem: "dispatch",
+	Name:      "hedgeable_requests_total",
+	Help:      "total number of dispatch requests which are eligible for hedging",
+})
+
+var hedgedCount = promauto.NewCounter(prometheus.CounterOpts{
+	Namespace: "spicedb",
+	Subsystem: "dispatch",
+	Name:      "hedged_requests_total",
+	Help:      "total number of dispatch requests which have been hedged",
+})
+
+var failoverCount = promauto.NewCounter(prometheus.CounterOpts{
+	Namespace: "spicedb",
+	Subsystem: "dispatch",
+	Name:      "failover_requests_total",
+	Help:      "total number of dispatch requests which have been retried after their peer was unavailable",
+})
+
+const defaultTDigestCompression = float64(1000)
+
+// attemptFunc performs a single attempt of a dispatch request. Attempts after the first must
+// be sent to a different node than the first, or be evaluated locally.
+type attemptFunc[T any] func(ctx context.Context, attempt uint8) (T, error)
+
+type attemptResult[T any] struct {
+	resp     T
+	err      error
+	attempt  uint8
+	duration time.Duration
+}
+
+// hedger tracks the latency of dispatch requests, to determine when a request should be
+// considered slow and hedged.
+type hedger struct {
+	timeSource     clock.Clock
+	maxSampleCount uint64
+	quantile       float64
+
+	sync.Mutex
+	digests []*tdigest.TDigest
+}
+
+func newHedger(
+	timeSource clock.Clock,
+	initialSlowRequestThreshold time.Duration,
+	maxSampleCount uint64,
+	quantile float64,
+) *hedger {
+	digests := []*tdigest.TDigest{
+		tdigest.NewWithCompression(defaultTDigestCompression),
+		tdigest.NewWithCompression(defaultTDigestCompression),
+	}
+
+	// As with the datastore hedger, the first digest is pre-loaded with the initial slow
+	// request threshold so that the digests are out of phase with one another.
+	digests[0].Add(initialSlowRequestThreshold.Seconds(), float64(maxSampleCount)/2)
+
+	return &hedger{
+		timeSource:     timeSource,
+		maxSampleCount: maxSampleCount,
+		quantile:       quantile,
+		digests:        digests,
+	}
+}
+
+func (h *hedger) slowRequestThreshold() time.Duration {
+	h.Lock()
+	defer h.Unlock()
+	return time.Duration(h.digests[0].Quantile(h.quantile) * float64(time.Second))
+}
+
+func (h *hedger) record(duration time.Duration) {
+	h.Lock()
+	defer h.Unlock()
+
+	// Swap the current active digest if it has too many samples
+	if h.digests[0].Count() >= float64(h.maxSampleCount) {
+		exhausted := h.digests[0]
+		h.digests = h.digests[1:]
+		exhausted.Reset()
+		h.digests = append(h.digests, exhausted)
+	}
+
+	durSeconds := duration.Seconds()
+	for _, digest := range h.digests {
+		digest.Add(durSeconds, 1)
+	}
+}
+
+// hedge performs a dispatch request, starting a second attempt if the first has not completed
+// within the hedger's slow request threshold or, when failover is enabled, has failed because
+// its peer was unavailable. The first successful response is returned along with the attempt
+// which produced it, and the remaining attempt is canceled. If the hedger is nil, requests are
+// never hedged.
+func hedge[T any](ctx context.Context, h *hedger, failover bool, run attemptFunc[T]) (T, uint8, error) {
+	if h == nil {
+		resp, err := run(ctx, 0)
+		if !failover || !isUnavailable(err) || ctx.Err() != nil {
+			return resp, 0, err
+		}
+
+		failoverCount.Inc()
+		resp, err = run(ctx, 1)
+		return resp, 1, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan attemptResult[T], 2)
+	start := func(attempt uint8) {
+		attemptStart := h.timeSource.Now()
+		go func() {
+			resp, err := run(ctx, attempt)
+			results <- attemptResult[T]{resp, err, attempt, h.timeSource.Since(attemptStart)}
+		}()
+	}
+
+	slowRequestThreshold := h.slowRequestThreshold()
+	timer := h.timeSource.Timer(slowRequestThreshold)
+	defer timer.Stop()
+
+	hedgeableCount.Inc()
+	start(0)
+
+	outstanding := 1
+	retried := false
+	var failed *attemptResult[T]
+	for {
+		select {
+		case <-timer.C:
+			if !retried {
+				log.Ctx(ctx).Debug().Dur("after", slowRequestThreshold).Msg("sending hedged dispatch request")
+				hedgedCount.Inc()
+				retried = true
+				outstanding++
+				start(1)
+			}
+
+		case result := <-results:
+			outstanding--
+			if result.err == nil {
+				h.record(result.duration)
+				return result.resp, result.attempt, nil
+			}
+
+			if failed == nil {
+				failed = &result
+			}
+
+			if failover && !retried && isUnavailable(result.err) && ctx.Err() == nil {
+				log.Ctx(ctx).Debug().Err(result.err).Msg("peer unavailable, retrying dispatch request")
+				failoverCount.Inc()
+				retried = true
+				outstanding++
+				start(1)
+				continue
+			}
+
+			if outstanding == 0 {
+				return failed.resp, failed.attempt, failed.err
+			}
+		}
+	}
+}
+
+func isUnavailable(err error) bool {
+	return status.Code(err) == codes.Unavailable
+}
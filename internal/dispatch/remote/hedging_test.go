@@ -0,0 +1,99 @@
+package remote
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/goleak"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	slowRequestTime = 10 * time.Millisecond
+	maxSampleCount  = uint64(1_000_000)
+	quantile        = 0.95
+
+	errUnavailable = status.Error(codes.Unavailable, "peer restarting")
+	errKnown       = errors.New("known error")
+)
+
+type attemptResponse struct {
+	attempt uint8
+	winner  uint8
+	err     error
+}
+
+func TestHedge(t *testing.T) {
+	testCases := []struct {
+		name             string
+		withHedger       bool
+		withFailover     bool
+		primaryResult    error
+		primaryIsSlow    bool
+		expectedAttempt  uint8
+		expectedErr      error
+		expectedAttempts int
+	}{
+		{"fast primary", true, true, nil, false, 0, nil, 1},
+		{"slow primary is hedged", true, true, nil, true, 1, nil, 2},
+		{"slow primary is hedged without failover", true, false, nil, true, 1, nil, 2},
+		{"unavailable primary fails over", true, true, errUnavailable, false, 1, nil, 2},
+		{"unavailable primary is not retried without failover", true, false, errUnavailable, false, 0, errUnavailable, 1},
+		{"failed primary is not retried", true, true, errKnown, false, 0, errKnown, 1},
+		{"unavailable primary fails over without hedging", false, true, errUnavailable, false, 1, nil, 2},
+		{"unavailable primary is not retried without hedging or failover", false, false, errUnavailable, false, 0, errUnavailable, 1},
+		{"failed primary is not retried without hedging", false, true, errKnown, false, 0, errKnown, 1},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+			require := require.New(t)
+
+			mockTime := clock.NewMock()
+			var h *hedger
+			if tc.withHedger {
+				h = newHedger(mockTime, slowRequestTime, maxSampleCount, quantile)
+			}
+
+			primaryStarted := make(chan struct{})
+			attempts := make(chan uint8, 2)
+			responses := make(chan attemptResponse, 1)
+			go func() {
+				resp, winner, err := hedge(context.Background(), h, tc.withFailover, func(ctx context.Context, attempt uint8) (uint8, error) {
+					attempts <- attempt
+					if attempt > 0 {
+						return attempt, nil
+					}
+
+					close(primaryStarted)
+					if tc.primaryIsSlow {
+						<-ctx.Done()
+						return attempt, ctx.Err()
+					}
+					return attempt, tc.primaryResult
+				})
+				responses <- attemptResponse{resp, winner, err}
+			}()
+
+			<-primaryStarted
+			if tc.primaryIsSlow {
+				mockTime.Add(2 * slowRequestTime)
+			}
+
+			resp := <-responses
+			require.ErrorIs(resp.err, tc.expectedErr)
+			require.Equal(resp.attempt, resp.winner)
+			if tc.expectedErr == nil {
+				require.Equal(tc.expectedAttempt, resp.attempt)
+			}
+			require.Len(attempts, tc.expectedAttempts)
+		})
+	}
+}
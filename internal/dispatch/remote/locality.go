@@ -0,0 +1,244 @@
+package remote
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/rs/zerolog/log"
+	"github.com/shopspring/decimal"
+
+	"github.com/authzed/spicedb/internal/dispatch"
+	datastoremw "github.com/authzed/spicedb/internal/middleware/datastore"
+	"github.com/authzed/spicedb/internal/namespace"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	v1 "github.com/authzed/spicedb/pkg/proto/dispatch/v1"
+)
+
+var localityDecisionCount = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "spicedb",
+	Subsystem: "dispatch",
+	Name:      "locality_decisions_total",
+	Help:      "total number of dispatch requests evaluated locally or sent to a peer node",
+}, []string{"operation", "locality"})
+
+const (
+	localLocality  = "local"
+	remoteLocality = "remote"
+
+	// directnessTTL is how long the result of determining whether a relation can be resolved
+	// directly is reused, before the schema is consulted again.
+	directnessTTL = time.Minute
+
+	// latencySmoothing is the weight given to each new sample in the moving averages of the
+	// latency of requests.
+	latencySmoothing = 0.1
+
+	// minLatencySamples is the number of samples required of both local and remote requests
+	// before their latencies are compared.
+	minLatencySamples = 20
+
+	// probeInterval is how often a request which would be evaluated locally is sent to a peer
+	// node regardless, so that the latency of remote requests remains known.
+	probeInterval = 100
+)
+
+// locality decides whether dispatch requests are evaluated locally or sent to their peer
+// node on the hashring.
+//
+// Requests are evaluated locally if they can be resolved with a single query for direct
+// relationships, i.e. if the only entrypoints from the subject type into the resource's
+// relation are relation entrypoints of the relation itself, and if the recent latency of
+// such requests locally is no worse than that of sending them to peer nodes. All other
+// requests are sent to peer nodes, where the recursive work is shared across the cluster.
+//
+// Requests which are already cached locally never reach the cluster dispatcher, as the
+// dispatcher's caching delegate answers them first.
+type locality struct {
+	local      dispatch.Dispatcher
+	timeSource clock.Clock
+
+	sync.Mutex
+	relations map[string]*relationLocality
+}
+
+type relationLocality struct {
+	direct    bool
+	checkedAt time.Time
+
+	local    latencyAverage
+	remote   latencyAverage
+	requests uint64
+}
+
+// latencyAverage is an exponentially weighted moving average of request latencies.
+type latencyAverage struct {
+	seconds float64
+	samples uint64
+}
+
+func (la *latencyAverage) add(duration time.Duration) {
+	if la.samples == 0 {
+		la.seconds = duration.Seconds()
+	} else {
+		la.seconds += latencySmoothing * (duration.Seconds() - la.seconds)
+	}
+	la.samples++
+}
+
+func newLocality(local dispatch.Dispatcher, timeSource clock.Clock) *locality {
+	return &locality{
+		local:      local,
+		timeSource: timeSource,
+		relations:  map[string]*relationLocality{},
+	}
+}
+
+// recordFunc records the outcome of a request once it has completed.
+type recordFunc func(err error)
+
+// place decides whether the request for the given resource relation and subject type should
+// be evaluated locally, and returns a function to record the request's outcome once it has
+// completed. A nil locality places all requests remotely.
+func (l *locality) place(
+	ctx context.Context,
+	operation string,
+	metadata *v1.ResolverMeta,
+	resource *core.RelationReference,
+	subject *core.RelationReference,
+) (bool, recordFunc) {
+	if l == nil {
+		return false, func(error) {}
+	}
+
+	key := resource.Namespace + "#" + resource.Relation + "@" + subject.Namespace + "#" + subject.Relation
+	rl := l.relationLocality(ctx, key, metadata, resource, subject)
+
+	evaluateLocally := l.evaluateLocally(rl)
+	if evaluateLocally {
+		localityDecisionCount.WithLabelValues(operation, localLocality).Inc()
+	} else {
+		localityDecisionCount.WithLabelValues(operation, remoteLocality).Inc()
+	}
+
+	start := l.timeSource.Now()
+	return evaluateLocally, func(err error) {
+		if err != nil {
+			return
+		}
+
+		duration := l.timeSource.Since(start)
+
+		l.Lock()
+		defer l.Unlock()
+		if !rl.direct {
+			return
+		}
+
+		if evaluateLocally {
+			rl.local.add(duration)
+		} else {
+			rl.remote.add(duration)
+		}
+	}
+}
+
+func (l *locality) evaluateLocally(rl *relationLocality) bool {
+	l.Lock()
+	defer l.Unlock()
+
+	if !rl.direct {
+		return false
+	}
+
+	rl.requests++
+	if rl.requests%probeInterval == 0 {
+		return false
+	}
+
+	if rl.local.samples < minLatencySamples || rl.remote.samples < minLatencySamples {
+		return true
+	}
+	return rl.local.seconds <= rl.remote.seconds
+}
+
+func (l *locality) relationLocality(
+	ctx context.Context,
+	key string,
+	metadata *v1.ResolverMeta,
+	resource *core.RelationReference,
+	subject *core.RelationReference,
+) *relationLocality {
+	now := l.timeSource.Now()
+
+	l.Lock()
+	rl, ok := l.relations[key]
+	if !ok {
+		rl = &relationLocality{}
+		l.relations[key] = rl
+	}
+	stale := !ok || now.Sub(rl.checkedAt) >= directnessTTL
+	l.Unlock()
+
+	if !stale {
+		return rl
+	}
+
+	direct, err := isDirect(ctx, metadata, resource, subject)
+	if err != nil {
+		log.Ctx(ctx).Debug().Err(err).Str("relation", key).Msg("unable to determine dispatch locality")
+	}
+
+	l.Lock()
+	rl.direct = direct
+	rl.checkedAt = now
+	l.Unlock()
+
+	return rl
+}
+
+// isDirect returns whether the subject type can only reach the resource relation through
+// relationships of the relation itself, such that resolving it requires a single query.
+func isDirect(
+	ctx context.Context,
+	metadata *v1.ResolverMeta,
+	resource *core.RelationReference,
+	subject *core.RelationReference,
+) (bool, error) {
+	revision, err := decimal.NewFromString(metadata.AtRevision)
+	if err != nil {
+		return false, err
+	}
+
+	reader := datastoremw.MustFromContext(ctx).SnapshotReader(revision)
+	_, typeSystem, err := namespace.ReadNamespaceAndTypes(ctx, resource.Namespace, reader)
+	if err != nil {
+		return false, err
+	}
+
+	entrypoints, err := namespace.ReachabilityGraphFor(typeSystem.AsValidated()).
+		AllEntrypointsForSubjectToResource(ctx, subject, resource)
+	if err != nil {
+		return false, err
+	}
+
+	if len(entrypoints) == 0 {
+		return false, nil
+	}
+
+	for _, entrypoint := range entrypoints {
+		if entrypoint.EntrypointKind() != core.ReachabilityEntrypoint_RELATION_ENTRYPOINT || !entrypoint.IsDirectResult() {
+			return false, nil
+		}
+
+		containing := entrypoint.ContainingRelationOrPermission()
+		if containing.Namespace != resource.Namespace || containing.Relation != resource.Relation {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
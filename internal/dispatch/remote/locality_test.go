@@ -0,0 +1,105 @@
+package remote
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/spicedb/internal/datastore/memdb"
+	datastoremw "github.com/authzed/spicedb/internal/middleware/datastore"
+	"github.com/authzed/spicedb/internal/testfixtures"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	v1 "github.com/authzed/spicedb/pkg/proto/dispatch/v1"
+)
+
+func TestIsDirect(t *testing.T) {
+	testCases := []struct {
+		resource       string
+		subject        string
+		expectedDirect bool
+	}{
+		{"document#owner", "user#...", true},
+		{"document#editor", "user#...", false},
+		{"document#viewer", "user#...", false},
+		{"document#viewer_and_editor", "user#...", false},
+		{"folder#owner", "user#...", true},
+		{"folder#viewer", "user#...", false},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.resource+"@"+tc.subject, func(t *testing.T) {
+			require := require.New(t)
+			ctx, metadata := localityTestContext(require)
+
+			direct, err := isDirect(ctx, metadata, relationReference(tc.resource), relationReference(tc.subject))
+			require.NoError(err)
+			require.Equal(tc.expectedDirect, direct)
+		})
+	}
+}
+
+func TestLocalityPlacement(t *testing.T) {
+	require := require.New(t)
+	ctx, metadata := localityTestContext(require)
+
+	mockTime := clock.NewMock()
+	l := newLocality(nil, mockTime)
+
+	owner := &core.RelationReference{Namespace: "document", Relation: "owner"}
+	viewer := &core.RelationReference{Namespace: "document", Relation: "viewer"}
+	user := &core.RelationReference{Namespace: "user", Relation: "..."}
+
+	run := func(resource *core.RelationReference, localLatency, remoteLatency time.Duration) bool {
+		evaluateLocally, record := l.place(ctx, "check", metadata, resource, user)
+		if evaluateLocally {
+			mockTime.Add(localLatency)
+		} else {
+			mockTime.Add(remoteLatency)
+		}
+		record(nil)
+		return evaluateLocally
+	}
+
+	// Recursive requests are always sent to peer nodes.
+	require.False(run(viewer, time.Millisecond, time.Millisecond))
+
+	// Direct requests are evaluated locally, other than periodic probes of remote latency.
+	remoteCount := 0
+	for i := 0; i < probeInterval*minLatencySamples; i++ {
+		if !run(owner, 10*time.Millisecond, 10*time.Millisecond) {
+			remoteCount++
+		}
+	}
+	require.Equal(minLatencySamples, remoteCount)
+
+	// Once slower than remote requests, direct requests are sent to peer nodes.
+	require.True(run(owner, 100*time.Millisecond, 10*time.Millisecond))
+	require.False(run(owner, 100*time.Millisecond, 10*time.Millisecond))
+	require.False(run(owner, 100*time.Millisecond, 10*time.Millisecond))
+
+	// A nil locality sends all requests to peer nodes.
+	var disabled *locality
+	evaluateLocally, _ := disabled.place(ctx, "check", metadata, owner, user)
+	require.False(evaluateLocally)
+}
+
+func localityTestContext(require *require.Assertions) (context.Context, *v1.ResolverMeta) {
+	rawDS, err := memdb.NewMemdbDatastore(0, 0, memdb.DisableGC)
+	require.NoError(err)
+
+	ds, revision := testfixtures.StandardDatastoreWithSchema(rawDS, require)
+	return datastoremw.ContextWithDatastore(context.Background(), ds), &v1.ResolverMeta{
+		AtRevision:     revision.String(),
+		DepthRemaining: 50,
+	}
+}
+
+func relationReference(ref string) *core.RelationReference {
+	namespace, relation, _ := strings.Cut(ref, "#")
+	return &core.RelationReference{Namespace: namespace, Relation: relation}
+}
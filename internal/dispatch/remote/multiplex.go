@@ -0,0 +1,397 @@
+package remote
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/sync/semaphore"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"github.com/authzed/spicedb/pkg/balancer"
+	v1 "github.com/authzed/spicedb/pkg/proto/dispatch/v1"
+)
+
+const (
+	// maxInFlightStreamRequests is the maximum number of requests in flight on a single
+	// multiplexed stream. It matches the number evaluated concurrently by the peer.
+	maxInFlightStreamRequests = 1024
+
+	// reachableResourcesWindow is the number of reachable resources a peer may send for a
+	// single request before further credit is granted.
+	reachableResourcesWindow = 100
+
+	// streamingUnsupportedCooldown is how long requests to a peer which does not support
+	// multiplexed streams are sent as individual calls before a stream is tried again.
+	streamingUnsupportedCooldown = 1 * time.Minute
+)
+
+var openStreamsGauge = promauto.NewGauge(prometheus.GaugeOpts{
+	Namespace: "spicedb",
+	Subsystem: "dispatch",
+	Name:      "multiplexed_streams",
+	Help:      "number of open streams over which dispatch requests are multiplexed to peer nodes",
+})
+
+// errStreamingUnsupported is returned when a request cannot be multiplexed over a stream and
+// must instead be sent as an individual call.
+var errStreamingUnsupported = errors.New("dispatch streams are not supported by the peer")
+
+type streamingClient interface {
+	DispatchStream(ctx context.Context, opts ...grpc.CallOption) (v1.DispatchService_DispatchStreamClient, error)
+}
+
+// multiplexer sends dispatch requests over a single stream to each peer node, chosen by the
+// router in the same way as individual calls.
+type multiplexer struct {
+	client     streamingClient
+	router     *balancer.Router
+	timeSource clock.Clock
+
+	sync.Mutex
+	streams     map[string]*muxStream
+	unsupported map[string]time.Time
+	closed      bool
+}
+
+func newMultiplexer(client streamingClient, router *balancer.Router, timeSource clock.Clock) *multiplexer {
+	return &multiplexer{
+		client:      client,
+		router:      router,
+		timeSource:  timeSource,
+		streams:     map[string]*muxStream{},
+		unsupported: map[string]time.Time{},
+	}
+}
+
+func (m *multiplexer) dispatchCheck(ctx context.Context, attempt uint8, req *v1.DispatchCheckRequest) (*v1.DispatchCheckResponse, error) {
+	var resp *v1.DispatchCheckResponse
+	err := m.call(ctx, attempt, &v1.DispatchStreamRequest{
+		Message: &v1.DispatchStreamRequest_Check{Check: req},
+	}, func(result *v1.DispatchStreamResponse) error {
+		resp = result.GetCheck()
+		return expectResponse(resp != nil, result)
+	})
+	return resp, err
+}
+
+func (m *multiplexer) dispatchExpand(ctx context.Context, attempt uint8, req *v1.DispatchExpandRequest) (*v1.DispatchExpandResponse, error) {
+	var resp *v1.DispatchExpandResponse
+	err := m.call(ctx, attempt, &v1.DispatchStreamRequest{
+		Message: &v1.DispatchStreamRequest_Expand{Expand: req},
+	}, func(result *v1.DispatchStreamResponse) error {
+		resp = result.GetExpand()
+		return expectResponse(resp != nil, result)
+	})
+	return resp, err
+}
+
+func (m *multiplexer) dispatchLookup(ctx context.Context, attempt uint8, req *v1.DispatchLookupRequest) (*v1.DispatchLookupResponse, error) {
+	var resp *v1.DispatchLookupResponse
+	err := m.call(ctx, attempt, &v1.DispatchStreamRequest{
+		Message: &v1.DispatchStreamRequest_Lookup{Lookup: req},
+	}, func(result *v1.DispatchStreamResponse) error {
+		resp = result.GetLookup()
+		return expectResponse(resp != nil, result)
+	})
+	return resp, err
+}
+
+func (m *multiplexer) dispatchReachableResources(
+	ctx context.Context,
+	attempt uint8,
+	req *v1.DispatchReachableResourcesRequest,
+	handle func(result *v1.DispatchReachableResourcesResponse) error,
+) error {
+	return m.call(ctx, attempt, &v1.DispatchStreamRequest{
+		Message: &v1.DispatchStreamRequest_ReachableResources{ReachableResources: req},
+		Window:  reachableResourcesWindow,
+	}, func(result *v1.DispatchStreamResponse) error {
+		resp := result.GetReachableResources()
+		if err := expectResponse(resp != nil, result); err != nil {
+			return err
+		}
+		return handle(resp)
+	})
+}
+
+// call sends the request over the stream to the peer chosen for the given attempt of the
+// request key in the context, and invokes handle with each of its results. Returns
+// errStreamingUnsupported, without invoking handle, if the request must instead be sent as an
+// individual call.
+func (m *multiplexer) call(ctx context.Context, attempt uint8, req *v1.DispatchStreamRequest, handle func(*v1.DispatchStreamResponse) error) error {
+	if m == nil {
+		return errStreamingUnsupported
+	}
+
+	key, _ := ctx.Value(balancer.CtxKey).([]byte)
+	member, done, err := m.router.Route(key, attempt)
+	if err != nil {
+		// The connection is not yet ready, or has no ready members: individual calls wait
+		// for, or report, its state.
+		return errStreamingUnsupported
+	}
+
+	s, err := m.stream(member)
+	if err == nil {
+		err = s.call(ctx, req, handle)
+	}
+	if !errors.Is(err, errStreamingUnsupported) {
+		done(err)
+	}
+	return err
+}
+
+// stream returns the open stream to the member, opening it if necessary.
+func (m *multiplexer) stream(member string) (*muxStream, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	if m.closed {
+		return nil, errStreamingUnsupported
+	}
+
+	if s, ok := m.streams[member]; ok {
+		return s, nil
+	}
+
+	if since, ok := m.unsupported[member]; ok {
+		if m.timeSource.Since(since) < streamingUnsupportedCooldown {
+			return nil, errStreamingUnsupported
+		}
+		delete(m.unsupported, member)
+	}
+
+	// The stream outlives the request which opened it, so it is not bound to the request's
+	// context; each request's deadline is instead sent with the request itself.
+	ctx, cancel := context.WithCancel(context.Background())
+	client, err := m.client.DispatchStream(balancer.WithMember(ctx, member))
+	if err != nil {
+		cancel()
+		if status.Code(err) == codes.Unimplemented {
+			m.unsupported[member] = m.timeSource.Now()
+			return nil, errStreamingUnsupported
+		}
+		return nil, err
+	}
+
+	s := &muxStream{
+		client:  client,
+		cancel:  cancel,
+		slots:   semaphore.NewWeighted(maxInFlightStreamRequests),
+		pending: map[uint64]chan *v1.DispatchStreamResponse{},
+		done:    make(chan struct{}),
+	}
+	m.streams[member] = s
+	openStreamsGauge.Inc()
+
+	go func() {
+		// Peers advertise their weight on the hashring in the headers of the stream.
+		if header, err := client.Header(); err == nil {
+			m.router.ObserveWeight(member, header)
+		}
+
+		err := s.receive()
+		openStreamsGauge.Dec()
+
+		m.Lock()
+		defer m.Unlock()
+		if m.streams[member] == s {
+			delete(m.streams, member)
+		}
+		if errors.Is(err, errStreamingUnsupported) {
+			log.Info().Str("member", member).Msg("peer does not support dispatch streams, falling back to individual calls")
+			m.unsupported[member] = m.timeSource.Now()
+		}
+	}()
+
+	return s, nil
+}
+
+// close closes all open streams. Requests in flight on them fail.
+func (m *multiplexer) close() {
+	if m == nil {
+		return
+	}
+
+	m.Lock()
+	defer m.Unlock()
+
+	m.closed = true
+	for _, s := range m.streams {
+		s.cancel()
+	}
+}
+
+// muxStream is a single stream to a peer, over which requests are multiplexed by ID.
+type muxStream struct {
+	client v1.DispatchService_DispatchStreamClient
+	cancel context.CancelFunc
+	slots  *semaphore.Weighted
+	nextID uint64
+
+	sendLock sync.Mutex
+
+	sync.Mutex
+	pending map[uint64]chan *v1.DispatchStreamResponse
+
+	// err is the error with which the stream failed, and is set before done is closed.
+	err  error
+	done chan struct{}
+}
+
+func (s *muxStream) call(ctx context.Context, req *v1.DispatchStreamRequest, handle func(*v1.DispatchStreamResponse) error) error {
+	if err := s.slots.Acquire(ctx, 1); err != nil {
+		return err
+	}
+	defer s.slots.Release(1)
+
+	id := atomic.AddUint64(&s.nextID, 1)
+	results := make(chan *v1.DispatchStreamResponse, req.Window+1)
+
+	s.Lock()
+	if s.err != nil {
+		s.Unlock()
+		return s.err
+	}
+	s.pending[id] = results
+	s.Unlock()
+	defer s.forget(id)
+
+	req.Id = id
+	if deadline, ok := ctx.Deadline(); ok {
+		req.Timeout = durationpb.New(time.Until(deadline))
+	}
+
+	if err := s.send(req); err != nil {
+		// The cause of a failed send is returned by the stream's receive loop.
+		select {
+		case <-s.done:
+			return s.err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	credit := uint32(0)
+	for {
+		var result *v1.DispatchStreamResponse
+		select {
+		case result = <-results:
+		case <-ctx.Done():
+			s.abandon(id)
+			return ctx.Err()
+		case <-s.done:
+			// Results received before the stream failed are still handled.
+			select {
+			case result = <-results:
+			default:
+				return s.err
+			}
+		}
+
+		switch message := result.Message.(type) {
+		case *v1.DispatchStreamResponse_Error:
+			return status.ErrorProto(&spb.Status{
+				Code:    int32(message.Error.Code),
+				Message: message.Error.Message,
+				Details: message.Error.Details,
+			})
+
+		case *v1.DispatchStreamResponse_Complete:
+			return nil
+
+		case *v1.DispatchStreamResponse_ReachableResources:
+			if err := handle(result); err != nil {
+				s.abandon(id)
+				return err
+			}
+
+			credit++
+			if credit >= req.Window/2 {
+				if err := s.send(&v1.DispatchStreamRequest{
+					Id:      id,
+					Message: &v1.DispatchStreamRequest_Credit{Credit: &v1.DispatchStreamCredit{Count: credit}},
+				}); err != nil {
+					continue
+				}
+				credit = 0
+			}
+
+		default:
+			return handle(result)
+		}
+	}
+}
+
+// receive delivers each response received to its pending request, until the stream fails.
+func (s *muxStream) receive() error {
+	for {
+		resp, err := s.client.Recv()
+		if err != nil {
+			switch {
+			case status.Code(err) == codes.Unimplemented:
+				err = errStreamingUnsupported
+			case errors.Is(err, io.EOF):
+				err = status.Error(codes.Unavailable, "dispatch stream closed by peer")
+			}
+
+			s.Lock()
+			s.err = err
+			close(s.done)
+			s.Unlock()
+			s.cancel()
+			return err
+		}
+
+		s.Lock()
+		results, ok := s.pending[resp.Id]
+		s.Unlock()
+
+		// Responses for abandoned requests are dropped. Otherwise, the request's window
+		// ensures that there is always room for the response.
+		if ok {
+			results <- resp
+		}
+	}
+}
+
+func (s *muxStream) send(req *v1.DispatchStreamRequest) error {
+	s.sendLock.Lock()
+	defer s.sendLock.Unlock()
+	return s.client.Send(req)
+}
+
+// abandon cancels the request on the peer.
+func (s *muxStream) abandon(id uint64) {
+	s.forget(id)
+	_ = s.send(&v1.DispatchStreamRequest{
+		Id:      id,
+		Message: &v1.DispatchStreamRequest_Cancel{Cancel: &v1.DispatchStreamCancel{}},
+	})
+}
+
+func (s *muxStream) forget(id uint64) {
+	s.Lock()
+	defer s.Unlock()
+	delete(s.pending, id)
+}
+
+func expectResponse(ok bool, result *v1.DispatchStreamResponse) error {
+	if !ok {
+		return fmt.Errorf("unexpected dispatch stream response %T", result.Message)
+	}
+	return nil
+}
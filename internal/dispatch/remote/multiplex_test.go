@@ -0,0 +1,388 @@
+package remote
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	grpcbalancer "google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/authzed/spicedb/internal/dispatch"
+	dispatchservice "github.com/authzed/spicedb/internal/services/dispatch/v1"
+	"github.com/authzed/spicedb/pkg/balancer"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	v1 "github.com/authzed/spicedb/pkg/proto/dispatch/v1"
+)
+
+func init() {
+	grpcbalancer.Register(balancer.NewConsistentHashringBuilder(xxhash.Sum64, 20, 1))
+}
+
+func TestMultiplexedDispatch(t *testing.T) {
+	require := require.New(t)
+
+	local := &fakeDispatcher{
+		check: func(ctx context.Context, req *v1.DispatchCheckRequest) (*v1.DispatchCheckResponse, error) {
+			return &v1.DispatchCheckResponse{
+				Metadata:   &v1.ResponseMeta{DispatchCount: 1},
+				Membership: v1.DispatchCheckResponse_MEMBER,
+			}, nil
+		},
+	}
+	peer := newTestPeer(t, dispatchservice.NewDispatchServer(local))
+	dispatcher := peer.dispatcher(t)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := dispatcher.DispatchCheck(context.Background(), checkRequest(fmt.Sprintf("doc%d", i)))
+			require.NoError(err)
+			require.Equal(v1.DispatchCheckResponse_MEMBER, resp.Membership)
+		}()
+	}
+	wg.Wait()
+
+	require.Equal(uint64(0), atomic.LoadUint64(&peer.unaryCalls))
+	require.Equal(uint64(1), atomic.LoadUint64(&peer.streams))
+}
+
+func TestMultiplexedDispatchError(t *testing.T) {
+	require := require.New(t)
+
+	local := &fakeDispatcher{
+		check: func(ctx context.Context, req *v1.DispatchCheckRequest) (*v1.DispatchCheckResponse, error) {
+			return &v1.DispatchCheckResponse{Metadata: &v1.ResponseMeta{}}, dispatch.NewBudgetExhaustedErr("dispatches", 1)
+		},
+	}
+	peer := newTestPeer(t, dispatchservice.NewDispatchServer(local))
+	dispatcher := peer.dispatcher(t)
+
+	_, err := dispatcher.DispatchCheck(context.Background(), checkRequest("doc"))
+	require.Error(err)
+	require.ErrorAs(err, &dispatch.ErrBudgetExhausted{})
+	require.Equal(uint64(1), atomic.LoadUint64(&peer.streams))
+}
+
+func TestMultiplexingFallsBackToUnary(t *testing.T) {
+	require := require.New(t)
+
+	local := &fakeDispatcher{
+		check: func(ctx context.Context, req *v1.DispatchCheckRequest) (*v1.DispatchCheckResponse, error) {
+			return &v1.DispatchCheckResponse{
+				Metadata:   &v1.ResponseMeta{DispatchCount: 1},
+				Membership: v1.DispatchCheckResponse_MEMBER,
+			}, nil
+		},
+	}
+	peer := newTestPeer(t, unaryOnlyServer{dispatchservice.NewDispatchServer(local)})
+	dispatcher := peer.dispatcher(t)
+
+	for i := 0; i < 3; i++ {
+		resp, err := dispatcher.DispatchCheck(context.Background(), checkRequest("doc"))
+		require.NoError(err)
+		require.Equal(v1.DispatchCheckResponse_MEMBER, resp.Membership)
+	}
+
+	// Once the peer is found not to support streams, no further streams are opened to it.
+	require.Equal(uint64(3), atomic.LoadUint64(&peer.unaryCalls))
+	require.Equal(uint64(1), atomic.LoadUint64(&peer.streams))
+}
+
+func TestMultiplexedDispatchCancellation(t *testing.T) {
+	require := require.New(t)
+
+	started := make(chan struct{})
+	canceled := make(chan struct{})
+	local := &fakeDispatcher{
+		check: func(ctx context.Context, req *v1.DispatchCheckRequest) (*v1.DispatchCheckResponse, error) {
+			if req.ObjectAndRelation.ObjectId != "blocking" {
+				return &v1.DispatchCheckResponse{Metadata: &v1.ResponseMeta{}}, nil
+			}
+
+			close(started)
+			<-ctx.Done()
+			close(canceled)
+			return &v1.DispatchCheckResponse{Metadata: &v1.ResponseMeta{}}, ctx.Err()
+		},
+	}
+	peer := newTestPeer(t, dispatchservice.NewDispatchServer(local))
+	dispatcher := peer.dispatcher(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	go func() {
+		_, err := dispatcher.DispatchCheck(ctx, checkRequest("blocking"))
+		errs <- err
+	}()
+
+	<-started
+	cancel()
+	require.ErrorIs(<-errs, context.Canceled)
+
+	select {
+	case <-canceled:
+	case <-time.After(5 * time.Second):
+		require.Fail("request was not canceled on the peer")
+	}
+
+	// The stream remains usable by other requests.
+	_, err := dispatcher.DispatchCheck(context.Background(), checkRequest("doc"))
+	require.NoError(err)
+	require.Equal(uint64(1), atomic.LoadUint64(&peer.streams))
+}
+
+func TestMultiplexedDispatchDeadline(t *testing.T) {
+	require := require.New(t)
+
+	peerDeadlines := make(chan time.Time, 1)
+	local := &fakeDispatcher{
+		check: func(ctx context.Context, req *v1.DispatchCheckRequest) (*v1.DispatchCheckResponse, error) {
+			deadline, _ := ctx.Deadline()
+			peerDeadlines <- deadline
+
+			<-ctx.Done()
+			return &v1.DispatchCheckResponse{Metadata: &v1.ResponseMeta{}}, ctx.Err()
+		},
+	}
+	peer := newTestPeer(t, dispatchservice.NewDispatchServer(local))
+	dispatcher := peer.dispatcher(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	_, err := dispatcher.DispatchCheck(ctx, checkRequest("blocking"))
+	require.Error(err)
+
+	// The caller's deadline is enforced on the peer, rather than the request running there
+	// until the caller abandons it.
+	deadline, _ := ctx.Deadline()
+	select {
+	case peerDeadline := <-peerDeadlines:
+		require.False(peerDeadline.IsZero(), "request has no deadline on the peer")
+		require.WithinDuration(deadline, peerDeadline, 50*time.Millisecond)
+	case <-time.After(5 * time.Second):
+		require.Fail("request did not reach the peer")
+	}
+	require.Equal(uint64(1), atomic.LoadUint64(&peer.streams))
+}
+
+func TestDispatchStreamCancellationWhileSaturated(t *testing.T) {
+	require := require.New(t)
+
+	local := &fakeDispatcher{
+		check: func(ctx context.Context, req *v1.DispatchCheckRequest) (*v1.DispatchCheckResponse, error) {
+			<-ctx.Done()
+			return &v1.DispatchCheckResponse{Metadata: &v1.ResponseMeta{}}, ctx.Err()
+		},
+	}
+	peer := newTestPeer(t, dispatchservice.NewDispatchServer(local))
+
+	stream, err := peer.client(t).DispatchStream(context.Background())
+	require.NoError(err)
+
+	// More requests are sent than can be evaluated concurrently, after which cancellations
+	// must still be read, both for the requests being evaluated and those waiting.
+	const requestCount = maxInFlightStreamRequests + 1
+	for id := uint64(1); id <= requestCount; id++ {
+		require.NoError(stream.Send(&v1.DispatchStreamRequest{
+			Id:      id,
+			Message: &v1.DispatchStreamRequest_Check{Check: checkRequest("blocking")},
+		}))
+	}
+	for id := uint64(1); id <= requestCount; id++ {
+		require.NoError(stream.Send(&v1.DispatchStreamRequest{
+			Id:      id,
+			Message: &v1.DispatchStreamRequest_Cancel{Cancel: &v1.DispatchStreamCancel{}},
+		}))
+	}
+
+	responded := map[uint64]bool{}
+	for len(responded) < requestCount {
+		resp, err := stream.Recv()
+		require.NoError(err)
+		require.NotNil(resp.GetError(), "request %d", resp.Id)
+		require.Equal(uint32(codes.Canceled), resp.GetError().Code)
+		require.False(responded[resp.Id], "request %d responded to twice", resp.Id)
+		responded[resp.Id] = true
+	}
+
+	require.NoError(stream.CloseSend())
+}
+
+func TestMultiplexedReachableResourcesFlowControl(t *testing.T) {
+	require := require.New(t)
+
+	const resultCount = 10 * reachableResourcesWindow
+
+	var published uint64
+	local := &fakeDispatcher{
+		reachableResources: func(req *v1.DispatchReachableResourcesRequest, stream dispatch.ReachableResourcesStream) error {
+			for i := 0; i < resultCount; i++ {
+				err := stream.Publish(&v1.DispatchReachableResourcesResponse{
+					Resource: &v1.ReachableResource{
+						Resource:     &core.ObjectAndRelation{Namespace: "document", ObjectId: fmt.Sprintf("doc%d", i), Relation: "view"},
+						ResultStatus: v1.ReachableResource_HAS_PERMISSION,
+					},
+					Metadata: &v1.ResponseMeta{DispatchCount: 1},
+				})
+				if err != nil {
+					return err
+				}
+				atomic.AddUint64(&published, 1)
+			}
+			return nil
+		},
+	}
+	peer := newTestPeer(t, dispatchservice.NewDispatchServer(local))
+	dispatcher := peer.dispatcher(t)
+
+	release := make(chan struct{})
+	var received []string
+	errs := make(chan error, 1)
+	go func() {
+		errs <- dispatcher.DispatchReachableResources(&v1.DispatchReachableResourcesRequest{
+			Metadata:       &v1.ResolverMeta{AtRevision: "1", DepthRemaining: 50},
+			ObjectRelation: &core.RelationReference{Namespace: "document", Relation: "view"},
+			Subject:        &core.ObjectAndRelation{Namespace: "user", ObjectId: "tom", Relation: "..."},
+		}, dispatch.NewHandlingDispatchStream(context.Background(), func(result *v1.DispatchReachableResourcesResponse) error {
+			<-release
+			received = append(received, result.Resource.Resource.ObjectId)
+			return nil
+		}))
+	}()
+
+	// While no results are consumed, the peer sends no more than a single window of them.
+	require.Eventually(func() bool {
+		return atomic.LoadUint64(&published) == reachableResourcesWindow
+	}, 5*time.Second, 10*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	require.Equal(uint64(reachableResourcesWindow), atomic.LoadUint64(&published))
+
+	close(release)
+	require.NoError(<-errs)
+	require.Len(received, resultCount)
+	for i, objectID := range received {
+		require.Equal(fmt.Sprintf("doc%d", i), objectID)
+	}
+	require.Equal(uint64(0), atomic.LoadUint64(&peer.unaryCalls))
+}
+
+func checkRequest(objectID string) *v1.DispatchCheckRequest {
+	return &v1.DispatchCheckRequest{
+		Metadata:          &v1.ResolverMeta{AtRevision: "1", DepthRemaining: 50},
+		ObjectAndRelation: &core.ObjectAndRelation{Namespace: "document", ObjectId: objectID, Relation: "view"},
+		Subject:           &core.ObjectAndRelation{Namespace: "user", ObjectId: "tom", Relation: "..."},
+	}
+}
+
+// testPeer is a single peer node, serving dispatch requests over an in-memory connection.
+type testPeer struct {
+	listener   *bufconn.Listener
+	unaryCalls uint64
+	streams    uint64
+}
+
+func newTestPeer(t *testing.T, srv v1.DispatchServiceServer) *testPeer {
+	peer := &testPeer{listener: bufconn.Listen(1024 * 1024)}
+
+	server := grpc.NewServer(
+		grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			atomic.AddUint64(&peer.unaryCalls, 1)
+			return handler(ctx, req)
+		}),
+		grpc.StreamInterceptor(func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			if info.FullMethod == "/dispatch.v1.DispatchService/DispatchStream" {
+				atomic.AddUint64(&peer.streams, 1)
+			}
+			return handler(srv, ss)
+		}),
+	)
+	v1.RegisterDispatchServiceServer(server, srv)
+	go func() {
+		_ = server.Serve(peer.listener)
+	}()
+	t.Cleanup(server.Stop)
+
+	return peer
+}
+
+// dispatcher returns a cluster dispatcher which multiplexes requests to the peer.
+func (tp *testPeer) dispatcher(t *testing.T, options ...Option) dispatch.Dispatcher {
+	router := balancer.NewRouter()
+	t.Cleanup(router.Close)
+
+	client := tp.client(t, grpc.WithDefaultServiceConfig(router.ServiceConfig("")))
+	dispatcher := NewClusterDispatcher(client, nil, append([]Option{Multiplexing(client, router)}, options...)...)
+	t.Cleanup(func() {
+		_ = dispatcher.Close()
+	})
+	return dispatcher
+}
+
+// client returns a client for the peer's dispatch service.
+func (tp *testPeer) client(t *testing.T, opts ...grpc.DialOption) v1.DispatchServiceClient {
+	opts = append([]grpc.DialOption{
+		grpc.WithContextDialer(func(ctx context.Context, s string) (net.Conn, error) {
+			return tp.listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithBlock(),
+	}, opts...)
+
+	conn, err := grpc.Dial("passthrough:///bufnet", opts...)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+
+	return v1.NewDispatchServiceClient(conn)
+}
+
+// unaryOnlyServer is a dispatch server which predates dispatch streams.
+type unaryOnlyServer struct {
+	v1.DispatchServiceServer
+}
+
+func (unaryOnlyServer) DispatchStream(v1.DispatchService_DispatchStreamServer) error {
+	return status.Error(codes.Unimplemented, "method DispatchStream not implemented")
+}
+
+type fakeDispatcher struct {
+	check              func(ctx context.Context, req *v1.DispatchCheckRequest) (*v1.DispatchCheckResponse, error)
+	reachableResources func(req *v1.DispatchReachableResourcesRequest, stream dispatch.ReachableResourcesStream) error
+}
+
+func (fd *fakeDispatcher) DispatchCheck(ctx context.Context, req *v1.DispatchCheckRequest) (*v1.DispatchCheckResponse, error) {
+	return fd.check(ctx, req)
+}
+
+func (fd *fakeDispatcher) DispatchExpand(ctx context.Context, req *v1.DispatchExpandRequest) (*v1.DispatchExpandResponse, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (fd *fakeDispatcher) DispatchLookup(ctx context.Context, req *v1.DispatchLookupRequest) (*v1.DispatchLookupResponse, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (fd *fakeDispatcher) DispatchReachableResources(req *v1.DispatchReachableResourcesRequest, stream dispatch.ReachableResourcesStream) error {
+	return fd.reachableResources(req, stream)
+}
+
+func (fd *fakeDispatcher) Close() error {
+	return nil
+}
@@ -77,6 +77,25 @@ func (s *CollectingDispatchStream[T]) Publish(result T) error {
 	return nil
 }
 
+// NewHandlingDispatchStream creates a new dispatch stream which invokes the given handler for
+// each result published. The handler must be safe for concurrent use.
+func NewHandlingDispatchStream[T any](ctx context.Context, handler func(result T) error) Stream[T] {
+	return &handlingDispatchStream[T]{ctx, handler}
+}
+
+type handlingDispatchStream[T any] struct {
+	ctx     context.Context
+	handler func(result T) error
+}
+
+func (s *handlingDispatchStream[T]) Context() context.Context {
+	return s.ctx
+}
+
+func (s *handlingDispatchStream[T]) Publish(result T) error {
+	return s.handler(result)
+}
+
 // WrappedDispatchStream is a dispatch stream that wraps another dispatch stream, and performs
 // an operation on each result before puppeting back up to the parent stream.
 type WrappedDispatchStream[T any] struct {
@@ -113,3 +132,4 @@ func StreamWithContext[T any](context context.Context, stream Stream[T]) Stream[
 // Ensure the streams implement the interface.
 var _ Stream[any] = &CollectingDispatchStream[any]{}
 var _ Stream[any] = &WrappedDispatchStream[any]{}
+var _ Stream[any] = &handlingDispatchStream[any]{}
@@ -45,6 +45,9 @@ type ValidatedCheckRequest struct {
 func (cc *ConcurrentChecker) Check(ctx context.Context, req ValidatedCheckRequest, relation *core.Relation) (*v1.DispatchCheckResponse, error) {
 	var directFunc ReduceableCheckFunc
 
+	ctx, recorder := contextWithReadRelationsRecorder(ctx)
+	recordReadRelation(ctx, req.ObjectAndRelation.Namespace, req.ObjectAndRelation.Relation)
+
 	// TODO(jschorr): Turn into an error once v0 API has been removed.
 	if relation.GetTypeInformation() == nil && relation.GetUsersetRewrite() == nil {
 		log.Ctx(ctx).Warn().Str("relation", relation.Name).Msg("Found relation without type information. Please switch to using schema. This will be an error in the future!")
@@ -63,6 +66,7 @@ func (cc *ConcurrentChecker) Check(ctx context.Context, req ValidatedCheckReques
 
 	resolved := union(ctx, []ReduceableCheckFunc{directFunc})
 	resolved.Resp.Metadata = addCallToResponseMetadata(resolved.Resp.Metadata)
+	resolved.Resp.Metadata.ReadRelations = mergeReadRelations(resolved.Resp.Metadata.ReadRelations, recorder.recorded())
 	return resolved.Resp, resolved.Err
 }
 
@@ -83,6 +87,11 @@ func (cc *ConcurrentChecker) checkDirect(ctx context.Context, req ValidatedCheck
 		log.Ctx(ctx).Trace().Object("direct", req).Send()
 		ds := datastoremw.MustFromContext(ctx).SnapshotReader(req.Revision)
 
+		if err := dispatch.ConsumeDatastoreQuery(ctx); err != nil {
+			resultChan <- checkResultError(err, emptyMetadata)
+			return
+		}
+
 		// TODO(jschorr): Use type information to further optimize this query.
 		it, err := ds.QueryRelationships(ctx, &v1_proto.RelationshipFilter{
 			ResourceType:       req.ObjectAndRelation.Namespace,
@@ -190,6 +199,7 @@ func (cc *ConcurrentChecker) checkComputedUserset(ctx context.Context, req Valid
 	}
 
 	// Check if the target relation exists. If not, return nothing.
+	recordReadRelation(ctx, start.Namespace, cu.Relation)
 	ds := datastoremw.MustFromContext(ctx).SnapshotReader(req.Revision)
 	err := namespace.CheckNamespaceAndRelation(ctx, start.Namespace, cu.Relation, true, ds)
 	if err != nil {
@@ -214,6 +224,12 @@ func (cc *ConcurrentChecker) checkTupleToUserset(ctx context.Context, req Valida
 	return func(ctx context.Context, resultChan chan<- CheckResult) {
 		log.Ctx(ctx).Trace().Object("ttu", req).Send()
 		ds := datastoremw.MustFromContext(ctx).SnapshotReader(req.Revision)
+		if err := dispatch.ConsumeDatastoreQuery(ctx); err != nil {
+			resultChan <- checkResultError(err, emptyMetadata)
+			return
+		}
+
+		recordReadRelation(ctx, req.ObjectAndRelation.Namespace, ttu.Tupleset.Relation)
 		it, err := ds.QueryRelationships(ctx, &v1_proto.RelationshipFilter{
 			ResourceType:       req.ObjectAndRelation.Namespace,
 			OptionalResourceId: req.ObjectAndRelation.ObjectId,
@@ -246,7 +262,7 @@ func all(ctx context.Context, requests []ReduceableCheckFunc) CheckResult {
 
 	responseMetadata := emptyMetadata
 	resultChan := make(chan CheckResult, len(requests))
-	childCtx, cancelFn := context.WithCancel(ctx)
+	childCtx, cancelFn := context.WithCancel(dispatch.ContextWithFanOut(ctx, uint32(len(requests))))
 	defer cancelFn()
 
 	for _, req := range requests {
@@ -300,7 +316,7 @@ func union(ctx context.Context, requests []ReduceableCheckFunc) CheckResult {
 	}
 
 	resultChan := make(chan CheckResult, len(requests))
-	childCtx, cancelFn := context.WithCancel(ctx)
+	childCtx, cancelFn := context.WithCancel(dispatch.ContextWithFanOut(ctx, uint32(len(requests))))
 	defer cancelFn()
 
 	for _, req := range requests {
@@ -332,7 +348,7 @@ func union(ctx context.Context, requests []ReduceableCheckFunc) CheckResult {
 
 // difference returns whether the first lazy check passes and none of the supsequent checks pass.
 func difference(ctx context.Context, requests []ReduceableCheckFunc) CheckResult {
-	childCtx, cancelFn := context.WithCancel(ctx)
+	childCtx, cancelFn := context.WithCancel(dispatch.ContextWithFanOut(ctx, uint32(len(requests))))
 	defer cancelFn()
 
 	baseChan := make(chan CheckResult, 1)
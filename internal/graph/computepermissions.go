@@ -0,0 +1,198 @@
+package graph
+
+import (
+	"context"
+	"sync"
+
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/authzed/spicedb/internal/dispatch"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	v1 "github.com/authzed/spicedb/pkg/proto/dispatch/v1"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+// ComputePermissions evaluates several relations of the request's resource for its subject in
+// a single evaluation of the relation graph. Each relation is evaluated after the relations of
+// the same resource which it references, and every subproblem is dispatched at most once, with
+// its result shared by all of the relations which reach it.
+//
+// Returns the response for each of the relations, in order. On error, the responses of the
+// relations which were evaluated are returned for their metadata.
+func ComputePermissions(ctx context.Context, d dispatch.Check, req ValidatedCheckRequest, relations []*core.Relation) ([]*v1.DispatchCheckResponse, error) {
+	shared := &sharedChecks{d: d, results: map[string]*v1.DispatchCheckResponse{}}
+	checker := NewConcurrentChecker(shared)
+
+	responses := make([]*v1.DispatchCheckResponse, len(relations))
+	for _, stage := range relationStages(relations) {
+		errG, stageCtx := errgroup.WithContext(ctx)
+		for _, index := range stage {
+			index := index
+			relation := relations[index]
+			errG.Go(func() error {
+				if err := dispatch.ConsumeDispatch(stageCtx); err != nil {
+					return err
+				}
+
+				relationReq := ValidatedCheckRequest{
+					&v1.DispatchCheckRequest{
+						ObjectAndRelation: &core.ObjectAndRelation{
+							Namespace: req.ObjectAndRelation.Namespace,
+							ObjectId:  req.ObjectAndRelation.ObjectId,
+							Relation:  relation.Name,
+						},
+						Subject:  req.Subject,
+						Metadata: req.Metadata,
+					},
+					req.Revision,
+				}
+
+				resp, err := checker.Check(stageCtx, relationReq, relation)
+				responses[index] = resp
+				if err != nil {
+					return err
+				}
+
+				shared.record(relationReq.DispatchCheckRequest, resp)
+				return nil
+			})
+		}
+
+		if err := errG.Wait(); err != nil {
+			return responses, err
+		}
+	}
+
+	return responses, nil
+}
+
+// relationStages orders the relations into stages, such that each relation is in a later stage
+// than the relations of the same resource which it references. Relations which reference each
+// other in a cycle are placed together in the final stage.
+func relationStages(relations []*core.Relation) [][]int {
+	indexes := make(map[string]int, len(relations))
+	for index, relation := range relations {
+		indexes[relation.Name] = index
+	}
+
+	dependencies := make([][]int, len(relations))
+	for index, relation := range relations {
+		for _, referenced := range referencedRelations(relation.UsersetRewrite, nil) {
+			if referencedIndex, ok := indexes[referenced]; ok && referencedIndex != index {
+				dependencies[index] = append(dependencies[index], referencedIndex)
+			}
+		}
+	}
+
+	staged := make([]bool, len(relations))
+	remaining := len(relations)
+
+	var stages [][]int
+	for remaining > 0 {
+		var stage []int
+		for index := range relations {
+			if !staged[index] && allStaged(dependencies[index], staged) {
+				stage = append(stage, index)
+			}
+		}
+
+		if len(stage) == 0 {
+			for index := range relations {
+				if !staged[index] {
+					stage = append(stage, index)
+				}
+			}
+		}
+
+		for _, index := range stage {
+			staged[index] = true
+		}
+		remaining -= len(stage)
+		stages = append(stages, stage)
+	}
+
+	return stages
+}
+
+func allStaged(indexes []int, staged []bool) bool {
+	for _, index := range indexes {
+		if !staged[index] {
+			return false
+		}
+	}
+	return true
+}
+
+// referencedRelations appends the relations of the same object referenced by the rewrite.
+func referencedRelations(usr *core.UsersetRewrite, referenced []string) []string {
+	var so *core.SetOperation
+	switch rw := usr.GetRewriteOperation().(type) {
+	case *core.UsersetRewrite_Union:
+		so = rw.Union
+	case *core.UsersetRewrite_Intersection:
+		so = rw.Intersection
+	case *core.UsersetRewrite_Exclusion:
+		so = rw.Exclusion
+	default:
+		return referenced
+	}
+
+	for _, childOneof := range so.Child {
+		switch child := childOneof.ChildType.(type) {
+		case *core.SetOperation_Child_ComputedUserset:
+			if child.ComputedUserset.Object == core.ComputedUserset_TUPLE_OBJECT {
+				referenced = append(referenced, child.ComputedUserset.Relation)
+			}
+		case *core.SetOperation_Child_UsersetRewrite:
+			referenced = referencedRelations(child.UsersetRewrite, referenced)
+		}
+	}
+	return referenced
+}
+
+// sharedChecks dispatches checks, reusing the results of those which have already completed
+// in the same evaluation.
+type sharedChecks struct {
+	d dispatch.Check
+
+	sync.Mutex
+	results map[string]*v1.DispatchCheckResponse
+}
+
+func (sc *sharedChecks) DispatchCheck(ctx context.Context, req *v1.DispatchCheckRequest) (*v1.DispatchCheckResponse, error) {
+	key := sharedCheckKey(req)
+
+	sc.Lock()
+	found, ok := sc.results[key]
+	sc.Unlock()
+
+	// A result is only reused if it was computed within the depth remaining for the request.
+	if ok && found.Metadata.DepthRequired <= req.Metadata.DepthRemaining {
+		return found, nil
+	}
+
+	resp, err := sc.d.DispatchCheck(ctx, req)
+	if err != nil {
+		return resp, err
+	}
+
+	sc.record(req, resp)
+	return resp, nil
+}
+
+// record saves the result of the check, to be reused by later checks. The result is reported
+// as cached when reused.
+func (sc *sharedChecks) record(req *v1.DispatchCheckRequest, resp *v1.DispatchCheckResponse) {
+	adjusted := proto.Clone(resp).(*v1.DispatchCheckResponse)
+	adjusted.Metadata.CachedDispatchCount += adjusted.Metadata.DispatchCount
+	adjusted.Metadata.DispatchCount = 0
+
+	sc.Lock()
+	defer sc.Unlock()
+	sc.results[sharedCheckKey(req)] = adjusted
+}
+
+func sharedCheckKey(req *v1.DispatchCheckRequest) string {
+	return tuple.StringONR(req.ObjectAndRelation) + "@" + tuple.StringONR(req.Subject)
+}
@@ -66,6 +66,11 @@ func (ce *ConcurrentExpander) expandDirect(
 	log.Ctx(ctx).Trace().Object("direct", req).Send()
 	return func(ctx context.Context, resultChan chan<- ExpandResult) {
 		ds := datastoremw.MustFromContext(ctx).SnapshotReader(req.Revision)
+		if err := dispatch.ConsumeDatastoreQuery(ctx); err != nil {
+			resultChan <- expandResultError(err, emptyMetadata)
+			return
+		}
+
 		it, err := ds.QueryRelationships(ctx, &v1_proto.RelationshipFilter{
 			ResourceType:       req.ObjectAndRelation.Namespace,
 			OptionalResourceId: req.ObjectAndRelation.ObjectId,
@@ -242,6 +247,11 @@ func (ce *ConcurrentExpander) expandComputedUserset(ctx context.Context, req Val
 func (ce *ConcurrentExpander) expandTupleToUserset(ctx context.Context, req ValidatedExpandRequest, ttu *core.TupleToUserset) ReduceableExpandFunc {
 	return func(ctx context.Context, resultChan chan<- ExpandResult) {
 		ds := datastoremw.MustFromContext(ctx).SnapshotReader(req.Revision)
+		if err := dispatch.ConsumeDatastoreQuery(ctx); err != nil {
+			resultChan <- expandResultError(err, emptyMetadata)
+			return
+		}
+
 		it, err := ds.QueryRelationships(ctx, &v1_proto.RelationshipFilter{
 			ResourceType:       req.ObjectAndRelation.Namespace,
 			OptionalResourceId: req.ObjectAndRelation.ObjectId,
@@ -298,7 +308,7 @@ func expandSetOperation(
 		return setResult(op, start, children, emptyMetadata)
 	}
 
-	childCtx, cancelFn := context.WithCancel(ctx)
+	childCtx, cancelFn := context.WithCancel(dispatch.ContextWithFanOut(ctx, uint32(len(requests))))
 	defer cancelFn()
 
 	resultChans := make([]chan ExpandResult, 0, len(requests))
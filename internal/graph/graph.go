@@ -76,6 +76,7 @@ func combineResponseMetadata(existing *v1.ResponseMeta, responseMetadata *v1.Res
 		DispatchCount:       existing.DispatchCount + responseMetadata.DispatchCount,
 		DepthRequired:       max(existing.DepthRequired, responseMetadata.DepthRequired),
 		CachedDispatchCount: existing.CachedDispatchCount + responseMetadata.CachedDispatchCount,
+		ReadRelations:       mergeReadRelations(existing.ReadRelations, responseMetadata.ReadRelations),
 	}
 }
 
@@ -88,6 +89,7 @@ func ensureMetadata(subProblemMetadata *v1.ResponseMeta) *v1.ResponseMeta {
 		DispatchCount:       subProblemMetadata.DispatchCount,
 		DepthRequired:       subProblemMetadata.DepthRequired,
 		CachedDispatchCount: subProblemMetadata.CachedDispatchCount,
+		ReadRelations:       subProblemMetadata.ReadRelations,
 	}
 }
 
@@ -97,5 +99,6 @@ func addCallToResponseMetadata(metadata *v1.ResponseMeta) *v1.ResponseMeta {
 		DispatchCount:       metadata.DispatchCount + 1,
 		DepthRequired:       metadata.DepthRequired + 1,
 		CachedDispatchCount: metadata.CachedDispatchCount,
+		ReadRelations:       metadata.ReadRelations,
 	}
 }
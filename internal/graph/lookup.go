@@ -11,6 +11,7 @@ import (
 	core "github.com/authzed/spicedb/pkg/proto/core/v1"
 
 	"github.com/authzed/spicedb/internal/dispatch"
+	datastoremw "github.com/authzed/spicedb/internal/middleware/datastore"
 	v1 "github.com/authzed/spicedb/pkg/proto/dispatch/v1"
 	"github.com/authzed/spicedb/pkg/tuple"
 )
@@ -101,6 +102,11 @@ func (cl *ConcurrentLookup) LookupViaReachability(ctx context.Context, req Valid
 		Metadata:       req.Metadata,
 	}, stream)
 	if err != nil {
+		if errors.As(err, &dispatch.ErrBudgetExhausted{}) {
+			resp := lookupResultError(err, emptyMetadata)
+			return resp.Resp, resp.Err
+		}
+
 		resp := lookupResultError(NewErrInvalidArgument(fmt.Errorf("error in reachablility: %w", err)), emptyMetadata)
 		return resp.Resp, resp.Err
 	}
@@ -112,10 +118,29 @@ func (cl *ConcurrentLookup) LookupViaReachability(ctx context.Context, req Valid
 		return resp.Resp, resp.Err
 	}
 
+	// The result depends upon every relation through which resources may be reachable, along
+	// with those read to check the resources found. If any check did not report the relations it
+	// read, none are reported for the lookup.
+	var readRelations []*core.RelationReference
+	if checkedRelations, ok := checker.ReadRelations(); ok {
+		reachableRelations, err := ReachableResourcesReadRelations(
+			ctx,
+			datastoremw.MustFromContext(ctx).SnapshotReader(req.Revision),
+			req.ObjectRelation,
+			&core.RelationReference{Namespace: req.Subject.Namespace, Relation: req.Subject.Relation},
+		)
+		if err != nil {
+			resp := lookupResultError(err, emptyMetadata)
+			return resp.Resp, resp.Err
+		}
+		readRelations = mergeReadRelations(reachableRelations, checkedRelations)
+	}
+
 	res := lookupResult(limitedSlice(allowed.AsSlice(), req.Limit), &v1.ResponseMeta{
 		DispatchCount:       stream.dispatchCount + checker.DispatchCount() + 1, // +1 for the lookup
 		CachedDispatchCount: stream.cachedDispatchCount + checker.CachedDispatchCount(),
 		DepthRequired:       max(stream.depthRequired, checker.DepthRequired()) + 1, // +1 for the lookup
+		ReadRelations:       readRelations,
 	})
 	return res.Resp, res.Err
 }
@@ -30,15 +30,17 @@ type ParallelChecker struct {
 	dispatchCount       uint32
 	cachedDispatchCount uint32
 	depthRequired       uint32
+	readRelations       []*core.RelationReference
+	unreportedReads     bool
 
 	mu sync.Mutex
 }
 
 // NewParallelChecker creates a new parallel checker, for a given subject.
 func NewParallelChecker(ctx context.Context, c dispatch.Check, subject *core.ObjectAndRelation, maxConcurrent uint8) *ParallelChecker {
-	g, checkCtx := errgroup.WithContext(ctx)
+	g, checkCtx := errgroup.WithContext(dispatch.ContextWithFanOut(ctx, uint32(maxConcurrent)))
 	toCheck := make(chan *v1.DispatchCheckRequest)
-	return &ParallelChecker{toCheck, tuple.NewONRSet(), c, g, checkCtx, subject, maxConcurrent, tuple.NewONRSet(), 0, 0, 0, sync.Mutex{}}
+	return &ParallelChecker{toCheck, tuple.NewONRSet(), c, g, checkCtx, subject, maxConcurrent, tuple.NewONRSet(), 0, 0, 0, nil, false, sync.Mutex{}}
 }
 
 // AddResult adds a result that has been already checked to the set.
@@ -63,6 +65,12 @@ func (pc *ParallelChecker) DepthRequired() uint32 {
 	return pc.depthRequired
 }
 
+// ReadRelations returns the relations read by the checks, and whether every check reported the
+// relations it read.
+func (pc *ParallelChecker) ReadRelations() ([]*core.RelationReference, bool) {
+	return pc.readRelations, !pc.unreportedReads
+}
+
 func (pc *ParallelChecker) addResultsUnsafe(resource *core.ObjectAndRelation) {
 	pc.results.Add(resource)
 }
@@ -71,6 +79,10 @@ func (pc *ParallelChecker) updateStatsUnsafe(metadata *v1.ResponseMeta) {
 	pc.dispatchCount += metadata.DispatchCount
 	pc.cachedDispatchCount += metadata.CachedDispatchCount
 	pc.depthRequired = max(pc.depthRequired, metadata.DepthRequired)
+	if len(metadata.ReadRelations) == 0 {
+		pc.unreportedReads = true
+	}
+	pc.readRelations = mergeReadRelations(pc.readRelations, metadata.ReadRelations)
 }
 
 // QueueCheck queues a resource to be checked.
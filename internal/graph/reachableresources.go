@@ -77,7 +77,9 @@ func (crr *ConcurrentReachableResources) ReachableResources(
 	cancelCtx, checkCancel := context.WithCancel(ctx)
 	defer checkCancel()
 
-	g, subCtx := errgroup.WithContext(cancelCtx)
+	// The number of subproblems is not known until their relationships have been read, so the
+	// budget is not split evenly between them.
+	g, subCtx := errgroup.WithContext(dispatch.ContextWithFanOut(cancelCtx, 0))
 
 	// For each entrypoint, load the necessary data and re-dispatch if a subproblem was found.
 	for _, entrypoint := range entrypoints {
@@ -141,6 +143,10 @@ func (crr *ConcurrentReachableResources) ReachableResources(
 					continue
 				}
 
+				if err := dispatch.ConsumeDatastoreQuery(ctx); err != nil {
+					return err
+				}
+
 				it, err := reader.ReverseQueryRelationships(
 					ctx,
 					tuple.UsersetToSubjectFilter(&core.ObjectAndRelation{
@@ -219,6 +225,10 @@ func (crr *ConcurrentReachableResources) lookupRelationEntrypoint(ctx context.Co
 	// TODO(jschorr): Combine these into a single query once the datastore supports a direct or wildcard
 	// query option (which should also be used for check).
 	collectResults := func(objectId string) error {
+		if err := dispatch.ConsumeDatastoreQuery(ctx); err != nil {
+			return err
+		}
+
 		it, err := reader.ReverseQueryRelationships(
 			ctx,
 			tuple.UsersetToSubjectFilter(&core.ObjectAndRelation{
@@ -0,0 +1,145 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/authzed/spicedb/internal/namespace"
+	"github.com/authzed/spicedb/pkg/datastore"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+)
+
+// readRelationsRecorder collects the relations read while resolving a single dispatched
+// request, so that they can be reported in its response metadata.
+type readRelationsRecorder struct {
+	sync.Mutex
+	relations []*core.RelationReference
+}
+
+type readRelationsRecorderKey struct{}
+
+func contextWithReadRelationsRecorder(ctx context.Context) (context.Context, *readRelationsRecorder) {
+	recorder := &readRelationsRecorder{}
+	return context.WithValue(ctx, readRelationsRecorderKey{}, recorder), recorder
+}
+
+// recordReadRelation records that the given relation was read by the request being resolved
+// under the context, if any.
+func recordReadRelation(ctx context.Context, namespace string, relation string) {
+	recorder, ok := ctx.Value(readRelationsRecorderKey{}).(*readRelationsRecorder)
+	if !ok {
+		return
+	}
+
+	recorder.Lock()
+	defer recorder.Unlock()
+	recorder.relations = mergeReadRelations(recorder.relations, []*core.RelationReference{{
+		Namespace: namespace,
+		Relation:  relation,
+	}})
+}
+
+func (rrr *readRelationsRecorder) recorded() []*core.RelationReference {
+	rrr.Lock()
+	defer rrr.Unlock()
+	return rrr.relations
+}
+
+// mergeReadRelations returns the union of the two sets of read relations. The existing slice
+// is never modified, as it may be shared with other response metadata.
+func mergeReadRelations(existing []*core.RelationReference, additional []*core.RelationReference) []*core.RelationReference {
+	if len(additional) == 0 {
+		return existing
+	}
+	if len(existing) == 0 {
+		return additional
+	}
+
+	merged := make([]*core.RelationReference, 0, len(existing)+len(additional))
+	merged = append(merged, existing...)
+	for _, toAdd := range additional {
+		found := false
+		for _, current := range existing {
+			if current.Namespace == toAdd.Namespace && current.Relation == toAdd.Relation {
+				found = true
+				break
+			}
+		}
+		if !found {
+			merged = append(merged, toAdd)
+		}
+	}
+	return merged
+}
+
+// ReachableResourcesReadRelations returns every relation which may be read while finding the
+// resources of the resource relation reachable from subjects of the subject relation, including
+// by any of the subproblems dispatched to do so. As a subproblem may find no resources, and so
+// report no metadata, the relations are determined from the schema rather than recorded.
+func ReachableResourcesReadRelations(
+	ctx context.Context,
+	reader datastore.Reader,
+	resourceRelation *core.RelationReference,
+	subjectRelation *core.RelationReference,
+) ([]*core.RelationReference, error) {
+	_, typeSystem, err := namespace.ReadNamespaceAndTypes(ctx, resourceRelation.Namespace, reader)
+	if err != nil {
+		return nil, err
+	}
+	rg := namespace.ReachabilityGraphFor(typeSystem.AsValidated())
+
+	read := []*core.RelationReference{resourceRelation}
+	toVisit := []*core.RelationReference{subjectRelation}
+	visited := map[string]struct{}{}
+	for len(toVisit) > 0 {
+		current := toVisit[0]
+		toVisit = toVisit[1:]
+
+		key := current.Namespace + "#" + current.Relation
+		if _, ok := visited[key]; ok {
+			continue
+		}
+		visited[key] = struct{}{}
+
+		entrypoints, err := rg.OptimizedEntrypointsForSubjectToResource(ctx, current, resourceRelation)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, entrypoint := range entrypoints {
+			switch entrypoint.EntrypointKind() {
+			case core.ReachabilityEntrypoint_RELATION_ENTRYPOINT:
+				directRelation := entrypoint.DirectRelation()
+				read = mergeReadRelations(read, []*core.RelationReference{directRelation})
+				toVisit = append(toVisit, directRelation)
+
+			case core.ReachabilityEntrypoint_COMPUTED_USERSET_ENTRYPOINT:
+				toVisit = append(toVisit, entrypoint.ContainingRelationOrPermission())
+
+			case core.ReachabilityEntrypoint_TUPLESET_TO_USERSET_ENTRYPOINT:
+				containingRelation := entrypoint.ContainingRelationOrPermission()
+				nsDef, _, err := reader.ReadNamespace(ctx, containingRelation.Namespace)
+				if err != nil {
+					return nil, err
+				}
+
+				ttu := entrypoint.TupleToUserset(nsDef)
+				if ttu == nil {
+					return nil, fmt.Errorf("found nil ttu for TTU entrypoint")
+				}
+
+				read = mergeReadRelations(read, []*core.RelationReference{{
+					Namespace: containingRelation.Namespace,
+					Relation:  ttu.Tupleset.Relation,
+				}})
+				toVisit = append(toVisit, containingRelation)
+
+			default:
+				return nil, fmt.Errorf("unknown kind of entrypoint: %v", entrypoint.EntrypointKind())
+			}
+		}
+	}
+
+	return read, nil
+}
@@ -0,0 +1,31 @@
+package dispatchbudget
+
+import (
+	"context"
+
+	middleware "github.com/grpc-ecosystem/go-grpc-middleware/v2"
+	"google.golang.org/grpc"
+
+	"github.com/authzed/spicedb/internal/dispatch"
+)
+
+// UnaryServerInterceptor returns a new unary server interceptor that adds a fresh dispatch
+// budget with the given limits to the context of each request. A limit of zero indicates
+// no limit.
+func UnaryServerInterceptor(maxDispatches uint32, maxDatastoreQueries uint32) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		newCtx := dispatch.ContextWithBudget(ctx, dispatch.NewBudget(maxDispatches, maxDatastoreQueries))
+		return handler(newCtx, req)
+	}
+}
+
+// StreamServerInterceptor returns a new stream server interceptor that adds a fresh dispatch
+// budget with the given limits to the context of each request. A limit of zero indicates
+// no limit.
+func StreamServerInterceptor(maxDispatches uint32, maxDatastoreQueries uint32) grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		wrapped := middleware.WrapServerStream(stream)
+		wrapped.WrappedContext = dispatch.ContextWithBudget(wrapped.WrappedContext, dispatch.NewBudget(maxDispatches, maxDatastoreQueries))
+		return handler(srv, wrapped)
+	}
+}
@@ -12,7 +12,8 @@ import (
 	"github.com/rs/zerolog/log"
 	"google.golang.org/grpc"
 
-	dispatch "github.com/authzed/spicedb/pkg/proto/dispatch/v1"
+	"github.com/authzed/spicedb/internal/dispatch"
+	dispatchv1 "github.com/authzed/spicedb/pkg/proto/dispatch/v1"
 )
 
 var (
@@ -30,6 +31,21 @@ var (
 		Help:      "Histogram of cluster dispatches performed by the instance.",
 		Buckets:   []float64{1, 5, 10, 25, 50, 100, 250},
 	}, DispatchedCountLabels)
+
+	// DispatchBudgetUsageLabels are the labels that DispatchBudgetUsageHistogram will
+	// have by default.
+	DispatchBudgetUsageLabels = []string{"method", "resource"}
+
+	// DispatchBudgetUsageHistogram is the metric that SpiceDB uses to keep track
+	// of the dispatches and datastore queries counted against the per-request
+	// dispatch budget of a single query.
+	DispatchBudgetUsageHistogram = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "spicedb",
+		Subsystem: "services",
+		Name:      "dispatch_budget_usage",
+		Help:      "Histogram of dispatches and datastore queries counted against the per-request budget.",
+		Buckets:   []float64{1, 5, 10, 25, 50, 100, 250, 1000, 10000},
+	}, DispatchBudgetUsageLabels)
 )
 
 type reporter struct{}
@@ -49,13 +65,17 @@ type serverReporter struct {
 func (r *serverReporter) PostCall(_ error, _ time.Duration) {
 	responseMeta := FromContext(r.ctx)
 	if responseMeta == nil {
-		responseMeta = &dispatch.ResponseMeta{}
+		responseMeta = &dispatchv1.ResponseMeta{}
 	}
 
 	err := annotateAndReportForMetadata(r.ctx, r.methodName, responseMeta)
 	if err != nil {
 		log.Ctx(r.ctx).Err(err).Msg("could not report metadata")
 	}
+
+	if budget := dispatch.BudgetFromContext(r.ctx); budget != nil {
+		reportBudgetUsage(r.methodName, budget)
+	}
 }
 
 // UnaryServerInterceptor implements a gRPC Middleware for reporting usage metrics
@@ -72,7 +92,7 @@ func StreamServerInterceptor() grpc.StreamServerInterceptor {
 	return interceptors.StreamServerInterceptor(&reporter{})
 }
 
-func annotateAndReportForMetadata(ctx context.Context, methodName string, metadata *dispatch.ResponseMeta) error {
+func annotateAndReportForMetadata(ctx context.Context, methodName string, metadata *dispatchv1.ResponseMeta) error {
 	DispatchedCountHistogram.WithLabelValues(methodName, "false").Observe(float64(metadata.DispatchCount))
 	DispatchedCountHistogram.WithLabelValues(methodName, "true").Observe(float64(metadata.CachedDispatchCount))
 
@@ -82,16 +102,21 @@ func annotateAndReportForMetadata(ctx context.Context, methodName string, metada
 	})
 }
 
+func reportBudgetUsage(methodName string, budget *dispatch.Budget) {
+	DispatchBudgetUsageHistogram.WithLabelValues(methodName, "dispatches").Observe(float64(budget.DispatchesUsed()))
+	DispatchBudgetUsageHistogram.WithLabelValues(methodName, "datastore_queries").Observe(float64(budget.DatastoreQueriesUsed()))
+}
+
 // Create a new type to prevent context collisions
 type responseMetaKey string
 
 var metadataCtxKey responseMetaKey = "dispatched-response-meta"
 
-type metaHandle struct{ metadata *dispatch.ResponseMeta }
+type metaHandle struct{ metadata *dispatchv1.ResponseMeta }
 
 // SetInContext should be called in a gRPC handler to correctly set the response metadata
 // for the dispatched request.
-func SetInContext(ctx context.Context, metadata *dispatch.ResponseMeta) {
+func SetInContext(ctx context.Context, metadata *dispatchv1.ResponseMeta) {
 	possibleHandle := ctx.Value(metadataCtxKey)
 	if possibleHandle == nil {
 		return
@@ -104,7 +129,7 @@ func SetInContext(ctx context.Context, metadata *dispatch.ResponseMeta) {
 // FromContext returns any metadata that was stored in the context.
 //
 // This is useful for testing that a handler is properly setting the context.
-func FromContext(ctx context.Context) *dispatch.ResponseMeta {
+func FromContext(ctx context.Context) *dispatchv1.ResponseMeta {
 	possibleHandle := ctx.Value(metadataCtxKey)
 	if possibleHandle == nil {
 		return nil
@@ -0,0 +1,108 @@
+//go:build ci
+// +build ci
+
+package services_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/spicedb/internal/testserver"
+	testdatastore "github.com/authzed/spicedb/internal/testserver/datastore"
+	"github.com/authzed/spicedb/internal/testserver/datastore/config"
+	dsconfig "github.com/authzed/spicedb/pkg/cmd/datastore"
+	"github.com/authzed/spicedb/pkg/datastore"
+	experimentalv1 "github.com/authzed/spicedb/pkg/proto/experimental/v1"
+)
+
+func TestBulkImportIntegration(t *testing.T) {
+	const (
+		batchCount = 5
+		batchSize  = 100
+	)
+
+	for _, engine := range datastore.Engines {
+		b := testdatastore.RunDatastoreEngine(t, engine)
+		t.Run(engine, func(t *testing.T) {
+			require := require.New(t)
+
+			ds := b.NewDatastore(t, config.DatastoreConfigInitFunc(t,
+				dsconfig.WithWatchBufferLength(0),
+				dsconfig.WithGCWindow(time.Duration(90_000_000_000_000)),
+				dsconfig.WithRevisionQuantization(10)))
+
+			conns, cleanup := testserver.TestClusterWithDispatch(t, 1, ds)
+			t.Cleanup(cleanup)
+
+			zerolog.SetGlobalLevel(zerolog.Disabled)
+
+			_, err := v1.NewSchemaServiceClient(conns[0]).WriteSchema(context.Background(), &v1.WriteSchemaRequest{
+				Schema: `definition user {}
+
+				definition document {
+					relation viewer: user
+					permission view = viewer
+				}`,
+			})
+			require.NoError(err)
+
+			client := experimentalv1.NewExperimentalServiceClient(conns[0])
+
+			// Every relationship is validated against the schema while it is being loaded,
+			// which must not interfere with the load itself.
+			stream, err := client.BulkImportRelationships(context.Background())
+			require.NoError(err)
+			for i := 0; i < batchCount; i++ {
+				batch := make([]*v1.Relationship, 0, batchSize)
+				for j := 0; j < batchSize; j++ {
+					batch = append(batch, &v1.Relationship{
+						Resource: &v1.ObjectReference{ObjectType: "document", ObjectId: fmt.Sprintf("doc%d", i*batchSize+j)},
+						Relation: "viewer",
+						Subject: &v1.SubjectReference{
+							Object: &v1.ObjectReference{ObjectType: "user", ObjectId: "tom"},
+						},
+					})
+				}
+				require.NoError(stream.Send(&experimentalv1.BulkImportRelationshipsRequest{Relationships: batch}))
+			}
+
+			resp, err := stream.CloseAndRecv()
+			require.NoError(err)
+			require.Equal(uint64(batchCount*batchSize), resp.NumLoaded)
+
+			checked, err := v1.NewPermissionsServiceClient(conns[0]).CheckPermission(context.Background(), &v1.CheckPermissionRequest{
+				Consistency: &v1.Consistency{
+					Requirement: &v1.Consistency_AtLeastAsFresh{AtLeastAsFresh: resp.WrittenAt},
+				},
+				Resource:   &v1.ObjectReference{ObjectType: "document", ObjectId: fmt.Sprintf("doc%d", batchCount*batchSize-1)},
+				Permission: "view",
+				Subject: &v1.SubjectReference{
+					Object: &v1.ObjectReference{ObjectType: "user", ObjectId: "tom"},
+				},
+			})
+			require.NoError(err)
+			require.Equal(v1.CheckPermissionResponse_PERMISSIONSHIP_HAS_PERMISSION, checked.Permissionship)
+
+			// A relationship which fails validation fails the whole import.
+			stream, err = client.BulkImportRelationships(context.Background())
+			require.NoError(err)
+			require.NoError(stream.Send(&experimentalv1.BulkImportRelationshipsRequest{
+				Relationships: []*v1.Relationship{{
+					Resource: &v1.ObjectReference{ObjectType: "document", ObjectId: "invalid"},
+					Relation: "view",
+					Subject: &v1.SubjectReference{
+						Object: &v1.ObjectReference{ObjectType: "user", ObjectId: "tom"},
+					},
+				}},
+			}))
+			_, err = stream.CloseAndRecv()
+			require.Error(err)
+		})
+	}
+}
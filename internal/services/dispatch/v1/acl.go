@@ -34,17 +34,17 @@ func NewDispatchServer(localDispatch dispatch.Dispatcher) dispatchv1.DispatchSer
 }
 
 func (ds *dispatchServer) DispatchCheck(ctx context.Context, req *dispatchv1.DispatchCheckRequest) (*dispatchv1.DispatchCheckResponse, error) {
-	resp, err := ds.localDispatch.DispatchCheck(ctx, req)
+	resp, err := ds.check(ctx, req)
 	return resp, rewriteGraphError(ctx, err)
 }
 
 func (ds *dispatchServer) DispatchExpand(ctx context.Context, req *dispatchv1.DispatchExpandRequest) (*dispatchv1.DispatchExpandResponse, error) {
-	resp, err := ds.localDispatch.DispatchExpand(ctx, req)
+	resp, err := ds.expand(ctx, req)
 	return resp, rewriteGraphError(ctx, err)
 }
 
 func (ds *dispatchServer) DispatchLookup(ctx context.Context, req *dispatchv1.DispatchLookupRequest) (*dispatchv1.DispatchLookupResponse, error) {
-	resp, err := ds.localDispatch.DispatchLookup(ctx, req)
+	resp, err := ds.lookup(ctx, req)
 	return resp, rewriteGraphError(ctx, err)
 }
 
@@ -52,8 +52,75 @@ func (ds *dispatchServer) DispatchReachableResources(
 	req *dispatchv1.DispatchReachableResourcesRequest,
 	resp dispatchv1.DispatchService_DispatchReachableResourcesServer,
 ) error {
-	return ds.localDispatch.DispatchReachableResources(req,
-		dispatch.WrapGRPCStream[*dispatchv1.DispatchReachableResourcesResponse](resp))
+	stream := dispatch.WrapGRPCStream[*dispatchv1.DispatchReachableResourcesResponse](resp)
+	err := ds.reachableResources(req, dispatch.StreamWithContext(resp.Context(), stream))
+	return rewriteGraphError(resp.Context(), err)
+}
+
+// check evaluates a check request within the budget sent by the dispatching node, reporting
+// the budget consumed in the response.
+func (ds *dispatchServer) check(ctx context.Context, req *dispatchv1.DispatchCheckRequest) (*dispatchv1.DispatchCheckResponse, error) {
+	budget := dispatch.NewBudgetFromMetadata(req.Metadata)
+	resp, err := ds.localDispatch.DispatchCheck(dispatch.ContextWithBudget(ctx, budget), req)
+	if err != nil {
+		return resp, err
+	}
+
+	return &dispatchv1.DispatchCheckResponse{
+		Metadata:   budget.Consumed(resp.Metadata),
+		Membership: resp.Membership,
+	}, nil
+}
+
+// expand evaluates an expand request within the budget sent by the dispatching node, reporting
+// the budget consumed in the response.
+func (ds *dispatchServer) expand(ctx context.Context, req *dispatchv1.DispatchExpandRequest) (*dispatchv1.DispatchExpandResponse, error) {
+	budget := dispatch.NewBudgetFromMetadata(req.Metadata)
+	resp, err := ds.localDispatch.DispatchExpand(dispatch.ContextWithBudget(ctx, budget), req)
+	if err != nil {
+		return resp, err
+	}
+
+	return &dispatchv1.DispatchExpandResponse{
+		Metadata: budget.Consumed(resp.Metadata),
+		TreeNode: resp.TreeNode,
+	}, nil
+}
+
+// lookup evaluates a lookup request within the budget sent by the dispatching node, reporting
+// the budget consumed in the response.
+func (ds *dispatchServer) lookup(ctx context.Context, req *dispatchv1.DispatchLookupRequest) (*dispatchv1.DispatchLookupResponse, error) {
+	budget := dispatch.NewBudgetFromMetadata(req.Metadata)
+	resp, err := ds.localDispatch.DispatchLookup(dispatch.ContextWithBudget(ctx, budget), req)
+	if err != nil {
+		return resp, err
+	}
+
+	return &dispatchv1.DispatchLookupResponse{
+		Metadata:          budget.Consumed(resp.Metadata),
+		ResolvedOnrs:      resp.ResolvedOnrs,
+		NextPageReference: resp.NextPageReference,
+	}, nil
+}
+
+// reachableResources evaluates a reachable resources request within the budget sent by the
+// dispatching node. Each result reports the budget consumed since the previous result was
+// published; work performed after the final result is not reported.
+func (ds *dispatchServer) reachableResources(
+	req *dispatchv1.DispatchReachableResourcesRequest,
+	stream dispatch.ReachableResourcesStream,
+) error {
+	budget := dispatch.NewBudgetFromMetadata(req.Metadata)
+	return ds.localDispatch.DispatchReachableResources(req, &dispatch.WrappedDispatchStream[*dispatchv1.DispatchReachableResourcesResponse]{
+		Stream: stream,
+		Ctx:    dispatch.ContextWithBudget(stream.Context(), budget),
+		Processor: func(result *dispatchv1.DispatchReachableResourcesResponse) (*dispatchv1.DispatchReachableResourcesResponse, error) {
+			return &dispatchv1.DispatchReachableResourcesResponse{
+				Resource: result.Resource,
+				Metadata: budget.Consumed(result.Metadata),
+			}, nil
+		},
+	})
 }
 
 func (ds *dispatchServer) Close() error {
@@ -68,6 +135,11 @@ func rewriteGraphError(ctx context.Context, err error) error {
 	case err == nil:
 		return nil
 
+	case errors.As(err, &dispatch.ErrBudgetExhausted{}):
+		var budgetErr dispatch.ErrBudgetExhausted
+		errors.As(err, &budgetErr)
+		return dispatch.BudgetExhaustedStatus(budgetErr)
+
 	case errors.As(err, &graph.ErrAlwaysFail{}):
 		fallthrough
 	default:
@@ -0,0 +1,265 @@
+package dispatch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"golang.org/x/sync/semaphore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/authzed/spicedb/internal/dispatch"
+	dispatchv1 "github.com/authzed/spicedb/pkg/proto/dispatch/v1"
+)
+
+const (
+	// maxConcurrentStreamRequests is the maximum number of requests evaluated concurrently
+	// for a single DispatchStream. Further requests wait for one to complete before they are
+	// evaluated, while the stream continues to be read for cancellations and credit.
+	maxConcurrentStreamRequests = 1024
+
+	// maxStreamWindow is the maximum number of reachable resources which may be sent for a
+	// single request before further credit is granted.
+	maxStreamWindow = 1024
+)
+
+// DispatchStream evaluates the requests multiplexed over the stream concurrently, sending
+// each response as it completes.
+func (ds *dispatchServer) DispatchStream(stream dispatchv1.DispatchService_DispatchStreamServer) error {
+	ctx, cancel := context.WithCancel(stream.Context())
+	mux := &streamMux{
+		stream:  stream,
+		active:  map[uint64]*streamRequest{},
+		slots:   semaphore.NewWeighted(maxConcurrentStreamRequests),
+		handled: &sync.WaitGroup{},
+	}
+	defer func() {
+		// Requests still in flight once the stream ends are canceled, as their responses can
+		// no longer be sent.
+		cancel()
+		mux.handled.Wait()
+	}()
+
+	for {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch message := req.Message.(type) {
+		case *dispatchv1.DispatchStreamRequest_Cancel:
+			mux.cancel(req.Id)
+
+		case *dispatchv1.DispatchStreamRequest_Credit:
+			mux.credit(req.Id, message.Credit.Count)
+
+		default:
+			active, err := mux.start(ctx, req)
+			if err != nil {
+				return err
+			}
+
+			mux.handled.Add(1)
+			go func() {
+				defer mux.handled.Done()
+				defer mux.finish(req.Id)
+
+				var resp *dispatchv1.DispatchStreamResponse
+				if err := mux.slots.Acquire(active.ctx, 1); err != nil {
+					resp = streamErrorResponse(active.ctx, err)
+				} else {
+					resp = ds.handleStreamRequest(active, req)
+					mux.slots.Release(1)
+				}
+
+				resp.Id = req.Id
+				if err := mux.send(resp); err != nil {
+					cancel()
+				}
+			}()
+		}
+	}
+}
+
+// handleStreamRequest evaluates a single request received on a DispatchStream, returning its
+// final response.
+func (ds *dispatchServer) handleStreamRequest(active *streamRequest, req *dispatchv1.DispatchStreamRequest) *dispatchv1.DispatchStreamResponse {
+	var err error
+	var resp *dispatchv1.DispatchStreamResponse
+
+	switch message := req.Message.(type) {
+	case *dispatchv1.DispatchStreamRequest_Check:
+		var checkResp *dispatchv1.DispatchCheckResponse
+		checkResp, err = ds.check(active.ctx, message.Check)
+		resp = &dispatchv1.DispatchStreamResponse{Message: &dispatchv1.DispatchStreamResponse_Check{Check: checkResp}}
+
+	case *dispatchv1.DispatchStreamRequest_Expand:
+		var expandResp *dispatchv1.DispatchExpandResponse
+		expandResp, err = ds.expand(active.ctx, message.Expand)
+		resp = &dispatchv1.DispatchStreamResponse{Message: &dispatchv1.DispatchStreamResponse_Expand{Expand: expandResp}}
+
+	case *dispatchv1.DispatchStreamRequest_Lookup:
+		var lookupResp *dispatchv1.DispatchLookupResponse
+		lookupResp, err = ds.lookup(active.ctx, message.Lookup)
+		resp = &dispatchv1.DispatchStreamResponse{Message: &dispatchv1.DispatchStreamResponse_Lookup{Lookup: lookupResp}}
+
+	case *dispatchv1.DispatchStreamRequest_ReachableResources:
+		err = ds.reachableResources(message.ReachableResources, dispatch.NewHandlingDispatchStream(active.ctx, func(result *dispatchv1.DispatchReachableResourcesResponse) error {
+			if err := active.awaitCredit(active.ctx); err != nil {
+				return err
+			}
+			return active.mux.send(&dispatchv1.DispatchStreamResponse{
+				Id:      req.Id,
+				Message: &dispatchv1.DispatchStreamResponse_ReachableResources{ReachableResources: result},
+			})
+		}))
+		resp = &dispatchv1.DispatchStreamResponse{Message: &dispatchv1.DispatchStreamResponse_Complete{Complete: &dispatchv1.DispatchStreamComplete{}}}
+
+	default:
+		err = status.Errorf(codes.InvalidArgument, "unknown dispatch stream message %T", req.Message)
+	}
+
+	if err != nil {
+		return streamErrorResponse(active.ctx, err)
+	}
+
+	return resp
+}
+
+// streamErrorResponse returns the final response for a request received on a DispatchStream
+// which failed with the given error.
+func streamErrorResponse(ctx context.Context, err error) *dispatchv1.DispatchStreamResponse {
+	switch {
+	case errors.Is(err, context.Canceled):
+		err = status.Errorf(codes.Canceled, "request canceled: %s", err)
+	case errors.Is(err, context.DeadlineExceeded):
+		err = status.Errorf(codes.DeadlineExceeded, "request deadline exceeded: %s", err)
+	}
+
+	s, _ := status.FromError(rewriteGraphError(ctx, err))
+	return &dispatchv1.DispatchStreamResponse{
+		Message: &dispatchv1.DispatchStreamResponse_Error{
+			Error: &dispatchv1.DispatchStreamError{
+				Code:    uint32(s.Code()),
+				Message: s.Message(),
+				Details: s.Proto().Details,
+			},
+		},
+	}
+}
+
+// streamMux tracks the requests in flight on a single DispatchStream.
+type streamMux struct {
+	stream  dispatchv1.DispatchService_DispatchStreamServer
+	slots   *semaphore.Weighted
+	handled *sync.WaitGroup
+
+	sendLock sync.Mutex
+
+	sync.Mutex
+	active map[uint64]*streamRequest
+}
+
+// streamRequest is a request in flight on a DispatchStream.
+type streamRequest struct {
+	mux     *streamMux
+	ctx     context.Context
+	cancel  context.CancelFunc
+	credits chan struct{}
+}
+
+func (sm *streamMux) start(ctx context.Context, req *dispatchv1.DispatchStreamRequest) (*streamRequest, error) {
+	sm.Lock()
+	defer sm.Unlock()
+
+	if _, ok := sm.active[req.Id]; ok {
+		return nil, status.Errorf(codes.InvalidArgument, "duplicate dispatch stream request id %d", req.Id)
+	}
+
+	window := req.Window
+	if window == 0 || window > maxStreamWindow {
+		window = maxStreamWindow
+	}
+
+	// The sender's deadline for the request is carried by the request itself, as the stream is
+	// shared by requests with different deadlines.
+	reqCtx, cancel := context.WithCancel(ctx)
+	if req.Timeout != nil {
+		reqCtx, cancel = context.WithTimeout(ctx, req.Timeout.AsDuration())
+	}
+
+	active := &streamRequest{
+		mux:     sm,
+		ctx:     reqCtx,
+		cancel:  cancel,
+		credits: make(chan struct{}, maxStreamWindow),
+	}
+	for i := uint32(0); i < window; i++ {
+		active.credits <- struct{}{}
+	}
+
+	sm.active[req.Id] = active
+	return active, nil
+}
+
+func (sm *streamMux) finish(id uint64) {
+	sm.Lock()
+	defer sm.Unlock()
+
+	if active, ok := sm.active[id]; ok {
+		active.cancel()
+		delete(sm.active, id)
+	}
+}
+
+func (sm *streamMux) cancel(id uint64) {
+	sm.Lock()
+	defer sm.Unlock()
+
+	if active, ok := sm.active[id]; ok {
+		active.cancel()
+	}
+}
+
+func (sm *streamMux) credit(id uint64, count uint32) {
+	sm.Lock()
+	active, ok := sm.active[id]
+	sm.Unlock()
+	if !ok {
+		return
+	}
+
+	for i := uint32(0); i < count; i++ {
+		select {
+		case active.credits <- struct{}{}:
+		default:
+			return
+		}
+	}
+}
+
+func (sm *streamMux) send(resp *dispatchv1.DispatchStreamResponse) error {
+	sm.sendLock.Lock()
+	defer sm.sendLock.Unlock()
+
+	if err := sm.stream.Send(resp); err != nil {
+		return fmt.Errorf("unable to send dispatch stream response: %w", err)
+	}
+	return nil
+}
+
+// awaitCredit blocks until the request may send a further reachable resource.
+func (sr *streamRequest) awaitCredit(ctx context.Context) error {
+	select {
+	case <-sr.credits:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -14,6 +14,7 @@ import (
 	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/require"
 
+	"github.com/authzed/spicedb/internal/datastore/memdb"
 	"github.com/authzed/spicedb/internal/datastore/spanner"
 	tf "github.com/authzed/spicedb/internal/testfixtures"
 	"github.com/authzed/spicedb/internal/testserver"
@@ -73,3 +74,49 @@ func TestBurst(t *testing.T) {
 		})
 	}
 }
+
+func BenchmarkDispatchMultiplexing(b *testing.B) {
+	testCases := []struct {
+		name    string
+		options []testserver.ClusterOption
+	}{
+		{"unary", nil},
+		{"multiplexed", []testserver.ClusterOption{testserver.WithDispatchMultiplexing()}},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		b.Run(tc.name, func(b *testing.B) {
+			emptyDS, err := memdb.NewMemdbDatastore(0, 0, memdb.DisableGC)
+			require.NoError(b, err)
+			ds, _ := tf.StandardDatastoreWithData(emptyDS, require.New(b))
+
+			conns, cleanup := testserver.TestClusterWithDispatch(b, 3, ds, tc.options...)
+			b.Cleanup(cleanup)
+
+			zerolog.SetGlobalLevel(zerolog.Disabled)
+			client := v1.NewPermissionsServiceClient(conns[0])
+
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				i := 0
+				for pb.Next() {
+					rel := tuple.MustToRelationship(tuple.Parse(tf.StandardTuples[i%(len(tf.StandardTuples))]))
+					i++
+
+					// Fully consistent checks are evaluated at the head revision, which
+					// changes between requests, so that their dispatches are not cached.
+					_, err := client.CheckPermission(context.Background(), &v1.CheckPermissionRequest{
+						Consistency: &v1.Consistency{
+							Requirement: &v1.Consistency_FullyConsistent{FullyConsistent: true},
+						},
+						Resource:   rel.Resource,
+						Permission: "viewer",
+						Subject:    rel.Subject,
+					})
+					require.NoError(b, err)
+				}
+			})
+		})
+	}
+}
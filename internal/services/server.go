@@ -13,6 +13,7 @@ import (
 	v0svc "github.com/authzed/spicedb/internal/services/v0"
 	v1svc "github.com/authzed/spicedb/internal/services/v1"
 	v1alpha1svc "github.com/authzed/spicedb/internal/services/v1alpha1"
+	experimentalv1 "github.com/authzed/spicedb/pkg/proto/experimental/v1"
 )
 
 // SchemaServiceOption defines the options for enabled or disabled the V1 Schema service.
@@ -26,13 +27,27 @@ const (
 	V1SchemaServiceEnabled SchemaServiceOption = 1
 )
 
+// ExperimentalServiceOption defines the options for enabling or disabling the experimental
+// service.
+type ExperimentalServiceOption int
+
+const (
+	// ExperimentalServiceDisabled indicates that the experimental service is disabled.
+	ExperimentalServiceDisabled ExperimentalServiceOption = 0
+
+	// ExperimentalServiceEnabled indicates that the experimental service is enabled.
+	ExperimentalServiceEnabled ExperimentalServiceOption = 1
+)
+
 // RegisterGrpcServices registers all services to be exposed on the GRPC server.
 func RegisterGrpcServices(
 	srv *grpc.Server,
 	dispatch dispatch.Dispatcher,
 	maxDepth uint32,
+	continuationKey []byte,
 	prefixRequired v1alpha1svc.PrefixRequiredOption,
 	schemaServiceOption SchemaServiceOption,
+	experimentalServiceOption ExperimentalServiceOption,
 ) {
 	healthSrv := grpcutil.NewAuthlessHealthServer()
 
@@ -48,7 +63,7 @@ func RegisterGrpcServices(
 	v1alpha1.RegisterSchemaServiceServer(srv, v1alpha1svc.NewSchemaServer(prefixRequired))
 	healthSrv.SetServicesHealthy(&v1alpha1.SchemaService_ServiceDesc)
 
-	v1.RegisterPermissionsServiceServer(srv, v1svc.NewPermissionsServer(dispatch, maxDepth))
+	v1.RegisterPermissionsServiceServer(srv, v1svc.NewPermissionsServer(dispatch, maxDepth, continuationKey))
 	healthSrv.SetServicesHealthy(&v1.PermissionsService_ServiceDesc)
 
 	v1.RegisterWatchServiceServer(srv, v1svc.NewWatchServer())
@@ -59,6 +74,11 @@ func RegisterGrpcServices(
 		healthSrv.SetServicesHealthy(&v1.SchemaService_ServiceDesc)
 	}
 
+	if experimentalServiceOption == ExperimentalServiceEnabled {
+		experimentalv1.RegisterExperimentalServiceServer(srv, v1svc.NewExperimentalServer(dispatch, maxDepth))
+		healthSrv.SetServicesHealthy(&experimentalv1.ExperimentalService_ServiceDesc)
+	}
+
 	healthpb.RegisterHealthServer(srv, healthSrv)
 
 	reflection.Register(grpcutil.NewAuthlessReflectionInterceptor(srv))
@@ -416,6 +416,9 @@ func rewriteACLError(ctx context.Context, err error) error {
 	case errors.As(err, &shared.ErrPreconditionFailed{}):
 		return status.Errorf(codes.FailedPrecondition, "failed precondition: %s", err)
 
+	case errors.As(err, &dispatch.ErrBudgetExhausted{}):
+		return status.Errorf(codes.ResourceExhausted, "%s", err)
+
 	case errors.As(err, &graph.ErrRequestCanceled{}):
 		return status.Errorf(codes.Canceled, "request canceled: %s", err)
 
@@ -0,0 +1,129 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	datastoremw "github.com/authzed/spicedb/internal/middleware/datastore"
+	"github.com/authzed/spicedb/internal/middleware/usagemetrics"
+	"github.com/authzed/spicedb/pkg/datastore"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	dispatchv1 "github.com/authzed/spicedb/pkg/proto/dispatch/v1"
+	experimentalv1 "github.com/authzed/spicedb/pkg/proto/experimental/v1"
+	"github.com/authzed/spicedb/pkg/zedtoken"
+)
+
+var errBulkImportRetried = status.Error(
+	codes.Aborted,
+	"bulk import transaction must be retried, which requires the relationships to be sent again",
+)
+
+// BulkImportRelationships loads the relationships as they are received, validating each
+// against the schema, all within a single transaction. As the relationships are consumed
+// from the stream, a transaction which the datastore would retry is instead aborted.
+//
+// The namespaces are read before the load begins, as a datastore may be unable to serve
+// other reads within the transaction while loading, as is the case for a postgres COPY.
+func (es *experimentalServer) BulkImportRelationships(stream experimentalv1.ExperimentalService_BulkImportRelationshipsServer) error {
+	ctx := stream.Context()
+	ds := datastoremw.MustFromContext(ctx)
+
+	var attempted bool
+	var loaded uint64
+	revision, err := ds.ReadWriteTx(ctx, func(ctx context.Context, rwt datastore.ReadWriteTransaction) error {
+		if attempted {
+			return errBulkImportRetried
+		}
+		attempted = true
+
+		nsDefs, err := rwt.ListNamespaces(ctx)
+		if err != nil {
+			return err
+		}
+
+		loaded, err = datastore.BulkLoad(ctx, rwt, &streamBulkSource{
+			stream:     stream,
+			namespaces: newLoadedNamespacesReader(rwt, nsDefs),
+		})
+		return err
+	})
+	if err != nil {
+		return rewritePermissionsError(ctx, err)
+	}
+
+	usagemetrics.SetInContext(ctx, &dispatchv1.ResponseMeta{
+		DispatchCount: 1,
+	})
+
+	return stream.SendAndClose(&experimentalv1.BulkImportRelationshipsResponse{
+		WrittenAt: zedtoken.NewFromRevision(revision),
+		NumLoaded: loaded,
+	})
+}
+
+// streamBulkSource is a source of the relationships received by a bulk import.
+type streamBulkSource struct {
+	stream     experimentalv1.ExperimentalService_BulkImportRelationshipsServer
+	namespaces datastore.Reader
+
+	received []*v1.Relationship
+}
+
+func (sbs *streamBulkSource) Next(ctx context.Context) (*v1.Relationship, error) {
+	for len(sbs.received) == 0 {
+		req, err := sbs.stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		sbs.received = req.Relationships
+	}
+
+	rel := sbs.received[0]
+	sbs.received = sbs.received[1:]
+
+	if err := validateRelationshipWrite(ctx, rel, sbs.namespaces); err != nil {
+		return nil, err
+	}
+	return rel, nil
+}
+
+// loadedNamespacesReader is a reader which serves the namespace definitions read ahead of
+// time, rather than reading them from the datastore. Any other reads are made by the
+// underlying reader.
+type loadedNamespacesReader struct {
+	datastore.Reader
+	nsDefs map[string]*core.NamespaceDefinition
+}
+
+func newLoadedNamespacesReader(reader datastore.Reader, nsDefs []*core.NamespaceDefinition) loadedNamespacesReader {
+	byName := make(map[string]*core.NamespaceDefinition, len(nsDefs))
+	for _, nsDef := range nsDefs {
+		byName[nsDef.Name] = nsDef
+	}
+	return loadedNamespacesReader{Reader: reader, nsDefs: byName}
+}
+
+func (lnr loadedNamespacesReader) ReadNamespace(ctx context.Context, nsName string) (*core.NamespaceDefinition, datastore.Revision, error) {
+	nsDef, ok := lnr.nsDefs[nsName]
+	if !ok {
+		return nil, datastore.NoRevision, datastore.NewNamespaceNotFoundErr(nsName)
+	}
+	return nsDef, datastore.NoRevision, nil
+}
+
+func (lnr loadedNamespacesReader) ListNamespaces(ctx context.Context) ([]*core.NamespaceDefinition, error) {
+	nsDefs := make([]*core.NamespaceDefinition, 0, len(lnr.nsDefs))
+	for _, nsDef := range lnr.nsDefs {
+		nsDefs = append(nsDefs, nsDef)
+	}
+	return nsDefs, nil
+}
@@ -0,0 +1,294 @@
+package v1
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+
+	"github.com/authzed/authzed-go/pkg/requestmeta"
+	"github.com/authzed/authzed-go/pkg/responsemeta"
+	"github.com/shopspring/decimal"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/authzed/spicedb/internal/graph"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	dispatch "github.com/authzed/spicedb/pkg/proto/dispatch/v1"
+	impl "github.com/authzed/spicedb/pkg/proto/impl/v1"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+const (
+	// ExpandModeRequestKey, if specified in the request header of ExpandPermissionTree, sets the
+	// mode of expansion.
+	// Value: `shallow` (default) or `recursive`
+	ExpandModeRequestKey requestmeta.RequestMetadataHeaderKey = "io.spicedb.expand.mode"
+
+	// ExpandMaxDepthRequestKey, if specified in the request header of a recursive
+	// ExpandPermissionTree, sets the maximum number of subject sets below the expanded
+	// permission which are expanded in turn.
+	// Value: an integer, defaulting to 10
+	ExpandMaxDepthRequestKey requestmeta.RequestMetadataHeaderKey = "io.spicedb.expand.maxdepth"
+
+	// ExpandMaxNodesRequestKey, if specified in the request header of a recursive
+	// ExpandPermissionTree, sets the maximum number of subject sets which are expanded.
+	// Value: an integer, defaulting to 1000
+	ExpandMaxNodesRequestKey requestmeta.RequestMetadataHeaderKey = "io.spicedb.expand.maxnodes"
+
+	// ExpandContinuationRequestKey, if specified in the request header of ExpandPermissionTree,
+	// continues the expansion of a subject set which was truncated by an earlier expansion, at
+	// the revision of that expansion. The resource and permission of the request must be those
+	// of the truncated subject set.
+	// Value: a continuation token from the ExpandContinuationsTrailerKey trailer
+	ExpandContinuationRequestKey requestmeta.RequestMetadataHeaderKey = "io.spicedb.expand.continuation"
+
+	// ExpandContinuationsTrailerKey is the key in the response trailer metadata of a recursive
+	// ExpandPermissionTree for the subject sets which were not expanded because the maximum
+	// depth or number of nodes was reached. Each value holds the subject set, such as
+	// `group:eng#member`, followed by a space and the continuation token with which its
+	// expansion can be continued.
+	ExpandContinuationsTrailerKey responsemeta.ResponseMetadataTrailerKey = "io.spicedb.respmeta.expandcontinuations"
+)
+
+const (
+	expandModeShallow   = "shallow"
+	expandModeRecursive = "recursive"
+
+	defaultExpandMaxDepth = 10
+	defaultExpandMaxNodes = 1000
+	maximumExpandMaxNodes = 10_000
+)
+
+// expandOptions are the options for ExpandPermissionTree given in its request header.
+type expandOptions struct {
+	recursive    bool
+	maxDepth     uint32
+	maxNodes     uint32
+	continuation *impl.DecodedExpandContinuation
+}
+
+func expandOptionsFromContext(ctx context.Context) (expandOptions, error) {
+	opts := expandOptions{
+		maxDepth: defaultExpandMaxDepth,
+		maxNodes: defaultExpandMaxNodes,
+	}
+
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return opts, nil
+	}
+
+	if values := md.Get(string(ExpandModeRequestKey)); len(values) > 0 {
+		switch values[0] {
+		case expandModeShallow:
+		case expandModeRecursive:
+			opts.recursive = true
+		default:
+			return opts, status.Errorf(codes.InvalidArgument, "unknown expansion mode `%s`", values[0])
+		}
+	}
+
+	var err error
+	if opts.maxDepth, err = uint32Header(md, ExpandMaxDepthRequestKey, opts.maxDepth); err != nil {
+		return opts, err
+	}
+
+	if opts.maxNodes, err = uint32Header(md, ExpandMaxNodesRequestKey, opts.maxNodes); err != nil {
+		return opts, err
+	}
+
+	if opts.maxNodes == 0 || opts.maxNodes > maximumExpandMaxNodes {
+		return opts, status.Errorf(codes.InvalidArgument, "maximum expansion nodes must be between 1 and %d", maximumExpandMaxNodes)
+	}
+
+	if values := md.Get(string(ExpandContinuationRequestKey)); len(values) > 0 {
+		opts.continuation, err = decodeExpandContinuation(values[0])
+		if err != nil {
+			return opts, status.Errorf(codes.InvalidArgument, "invalid expansion continuation token: %s", err)
+		}
+	}
+
+	return opts, nil
+}
+
+func uint32Header(md metadata.MD, key requestmeta.RequestMetadataHeaderKey, defaultValue uint32) (uint32, error) {
+	values := md.Get(string(key))
+	if len(values) == 0 {
+		return defaultValue, nil
+	}
+
+	parsed, err := strconv.ParseUint(values[0], 10, 32)
+	if err != nil {
+		return 0, status.Errorf(codes.InvalidArgument, "invalid value for `%s`: %s", key, err)
+	}
+	return uint32(parsed), nil
+}
+
+// expandContinuationHMAC returns the HMAC, keyed by the server's continuation key, of the
+// request to expand the given subject set and the revision at which it is continued.
+func expandContinuationHMAC(key []byte, revision string, onr *core.ObjectAndRelation) []byte {
+	mac := hmac.New(sha256.New, key)
+	for _, part := range []string{onr.Namespace, onr.ObjectId, onr.Relation, revision} {
+		// Each part is terminated, so that the boundaries between them are part of the HMAC.
+		mac.Write([]byte(part))
+		mac.Write([]byte{0})
+	}
+	return mac.Sum(nil)
+}
+
+func encodeExpandContinuation(key []byte, revision decimal.Decimal, onr *core.ObjectAndRelation) (string, error) {
+	marshalled, err := proto.Marshal(&impl.DecodedExpandContinuation{
+		Revision:    revision.String(),
+		RequestHmac: expandContinuationHMAC(key, revision.String(), onr),
+	})
+	if err != nil {
+		return "", fmt.Errorf("error encoding expansion continuation token: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(marshalled), nil
+}
+
+func decodeExpandContinuation(encoded string) (*impl.DecodedExpandContinuation, error) {
+	decodedBytes, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+
+	decoded := &impl.DecodedExpandContinuation{}
+	if err := proto.Unmarshal(decodedBytes, decoded); err != nil {
+		return nil, err
+	}
+	return decoded, nil
+}
+
+// expandRecursively expands the given object and relation, and then each of the subject sets
+// found in the leaves of its expansion tree in turn, breadth first, until either the maximum
+// depth or number of expanded nodes has been reached. As with recursive dispatched expansion,
+// each leaf with expanded subject sets becomes a union of their expansions and the leaf itself.
+//
+// Each subject set is expanded at most once, to handle cyclic and repeated subject sets.
+// Returns the expansion tree, the combined metadata of the dispatched expansions, and the
+// subject sets which were not expanded because a limit was reached.
+func (ps *permissionServer) expandRecursively(
+	ctx context.Context,
+	start *core.ObjectAndRelation,
+	revision decimal.Decimal,
+	opts expandOptions,
+) (*core.RelationTupleTreeNode, *dispatch.ResponseMeta, []*core.ObjectAndRelation, error) {
+	combined := &dispatch.ResponseMeta{}
+	expandedCount := uint32(0)
+	expand := func(onr *core.ObjectAndRelation) (*core.RelationTupleTreeNode, error) {
+		expandedCount++
+		resp, err := ps.dispatch.DispatchExpand(ctx, &dispatch.DispatchExpandRequest{
+			Metadata: &dispatch.ResolverMeta{
+				AtRevision:     revision.String(),
+				DepthRemaining: ps.defaultDepth,
+			},
+			ObjectAndRelation: onr,
+			ExpansionMode:     dispatch.DispatchExpandRequest_SHALLOW,
+		})
+
+		combined.DispatchCount += resp.Metadata.DispatchCount
+		combined.CachedDispatchCount += resp.Metadata.CachedDispatchCount
+		if resp.Metadata.DepthRequired > combined.DepthRequired {
+			combined.DepthRequired = resp.Metadata.DepthRequired
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		// The tree is cloned, as its leaves are rewritten as their subject sets are expanded.
+		return proto.Clone(resp.TreeNode).(*core.RelationTupleTreeNode), nil
+	}
+
+	root, err := expand(start)
+	if err != nil {
+		return nil, combined, nil, err
+	}
+
+	visited := map[string]struct{}{tuple.StringONR(start): {}}
+	var truncated []*core.ObjectAndRelation
+
+	frontier := leavesOf(root, nil)
+	for depth := uint32(1); len(frontier) > 0; depth++ {
+		var next []*core.RelationTupleTreeNode
+		for _, leaf := range frontier {
+			var children []*core.RelationTupleTreeNode
+			for _, user := range leaf.GetLeafNode().Users {
+				userset := user.GetUserset()
+				if userset.Relation == graph.Ellipsis {
+					continue
+				}
+
+				key := tuple.StringONR(userset)
+				if _, ok := visited[key]; ok {
+					continue
+				}
+				visited[key] = struct{}{}
+
+				if depth > opts.maxDepth || expandedCount >= opts.maxNodes {
+					truncated = append(truncated, userset)
+					continue
+				}
+
+				child, err := expand(userset)
+				if err != nil {
+					return nil, combined, nil, err
+				}
+
+				children = append(children, child)
+				next = leavesOf(child, next)
+			}
+
+			if len(children) > 0 {
+				original := &core.RelationTupleTreeNode{NodeType: leaf.NodeType, Expanded: leaf.Expanded}
+				leaf.NodeType = &core.RelationTupleTreeNode_IntermediateNode{
+					IntermediateNode: &core.SetOperationUserset{
+						Operation:  core.SetOperationUserset_UNION,
+						ChildNodes: append(children, original),
+					},
+				}
+			}
+		}
+		frontier = next
+	}
+
+	return root, combined, truncated, nil
+}
+
+// leavesOf appends the leaves of the given tree to the given slice.
+func leavesOf(node *core.RelationTupleTreeNode, leaves []*core.RelationTupleTreeNode) []*core.RelationTupleTreeNode {
+	switch t := node.NodeType.(type) {
+	case *core.RelationTupleTreeNode_LeafNode:
+		return append(leaves, node)
+	case *core.RelationTupleTreeNode_IntermediateNode:
+		for _, child := range t.IntermediateNode.ChildNodes {
+			leaves = leavesOf(child, leaves)
+		}
+	}
+	return leaves
+}
+
+// setExpandContinuations sets the continuation tokens of the given truncated subject sets in
+// the response trailer.
+func (ps *permissionServer) setExpandContinuations(ctx context.Context, revision decimal.Decimal, truncated []*core.ObjectAndRelation) error {
+	if len(truncated) == 0 {
+		return nil
+	}
+
+	pairs := make([]string, 0, len(truncated)*2)
+	for _, onr := range truncated {
+		token, err := encodeExpandContinuation(ps.continuationKey, revision, onr)
+		if err != nil {
+			return err
+		}
+		pairs = append(pairs, string(ExpandContinuationsTrailerKey), tuple.StringONR(onr)+" "+token)
+	}
+	return grpc.SetTrailer(ctx, metadata.Pairs(pairs...))
+}
@@ -0,0 +1,215 @@
+package v1
+
+import (
+	"context"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	grpcmw "github.com/grpc-ecosystem/go-grpc-middleware"
+	grpcvalidate "github.com/grpc-ecosystem/go-grpc-middleware/validator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/authzed/spicedb/internal/dispatch"
+	"github.com/authzed/spicedb/internal/graph"
+	datastoremw "github.com/authzed/spicedb/internal/middleware/datastore"
+	"github.com/authzed/spicedb/internal/middleware/usagemetrics"
+	"github.com/authzed/spicedb/internal/namespace"
+	"github.com/authzed/spicedb/internal/services/shared"
+	"github.com/authzed/spicedb/pkg/datastore"
+	"github.com/authzed/spicedb/pkg/middleware/consistency"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	dispatchv1 "github.com/authzed/spicedb/pkg/proto/dispatch/v1"
+	experimentalv1 "github.com/authzed/spicedb/pkg/proto/experimental/v1"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+// NewExperimentalServer creates an ExperimentalServiceServer instance.
+func NewExperimentalServer(
+	dispatch dispatch.Dispatcher,
+	defaultDepth uint32,
+) experimentalv1.ExperimentalServiceServer {
+	return &experimentalServer{
+		dispatch:     dispatch,
+		defaultDepth: defaultDepth,
+		WithServiceSpecificInterceptors: shared.WithServiceSpecificInterceptors{
+			Unary: grpcmw.ChainUnaryServer(
+				grpcvalidate.UnaryServerInterceptor(),
+				usagemetrics.UnaryServerInterceptor(),
+			),
+			Stream: grpcmw.ChainStreamServer(
+				grpcvalidate.StreamServerInterceptor(),
+				usagemetrics.StreamServerInterceptor(),
+			),
+		},
+	}
+}
+
+type experimentalServer struct {
+	experimentalv1.UnimplementedExperimentalServiceServer
+	shared.WithServiceSpecificInterceptors
+
+	dispatch     dispatch.Dispatcher
+	defaultDepth uint32
+}
+
+// ComputePermissions computes each of the permissions in a single evaluation of the relation
+// graph, such that the subproblems they share are only evaluated once.
+func (es *experimentalServer) ComputePermissions(ctx context.Context, req *experimentalv1.ComputePermissionsRequest) (*experimentalv1.ComputePermissionsResponse, error) {
+	atRevision, computedAt := consistency.MustRevisionFromContext(ctx)
+	ds := datastoremw.MustFromContext(ctx).SnapshotReader(atRevision)
+
+	err := namespace.CheckNamespaceAndRelation(
+		ctx,
+		req.Subject.Object.ObjectType,
+		normalizeSubjectRelation(req.Subject),
+		true,
+		ds,
+	)
+	if err != nil {
+		return nil, rewritePermissionsError(ctx, err)
+	}
+
+	nsDef, _, err := ds.ReadNamespace(ctx, req.Resource.ObjectType)
+	if err != nil {
+		return nil, rewritePermissionsError(ctx, err)
+	}
+
+	// Relations defined by a rewrite are permissions.
+	var relations []*core.Relation
+	if len(req.Permissions) == 0 {
+		for _, relation := range nsDef.Relation {
+			if relation.UsersetRewrite != nil {
+				relations = append(relations, relation)
+			}
+		}
+	} else {
+		for _, permission := range req.Permissions {
+			relation, ok := findRelation(nsDef, permission)
+			if !ok {
+				return nil, rewritePermissionsError(ctx, namespace.NewRelationNotFoundErr(req.Resource.ObjectType, permission))
+			}
+			relations = append(relations, relation)
+		}
+	}
+
+	responses, err := graph.ComputePermissions(ctx, es.dispatch, graph.ValidatedCheckRequest{
+		DispatchCheckRequest: &dispatchv1.DispatchCheckRequest{
+			Metadata: &dispatchv1.ResolverMeta{
+				AtRevision:     atRevision.String(),
+				DepthRemaining: es.defaultDepth,
+			},
+			ObjectAndRelation: &core.ObjectAndRelation{
+				Namespace: req.Resource.ObjectType,
+				ObjectId:  req.Resource.ObjectId,
+			},
+			Subject: &core.ObjectAndRelation{
+				Namespace: req.Subject.Object.ObjectType,
+				ObjectId:  req.Subject.Object.ObjectId,
+				Relation:  normalizeSubjectRelation(req.Subject),
+			},
+		},
+		Revision: atRevision,
+	}, relations)
+
+	metadata := &dispatchv1.ResponseMeta{}
+	for _, cr := range responses {
+		if cr.GetMetadata() != nil {
+			metadata.DispatchCount += cr.Metadata.DispatchCount
+			metadata.CachedDispatchCount += cr.Metadata.CachedDispatchCount
+			if cr.Metadata.DepthRequired > metadata.DepthRequired {
+				metadata.DepthRequired = cr.Metadata.DepthRequired
+			}
+		}
+	}
+	usagemetrics.SetInContext(ctx, metadata)
+	if err != nil {
+		return nil, rewritePermissionsError(ctx, err)
+	}
+
+	computed := make([]*experimentalv1.ComputedPermission, 0, len(responses))
+	for index, cr := range responses {
+		var permissionship v1.CheckPermissionResponse_Permissionship
+		switch cr.Membership {
+		case dispatchv1.DispatchCheckResponse_MEMBER:
+			permissionship = v1.CheckPermissionResponse_PERMISSIONSHIP_HAS_PERMISSION
+		case dispatchv1.DispatchCheckResponse_NOT_MEMBER:
+			permissionship = v1.CheckPermissionResponse_PERMISSIONSHIP_NO_PERMISSION
+		default:
+			permissionship = v1.CheckPermissionResponse_PERMISSIONSHIP_UNSPECIFIED
+		}
+		computed = append(computed, &experimentalv1.ComputedPermission{
+			Permission:     relations[index].Name,
+			Permissionship: permissionship,
+		})
+	}
+
+	return &experimentalv1.ComputePermissionsResponse{
+		ComputedAt:  computedAt,
+		Permissions: computed,
+	}, nil
+}
+
+func findRelation(nsDef *core.NamespaceDefinition, name string) (*core.Relation, bool) {
+	for _, relation := range nsDef.Relation {
+		if relation.Name == name {
+			return relation, true
+		}
+	}
+	return nil, false
+}
+
+// CountRelationships counts the relationships matching the filter in the datastore, rather
+// than streaming them back to be counted by the caller.
+func (es *experimentalServer) CountRelationships(ctx context.Context, req *experimentalv1.CountRelationshipsRequest) (*experimentalv1.CountRelationshipsResponse, error) {
+	atRevision, countedAt := consistency.MustRevisionFromContext(ctx)
+	ds := datastoremw.MustFromContext(ctx).SnapshotReader(atRevision)
+
+	if err := checkFilterNamespaces(ctx, req.RelationshipFilter, ds); err != nil {
+		return nil, rewritePermissionsError(ctx, err)
+	}
+
+	usagemetrics.SetInContext(ctx, &dispatchv1.ResponseMeta{
+		DispatchCount: 1,
+	})
+
+	count, err := ds.CountRelationships(ctx, req.RelationshipFilter)
+	if err != nil {
+		return nil, rewritePermissionsError(ctx, err)
+	}
+
+	return &experimentalv1.CountRelationshipsResponse{
+		CountedAt:         countedAt,
+		RelationshipCount: count,
+	}, nil
+}
+
+// ReadRelationshipHistory streams the relationships which existed at a past time from the
+// history archived by the datastore. The filter is not checked against the current schema,
+// as the relationships may be of object types and relations which have since been removed.
+func (es *experimentalServer) ReadRelationshipHistory(req *experimentalv1.ReadRelationshipHistoryRequest, resp experimentalv1.ExperimentalService_ReadRelationshipHistoryServer) error {
+	ctx := resp.Context()
+	ds := datastoremw.MustFromContext(ctx)
+
+	usagemetrics.SetInContext(ctx, &dispatchv1.ResponseMeta{
+		DispatchCount: 1,
+	})
+
+	tupleIterator, err := datastore.QueryRelationshipsAsOf(ctx, ds, req.AsOf.AsTime(), req.RelationshipFilter)
+	if err != nil {
+		return rewritePermissionsError(ctx, err)
+	}
+	defer tupleIterator.Close()
+
+	for tpl := tupleIterator.Next(); tpl != nil; tpl = tupleIterator.Next() {
+		if err := resp.Send(&experimentalv1.ReadRelationshipHistoryResponse{
+			Relationship: tuple.ToRelationship(tpl),
+		}); err != nil {
+			return err
+		}
+	}
+	if err := tupleIterator.Err(); err != nil {
+		return status.Errorf(codes.Internal, "error when reading relationship history: %s", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,513 @@
+package v1_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sort"
+	"testing"
+	"time"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/authzed/grpcutil"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/authzed/spicedb/internal/datastore/memdb"
+	tf "github.com/authzed/spicedb/internal/testfixtures"
+	"github.com/authzed/spicedb/internal/testserver"
+	experimentalv1 "github.com/authzed/spicedb/pkg/proto/experimental/v1"
+	"github.com/authzed/spicedb/pkg/zedtoken"
+)
+
+func TestComputePermissions(t *testing.T) {
+	var (
+		has = v1.CheckPermissionResponse_PERMISSIONSHIP_HAS_PERMISSION
+		no  = v1.CheckPermissionResponse_PERMISSIONSHIP_NO_PERMISSION
+	)
+
+	testCases := []struct {
+		name              string
+		resource          *v1.ObjectReference
+		subject           *v1.SubjectReference
+		permissions       []string
+		expected          map[string]v1.CheckPermissionResponse_Permissionship
+		expectedErrorCode codes.Code
+	}{
+		{
+			"all permissions",
+			obj("document", "masterplan"),
+			sub("user", "eng_lead", ""),
+			nil,
+			map[string]v1.CheckPermissionResponse_Permissionship{
+				"editor":                    no,
+				"viewer":                    has,
+				"viewer_and_editor":         no,
+				"viewer_and_editor_derived": no,
+			},
+			codes.OK,
+		},
+		{
+			"chosen permissions and relations",
+			obj("document", "masterplan"),
+			sub("user", "product_manager", ""),
+			[]string{"owner", "viewer", "viewer_and_editor"},
+			map[string]v1.CheckPermissionResponse_Permissionship{
+				"owner":             has,
+				"viewer":            has,
+				"viewer_and_editor": no,
+			},
+			codes.OK,
+		},
+		{
+			"unknown permission",
+			obj("document", "masterplan"),
+			sub("user", "eng_lead", ""),
+			[]string{"viewer", "fakepermission"},
+			nil,
+			codes.FailedPrecondition,
+		},
+		{
+			"unknown resource type",
+			obj("fake", "masterplan"),
+			sub("user", "eng_lead", ""),
+			nil,
+			nil,
+			codes.FailedPrecondition,
+		},
+		{
+			"unknown subject type",
+			obj("document", "masterplan"),
+			sub("fake", "eng_lead", ""),
+			nil,
+			nil,
+			codes.FailedPrecondition,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			require := require.New(t)
+			conn, cleanup, revision := testserver.NewTestServer(require, 0, memdb.DisableGC, true, tf.StandardDatastoreWithData)
+			client := experimentalv1.NewExperimentalServiceClient(conn)
+			t.Cleanup(cleanup)
+
+			resp, err := client.ComputePermissions(context.Background(), &experimentalv1.ComputePermissionsRequest{
+				Consistency: &v1.Consistency{
+					Requirement: &v1.Consistency_AtLeastAsFresh{
+						AtLeastAsFresh: zedtoken.NewFromRevision(revision),
+					},
+				},
+				Resource:    tc.resource,
+				Subject:     tc.subject,
+				Permissions: tc.permissions,
+			})
+			if tc.expectedErrorCode != codes.OK {
+				grpcutil.RequireStatus(t, tc.expectedErrorCode, err)
+				return
+			}
+			require.NoError(err)
+			require.NotNil(resp.ComputedAt)
+
+			computed := make(map[string]v1.CheckPermissionResponse_Permissionship, len(resp.Permissions))
+			for index, permission := range resp.Permissions {
+				if tc.permissions != nil {
+					require.Equal(tc.permissions[index], permission.Permission)
+				}
+				computed[permission.Permission] = permission.Permissionship
+			}
+			require.Equal(tc.expected, computed)
+		})
+	}
+}
+
+func TestListSubjectAccess(t *testing.T) {
+	testCases := []struct {
+		name              string
+		subject           *v1.SubjectReference
+		expected          []string
+		expectedErrorCode codes.Code
+	}{
+		{
+			"inherited from folder",
+			sub("user", "legal", ""),
+			[]string{
+				"document#viewer:companyplan",
+				"document#viewer:masterplan",
+				"folder#viewer:company",
+				"folder#viewer:strategy",
+			},
+			codes.OK,
+		},
+		{
+			"intersection checked",
+			sub("user", "multiroleguy", ""),
+			[]string{
+				"document#editor:specialplan",
+				"document#viewer:specialplan",
+				"document#viewer_and_editor:specialplan",
+				"document#viewer_and_editor_derived:specialplan",
+			},
+			codes.OK,
+		},
+		{
+			"intersection not satisfied",
+			sub("user", "missingrolegal", ""),
+			nil,
+			codes.OK,
+		},
+		{
+			"wildcard subject",
+			sub("user", "*", ""),
+			nil,
+			codes.InvalidArgument,
+		},
+		{
+			"unknown subject type",
+			sub("fake", "someone", ""),
+			nil,
+			codes.FailedPrecondition,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			require := require.New(t)
+			conn, cleanup, revision := testserver.NewTestServer(require, 0, memdb.DisableGC, true, tf.StandardDatastoreWithData)
+			client := experimentalv1.NewExperimentalServiceClient(conn)
+			t.Cleanup(cleanup)
+
+			stream, err := client.ListSubjectAccess(context.Background(), &experimentalv1.ListSubjectAccessRequest{
+				Consistency: &v1.Consistency{
+					Requirement: &v1.Consistency_AtLeastAsFresh{
+						AtLeastAsFresh: zedtoken.NewFromRevision(revision),
+					},
+				},
+				Subject: tc.subject,
+			})
+			require.NoError(err)
+
+			var listed []string
+			for {
+				resp, err := stream.Recv()
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				if tc.expectedErrorCode != codes.OK {
+					grpcutil.RequireStatus(t, tc.expectedErrorCode, err)
+					return
+				}
+				require.NoError(err)
+				require.NotNil(resp.ListedAt)
+
+				for _, objectID := range resp.ResourceObjectIds {
+					listed = append(listed, resp.ResourceObjectType+"#"+resp.Permission+":"+objectID)
+				}
+			}
+
+			require.Equal(codes.OK, tc.expectedErrorCode)
+			sort.Strings(listed)
+			require.Equal(tc.expected, listed)
+		})
+	}
+}
+
+func TestLookupResourceCandidates(t *testing.T) {
+	var (
+		has      = experimentalv1.LookupResourceCandidatesResponse_PERMISSIONSHIP_HAS_PERMISSION
+		possibly = experimentalv1.LookupResourceCandidatesResponse_PERMISSIONSHIP_POSSIBLY_HAS_PERMISSION
+	)
+
+	testCases := []struct {
+		name              string
+		permission        string
+		subject           *v1.SubjectReference
+		expected          map[string]experimentalv1.LookupResourceCandidatesResponse_Permissionship
+		expectedErrorCode codes.Code
+	}{
+		{
+			"reached through intersection",
+			"viewer_and_editor",
+			sub("user", "missingrolegal", ""),
+			map[string]experimentalv1.LookupResourceCandidatesResponse_Permissionship{
+				"specialplan": possibly,
+			},
+			codes.OK,
+		},
+		{
+			"reached through union",
+			"viewer",
+			sub("user", "auditor", ""),
+			map[string]experimentalv1.LookupResourceCandidatesResponse_Permissionship{
+				"companyplan": has,
+				"masterplan":  has,
+			},
+			codes.OK,
+		},
+		{
+			"wildcard subject",
+			"viewer",
+			sub("user", "*", ""),
+			nil,
+			codes.InvalidArgument,
+		},
+		{
+			"unknown permission",
+			"fakepermission",
+			sub("user", "auditor", ""),
+			nil,
+			codes.FailedPrecondition,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			require := require.New(t)
+			conn, cleanup, revision := testserver.NewTestServer(require, 0, memdb.DisableGC, true, tf.StandardDatastoreWithData)
+			client := experimentalv1.NewExperimentalServiceClient(conn)
+			t.Cleanup(cleanup)
+
+			stream, err := client.LookupResourceCandidates(context.Background(), &experimentalv1.LookupResourceCandidatesRequest{
+				Consistency: &v1.Consistency{
+					Requirement: &v1.Consistency_AtLeastAsFresh{
+						AtLeastAsFresh: zedtoken.NewFromRevision(revision),
+					},
+				},
+				ResourceObjectType: "document",
+				Permission:         tc.permission,
+				Subject:            tc.subject,
+			})
+			require.NoError(err)
+
+			var found map[string]experimentalv1.LookupResourceCandidatesResponse_Permissionship
+			for {
+				resp, err := stream.Recv()
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				if tc.expectedErrorCode != codes.OK {
+					grpcutil.RequireStatus(t, tc.expectedErrorCode, err)
+					return
+				}
+				require.NoError(err)
+				require.NotNil(resp.LookedUpAt)
+
+				if found == nil {
+					found = map[string]experimentalv1.LookupResourceCandidatesResponse_Permissionship{}
+				}
+				require.NotContains(found, resp.ResourceObjectId)
+				found[resp.ResourceObjectId] = resp.Permissionship
+			}
+
+			require.Equal(codes.OK, tc.expectedErrorCode)
+			require.Equal(tc.expected, found)
+		})
+	}
+}
+
+func TestCountRelationships(t *testing.T) {
+	testCases := []struct {
+		name              string
+		filter            *v1.RelationshipFilter
+		expected          uint64
+		expectedErrorCode codes.Code
+	}{
+		{
+			"resource type",
+			&v1.RelationshipFilter{ResourceType: "document"},
+			9,
+			codes.OK,
+		},
+		{
+			"resource",
+			&v1.RelationshipFilter{ResourceType: "document", OptionalResourceId: "masterplan"},
+			4,
+			codes.OK,
+		},
+		{
+			"relation",
+			&v1.RelationshipFilter{ResourceType: "document", OptionalRelation: "parent"},
+			4,
+			codes.OK,
+		},
+		{
+			"subject type",
+			&v1.RelationshipFilter{
+				ResourceType:          "folder",
+				OptionalSubjectFilter: &v1.SubjectFilter{SubjectType: "user"},
+			},
+			6,
+			codes.OK,
+		},
+		{
+			"no matches",
+			&v1.RelationshipFilter{ResourceType: "document", OptionalResourceId: "unknownplan"},
+			0,
+			codes.OK,
+		},
+		{
+			"unknown resource type",
+			&v1.RelationshipFilter{ResourceType: "fake"},
+			0,
+			codes.FailedPrecondition,
+		},
+		{
+			"unknown relation",
+			&v1.RelationshipFilter{ResourceType: "document", OptionalRelation: "fake"},
+			0,
+			codes.FailedPrecondition,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			require := require.New(t)
+			conn, cleanup, revision := testserver.NewTestServer(require, 0, memdb.DisableGC, true, tf.StandardDatastoreWithData)
+			client := experimentalv1.NewExperimentalServiceClient(conn)
+			t.Cleanup(cleanup)
+
+			resp, err := client.CountRelationships(context.Background(), &experimentalv1.CountRelationshipsRequest{
+				Consistency: &v1.Consistency{
+					Requirement: &v1.Consistency_AtLeastAsFresh{
+						AtLeastAsFresh: zedtoken.NewFromRevision(revision),
+					},
+				},
+				RelationshipFilter: tc.filter,
+			})
+			if tc.expectedErrorCode != codes.OK {
+				grpcutil.RequireStatus(t, tc.expectedErrorCode, err)
+				return
+			}
+			require.NoError(err)
+			require.NotNil(resp.CountedAt)
+			require.Equal(tc.expected, resp.RelationshipCount)
+		})
+	}
+}
+
+func TestBulkImportRelationships(t *testing.T) {
+	testCases := []struct {
+		name              string
+		batches           [][]*v1.Relationship
+		expectedErrorCode codes.Code
+	}{
+		{
+			"multiple batches",
+			[][]*v1.Relationship{
+				{
+					rel("document", "newplan", "viewer", "user", "eng_lead", ""),
+					rel("document", "newplan", "owner", "user", "product_manager", ""),
+				},
+				{
+					rel("document", "newplan", "parent", "folder", "plans", ""),
+				},
+			},
+			codes.OK,
+		},
+		{
+			"no relationships",
+			nil,
+			codes.OK,
+		},
+		{
+			"unknown relation",
+			[][]*v1.Relationship{
+				{rel("document", "newplan", "fake", "user", "eng_lead", "")},
+			},
+			codes.FailedPrecondition,
+		},
+		{
+			"disallowed subject type",
+			[][]*v1.Relationship{
+				{rel("document", "newplan", "parent", "user", "eng_lead", "")},
+			},
+			codes.InvalidArgument,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			require := require.New(t)
+			conn, cleanup, _ := testserver.NewTestServer(require, 0, memdb.DisableGC, true, tf.StandardDatastoreWithData)
+			client := experimentalv1.NewExperimentalServiceClient(conn)
+			t.Cleanup(cleanup)
+
+			stream, err := client.BulkImportRelationships(context.Background())
+			require.NoError(err)
+
+			var expected uint64
+			for _, batch := range tc.batches {
+				require.NoError(stream.Send(&experimentalv1.BulkImportRelationshipsRequest{
+					Relationships: batch,
+				}))
+				expected += uint64(len(batch))
+			}
+
+			resp, err := stream.CloseAndRecv()
+			if tc.expectedErrorCode != codes.OK {
+				grpcutil.RequireStatus(t, tc.expectedErrorCode, err)
+				return
+			}
+			require.NoError(err)
+			require.Equal(expected, resp.NumLoaded)
+
+			counted, err := client.CountRelationships(context.Background(), &experimentalv1.CountRelationshipsRequest{
+				Consistency: &v1.Consistency{
+					Requirement: &v1.Consistency_AtLeastAsFresh{
+						AtLeastAsFresh: resp.WrittenAt,
+					},
+				},
+				RelationshipFilter: &v1.RelationshipFilter{
+					ResourceType:       "document",
+					OptionalResourceId: "newplan",
+				},
+			})
+			require.NoError(err)
+			require.Equal(expected, counted.RelationshipCount)
+		})
+	}
+}
+
+func TestReadRelationshipHistory(t *testing.T) {
+	testCases := []struct {
+		name              string
+		asOf              *timestamppb.Timestamp
+		expectedErrorCode codes.Code
+	}{
+		{
+			"history not archived",
+			timestamppb.New(time.Now().Add(-48 * time.Hour)),
+			codes.FailedPrecondition,
+		},
+		{
+			"missing time",
+			nil,
+			codes.InvalidArgument,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			require := require.New(t)
+			conn, cleanup, _ := testserver.NewTestServer(require, 0, memdb.DisableGC, true, tf.StandardDatastoreWithData)
+			client := experimentalv1.NewExperimentalServiceClient(conn)
+			t.Cleanup(cleanup)
+
+			stream, err := client.ReadRelationshipHistory(context.Background(), &experimentalv1.ReadRelationshipHistoryRequest{
+				AsOf:               tc.asOf,
+				RelationshipFilter: &v1.RelationshipFilter{ResourceType: "document"},
+			})
+			require.NoError(err)
+
+			_, err = stream.Recv()
+			grpcutil.RequireStatus(t, tc.expectedErrorCode, err)
+		})
+	}
+}
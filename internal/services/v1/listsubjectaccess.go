@@ -0,0 +1,114 @@
+package v1
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/authzed/spicedb/internal/graph"
+	datastoremw "github.com/authzed/spicedb/internal/middleware/datastore"
+	"github.com/authzed/spicedb/internal/middleware/usagemetrics"
+	"github.com/authzed/spicedb/internal/namespace"
+	"github.com/authzed/spicedb/pkg/middleware/consistency"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	experimentalv1 "github.com/authzed/spicedb/pkg/proto/experimental/v1"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+// ListSubjectAccess walks, for each permission of each definition, the reachability graph from
+// the subject's type. Permissions which the subject's type cannot reach are skipped, and the
+// resources of the rest are found with a reachable resources dispatch, checking those which
+// were reached through an intersection or exclusion. Each resource is sent as it is found.
+func (es *experimentalServer) ListSubjectAccess(req *experimentalv1.ListSubjectAccessRequest, resp experimentalv1.ExperimentalService_ListSubjectAccessServer) error {
+	ctx := resp.Context()
+	atRevision, listedAt := consistency.MustRevisionFromContext(ctx)
+	reader := datastoremw.MustFromContext(ctx).SnapshotReader(atRevision)
+
+	if req.Subject.Object.ObjectId == tuple.PublicWildcard {
+		return rewritePermissionsError(ctx, graph.NewErrInvalidArgument(errors.New("cannot list access of wildcard")))
+	}
+
+	err := namespace.CheckNamespaceAndRelation(
+		ctx,
+		req.Subject.Object.ObjectType,
+		normalizeSubjectRelation(req.Subject),
+		true,
+		reader,
+	)
+	if err != nil {
+		return rewritePermissionsError(ctx, err)
+	}
+
+	nsDefs, err := reader.ListNamespaces(ctx)
+	if err != nil {
+		return rewritePermissionsError(ctx, err)
+	}
+	sort.Slice(nsDefs, func(i, j int) bool {
+		return nsDefs[i].Name < nsDefs[j].Name
+	})
+
+	subject := &core.ObjectAndRelation{
+		Namespace: req.Subject.Object.ObjectType,
+		ObjectId:  req.Subject.Object.ObjectId,
+		Relation:  normalizeSubjectRelation(req.Subject),
+	}
+	subjectType := &core.RelationReference{
+		Namespace: subject.Namespace,
+		Relation:  subject.Relation,
+	}
+
+	dispatched := newDispatchMetadata()
+	defer func() {
+		usagemetrics.SetInContext(ctx, dispatched.metadata)
+	}()
+
+	for _, nsDef := range nsDefs {
+		typeSystem, err := namespace.BuildNamespaceTypeSystemForDatastore(nsDef, reader)
+		if err != nil {
+			return rewritePermissionsError(ctx, err)
+		}
+		rg := namespace.ReachabilityGraphFor(typeSystem.AsValidated())
+
+		for _, relation := range nsDef.Relation {
+			// Relations defined by a rewrite are permissions.
+			if relation.UsersetRewrite == nil {
+				continue
+			}
+
+			resourceType := &core.RelationReference{
+				Namespace: nsDef.Name,
+				Relation:  relation.Name,
+			}
+			entrypoints, err := rg.AllEntrypointsForSubjectToResource(ctx, subjectType, resourceType)
+			if err != nil {
+				return rewritePermissionsError(ctx, err)
+			}
+			if len(entrypoints) == 0 {
+				continue
+			}
+
+			err = streamReachableResources(
+				ctx,
+				es.dispatch,
+				atRevision,
+				es.defaultDepth,
+				resourceType,
+				subject,
+				true,
+				dispatched,
+				func(objectID string, _ bool) error {
+					return resp.Send(&experimentalv1.ListSubjectAccessResponse{
+						ListedAt:           listedAt,
+						ResourceObjectType: nsDef.Name,
+						Permission:         relation.Name,
+						ResourceObjectIds:  []string{objectID},
+					})
+				},
+			)
+			if err != nil {
+				return rewritePermissionsError(ctx, err)
+			}
+		}
+	}
+
+	return nil
+}
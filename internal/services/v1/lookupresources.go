@@ -0,0 +1,188 @@
+package v1
+
+import (
+	"context"
+	"errors"
+
+	"github.com/authzed/authzed-go/pkg/requestmeta"
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+	"github.com/shopspring/decimal"
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/authzed/spicedb/internal/graph"
+	datastoremw "github.com/authzed/spicedb/internal/middleware/datastore"
+	"github.com/authzed/spicedb/internal/middleware/usagemetrics"
+	"github.com/authzed/spicedb/internal/namespace"
+	"github.com/authzed/spicedb/pkg/datastore"
+	"github.com/authzed/spicedb/pkg/middleware/consistency"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	experimentalv1 "github.com/authzed/spicedb/pkg/proto/experimental/v1"
+	"github.com/authzed/spicedb/pkg/tuple"
+)
+
+// LookupResourcesModeRequestKey, if specified in the request header of LookupResources, sets
+// how the resources found are verified. Every resource returned has permission in each mode;
+// resources which may have permission are returned without being checked by the experimental
+// LookupResourceCandidates method.
+//
+// Value: one of:
+//   - `checked` (default): results are returned once all of them have been verified.
+//   - `checkrequired`: resources found to definitely have permission are returned as soon as
+//     they are found, while the rest are checked concurrently and returned if they have
+//     permission. For schemas without intersections or exclusions, no checks are performed.
+const LookupResourcesModeRequestKey requestmeta.RequestMetadataHeaderKey = "io.spicedb.lookupresources.mode"
+
+const (
+	lookupResourcesModeChecked       = "checked"
+	lookupResourcesModeCheckRequired = "checkrequired"
+)
+
+func lookupResourcesModeFromContext(ctx context.Context) (string, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return lookupResourcesModeChecked, nil
+	}
+
+	values := md.Get(string(LookupResourcesModeRequestKey))
+	if len(values) == 0 {
+		return lookupResourcesModeChecked, nil
+	}
+
+	switch values[0] {
+	case lookupResourcesModeChecked, lookupResourcesModeCheckRequired:
+		return values[0], nil
+	default:
+		return "", status.Errorf(codes.InvalidArgument, "unknown lookup resources mode `%s`", values[0])
+	}
+}
+
+// checkLookupResourcesNamespaces checks that the resource type, permission and subject type
+// of a lookup exist.
+func checkLookupResourcesNamespaces(
+	ctx context.Context,
+	ds datastore.Reader,
+	resourceObjectType string,
+	permission string,
+	subject *v1.SubjectReference,
+) error {
+	// Perform our preflight checks in parallel
+	errG, checksCtx := errgroup.WithContext(ctx)
+	errG.Go(func() error {
+		return namespace.CheckNamespaceAndRelation(
+			checksCtx,
+			subject.Object.ObjectType,
+			normalizeSubjectRelation(subject),
+			true,
+			ds,
+		)
+	})
+	errG.Go(func() error {
+		return namespace.CheckNamespaceAndRelation(
+			checksCtx,
+			resourceObjectType,
+			permission,
+			false,
+			ds,
+		)
+	})
+	return errG.Wait()
+}
+
+// lookupReachableResources streams the resources reachable from the subject of the request,
+// checking those which require a check.
+func (ps *permissionServer) lookupReachableResources(
+	req *v1.LookupResourcesRequest,
+	resp v1.PermissionsService_LookupResourcesServer,
+	atRevision decimal.Decimal,
+	revisionReadAt *v1.ZedToken,
+) error {
+	ctx := resp.Context()
+	if req.Subject.Object.ObjectId == tuple.PublicWildcard {
+		return rewritePermissionsError(ctx, graph.NewErrInvalidArgument(errors.New("cannot perform lookup on wildcard")))
+	}
+
+	dispatched := newDispatchMetadata()
+	err := streamReachableResources(
+		ctx,
+		ps.dispatch,
+		atRevision,
+		ps.defaultDepth,
+		&core.RelationReference{
+			Namespace: req.ResourceObjectType,
+			Relation:  req.Permission,
+		},
+		&core.ObjectAndRelation{
+			Namespace: req.Subject.Object.ObjectType,
+			ObjectId:  req.Subject.Object.ObjectId,
+			Relation:  normalizeSubjectRelation(req.Subject),
+		},
+		true,
+		dispatched,
+		func(objectID string, _ bool) error {
+			return resp.Send(&v1.LookupResourcesResponse{
+				LookedUpAt:       revisionReadAt,
+				ResourceObjectId: objectID,
+			})
+		},
+	)
+	usagemetrics.SetInContext(ctx, dispatched.metadata)
+	if err != nil {
+		return rewritePermissionsError(ctx, err)
+	}
+	return nil
+}
+
+// LookupResourceCandidates streams the resources reachable from the subject of the request as
+// they are found, without checking those reached through an intersection or exclusion.
+func (es *experimentalServer) LookupResourceCandidates(req *experimentalv1.LookupResourceCandidatesRequest, resp experimentalv1.ExperimentalService_LookupResourceCandidatesServer) error {
+	ctx := resp.Context()
+	atRevision, revisionReadAt := consistency.MustRevisionFromContext(ctx)
+	ds := datastoremw.MustFromContext(ctx).SnapshotReader(atRevision)
+
+	if err := checkLookupResourcesNamespaces(ctx, ds, req.ResourceObjectType, req.Permission, req.Subject); err != nil {
+		return rewritePermissionsError(ctx, err)
+	}
+
+	if req.Subject.Object.ObjectId == tuple.PublicWildcard {
+		return rewritePermissionsError(ctx, graph.NewErrInvalidArgument(errors.New("cannot perform lookup on wildcard")))
+	}
+
+	dispatched := newDispatchMetadata()
+	err := streamReachableResources(
+		ctx,
+		es.dispatch,
+		atRevision,
+		es.defaultDepth,
+		&core.RelationReference{
+			Namespace: req.ResourceObjectType,
+			Relation:  req.Permission,
+		},
+		&core.ObjectAndRelation{
+			Namespace: req.Subject.Object.ObjectType,
+			ObjectId:  req.Subject.Object.ObjectId,
+			Relation:  normalizeSubjectRelation(req.Subject),
+		},
+		false,
+		dispatched,
+		func(objectID string, definite bool) error {
+			permissionship := experimentalv1.LookupResourceCandidatesResponse_PERMISSIONSHIP_POSSIBLY_HAS_PERMISSION
+			if definite {
+				permissionship = experimentalv1.LookupResourceCandidatesResponse_PERMISSIONSHIP_HAS_PERMISSION
+			}
+
+			return resp.Send(&experimentalv1.LookupResourceCandidatesResponse{
+				LookedUpAt:       revisionReadAt,
+				ResourceObjectId: objectID,
+				Permissionship:   permissionship,
+			})
+		},
+	)
+	usagemetrics.SetInContext(ctx, dispatched.metadata)
+	if err != nil {
+		return rewritePermissionsError(ctx, err)
+	}
+	return nil
+}
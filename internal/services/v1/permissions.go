@@ -2,11 +2,15 @@ package v1
 
 import (
 	"context"
+	"crypto/hmac"
 	"fmt"
 
 	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
 	"github.com/jzelinskie/stringz"
+	"github.com/shopspring/decimal"
 	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	"github.com/authzed/spicedb/internal/graph"
 	datastoremw "github.com/authzed/spicedb/internal/middleware/datastore"
@@ -15,6 +19,9 @@ import (
 	"github.com/authzed/spicedb/pkg/middleware/consistency"
 	core "github.com/authzed/spicedb/pkg/proto/core/v1"
 	dispatch "github.com/authzed/spicedb/pkg/proto/dispatch/v1"
+	impl "github.com/authzed/spicedb/pkg/proto/impl/v1"
+	"github.com/authzed/spicedb/pkg/tuple"
+	"github.com/authzed/spicedb/pkg/zedtoken"
 )
 
 func (ps *permissionServer) CheckPermission(ctx context.Context, req *v1.CheckPermissionRequest) (*v1.CheckPermissionResponse, error) {
@@ -83,25 +90,57 @@ func (ps *permissionServer) CheckPermission(ctx context.Context, req *v1.CheckPe
 }
 
 func (ps *permissionServer) ExpandPermissionTree(ctx context.Context, req *v1.ExpandPermissionTreeRequest) (*v1.ExpandPermissionTreeResponse, error) {
+	opts, err := expandOptionsFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	start := &core.ObjectAndRelation{
+		Namespace: req.Resource.ObjectType,
+		ObjectId:  req.Resource.ObjectId,
+		Relation:  req.Permission,
+	}
+
 	atRevision, expandedAt := consistency.MustRevisionFromContext(ctx)
+	if opts.continuation != nil {
+		atRevision, err = ps.continuationRevision(ctx, start, opts.continuation)
+		if err != nil {
+			return nil, err
+		}
+		expandedAt = zedtoken.NewFromRevision(atRevision)
+	}
+
 	ds := datastoremw.MustFromContext(ctx).SnapshotReader(atRevision)
 
-	err := namespace.CheckNamespaceAndRelation(ctx, req.Resource.ObjectType, req.Permission, false, ds)
+	err = namespace.CheckNamespaceAndRelation(ctx, req.Resource.ObjectType, req.Permission, false, ds)
 	if err != nil {
 		return nil, rewritePermissionsError(ctx, err)
 	}
 
+	if opts.recursive {
+		tree, metadata, truncated, err := ps.expandRecursively(ctx, start, atRevision, opts)
+		usagemetrics.SetInContext(ctx, metadata)
+		if err != nil {
+			return nil, rewritePermissionsError(ctx, err)
+		}
+
+		if err := ps.setExpandContinuations(ctx, atRevision, truncated); err != nil {
+			return nil, err
+		}
+
+		return &v1.ExpandPermissionTreeResponse{
+			TreeRoot:   TranslateExpansionTree(tree),
+			ExpandedAt: expandedAt,
+		}, nil
+	}
+
 	resp, err := ps.dispatch.DispatchExpand(ctx, &dispatch.DispatchExpandRequest{
 		Metadata: &dispatch.ResolverMeta{
 			AtRevision:     atRevision.String(),
 			DepthRemaining: ps.defaultDepth,
 		},
-		ObjectAndRelation: &core.ObjectAndRelation{
-			Namespace: req.Resource.ObjectType,
-			ObjectId:  req.Resource.ObjectId,
-			Relation:  req.Permission,
-		},
-		ExpansionMode: dispatch.DispatchExpandRequest_SHALLOW,
+		ObjectAndRelation: start,
+		ExpansionMode:     dispatch.DispatchExpandRequest_SHALLOW,
 	})
 	usagemetrics.SetInContext(ctx, resp.Metadata)
 	if err != nil {
@@ -116,6 +155,32 @@ func (ps *permissionServer) ExpandPermissionTree(ctx context.Context, req *v1.Ex
 	}, nil
 }
 
+// continuationRevision returns the revision at which the expansion continued by the given
+// continuation token was performed, ensuring the token was issued for the requested subject set.
+func (ps *permissionServer) continuationRevision(
+	ctx context.Context,
+	start *core.ObjectAndRelation,
+	continuation *impl.DecodedExpandContinuation,
+) (decimal.Decimal, error) {
+	if !hmac.Equal(continuation.RequestHmac, expandContinuationHMAC(ps.continuationKey, continuation.Revision, start)) {
+		return decimal.Zero, status.Errorf(
+			codes.InvalidArgument,
+			"expansion continuation token was not issued for `%s`",
+			tuple.StringONR(start),
+		)
+	}
+
+	revision, err := decimal.NewFromString(continuation.Revision)
+	if err != nil {
+		return decimal.Zero, status.Errorf(codes.InvalidArgument, "invalid expansion continuation token: %s", err)
+	}
+
+	if err := datastoremw.MustFromContext(ctx).CheckRevision(ctx, revision); err != nil {
+		return decimal.Zero, rewritePermissionsError(ctx, err)
+	}
+	return revision, nil
+}
+
 // TranslateRelationshipTree translates a V1 PermissionRelationshipTree into a RelationTupleTreeNode.
 func TranslateRelationshipTree(tree *v1.PermissionRelationshipTree) *core.RelationTupleTreeNode {
 	var expanded *core.ObjectAndRelation
@@ -265,34 +330,23 @@ func TranslateExpansionTree(node *core.RelationTupleTreeNode) *v1.PermissionRela
 
 func (ps *permissionServer) LookupResources(req *v1.LookupResourcesRequest, resp v1.PermissionsService_LookupResourcesServer) error {
 	ctx := resp.Context()
+	mode, err := lookupResourcesModeFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
 	atRevision, revisionReadAt := consistency.MustRevisionFromContext(ctx)
 
 	ds := datastoremw.MustFromContext(ctx).SnapshotReader(atRevision)
 
-	// Perform our preflight checks in parallel
-	errG, checksCtx := errgroup.WithContext(ctx)
-	errG.Go(func() error {
-		return namespace.CheckNamespaceAndRelation(
-			checksCtx,
-			req.Subject.Object.ObjectType,
-			normalizeSubjectRelation(req.Subject),
-			true,
-			ds,
-		)
-	})
-	errG.Go(func() error {
-		return namespace.CheckNamespaceAndRelation(
-			ctx,
-			req.ResourceObjectType,
-			req.Permission,
-			false,
-			ds,
-		)
-	})
-	if err := errG.Wait(); err != nil {
+	if err := checkLookupResourcesNamespaces(ctx, ds, req.ResourceObjectType, req.Permission, req.Subject); err != nil {
 		return rewritePermissionsError(ctx, err)
 	}
 
+	if mode == lookupResourcesModeCheckRequired {
+		return ps.lookupReachableResources(req, resp, atRevision, revisionReadAt)
+	}
+
 	// TODO(jschorr): Change the internal dispatched lookup to also be streamed.
 	lookupResp, err := ps.dispatch.DispatchLookup(ctx, &dispatch.DispatchLookupRequest{
 		Metadata: &dispatch.ResolverMeta{
@@ -2,11 +2,13 @@ package v1_test
 
 import (
 	"context"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"io"
 	"os"
 	"sort"
+	"strings"
 	"testing"
 	"time"
 
@@ -20,6 +22,7 @@ import (
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
 
 	"github.com/authzed/spicedb/internal/datastore/memdb"
 	v1svc "github.com/authzed/spicedb/internal/services/v1"
@@ -27,6 +30,7 @@ import (
 	"github.com/authzed/spicedb/internal/testserver"
 	pgraph "github.com/authzed/spicedb/pkg/graph"
 	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	impl "github.com/authzed/spicedb/pkg/proto/impl/v1"
 	"github.com/authzed/spicedb/pkg/tuple"
 	"github.com/authzed/spicedb/pkg/zedtoken"
 )
@@ -465,6 +469,66 @@ func TestLookupResources(t *testing.T) {
 	}
 }
 
+func TestLookupResourcesModes(t *testing.T) {
+	testCases := []struct {
+		mode              string
+		permission        string
+		subject           *v1.SubjectReference
+		expectedObjectIds []string
+		expectedErrorCode codes.Code
+	}{
+		{"checked", "viewer_and_editor", sub("user", "missingrolegal", ""), nil, codes.OK},
+		{"checkrequired", "viewer_and_editor", sub("user", "missingrolegal", ""), nil, codes.OK},
+		{"checkrequired", "viewer_and_editor", sub("user", "multiroleguy", ""), []string{"specialplan"}, codes.OK},
+		{"checkrequired", "viewer", sub("user", "auditor", ""), []string{"companyplan", "masterplan"}, codes.OK},
+		{"checkrequired", "viewer", sub("user", "*", ""), nil, codes.InvalidArgument},
+		{"candidates", "viewer", sub("user", "auditor", ""), nil, codes.InvalidArgument},
+		{"unknown", "viewer", sub("user", "auditor", ""), nil, codes.InvalidArgument},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(fmt.Sprintf("%s document::%s from %s", tc.mode, tc.permission, tc.subject.Object.ObjectId), func(t *testing.T) {
+			require := require.New(t)
+			conn, cleanup, revision := testserver.NewTestServer(require, 0, memdb.DisableGC, true, tf.StandardDatastoreWithData)
+			client := v1.NewPermissionsServiceClient(conn)
+			t.Cleanup(cleanup)
+
+			ctx := metadata.AppendToOutgoingContext(context.Background(), string(v1svc.LookupResourcesModeRequestKey), tc.mode)
+			lookupClient, err := client.LookupResources(ctx, &v1.LookupResourcesRequest{
+				ResourceObjectType: "document",
+				Permission:         tc.permission,
+				Subject:            tc.subject,
+				Consistency: &v1.Consistency{
+					Requirement: &v1.Consistency_AtLeastAsFresh{
+						AtLeastAsFresh: zedtoken.NewFromRevision(revision),
+					},
+				},
+			})
+			require.NoError(err)
+
+			var resolvedObjectIds []string
+			for {
+				resp, err := lookupClient.Recv()
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				if tc.expectedErrorCode != codes.OK {
+					grpcutil.RequireStatus(t, tc.expectedErrorCode, err)
+					return
+				}
+
+				require.NoError(err)
+				resolvedObjectIds = append(resolvedObjectIds, resp.ResourceObjectId)
+			}
+			require.Equal(codes.OK, tc.expectedErrorCode)
+
+			sort.Strings(resolvedObjectIds)
+			require.Equal(tc.expectedObjectIds, resolvedObjectIds)
+		})
+	}
+}
+
 func TestExpand(t *testing.T) {
 	testCases := []struct {
 		startObjectType    string
@@ -518,6 +582,101 @@ func TestExpand(t *testing.T) {
 	}
 }
 
+func TestExpandRecursive(t *testing.T) {
+	testCases := []struct {
+		name                  string
+		header                []string
+		expandRelatedCount    int
+		expectedContinuations []string
+		expectedErrorCode     codes.Code
+	}{
+		{"shallow", nil, 3, nil, codes.OK},
+		{"recursive", []string{string(v1svc.ExpandModeRequestKey), "recursive"}, 4, nil, codes.OK},
+		{
+			"recursive with no depth",
+			[]string{string(v1svc.ExpandModeRequestKey), "recursive", string(v1svc.ExpandMaxDepthRequestKey), "0"},
+			3,
+			[]string{"folder:auditors#viewer"},
+			codes.OK,
+		},
+		{
+			"recursive with a single node",
+			[]string{string(v1svc.ExpandModeRequestKey), "recursive", string(v1svc.ExpandMaxNodesRequestKey), "1"},
+			3,
+			[]string{"folder:auditors#viewer"},
+			codes.OK,
+		},
+		{"unknown mode", []string{string(v1svc.ExpandModeRequestKey), "sideways"}, 0, nil, codes.InvalidArgument},
+		{"invalid max nodes", []string{string(v1svc.ExpandMaxNodesRequestKey), "0"}, 0, nil, codes.InvalidArgument},
+		{"invalid continuation", []string{string(v1svc.ExpandContinuationRequestKey), "notatoken"}, 0, nil, codes.InvalidArgument},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			require := require.New(t)
+			conn, cleanup, revision := testserver.NewTestServer(require, 0, memdb.DisableGC, true, tf.StandardDatastoreWithData)
+			client := v1.NewPermissionsServiceClient(conn)
+			t.Cleanup(cleanup)
+
+			ctx := metadata.AppendToOutgoingContext(context.Background(), tc.header...)
+			expand := func(ctx context.Context, objectID string, trailer *metadata.MD) (*v1.ExpandPermissionTreeResponse, error) {
+				return client.ExpandPermissionTree(ctx, &v1.ExpandPermissionTreeRequest{
+					Resource: &v1.ObjectReference{
+						ObjectType: "folder",
+						ObjectId:   objectID,
+					},
+					Permission: "viewer",
+					Consistency: &v1.Consistency{
+						Requirement: &v1.Consistency_AtLeastAsFresh{
+							AtLeastAsFresh: zedtoken.NewFromRevision(revision),
+						},
+					},
+				}, grpc.Trailer(trailer))
+			}
+
+			var trailer metadata.MD
+			expanded, err := expand(ctx, "company", &trailer)
+			if tc.expectedErrorCode != codes.OK {
+				grpcutil.RequireStatus(t, tc.expectedErrorCode, err)
+				return
+			}
+			require.NoError(err)
+			require.Equal(tc.expandRelatedCount, countLeafs(expanded.TreeRoot))
+
+			continuations := trailer.Get(string(v1svc.ExpandContinuationsTrailerKey))
+			require.Len(continuations, len(tc.expectedContinuations))
+			for index, continuation := range continuations {
+				subjectSet, token, ok := strings.Cut(continuation, " ")
+				require.True(ok)
+				require.Equal(tc.expectedContinuations[index], subjectSet)
+
+				// The continuation token only continues the expansion of its own subject set.
+				continuationCtx := metadata.AppendToOutgoingContext(context.Background(), string(v1svc.ExpandContinuationRequestKey), token)
+				_, err := expand(continuationCtx, "company", &metadata.MD{})
+				grpcutil.RequireStatus(t, codes.InvalidArgument, err)
+
+				// Nor can its revision be changed.
+				decodedBytes, err := base64.StdEncoding.DecodeString(token)
+				require.NoError(err)
+				decoded := &impl.DecodedExpandContinuation{}
+				require.NoError(proto.Unmarshal(decodedBytes, decoded))
+				decoded.Revision = "1"
+				tampered, err := proto.Marshal(decoded)
+				require.NoError(err)
+				tamperedCtx := metadata.AppendToOutgoingContext(context.Background(), string(v1svc.ExpandContinuationRequestKey), base64.StdEncoding.EncodeToString(tampered))
+				_, err = expand(tamperedCtx, tuple.ParseONR(subjectSet).ObjectId, &metadata.MD{})
+				grpcutil.RequireStatus(t, codes.InvalidArgument, err)
+
+				continued, err := expand(continuationCtx, tuple.ParseONR(subjectSet).ObjectId, &metadata.MD{})
+				require.NoError(err)
+				require.Equal(1, countLeafs(continued.TreeRoot))
+				require.Equal(expanded.ExpandedAt.Token, continued.ExpandedAt.Token)
+			}
+		})
+	}
+}
+
 func countLeafs(node *v1.PermissionRelationshipTree) int {
 	switch t := node.TreeType.(type) {
 	case *v1.PermissionRelationshipTree_Leaf:
@@ -0,0 +1,195 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/shopspring/decimal"
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/sync/semaphore"
+
+	"github.com/authzed/spicedb/internal/dispatch"
+	core "github.com/authzed/spicedb/pkg/proto/core/v1"
+	dispatchv1 "github.com/authzed/spicedb/pkg/proto/dispatch/v1"
+)
+
+const maxConcurrentReachableResourcesChecks = 10
+
+// reachableResourceFunc is invoked with each resource found by streamReachableResources, and
+// whether it definitely has permission.
+type reachableResourceFunc func(objectID string, definite bool) error
+
+// streamReachableResources dispatches a reachable resources request for the resources of the
+// given type which are reachable from the subject, invoking found for each resource as it is
+// found. Resources reached through an intersection or exclusion are checked concurrently if
+// checkRequired is set, such that found is only invoked for those which have permission, and
+// are otherwise passed to found as possibly allowed.
+//
+// Found is invoked at most once for each resource, and never concurrently. The metadata of
+// the dispatches performed is added to metadata.
+func streamReachableResources(
+	ctx context.Context,
+	d dispatch.Dispatcher,
+	atRevision decimal.Decimal,
+	depth uint32,
+	resourceType *core.RelationReference,
+	subject *core.ObjectAndRelation,
+	checkRequired bool,
+	metadata *dispatchMetadata,
+	found reachableResourceFunc,
+) error {
+	results := &reachableResults{
+		states: map[string]reachableResultState{},
+		found:  found,
+	}
+
+	cancelCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	checks, checksCtx := errgroup.WithContext(cancelCtx)
+	sem := semaphore.NewWeighted(maxConcurrentReachableResourcesChecks)
+
+	err := d.DispatchReachableResources(&dispatchv1.DispatchReachableResourcesRequest{
+		Metadata: &dispatchv1.ResolverMeta{
+			AtRevision:     atRevision.String(),
+			DepthRemaining: depth,
+		},
+		ObjectRelation: resourceType,
+		Subject:        subject,
+	}, dispatch.NewHandlingDispatchStream(checksCtx, func(result *dispatchv1.DispatchReachableResourcesResponse) error {
+		metadata.add(result.Metadata)
+
+		resource := result.Resource.Resource
+		if resource.Namespace != resourceType.Namespace {
+			return fmt.Errorf("got invalid resolved object %v (expected %v)", resource.Namespace, resourceType.Namespace)
+		}
+
+		if result.Resource.ResultStatus == dispatchv1.ReachableResource_HAS_PERMISSION {
+			return results.sendDefinite(resource.ObjectId)
+		}
+
+		if !checkRequired {
+			return results.sendPossiblyAllowed(resource.ObjectId)
+		}
+
+		if !results.queueCheck(resource.ObjectId) {
+			return nil
+		}
+
+		if err := sem.Acquire(checksCtx, 1); err != nil {
+			return err
+		}
+
+		checks.Go(func() error {
+			defer sem.Release(1)
+
+			checkResp, err := d.DispatchCheck(checksCtx, &dispatchv1.DispatchCheckRequest{
+				Metadata: &dispatchv1.ResolverMeta{
+					AtRevision:     atRevision.String(),
+					DepthRemaining: depth,
+				},
+				ObjectAndRelation: resource,
+				Subject:           subject,
+			})
+			metadata.add(checkResp.GetMetadata())
+			if err != nil {
+				return err
+			}
+
+			if checkResp.Membership == dispatchv1.DispatchCheckResponse_MEMBER {
+				return results.sendDefinite(resource.ObjectId)
+			}
+			return nil
+		})
+		return nil
+	}))
+
+	if err != nil {
+		cancel()
+	}
+	checksErr := checks.Wait()
+	if err != nil {
+		return err
+	}
+	return checksErr
+}
+
+type reachableResultState int
+
+const (
+	reachableResultQueuedForCheck reachableResultState = iota
+	reachableResultSentPossiblyAllowed
+	reachableResultSentDefinite
+)
+
+// reachableResults tracks the resources found by streamReachableResources, such that each
+// resource is sent at most once, regardless of how many times it is reached.
+type reachableResults struct {
+	sync.Mutex
+
+	states map[string]reachableResultState
+	found  reachableResourceFunc
+}
+
+// sendDefinite sends the resource, if not already sent, and marks it as having permission.
+func (rr *reachableResults) sendDefinite(objectID string) error {
+	rr.Lock()
+	defer rr.Unlock()
+
+	state, ok := rr.states[objectID]
+	rr.states[objectID] = reachableResultSentDefinite
+	if ok && state != reachableResultQueuedForCheck {
+		return nil
+	}
+	return rr.found(objectID, true)
+}
+
+// sendPossiblyAllowed sends the resource, if not already sent.
+func (rr *reachableResults) sendPossiblyAllowed(objectID string) error {
+	rr.Lock()
+	defer rr.Unlock()
+
+	if _, ok := rr.states[objectID]; ok {
+		return nil
+	}
+	rr.states[objectID] = reachableResultSentPossiblyAllowed
+	return rr.found(objectID, false)
+}
+
+// queueCheck returns whether the resource should be checked, which is the case if it has
+// neither been sent nor already queued for a check.
+func (rr *reachableResults) queueCheck(objectID string) bool {
+	rr.Lock()
+	defer rr.Unlock()
+
+	if _, ok := rr.states[objectID]; ok {
+		return false
+	}
+	rr.states[objectID] = reachableResultQueuedForCheck
+	return true
+}
+
+// dispatchMetadata accumulates the metadata of the dispatches performed for a request.
+type dispatchMetadata struct {
+	sync.Mutex
+	metadata *dispatchv1.ResponseMeta
+}
+
+func newDispatchMetadata() *dispatchMetadata {
+	return &dispatchMetadata{metadata: &dispatchv1.ResponseMeta{}}
+}
+
+func (dm *dispatchMetadata) add(metadata *dispatchv1.ResponseMeta) {
+	if metadata == nil {
+		return
+	}
+
+	dm.Lock()
+	defer dm.Unlock()
+	dm.metadata.DispatchCount += metadata.DispatchCount
+	dm.metadata.CachedDispatchCount += metadata.CachedDispatchCount
+	if metadata.DepthRequired > dm.metadata.DepthRequired {
+		dm.metadata.DepthRequired = metadata.DepthRequired
+	}
+}
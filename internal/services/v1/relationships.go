@@ -28,14 +28,18 @@ import (
 	"github.com/authzed/spicedb/pkg/zedtoken"
 )
 
-// NewPermissionsServer creates a PermissionsServiceServer instance.
+// NewPermissionsServer creates a PermissionsServiceServer instance. The continuation key is
+// the secret with which the continuation tokens of recursive expansions are signed, and must
+// be shared by every server which may receive the continuation of another's expansion.
 func NewPermissionsServer(
 	dispatch dispatch.Dispatcher,
 	defaultDepth uint32,
+	continuationKey []byte,
 ) v1.PermissionsServiceServer {
 	return &permissionServer{
-		dispatch:     dispatch,
-		defaultDepth: defaultDepth,
+		dispatch:        dispatch,
+		defaultDepth:    defaultDepth,
+		continuationKey: continuationKey,
 		WithServiceSpecificInterceptors: shared.WithServiceSpecificInterceptors{
 			Unary: grpcmw.ChainUnaryServer(
 				grpcvalidate.UnaryServerInterceptor(),
@@ -55,18 +59,19 @@ type permissionServer struct {
 	v1.UnimplementedPermissionsServiceServer
 	shared.WithServiceSpecificInterceptors
 
-	dispatch     dispatch.Dispatcher
-	defaultDepth uint32
+	dispatch        dispatch.Dispatcher
+	defaultDepth    uint32
+	continuationKey []byte
 }
 
-func (ps *permissionServer) checkFilterComponent(ctx context.Context, objectType, optionalRelation string, ds datastore.Reader) error {
+func checkFilterComponent(ctx context.Context, objectType, optionalRelation string, ds datastore.Reader) error {
 	relationToTest := stringz.DefaultEmpty(optionalRelation, datastore.Ellipsis)
 	allowEllipsis := optionalRelation == ""
 	return namespace.CheckNamespaceAndRelation(ctx, objectType, relationToTest, allowEllipsis, ds)
 }
 
-func (ps *permissionServer) checkFilterNamespaces(ctx context.Context, filter *v1.RelationshipFilter, ds datastore.Reader) error {
-	if err := ps.checkFilterComponent(ctx, filter.ResourceType, filter.OptionalRelation, ds); err != nil {
+func checkFilterNamespaces(ctx context.Context, filter *v1.RelationshipFilter, ds datastore.Reader) error {
+	if err := checkFilterComponent(ctx, filter.ResourceType, filter.OptionalRelation, ds); err != nil {
 		return err
 	}
 
@@ -75,7 +80,7 @@ func (ps *permissionServer) checkFilterNamespaces(ctx context.Context, filter *v
 		if subjectFilter.OptionalRelation != nil {
 			subjectRelation = subjectFilter.OptionalRelation.Relation
 		}
-		if err := ps.checkFilterComponent(ctx, subjectFilter.SubjectType, subjectRelation, ds); err != nil {
+		if err := checkFilterComponent(ctx, subjectFilter.SubjectType, subjectRelation, ds); err != nil {
 			return err
 		}
 	}
@@ -88,7 +93,7 @@ func (ps *permissionServer) ReadRelationships(req *v1.ReadRelationshipsRequest,
 	atRevision, revisionReadAt := consistency.MustRevisionFromContext(ctx)
 	ds := datastoremw.MustFromContext(ctx).SnapshotReader(atRevision)
 
-	if err := ps.checkFilterNamespaces(ctx, req.RelationshipFilter, ds); err != nil {
+	if err := checkFilterNamespaces(ctx, req.RelationshipFilter, ds); err != nil {
 		return rewritePermissionsError(ctx, err)
 	}
 
@@ -143,91 +148,14 @@ func (ps *permissionServer) WriteRelationships(ctx context.Context, req *v1.Writ
 
 	revision, err := ds.ReadWriteTx(ctx, func(ctx context.Context, rwt datastore.ReadWriteTransaction) error {
 		for _, precond := range req.OptionalPreconditions {
-			if err := ps.checkFilterNamespaces(ctx, precond.Filter, rwt); err != nil {
+			if err := checkFilterNamespaces(ctx, precond.Filter, rwt); err != nil {
 				return err
 			}
 		}
 		for _, update := range req.Updates {
-			if err := tuple.ValidateResourceID(update.Relationship.Resource.ObjectId); err != nil {
+			if err := validateRelationshipWrite(ctx, update.Relationship, rwt); err != nil {
 				return err
 			}
-
-			if err := tuple.ValidateSubjectID(update.Relationship.Subject.Object.ObjectId); err != nil {
-				return err
-			}
-
-			if err := namespace.CheckNamespaceAndRelation(
-				ctx,
-				update.Relationship.Resource.ObjectType,
-				update.Relationship.Relation,
-				false,
-				rwt,
-			); err != nil {
-				return err
-			}
-
-			if err := namespace.CheckNamespaceAndRelation(
-				ctx,
-				update.Relationship.Subject.Object.ObjectType,
-				stringz.DefaultEmpty(update.Relationship.Subject.OptionalRelation, datastore.Ellipsis),
-				true,
-				rwt,
-			); err != nil {
-				return err
-			}
-
-			_, ts, err := namespace.ReadNamespaceAndTypes(
-				ctx,
-				update.Relationship.Resource.ObjectType,
-				rwt,
-			)
-			if err != nil {
-				return err
-			}
-
-			if ts.IsPermission(update.Relationship.Relation) {
-				return status.Errorf(
-					codes.InvalidArgument,
-					"cannot write a relationship to permission %s",
-					update.Relationship.Relation,
-				)
-			}
-
-			if update.Relationship.Subject.Object.ObjectId == tuple.PublicWildcard {
-				isAllowed, err := ts.IsAllowedPublicNamespace(
-					update.Relationship.Relation,
-					update.Relationship.Subject.Object.ObjectType)
-				if err != nil {
-					return err
-				}
-
-				if isAllowed != namespace.PublicSubjectAllowed {
-					return status.Errorf(
-						codes.InvalidArgument,
-						"wildcard subjects of type %s are not allowed on %v",
-						update.Relationship.Subject.Object.ObjectType,
-						tuple.StringObjectRef(update.Relationship.Resource),
-					)
-				}
-			} else {
-				isAllowed, err := ts.IsAllowedDirectRelation(
-					update.Relationship.Relation,
-					update.Relationship.Subject.Object.ObjectType,
-					stringz.DefaultEmpty(update.Relationship.Subject.OptionalRelation, datastore.Ellipsis),
-				)
-				if err != nil {
-					return err
-				}
-
-				if isAllowed == namespace.DirectRelationNotValid {
-					return status.Errorf(
-						codes.InvalidArgument,
-						"subject %s is not allowed for the resource %s",
-						tuple.StringSubjectRef(update.Relationship.Subject),
-						tuple.StringObjectRef(update.Relationship.Resource),
-					)
-				}
-			}
 		}
 
 		usagemetrics.SetInContext(ctx, &dispatchv1.ResponseMeta{
@@ -250,11 +178,97 @@ func (ps *permissionServer) WriteRelationships(ctx context.Context, req *v1.Writ
 	}, nil
 }
 
+// validateRelationshipWrite checks that the relationship may be written under the schema.
+func validateRelationshipWrite(ctx context.Context, rel *v1.Relationship, ds datastore.Reader) error {
+	if err := tuple.ValidateResourceID(rel.Resource.ObjectId); err != nil {
+		return err
+	}
+
+	if err := tuple.ValidateSubjectID(rel.Subject.Object.ObjectId); err != nil {
+		return err
+	}
+
+	if err := namespace.CheckNamespaceAndRelation(
+		ctx,
+		rel.Resource.ObjectType,
+		rel.Relation,
+		false,
+		ds,
+	); err != nil {
+		return err
+	}
+
+	if err := namespace.CheckNamespaceAndRelation(
+		ctx,
+		rel.Subject.Object.ObjectType,
+		stringz.DefaultEmpty(rel.Subject.OptionalRelation, datastore.Ellipsis),
+		true,
+		ds,
+	); err != nil {
+		return err
+	}
+
+	_, ts, err := namespace.ReadNamespaceAndTypes(
+		ctx,
+		rel.Resource.ObjectType,
+		ds,
+	)
+	if err != nil {
+		return err
+	}
+
+	if ts.IsPermission(rel.Relation) {
+		return status.Errorf(
+			codes.InvalidArgument,
+			"cannot write a relationship to permission %s",
+			rel.Relation,
+		)
+	}
+
+	if rel.Subject.Object.ObjectId == tuple.PublicWildcard {
+		isAllowed, err := ts.IsAllowedPublicNamespace(
+			rel.Relation,
+			rel.Subject.Object.ObjectType)
+		if err != nil {
+			return err
+		}
+
+		if isAllowed != namespace.PublicSubjectAllowed {
+			return status.Errorf(
+				codes.InvalidArgument,
+				"wildcard subjects of type %s are not allowed on %v",
+				rel.Subject.Object.ObjectType,
+				tuple.StringObjectRef(rel.Resource),
+			)
+		}
+	} else {
+		isAllowed, err := ts.IsAllowedDirectRelation(
+			rel.Relation,
+			rel.Subject.Object.ObjectType,
+			stringz.DefaultEmpty(rel.Subject.OptionalRelation, datastore.Ellipsis),
+		)
+		if err != nil {
+			return err
+		}
+
+		if isAllowed == namespace.DirectRelationNotValid {
+			return status.Errorf(
+				codes.InvalidArgument,
+				"subject %s is not allowed for the resource %s",
+				tuple.StringSubjectRef(rel.Subject),
+				tuple.StringObjectRef(rel.Resource),
+			)
+		}
+	}
+
+	return nil
+}
+
 func (ps *permissionServer) DeleteRelationships(ctx context.Context, req *v1.DeleteRelationshipsRequest) (*v1.DeleteRelationshipsResponse, error) {
 	ds := datastoremw.MustFromContext(ctx)
 
 	revision, err := ds.ReadWriteTx(ctx, func(ctx context.Context, rwt datastore.ReadWriteTransaction) error {
-		if err := ps.checkFilterNamespaces(ctx, req.RelationshipFilter, rwt); err != nil {
+		if err := checkFilterNamespaces(ctx, req.RelationshipFilter, rwt); err != nil {
 			return err
 		}
 
@@ -290,6 +304,9 @@ func rewritePermissionsError(ctx context.Context, err error) error {
 	case errors.As(err, &shared.ErrPreconditionFailed{}):
 		return status.Errorf(codes.FailedPrecondition, "failed precondition: %s", err)
 
+	case errors.As(err, &dispatch.ErrBudgetExhausted{}):
+		return status.Errorf(codes.ResourceExhausted, "%s", err)
+
 	case errors.As(err, &graph.ErrInvalidArgument{}):
 		return status.Errorf(codes.InvalidArgument, "%s", err)
 
@@ -302,6 +319,9 @@ func rewritePermissionsError(ctx context.Context, err error) error {
 	case errors.As(err, &datastore.ErrReadOnly{}):
 		return serviceerrors.ErrServiceReadOnly
 
+	case errors.As(err, &datastore.ErrHistoryUnavailable{}):
+		return status.Errorf(codes.FailedPrecondition, "failed precondition: %s", err)
+
 	case errors.As(err, &graph.ErrRelationMissingTypeInfo{}):
 		return status.Errorf(codes.FailedPrecondition, "failed precondition: %s", err)
 
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
 
@@ -69,6 +70,18 @@ func (vd validatingDatastore) Statistics(ctx context.Context) (datastore.Stats,
 	return vd.delegate.Statistics(ctx)
 }
 
+func (vd validatingDatastore) QueryRelationshipsAsOf(
+	ctx context.Context,
+	asOf time.Time,
+	filter *v1.RelationshipFilter,
+) (datastore.RelationshipIterator, error) {
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+
+	return datastore.QueryRelationshipsAsOf(ctx, vd.delegate, asOf, filter)
+}
+
 type validatingSnapshotReader struct {
 	delegate datastore.Reader
 }
@@ -143,6 +156,14 @@ func (vsr validatingSnapshotReader) ReverseQueryRelationships(ctx context.Contex
 	return vsr.delegate.ReverseQueryRelationships(ctx, subjectFilter, opts...)
 }
 
+func (vsr validatingSnapshotReader) CountRelationships(ctx context.Context, filter *v1.RelationshipFilter) (uint64, error) {
+	if err := filter.Validate(); err != nil {
+		return 0, err
+	}
+
+	return vsr.delegate.CountRelationships(ctx, filter)
+}
+
 type validatingReadWriteTransaction struct {
 	validatingSnapshotReader
 	delegate datastore.ReadWriteTransaction
@@ -182,8 +203,29 @@ func (vrwt validatingReadWriteTransaction) DeleteRelationships(filter *v1.Relati
 	return vrwt.delegate.DeleteRelationships(filter)
 }
 
+func (vrwt validatingReadWriteTransaction) BulkLoad(ctx context.Context, source datastore.BulkWriteRelationshipSource) (uint64, error) {
+	return datastore.BulkLoad(ctx, vrwt.delegate, validatingBulkSource{source})
+}
+
+type validatingBulkSource struct {
+	delegate datastore.BulkWriteRelationshipSource
+}
+
+func (vbs validatingBulkSource) Next(ctx context.Context) (*v1.Relationship, error) {
+	rel, err := vbs.delegate.Next(ctx)
+	if err != nil || rel == nil {
+		return rel, err
+	}
+
+	if err := rel.Validate(); err != nil {
+		return nil, err
+	}
+	return rel, nil
+}
+
 var (
 	_ datastore.Datastore            = validatingDatastore{}
 	_ datastore.Reader               = validatingSnapshotReader{}
 	_ datastore.ReadWriteTransaction = validatingReadWriteTransaction{}
+	_ datastore.BulkLoader           = validatingReadWriteTransaction{}
 )
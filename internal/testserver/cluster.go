@@ -133,9 +133,29 @@ func (r *SafeManualResolver) ResolveNow(options resolver.ResolveNowOptions) {
 // Close implements the resolver.Resolver interface
 func (r *SafeManualResolver) Close() {}
 
+// ClusterOption is a function-style option for configuring a test cluster.
+type ClusterOption func(*clusterOptions)
+
+type clusterOptions struct {
+	multiplexing bool
+}
+
+// WithDispatchMultiplexing multiplexes the requests dispatched between the nodes of the
+// cluster over a single stream to each node.
+func WithDispatchMultiplexing() ClusterOption {
+	return func(opts *clusterOptions) {
+		opts.multiplexing = true
+	}
+}
+
 // TestClusterWithDispatch creates a cluster with `size` nodes
 // The cluster has a real dispatch stack that uses bufconn grpc connections
-func TestClusterWithDispatch(t testing.TB, size uint, ds datastore.Datastore) ([]*grpc.ClientConn, func()) {
+func TestClusterWithDispatch(t testing.TB, size uint, ds datastore.Datastore, options ...ClusterOption) ([]*grpc.ClientConn, func()) {
+	var opts clusterOptions
+	for _, fn := range options {
+		fn(&opts)
+	}
+
 	// each cluster gets a unique prefix since grpc resolution is process-global
 	prefix := getPrefix(t)
 
@@ -154,11 +174,19 @@ func TestClusterWithDispatch(t testing.TB, size uint, ds datastore.Datastore) ([
 	cancelFuncs := make([]func(), 0, size)
 
 	for i := uint(0); i < size; i++ {
-		dispatcher, err := combineddispatch.NewDispatcher(
-			combineddispatch.UpstreamAddr("test://"+prefix),
+		var router *hashbalancer.Router
+		serviceConfig := hashbalancer.BalancerServiceConfig
+		if opts.multiplexing {
+			router = hashbalancer.NewRouter()
+			cancelFuncs = append(cancelFuncs, router.Close)
+			serviceConfig = router.ServiceConfig("")
+		}
+
+		dispatchOptions := []combineddispatch.Option{
+			combineddispatch.UpstreamAddr("test://" + prefix),
 			combineddispatch.PrometheusSubsystem(fmt.Sprintf("%s_%d_client_dispatch", prefix, i)),
 			combineddispatch.GrpcDialOpts(
-				grpc.WithDefaultServiceConfig(hashbalancer.BalancerServiceConfig),
+				grpc.WithDefaultServiceConfig(serviceConfig),
 				grpc.WithContextDialer(func(ctx context.Context, s string) (net.Conn, error) {
 					// it's possible grpc tries to dial before we have set the
 					// buffconn dialers, we have to return a "TempError" so that
@@ -173,7 +201,12 @@ func TestClusterWithDispatch(t testing.TB, size uint, ds datastore.Datastore) ([
 					return dialers[i](ctx, s)
 				}),
 			),
-		)
+		}
+		if router != nil {
+			dispatchOptions = append(dispatchOptions, combineddispatch.Multiplexing(router))
+		}
+
+		dispatcher, err := combineddispatch.NewDispatcher(dispatchOptions...)
 		require.NoError(t, err)
 
 		srv, err := server.NewConfigWithOptions(
@@ -196,6 +229,7 @@ func TestClusterWithDispatch(t testing.TB, size uint, ds datastore.Datastore) ([
 				Network: util.BufferedNetwork,
 			}),
 			server.WithDispatchClusterMetricsPrefix(fmt.Sprintf("%s_%d_dispatch", prefix, i)),
+			server.WithEnableExperimentalAPI(true),
 		).Complete()
 		require.NoError(t, err)
 
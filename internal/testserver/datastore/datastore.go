@@ -60,6 +60,8 @@ func RunDatastoreEngineWithBridge(t testing.TB, engine string, bridgeNetworkName
 		return RunMySQLForTesting(t, bridgeNetworkName)
 	case "spanner":
 		return RunSpannerForTesting(t, bridgeNetworkName)
+	case "sqlite":
+		return RunSQLiteForTesting(t, bridgeNetworkName)
 	default:
 		t.Fatalf("found missing engine for RunDatastoreEngine: %s", engine)
 		return nil
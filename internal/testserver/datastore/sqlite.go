@@ -0,0 +1,42 @@
+package datastore
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/authzed/spicedb/internal/datastore/sqlite/migrations"
+	"github.com/authzed/spicedb/pkg/datastore"
+	"github.com/authzed/spicedb/pkg/migrate"
+)
+
+type sqliteTester struct {
+	dir     string
+	dbCount uint32
+}
+
+// RunSQLiteForTesting returns a RunningEngineForTest for the SQLite driver, with each
+// logical database stored as a file in a temporary directory.
+func RunSQLiteForTesting(t testing.TB, bridgeNetworkName string) RunningEngineForTest {
+	require.Equal(t, "", bridgeNetworkName, "sqlite datastore does not support bridge networking")
+	return &sqliteTester{dir: t.TempDir()}
+}
+
+func (st *sqliteTester) NewDatabase(t testing.TB) string {
+	dbNum := atomic.AddUint32(&st.dbCount, 1)
+	return filepath.Join(st.dir, fmt.Sprintf("db%d.sqlite", dbNum))
+}
+
+func (st *sqliteTester) NewDatastore(t testing.TB, initFunc InitFunc) datastore.Datastore {
+	path := st.NewDatabase(t)
+
+	driver, err := migrations.NewSQLiteDriver(path)
+	require.NoError(t, err, "failed to create migration driver: %s", err)
+	require.NoError(t, migrations.Manager.Run(driver, migrate.Head, migrate.LiveRun), "failed to run migration")
+	require.NoError(t, driver.Close())
+
+	return initFunc("sqlite", path)
+}
@@ -36,6 +36,7 @@ func NewTestServer(require *require.Assertions,
 			Enabled: true,
 		}),
 		server.WithSchemaPrefixesRequired(schemaPrefixRequired),
+		server.WithEnableExperimentalAPI(true),
 		server.WithGRPCAuthFunc(func(ctx context.Context) (context.Context, error) {
 			return ctx, nil
 		}),
@@ -1,13 +1,22 @@
 package balancer
 
 import (
-	"math/rand"
+	"context"
+	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/benbjohnson/clock"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 	"google.golang.org/grpc/balancer"
 	"google.golang.org/grpc/balancer/base"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/grpclog"
+	_ "google.golang.org/grpc/health" // enables client-side health checking
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/status"
 
 	"github.com/authzed/spicedb/pkg/consistent"
 )
@@ -25,25 +34,143 @@ const (
 	// CtxKey is the key for the grpc request's context.Context which points to
 	// the key to hash for the request. The value it points to must be []byte
 	CtxKey ctxKey = "requestKey"
+
+	// CtxAttemptKey is the key for the grpc request's context.Context which points
+	// to the attempt number of the request, as set by WithAttempt. The value it
+	// points to must be uint8.
+	CtxAttemptKey ctxKey = "requestAttempt"
+
+	// CtxMemberKey is the key for the grpc request's context.Context which points
+	// to the member to which the request must be sent, as set by WithMember. The
+	// value it points to must be a string.
+	CtxMemberKey ctxKey = "requestMember"
+
+	// breakerFailureThreshold is the number of consecutive failures after which
+	// requests are routed around a member.
+	breakerFailureThreshold = 5
+
+	// breakerCooldown is how long requests are routed around a member once its
+	// circuit breaker has been tripped.
+	breakerCooldown = 10 * time.Second
 )
 
+// HealthCheckingBalancerServiceConfig returns a service config that sets the default
+// balancer to the consistent-hashring balancer, and only includes members for which the
+// given service is reported as serving by the standard gRPC health service.
+func HealthCheckingBalancerServiceConfig(serviceName string) string {
+	return fmt.Sprintf(`{"loadBalancingPolicy":"consistent-hashring","healthCheckConfig":{"serviceName":%q}}`, serviceName)
+}
+
+// WithMember returns a context indicating that the request must be sent to the member with
+// the given key, as returned by Router.Route, rather than to a member chosen by its request key.
+func WithMember(ctx context.Context, member string) context.Context {
+	return context.WithValue(ctx, CtxMemberKey, member)
+}
+
+// WithAttempt returns a context indicating that the request is a further attempt
+// of an earlier request for the same key, such as a hedged or failover request.
+// Attempts after the first are sent to the members following the request's
+// chosen members on the hashring, rather than to the chosen members themselves.
+func WithAttempt(ctx context.Context, attempt uint8) context.Context {
+	return context.WithValue(ctx, CtxAttemptKey, attempt)
+}
+
 var logger = grpclog.Component("consistenthashring")
 
+// The rank of the member to which a request is sent, among the members found for its key.
+const (
+	primaryRank    = "primary"
+	spreadRank     = "spread"
+	subsequentRank = "subsequent"
+)
+
+var memberPicksCount = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "spicedb",
+	Subsystem: "dispatch_hashring",
+	Name:      "member_picks_total",
+	Help:      "total number of requests sent to members of the hashring, by the rank of the member among those found for the request's key: the primary member, another member across which the key is spread, or a subsequent member for further attempts",
+}, []string{"rank"})
+
+var memberOwnership = promauto.NewHistogram(prometheus.HistogramOpts{
+	Namespace: "spicedb",
+	Subsystem: "dispatch_hashring",
+	Name:      "member_ownership_ratio",
+	Help:      "distribution of the fraction of the hashring owned by each member, relative to the fraction it should own given its weight, observed whenever the hashring is built",
+	Buckets:   []float64{0.5, 0.75, 0.9, 1, 1.1, 1.25, 1.5, 2},
+})
+
+var ownershipImbalance = promauto.NewGauge(prometheus.GaugeOpts{
+	Namespace: "spicedb",
+	Subsystem: "dispatch_hashring",
+	Name:      "ownership_imbalance_ratio",
+	Help:      "ratio of the largest fraction of the hashring owned by a member to the mean fraction owned, adjusted for member weights",
+})
+
+type weightAttributeKey struct{}
+
+// WithWeight returns a copy of the address, weighted so that the member of the hashring
+// for the address is allotted virtual nodes in proportion to the weight. Addresses
+// without a weight are given a weight of 1. A weight advertised by the member itself, with
+// AdvertiseWeight, takes precedence.
+//
+// NOTE: The weight of an address is only read when a connection to it is first made.
+func WithWeight(addr resolver.Address, weight uint16) resolver.Address {
+	addr.BalancerAttributes = addr.BalancerAttributes.WithValue(weightAttributeKey{}, weight)
+	return addr
+}
+
+// WeightOf returns the weight of the address, as set by WithWeight.
+func WeightOf(addr resolver.Address) uint16 {
+	weight, ok := addr.BalancerAttributes.Value(weightAttributeKey{}).(uint16)
+	if !ok || weight == 0 {
+		return 1
+	}
+	return weight
+}
+
+// Option is a function-style option for configuring the consistent hashring balancer.
+type Option func(*consistentHashringPickerBuilder)
+
+// HotKeySpread sets the number of members following a key's chosen members on the hashring
+// across which requests for the key are also spread, once the key is found to be hot.
+// A spread of 0, the default, disables hot key detection.
+func HotKeySpread(spread uint8) Option {
+	return func(b *consistentHashringPickerBuilder) {
+		b.hotKeySpread = spread
+	}
+}
+
+// HotKeyShare sets the fraction of requests for which a key must account, over a window of
+// requests, to be considered hot. Defaults to DefaultHotKeyShare.
+func HotKeyShare(share float64) Option {
+	return func(b *consistentHashringPickerBuilder) {
+		b.hotKeys.share = share
+	}
+}
+
 // NewConsistentHashringBuilder creates a new balancer.Builder that
 // will create a consistent hashring balancer with the given config.
 // Before making a connection, register it with grpc with:
 // `balancer.Register(consistent.NewConsistentHashringBuilder(hasher, factor, spread))`
-func NewConsistentHashringBuilder(hasher consistent.HasherFunc, replicationFactor uint16, spread uint8) balancer.Builder {
-	return base.NewBalancerBuilder(
-		BalancerName,
-		&consistentHashringPickerBuilder{hasher: hasher, replicationFactor: replicationFactor, spread: spread},
-		base.Config{HealthCheck: true},
-	)
+func NewConsistentHashringBuilder(hasher consistent.HasherFunc, replicationFactor uint16, spread uint8, options ...Option) balancer.Builder {
+	pickerBuilder := &consistentHashringPickerBuilder{
+		hasher:            hasher,
+		replicationFactor: replicationFactor,
+		spread:            spread,
+		breakers:          newCircuitBreakers(breakerFailureThreshold, breakerCooldown, clock.New()),
+		hotKeys:           newHotKeyTracker(hasher, DefaultHotKeyShare, hotKeyWindow, hotKeyMinRequests),
+	}
+	for _, fn := range options {
+		fn(pickerBuilder)
+	}
+
+	return &hashringBalancerBuilder{pickerBuilder: pickerBuilder}
 }
 
 type subConnMember struct {
 	balancer.SubConn
-	key string
+	key    string
+	weight uint16
 }
 
 // Key implements consistent.Member
@@ -52,56 +179,272 @@ func (s subConnMember) Key() string {
 	return s.key
 }
 
-var _ consistent.Member = &subConnMember{}
+// Weight implements consistent.WeightedMember
+func (s subConnMember) Weight() uint16 {
+	return s.weight
+}
+
+var _ consistent.WeightedMember = &subConnMember{}
 
 type consistentHashringPickerBuilder struct {
 	hasher            consistent.HasherFunc
 	replicationFactor uint16
 	spread            uint8
+	hotKeySpread      uint8
+
+	// breakers and hotKeys are shared between all pickers built, so that their
+	// state survives changes to the set of ready connections.
+	breakers *circuitBreakers
+	hotKeys  *hotKeyTracker
+
+	// rings builds the hashring of each picker built for the connection, and rebuilds it
+	// whenever a member advertises a new weight.
+	rings *ringBuilder
+
+	// binding is the Router, if any, bound to the connection for which pickers are built.
+	binding *routerBinding
 }
 
 func (b *consistentHashringPickerBuilder) Build(info base.PickerBuildInfo) balancer.Picker {
 	logger.Infof("consistentHashringPicker: Build called with info: %v", info)
 	if len(info.ReadySCs) == 0 {
+		b.rings.attach(nil, nil)
+		b.binding.setPicker(nil)
 		return base.NewErrPicker(balancer.ErrNoSubConnAvailable)
 	}
-	hashring := consistent.NewHashring(b.hasher, b.replicationFactor)
-	for sc, scInfo := range info.ReadySCs {
-		if err := hashring.Add(subConnMember{
-			SubConn: sc,
-			key:     scInfo.Address.Addr + scInfo.Address.ServerName,
-		}); err != nil {
-			return base.NewErrPicker(err)
-		}
+
+	picker := &consistentHashringPicker{
+		spread:       b.spread,
+		hotKeySpread: b.hotKeySpread,
+		breakers:     b.breakers,
+		hotKeys:      b.hotKeys,
+		rings:        b.rings,
 	}
-	return &consistentHashringPicker{
-		hashring: hashring,
-		spread:   b.spread,
-		rand:     rand.New(rand.NewSource(time.Now().UnixNano())),
+	if err := b.rings.attach(picker, info.ReadySCs); err != nil {
+		b.binding.setPicker(nil)
+		return base.NewErrPicker(err)
 	}
+
+	b.binding.setPicker(picker)
+	return picker
 }
 
 type consistentHashringPicker struct {
-	sync.Mutex
-	hashring *consistent.Hashring
-	spread   uint8
-	rand     *rand.Rand
+	// ring holds the picker's current *pickerRing, which is replaced whenever a member
+	// advertises a new weight.
+	ring atomic.Value
+
+	spread       uint8
+	hotKeySpread uint8
+	breakers     *circuitBreakers
+	hotKeys      *hotKeyTracker
+	rings        *ringBuilder
+
+	// next rotates requests for each key across the members over which it is spread.
+	next uint32
+}
+
+// pickerRing is the hashring of a picker, along with its members by key.
+type pickerRing struct {
+	hashring    *consistent.Hashring
+	members     map[string]subConnMember
+	memberCount uint8
+}
+
+func (p *consistentHashringPicker) currentRing() *pickerRing {
+	return p.ring.Load().(*pickerRing)
 }
 
 func (p *consistentHashringPicker) Pick(info balancer.PickInfo) (balancer.PickResult, error) {
+	// Requests for a particular member, such as streams opened by a Router's user, are sent
+	// to that member without affecting its circuit breaker, as the failures of the requests
+	// routed over them are recorded individually.
+	if member, ok := info.Ctx.Value(CtxMemberKey).(string); ok {
+		chosen, ok := p.currentRing().members[member]
+		if !ok {
+			return balancer.PickResult{}, status.Errorf(codes.Unavailable, "member %s is not available", member)
+		}
+		return balancer.PickResult{SubConn: chosen.SubConn}, nil
+	}
+
 	key := info.Ctx.Value(CtxKey).([]byte)
-	members, err := p.hashring.FindN(key, p.spread)
+	attempt, _ := info.Ctx.Value(CtxAttemptKey).(uint8)
+
+	chosen, err := p.choose(key, attempt)
 	if err != nil {
 		return balancer.PickResult{}, err
 	}
 
-	// rand is not safe for concurrent use
-	p.Lock()
-	index := p.rand.Intn(int(p.spread))
-	p.Unlock()
-
-	chosen := members[index].(subConnMember)
 	return balancer.PickResult{
 		SubConn: chosen.SubConn,
+		Done: func(doneInfo balancer.DoneInfo) {
+			p.breakers.record(chosen.key, doneInfo.Err)
+			p.rings.observe(chosen.key, doneInfo.Trailer)
+		},
 	}, nil
 }
+
+// choose returns the member to which the given attempt of a request for the key is sent.
+func (p *consistentHashringPicker) choose(key []byte, attempt uint8) (subConnMember, error) {
+	ring := p.currentRing()
+	if p.spread > ring.memberCount {
+		return subConnMember{}, consistent.ErrNotEnoughMembers
+	}
+
+	// Requests for hot keys are spread across the members following the chosen members as
+	// well, so that no single member must serve all of them.
+	spread := int(p.spread)
+	if p.hotKeySpread > 0 && p.hotKeys.record(key) {
+		hotKeyPicksCount.Inc()
+		spread += int(p.hotKeySpread)
+	}
+
+	members, available, err := p.find(ring, key, spread+int(attempt))
+	if err != nil {
+		return subConnMember{}, err
+	}
+
+	// If every member has been tripped, there is nothing to route around.
+	if len(available) < int(p.spread) {
+		available = members
+	}
+
+	if spread > len(available) {
+		spread = len(available)
+	}
+
+	var index int
+	if attempt > 0 {
+		index = (spread - 1 + int(attempt)) % len(available)
+	} else {
+		index = int(atomic.AddUint32(&p.next, 1) % uint32(spread))
+	}
+
+	rank := subsequentRank
+	switch {
+	case index == 0:
+		rank = primaryRank
+	case index < spread:
+		rank = spreadRank
+	}
+	memberPicksCount.WithLabelValues(rank).Inc()
+
+	return available[index].(subConnMember), nil
+}
+
+// find returns the members for the key in ring order, along with those of them whose
+// circuit breakers have not been tripped. Only enough members are found for the given number
+// to be available, allowing for members which have been tripped, unless there are too few
+// members in total.
+func (p *consistentHashringPicker) find(ring *pickerRing, key []byte, needed int) ([]consistent.Member, []consistent.Member, error) {
+	count := needed + p.breakers.openCount()
+	for {
+		if count > int(ring.memberCount) {
+			count = int(ring.memberCount)
+		}
+
+		members, err := ring.hashring.FindN(key, uint8(count))
+		if err != nil {
+			return nil, nil, err
+		}
+
+		available := make([]consistent.Member, 0, len(members))
+		for _, member := range members {
+			if !p.breakers.isOpen(member.Key()) {
+				available = append(available, member)
+			}
+		}
+
+		// A breaker may have been tripped since they were counted, in which case the
+		// remaining members must be found as well.
+		if len(available) >= needed || count == int(ring.memberCount) {
+			return members, available, nil
+		}
+		count = int(ring.memberCount)
+	}
+}
+
+// circuitBreakers tracks the consecutive failures of each member, tripping a member's
+// breaker once its failures reach the threshold.
+type circuitBreakers struct {
+	failureThreshold int
+	cooldown         time.Duration
+	timeSource       clock.Clock
+
+	// open holds a map[string]time.Time of the members whose breakers have been tripped, to
+	// when they are tripped until. It is replaced rather than modified, so that it can be read
+	// for every request without locking.
+	open atomic.Value
+
+	sync.Mutex
+	failures map[string]int
+}
+
+func newCircuitBreakers(failureThreshold int, cooldown time.Duration, timeSource clock.Clock) *circuitBreakers {
+	cb := &circuitBreakers{
+		failureThreshold: failureThreshold,
+		cooldown:         cooldown,
+		timeSource:       timeSource,
+		failures:         map[string]int{},
+	}
+	cb.open.Store(map[string]time.Time{})
+	return cb
+}
+
+func (cb *circuitBreakers) isOpen(key string) bool {
+	openUntil, ok := cb.open.Load().(map[string]time.Time)[key]
+	return ok && cb.timeSource.Now().Before(openUntil)
+}
+
+// openCount returns the number of members whose breakers are currently tripped.
+func (cb *circuitBreakers) openCount() int {
+	open := cb.open.Load().(map[string]time.Time)
+	if len(open) == 0 {
+		return 0
+	}
+
+	now := cb.timeSource.Now()
+	count := 0
+	for _, openUntil := range open {
+		if now.Before(openUntil) {
+			count++
+		}
+	}
+	return count
+}
+
+func (cb *circuitBreakers) record(key string, err error) {
+	// Only failures indicating that the member itself is unhealthy are counted: errors
+	// such as cancelation are expected whenever a hedged request completes first.
+	if status.Code(err) != codes.Unavailable {
+		if err == nil {
+			cb.Lock()
+			delete(cb.failures, key)
+			cb.Unlock()
+		}
+		return
+	}
+
+	cb.Lock()
+	defer cb.Unlock()
+
+	cb.failures[key]++
+	if cb.failures[key] < cb.failureThreshold {
+		return
+	}
+
+	logger.Warningf("consistentHashringPicker: routing around member %s for %s", key, cb.cooldown)
+
+	// Breakers which have cooled down are dropped from the copy.
+	now := cb.timeSource.Now()
+	open := map[string]time.Time{key: now.Add(cb.cooldown)}
+	for other, openUntil := range cb.open.Load().(map[string]time.Time) {
+		if other != key && now.Before(openUntil) {
+			open[other] = openUntil
+		}
+	}
+	cb.open.Store(open)
+
+	// Once the cooldown has elapsed, a single further failure trips the breaker again.
+	cb.failures[key] = cb.failureThreshold - 1
+}
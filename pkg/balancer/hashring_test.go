@@ -0,0 +1,226 @@
+package balancer
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/cespare/xxhash/v2"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/balancer/base"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/status"
+
+	"github.com/authzed/spicedb/pkg/consistent"
+)
+
+var errUnavailable = status.Error(codes.Unavailable, "member restarting")
+
+func TestCircuitBreakers(t *testing.T) {
+	require := require.New(t)
+
+	mockTime := clock.NewMock()
+	breakers := newCircuitBreakers(3, time.Second, mockTime)
+
+	// Failures which do not indicate an unhealthy member are not counted.
+	for i := 0; i < 5; i++ {
+		breakers.record("a", status.Error(codes.Canceled, "hedged"))
+	}
+	require.False(breakers.isOpen("a"))
+
+	// A success resets the consecutive failures.
+	breakers.record("a", errUnavailable)
+	breakers.record("a", errUnavailable)
+	breakers.record("a", nil)
+	breakers.record("a", errUnavailable)
+	breakers.record("a", errUnavailable)
+	require.False(breakers.isOpen("a"))
+	require.Equal(0, breakers.openCount())
+
+	breakers.record("a", errUnavailable)
+	require.True(breakers.isOpen("a"))
+	require.False(breakers.isOpen("b"))
+	require.Equal(1, breakers.openCount())
+
+	// Once the cooldown has elapsed, the breaker is closed, but trips on the next failure.
+	mockTime.Add(time.Second)
+	require.False(breakers.isOpen("a"))
+	require.Equal(0, breakers.openCount())
+
+	breakers.record("a", errUnavailable)
+	require.True(breakers.isOpen("a"))
+
+	mockTime.Add(time.Second)
+	breakers.record("a", nil)
+	breakers.record("a", errUnavailable)
+	require.False(breakers.isOpen("a"))
+}
+
+type fakeSubConn struct {
+	balancer.SubConn
+}
+
+// readySubConns returns ready connections to each of the addresses.
+func readySubConns(addresses ...resolver.Address) map[balancer.SubConn]base.SubConnInfo {
+	readySCs := make(map[balancer.SubConn]base.SubConnInfo, len(addresses))
+	for _, address := range addresses {
+		readySCs[&fakeSubConn{}] = base.SubConnInfo{Address: address}
+	}
+	return readySCs
+}
+
+func newTestPicker(t *testing.T, spread uint8, breakers *circuitBreakers, readySCs map[balancer.SubConn]base.SubConnInfo) *consistentHashringPicker {
+	picker := &consistentHashringPicker{
+		spread:   spread,
+		breakers: breakers,
+		hotKeys:  newHotKeyTracker(xxhash.Sum64, DefaultHotKeyShare, hotKeyWindow, hotKeyMinRequests),
+		rings:    newRingBuilder(xxhash.Sum64, 100),
+	}
+	require.NoError(t, picker.rings.attach(picker, readySCs))
+	return picker
+}
+
+func TestChoose(t *testing.T) {
+	const memberCount = 5
+
+	addresses := make([]resolver.Address, 0, memberCount)
+	for i := 0; i < memberCount; i++ {
+		addresses = append(addresses, resolver.Address{Addr: fmt.Sprintf("member%d", i)})
+	}
+	readySCs := readySubConns(addresses...)
+
+	key := []byte("somekey")
+	ordered, err := newTestPicker(t, 1, nil, readySCs).currentRing().hashring.FindN(key, memberCount)
+	require.NoError(t, err)
+
+	keyOf := func(i int) string {
+		return ordered[i].Key()
+	}
+
+	testCases := []struct {
+		name     string
+		spread   uint8
+		tripped  []int
+		attempt  uint8
+		expected []int
+	}{
+		{"first attempt", 1, nil, 0, []int{0}},
+		{"first attempt is spread", 2, nil, 0, []int{0, 1}},
+		{"second attempt follows the chosen member", 1, nil, 1, []int{1}},
+		{"second attempt follows the spread", 2, nil, 1, []int{2}},
+		{"third attempt", 1, nil, 2, []int{2}},
+		{"attempts wrap around the ring", 1, nil, memberCount, []int{0}},
+		{"tripped member is skipped", 1, []int{0}, 0, []int{1}},
+		{"tripped members are skipped by later attempts", 1, []int{0, 2}, 1, []int{3}},
+		{"tripped member is skipped within spread", 2, []int{1}, 0, []int{0, 2}},
+		{"every member tripped", 1, []int{0, 1, 2, 3, 4}, 0, []int{0}},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			require := require.New(t)
+
+			mockTime := clock.NewMock()
+			breakers := newCircuitBreakers(1, time.Minute, mockTime)
+			for _, index := range tc.tripped {
+				breakers.record(keyOf(index), errUnavailable)
+			}
+
+			picker := newTestPicker(t, tc.spread, breakers, readySCs)
+
+			expected := make([]string, 0, len(tc.expected))
+			for _, index := range tc.expected {
+				expected = append(expected, keyOf(index))
+			}
+
+			chosen := make(map[string]struct{})
+			for i := 0; i < 100; i++ {
+				member, err := picker.choose(key, tc.attempt)
+				require.NoError(err)
+				require.Contains(expected, member.key)
+				chosen[member.key] = struct{}{}
+			}
+			require.Len(chosen, len(expected))
+
+			// Once the breakers have cooled down, the tripped members are chosen again.
+			mockTime.Add(time.Minute)
+			member, err := picker.choose(key, 0)
+			require.NoError(err)
+			if tc.spread == 1 {
+				require.Equal(keyOf(0), member.key)
+			}
+		})
+	}
+}
+
+func TestChooseWithTooFewMembers(t *testing.T) {
+	picker := newTestPicker(t, 2, newCircuitBreakers(1, time.Minute, clock.NewMock()), readySubConns(resolver.Address{Addr: "member"}))
+
+	_, err := picker.choose([]byte("somekey"), 0)
+	require.ErrorIs(t, err, consistent.ErrNotEnoughMembers)
+}
+
+func TestAdvertisedWeights(t *testing.T) {
+	require := require.New(t)
+
+	readySCs := readySubConns(
+		WithWeight(resolver.Address{Addr: "small"}, 1),
+		WithWeight(resolver.Address{Addr: "large"}, 3),
+	)
+	picker := newTestPicker(t, 1, newCircuitBreakers(1, time.Minute, clock.NewMock()), readySCs)
+
+	ownership := func() map[string]float64 {
+		return picker.currentRing().hashring.Ownership()
+	}
+
+	// Members are weighted by their addresses until they advertise their own weights.
+	require.InDelta(0.75, ownership()["large"], 0.1)
+
+	picker.rings.observe("small", metadata.Pairs(WeightMetadataKey, "3"))
+	require.InDelta(0.5, ownership()["large"], 0.1)
+	require.Equal(uint16(3), picker.currentRing().members["small"].weight)
+
+	// Invalid weights are ignored.
+	picker.rings.observe("small", metadata.Pairs(WeightMetadataKey, "0"))
+	picker.rings.observe("small", metadata.Pairs(WeightMetadataKey, "heavy"))
+	picker.rings.observe("small", metadata.MD{})
+	require.Equal(uint16(3), picker.currentRing().members["small"].weight)
+
+	// Advertised weights survive the picker being rebuilt.
+	rebuilt := newTestPicker(t, 1, picker.breakers, readySCs)
+	rebuilt.rings = picker.rings
+	require.NoError(picker.rings.attach(rebuilt, readySCs))
+	require.Equal(uint16(3), rebuilt.currentRing().members["small"].weight)
+}
+
+func TestAdvertiseWeight(t *testing.T) {
+	unary, _ := AdvertiseWeight(7)
+
+	stream := &fakeServerTransportStream{}
+	ctx := grpc.NewContextWithServerTransportStream(context.Background(), stream)
+	_, err := unary(ctx, nil, &grpc.UnaryServerInfo{}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, nil
+	})
+	require.NoError(t, err)
+
+	weight, ok := advertisedWeight(stream.trailer)
+	require.True(t, ok)
+	require.Equal(t, uint16(7), weight)
+}
+
+type fakeServerTransportStream struct {
+	grpc.ServerTransportStream
+	trailer metadata.MD
+}
+
+func (s *fakeServerTransportStream) SetTrailer(md metadata.MD) error {
+	s.trailer = metadata.Join(s.trailer, md)
+	return nil
+}
@@ -0,0 +1,141 @@
+package balancer
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/authzed/spicedb/pkg/consistent"
+)
+
+const (
+	// DefaultHotKeyShare is the default fraction of requests for which a key must account
+	// to be considered hot.
+	DefaultHotKeyShare = 0.05
+
+	// hotKeyWindow is the window over which requests for each key are counted.
+	hotKeyWindow = 1 * time.Second
+
+	// hotKeyMinRequests is the minimum number of requests a key must receive in a window to
+	// be considered hot, so that keys are not considered hot when there is little traffic.
+	hotKeyMinRequests = 100
+
+	// maxTrackedKeys bounds the number of keys counted in each window. Keys first requested
+	// after this many other keys in a window are not counted, which is sufficient to find
+	// hot keys, as they are very likely to be among those requested early in the window.
+	maxTrackedKeys = 10_000
+)
+
+var hotKeysGauge = promauto.NewGauge(prometheus.GaugeOpts{
+	Namespace: "spicedb",
+	Subsystem: "dispatch_hashring",
+	Name:      "hot_keys",
+	Help:      "number of keys considered hot in the last window of requests",
+})
+
+var hotKeyPicksCount = promauto.NewCounter(prometheus.CounterOpts{
+	Namespace: "spicedb",
+	Subsystem: "dispatch_hashring",
+	Name:      "hot_key_picks_total",
+	Help:      "total number of requests for hot keys spread across additional members of the hashring",
+})
+
+// hotKeyTracker counts the requests for each key over fixed windows, and considers a key hot
+// if it accounted for at least the configured share of the requests in the previous window.
+// Requests are counted without locking, as every request is recorded.
+type hotKeyTracker struct {
+	hasher      consistent.HasherFunc
+	share       float64
+	window      time.Duration
+	minRequests uint64
+
+	// current holds the *keyWindow in which requests are currently counted.
+	current atomic.Value
+
+	// rotateLock serializes rotating to a new window.
+	rotateLock sync.Mutex
+}
+
+// keyWindow is a single window of requests, along with the keys found to be hot in the
+// previous window.
+type keyWindow struct {
+	// tracked and total are accessed atomically, so must be 64-bit aligned.
+	tracked int64
+	total   uint64
+
+	start  time.Time
+	hot    map[uint64]struct{}
+	counts sync.Map // map[uint64]*uint64
+}
+
+func newHotKeyTracker(hasher consistent.HasherFunc, share float64, window time.Duration, minRequests uint64) *hotKeyTracker {
+	t := &hotKeyTracker{
+		hasher:      hasher,
+		share:       share,
+		window:      window,
+		minRequests: minRequests,
+	}
+	t.current.Store(&keyWindow{start: time.Now(), hot: map[uint64]struct{}{}})
+	return t
+}
+
+// record counts a request for the key, returning whether the key is currently hot.
+func (t *hotKeyTracker) record(key []byte) bool {
+	keyHash := t.hasher(key)
+
+	current := t.current.Load().(*keyWindow)
+	if now := time.Now(); now.Sub(current.start) >= t.window {
+		t.rotateFrom(current, now)
+		current = t.current.Load().(*keyWindow)
+	}
+
+	atomic.AddUint64(&current.total, 1)
+	if count, ok := current.counts.Load(keyHash); ok {
+		atomic.AddUint64(count.(*uint64), 1)
+	} else if atomic.LoadInt64(&current.tracked) < maxTrackedKeys {
+		count, loaded := current.counts.LoadOrStore(keyHash, new(uint64))
+		if !loaded {
+			atomic.AddInt64(&current.tracked, 1)
+		}
+		atomic.AddUint64(count.(*uint64), 1)
+	}
+
+	_, isHot := current.hot[keyHash]
+	return isHot
+}
+
+// rotate starts a new window, finding the keys which were hot in the current window.
+func (t *hotKeyTracker) rotate(now time.Time) {
+	t.rotateFrom(t.current.Load().(*keyWindow), now)
+}
+
+// rotateFrom starts a new window if the given window is still the current window. Requests
+// counted in the given window after it has been replaced are not considered.
+func (t *hotKeyTracker) rotateFrom(previous *keyWindow, now time.Time) {
+	t.rotateLock.Lock()
+	defer t.rotateLock.Unlock()
+
+	if t.current.Load().(*keyWindow) != previous {
+		return
+	}
+
+	total := atomic.LoadUint64(&previous.total)
+	hot := map[uint64]struct{}{}
+	previous.counts.Range(func(keyHash, count interface{}) bool {
+		c := atomic.LoadUint64(count.(*uint64))
+		if c >= t.minRequests && float64(c) >= t.share*float64(total) {
+			hot[keyHash.(uint64)] = struct{}{}
+		}
+		return true
+	})
+
+	if len(hot) != len(previous.hot) {
+		logger.Infof("consistentHashringPicker: %d hot keys found", len(hot))
+	}
+	hotKeysGauge.Set(float64(len(hot)))
+
+	t.current.Store(&keyWindow{start: now, hot: hot})
+}
@@ -0,0 +1,34 @@
+package balancer
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHotKeyTracker(t *testing.T) {
+	require := require.New(t)
+
+	tracker := newHotKeyTracker(xxhash.Sum64, 0.1, time.Hour, 10)
+
+	// Within the first window, no keys are yet known to be hot.
+	for i := 0; i < 100; i++ {
+		require.False(tracker.record([]byte("everyone")))
+		require.False(tracker.record([]byte(strconv.Itoa(i))))
+		if i%10 == 0 {
+			require.False(tracker.record([]byte("rare")))
+		}
+	}
+
+	tracker.rotate(time.Now())
+	require.True(tracker.record([]byte("everyone")))
+	require.False(tracker.record([]byte("rare")), "keys must receive more than the share of requests")
+	require.False(tracker.record([]byte("0")))
+
+	// Keys which are no longer hot are forgotten in the next window.
+	tracker.rotate(time.Now())
+	require.False(tracker.record([]byte("everyone")))
+}
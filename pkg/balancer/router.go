@@ -0,0 +1,200 @@
+package balancer
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/balancer/base"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/serviceconfig"
+)
+
+// routers holds every Router which has not been closed, by ID, so that the balancer of a
+// connection can find the Router named in its service config.
+var (
+	routers     sync.Map
+	routerCount uint64
+)
+
+// Router exposes the consistent hashring of a single connection, so that requests which are
+// not sent as individual calls, such as those multiplexed over a stream to each member, can
+// be routed to the same members as individual calls would be.
+//
+// A Router is bound to a connection by dialing it with the service config returned by
+// ServiceConfig.
+type Router struct {
+	id string
+
+	sync.RWMutex
+	picker *consistentHashringPicker
+}
+
+// NewRouter creates a new Router, which is not bound to any connection.
+func NewRouter() *Router {
+	router := &Router{id: strconv.FormatUint(atomic.AddUint64(&routerCount, 1), 10)}
+	routers.Store(router.id, router)
+	return router
+}
+
+// ServiceConfig returns a service config which sets the default balancer to the consistent
+// hashring balancer and binds the connection's hashring to the Router. If a service name is
+// given, only members for which it is reported as serving by the standard gRPC health service
+// are included.
+func (r *Router) ServiceConfig(healthCheckServiceName string) string {
+	config := fmt.Sprintf(`{"loadBalancingConfig":[{%q:{"router":%q}}]`, BalancerName, r.id)
+	if healthCheckServiceName != "" {
+		config += fmt.Sprintf(`,"healthCheckConfig":{"serviceName":%q}`, healthCheckServiceName)
+	}
+	return config + "}"
+}
+
+// Route returns the key of the member to which the given attempt of a request for the key
+// would be sent, and a function with which the outcome of the request must be recorded.
+// Requests can be sent to the member by calling WithMember on their context.
+//
+// Returns balancer.ErrNoSubConnAvailable if the Router is not bound to a connection, or
+// the connection has no members which are ready.
+func (r *Router) Route(key []byte, attempt uint8) (string, func(err error), error) {
+	r.RLock()
+	picker := r.picker
+	r.RUnlock()
+
+	if picker == nil {
+		return "", nil, balancer.ErrNoSubConnAvailable
+	}
+
+	chosen, err := picker.choose(key, attempt)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return chosen.key, func(err error) {
+		picker.breakers.record(chosen.key, err)
+	}, nil
+}
+
+// ObserveWeight records the weight, if any, advertised by the member in the metadata, such
+// as the headers of a stream opened to the member.
+func (r *Router) ObserveWeight(member string, md metadata.MD) {
+	r.RLock()
+	picker := r.picker
+	r.RUnlock()
+
+	if picker != nil {
+		picker.rings.observe(member, md)
+	}
+}
+
+// Close releases the Router. Connections to which it is bound continue to function.
+func (r *Router) Close() {
+	routers.Delete(r.id)
+}
+
+func (r *Router) setPicker(picker *consistentHashringPicker) {
+	r.Lock()
+	defer r.Unlock()
+	r.picker = picker
+}
+
+// routerBinding tracks the Router bound to a single connection and the most recent picker
+// built for the connection, which are set in either order.
+type routerBinding struct {
+	sync.Mutex
+	router *Router
+	picker *consistentHashringPicker
+}
+
+func (rb *routerBinding) bind(router *Router) {
+	rb.Lock()
+	defer rb.Unlock()
+
+	if rb.router == router {
+		return
+	}
+	if rb.router != nil {
+		rb.router.setPicker(nil)
+	}
+	rb.router = router
+	if router != nil {
+		router.setPicker(rb.picker)
+	}
+}
+
+func (rb *routerBinding) setPicker(picker *consistentHashringPicker) {
+	if rb == nil {
+		return
+	}
+
+	rb.Lock()
+	defer rb.Unlock()
+
+	rb.picker = picker
+	if rb.router != nil {
+		rb.router.setPicker(picker)
+	}
+}
+
+type hashringConfig struct {
+	serviceconfig.LoadBalancingConfig `json:"-"`
+
+	Router string `json:"router,omitempty"`
+}
+
+// hashringBalancerBuilder builds a balancer for each connection, with its own picker builder
+// such that a Router can be bound to the connection through its balancer config.
+type hashringBalancerBuilder struct {
+	pickerBuilder *consistentHashringPickerBuilder
+}
+
+func (b *hashringBalancerBuilder) Name() string {
+	return BalancerName
+}
+
+func (b *hashringBalancerBuilder) Build(cc balancer.ClientConn, opts balancer.BuildOptions) balancer.Balancer {
+	pickerBuilder := *b.pickerBuilder
+	pickerBuilder.rings = newRingBuilder(pickerBuilder.hasher, pickerBuilder.replicationFactor)
+	pickerBuilder.binding = &routerBinding{}
+
+	return &hashringBalancer{
+		Balancer: base.NewBalancerBuilder(BalancerName, &pickerBuilder, base.Config{HealthCheck: true}).Build(cc, opts),
+		binding:  pickerBuilder.binding,
+	}
+}
+
+func (b *hashringBalancerBuilder) ParseConfig(js json.RawMessage) (serviceconfig.LoadBalancingConfig, error) {
+	var config hashringConfig
+	if err := json.Unmarshal(js, &config); err != nil {
+		return nil, fmt.Errorf("invalid %s config: %w", BalancerName, err)
+	}
+	return &config, nil
+}
+
+type hashringBalancer struct {
+	balancer.Balancer
+	binding *routerBinding
+}
+
+func (hb *hashringBalancer) UpdateClientConnState(state balancer.ClientConnState) error {
+	if config, ok := state.BalancerConfig.(*hashringConfig); ok && config.Router != "" {
+		if router, ok := routers.Load(config.Router); ok {
+			hb.binding.bind(router.(*Router))
+		} else {
+			logger.Warningf("consistentHashringBalancer: unknown router %s", config.Router)
+		}
+	}
+	return hb.Balancer.UpdateClientConnState(state)
+}
+
+func (hb *hashringBalancer) Close() {
+	hb.binding.bind(nil)
+	hb.Balancer.Close()
+}
+
+var (
+	_ balancer.Builder      = &hashringBalancerBuilder{}
+	_ balancer.ConfigParser = &hashringBalancerBuilder{}
+)
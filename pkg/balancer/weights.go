@@ -0,0 +1,197 @@
+package balancer
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/balancer/base"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/authzed/spicedb/pkg/consistent"
+)
+
+// WeightMetadataKey is the key of the gRPC metadata with which a member of the hashring
+// advertises its own weight, as set by AdvertiseWeight.
+const WeightMetadataKey = "io.spicedb.hashring-weight"
+
+// AdvertiseWeight returns server interceptors which advertise the given weight to clients
+// balancing requests across servers with the consistent hashring balancer, so that each
+// server can set its own weight rather than it being set by peer discovery. The weight is
+// sent in the trailers of unary calls and the headers of streams.
+func AdvertiseWeight(weight uint16) (grpc.UnaryServerInterceptor, grpc.StreamServerInterceptor) {
+	md := metadata.Pairs(WeightMetadataKey, strconv.FormatUint(uint64(weight), 10))
+
+	unary := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		_ = grpc.SetTrailer(ctx, md)
+		return handler(ctx, req)
+	}
+
+	stream := func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		_ = ss.SendHeader(md)
+		return handler(srv, ss)
+	}
+
+	return unary, stream
+}
+
+// advertisedWeight returns the weight advertised in the metadata, if any.
+func advertisedWeight(md metadata.MD) (uint16, bool) {
+	values := md.Get(WeightMetadataKey)
+	if len(values) == 0 {
+		return 0, false
+	}
+
+	weight, err := strconv.ParseUint(values[0], 10, 16)
+	if err != nil || weight == 0 {
+		return 0, false
+	}
+	return uint16(weight), true
+}
+
+// ringBuilder builds the hashring of each picker for a single connection, weighting each
+// member by the weight it has advertised or, if it has not advertised one, by the weight of
+// its address. The hashring of the current picker is rebuilt whenever a member advertises a
+// new weight.
+type ringBuilder struct {
+	hasher            consistent.HasherFunc
+	replicationFactor uint16
+
+	// advertised holds a map[string]uint16 of the weight advertised by each member, by member
+	// key. It is replaced rather than modified, so that it can be read after every request
+	// without locking.
+	advertised atomic.Value
+
+	sync.Mutex
+	picker   *consistentHashringPicker
+	readySCs map[balancer.SubConn]base.SubConnInfo
+}
+
+func newRingBuilder(hasher consistent.HasherFunc, replicationFactor uint16) *ringBuilder {
+	rb := &ringBuilder{
+		hasher:            hasher,
+		replicationFactor: replicationFactor,
+	}
+	rb.advertised.Store(map[string]uint16{})
+	return rb
+}
+
+// attach builds the hashring for the ready connections of the picker, which becomes the
+// current picker.
+func (rb *ringBuilder) attach(picker *consistentHashringPicker, readySCs map[balancer.SubConn]base.SubConnInfo) error {
+	rb.Lock()
+	defer rb.Unlock()
+
+	rb.picker = nil
+	rb.readySCs = nil
+	if picker == nil {
+		return nil
+	}
+
+	ring, err := rb.build(readySCs)
+	if err != nil {
+		return err
+	}
+
+	picker.ring.Store(ring)
+	rb.picker = picker
+	rb.readySCs = readySCs
+	return nil
+}
+
+// observe records the weight, if any, advertised by the member in the metadata, rebuilding
+// the hashring of the current picker if the weight has changed.
+func (rb *ringBuilder) observe(member string, md metadata.MD) {
+	weight, ok := advertisedWeight(md)
+	if !ok {
+		return
+	}
+
+	if current, ok := rb.advertised.Load().(map[string]uint16)[member]; ok && current == weight {
+		return
+	}
+
+	rb.Lock()
+	defer rb.Unlock()
+
+	current := rb.advertised.Load().(map[string]uint16)
+	if existing, ok := current[member]; ok && existing == weight {
+		return
+	}
+
+	advertised := make(map[string]uint16, len(current)+1)
+	for key, existing := range current {
+		advertised[key] = existing
+	}
+	advertised[member] = weight
+	rb.advertised.Store(advertised)
+
+	if rb.picker == nil {
+		return
+	}
+	if _, ok := rb.picker.currentRing().members[member]; !ok {
+		return
+	}
+
+	logger.Infof("consistentHashringPicker: member %s advertised weight %d", member, weight)
+	ring, err := rb.build(rb.readySCs)
+	if err != nil {
+		logger.Warningf("consistentHashringPicker: unable to rebuild hashring: %v", err)
+		return
+	}
+	rb.picker.ring.Store(ring)
+}
+
+// build builds the hashring for the ready connections. Must be called with the lock held.
+func (rb *ringBuilder) build(readySCs map[balancer.SubConn]base.SubConnInfo) (*pickerRing, error) {
+	advertised := rb.advertised.Load().(map[string]uint16)
+
+	hashring := consistent.NewHashring(rb.hasher, rb.replicationFactor)
+	totalWeight := 0
+	members := make(map[string]subConnMember, len(readySCs))
+	for sc, scInfo := range readySCs {
+		member := subConnMember{
+			SubConn: sc,
+			key:     scInfo.Address.Addr + scInfo.Address.ServerName,
+			weight:  WeightOf(scInfo.Address),
+		}
+		if weight, ok := advertised[member.key]; ok {
+			member.weight = weight
+		}
+
+		if err := hashring.Add(member); err != nil {
+			return nil, err
+		}
+
+		members[member.key] = member
+		totalWeight += int(member.weight)
+	}
+
+	// Imbalance is measured relative to the share of the hashring each member should own
+	// given its weight, so that intentionally uneven weights are not reported.
+	imbalance := 0.0
+	for key, ownership := range hashring.Ownership() {
+		expected := float64(members[key].weight) / float64(totalWeight)
+		ratio := ownership / expected
+		memberOwnership.Observe(ratio)
+		if ratio > imbalance {
+			imbalance = ratio
+		}
+	}
+	ownershipImbalance.Set(imbalance)
+
+	memberCount := len(readySCs)
+	if memberCount > math.MaxUint8 {
+		memberCount = math.MaxUint8
+	}
+
+	return &pickerRing{
+		hashring:    hashring,
+		members:     members,
+		memberCount: uint8(memberCount),
+	}, nil
+}
@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jzelinskie/cobrautil"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+
+	"github.com/authzed/spicedb/internal/datastore/copier"
+	dsconfig "github.com/authzed/spicedb/pkg/cmd/datastore"
+	"github.com/authzed/spicedb/pkg/cmd/server"
+	"github.com/authzed/spicedb/pkg/datastore"
+)
+
+func NewDatastoreCommand(programName string) *cobra.Command {
+	return &cobra.Command{
+		Use:   "datastore",
+		Short: "operate on datastores",
+	}
+}
+
+func RegisterDatastoreCopyFlags(cmd *cobra.Command) {
+	cmd.Flags().String("from-engine", "", fmt.Sprintf(`type of datastore to copy from (%s)`, datastore.EngineOptions()))
+	cmd.Flags().String("from-conn-uri", "", "connection string of the datastore to copy from")
+	cmd.Flags().String("to-engine", "", fmt.Sprintf(`type of datastore to copy to (%s)`, datastore.EngineOptions()))
+	cmd.Flags().String("to-conn-uri", "", "connection string of the datastore to copy to, which must already be migrated")
+	cmd.Flags().String("datastore-spanner-credentials", "", "path to service account key credentials file with access to the cloud spanner instance")
+	cmd.Flags().String("datastore-mysql-table-prefix", "", "prefix to add to the name of all mysql database tables")
+	cmd.Flags().Uint16("batch-size", 1000, "number of relationships to write to the target datastore in each transaction")
+	cmd.Flags().String("checkpoint-file", "", "file in which to record progress, so that an interrupted copy can be resumed by running the same command")
+	cmd.Flags().Uint32("verify-sample-size", 100, "number of randomly chosen relationships to check for in the target datastore after copying")
+	cmd.Flags().Bool("skip-verify", false, "skip verifying the target datastore after copying")
+	cmd.Flags().Bool("tail", false, "after copying, replicate changes made to the source datastore until interrupted")
+
+	for _, required := range []string{"from-engine", "from-conn-uri", "to-engine", "to-conn-uri"} {
+		if err := cmd.MarkFlagRequired(required); err != nil {
+			panic("failed to mark flag as required: " + err.Error())
+		}
+	}
+}
+
+func NewDatastoreCopyCommand(programName string) *cobra.Command {
+	return &cobra.Command{
+		Use:   "copy",
+		Short: "copy the schema and relationships from one datastore to another",
+		Long: "Copies the schema and all relationships from one datastore to another, as of the current revision of the source datastore.\n" +
+			"The copy can then be verified, and changes made to the source datastore replicated until the target datastore is cut over to.\n" +
+			"The source datastore must retain the copied revision until the copy completes, so its garbage collection window must be long enough to cover the copy.",
+		PreRunE: server.DefaultPreRunE(programName),
+		RunE:    datastoreCopyRun,
+		Args:    cobra.ExactArgs(0),
+	}
+}
+
+func datastoreCopyRun(cmd *cobra.Command, args []string) error {
+	ctx := SignalContextWithGracePeriod(context.Background(), 0)
+
+	source, err := newCopyDatastore(cmd, "from")
+	if err != nil {
+		return fmt.Errorf("unable to open source datastore: %w", err)
+	}
+	defer source.Close()
+
+	target, err := newCopyDatastore(cmd, "to")
+	if err != nil {
+		return fmt.Errorf("unable to open target datastore: %w", err)
+	}
+	defer target.Close()
+
+	ready, err := target.IsReady(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to check target datastore: %w", err)
+	}
+	if !ready {
+		return fmt.Errorf("target datastore is not ready; run migrations against it first")
+	}
+
+	batchSize, err := cmd.Flags().GetUint16("batch-size")
+	if err != nil {
+		return err
+	}
+	sampleSize, err := cmd.Flags().GetUint32("verify-sample-size")
+	if err != nil {
+		return err
+	}
+
+	c := copier.NewCopier(source, target,
+		copier.BatchSize(batchSize),
+		copier.SampleSize(sampleSize),
+		copier.CheckpointFile(cobrautil.MustGetStringExpanded(cmd, "checkpoint-file")),
+	)
+
+	revision, err := c.Copy(ctx)
+	if err != nil {
+		return err
+	}
+	log.Info().Stringer("revision", revision).Msg("copied datastore")
+
+	// Once changes have been replicated, the target no longer matches the copied revision,
+	// so a resumed copy is only verified if tailing had not begun.
+	if skip, _ := cmd.Flags().GetBool("skip-verify"); skip {
+		log.Info().Msg("skipping verification of copy")
+	} else if c.Tailed() {
+		log.Info().Msg("skipping verification of copy to which changes have been replicated")
+	} else {
+		if err := c.Verify(ctx); err != nil {
+			return fmt.Errorf("copy verification failed: %w", err)
+		}
+	}
+
+	if tail, _ := cmd.Flags().GetBool("tail"); tail {
+		log.Info().Msg("replicating changes until interrupted")
+		return c.Tail(ctx)
+	}
+
+	return nil
+}
+
+// newCopyDatastore opens the datastore configured by the flags with the given prefix.
+// Garbage collection is disabled, so that the copy never removes the revision being
+// copied, and metrics are disabled, as they cannot be registered for both datastores.
+func newCopyDatastore(cmd *cobra.Command, prefix string) (datastore.Datastore, error) {
+	return dsconfig.NewDatastore(
+		dsconfig.WithEngine(cobrautil.MustGetStringExpanded(cmd, prefix+"-engine")),
+		dsconfig.WithURI(cobrautil.MustGetStringExpanded(cmd, prefix+"-conn-uri")),
+		dsconfig.WithSpannerCredentialsFile(cobrautil.MustGetStringExpanded(cmd, "datastore-spanner-credentials")),
+		dsconfig.WithTablePrefix(cobrautil.MustGetStringExpanded(cmd, "datastore-mysql-table-prefix")),
+		dsconfig.WithGCInterval(0),
+		dsconfig.WithEnableDatastoreMetrics(false),
+		dsconfig.WithRequestHedgingEnabled(false),
+	)
+}
@@ -39,12 +39,16 @@ func (c *Config) ToOption() ConfigOption {
 		to.MaxRetries = c.MaxRetries
 		to.OverlapKey = c.OverlapKey
 		to.OverlapStrategy = c.OverlapStrategy
+		to.ReadReplicaURIs = c.ReadReplicaURIs
 		to.HealthCheckPeriod = c.HealthCheckPeriod
 		to.GCInterval = c.GCInterval
 		to.GCMaxOperationTime = c.GCMaxOperationTime
+		to.GCArchiveHistory = c.GCArchiveHistory
 		to.SpannerCredentialsFile = c.SpannerCredentialsFile
 		to.SpannerEmulatorHost = c.SpannerEmulatorHost
 		to.TablePrefix = c.TablePrefix
+		to.MemoryPersistenceDir = c.MemoryPersistenceDir
+		to.MemorySnapshotInterval = c.MemorySnapshotInterval
 		to.WatchBufferLength = c.WatchBufferLength
 	}
 }
@@ -218,6 +222,20 @@ func WithOverlapStrategy(overlapStrategy string) ConfigOption {
 	}
 }
 
+// WithReadReplicaURIs returns an option that can append ReadReplicaURIss to Config.ReadReplicaURIs
+func WithReadReplicaURIs(readReplicaURIs string) ConfigOption {
+	return func(c *Config) {
+		c.ReadReplicaURIs = append(c.ReadReplicaURIs, readReplicaURIs)
+	}
+}
+
+// SetReadReplicaURIs returns an option that can set ReadReplicaURIs on a Config
+func SetReadReplicaURIs(readReplicaURIs []string) ConfigOption {
+	return func(c *Config) {
+		c.ReadReplicaURIs = readReplicaURIs
+	}
+}
+
 // WithHealthCheckPeriod returns an option that can set HealthCheckPeriod on a Config
 func WithHealthCheckPeriod(healthCheckPeriod time.Duration) ConfigOption {
 	return func(c *Config) {
@@ -239,6 +257,13 @@ func WithGCMaxOperationTime(gCMaxOperationTime time.Duration) ConfigOption {
 	}
 }
 
+// WithGCArchiveHistory returns an option that can set GCArchiveHistory on a Config
+func WithGCArchiveHistory(gCArchiveHistory bool) ConfigOption {
+	return func(c *Config) {
+		c.GCArchiveHistory = gCArchiveHistory
+	}
+}
+
 // WithSpannerCredentialsFile returns an option that can set SpannerCredentialsFile on a Config
 func WithSpannerCredentialsFile(spannerCredentialsFile string) ConfigOption {
 	return func(c *Config) {
@@ -260,6 +285,20 @@ func WithTablePrefix(tablePrefix string) ConfigOption {
 	}
 }
 
+// WithMemoryPersistenceDir returns an option that can set MemoryPersistenceDir on a Config
+func WithMemoryPersistenceDir(memoryPersistenceDir string) ConfigOption {
+	return func(c *Config) {
+		c.MemoryPersistenceDir = memoryPersistenceDir
+	}
+}
+
+// WithMemorySnapshotInterval returns an option that can set MemorySnapshotInterval on a Config
+func WithMemorySnapshotInterval(memorySnapshotInterval time.Duration) ConfigOption {
+	return func(c *Config) {
+		c.MemorySnapshotInterval = memorySnapshotInterval
+	}
+}
+
 // WithWatchBufferLength returns an option that can set WatchBufferLength on a Config
 func WithWatchBufferLength(watchBufferLength uint16) ConfigOption {
 	return func(c *Config) {
@@ -33,6 +33,11 @@ func RegisterServeFlags(cmd *cobra.Command, config *server.Config) {
 	}
 	server.RegisterCacheConfigFlags(cmd.Flags(), &config.NamespaceCacheConfig, "ns-cache")
 
+	// Flags for the relationship cache
+	cmd.Flags().BoolVar(&config.RelationshipCacheEnabled, "relationship-cache", false, "cache the results of relationship queries performed at the same datastore revision")
+	server.RegisterCacheConfigFlags(cmd.Flags(), &config.RelationshipCacheConfig, "relationship-cache")
+	cmd.Flags().StringVar(&config.RelationshipCacheMaxResultCost, "relationship-cache-max-result-cost", "64KiB", "the maximum size of a single relationship query result to be stored in the relationship cache, in bytes")
+
 	// Flags for parsing and validating schemas.
 	cmd.Flags().BoolVar(&config.SchemaPrefixesRequired, "schema-prefixes-required", false, "require prefixes on all object definitions in schemas")
 
@@ -59,14 +64,32 @@ func RegisterServeFlags(cmd *cobra.Command, config *server.Config) {
 	util.RegisterGRPCServerFlags(cmd.Flags(), &config.DispatchServer, "dispatch-cluster", "dispatch", ":50053", false)
 	server.RegisterCacheConfigFlags(cmd.Flags(), &config.DispatchCacheConfig, "dispatch-cache")
 	server.RegisterCacheConfigFlags(cmd.Flags(), &config.ClusterDispatchCacheConfig, "dispatch-cluster-cache")
+	cmd.Flags().BoolVar(&config.DispatchCacheWriteAware, "dispatch-cache-write-aware", false, "reuse cached check results across revisions until the relations they depend upon are written")
+	cmd.Flags().BoolVar(&config.DispatchMaterializedIndex, "dispatch-materialized-index", false, "answer dispatches for relations annotated with the spicedb:materialize directive from an in-memory index of their recursive membership")
+	cmd.Flags().StringVar(&config.DispatchCacheSnapshotDir, "dispatch-cache-snapshot-dir", "", "directory in which to save the dispatch caches on shutdown and from which to load them on startup")
+	cmd.Flags().StringVar(&config.DispatchCacheWarmupChecksPath, "dispatch-cache-warmup-checks-path", "", "path to a file of checks, one relationship per line, to perform to warm the dispatch cache on startup")
+	cmd.Flags().DurationVar(&config.DispatchCacheWarmupTimeout, "dispatch-cache-warmup-timeout", 30*time.Second, "maximum time to spend warming the dispatch cache on startup, before the servers start listening (0 for no limit)")
 
 	// Flags for configuring dispatch requests
 	cmd.Flags().Uint32Var(&config.DispatchMaxDepth, "dispatch-max-depth", 50, "maximum recursion depth for nested calls")
-	cmd.Flags().StringVar(&config.DispatchUpstreamAddr, "dispatch-upstream-addr", "", "upstream grpc address to dispatch to")
+	cmd.Flags().Uint32Var(&config.DispatchBudgetMaxDispatches, "dispatch-budget-max-dispatches", 0, "maximum number of dispatches a single request may perform before failing with RESOURCE_EXHAUSTED (0 for no limit)")
+	cmd.Flags().Uint32Var(&config.DispatchBudgetMaxDatastoreQueries, "dispatch-budget-max-datastore-queries", 0, "maximum number of datastore queries a single request may perform before failing with RESOURCE_EXHAUSTED (0 for no limit)")
+	cmd.Flags().StringVar(&config.DispatchUpstreamAddr, "dispatch-upstream-addr", "", "upstream grpc address to dispatch to, which may use the dns-srv:/// or peers-file:/// schemes to discover peers")
+	cmd.Flags().StringSliceVar(&config.DispatchUpstreamStaticPeers, "dispatch-upstream-static-peers", nil, "static list of peer grpc addresses to dispatch to, in place of an upstream address, each optionally followed by =weight to set its weight on the hashring")
 	cmd.Flags().StringVar(&config.DispatchUpstreamCAPath, "dispatch-upstream-ca-path", "", "local path to the TLS CA used when connecting to the dispatch cluster")
+	cmd.Flags().Uint16Var(&config.DispatchHashringWeight, "dispatch-hashring-weight", 0, "weight of this node on the hashring of its peers, advertised to them on each dispatch (0 to leave the weight to peer discovery)")
+	cmd.Flags().BoolVar(&config.DispatchHedgingEnabled, "dispatch-hedging", false, "enable hedging of requests dispatched to the dispatch cluster")
+	cmd.Flags().DurationVar(&config.DispatchHedgingInitialSlowValue, "dispatch-hedging-initial-slow-value", 50*time.Millisecond, "initial value to use for slow dispatch requests, before statistics have been collected")
+	cmd.Flags().Uint64Var(&config.DispatchHedgingMaxRequests, "dispatch-hedging-max-requests", 1_000_000, "maximum number of historical dispatch requests to consider")
+	cmd.Flags().Float64Var(&config.DispatchHedgingQuantile, "dispatch-hedging-quantile", 0.95, "quantile of historical dispatch request time over which a request will be considered slow")
+	cmd.Flags().BoolVar(&config.DispatchHedgingLocally, "dispatch-hedging-locally", false, "evaluate hedged and failover dispatch requests locally, rather than on the next node in the dispatch cluster")
+	cmd.Flags().BoolVar(&config.DispatchFailoverEnabled, "dispatch-failover", false, "retry requests dispatched to the dispatch cluster once if their node is unavailable")
+	cmd.Flags().BoolVar(&config.DispatchLocalityAware, "dispatch-locality-aware", false, "evaluate dispatched subproblems which only read direct relationships locally, when faster than sending them to the dispatch cluster")
+	cmd.Flags().BoolVar(&config.DispatchMultiplexing, "dispatch-multiplexing", true, "multiplex requests to each node in the dispatch cluster over a single stream, for nodes which support it")
 
 	// Flags for configuring API behavior
 	cmd.Flags().BoolVar(&config.DisableV1SchemaAPI, "disable-v1-schema-api", false, "disables the V1 schema API")
+	cmd.Flags().BoolVar(&config.EnableExperimentalAPI, "enable-experimental-api", false, "enables the experimental API, whose methods may change or be removed without notice")
 	cmd.Flags().BoolVar(&config.DisableVersionResponse, "disable-version-response", false, "disables version response support in the API")
 
 	// Flags for misc services
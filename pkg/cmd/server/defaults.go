@@ -20,6 +20,7 @@ import (
 	"github.com/authzed/spicedb/internal/logging"
 	consistencymw "github.com/authzed/spicedb/internal/middleware/consistency"
 	datastoremw "github.com/authzed/spicedb/internal/middleware/datastore"
+	"github.com/authzed/spicedb/internal/middleware/dispatchbudget"
 	dispatchmw "github.com/authzed/spicedb/internal/middleware/dispatcher"
 	"github.com/authzed/spicedb/internal/middleware/serverversion"
 	"github.com/authzed/spicedb/internal/middleware/servicespecific"
@@ -74,7 +75,7 @@ func MetricsHandler(telemetryRegistry *prometheus.Registry) http.Handler {
 	return mux
 }
 
-func DefaultMiddleware(logger zerolog.Logger, authFunc grpcauth.AuthFunc, enableVersionResponse bool, dispatcher dispatch.Dispatcher, ds datastore.Datastore) ([]grpc.UnaryServerInterceptor, []grpc.StreamServerInterceptor) {
+func DefaultMiddleware(logger zerolog.Logger, authFunc grpcauth.AuthFunc, enableVersionResponse bool, dispatcher dispatch.Dispatcher, ds datastore.Datastore, maxDispatchesPerRequest uint32, maxDatastoreQueriesPerRequest uint32) ([]grpc.UnaryServerInterceptor, []grpc.StreamServerInterceptor) {
 	return []grpc.UnaryServerInterceptor{
 			requestid.UnaryServerInterceptor(requestid.GenerateIfMissing(true)),
 			logmw.UnaryServerInterceptor(logmw.ExtractMetadataField("x-request-id", "requestID")),
@@ -83,6 +84,7 @@ func DefaultMiddleware(logger zerolog.Logger, authFunc grpcauth.AuthFunc, enable
 			grpcauth.UnaryServerInterceptor(authFunc),
 			grpcprom.UnaryServerInterceptor,
 			dispatchmw.UnaryServerInterceptor(dispatcher),
+			dispatchbudget.UnaryServerInterceptor(maxDispatchesPerRequest, maxDatastoreQueriesPerRequest),
 			datastoremw.UnaryServerInterceptor(ds),
 			consistencymw.UnaryServerInterceptor(),
 			servicespecific.UnaryServerInterceptor,
@@ -95,6 +97,7 @@ func DefaultMiddleware(logger zerolog.Logger, authFunc grpcauth.AuthFunc, enable
 			grpcauth.StreamServerInterceptor(authFunc),
 			grpcprom.StreamServerInterceptor,
 			dispatchmw.StreamServerInterceptor(dispatcher),
+			dispatchbudget.StreamServerInterceptor(maxDispatchesPerRequest, maxDatastoreQueriesPerRequest),
 			datastoremw.StreamServerInterceptor(ds),
 			consistencymw.StreamServerInterceptor(),
 			servicespecific.StreamServerInterceptor,
@@ -4,10 +4,12 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"path/filepath"
 	"sync"
 	"time"
 
 	"github.com/authzed/grpcutil"
+	"github.com/dustin/go-humanize"
 	grpc_auth "github.com/grpc-ecosystem/go-grpc-middleware/auth"
 	grpcprom "github.com/grpc-ecosystem/go-grpc-prometheus"
 	"github.com/rs/cors"
@@ -21,8 +23,10 @@ import (
 	"github.com/authzed/spicedb/internal/dashboard"
 	"github.com/authzed/spicedb/internal/datastore/proxy"
 	"github.com/authzed/spicedb/internal/dispatch"
+	"github.com/authzed/spicedb/internal/dispatch/caching"
 	clusterdispatch "github.com/authzed/spicedb/internal/dispatch/cluster"
 	combineddispatch "github.com/authzed/spicedb/internal/dispatch/combined"
+	"github.com/authzed/spicedb/internal/dispatch/materialized"
 	"github.com/authzed/spicedb/internal/gateway"
 	"github.com/authzed/spicedb/internal/services"
 	dispatchSvc "github.com/authzed/spicedb/internal/services/dispatch"
@@ -32,6 +36,16 @@ import (
 	datastorecfg "github.com/authzed/spicedb/pkg/cmd/datastore"
 	"github.com/authzed/spicedb/pkg/cmd/util"
 	"github.com/authzed/spicedb/pkg/datastore"
+	"github.com/authzed/spicedb/pkg/discovery"
+	dispatchv1 "github.com/authzed/spicedb/pkg/proto/dispatch/v1"
+	"github.com/authzed/spicedb/pkg/secrets"
+)
+
+// The names of the files within the dispatch cache snapshot directory holding the
+// snapshots of the dispatch and cluster dispatch caches.
+const (
+	dispatchCacheSnapshotFile        = "dispatch-cache.snapshot"
+	clusterDispatchCacheSnapshotFile = "dispatch-cluster-cache.snapshot"
 )
 
 //go:generate go run github.com/ecordell/optgen -output zz_generated.options.go . Config
@@ -57,23 +71,50 @@ type Config struct {
 	// Namespace cache
 	NamespaceCacheConfig CacheConfig
 
+	// Relationship cache
+	RelationshipCacheEnabled       bool
+	RelationshipCacheConfig        CacheConfig
+	RelationshipCacheMaxResultCost string
+
 	// Schema options
 	SchemaPrefixesRequired bool
 
 	// Dispatch options
-	DispatchServer               util.GRPCServerConfig
-	DispatchMaxDepth             uint32
-	DispatchUpstreamAddr         string
-	DispatchUpstreamCAPath       string
-	DispatchClientMetricsPrefix  string
-	DispatchClusterMetricsPrefix string
-	Dispatcher                   dispatch.Dispatcher
+	DispatchServer                    util.GRPCServerConfig
+	DispatchMaxDepth                  uint32
+	DispatchBudgetMaxDispatches       uint32
+	DispatchBudgetMaxDatastoreQueries uint32
+	DispatchUpstreamAddr              string
+	DispatchUpstreamStaticPeers       []string
+	DispatchUpstreamCAPath            string
+	DispatchHashringWeight            uint16
+	DispatchClientMetricsPrefix       string
+	DispatchClusterMetricsPrefix      string
+	Dispatcher                        dispatch.Dispatcher
+
+	DispatchHedgingEnabled          bool
+	DispatchHedgingInitialSlowValue time.Duration
+	DispatchHedgingMaxRequests      uint64
+	DispatchHedgingQuantile         float64
+	DispatchHedgingLocally          bool
+	DispatchFailoverEnabled         bool
+
+	DispatchLocalityAware bool
+	DispatchMultiplexing  bool
 
 	DispatchCacheConfig        CacheConfig
 	ClusterDispatchCacheConfig CacheConfig
+	DispatchCacheWriteAware    bool
+
+	DispatchMaterializedIndex bool
+
+	DispatchCacheSnapshotDir      string
+	DispatchCacheWarmupChecksPath string
+	DispatchCacheWarmupTimeout    time.Duration
 
 	// API Behavior
-	DisableV1SchemaAPI bool
+	DisableV1SchemaAPI    bool
+	EnableExperimentalAPI bool
 
 	// Additional Services
 	DashboardAPI util.HTTPServerConfig
@@ -136,8 +177,45 @@ func (c *Config) Complete() (RunnableServer, error) {
 		return nil, fmt.Errorf("failed to create namespace caching datastore proxy: %w", err)
 	}
 
+	if c.RelationshipCacheEnabled {
+		rcc, err := c.RelationshipCacheConfig.Complete()
+		if err != nil {
+			return nil, fmt.Errorf("failed to create relationship cache: %w", err)
+		}
+
+		var maxResultCost uint64
+		if c.RelationshipCacheMaxResultCost != "" {
+			maxResultCost, err = humanize.ParseBytes(c.RelationshipCacheMaxResultCost)
+			if err != nil {
+				return nil, fmt.Errorf("error parsing relationship cache max result cost `%s`: %w", c.RelationshipCacheMaxResultCost, err)
+			}
+		}
+
+		ds, err = proxy.NewRelationshipCachingDatastoreProxy(ds, rcc, int64(maxResultCost))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create relationship caching datastore proxy: %w", err)
+		}
+	}
+
 	enableGRPCHistogram()
 
+	var writeTracker *caching.WriteTracker
+	if c.DispatchCacheWriteAware {
+		writeTracker, err = caching.NewWriteTracker(ds)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create dispatch cache write tracker: %w", err)
+		}
+	}
+
+	var materializedIndex *materialized.Index
+	if c.DispatchMaterializedIndex {
+		materializedIndex, err = materialized.NewIndex(ds)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create materialized index: %w", err)
+		}
+	}
+
+	var router *balancer.Router
 	dispatcher := c.Dispatcher
 	if dispatcher == nil {
 		var err error
@@ -151,17 +229,62 @@ func (c *Config) Complete() (RunnableServer, error) {
 			dispatchPresharedKey = c.PresharedKey[0]
 		}
 
-		dispatcher, err = combineddispatch.NewDispatcher(
-			combineddispatch.UpstreamAddr(c.DispatchUpstreamAddr),
+		upstreamAddr := c.DispatchUpstreamAddr
+		if len(c.DispatchUpstreamStaticPeers) > 0 {
+			if upstreamAddr != "" {
+				return nil, fmt.Errorf("failed to create dispatcher: only one of an upstream address and static peers may be specified")
+			}
+			upstreamAddr = discovery.StaticTarget(c.DispatchUpstreamStaticPeers)
+		}
+
+		serviceConfig := balancer.HealthCheckingBalancerServiceConfig(dispatchv1.DispatchService_ServiceDesc.ServiceName)
+		if c.DispatchMultiplexing {
+			router = balancer.NewRouter()
+			serviceConfig = router.ServiceConfig(dispatchv1.DispatchService_ServiceDesc.ServiceName)
+		}
+
+		options := []combineddispatch.Option{
+			combineddispatch.UpstreamAddr(upstreamAddr),
 			combineddispatch.UpstreamCAPath(c.DispatchUpstreamCAPath),
 			combineddispatch.GrpcPresharedKey(dispatchPresharedKey),
 			combineddispatch.GrpcDialOpts(
 				grpc.WithUnaryInterceptor(otelgrpc.UnaryClientInterceptor()),
-				grpc.WithDefaultServiceConfig(balancer.BalancerServiceConfig),
+				grpc.WithDefaultServiceConfig(serviceConfig),
 			),
 			combineddispatch.PrometheusSubsystem(c.DispatchClientMetricsPrefix),
 			combineddispatch.CacheConfig(cc),
-		)
+			combineddispatch.WriteTracker(writeTracker),
+			combineddispatch.LocalityAware(c.DispatchLocalityAware),
+		}
+
+		if materializedIndex != nil {
+			options = append(options, combineddispatch.MaterializedIndex(materializedIndex))
+		}
+
+		if router != nil {
+			options = append(options, combineddispatch.Multiplexing(router))
+		}
+
+		if c.DispatchCacheSnapshotDir != "" {
+			options = append(options, combineddispatch.CacheSnapshot(
+				filepath.Join(c.DispatchCacheSnapshotDir, dispatchCacheSnapshotFile), ds,
+			))
+		}
+
+		if c.DispatchHedgingEnabled {
+			options = append(options,
+				combineddispatch.Hedging(c.DispatchHedgingInitialSlowValue, c.DispatchHedgingMaxRequests, c.DispatchHedgingQuantile),
+			)
+		}
+
+		if c.DispatchHedgingEnabled || c.DispatchFailoverEnabled {
+			options = append(options,
+				combineddispatch.Failover(c.DispatchFailoverEnabled),
+				combineddispatch.HedgeLocally(c.DispatchHedgingLocally),
+			)
+		}
+
+		dispatcher, err = combineddispatch.NewDispatcher(options...)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create dispatcher: %w", err)
 		}
@@ -182,23 +305,43 @@ func (c *Config) Complete() (RunnableServer, error) {
 			return nil, fmt.Errorf("failed to configure cluster dispatch: %w", cerr)
 		}
 
-		var err error
-		cachingClusterDispatch, err = clusterdispatch.NewClusterDispatcher(
-			dispatcher,
+		options := []clusterdispatch.Option{
 			clusterdispatch.PrometheusSubsystem(c.DispatchClusterMetricsPrefix),
 			clusterdispatch.CacheConfig(cdcc),
-		)
+			clusterdispatch.WriteTracker(writeTracker),
+		}
+
+		if materializedIndex != nil {
+			options = append(options, clusterdispatch.MaterializedIndex(materializedIndex))
+		}
+
+		if c.DispatchCacheSnapshotDir != "" {
+			options = append(options, clusterdispatch.CacheSnapshot(
+				filepath.Join(c.DispatchCacheSnapshotDir, clusterDispatchCacheSnapshotFile), ds,
+			))
+		}
+
+		var err error
+		cachingClusterDispatch, err = clusterdispatch.NewClusterDispatcher(dispatcher, options...)
 		if err != nil {
 			return nil, fmt.Errorf("failed to configure cluster dispatch: %w", err)
 		}
 	}
 
+	dispatchUnaryMiddleware := c.DispatchUnaryMiddleware
+	dispatchStreamingMiddleware := c.DispatchStreamingMiddleware
+	if c.DispatchHashringWeight > 0 {
+		unary, stream := balancer.AdvertiseWeight(c.DispatchHashringWeight)
+		dispatchUnaryMiddleware = append([]grpc.UnaryServerInterceptor{unary}, dispatchUnaryMiddleware...)
+		dispatchStreamingMiddleware = append([]grpc.StreamServerInterceptor{stream}, dispatchStreamingMiddleware...)
+	}
+
 	dispatchGrpcServer, err := c.DispatchServer.Complete(zerolog.InfoLevel,
 		func(server *grpc.Server) {
 			dispatchSvc.RegisterGrpcServices(server, cachingClusterDispatch)
 		},
-		grpc.ChainUnaryInterceptor(c.DispatchUnaryMiddleware...),
-		grpc.ChainStreamInterceptor(c.DispatchStreamingMiddleware...),
+		grpc.ChainUnaryInterceptor(dispatchUnaryMiddleware...),
+		grpc.ChainStreamInterceptor(dispatchStreamingMiddleware...),
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create dispatch gRPC server: %w", err)
@@ -214,8 +357,26 @@ func (c *Config) Complete() (RunnableServer, error) {
 		v1SchemaServiceOption = services.V1SchemaServiceDisabled
 	}
 
+	experimentalServiceOption := services.ExperimentalServiceDisabled
+	if c.EnableExperimentalAPI {
+		experimentalServiceOption = services.ExperimentalServiceEnabled
+	}
+
 	if len(c.UnaryMiddleware) == 0 && len(c.StreamingMiddleware) == 0 {
-		c.UnaryMiddleware, c.StreamingMiddleware = DefaultMiddleware(log.Logger, c.GRPCAuthFunc, !c.DisableVersionResponse, dispatcher, ds)
+		c.UnaryMiddleware, c.StreamingMiddleware = DefaultMiddleware(log.Logger, c.GRPCAuthFunc, !c.DisableVersionResponse, dispatcher, ds, c.DispatchBudgetMaxDispatches, c.DispatchBudgetMaxDatastoreQueries)
+	}
+
+	// Expansion continuation tokens are signed with the first preshared key, which is shared by
+	// every node of the cluster. Without one, they are signed with a random key and can only be
+	// continued by this node.
+	var continuationKey []byte
+	if len(c.PresharedKey) > 0 {
+		continuationKey = []byte(c.PresharedKey[0])
+	} else {
+		continuationKey, err = secrets.TokenBytes(32)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate expansion continuation key: %w", err)
+		}
 	}
 
 	grpcServer, err := c.GRPCServer.Complete(zerolog.InfoLevel,
@@ -224,8 +385,10 @@ func (c *Config) Complete() (RunnableServer, error) {
 				server,
 				dispatcher,
 				c.DispatchMaxDepth,
+				continuationKey,
 				prefixRequiredOption,
 				v1SchemaServiceOption,
+				experimentalServiceOption,
 			)
 		},
 	)
@@ -303,6 +466,26 @@ func (c *Config) Complete() (RunnableServer, error) {
 		return nil, fmt.Errorf("failed to initialize metrics server: %w", err)
 	}
 
+	warmupFunc := func(context.Context) {}
+	if c.DispatchCacheWarmupChecksPath != "" {
+		warmupFunc = func(ctx context.Context) {
+			// The servers do not listen until the cache has been warmed, so warming is
+			// abandoned if it does not complete in time.
+			if c.DispatchCacheWarmupTimeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, c.DispatchCacheWarmupTimeout)
+				defer cancel()
+			}
+
+			warmed, err := caching.Warm(ctx, dispatcher, ds, c.DispatchCacheWarmupChecksPath, c.DispatchMaxDepth)
+			if err != nil {
+				log.Warn().Err(err).Int("checks", warmed).Msg("couldn't warm dispatch cache")
+				return
+			}
+			log.Info().Int("checks", warmed).Msg("warmed dispatch cache")
+		}
+	}
+
 	return &completedServerConfig{
 		gRPCServer:          grpcServer,
 		dispatchGRPCServer:  dispatchGrpcServer,
@@ -313,6 +496,7 @@ func (c *Config) Complete() (RunnableServer, error) {
 		streamingMiddleware: c.StreamingMiddleware,
 		presharedKeys:       c.PresharedKey,
 		telemetryReporter:   reporter,
+		warmupFunc:          warmupFunc,
 		closeFunc: func() {
 			if err := ds.Close(); err != nil {
 				log.Warn().Err(err).Msg("couldn't close datastore")
@@ -320,6 +504,19 @@ func (c *Config) Complete() (RunnableServer, error) {
 			if err := dispatcher.Close(); err != nil {
 				log.Warn().Err(err).Msg("couldn't close dispatcher")
 			}
+			if router != nil {
+				router.Close()
+			}
+			if writeTracker != nil {
+				if err := writeTracker.Close(); err != nil {
+					log.Warn().Err(err).Msg("couldn't close dispatch cache write tracker")
+				}
+			}
+			if materializedIndex != nil {
+				if err := materializedIndex.Close(); err != nil {
+					log.Warn().Err(err).Msg("couldn't close materialized index")
+				}
+			}
 			if cachingClusterDispatch == nil {
 				return
 			}
@@ -353,6 +550,7 @@ type completedServerConfig struct {
 	unaryMiddleware     []grpc.UnaryServerInterceptor
 	streamingMiddleware []grpc.StreamServerInterceptor
 	presharedKeys       []string
+	warmupFunc          func(context.Context)
 	closeFunc           func()
 }
 
@@ -383,6 +581,10 @@ func (c *completedServerConfig) DispatchNetDialContext(ctx context.Context, s st
 }
 
 func (c *completedServerConfig) Run(ctx context.Context) error {
+	// The dispatch cache is warmed before the servers start listening, so that the first
+	// requests they receive are served from the cache.
+	c.warmupFunc(ctx)
+
 	g, ctx := errgroup.WithContext(ctx)
 
 	stopOnCancel := func(stopFn func()) func() error {
@@ -38,17 +38,38 @@ func (c *Config) ToOption() ConfigOption {
 		to.DatastoreConfig = c.DatastoreConfig
 		to.Datastore = c.Datastore
 		to.NamespaceCacheConfig = c.NamespaceCacheConfig
+		to.RelationshipCacheEnabled = c.RelationshipCacheEnabled
+		to.RelationshipCacheConfig = c.RelationshipCacheConfig
+		to.RelationshipCacheMaxResultCost = c.RelationshipCacheMaxResultCost
 		to.SchemaPrefixesRequired = c.SchemaPrefixesRequired
 		to.DispatchServer = c.DispatchServer
 		to.DispatchMaxDepth = c.DispatchMaxDepth
+		to.DispatchBudgetMaxDispatches = c.DispatchBudgetMaxDispatches
+		to.DispatchBudgetMaxDatastoreQueries = c.DispatchBudgetMaxDatastoreQueries
 		to.DispatchUpstreamAddr = c.DispatchUpstreamAddr
+		to.DispatchUpstreamStaticPeers = c.DispatchUpstreamStaticPeers
 		to.DispatchUpstreamCAPath = c.DispatchUpstreamCAPath
+		to.DispatchHashringWeight = c.DispatchHashringWeight
 		to.DispatchClientMetricsPrefix = c.DispatchClientMetricsPrefix
 		to.DispatchClusterMetricsPrefix = c.DispatchClusterMetricsPrefix
 		to.Dispatcher = c.Dispatcher
+		to.DispatchHedgingEnabled = c.DispatchHedgingEnabled
+		to.DispatchHedgingInitialSlowValue = c.DispatchHedgingInitialSlowValue
+		to.DispatchHedgingMaxRequests = c.DispatchHedgingMaxRequests
+		to.DispatchHedgingQuantile = c.DispatchHedgingQuantile
+		to.DispatchHedgingLocally = c.DispatchHedgingLocally
+		to.DispatchFailoverEnabled = c.DispatchFailoverEnabled
+		to.DispatchLocalityAware = c.DispatchLocalityAware
+		to.DispatchMultiplexing = c.DispatchMultiplexing
 		to.DispatchCacheConfig = c.DispatchCacheConfig
 		to.ClusterDispatchCacheConfig = c.ClusterDispatchCacheConfig
+		to.DispatchCacheWriteAware = c.DispatchCacheWriteAware
+		to.DispatchMaterializedIndex = c.DispatchMaterializedIndex
+		to.DispatchCacheSnapshotDir = c.DispatchCacheSnapshotDir
+		to.DispatchCacheWarmupChecksPath = c.DispatchCacheWarmupChecksPath
+		to.DispatchCacheWarmupTimeout = c.DispatchCacheWarmupTimeout
 		to.DisableV1SchemaAPI = c.DisableV1SchemaAPI
+		to.EnableExperimentalAPI = c.EnableExperimentalAPI
 		to.DashboardAPI = c.DashboardAPI
 		to.MetricsAPI = c.MetricsAPI
 		to.UnaryMiddleware = c.UnaryMiddleware
@@ -175,6 +196,27 @@ func WithNamespaceCacheConfig(namespaceCacheConfig CacheConfig) ConfigOption {
 	}
 }
 
+// WithRelationshipCacheEnabled returns an option that can set RelationshipCacheEnabled on a Config
+func WithRelationshipCacheEnabled(relationshipCacheEnabled bool) ConfigOption {
+	return func(c *Config) {
+		c.RelationshipCacheEnabled = relationshipCacheEnabled
+	}
+}
+
+// WithRelationshipCacheConfig returns an option that can set RelationshipCacheConfig on a Config
+func WithRelationshipCacheConfig(relationshipCacheConfig CacheConfig) ConfigOption {
+	return func(c *Config) {
+		c.RelationshipCacheConfig = relationshipCacheConfig
+	}
+}
+
+// WithRelationshipCacheMaxResultCost returns an option that can set RelationshipCacheMaxResultCost on a Config
+func WithRelationshipCacheMaxResultCost(relationshipCacheMaxResultCost string) ConfigOption {
+	return func(c *Config) {
+		c.RelationshipCacheMaxResultCost = relationshipCacheMaxResultCost
+	}
+}
+
 // WithSchemaPrefixesRequired returns an option that can set SchemaPrefixesRequired on a Config
 func WithSchemaPrefixesRequired(schemaPrefixesRequired bool) ConfigOption {
 	return func(c *Config) {
@@ -196,6 +238,20 @@ func WithDispatchMaxDepth(dispatchMaxDepth uint32) ConfigOption {
 	}
 }
 
+// WithDispatchBudgetMaxDispatches returns an option that can set DispatchBudgetMaxDispatches on a Config
+func WithDispatchBudgetMaxDispatches(dispatchBudgetMaxDispatches uint32) ConfigOption {
+	return func(c *Config) {
+		c.DispatchBudgetMaxDispatches = dispatchBudgetMaxDispatches
+	}
+}
+
+// WithDispatchBudgetMaxDatastoreQueries returns an option that can set DispatchBudgetMaxDatastoreQueries on a Config
+func WithDispatchBudgetMaxDatastoreQueries(dispatchBudgetMaxDatastoreQueries uint32) ConfigOption {
+	return func(c *Config) {
+		c.DispatchBudgetMaxDatastoreQueries = dispatchBudgetMaxDatastoreQueries
+	}
+}
+
 // WithDispatchUpstreamAddr returns an option that can set DispatchUpstreamAddr on a Config
 func WithDispatchUpstreamAddr(dispatchUpstreamAddr string) ConfigOption {
 	return func(c *Config) {
@@ -203,6 +259,20 @@ func WithDispatchUpstreamAddr(dispatchUpstreamAddr string) ConfigOption {
 	}
 }
 
+// WithDispatchUpstreamStaticPeers returns an option that can append DispatchUpstreamStaticPeerss to Config.DispatchUpstreamStaticPeers
+func WithDispatchUpstreamStaticPeers(dispatchUpstreamStaticPeers string) ConfigOption {
+	return func(c *Config) {
+		c.DispatchUpstreamStaticPeers = append(c.DispatchUpstreamStaticPeers, dispatchUpstreamStaticPeers)
+	}
+}
+
+// SetDispatchUpstreamStaticPeers returns an option that can set DispatchUpstreamStaticPeers on a Config
+func SetDispatchUpstreamStaticPeers(dispatchUpstreamStaticPeers []string) ConfigOption {
+	return func(c *Config) {
+		c.DispatchUpstreamStaticPeers = dispatchUpstreamStaticPeers
+	}
+}
+
 // WithDispatchUpstreamCAPath returns an option that can set DispatchUpstreamCAPath on a Config
 func WithDispatchUpstreamCAPath(dispatchUpstreamCAPath string) ConfigOption {
 	return func(c *Config) {
@@ -210,6 +280,13 @@ func WithDispatchUpstreamCAPath(dispatchUpstreamCAPath string) ConfigOption {
 	}
 }
 
+// WithDispatchHashringWeight returns an option that can set DispatchHashringWeight on a Config
+func WithDispatchHashringWeight(dispatchHashringWeight uint16) ConfigOption {
+	return func(c *Config) {
+		c.DispatchHashringWeight = dispatchHashringWeight
+	}
+}
+
 // WithDispatchClientMetricsPrefix returns an option that can set DispatchClientMetricsPrefix on a Config
 func WithDispatchClientMetricsPrefix(dispatchClientMetricsPrefix string) ConfigOption {
 	return func(c *Config) {
@@ -231,6 +308,62 @@ func WithDispatcher(dispatcher dispatch.Dispatcher) ConfigOption {
 	}
 }
 
+// WithDispatchHedgingEnabled returns an option that can set DispatchHedgingEnabled on a Config
+func WithDispatchHedgingEnabled(dispatchHedgingEnabled bool) ConfigOption {
+	return func(c *Config) {
+		c.DispatchHedgingEnabled = dispatchHedgingEnabled
+	}
+}
+
+// WithDispatchHedgingInitialSlowValue returns an option that can set DispatchHedgingInitialSlowValue on a Config
+func WithDispatchHedgingInitialSlowValue(dispatchHedgingInitialSlowValue time.Duration) ConfigOption {
+	return func(c *Config) {
+		c.DispatchHedgingInitialSlowValue = dispatchHedgingInitialSlowValue
+	}
+}
+
+// WithDispatchHedgingMaxRequests returns an option that can set DispatchHedgingMaxRequests on a Config
+func WithDispatchHedgingMaxRequests(dispatchHedgingMaxRequests uint64) ConfigOption {
+	return func(c *Config) {
+		c.DispatchHedgingMaxRequests = dispatchHedgingMaxRequests
+	}
+}
+
+// WithDispatchHedgingQuantile returns an option that can set DispatchHedgingQuantile on a Config
+func WithDispatchHedgingQuantile(dispatchHedgingQuantile float64) ConfigOption {
+	return func(c *Config) {
+		c.DispatchHedgingQuantile = dispatchHedgingQuantile
+	}
+}
+
+// WithDispatchHedgingLocally returns an option that can set DispatchHedgingLocally on a Config
+func WithDispatchHedgingLocally(dispatchHedgingLocally bool) ConfigOption {
+	return func(c *Config) {
+		c.DispatchHedgingLocally = dispatchHedgingLocally
+	}
+}
+
+// WithDispatchFailoverEnabled returns an option that can set DispatchFailoverEnabled on a Config
+func WithDispatchFailoverEnabled(dispatchFailoverEnabled bool) ConfigOption {
+	return func(c *Config) {
+		c.DispatchFailoverEnabled = dispatchFailoverEnabled
+	}
+}
+
+// WithDispatchLocalityAware returns an option that can set DispatchLocalityAware on a Config
+func WithDispatchLocalityAware(dispatchLocalityAware bool) ConfigOption {
+	return func(c *Config) {
+		c.DispatchLocalityAware = dispatchLocalityAware
+	}
+}
+
+// WithDispatchMultiplexing returns an option that can set DispatchMultiplexing on a Config
+func WithDispatchMultiplexing(dispatchMultiplexing bool) ConfigOption {
+	return func(c *Config) {
+		c.DispatchMultiplexing = dispatchMultiplexing
+	}
+}
+
 // WithDispatchCacheConfig returns an option that can set DispatchCacheConfig on a Config
 func WithDispatchCacheConfig(dispatchCacheConfig CacheConfig) ConfigOption {
 	return func(c *Config) {
@@ -245,6 +378,41 @@ func WithClusterDispatchCacheConfig(clusterDispatchCacheConfig CacheConfig) Conf
 	}
 }
 
+// WithDispatchCacheWriteAware returns an option that can set DispatchCacheWriteAware on a Config
+func WithDispatchCacheWriteAware(dispatchCacheWriteAware bool) ConfigOption {
+	return func(c *Config) {
+		c.DispatchCacheWriteAware = dispatchCacheWriteAware
+	}
+}
+
+// WithDispatchMaterializedIndex returns an option that can set DispatchMaterializedIndex on a Config
+func WithDispatchMaterializedIndex(dispatchMaterializedIndex bool) ConfigOption {
+	return func(c *Config) {
+		c.DispatchMaterializedIndex = dispatchMaterializedIndex
+	}
+}
+
+// WithDispatchCacheSnapshotDir returns an option that can set DispatchCacheSnapshotDir on a Config
+func WithDispatchCacheSnapshotDir(dispatchCacheSnapshotDir string) ConfigOption {
+	return func(c *Config) {
+		c.DispatchCacheSnapshotDir = dispatchCacheSnapshotDir
+	}
+}
+
+// WithDispatchCacheWarmupChecksPath returns an option that can set DispatchCacheWarmupChecksPath on a Config
+func WithDispatchCacheWarmupChecksPath(dispatchCacheWarmupChecksPath string) ConfigOption {
+	return func(c *Config) {
+		c.DispatchCacheWarmupChecksPath = dispatchCacheWarmupChecksPath
+	}
+}
+
+// WithDispatchCacheWarmupTimeout returns an option that can set DispatchCacheWarmupTimeout on a Config
+func WithDispatchCacheWarmupTimeout(dispatchCacheWarmupTimeout time.Duration) ConfigOption {
+	return func(c *Config) {
+		c.DispatchCacheWarmupTimeout = dispatchCacheWarmupTimeout
+	}
+}
+
 // WithDisableV1SchemaAPI returns an option that can set DisableV1SchemaAPI on a Config
 func WithDisableV1SchemaAPI(disableV1SchemaAPI bool) ConfigOption {
 	return func(c *Config) {
@@ -252,6 +420,13 @@ func WithDisableV1SchemaAPI(disableV1SchemaAPI bool) ConfigOption {
 	}
 }
 
+// WithEnableExperimentalAPI returns an option that can set EnableExperimentalAPI on a Config
+func WithEnableExperimentalAPI(enableExperimentalAPI bool) ConfigOption {
+	return func(c *Config) {
+		c.EnableExperimentalAPI = enableExperimentalAPI
+	}
+}
+
 // WithDashboardAPI returns an option that can set DashboardAPI on a Config
 func WithDashboardAPI(dashboardAPI util.HTTPServerConfig) ConfigOption {
 	return func(c *Config) {
@@ -2,6 +2,7 @@ package testserver
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
@@ -17,6 +18,7 @@ import (
 	"github.com/authzed/spicedb/internal/services"
 	v1alpha1svc "github.com/authzed/spicedb/internal/services/v1alpha1"
 	"github.com/authzed/spicedb/pkg/cmd/util"
+	"github.com/authzed/spicedb/pkg/secrets"
 )
 
 const maxDepth = 50
@@ -39,13 +41,20 @@ func (c *Config) Complete() (RunnableTestServer, error) {
 
 	datastoreMiddleware := pertoken.NewMiddleware(c.LoadConfigs)
 
+	continuationKey, err := secrets.TokenBytes(32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate expansion continuation key: %w", err)
+	}
+
 	registerServices := func(srv *grpc.Server) {
 		services.RegisterGrpcServices(
 			srv,
 			dispatcher,
 			maxDepth,
+			continuationKey,
 			v1alpha1svc.PrefixNotRequired,
 			services.V1SchemaServiceEnabled,
+			services.ExperimentalServiceDisabled,
 		)
 	}
 	gRPCSrv, err := c.GRPCServer.Complete(zerolog.InfoLevel, registerServices,
@@ -4,8 +4,10 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"math"
 	"sort"
 	"sync"
+	"sync/atomic"
 )
 
 var (
@@ -23,13 +25,32 @@ type Member interface {
 	Key() string
 }
 
+// WeightedMember is a Member which is allotted virtual nodes in proportion to its weight.
+// Members which do not implement WeightedMember, or which have a weight of 0, are given
+// a weight of 1.
+type WeightedMember interface {
+	Member
+	Weight() uint16
+}
+
 // Hashring provides a ring consistent hash implementation using a configurable number of virtual
-// nodes. It is internally synchronized and thread-safe.
+// nodes. It is internally synchronized and thread-safe: members are found in an immutable
+// snapshot of the ring, which is replaced whenever members are added or removed, so that
+// finding members never waits on a lock.
 type Hashring struct {
 	hasher            HasherFunc
 	replicationFactor uint16
 
-	sync.RWMutex
+	// ring holds the current *ringSnapshot.
+	ring atomic.Value
+
+	// Mutex serializes changes to the members of the ring.
+	sync.Mutex
+}
+
+// ringSnapshot is the state of the ring at some point in time. It is never modified once it
+// has been stored in the Hashring.
+type ringSnapshot struct {
 	nodes        map[string]nodeRecord
 	virtualNodes virtualNodeList
 }
@@ -46,15 +67,21 @@ func NewHashring(hasher HasherFunc, replicationFactor uint16) *Hashring {
 		panic("replicationFactor must be at least 1")
 	}
 
-	return &Hashring{
+	h := &Hashring{
 		hasher:            hasher,
 		replicationFactor: replicationFactor,
-		nodes:             map[string]nodeRecord{},
 	}
+	h.ring.Store(&ringSnapshot{nodes: map[string]nodeRecord{}})
+	return h
+}
+
+func (h *Hashring) snapshot() *ringSnapshot {
+	return h.ring.Load().(*ringSnapshot)
 }
 
 // Add adds an object that implements the Member interface as a node in the
-// consistent hashring.
+// consistent hashring. The member is allotted replicationFactor virtual nodes
+// for each unit of its weight, if it implements WeightedMember.
 //
 // If a member with the same key is already in the hashring,
 // ErrMemberAlreadyExists is returned.
@@ -64,7 +91,8 @@ func (h *Hashring) Add(member Member) error {
 	h.Lock()
 	defer h.Unlock()
 
-	if _, ok := h.nodes[nodeKeyString]; ok {
+	current := h.snapshot()
+	if _, ok := current.nodes[nodeKeyString]; ok {
 		// already have node, bail
 		return ErrMemberAlreadyExists
 	}
@@ -78,13 +106,17 @@ func (h *Hashring) Add(member Member) error {
 		nil,
 	}
 
+	vnodeCount := virtualNodeCount(member, h.replicationFactor)
+	virtualNodes := make(virtualNodeList, 0, len(current.virtualNodes)+int(vnodeCount))
+	virtualNodes = append(virtualNodes, current.virtualNodes...)
+
 	// virtualNodeBuffer is a 10-byte array, where 8 bytes are the hash value of
 	// the member key, and the final 2 bytes are an offset of the virtual node
 	// itself. This value is then hashed to get the final hash value of the virtual node.
 	virtualNodeBuffer := make([]byte, 10)
 	binary.LittleEndian.PutUint64(virtualNodeBuffer, nodeHash)
 
-	for i := uint16(0); i < h.replicationFactor; i++ {
+	for i := uint16(0); i < vnodeCount; i++ {
 		binary.LittleEndian.PutUint16(virtualNodeBuffer[8:], i)
 		virtualNodeHash := h.hasher(virtualNodeBuffer)
 
@@ -94,14 +126,19 @@ func (h *Hashring) Add(member Member) error {
 		}
 
 		newNodeRecord.virtualNodes = append(newNodeRecord.virtualNodes, virtualNode)
-		h.virtualNodes = append(h.virtualNodes, virtualNode)
+		virtualNodes = append(virtualNodes, virtualNode)
 	}
 
-	sort.Sort(h.virtualNodes)
+	sort.Sort(virtualNodes)
 
-	// Add the node to our map of nodes
-	h.nodes[nodeKeyString] = newNodeRecord
+	// Add the node to a copy of our map of nodes
+	nodes := make(map[string]nodeRecord, len(current.nodes)+1)
+	for key, node := range current.nodes {
+		nodes[key] = node
+	}
+	nodes[nodeKeyString] = newNodeRecord
 
+	h.ring.Store(&ringSnapshot{nodes: nodes, virtualNodes: virtualNodes})
 	return nil
 }
 
@@ -114,45 +151,35 @@ func (h *Hashring) Remove(member Member) error {
 	h.Lock()
 	defer h.Unlock()
 
-	foundNode, ok := h.nodes[nodeKeyString]
+	current := h.snapshot()
+	foundNode, ok := current.nodes[nodeKeyString]
 	if !ok {
 		// don't have the node, bail
 		return ErrMemberNotFound
 	}
 
-	indexesToRemove := make([]int, 0, h.replicationFactor)
-	for _, vnode := range foundNode.virtualNodes {
-		vnodeIndex := sort.Search(len(h.virtualNodes), func(i int) bool {
-			return !h.virtualNodes[i].less(vnode)
-		})
-		if vnodeIndex >= len(h.virtualNodes) {
-			panic(fmt.Sprintf("unable to find vnode to remove: %020d:%020d:%s", vnode.hashvalue, vnode.members.hashvalue, vnode.members.nodeKey))
+	// The remaining virtual nodes are already in order, so filtering out those of the removed
+	// node leaves them sorted.
+	virtualNodes := make(virtualNodeList, 0, len(current.virtualNodes)-len(foundNode.virtualNodes))
+	for _, vnode := range current.virtualNodes {
+		if vnode.members.nodeKey != nodeKeyString {
+			virtualNodes = append(virtualNodes, vnode)
 		}
-
-		indexesToRemove = append(indexesToRemove, vnodeIndex)
 	}
 
-	sort.Slice(indexesToRemove, func(i, j int) bool {
-		// NOTE: this is a reverse sort!
-		return indexesToRemove[j] < indexesToRemove[i]
-	})
-
-	if len(indexesToRemove) != int(h.replicationFactor) {
-		panic(fmt.Sprintf("found wrong number of vnodes to remove: %d != %d", len(indexesToRemove), h.replicationFactor))
+	if len(current.virtualNodes)-len(virtualNodes) != len(foundNode.virtualNodes) {
+		panic(fmt.Sprintf("found wrong number of vnodes to remove: %d != %d", len(current.virtualNodes)-len(virtualNodes), len(foundNode.virtualNodes)))
 	}
 
-	for i, indexToRemove := range indexesToRemove {
-		// Swap this index for a later one
-		h.virtualNodes[indexToRemove] = h.virtualNodes[len(h.virtualNodes)-1-i]
+	// Remove the node from a copy of our map
+	nodes := make(map[string]nodeRecord, len(current.nodes)-1)
+	for key, node := range current.nodes {
+		if key != nodeKeyString {
+			nodes[key] = node
+		}
 	}
 
-	// Truncate and sort the nodelist
-	h.virtualNodes = h.virtualNodes[:len(h.virtualNodes)-len(indexesToRemove)]
-	sort.Sort(h.virtualNodes)
-
-	// Remove the node from our map
-	delete(h.nodes, nodeKeyString)
-
+	h.ring.Store(&ringSnapshot{nodes: nodes, virtualNodes: virtualNodes})
 	return nil
 }
 
@@ -161,24 +188,22 @@ func (h *Hashring) Remove(member Member) error {
 // If there are not enough members in the hashring to satisfy the request,
 // ErrNotEnoughMembers is returned.
 func (h *Hashring) FindN(key []byte, num uint8) ([]Member, error) {
-	h.RLock()
-	defer h.RUnlock()
-
-	if int(num) > len(h.nodes) {
+	ring := h.snapshot()
+	if int(num) > len(ring.nodes) {
 		return nil, ErrNotEnoughMembers
 	}
 
 	keyHash := h.hasher(key)
 
-	vnodeIndex := sort.Search(len(h.virtualNodes), func(i int) bool {
-		return h.virtualNodes[i].hashvalue >= keyHash
+	vnodeIndex := sort.Search(len(ring.virtualNodes), func(i int) bool {
+		return ring.virtualNodes[i].hashvalue >= keyHash
 	})
 
 	alreadyFoundNodeKeys := map[string]struct{}{}
 	foundNodes := make([]Member, 0, num)
-	for i := 0; i < len(h.virtualNodes) && len(foundNodes) < int(num); i++ {
-		boundedIndex := (i + vnodeIndex) % len(h.virtualNodes)
-		candidate := h.virtualNodes[boundedIndex]
+	for i := 0; i < len(ring.virtualNodes) && len(foundNodes) < int(num); i++ {
+		boundedIndex := (i + vnodeIndex) % len(ring.virtualNodes)
+		candidate := ring.virtualNodes[boundedIndex]
 		if _, ok := alreadyFoundNodeKeys[candidate.members.nodeKey]; !ok {
 			foundNodes = append(foundNodes, candidate.members.member)
 			alreadyFoundNodeKeys[candidate.members.nodeKey] = struct{}{}
@@ -190,12 +215,49 @@ func (h *Hashring) FindN(key []byte, num uint8) ([]Member, error) {
 
 // Members returns the current list of members of the Hashring.
 func (h *Hashring) Members() []Member {
-	h.RLock()
-	defer h.RUnlock()
+	ring := h.snapshot()
 
-	membersCopy := make([]Member, 0, len(h.nodes))
-	for _, nodeInfo := range h.nodes {
+	membersCopy := make([]Member, 0, len(ring.nodes))
+	for _, nodeInfo := range ring.nodes {
 		membersCopy = append(membersCopy, nodeInfo.member)
 	}
 	return membersCopy
 }
+
+// Ownership returns the fraction of the hash space owned by each member of the Hashring,
+// keyed by member key. With an even distribution of keys, each member is found first for
+// its fraction of keys.
+func (h *Hashring) Ownership() map[string]float64 {
+	ring := h.snapshot()
+
+	ownership := make(map[string]float64, len(ring.nodes))
+	if len(ring.virtualNodes) == 0 {
+		return ownership
+	}
+
+	// Each virtual node owns the hashes after those of the virtual node preceding it, up to
+	// and including its own, with the first owning the hashes which wrap around the ring.
+	previous := ring.virtualNodes[len(ring.virtualNodes)-1].hashvalue
+	for _, vnode := range ring.virtualNodes {
+		span := vnode.hashvalue - previous
+		if len(ring.virtualNodes) == 1 {
+			span = math.MaxUint64
+		}
+		ownership[vnode.members.nodeKey] += float64(span) / math.MaxUint64
+		previous = vnode.hashvalue
+	}
+	return ownership
+}
+
+func virtualNodeCount(member Member, replicationFactor uint16) uint16 {
+	weighted, ok := member.(WeightedMember)
+	if !ok || weighted.Weight() == 0 {
+		return replicationFactor
+	}
+
+	count := uint64(replicationFactor) * uint64(weighted.Weight())
+	if count > math.MaxUint16 {
+		return math.MaxUint16
+	}
+	return uint16(count)
+}
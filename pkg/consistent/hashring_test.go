@@ -42,8 +42,8 @@ func TestHashring(t *testing.T) {
 
 			require.NotNil(ring.hasher)
 			require.Equal(tc.replicationFactor, ring.replicationFactor)
-			require.Len(ring.virtualNodes, 0)
-			require.Len(ring.nodes, 0)
+			require.Len(ring.snapshot().virtualNodes, 0)
+			require.Len(ring.snapshot().nodes, 0)
 
 			successfulNodes := map[string]struct{}{}
 			for _, testNodeInfo := range tc.nodes {
@@ -54,8 +54,8 @@ func TestHashring(t *testing.T) {
 					successfulNodes[testNodeInfo.nodeKeyAndValue] = struct{}{}
 				}
 
-				require.Len(ring.virtualNodes, len(successfulNodes)*int(tc.replicationFactor))
-				require.Len(ring.nodes, len(successfulNodes))
+				require.Len(ring.snapshot().virtualNodes, len(successfulNodes)*int(tc.replicationFactor))
+				require.Len(ring.snapshot().nodes, len(successfulNodes))
 
 				// Try the find function
 				if len(successfulNodes) > 0 {
@@ -121,8 +121,8 @@ func TestHashring(t *testing.T) {
 					require.Equal(ErrMemberNotFound, err)
 				}
 
-				require.Len(ring.virtualNodes, len(successfulNodes)*int(tc.replicationFactor))
-				require.Len(ring.nodes, len(successfulNodes))
+				require.Len(ring.snapshot().virtualNodes, len(successfulNodes)*int(tc.replicationFactor))
+				require.Len(ring.snapshot().nodes, len(successfulNodes))
 			}
 		})
 	}
@@ -303,3 +303,60 @@ type member int
 func (m member) Key() string {
 	return fmt.Sprintf("member-%d", m)
 }
+
+type weightedMember struct {
+	member
+	weight uint16
+}
+
+func (wm weightedMember) Weight() uint16 {
+	return wm.weight
+}
+
+func TestWeightedBalance(t *testing.T) {
+	require := require.New(t)
+
+	ring := NewHashring(xxhash.Sum64, 100)
+
+	members := []weightedMember{{0, 1}, {1, 2}, {2, 4}, {3, 0}}
+	totalWeight := 0
+	for _, wm := range members {
+		require.NoError(ring.Add(wm))
+
+		weight := int(wm.weight)
+		if weight == 0 {
+			weight = 1
+		}
+		totalWeight += weight
+	}
+	require.Len(ring.snapshot().virtualNodes, totalWeight*100)
+
+	memberKeyCount := map[string]int{}
+	for i := 0; i < numTestKeys; i++ {
+		found, err := ring.FindN([]byte(strconv.Itoa(i)), 1)
+		require.NoError(err)
+		memberKeyCount[found[0].Key()]++
+	}
+
+	ownership := ring.Ownership()
+	totalOwnership := 0.0
+	for _, wm := range members {
+		weight := float64(wm.weight)
+		if weight == 0 {
+			weight = 1
+		}
+
+		// Each member should receive within 20% of its weighted share of the keys, and own
+		// close to the share of the hash space it is found for.
+		expectedShare := weight / float64(totalWeight)
+		keyShare := float64(memberKeyCount[wm.Key()]) / numTestKeys
+		require.InDelta(expectedShare, keyShare, expectedShare*.2)
+		require.InDelta(keyShare, ownership[wm.Key()], .01)
+
+		totalOwnership += ownership[wm.Key()]
+	}
+	require.InDelta(1.0, totalOwnership, .000001)
+
+	require.NoError(ring.Remove(members[2]))
+	require.Len(ring.snapshot().virtualNodes, (totalWeight-4)*100)
+}
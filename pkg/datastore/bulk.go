@@ -0,0 +1,102 @@
+package datastore
+
+import (
+	"context"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+)
+
+// BulkWriteRelationshipSource is a source of relationships to be bulk loaded.
+type BulkWriteRelationshipSource interface {
+	// Next returns the next relationship to be loaded, or nil once all relationships have
+	// been returned.
+	Next(ctx context.Context) (*v1.Relationship, error)
+}
+
+// BulkLoader is implemented by the read-write transactions of datastores which can create
+// relationships faster than WriteRelationships. The relationships are created at the
+// revision of the transaction, and are reported by Watch as if they had been created by
+// WriteRelationships.
+type BulkLoader interface {
+	// BulkLoad creates all of the relationships from the source, none of which may already
+	// exist, returning the number created.
+	BulkLoad(ctx context.Context, source BulkWriteRelationshipSource) (uint64, error)
+}
+
+// bulkLoadFallbackBatchSize is the number of relationships created by each call to
+// WriteRelationships when the transaction is not a BulkLoader.
+const bulkLoadFallbackBatchSize = 1000
+
+// BulkLoad creates the relationships from the source in the transaction, with the bulk load
+// fast path of the datastore if it has one, and in batches with WriteRelationships otherwise.
+func BulkLoad(ctx context.Context, rwt ReadWriteTransaction, source BulkWriteRelationshipSource) (uint64, error) {
+	if loader, ok := rwt.(BulkLoader); ok {
+		return loader.BulkLoad(ctx, source)
+	}
+
+	updates := make([]*v1.RelationshipUpdate, 0, bulkLoadFallbackBatchSize)
+	return BulkLoadInBatches(ctx, source, bulkLoadFallbackBatchSize, func(batch []*v1.Relationship) error {
+		updates = updates[:0]
+		for _, rel := range batch {
+			updates = append(updates, &v1.RelationshipUpdate{
+				Operation:    v1.RelationshipUpdate_OPERATION_CREATE,
+				Relationship: rel,
+			})
+		}
+		return rwt.WriteRelationships(updates)
+	})
+}
+
+// BulkLoadInBatches reads the relationships from the source in batches of up to batchSize,
+// calling writeBatch with each, and returns the number of relationships written. The batch
+// slice is reused between calls.
+func BulkLoadInBatches(
+	ctx context.Context,
+	source BulkWriteRelationshipSource,
+	batchSize int,
+	writeBatch func(batch []*v1.Relationship) error,
+) (uint64, error) {
+	var written uint64
+	batch := make([]*v1.Relationship, 0, batchSize)
+	for {
+		rel, err := source.Next(ctx)
+		if err != nil {
+			return written, err
+		}
+
+		if rel != nil {
+			batch = append(batch, rel)
+		}
+
+		if len(batch) > 0 && (len(batch) == batchSize || rel == nil) {
+			if err := writeBatch(batch); err != nil {
+				return written, err
+			}
+			written += uint64(len(batch))
+			batch = batch[:0]
+		}
+
+		if rel == nil {
+			return written, nil
+		}
+	}
+}
+
+// NewBulkSourceFromSlice returns a BulkWriteRelationshipSource of the relationships.
+func NewBulkSourceFromSlice(rels []*v1.Relationship) BulkWriteRelationshipSource {
+	return &sliceSource{rels}
+}
+
+type sliceSource struct {
+	remaining []*v1.Relationship
+}
+
+func (ss *sliceSource) Next(_ context.Context) (*v1.Relationship, error) {
+	if len(ss.remaining) == 0 {
+		return nil, nil
+	}
+
+	next := ss.remaining[0]
+	ss.remaining = ss.remaining[1:]
+	return next, nil
+}
@@ -40,6 +40,25 @@ const Ellipsis = "..."
 type RevisionChanges struct {
 	Revision Revision
 	Changes  []*core.RelationTupleUpdate
+
+	// IsCheckpoint, if true, indicates that the event carries no changes and that all changes
+	// at or before Revision have already been sent. Checkpoints are only sent to watchers which
+	// requested them with WithWatchCheckpoints.
+	IsCheckpoint bool
+}
+
+type watchCheckpointsKey struct{}
+
+// WithWatchCheckpoints returns a context which, when passed to Watch, requests that the datastore
+// also send checkpoint events as it advances past revisions at which nothing changed.
+func WithWatchCheckpoints(ctx context.Context) context.Context {
+	return context.WithValue(ctx, watchCheckpointsKey{}, true)
+}
+
+// WatchCheckpointsRequested returns whether the caller of Watch requested checkpoint events.
+func WatchCheckpointsRequested(ctx context.Context) bool {
+	requested, _ := ctx.Value(watchCheckpointsKey{}).(bool)
+	return requested
 }
 
 type Reader interface {
@@ -63,6 +82,10 @@ type Reader interface {
 
 	// ListNamespaces lists all namespaces defined.
 	ListNamespaces(ctx context.Context) ([]*core.NamespaceDefinition, error)
+
+	// CountRelationships returns the exact number of relationships that match the provided
+	// filter at the revision of the reader.
+	CountRelationships(ctx context.Context, filter *v1.RelationshipFilter) (uint64, error)
 }
 
 type ReadWriteTransaction interface {
@@ -108,7 +131,10 @@ type Datastore interface {
 
 	// Watch notifies the caller about all changes to tuples.
 	//
-	// All events following afterRevision will be sent to the caller.
+	// All events following afterRevision will be sent to the caller, and each carries changes.
+	// If the context was created by WithWatchCheckpoints, checkpoint events with no changes are
+	// also sent as the datastore advances past revisions at which nothing changed, but may be
+	// skipped if the caller is not keeping up.
 	Watch(ctx context.Context, afterRevision Revision) (<-chan *RevisionChanges, <-chan error)
 
 	// IsReady returns whether the datastore is ready to accept data. Datastores that require
@@ -125,11 +151,19 @@ type Datastore interface {
 
 // ObjectTypeStat represents statistics for a single object type (namespace).
 type ObjectTypeStat struct {
+	// Name is the name of the object type.
+	Name string
+
 	// NumRelations is the number of relations defined in a single object type.
 	NumRelations uint32
 
 	// NumPermissions is the number of permissions defined in a single object type.
 	NumPermissions uint32
+
+	// EstimatedRelationshipCount is a best-guess estimate of the number of relationships
+	// with a resource of the object type, as read from the counters maintained by the
+	// datastore.
+	EstimatedRelationshipCount uint64
 }
 
 // Stats represents statistics for the entire datastore.
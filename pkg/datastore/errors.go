@@ -2,6 +2,7 @@ package datastore
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/rs/zerolog"
 )
@@ -33,6 +34,10 @@ type ErrWatchCanceled struct{ error }
 // read-only mode.
 type ErrReadOnly struct{ error }
 
+// ErrHistoryUnavailable occurs when the relationships of a past time are read from a datastore
+// which has not archived its history as of that time.
+type ErrHistoryUnavailable struct{ error }
+
 // InvalidRevisionReason is the reason the revision could not be used.
 type InvalidRevisionReason int
 
@@ -111,6 +116,22 @@ func NewReadonlyErr() error {
 	}
 }
 
+// NewHistoryNotArchivedErr constructs an error for when the relationships of a past time
+// were read from a datastore which does not archive its history.
+func NewHistoryNotArchivedErr() error {
+	return ErrHistoryUnavailable{
+		error: fmt.Errorf("datastore does not archive relationship history"),
+	}
+}
+
+// NewHistoryNotRecordedErr constructs an error for when the relationships of a past time
+// were read from a datastore whose archived history begins after that time.
+func NewHistoryNotRecordedErr(asOf time.Time) error {
+	return ErrHistoryUnavailable{
+		error: fmt.Errorf("relationship history was not yet recorded as of %s", asOf.UTC().Format(time.RFC3339Nano)),
+	}
+}
+
 // NewInvalidRevisionErr constructs a new invalid revision error.
 func NewInvalidRevisionErr(revision Revision, reason InvalidRevisionReason) error {
 	switch reason {
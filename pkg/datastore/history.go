@@ -0,0 +1,33 @@
+package datastore
+
+import (
+	"context"
+	"time"
+
+	v1 "github.com/authzed/authzed-go/proto/authzed/api/v1"
+)
+
+// HistoryReader is implemented by datastores which archive the relationships removed by
+// garbage collection, so that the relationships which existed at any time since archiving
+// began can be read, even once that time has passed out of the garbage collection window.
+type HistoryReader interface {
+	// QueryRelationshipsAsOf reads the relationships matching the filter which existed at
+	// the given time. It returns an instance of ErrHistoryUnavailable if the history of the
+	// datastore is not archived, or was not yet recorded at that time.
+	QueryRelationshipsAsOf(ctx context.Context, asOf time.Time, filter *v1.RelationshipFilter) (RelationshipIterator, error)
+}
+
+// QueryRelationshipsAsOf reads the relationships matching the filter which existed at the
+// given time from the archived history of the datastore, returning an instance of
+// ErrHistoryUnavailable if the datastore is not a HistoryReader.
+func QueryRelationshipsAsOf(
+	ctx context.Context,
+	ds Datastore,
+	asOf time.Time,
+	filter *v1.RelationshipFilter,
+) (RelationshipIterator, error) {
+	if hr, ok := ds.(HistoryReader); ok {
+		return hr.QueryRelationshipsAsOf(ctx, asOf, filter)
+	}
+	return nil, NewHistoryNotArchivedErr()
+}
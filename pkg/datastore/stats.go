@@ -7,8 +7,8 @@ import (
 )
 
 // ComputeObjectTypeStats creates a list of object type stats from an input list of
-// parsed object types.
-func ComputeObjectTypeStats(objTypes []*core.NamespaceDefinition) []ObjectTypeStat {
+// parsed object types and the estimated number of relationships for each object type.
+func ComputeObjectTypeStats(objTypes []*core.NamespaceDefinition, relationshipCounts map[string]uint64) []ObjectTypeStat {
 	stats := make([]ObjectTypeStat, 0, len(objTypes))
 
 	for _, objType := range objTypes {
@@ -23,8 +23,10 @@ func ComputeObjectTypeStats(objTypes []*core.NamespaceDefinition) []ObjectTypeSt
 		}
 
 		stats = append(stats, ObjectTypeStat{
-			NumRelations:   relations,
-			NumPermissions: permissions,
+			Name:                       objType.Name,
+			NumRelations:               relations,
+			NumPermissions:             permissions,
+			EstimatedRelationshipCount: relationshipCounts[objType.Name],
 		})
 	}
 
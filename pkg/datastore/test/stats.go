@@ -2,6 +2,7 @@ package test
 
 import (
 	"context"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -25,6 +26,19 @@ func StatsTest(t *testing.T, tester DatastoreTester) {
 	require.Len(stats.ObjectTypeStatistics, 3, "must report object stats")
 	require.Greater(stats.EstimatedRelationshipCount, uint64(0), "must report some relationships")
 
+	expectedCounts := make(map[string]uint64)
+	for _, tpl := range testfixtures.StandardTuples {
+		objectType, _, _ := strings.Cut(tpl, ":")
+		expectedCounts[objectType]++
+	}
+	for _, objTypeStats := range stats.ObjectTypeStatistics {
+		require.Equal(
+			expectedCounts[objTypeStats.Name],
+			objTypeStats.EstimatedRelationshipCount,
+			"must report the relationships of object type %s", objTypeStats.Name,
+		)
+	}
+
 	newStats, err := ds.Statistics(ctx)
 	require.NoError(err)
 	require.Equal(newStats.UniqueID, stats.UniqueID, "unique ID must be stable")
@@ -471,6 +471,168 @@ func DeleteRelationshipsTest(t *testing.T, tester DatastoreTester) {
 	}
 }
 
+// CountRelationshipsTest tests whether or not relationships matching a filter are counted
+// exactly at the revision of the reader for a particular datastore.
+func CountRelationshipsTest(t *testing.T, tester DatastoreTester) {
+	ctx := context.Background()
+
+	ds, err := tester.New(0, veryLargeGCWindow, 1)
+	require.NoError(t, err)
+	defer ds.Close()
+
+	setupDatastore(ds, require.New(t))
+
+	var updates []*v1.RelationshipUpdate
+	for i := 0; i < 10; i++ {
+		updates = append(updates, &v1.RelationshipUpdate{
+			Operation:    v1.RelationshipUpdate_OPERATION_CREATE,
+			Relationship: makeTestRelationship(fmt.Sprintf("resource%d", i%3), fmt.Sprintf("user%d", i)),
+		})
+	}
+	written, err := ds.ReadWriteTx(ctx, func(ctx context.Context, rwt datastore.ReadWriteTransaction) error {
+		return rwt.WriteRelationships(updates)
+	})
+	require.NoError(t, err)
+
+	deleted, err := ds.ReadWriteTx(ctx, func(ctx context.Context, rwt datastore.ReadWriteTransaction) error {
+		// Touching an existing relationship does not change the count.
+		return rwt.WriteRelationships([]*v1.RelationshipUpdate{
+			{Operation: v1.RelationshipUpdate_OPERATION_DELETE, Relationship: makeTestRelationship("resource0", "user0")},
+			{Operation: v1.RelationshipUpdate_OPERATION_TOUCH, Relationship: makeTestRelationship("resource1", "user1")},
+		})
+	})
+	require.NoError(t, err)
+
+	table := []struct {
+		name            string
+		filter          *v1.RelationshipFilter
+		expectedWritten uint64
+		expectedDeleted uint64
+	}{
+		{
+			"resourceType",
+			&v1.RelationshipFilter{ResourceType: testResourceNamespace},
+			10,
+			9,
+		},
+		{
+			"resourceID",
+			&v1.RelationshipFilter{ResourceType: testResourceNamespace, OptionalResourceId: "resource0"},
+			4,
+			3,
+		},
+		{
+			"relation",
+			&v1.RelationshipFilter{ResourceType: testResourceNamespace, OptionalRelation: "writer"},
+			0,
+			0,
+		},
+		{
+			"subjectID",
+			&v1.RelationshipFilter{
+				ResourceType:          testResourceNamespace,
+				OptionalSubjectFilter: &v1.SubjectFilter{SubjectType: testUserNamespace, OptionalSubjectId: "user1"},
+			},
+			1,
+			1,
+		},
+		{
+			"subjectRelation",
+			&v1.RelationshipFilter{
+				ResourceType:          testResourceNamespace,
+				OptionalResourceId:    "resource0",
+				OptionalRelation:      testReaderRelation,
+				OptionalSubjectFilter: &v1.SubjectFilter{SubjectType: testUserNamespace, OptionalRelation: &v1.SubjectFilter_RelationFilter{Relation: ""}},
+			},
+			4,
+			3,
+		},
+		{
+			"otherResourceType",
+			&v1.RelationshipFilter{ResourceType: testUserNamespace},
+			0,
+			0,
+		},
+	}
+
+	for _, tt := range table {
+		t.Run(tt.name, func(t *testing.T) {
+			require := require.New(t)
+
+			count, err := ds.SnapshotReader(written).CountRelationships(ctx, tt.filter)
+			require.NoError(err)
+			require.Equal(tt.expectedWritten, count)
+
+			count, err = ds.SnapshotReader(deleted).CountRelationships(ctx, tt.filter)
+			require.NoError(err)
+			require.Equal(tt.expectedDeleted, count)
+		})
+	}
+}
+
+// BulkLoadTest tests whether relationships bulk loaded into a particular datastore are
+// written at the revision of the transaction and reported by Watch.
+func BulkLoadTest(t *testing.T, tester DatastoreTester) {
+	require := require.New(t)
+
+	ds, err := tester.New(0, veryLargeGCWindow, 1)
+	require.NoError(err)
+	defer ds.Close()
+
+	setupRevision := setupDatastore(ds, require)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	startRevision, err := ds.HeadRevision(ctx)
+	require.NoError(err)
+
+	changes, errchan := ds.Watch(ctx, startRevision)
+	require.Zero(len(errchan))
+
+	// Enough relationships to be loaded in more than one batch, but few enough to be
+	// loaded within the mutation limit of a single Spanner commit.
+	const numRelationships = 1100
+
+	rels := make([]*v1.Relationship, 0, numRelationships)
+	expectedUpdates := make([]*v1.RelationshipUpdate, 0, numRelationships)
+	for i := 0; i < numRelationships; i++ {
+		rel := makeTestRelationship(fmt.Sprintf("resource%d", i%10), fmt.Sprintf("user%d", i))
+		rels = append(rels, rel)
+		expectedUpdates = append(expectedUpdates, &v1.RelationshipUpdate{
+			Operation:    v1.RelationshipUpdate_OPERATION_TOUCH,
+			Relationship: rel,
+		})
+	}
+
+	var loaded uint64
+	loadedRevision, err := ds.ReadWriteTx(ctx, func(ctx context.Context, rwt datastore.ReadWriteTransaction) error {
+		var err error
+		loaded, err = datastore.BulkLoad(ctx, rwt, datastore.NewBulkSourceFromSlice(rels))
+		return err
+	})
+	require.NoError(err)
+	require.Equal(uint64(numRelationships), loaded)
+
+	filter := &v1.RelationshipFilter{ResourceType: testResourceNamespace}
+	count, err := ds.SnapshotReader(setupRevision).CountRelationships(ctx, filter)
+	require.NoError(err)
+	require.Zero(count)
+
+	count, err = ds.SnapshotReader(loadedRevision).CountRelationships(ctx, filter)
+	require.NoError(err)
+	require.Equal(uint64(numRelationships), count)
+
+	verifyUpdates(require, [][]*v1.RelationshipUpdate{expectedUpdates}, changes, errchan, false)
+
+	// Relationships which already exist cannot be bulk loaded.
+	_, err = ds.ReadWriteTx(ctx, func(ctx context.Context, rwt datastore.ReadWriteTransaction) error {
+		_, err := datastore.BulkLoad(ctx, rwt, datastore.NewBulkSourceFromSlice(rels[:1]))
+		return err
+	})
+	require.Error(err)
+}
+
 // InvalidReadsTest tests whether or not the requirements for reading via
 // invalid revisions hold for a particular datastore.
 func InvalidReadsTest(t *testing.T, tester DatastoreTester) {
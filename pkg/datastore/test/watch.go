@@ -251,3 +251,103 @@ func WatchCancelTest(t *testing.T, tester DatastoreTester) {
 		}
 	}
 }
+
+// WatchCheckpointTest tests whether or not checkpoints are sent only to watchers which request
+// them, and only once all of the changes at or before the checkpoint have been sent.
+func WatchCheckpointTest(t *testing.T, tester DatastoreTester) {
+	require := require.New(t)
+
+	ds, err := tester.New(0, veryLargeGCWindow, 16)
+	require.NoError(err)
+
+	startWatchRevision := setupDatastore(ds, require)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	changes, errchan := ds.Watch(ctx, startWatchRevision)
+	require.Zero(len(errchan))
+
+	checkpoints, checkpointErrchan := ds.Watch(datastore.WithWatchCheckpoints(ctx), startWatchRevision)
+	require.Zero(len(checkpointErrchan))
+
+	expectedChanges := []*core.RelationTupleUpdate{tuple.Touch(makeTestTuple("test", "test"))}
+	changedRevision, err := ds.ReadWriteTx(ctx, func(ctx context.Context, rwt datastore.ReadWriteTransaction) error {
+		return rwt.WriteRelationships([]*v1.RelationshipUpdate{{
+			Operation:    v1.RelationshipUpdate_OPERATION_CREATE,
+			Relationship: makeTestRelationship("test", "test"),
+		}})
+	})
+	require.NoError(err)
+
+	// A transaction which changes no relationships advances the datastore without a change.
+	unchangedRevision, err := ds.ReadWriteTx(ctx, func(ctx context.Context, rwt datastore.ReadWriteTransaction) error {
+		return rwt.WriteNamespaces(testUserNS)
+	})
+	require.NoError(err)
+
+	// The watcher which requested checkpoints eventually receives one at or after the revision at
+	// which nothing changed, and never one at or after a change which it has not yet received.
+	lastRevision := startWatchRevision
+	receivedChange := false
+	for checkpointed := false; !checkpointed; {
+		changeWait := time.NewTimer(5 * time.Second)
+		select {
+		case change, ok := <-checkpoints:
+			changeWait.Stop()
+			if !ok {
+				require.Fail("Watch closed", "error: %v", <-checkpointErrchan)
+			}
+
+			require.True(change.Revision.GreaterThanOrEqual(lastRevision), "revisions out of order")
+			lastRevision = change.Revision
+
+			if !change.IsCheckpoint {
+				if len(change.Changes) > 0 {
+					require.True(change.Revision.Equal(changedRevision))
+					require.Empty(cmp.Diff(expectedChanges, change.Changes, protocmp.Transform()))
+					receivedChange = true
+				}
+				continue
+			}
+
+			require.Empty(change.Changes)
+			if change.Revision.GreaterThanOrEqual(changedRevision) {
+				require.True(receivedChange, "checkpoint sent before the change it covers")
+			}
+			checkpointed = change.Revision.GreaterThanOrEqual(unchangedRevision)
+		case <-changeWait.C:
+			require.Fail("Timed out", "waiting for a checkpoint at %s", unchangedRevision)
+		}
+	}
+
+	// The watcher which did not request checkpoints receives the change, and nothing further as
+	// the datastore advances without changes.
+	receivedChange = false
+	for quiet := false; !quiet; {
+		changeWait := time.NewTimer(1 * time.Second)
+		select {
+		case change, ok := <-changes:
+			changeWait.Stop()
+			if !ok {
+				require.Fail("Watch closed", "error: %v", <-errchan)
+			}
+
+			require.False(change.IsCheckpoint, "unrequested checkpoint at %s", change.Revision)
+			if len(change.Changes) > 0 {
+				require.False(receivedChange)
+				require.True(change.Revision.Equal(changedRevision))
+				require.Empty(cmp.Diff(expectedChanges, change.Changes, protocmp.Transform()))
+				receivedChange = true
+
+				_, err := ds.ReadWriteTx(ctx, func(ctx context.Context, rwt datastore.ReadWriteTransaction) error {
+					return rwt.WriteNamespaces(testUserNS)
+				})
+				require.NoError(err)
+			}
+		case <-changeWait.C:
+			require.True(receivedChange, "change was never received")
+			quiet = true
+		}
+	}
+}
@@ -0,0 +1,198 @@
+// Package discovery implements gRPC resolvers for discovering the peer nodes of a
+// dispatch cluster, for use with the consistent hashring balancer.
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc/resolver"
+
+	"github.com/authzed/spicedb/pkg/balancer"
+)
+
+// RegisterResolvers registers all of the peer discovery resolvers with gRPC, using their
+// default configuration.
+func RegisterResolvers() {
+	resolver.Register(NewSRVResolverBuilder(DefaultSRVRefreshInterval))
+	resolver.Register(NewFileResolverBuilder())
+	resolver.Register(NewStaticResolverBuilder())
+}
+
+// peer is a peer node of the dispatch cluster. Peers with a weight greater than 1 are
+// sent a proportionally larger share of requests by the consistent hashring balancer.
+type peer struct {
+	address string
+	weight  uint16
+}
+
+// resolveFunc returns the current set of peers.
+type resolveFunc func(ctx context.Context) ([]peer, error)
+
+// peerResolver resolves the set of peers whenever gRPC requests it, at a fixed interval
+// and whenever signaled that the peers have changed, sending the peers to the client
+// connection and logging any changes in membership.
+type peerResolver struct {
+	target  string
+	cc      resolver.ClientConn
+	resolve resolveFunc
+
+	cancel     context.CancelFunc
+	resolveNow chan struct{}
+	done       chan struct{}
+
+	// current is the set of peers last sent to the client connection, keyed by address. It is
+	// only accessed by the goroutine started in newPeerResolver.
+	current map[string]peer
+}
+
+func newPeerResolver(
+	target string,
+	cc resolver.ClientConn,
+	resolve resolveFunc,
+	refreshInterval time.Duration,
+	changed <-chan struct{},
+) *peerResolver {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &peerResolver{
+		target:     target,
+		cc:         cc,
+		resolve:    resolve,
+		cancel:     cancel,
+		resolveNow: make(chan struct{}, 1),
+		done:       make(chan struct{}),
+		current:    map[string]peer{},
+	}
+
+	go r.run(ctx, refreshInterval, changed)
+	return r
+}
+
+// ResolveNow implements the resolver.Resolver interface.
+func (r *peerResolver) ResolveNow(resolver.ResolveNowOptions) {
+	select {
+	case r.resolveNow <- struct{}{}:
+	default:
+	}
+}
+
+// Close implements the resolver.Resolver interface.
+func (r *peerResolver) Close() {
+	r.cancel()
+	<-r.done
+}
+
+func (r *peerResolver) run(ctx context.Context, refreshInterval time.Duration, changed <-chan struct{}) {
+	defer close(r.done)
+
+	var refresh <-chan time.Time
+	if refreshInterval > 0 {
+		ticker := time.NewTicker(refreshInterval)
+		defer ticker.Stop()
+		refresh = ticker.C
+	}
+
+	for {
+		r.update(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-r.resolveNow:
+		case <-refresh:
+		case <-changed:
+		}
+	}
+}
+
+func (r *peerResolver) update(ctx context.Context) {
+	peers, err := r.resolve(ctx)
+	if err != nil {
+		if ctx.Err() != nil {
+			return
+		}
+
+		log.Warn().Err(err).Str("target", r.target).Msg("unable to resolve dispatch cluster peers")
+		r.cc.ReportError(err)
+		return
+	}
+
+	resolved := make(map[string]peer, len(peers))
+	for _, p := range peers {
+		resolved[p.address] = p
+	}
+
+	added := difference(resolved, r.current)
+	removed := difference(r.current, resolved)
+	if len(added) > 0 || len(removed) > 0 {
+		log.Info().
+			Str("target", r.target).
+			Strs("added", added).
+			Strs("removed", removed).
+			Int("peers", len(resolved)).
+			Msg("dispatch cluster membership changed")
+	}
+	r.current = resolved
+
+	addresses := make([]resolver.Address, 0, len(resolved))
+	for _, address := range sortedKeys(resolved) {
+		addresses = append(addresses, balancer.WithWeight(resolver.Address{Addr: address}, resolved[address].weight))
+	}
+
+	if err := r.cc.UpdateState(resolver.State{Addresses: addresses}); err != nil {
+		log.Warn().Err(err).Str("target", r.target).Msg("unable to update dispatch cluster peers")
+	}
+}
+
+// parsePeers parses a list of peer addresses separated by commas or whitespace, ignoring
+// empty entries. Each address may be followed by `=` and the weight of the peer.
+func parsePeers(list string) ([]peer, error) {
+	entries := strings.FieldsFunc(list, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t' || r == '\n' || r == '\r'
+	})
+
+	peers := make([]peer, 0, len(entries))
+	for _, entry := range entries {
+		address, weightString, weighted := strings.Cut(entry, "=")
+
+		p := peer{address: address, weight: 1}
+		if weighted {
+			weight, err := strconv.ParseUint(weightString, 10, 16)
+			if err != nil {
+				return nil, fmt.Errorf("invalid weight for peer %s: %w", address, err)
+			}
+			p.weight = uint16(weight)
+		}
+		peers = append(peers, p)
+	}
+	return peers, nil
+}
+
+func difference(set, other map[string]peer) []string {
+	diff := make([]string, 0)
+	for key := range set {
+		if _, ok := other[key]; !ok {
+			diff = append(diff, key)
+		}
+	}
+	sort.Strings(diff)
+	return diff
+}
+
+func sortedKeys(set map[string]peer) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func targetPath(target resolver.Target) string {
+	return strings.TrimPrefix(target.URL.Path, "/")
+}
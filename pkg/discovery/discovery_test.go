@@ -0,0 +1,151 @@
+package discovery
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/goleak"
+	"google.golang.org/grpc/resolver"
+
+	"github.com/authzed/spicedb/pkg/balancer"
+)
+
+const (
+	waitFor = 5 * time.Second
+	tick    = 10 * time.Millisecond
+)
+
+type fakeClientConn struct {
+	resolver.ClientConn
+
+	sync.Mutex
+	peers   []string
+	weights map[string]uint16
+	err     error
+}
+
+func (cc *fakeClientConn) UpdateState(state resolver.State) error {
+	cc.Lock()
+	defer cc.Unlock()
+
+	cc.peers = make([]string, 0, len(state.Addresses))
+	cc.weights = make(map[string]uint16, len(state.Addresses))
+	for _, address := range state.Addresses {
+		cc.peers = append(cc.peers, address.Addr)
+		cc.weights[address.Addr] = balancer.WeightOf(address)
+	}
+	cc.err = nil
+	return nil
+}
+
+func (cc *fakeClientConn) ReportError(err error) {
+	cc.Lock()
+	defer cc.Unlock()
+	cc.err = err
+}
+
+func (cc *fakeClientConn) requirePeers(t *testing.T, expected []string) {
+	require.Eventually(t, func() bool {
+		cc.Lock()
+		defer cc.Unlock()
+		return cc.err == nil && assert.ObjectsAreEqual(expected, cc.peers)
+	}, waitFor, tick)
+}
+
+func (cc *fakeClientConn) requireWeight(t *testing.T, peer string, expected uint16) {
+	cc.Lock()
+	defer cc.Unlock()
+	require.Equal(t, expected, cc.weights[peer])
+}
+
+func parseTarget(t *testing.T, target string) resolver.Target {
+	u, err := url.Parse(target)
+	require.NoError(t, err)
+	return resolver.Target{URL: *u}
+}
+
+func TestStaticResolver(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	cc := &fakeClientConn{}
+	target := parseTarget(t, StaticTarget([]string{"spicedb-2:50053=3", "spicedb-1:50053", "spicedb-2:50053=3"}))
+	r, err := NewStaticResolverBuilder().Build(target, cc, resolver.BuildOptions{})
+	require.NoError(t, err)
+	defer r.Close()
+
+	cc.requirePeers(t, []string{"spicedb-1:50053", "spicedb-2:50053"})
+	cc.requireWeight(t, "spicedb-1:50053", 1)
+	cc.requireWeight(t, "spicedb-2:50053", 3)
+
+	_, err = NewStaticResolverBuilder().Build(parseTarget(t, "static:///"), cc, resolver.BuildOptions{})
+	require.ErrorIs(t, err, errNoStaticPeers)
+
+	_, err = NewStaticResolverBuilder().Build(parseTarget(t, "static:///spicedb-1:50053=heavy"), cc, resolver.BuildOptions{})
+	require.Error(t, err)
+}
+
+func TestSRVResolver(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	var lock sync.Mutex
+	records := []*net.SRV{
+		{Target: "spicedb-1.example.com.", Port: 50053},
+		{Target: "spicedb-2.example.com.", Port: 50053, Weight: 2},
+	}
+
+	builder := &srvResolverBuilder{
+		refreshInterval: tick,
+		lookupSRV: func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+			require.Equal(t, "_dispatch._tcp.example.com", name)
+
+			lock.Lock()
+			defer lock.Unlock()
+			return name, records, nil
+		},
+	}
+
+	cc := &fakeClientConn{}
+	r, err := builder.Build(parseTarget(t, "dns-srv:///_dispatch._tcp.example.com"), cc, resolver.BuildOptions{})
+	require.NoError(t, err)
+	defer r.Close()
+
+	cc.requirePeers(t, []string{"spicedb-1.example.com:50053", "spicedb-2.example.com:50053"})
+	cc.requireWeight(t, "spicedb-1.example.com:50053", 1)
+	cc.requireWeight(t, "spicedb-2.example.com:50053", 2)
+
+	lock.Lock()
+	records = records[1:]
+	lock.Unlock()
+
+	cc.requirePeers(t, []string{"spicedb-2.example.com:50053"})
+}
+
+func TestFileResolver(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	path := filepath.Join(t.TempDir(), "peers")
+	require.NoError(t, os.WriteFile(path, []byte("# dispatch peers\nspicedb-1:50053\n\nspicedb-2:50053\n"), 0o600))
+
+	cc := &fakeClientConn{}
+	r, err := NewFileResolverBuilder().Build(parseTarget(t, "peers-file://"+path), cc, resolver.BuildOptions{})
+	require.NoError(t, err)
+	defer r.Close()
+
+	cc.requirePeers(t, []string{"spicedb-1:50053", "spicedb-2:50053"})
+
+	// Replace the file atomically, as is done for Kubernetes ConfigMaps.
+	replacement := path + ".new"
+	require.NoError(t, os.WriteFile(replacement, []byte("spicedb-2:50053\nspicedb-3:50053 4\n"), 0o600))
+	require.NoError(t, os.Rename(replacement, path))
+
+	cc.requirePeers(t, []string{"spicedb-2:50053", "spicedb-3:50053"})
+	cc.requireWeight(t, "spicedb-3:50053", 4)
+}
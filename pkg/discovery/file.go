@@ -0,0 +1,134 @@
+package discovery
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc/resolver"
+)
+
+// FileScheme is the scheme of targets naming a file listing the peers of the dispatch
+// cluster, e.g. `peers-file:///etc/spicedb/peers`. The file lists one peer address per
+// line, optionally followed by the peer's weight on the hashring, ignoring blank lines and
+// those starting with `#`. The file is watched for changes.
+const FileScheme = "peers-file"
+
+var errNoFilePath = errors.New("no peer file path in target")
+
+// NewFileResolverBuilder creates a resolver.Builder resolving targets with the FileScheme.
+func NewFileResolverBuilder() resolver.Builder {
+	return fileResolverBuilder{}
+}
+
+type fileResolverBuilder struct{}
+
+func (fileResolverBuilder) Scheme() string {
+	return FileScheme
+}
+
+func (fileResolverBuilder) Build(target resolver.Target, cc resolver.ClientConn, _ resolver.BuildOptions) (resolver.Resolver, error) {
+	path := filepath.Clean("/" + targetPath(target))
+	if path == "/" {
+		return nil, errNoFilePath
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("unable to watch peer file: %w", err)
+	}
+
+	// The directory is watched rather than the file itself, so that the file can be replaced
+	// atomically by renaming another over it, as is done for Kubernetes ConfigMaps.
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		watcher.Close()
+		return nil, fmt.Errorf("unable to watch peer file: %w", err)
+	}
+
+	changed := make(chan struct{}, 1)
+	go forwardFileChanges(watcher, path, changed)
+
+	return &fileResolver{
+		peerResolver: newPeerResolver(target.URL.String(), cc, func(ctx context.Context) ([]peer, error) {
+			return readPeerFile(path)
+		}, 0, changed),
+		watcher: watcher,
+	}, nil
+}
+
+type fileResolver struct {
+	*peerResolver
+	watcher *fsnotify.Watcher
+}
+
+// Close implements the resolver.Resolver interface.
+func (r *fileResolver) Close() {
+	r.watcher.Close()
+	r.peerResolver.Close()
+}
+
+func forwardFileChanges(watcher *fsnotify.Watcher, path string, changed chan<- struct{}) {
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+
+			if filepath.Clean(event.Name) != path {
+				continue
+			}
+
+			select {
+			case changed <- struct{}{}:
+			default:
+			}
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Warn().Err(err).Str("path", path).Msg("error watching peer file")
+		}
+	}
+}
+
+func readPeerFile(path string) ([]peer, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read peer file: %w", err)
+	}
+
+	var peers []peer
+	scanner := bufio.NewScanner(bytes.NewReader(contents))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		p := peer{address: fields[0], weight: 1}
+		switch len(fields) {
+		case 1:
+		case 2:
+			weight, err := strconv.ParseUint(fields[1], 10, 16)
+			if err != nil {
+				return nil, fmt.Errorf("invalid weight for peer %s: %w", p.address, err)
+			}
+			p.weight = uint16(weight)
+		default:
+			return nil, fmt.Errorf("invalid line in peer file: %q", scanner.Text())
+		}
+		peers = append(peers, p)
+	}
+
+	return peers, scanner.Err()
+}
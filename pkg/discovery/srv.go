@@ -0,0 +1,71 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/resolver"
+)
+
+const (
+	// SRVScheme is the scheme of targets naming a DNS SRV record listing the peers of the
+	// dispatch cluster, e.g. `dns-srv:///_dispatch._tcp.spicedb.example.com`. The weight
+	// of each record is used as the weight of its peer on the hashring.
+	SRVScheme = "dns-srv"
+
+	// DefaultSRVRefreshInterval is the default interval at which SRV records are looked up.
+	DefaultSRVRefreshInterval = 30 * time.Second
+)
+
+var errNoSRVName = errors.New("no SRV record name in target")
+
+type lookupSRVFunc func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+
+// NewSRVResolverBuilder creates a resolver.Builder resolving targets with the SRVScheme,
+// looking up the named SRV record at the given interval.
+func NewSRVResolverBuilder(refreshInterval time.Duration) resolver.Builder {
+	return &srvResolverBuilder{
+		refreshInterval: refreshInterval,
+		lookupSRV:       net.DefaultResolver.LookupSRV,
+	}
+}
+
+type srvResolverBuilder struct {
+	refreshInterval time.Duration
+	lookupSRV       lookupSRVFunc
+}
+
+func (b *srvResolverBuilder) Scheme() string {
+	return SRVScheme
+}
+
+func (b *srvResolverBuilder) Build(target resolver.Target, cc resolver.ClientConn, _ resolver.BuildOptions) (resolver.Resolver, error) {
+	name := targetPath(target)
+	if name == "" {
+		return nil, errNoSRVName
+	}
+
+	return newPeerResolver(target.URL.String(), cc, func(ctx context.Context) ([]peer, error) {
+		_, records, err := b.lookupSRV(ctx, "", "", name)
+		if err != nil {
+			return nil, fmt.Errorf("unable to look up SRV record %s: %w", name, err)
+		}
+
+		// The weight of each record is used as the weight of its peer, so that each peer can
+		// advertise its capacity when registering itself.
+		peers := make([]peer, 0, len(records))
+		for _, record := range records {
+			host := strings.TrimSuffix(record.Target, ".")
+			peers = append(peers, peer{
+				address: net.JoinHostPort(host, strconv.Itoa(int(record.Port))),
+				weight:  record.Weight,
+			})
+		}
+		return peers, nil
+	}, b.refreshInterval, nil), nil
+}
@@ -0,0 +1,46 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"google.golang.org/grpc/resolver"
+)
+
+// StaticScheme is the scheme of targets listing the peers of the dispatch cluster directly,
+// e.g. `static:///spicedb-1:50053,spicedb-2:50053`. Each peer may be followed by its weight
+// on the hashring, e.g. `static:///spicedb-1:50053=2,spicedb-2:50053`.
+const StaticScheme = "static"
+
+var errNoStaticPeers = errors.New("no peers listed in static target")
+
+// StaticTarget returns the target resolving to the given list of peers.
+func StaticTarget(peers []string) string {
+	return StaticScheme + ":///" + strings.Join(peers, ",")
+}
+
+// NewStaticResolverBuilder creates a resolver.Builder resolving targets with the StaticScheme.
+func NewStaticResolverBuilder() resolver.Builder {
+	return staticResolverBuilder{}
+}
+
+type staticResolverBuilder struct{}
+
+func (staticResolverBuilder) Scheme() string {
+	return StaticScheme
+}
+
+func (staticResolverBuilder) Build(target resolver.Target, cc resolver.ClientConn, _ resolver.BuildOptions) (resolver.Resolver, error) {
+	peers, err := parsePeers(targetPath(target))
+	if err != nil {
+		return nil, err
+	}
+	if len(peers) == 0 {
+		return nil, errNoStaticPeers
+	}
+
+	return newPeerResolver(target.URL.String(), cc, func(ctx context.Context) ([]peer, error) {
+		return peers, nil
+	}, 0, nil), nil
+}
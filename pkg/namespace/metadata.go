@@ -1,6 +1,8 @@
 package namespace
 
 import (
+	"strings"
+
 	"google.golang.org/protobuf/types/known/anypb"
 
 	core "github.com/authzed/spicedb/pkg/proto/core/v1"
@@ -72,6 +74,28 @@ func AddComment(metadata *core.Metadata, comment string) (*core.Metadata, error)
 	return metadata, nil
 }
 
+// MaterializeDirective is the text of a line comment which, when placed on a relation in a
+// schema, marks the relation to have the transitive closure of its nested relationships
+// materialized by the server, such as:
+//
+//	definition group {
+//		// spicedb:materialize
+//		relation member: user | group#member
+//	}
+const MaterializeDirective = "spicedb:materialize"
+
+// IsMaterialized returns whether the relation is marked with the MaterializeDirective.
+func IsMaterialized(relation *core.Relation) bool {
+	for _, comment := range GetComments(relation.Metadata) {
+		for _, line := range strings.Split(comment, "\n") {
+			if strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), "//")) == MaterializeDirective {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // GetRelationKind returns the kind of the relation.
 func GetRelationKind(relation *core.Relation) iv1.RelationMetadata_RelationKind {
 	metadata := relation.Metadata
@@ -61,3 +61,30 @@ func TestMetadata(t *testing.T) {
 
 	require.Equal(iv1.RelationMetadata_PERMISSION, GetRelationKind(ns.Relation[0]))
 }
+
+func TestIsMaterialized(t *testing.T) {
+	testCases := []struct {
+		name     string
+		comments []string
+		expected bool
+	}{
+		{"no comments", nil, false},
+		{"unrelated comment", []string{"// the members of the group"}, false},
+		{"directive", []string{"// spicedb:materialize"}, true},
+		{"directive among comments", []string{"// the members of the group\n// spicedb:materialize"}, true},
+		{"directive in text", []string{"// do not spicedb:materialize"}, false},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			relation := &core.Relation{Name: "member"}
+			for _, comment := range tc.comments {
+				var err error
+				relation.Metadata, err = AddComment(relation.Metadata, comment)
+				require.NoError(t, err)
+			}
+			require.Equal(t, tc.expected, IsMaterialized(relation))
+		})
+	}
+}
@@ -109,19 +109,16 @@ func PopulateFromFiles(ds datastore.Datastore, filePaths []string) (*PopulatedVa
 		}
 
 		// Load the validation tuples/relationships.
-		var updates []*v1.RelationshipUpdate
+		var rels []*v1.Relationship
 		seenTuples := map[string]bool{}
 		for _, rel := range parsed.Relationships.Relationships {
-			updates = append(updates, &v1.RelationshipUpdate{
-				Operation:    v1.RelationshipUpdate_OPERATION_CREATE,
-				Relationship: rel,
-			})
+			rels = append(rels, rel)
 			tpl := tuple.MustFromRelationship(rel)
 			tuples = append(tuples, tpl)
 			seenTuples[tuple.String(tpl)] = true
 		}
 
-		log.Info().Str("filePath", filePath).Int("tupleCount", len(updates)+len(parsed.ValidationTuples)).Msg("Loading test data")
+		log.Info().Str("filePath", filePath).Int("tupleCount", len(rels)+len(parsed.ValidationTuples)).Msg("Loading test data")
 		for index, validationTuple := range parsed.ValidationTuples {
 			tpl := tuple.Parse(validationTuple)
 			if tpl == nil {
@@ -135,14 +132,12 @@ func PopulateFromFiles(ds datastore.Datastore, filePaths []string) (*PopulatedVa
 			seenTuples[tuple.String(tpl)] = true
 
 			tuples = append(tuples, tpl)
-			updates = append(updates, &v1.RelationshipUpdate{
-				Operation:    v1.RelationshipUpdate_OPERATION_CREATE,
-				Relationship: tuple.MustToRelationship(tpl),
-			})
+			rels = append(rels, tuple.MustToRelationship(tpl))
 		}
 
 		wrevision, terr := ds.ReadWriteTx(context.Background(), func(ctx context.Context, rwt datastore.ReadWriteTransaction) error {
-			return rwt.WriteRelationships(updates)
+			_, err := datastore.BulkLoad(ctx, rwt, datastore.NewBulkSourceFromSlice(rels))
+			return err
 		})
 		if terr != nil {
 			return nil, decimal.Zero, fmt.Errorf("error when loading validation tuples from file %s: %w", filePath, terr)